@@ -0,0 +1,111 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/SnellerInc/sneller/expr"
+)
+
+// A continuation token lets a client page through
+// the results of a query with a LIMIT clause:
+// the response to a query with LIMIT n (OFFSET m)
+// includes a token for the rows from offset m+n,
+// and the same query text sent along with that
+// token produces the next page of results.
+//
+// The token records the ETag of the query as
+// it was sent by the client, so it can only be
+// used as long as that ETag does not change
+// (i.e. for the same query over the same data).
+
+var errBadContinuation = errors.New("invalid continuation token")
+
+// pageable returns the top-level SELECT of q
+// if it has a LIMIT clause and an ORDER BY clause
+// that fully orders the result, or nil otherwise
+//
+// pages of a result that is not fully ordered
+// could overlap or skip rows, since the rows
+// that precede the offset are not well-defined
+func pageable(q *expr.Query) *expr.Select {
+	sel, ok := q.Body.(*expr.Select)
+	if !ok || sel.Limit == nil || q.Into != nil || len(sel.OrderBy) == 0 {
+		return nil
+	}
+	// each group (or distinct row) is
+	// identified by its keys, so all of
+	// them have to be ordered
+	for i := range sel.GroupBy {
+		if !ordered(sel, sel.GroupBy[i]) {
+			return nil
+		}
+	}
+	// otherwise each row is only identified
+	// by all of its columns (so SELECT * is
+	// never fully ordered)
+	if sel.Distinct || (len(sel.GroupBy) == 0 && len(sel.DistinctExpr) == 0) {
+		for i := range sel.Columns {
+			if !ordered(sel, sel.Columns[i]) {
+				return nil
+			}
+		}
+	}
+	for i := range sel.DistinctExpr {
+		if !ordered(sel, expr.Bind(sel.DistinctExpr[i], "")) {
+			return nil
+		}
+	}
+	return sel
+}
+
+// ordered returns whether b is
+// one of the ORDER BY columns of sel
+func ordered(sel *expr.Select, b expr.Binding) bool {
+	for i := range sel.OrderBy {
+		col := sel.OrderBy[i].Column
+		if expr.Equivalent(col, b.Expr) ||
+			(b.Result() != "" && expr.IsIdentifier(col, b.Result())) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeContinuation produces a token for
+// the rows of a query starting at offset
+func encodeContinuation(eTag string, offset int64) string {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(offset))
+	buf := append(tmp[:n:n], eTag...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// decodeContinuation decodes a token
+// produced by encodeContinuation
+func decodeContinuation(token string) (eTag string, offset int64, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, errBadContinuation
+	}
+	off, n := binary.Uvarint(buf)
+	if n <= 0 || int64(off) < 0 {
+		return "", 0, errBadContinuation
+	}
+	return string(buf[n:]), int64(off), nil
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr/partiql"
+)
+
+func TestPageable(t *testing.T) {
+	tcs := []struct {
+		query string
+		ok    bool
+	}{
+		{`SELECT x FROM t ORDER BY x LIMIT 10`, true},
+		{`SELECT x FROM t LIMIT 10`, false},
+		// x can have duplicates, so pages
+		// could overlap unless y is ordered too
+		{`SELECT x, y FROM t ORDER BY x LIMIT 10`, false},
+		{`SELECT x, y FROM t ORDER BY x, y DESC LIMIT 10`, true},
+		{`SELECT * FROM t ORDER BY x LIMIT 10`, false},
+		{`SELECT x FROM t ORDER BY x`, false},
+		{`SELECT x, COUNT(*) FROM t GROUP BY x ORDER BY x LIMIT 10`, true},
+		{`SELECT x, COUNT(*) AS c FROM t GROUP BY x ORDER BY c DESC, x LIMIT 10`, true},
+		{`SELECT x, y, COUNT(*) AS c FROM t GROUP BY x, y ORDER BY c DESC, x LIMIT 10`, false},
+		{`SELECT x, COUNT(*) AS c FROM t GROUP BY x ORDER BY c LIMIT 10`, false},
+		{`SELECT a + 1 AS k, COUNT(*) FROM t GROUP BY a + 1 AS k ORDER BY k LIMIT 10`, true},
+		{`SELECT DISTINCT x, y FROM t ORDER BY x, y LIMIT 10`, true},
+		{`SELECT DISTINCT x, y FROM t ORDER BY x LIMIT 10`, false},
+	}
+	for i := range tcs {
+		q, err := partiql.Parse([]byte(tcs[i].query))
+		if err != nil {
+			t.Fatalf("%s: %s", tcs[i].query, err)
+		}
+		if got := pageable(q) != nil; got != tcs[i].ok {
+			t.Errorf("%s: pageable = %v", tcs[i].query, got)
+		}
+	}
+}
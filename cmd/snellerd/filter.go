@@ -175,77 +175,141 @@ func logical(left filter, op expr.LogicalOp, right filter) (filter, bool) {
 // compileComparisonFilter compiles a filter from a
 // comparison expression.
 func compileComparisonFilter(e *expr.Comparison) (filter, bool) {
-	fn, ok1 := e.Left.(*expr.Builtin)
-	im, ok2 := e.Right.(expr.Integer)
 	op := e.Op
-	if !ok1 || !ok2 {
-		fn, ok1 = e.Right.(*expr.Builtin)
-		im, ok2 = e.Left.(expr.Integer)
-		if !ok1 || !ok2 {
+	path, lo, hi, ok := timeRange(e.Left, e.Right)
+	if !ok {
+		path, lo, hi, ok = timeRange(e.Right, e.Left)
+		if !ok {
 			return nil, false
 		}
 		op = e.Op.Flip()
 	}
-	if len(fn.Args) != 1 {
-		return nil, false
-	}
-	path, ok := fn.Args[0].(*expr.Path)
-	if !ok {
+	cmp := compareFunc(op, lo, hi)
+	if cmp == nil {
 		return nil, false
 	}
-	switch fn.Func {
-	case expr.DateToUnixEpoch:
-		when := date.Unix(int64(im), 0)
-		cmp := compareFunc(op, when)
-		if cmp == nil {
-			return nil, false
+	return pathFilter(path, cmp), true
+}
+
+// timeRange determines the range of times [lo, hi)
+// of path for which the expression fn (which is
+// non-decreasing in time) yields the constant k
+func timeRange(fn, k expr.Node) (path *expr.Path, lo, hi date.Time, ok bool) {
+	switch k := k.(type) {
+	case *expr.Timestamp:
+		if path, ok := fn.(*expr.Path); ok {
+			return path, k.Value, k.Value.Add(time.Microsecond), true
 		}
-		return pathFilter(path, cmp), true
-	case expr.DateToUnixMicro:
-		when := date.UnixMicro(int64(im))
-		cmp := compareFunc(op, when)
-		if cmp == nil {
-			return nil, false
+		part, path, ok := truncPath(fn)
+		if !ok {
+			break
 		}
-		return pathFilter(path, cmp), true
+		// DATE_TRUNC(part, path) yields k only
+		// if k is the start of a part
+		lo, hi = k.Value, nextPart(part, k.Value)
+		if truncTime(part, lo).Before(lo) {
+			lo = hi
+		}
+		return path, lo, hi, true
+	case expr.Integer:
+		b, ok := fn.(*expr.Builtin)
+		if !ok || len(b.Args) != 1 {
+			break
+		}
+		path, ok := b.Args[0].(*expr.Path)
+		if !ok {
+			break
+		}
+		// start(n) is the earliest time
+		// for which b yields at least n
+		var start func(n int64) date.Time
+		switch b.Func {
+		case expr.DateToUnixEpoch:
+			start = func(n int64) date.Time { return date.Unix(n, 0) }
+		case expr.DateToUnixMicro:
+			start = date.UnixMicro
+		case expr.DateExtractYear, expr.DateExtractISOYear:
+			if k < 0 || k >= 9999 {
+				return nil, lo, hi, false
+			}
+			start = func(n int64) date.Time {
+				if b.Func == expr.DateExtractISOYear {
+					// the first ISO week contains January 4th
+					return truncTime(expr.ISOYear, date.Date(int(n), 1, 4, 0, 0, 0, 0))
+				}
+				return date.Date(int(n), 1, 1, 0, 0, 0, 0)
+			}
+		default:
+			return nil, lo, hi, false
+		}
+		return path, start(int64(k)), start(int64(k) + 1), true
 	}
-	return nil, false
+	return nil, lo, hi, false
 }
 
 // compareFunc returns a function that returns whether
-// "min op v" always, maybe, or never evaluates to true
-// for any value v in the range [min, max] (inclusive).
-// This returns nil if op is not applicable to integers
-// (LIKE or ILIKE).
-func compareFunc(op expr.CmpOp, when date.Time) func(*blockfmt.TimeIndex, int) ternary {
-	const epsilon = time.Microsecond
+// "f(v) op k" always, maybe, or never evaluates to true
+// for the values v in a block, where f is a non-decreasing
+// function and [lo, hi) is the range of times for which
+// f yields k. This returns nil if op is not applicable
+// to integers (LIKE or ILIKE).
+func compareFunc(op expr.CmpOp, lo, hi date.Time) func(*blockfmt.TimeIndex, int) ternary {
 	switch op {
 	case expr.Equals:
+		after, before := pickAfter(lo), pickBefore(hi)
 		return func(i *blockfmt.TimeIndex, n int) ternary {
-			if i.Contains(when) {
-				return maybe
+			a, b := after(i, n), before(i, n)
+			if a == never || b == never {
+				return never
 			}
-			return never
+			if a == always && b == always {
+				return always
+			}
+			return maybe
 		}
 	case expr.NotEquals:
 		// complicated due to partiql semantics
 		return nil
 	case expr.Less:
-		// when < expr
-		return pickAfter(when.Add(-epsilon))
+		return pickBefore(lo)
 	case expr.LessEquals:
-		// when <= expr
-		return pickAfter(when)
+		return pickBefore(hi)
 	case expr.Greater:
-		// when > expr
-		return pickBefore(when)
+		return pickAfter(hi)
 	case expr.GreaterEquals:
-		// when >= expr
-		return pickBefore(when.Add(epsilon))
+		return pickAfter(lo)
 	}
 	return nil
 }
 
+// maxPartLength is the maximum length of each
+// date part; adding it to the start of a part
+// always yields a time within the next part
+var maxPartLength = [...]time.Duration{
+	expr.Microsecond: time.Microsecond,
+	expr.Millisecond: time.Millisecond,
+	expr.Second:      time.Second,
+	expr.Minute:      time.Minute,
+	expr.Hour:        time.Hour,
+	expr.Day:         24 * time.Hour,
+	expr.Month:       31 * 24 * time.Hour,
+	expr.Year:        366 * 24 * time.Hour,
+	expr.Quarter:     92 * 24 * time.Hour,
+	expr.Week:        7 * 24 * time.Hour,
+	expr.ISOWeek:     7 * 24 * time.Hour,
+	expr.ISOYear:     53 * 7 * 24 * time.Hour,
+}
+
+func truncTime(part expr.Timepart, t date.Time) date.Time {
+	return expr.DateTrunc(part, &expr.Timestamp{Value: t}).(*expr.Timestamp).Value
+}
+
+// nextPart returns the start of the date part
+// that follows the one that contains t
+func nextPart(part expr.Timepart, t date.Time) date.Time {
+	return truncTime(part, truncTime(part, t).Add(maxPartLength[part]))
+}
+
 // compileBuiltin compiles a filter from a builtin
 // expression.
 func compileBuiltin(e *expr.Builtin) (filter, bool) {
@@ -288,6 +352,20 @@ func pickBefore(when date.Time) func(*blockfmt.TimeIndex, int) ternary {
 	}
 }
 
+// truncPath returns the date part and the path
+// of a DATE_TRUNC(part, path) expression
+func truncPath(e expr.Node) (expr.Timepart, *expr.Path, bool) {
+	b, ok := e.(*expr.Builtin)
+	if !ok || b.Func < expr.DateTruncMicrosecond || b.Func > expr.DateTruncISOYear || len(b.Args) != 1 {
+		return 0, nil, false
+	}
+	path, ok := b.Args[0].(*expr.Path)
+	if !ok {
+		return 0, nil, false
+	}
+	return expr.Timepart(b.Func - expr.DateTruncMicrosecond), path, true
+}
+
 // compileBefore compiles a filter from a BEFORE
 // expression.
 func compileBefore(args []expr.Node) (filter, bool) {
@@ -302,20 +380,12 @@ func compileBefore(args []expr.Node) (filter, bool) {
 		}
 		return nil, false
 	}
-	switch lhs := args[0].(type) {
-	case *expr.Timestamp:
-		switch rhs := args[1].(type) {
-		case *expr.Path:
-			return pathFilter(rhs, pickAfter(lhs.Value)), true
-		}
-	case *expr.Path:
-		switch rhs := args[1].(type) {
-		case *expr.Timestamp:
-			// BEFORE(path, ts)
-			return pathFilter(lhs, pickBefore(rhs.Value)), true
-		}
-	}
-	return nil, false
+	// BEFORE(a, b) is equivalent to a < b
+	return compileComparisonFilter(&expr.Comparison{
+		Op:    expr.Less,
+		Left:  args[0],
+		Right: args[1],
+	})
 }
 
 func flatpath(path *expr.Path) ([]string, bool) {
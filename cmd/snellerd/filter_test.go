@@ -106,6 +106,13 @@ func TestCompileFilter(t *testing.T) {
 
 	// Test some path expressions against ranges.
 	now := date.Now().Truncate(time.Second)
+	ts := func(s string) date.Time {
+		t, ok := date.Parse([]byte(s))
+		if !ok {
+			panic("bad timestamp " + s)
+		}
+		return t
+	}
 	type check struct {
 		ranges []blockfmt.Range
 		expect ternary
@@ -136,14 +143,13 @@ func TestCompileFilter(t *testing.T) {
 			)},
 			expect: maybe,
 		}, {
-			// Right at the max; before(now, now) is false,
-			// but we use inclusive ranges, so "maybe"
+			// Right at the max; before(now, now) is false
 			ranges: []blockfmt.Range{blockfmt.NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Hour)),
 				ion.Timestamp(now),
 			)},
-			expect: maybe,
+			expect: never,
 		}, {
 			// Before the range -> always
 			ranges: []blockfmt.Range{blockfmt.NewRange(
@@ -320,7 +326,7 @@ func TestCompileFilter(t *testing.T) {
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
 			)},
-			expect: always,
+			expect: never,
 		}, {
 			// Before the range
 			ranges: []blockfmt.Range{blockfmt.NewRange(
@@ -328,7 +334,7 @@ func TestCompileFilter(t *testing.T) {
 				ion.Timestamp(now.Add(1*time.Minute)),
 				ion.Timestamp(now.Add(2*time.Minute)),
 			)},
-			expect: never,
+			expect: always,
 		}, {
 			// Right at min
 			ranges: []blockfmt.Range{blockfmt.NewRange(
@@ -349,13 +355,22 @@ func TestCompileFilter(t *testing.T) {
 			)},
 			expect: maybe,
 		}, {
-			// Right below min
+			// Right above min; TO_UNIX_EPOCH(bar)
+			// truncates to now at the start
 			ranges: []blockfmt.Range{blockfmt.NewRange(
 				[]string{"bar"},
 				ion.Timestamp(now.Add(time.Millisecond)),
 				ion.Timestamp(now.Add(time.Minute)),
 			)},
-			expect: never,
+			expect: maybe,
+		}, {
+			// Starting after the next second
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"bar"},
+				ion.Timestamp(now.Add(time.Second+time.Millisecond)),
+				ion.Timestamp(now.Add(time.Minute)),
+			)},
+			expect: always,
 		}, {
 			// After the range
 			ranges: []blockfmt.Range{blockfmt.NewRange(
@@ -363,7 +378,7 @@ func TestCompileFilter(t *testing.T) {
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
 			)},
-			expect: always,
+			expect: never,
 		}},
 	}, {
 		expr: parseExpr("TO_UNIX_MICRO(foo) = %d", now.UnixMicro()),
@@ -392,6 +407,209 @@ func TestCompileFilter(t *testing.T) {
 			)},
 			expect: never,
 		}},
+	}, {
+		expr: parseExpr("foo >= %s", now),
+		checks: []check{{
+			// Right at the max
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(-time.Hour)),
+				ion.Timestamp(now),
+			)},
+			expect: maybe,
+		}, {
+			// Just after the min
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(time.Second)),
+				ion.Timestamp(now.Add(time.Hour)),
+			)},
+			expect: always,
+		}, {
+			// Before the range
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(-2*time.Hour)),
+				ion.Timestamp(now.Add(-time.Hour)),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("%s > foo", now),
+		checks: []check{{
+			// Just after the min
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(time.Second)),
+				ion.Timestamp(now.Add(time.Hour)),
+			)},
+			expect: never,
+		}, {
+			// Right at the max
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(-time.Hour)),
+				ion.Timestamp(now),
+			)},
+			expect: maybe,
+		}, {
+			// Before the range
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(-2*time.Hour)),
+				ion.Timestamp(now.Add(-time.Hour)),
+			)},
+			expect: always,
+		}},
+	}, {
+		expr: parseExpr("foo BETWEEN %s AND %s", now, now.Add(time.Hour)),
+		checks: []check{{
+			// Within the range
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(time.Second)),
+				ion.Timestamp(now.Add(time.Hour)),
+			)},
+			expect: always,
+		}, {
+			// Overlapping the end of the range
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(time.Minute)),
+				ion.Timestamp(now.Add(2*time.Hour)),
+			)},
+			expect: maybe,
+		}, {
+			// After the range
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(now.Add(time.Hour+time.Second)),
+				ion.Timestamp(now.Add(2*time.Hour)),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("DATE_TRUNC(DAY, foo) = `2022-01-05T00:00:00Z`"),
+		checks: []check{{
+			// Within the day
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-01-05T00:00:01Z")),
+				ion.Timestamp(ts("2022-01-05T23:59:59Z")),
+			)},
+			expect: always,
+		}, {
+			// The previous day
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-01-04T00:00:00Z")),
+				ion.Timestamp(ts("2022-01-04T23:59:59Z")),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("DATE_TRUNC(DAY, foo) = `2022-01-05T12:00:00Z`"),
+		checks: []check{{
+			// Never the start of a day
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-01-05T00:00:00Z")),
+				ion.Timestamp(ts("2022-01-05T23:59:59Z")),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("EXTRACT(YEAR FROM foo) = 2022"),
+		checks: []check{{
+			// Within the year
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-03-01T00:00:00Z")),
+				ion.Timestamp(ts("2022-04-01T00:00:00Z")),
+			)},
+			expect: always,
+		}, {
+			// Across the start of the year
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2021-12-31T23:00:00Z")),
+				ion.Timestamp(ts("2022-01-01T01:00:00Z")),
+			)},
+			expect: maybe,
+		}, {
+			// The next year
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2023-01-01T00:00:01Z")),
+				ion.Timestamp(ts("2023-02-01T00:00:00Z")),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("EXTRACT(ISOYEAR FROM foo) < 2021"),
+		checks: []check{{
+			// ISO year 2021 starts on 2021-01-04
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2020-12-30T00:00:00Z")),
+				ion.Timestamp(ts("2021-01-03T23:59:59Z")),
+			)},
+			expect: always,
+		}, {
+			// Within ISO year 2021
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2021-01-04T00:00:01Z")),
+				ion.Timestamp(ts("2021-02-01T00:00:00Z")),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("DATE_TRUNC(WEEK, foo) < `2022-01-05T00:00:00Z`"),
+		checks: []check{{
+			// The week starting on 2022-01-02
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-01-08T00:00:00Z")),
+				ion.Timestamp(ts("2022-01-08T23:59:59Z")),
+			)},
+			expect: always,
+		}, {
+			// The next week
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-01-09T00:00:01Z")),
+				ion.Timestamp(ts("2022-01-10T00:00:00Z")),
+			)},
+			expect: never,
+		}},
+	}, {
+		expr: parseExpr("DATE_TRUNC(QUARTER, foo) > `2022-04-01T00:00:00Z`"),
+		checks: []check{{
+			// Across the start of the next quarter
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-06-30T23:59:59Z")),
+				ion.Timestamp(ts("2022-07-01T00:00:00Z")),
+			)},
+			expect: maybe,
+		}, {
+			// Within the quarter
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-04-01T00:00:00Z")),
+				ion.Timestamp(ts("2022-06-30T00:00:00Z")),
+			)},
+			expect: never,
+		}, {
+			// Within the next quarter
+			ranges: []blockfmt.Range{blockfmt.NewRange(
+				[]string{"foo"},
+				ion.Timestamp(ts("2022-07-01T00:00:01Z")),
+				ion.Timestamp(ts("2022-08-01T00:00:00Z")),
+			)},
+			expect: always,
+		}},
 	}, {
 		expr:   expr.Bool(false),
 		checks: []check{{expect: never}},
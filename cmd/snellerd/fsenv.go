@@ -53,6 +53,9 @@ type fsEnv struct {
 	// keep the accumulated state here:
 	hash    hash.Hash
 	modtime date.Time
+
+	// spill permits queries to spill to disk
+	spill bool
 }
 
 func environ(t db.Tenant, dbname string) (cachedEnv, error) {
@@ -97,6 +100,11 @@ func (f *fsEnv) CacheValues() ([]byte, time.Time) {
 
 var _ plan.Indexer = (*fsEnv)(nil)
 
+var _ plan.Spiller = (*fsEnv)(nil)
+
+// Spill implements plan.Spiller.Spill
+func (f *fsEnv) Spill() bool { return f.spill }
+
 func (f *fsEnv) Index(p expr.Node) (plan.Index, error) {
 	return f.index(p)
 }
@@ -616,4 +616,119 @@ SELECT ROUND(SUM(total_amount)) AS "sum" FROM default.taxi WHERE VendorID = (SEL
 		}
 		checkTiming(t, res)
 	}
+
+	// page through results with continuation tokens
+	pagequery := `SELECT Ticket FROM default.parking WHERE Route = '2A75' AND IssueTime <= 1100 ORDER BY Ticket LIMIT 2`
+	pages := []string{
+		`[{"Ticket": 1106506402},{"Ticket": 1106506413}]`,
+		`[{"Ticket": 1106506424}]`,
+		`[]`,
+	}
+	token := ""
+	etags := make(map[string]bool)
+	for i := range pages {
+		r := rq.getQueryJSON("", pagequery)
+		if token != "" {
+			q := r.URL.Query()
+			q.Set("continuation", token)
+			r.URL.RawQuery = q.Encode()
+		}
+		res, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("page %d: status %s: %s", i, res.Status, got)
+		}
+		if string(got) != pages[i] {
+			t.Errorf("page %d: got %q, want %q", i, got, pages[i])
+		}
+		etag := res.Header.Get("ETag")
+		if etags[etag] {
+			t.Errorf("page %d: ETag %s already seen", i, etag)
+		}
+		etags[etag] = true
+		token = res.Header.Get("X-Sneller-Continuation")
+		if token == "" {
+			t.Fatalf("page %d: no continuation token", i)
+		}
+	}
+	// a token can only be used with the query
+	// that produced it
+	r := rq.getQueryJSON("", `SELECT Ticket FROM default.parking ORDER BY Ticket LIMIT 2`)
+	q := r.URL.Query()
+	q.Set("continuation", token)
+	r.URL.RawQuery = q.Encode()
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("stale token: got status %s", res.Status)
+	}
+	// results without a total order
+	// cannot be paged through
+	r = rq.getQueryJSON("", `SELECT Ticket FROM default.parking LIMIT 2`)
+	res, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unordered query: status %s", res.Status)
+	}
+	if tok := res.Header.Get("X-Sneller-Continuation"); tok != "" {
+		t.Errorf("unordered query: got continuation token %q", tok)
+	}
+	q = r.URL.Query()
+	q.Set("continuation", token)
+	r.URL.RawQuery = q.Encode()
+	res, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("unordered query with token: got status %s", res.Status)
+	}
+
+	// ORDER BY without LIMIT is only
+	// accepted if the query opts in to spilling
+	sortquery := `SELECT Ticket FROM default.parking WHERE Route = '2A75' AND IssueTime <= 1100 ORDER BY Ticket DESC`
+	for _, spill := range []bool{false, true} {
+		r := rq.getQueryJSON("", sortquery)
+		if spill {
+			q := r.URL.Query()
+			q.Set("spill", "")
+			r.URL.RawQuery = q.Encode()
+		}
+		res, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !spill {
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("without spill: got status %s: %s", res.Status, got)
+			}
+			continue
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("with spill: status %s: %s", res.Status, got)
+		}
+		want := `[{"Ticket": 1106506424},{"Ticket": 1106506413},{"Ticket": 1106506402}]`
+		if string(got) != want {
+			t.Errorf("with spill: got %q, want %q", got, want)
+		}
+	}
 }
@@ -137,6 +137,25 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	normalized := parsedQuery.Text()
+
+	// a continuation token resumes a query
+	// with a LIMIT clause at the next page
+	var contTag string
+	page := pageable(parsedQuery)
+	if token := r.URL.Query().Get("continuation"); token != "" {
+		if page == nil {
+			http.Error(w, "continuation requires a query with LIMIT and an ORDER BY that orders every row", http.StatusBadRequest)
+			return
+		}
+		var offset int64
+		contTag, offset, err = decodeContinuation(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		off := expr.Integer(offset)
+		page.Offset = &off
+	}
 	redacted := parsedQuery.Text()
 
 	var workerID tnproto.ID
@@ -149,6 +168,12 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		s.logger.Printf("refusing query: %s", err)
 		return
 	}
+	// queries that would otherwise be rejected
+	// for their memory use (i.e. an ORDER BY
+	// without a LIMIT) have to opt in to spilling
+	if f, ok := planEnv.(*fsEnv); ok {
+		f.spill = r.URL.Query().Has("spill")
+	}
 	endPoints := s.peers.Get()
 
 	queryID := uuid.New()
@@ -176,12 +201,30 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 	planHash, newestBlobTime := planEnv.CacheValues()
 
 	// hash the tenant/query/plan/format to an eTag
-	hasher := sha256.New()
-	hasher.Write([]byte(tenantCreds.ID()))
-	io.WriteString(hasher, normalized)
-	hasher.Write(planHash)
-	hasher.Write([]byte{byte(encodingFormat)})
-	eTag := `"` + base64.RawStdEncoding.EncodeToString(hasher.Sum(nil)) + `"`
+	etag := func(text string) string {
+		hasher := sha256.New()
+		hasher.Write([]byte(tenantCreds.ID()))
+		io.WriteString(hasher, text)
+		hasher.Write(planHash)
+		hasher.Write([]byte{byte(encodingFormat)})
+		return `"` + base64.RawStdEncoding.EncodeToString(hasher.Sum(nil)) + `"`
+	}
+	eTag := etag(normalized)
+	if page != nil {
+		// continuation tokens are tied to the eTag
+		// of the query text sent by the client,
+		// and each page has an eTag of its own
+		if contTag != "" && contTag != eTag {
+			http.Error(w, "continuation token is stale", http.StatusPreconditionFailed)
+			return
+		}
+		offset := int64(0)
+		if page.Offset != nil {
+			offset = int64(*page.Offset)
+		}
+		w.Header().Add("X-Sneller-Continuation", encodeContinuation(eTag, offset+int64(*page.Limit)))
+		eTag = etag(redacted)
+	}
 
 	// Add the ETag to the response
 	w.Header().Add("ETag", eTag)
@@ -15,7 +15,10 @@
 // Package date implements optimized date-parsing routines
 // specific to the date formats that we support.
 //
-// Currently, only RFC3339Nano dates are supported.
+// Parse recognizes RFC3339Nano dates, and a Format
+// parses and formats strftime-style layouts.
+// A Zone maps times to and from the local time
+// of a time zone from the tz database.
 package date
 
 //go:generate ragel -Z -G2 date.rl
@@ -0,0 +1,303 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package date
+
+import (
+	"fmt"
+)
+
+// FormatKind is the kind of a FormatItem
+type FormatKind uint8
+
+const (
+	// FormatLiteral matches the byte Lit
+	FormatLiteral FormatKind = iota
+	// FormatYear is %Y: a 4-digit year
+	FormatYear
+	// FormatMonth is %m: a 2-digit month
+	FormatMonth
+	// FormatDay is %d: a 2-digit day of the month
+	FormatDay
+	// FormatDaySpace is %e: a day of the month
+	// that is padded with a space rather than a zero
+	FormatDaySpace
+	// FormatHour is %H: a 2-digit hour
+	FormatHour
+	// FormatMinute is %M: a 2-digit minute
+	FormatMinute
+	// FormatSecond is %S: a 2-digit second
+	FormatSecond
+	// FormatFraction is %f: the fractional seconds
+	// (formatted as 6 digits; parsed from 1 to 9 digits)
+	FormatFraction
+	// FormatMonthName is %b: an abbreviated month name
+	FormatMonthName
+	// FormatZone is %z: a UTC offset as +hhmm
+	// (parsed from +hhmm, +hh:mm, or Z)
+	FormatZone
+)
+
+// FormatItem is one element of a Format
+type FormatItem struct {
+	Kind FormatKind
+	Lit  byte
+}
+
+// Format is a parsed strftime-style format string
+type Format []FormatItem
+
+// MonthNames are the abbreviated month names
+// used by %b
+var MonthNames = [12]string{
+	"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+	"Jul", "Aug", "Sep", "Oct", "Nov", "Dec",
+}
+
+// ParseFormat parses a strftime-style format string.
+// The supported conversions are %Y, %m, %d, %e, %H,
+// %M, %S, %f, %b (or %h), %z, %F (equivalent to %Y-%m-%d),
+// %T (equivalent to %H:%M:%S), and %% (a literal %).
+// Every other character is matched literally.
+func ParseFormat(f string) (Format, error) {
+	var out Format
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			out = append(out, FormatItem{Kind: FormatLiteral, Lit: f[i]})
+			continue
+		}
+		i++
+		if i == len(f) {
+			return nil, fmt.Errorf("format %q ends with %%", f)
+		}
+		switch f[i] {
+		case 'Y':
+			out = append(out, FormatItem{Kind: FormatYear})
+		case 'm':
+			out = append(out, FormatItem{Kind: FormatMonth})
+		case 'd':
+			out = append(out, FormatItem{Kind: FormatDay})
+		case 'e':
+			out = append(out, FormatItem{Kind: FormatDaySpace})
+		case 'H':
+			out = append(out, FormatItem{Kind: FormatHour})
+		case 'M':
+			out = append(out, FormatItem{Kind: FormatMinute})
+		case 'S':
+			out = append(out, FormatItem{Kind: FormatSecond})
+		case 'f':
+			out = append(out, FormatItem{Kind: FormatFraction})
+		case 'b', 'h':
+			out = append(out, FormatItem{Kind: FormatMonthName})
+		case 'z':
+			out = append(out, FormatItem{Kind: FormatZone})
+		case 'F':
+			out = append(out,
+				FormatItem{Kind: FormatYear},
+				FormatItem{Kind: FormatLiteral, Lit: '-'},
+				FormatItem{Kind: FormatMonth},
+				FormatItem{Kind: FormatLiteral, Lit: '-'},
+				FormatItem{Kind: FormatDay})
+		case 'T':
+			out = append(out,
+				FormatItem{Kind: FormatHour},
+				FormatItem{Kind: FormatLiteral, Lit: ':'},
+				FormatItem{Kind: FormatMinute},
+				FormatItem{Kind: FormatLiteral, Lit: ':'},
+				FormatItem{Kind: FormatSecond})
+		case '%':
+			out = append(out, FormatItem{Kind: FormatLiteral, Lit: '%'})
+		default:
+			return nil, fmt.Errorf("format %q: unsupported conversion %%%c", f, f[i])
+		}
+	}
+	return out, nil
+}
+
+// Width returns the length of the
+// text produced by f.Append
+func (f Format) Width() int {
+	n := 0
+	for i := range f {
+		switch f[i].Kind {
+		case FormatLiteral:
+			n++
+		case FormatYear:
+			n += 4
+		case FormatFraction:
+			n += 6
+		case FormatMonthName:
+			n += 3
+		case FormatZone:
+			n += 5
+		default:
+			n += 2
+		}
+	}
+	return n
+}
+
+// Append appends t formatted according to f to dst.
+// Times are always formatted in UTC. Append returns
+// false if the year of t is not between 0 and 9999.
+func (f Format) Append(dst []byte, t Time) ([]byte, bool) {
+	if t.Year() < 0 || t.Year() > 9999 {
+		return dst, false
+	}
+	for i := range f {
+		switch f[i].Kind {
+		case FormatLiteral:
+			dst = append(dst, f[i].Lit)
+		case FormatYear:
+			dst = appendInt(dst, t.Year(), 4, false)
+		case FormatMonth:
+			dst = appendInt(dst, t.Month(), 2, false)
+		case FormatDay:
+			dst = appendInt(dst, t.Day(), 2, false)
+		case FormatDaySpace:
+			if t.Day() < 10 {
+				dst = append(dst, ' ')
+			}
+			dst = appendInt(dst, t.Day(), 1, false)
+		case FormatHour:
+			dst = appendInt(dst, t.Hour(), 2, false)
+		case FormatMinute:
+			dst = appendInt(dst, t.Minute(), 2, false)
+		case FormatSecond:
+			dst = appendInt(dst, t.Second(), 2, false)
+		case FormatFraction:
+			dst = appendInt(dst, t.Nanosecond()/1000, 6, false)
+		case FormatMonthName:
+			dst = append(dst, MonthNames[t.Month()-1]...)
+		case FormatZone:
+			dst = append(dst, "+0000"...)
+		}
+	}
+	return dst, true
+}
+
+// digits parses between 1 and max decimal digits
+// from the start of s; it returns the value and
+// the number of digits, or 0 digits if s does not
+// start with a digit
+func digits(s []byte, max int) (val, n int) {
+	for n < max && n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		val = val*10 + int(s[n]-'0')
+		n++
+	}
+	return val, n
+}
+
+// Parse parses a time from s according to f;
+// all of s must match f. Components that are
+// not part of f default to the Unix epoch.
+// Parse returns false if s does not match f or
+// describes a date or time that does not exist.
+//
+// Numeric components may have fewer digits than
+// they are formatted with (so "%m/%d" matches "1/2"),
+// %e may start with a space, and %b is matched
+// without regard to case.
+func (f Format) Parse(s []byte) (Time, bool) {
+	year, month, day := 1970, 1, 1
+	hour, min, sec, us, offset := 0, 0, 0, 0, 0
+	for i := range f {
+		var n int
+		switch f[i].Kind {
+		case FormatLiteral:
+			if len(s) == 0 || s[0] != f[i].Lit {
+				return Time{}, false
+			}
+			n = 1
+		case FormatYear:
+			year, n = digits(s, 4)
+		case FormatMonth:
+			month, n = digits(s, 2)
+		case FormatDay:
+			day, n = digits(s, 2)
+		case FormatDaySpace:
+			if len(s) > 0 && s[0] == ' ' {
+				s = s[1:]
+			}
+			day, n = digits(s, 2)
+		case FormatHour:
+			hour, n = digits(s, 2)
+		case FormatMinute:
+			min, n = digits(s, 2)
+		case FormatSecond:
+			sec, n = digits(s, 2)
+		case FormatFraction:
+			us, n = digits(s, 9)
+			for j := n; j < 6; j++ {
+				us *= 10
+			}
+			for j := n; j > 6; j-- {
+				us /= 10
+			}
+		case FormatMonthName:
+			if len(s) < 3 {
+				return Time{}, false
+			}
+			month = 0
+			for j := range MonthNames {
+				m := MonthNames[j]
+				if s[0]|0x20 == m[0]|0x20 && s[1]|0x20 == m[1] && s[2]|0x20 == m[2] {
+					month = j + 1
+					n = 3
+					break
+				}
+			}
+		case FormatZone:
+			offset, n = parseZone(s)
+		}
+		if n == 0 {
+			return Time{}, false
+		}
+		s = s[n:]
+	}
+	if len(s) != 0 || month < 1 || month > 12 || day < 1 || day > daysin(year, month) ||
+		hour > 23 || min > 59 || sec > 59 {
+		return Time{}, false
+	}
+	return Date(year, month, day, hour, min, sec-offset, us*1000), true
+}
+
+// parseZone parses a UTC offset of the form
+// Z, +hhmm, or +hh:mm and returns the offset
+// in seconds and the number of bytes consumed
+func parseZone(s []byte) (offset, n int) {
+	if len(s) > 0 && (s[0] == 'Z' || s[0] == 'z') {
+		return 0, 1
+	}
+	if len(s) < 5 || (s[0] != '+' && s[0] != '-') {
+		return 0, 0
+	}
+	hh, nh := digits(s[1:], 2)
+	if nh != 2 {
+		return 0, 0
+	}
+	n = 3
+	if s[3] == ':' {
+		n++
+	}
+	mm, nm := digits(s[n:], 2)
+	if nm != 2 || hh > 23 || mm > 59 {
+		return 0, 0
+	}
+	offset = hh*3600 + mm*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, n + 2
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package date
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestFormatParse(t *testing.T) {
+	tcs := []struct {
+		format, in string
+		want       string // RFC3339, or "" if in does not match
+	}{
+		{"%d/%b/%Y:%H:%M:%S %z", "10/Oct/2000:13:55:36 -0700", "2000-10-10T20:55:36Z"},
+		{"%d/%b/%Y:%H:%M:%S %z", "10/oct/2000:13:55:36 +05:30", "2000-10-10T08:25:36Z"},
+		{"%d/%b/%Y:%H:%M:%S %z", "10/Oct/2000:13:55:36", ""},
+		{"%F %T", "2022-03-04 05:06:07", "2022-03-04T05:06:07Z"},
+		{"%F %T", "2022-3-4 5:6:7", "2022-03-04T05:06:07Z"},
+		{"%F %T", "2022-03-04 05:06:07 ", ""},
+		{"%F", "2022-02-29", ""},
+		{"%F", "2024-02-29", "2024-02-29T00:00:00Z"},
+		{"%F", "2022-13-01", ""},
+		{"%T", "24:00:00", ""},
+		{"%T", "23:59:59", "1970-01-01T23:59:59Z"},
+		{"%Y%m%d", "20220304", "2022-03-04T00:00:00Z"},
+		{"%Y-%m-%dT%H:%M:%S.%fZ", "2022-03-04T05:06:07.5Z", "2022-03-04T05:06:07.5Z"},
+		{"%Y-%m-%dT%H:%M:%S.%f%z", "2022-03-04T05:06:07.123456789Z", "2022-03-04T05:06:07.123456Z"},
+		{"%b %e %T", "Mar  4 05:06:07", "1970-03-04T05:06:07Z"},
+		{"%b %e %T", "Mar 14 05:06:07", "1970-03-14T05:06:07Z"},
+		{"100%%", "100%", "1970-01-01T00:00:00Z"},
+		{"%Y", "", ""},
+	}
+	for _, tc := range tcs {
+		f, err := ParseFormat(tc.format)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.format, err)
+		}
+		got, ok := f.Parse([]byte(tc.in))
+		if tc.want == "" {
+			if ok {
+				t.Errorf("%s: %q: expected no match; got %s", tc.format, tc.in, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: %q: no match", tc.format, tc.in)
+			continue
+		}
+		want, err := time.Parse(time.RFC3339Nano, tc.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, err := range check(got, want) {
+			t.Errorf("%s: %q: %s: got %s want %s", tc.format, tc.in, err, got, want)
+		}
+	}
+	for _, bad := range []string{"%", "%Q", "abc%"} {
+		if _, err := ParseFormat(bad); err == nil {
+			t.Errorf("format %q: expected an error", bad)
+		}
+	}
+}
+
+func TestFormatAppend(t *testing.T) {
+	formats := []struct {
+		format, layout string
+		complete       bool // includes every component
+	}{
+		{"%Y-%m-%dT%H:%M:%S.%f%z", "2006-01-02T15:04:05.000000-0700", true},
+		{"%d/%b/%Y:%T %z", "02/Jan/2006:15:04:05 -0700", false},
+		{"%b %e %T", "Jan _2 15:04:05", false},
+		{"%F (%%)", "2006-01-02 (%)", false},
+	}
+	for _, ft := range formats {
+		f, err := ParseFormat(ft.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			ref := time.Unix(rand.Int63n(1<<36), rand.Int63n(1e9)).UTC()
+			got, ok := f.Append(nil, FromTime(ref))
+			if !ok {
+				t.Fatalf("%s: cannot format %s", ft.format, ref)
+			}
+			want := ref.Format(ft.layout)
+			if string(got) != want {
+				t.Fatalf("%s: got %q want %q", ft.format, got, want)
+			}
+			if len(got) != f.Width() {
+				t.Fatalf("%s: width %d != %d", ft.format, len(got), f.Width())
+			}
+			if !ft.complete {
+				continue
+			}
+			// formatted times (truncated to microseconds)
+			// should parse to the same time
+			back, ok := f.Parse(got)
+			if !ok {
+				t.Fatalf("%s: cannot parse %q", ft.format, got)
+			}
+			for _, err := range check(back, ref.Truncate(time.Microsecond)) {
+				t.Fatalf("%s: %s: %s != %s", ft.format, err, back, ref)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package date
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	// make sure time zones can be loaded
+	// even if the system has no tz database
+	_ "time/tzdata"
+)
+
+// The range of years for which the UTC offset
+// changes of a Zone are known. Outside of this
+// range, the first and last offsets apply.
+const (
+	ZoneMinYear = 1900
+	ZoneMaxYear = 2100
+)
+
+// Transition is a change of the UTC offset of a Zone
+type Transition struct {
+	// Start is the first Unix time (in microseconds)
+	// to which Offset applies
+	Start int64
+	// Offset is the UTC offset in seconds
+	Offset int
+}
+
+// A Zone is a time zone backed by the tz database
+type Zone struct {
+	name  string
+	trans []Transition // sorted by Start
+	local []Transition // see LocalTransitions
+}
+
+var zones sync.Map // zone name -> *Zone
+
+// UTC is the UTC time zone
+var UTC = FixedZone("UTC", 0)
+
+// FixedZone returns a Zone with the given name
+// that always has the given offset (in seconds)
+func FixedZone(name string, offset int) *Zone {
+	trans := []Transition{{Start: math.MinInt64, Offset: offset}}
+	return &Zone{name: name, trans: trans, local: trans}
+}
+
+// LoadZone returns the Zone with the given name.
+// The name is either a tz database name such as
+// "America/New_York", "UTC", or a fixed offset
+// of the form +hh:mm or -hh:mm.
+func LoadZone(name string) (*Zone, error) {
+	if z, ok := zones.Load(name); ok {
+		return z.(*Zone), nil
+	}
+	var z *Zone
+	if offset, n := parseZone([]byte(name)); n == len(name) && n > 1 {
+		z = FixedZone(name, offset)
+	} else if name == "" || name == "Local" {
+		// time.LoadLocation would return the local time zone
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	} else {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, err
+		}
+		z = zoneOf(name, loc)
+	}
+	actual, _ := zones.LoadOrStore(name, z)
+	return actual.(*Zone), nil
+}
+
+func zoneOf(name string, loc *time.Location) *Zone {
+	t := time.Date(ZoneMinYear, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(ZoneMaxYear+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, offset := t.Zone()
+	z := &Zone{name: name}
+	z.trans = append(z.trans, Transition{Start: math.MinInt64, Offset: offset})
+	for {
+		_, next := t.ZoneBounds()
+		if next.IsZero() || !next.Before(end) {
+			break
+		}
+		if !next.After(t) {
+			// ZoneBounds can stop at the start of a
+			// year when it applies the rules that follow
+			// the last explicit transition
+			t = t.Add(time.Second)
+			continue
+		}
+		t = next
+		_, offset = t.Zone()
+		if offset != z.trans[len(z.trans)-1].Offset {
+			z.trans = append(z.trans, Transition{Start: t.UnixMicro(), Offset: offset})
+		}
+	}
+	// a local time maps to the offset of the
+	// earlier transition up to the point where it
+	// is unambiguously past the later transition,
+	// so that repeated local times use the first
+	// offset and skipped local times use the
+	// offset from before the change
+	z.local = make([]Transition, len(z.trans))
+	z.local[0] = z.trans[0]
+	for i := 1; i < len(z.trans); i++ {
+		shift := z.trans[i].Offset
+		if prev := z.trans[i-1].Offset; prev > shift {
+			shift = prev
+		}
+		z.local[i] = Transition{
+			Start:  z.trans[i].Start + int64(shift)*1e6,
+			Offset: z.trans[i].Offset,
+		}
+	}
+	return z
+}
+
+// String returns the name of z
+func (z *Zone) String() string { return z.name }
+
+// Transitions returns the UTC offset changes of z.
+// The first transition starts at math.MinInt64.
+func (z *Zone) Transitions() []Transition { return z.trans }
+
+// LocalTransitions is like Transitions, but the
+// Start of each transition is a local time in z
+// (as returned by ToLocal) rather than a UTC time.
+// Local times that occur twice map to the first
+// offset, and local times that are skipped map to
+// the offset that was in effect before the skip.
+func (z *Zone) LocalTransitions() []Transition { return z.local }
+
+// Fixed returns whether the offset of z never changes
+func (z *Zone) Fixed() bool { return len(z.trans) == 1 }
+
+func search(trans []Transition, us int64) int {
+	i := sort.Search(len(trans), func(i int) bool {
+		return trans[i].Start > us
+	})
+	return trans[i-1].Offset
+}
+
+// Offset returns the UTC offset of z
+// at time t in seconds
+func (z *Zone) Offset(t Time) int {
+	return search(z.trans, t.UnixMicro())
+}
+
+// ToLocal returns the local time of t in z
+// as a Time whose components are the local
+// date and time
+func (z *Zone) ToLocal(t Time) Time {
+	us := t.UnixMicro()
+	return UnixMicro(us + int64(search(z.trans, us))*1e6)
+}
+
+// FromLocal is the inverse of ToLocal:
+// it returns the time at which the local
+// date and time in z is t
+func (z *Zone) FromLocal(t Time) Time {
+	us := t.UnixMicro()
+	return UnixMicro(us - int64(search(z.local, us))*1e6)
+}
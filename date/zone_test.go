@@ -0,0 +1,105 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package date
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestZone(t *testing.T) {
+	names := []string{
+		"UTC",
+		"America/New_York",
+		"Europe/Warsaw",
+		"Asia/Kolkata",
+		"Australia/Lord_Howe",
+		"America/Sao_Paulo",
+	}
+	start := time.Date(ZoneMinYear, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(ZoneMaxYear, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	for _, name := range names {
+		z, err := LoadZone(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10000; i++ {
+			ref := time.Unix(start+rand.Int63n(end-start), 0)
+			lt := ref.In(loc)
+			want := time.Date(lt.Year(), lt.Month(), lt.Day(), lt.Hour(), lt.Minute(), lt.Second(), 0, time.UTC)
+			got := z.ToLocal(FromTime(ref))
+			if !got.Equal(FromTime(want)) {
+				t.Fatalf("%s: ToLocal(%s) = %s, want %s", name, ref.UTC(), got, want)
+			}
+			// local times are ambiguous or skipped
+			// only when they are within the period
+			// of an offset change
+			_, off := lt.Zone()
+			_, before := lt.Add(-3 * time.Hour).Zone()
+			_, after := lt.Add(3 * time.Hour).Zone()
+			if off != before || off != after {
+				continue
+			}
+			back := z.FromLocal(got)
+			if !back.Equal(FromTime(ref)) {
+				t.Fatalf("%s: FromLocal(%s) = %s, want %s", name, got, back, ref.UTC())
+			}
+		}
+	}
+}
+
+func TestZoneTransition(t *testing.T) {
+	z, err := LoadZone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		local, utc string
+	}{
+		// skipped: 02:30 does not exist on 2022-03-13,
+		// so it is interpreted with the EST offset
+		{"2022-03-13T02:30:00Z", "2022-03-13T07:30:00Z"},
+		{"2022-03-13T01:59:59Z", "2022-03-13T06:59:59Z"},
+		{"2022-03-13T03:00:00Z", "2022-03-13T07:00:00Z"},
+		// repeated: 01:30 occurs twice on 2022-11-06,
+		// and the first (EDT) occurrence is used
+		{"2022-11-06T01:30:00Z", "2022-11-06T05:30:00Z"},
+		{"2022-11-06T02:00:00Z", "2022-11-06T07:00:00Z"},
+	}
+	for _, tc := range tcs {
+		local, _ := Parse([]byte(tc.local))
+		want, _ := Parse([]byte(tc.utc))
+		if got := z.FromLocal(local); !got.Equal(want) {
+			t.Errorf("FromLocal(%s) = %s, want %s", local, got, want)
+		}
+	}
+	for _, name := range []string{"+05:30", "-0800"} {
+		z, err := LoadZone(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !z.Fixed() {
+			t.Errorf("%s: not a fixed zone", name)
+		}
+	}
+	if _, err := LoadZone("Mars/Olympus_Mons"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -63,6 +63,40 @@ func TestFormat(t *testing.T) {
 			name: "foo.bar",
 			want: "",
 		},
+		{
+			name: "foo.json.bz2",
+			want: "json.bz2",
+		},
+		{
+			name: "2022/10/01/foo.csv.xz",
+			want: "csv.xz",
+		},
+		{
+			name: "export.logfmt.lz4",
+			want: "logfmt.lz4",
+		},
+		{
+			name: "vendor.json.zip",
+			want: "json.zip",
+		},
+		{
+			name: "foo.tsv.sz",
+			want: "tsv.sz",
+		},
+		{
+			explicit: "json.xz",
+			name:     "foo.gz",
+			want:     "json.xz",
+		},
+		{
+			explicit: "cloudtrail.json.zst",
+			name:     "foo.json.gz",
+			want:     "json.zst",
+		},
+		{
+			name: "foo.xz",
+			want: "",
+		},
 	}
 
 	for i := range cases {
@@ -112,6 +112,17 @@ type Builder struct {
 	// for newly-created index objects.
 	NewIndexScan bool
 
+	// TempDir is the directory in which inputs
+	// that have to be copied before they can be
+	// converted (zip archives and parquet files)
+	// are written. If TempDir is empty,
+	// os.TempDir() is used.
+	TempDir string
+	// MaxTempSize is the maximum size of such a copy.
+	// If MaxTempSize is zero,
+	// blockfmt.DefaultMaxTempSize is used.
+	MaxTempSize int64
+
 	// MaxInlineBytes is the maximum number
 	// of (decompressed) data bytes for which
 	// we should store references directly in
@@ -155,15 +166,19 @@ type Builder struct {
 //   1. If 'chosen' is the name of a known format,
 //      then that format is returned.
 //   2. If 'name' has a suffix that indicates a known format,
-//      then that format is returned.
+//      then that format is returned. (The suffix may include
+//      that of a compression codec, i.e. .json.xz or .csv.zip;
+//      see blockfmt.SuffixToFormat.)
 //   3. If b.Fallback is non-nil, then Fallback(name) is returned.
 // Otherwise, Format returns nil.
 func (b *Builder) Format(chosen, name string) blockfmt.RowFormat {
 	if chosen != "" {
-		if chosen == "cloudtrail.json.gz" {
+		if comp, ok := cutPrefix(chosen, "cloudtrail.json"); ok {
 			// this must come first, because otherwise
-			// name would match *.json.gz
-			return blockfmt.CloudtrailJSON(".gz")
+			// name would match *.json.gz, etc.
+			if f := blockfmt.CloudtrailJSON(comp); f != nil {
+				return f
+			}
 		}
 		if f := blockfmt.SuffixToFormat["."+chosen]; f != nil {
 			return f()
@@ -180,6 +195,13 @@ func (b *Builder) Format(chosen, name string) blockfmt.RowFormat {
 	return nil
 }
 
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
 func (b *Builder) logf(f string, args ...interface{}) {
 	if b.Logf != nil {
 		b.Logf(f, args...)
@@ -669,6 +691,9 @@ func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, l
 		Align:     st.conf.align(),
 		FlushMeta: st.conf.flushMeta(),
 		Comp:      st.conf.comp(),
+
+		TempDir:     st.conf.TempDir,
+		MaxTempSize: st.conf.MaxTempSize,
 	}
 
 	if prepend != nil {
@@ -710,6 +735,11 @@ func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, l
 		return err
 	}
 	st.conf.logf("table %s: wrote object %s ETag %s", st.table, fp, etag)
+	for i := range lst {
+		if lst[i].ParseErrors > 0 {
+			st.conf.logf("table %s: skipped %d malformed lines in %s", st.table, lst[i].ParseErrors, lst[i].Path)
+		}
+	}
 	buildtime := date.Now().Truncate(time.Microsecond)
 	if idx == nil {
 		idx = new(blockfmt.Index)
@@ -15,15 +15,16 @@
 package expr
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"math"
-	"net"
+	"net/netip"
 	"strings"
 	"unicode/utf8"
 
+	"golang.org/x/exp/slices"
+
 	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/regexp2"
 )
 
 func mismatch(want, got int) error {
@@ -116,6 +117,27 @@ const (
 	IsSubnetOf
 	SubString
 	SplitPart
+	StrPos
+	Replace
+	Reverse
+	Initcap
+	Repeat
+	Lpad
+	Rpad
+	StartsWith
+	EndsWith
+	Left
+	Right
+	RegexpExtract
+	RegexpReplace
+	RegexpCount
+	InetParse
+	InetTrunc
+	InetFamily
+
+	JSONParse
+	JSONExtract
+	ToJSONString
 
 	BitCount
 
@@ -173,6 +195,8 @@ const (
 	DateAddDay
 	DateAddMonth
 	DateAddYear
+	DateAddQuarter
+	DateAddWeek
 
 	DateDiffMicrosecond
 	DateDiffMillisecond
@@ -182,6 +206,8 @@ const (
 	DateDiffDay
 	DateDiffMonth
 	DateDiffYear
+	DateDiffQuarter
+	DateDiffWeek
 
 	DateExtractMicrosecond
 	DateExtractMillisecond
@@ -191,8 +217,19 @@ const (
 	DateExtractDay
 	DateExtractMonth
 	DateExtractYear
+	DateExtractQuarter
+	DateExtractWeek
+	DateExtractISOWeek
+	DateExtractISOYear
+	DateExtractDOW
+	DateExtractDOY
 	DateToUnixEpoch
 	DateToUnixMicro
+	FromUnixTime    // FROM_UNIXTIME(seconds)
+	FromUnixMicro   // FROM_UNIX_MICRO(microseconds)
+	ParseTimestamp  // PARSE_TIMESTAMP(fmt, str)
+	FormatTimestamp // FORMAT_TIMESTAMP(fmt, ts)
+	AtTimeZone      // ts AT TIME ZONE zone
 
 	DateTruncMicrosecond
 	DateTruncMillisecond
@@ -202,6 +239,10 @@ const (
 	DateTruncDay
 	DateTruncMonth
 	DateTruncYear
+	DateTruncQuarter
+	DateTruncWeek
+	DateTruncISOWeek
+	DateTruncISOYear
 
 	GeoHash
 	GeoTileX
@@ -211,6 +252,13 @@ const (
 
 	ObjectSize // SIZE(x)
 
+	ArrayLength   // ARRAY_LENGTH(list) or CARDINALITY(list)
+	ArrayContains // ARRAY_CONTAINS(list, value)
+	ArrayPosition // ARRAY_POSITION(list, value)
+	ArraySlice    // ARRAY_SLICE(list, from[, to])
+	ArrayDistinct // ARRAY_DISTINCT(list)
+	ArrayJoin     // ARRAY_JOIN(list, sep)
+
 	TableGlob
 	TablePattern
 
@@ -247,6 +295,26 @@ var name2Builtin = map[string]BuiltinOp{
 	"IS_SUBNET_OF":             IsSubnetOf,
 	"SUBSTRING":                SubString,
 	"SPLIT_PART":               SplitPart,
+	"STRPOS":                   StrPos,
+	"REPLACE":                  Replace,
+	"REVERSE":                  Reverse,
+	"INITCAP":                  Initcap,
+	"REPEAT":                   Repeat,
+	"LPAD":                     Lpad,
+	"RPAD":                     Rpad,
+	"STARTS_WITH":              StartsWith,
+	"ENDS_WITH":                EndsWith,
+	"LEFT":                     Left,
+	"RIGHT":                    Right,
+	"REGEXP_EXTRACT":           RegexpExtract,
+	"REGEXP_REPLACE":           RegexpReplace,
+	"REGEXP_COUNT":             RegexpCount,
+	"INET_PARSE":               InetParse,
+	"INET_TRUNC":               InetTrunc,
+	"INET_FAMILY":              InetFamily,
+	"JSON_PARSE":               JSONParse,
+	"JSON_EXTRACT":             JSONExtract,
+	"TO_JSON_STRING":           ToJSONString,
 	"BIT_COUNT":                BitCount,
 	"ABS":                      Abs,
 	"SIGN":                     Sign,
@@ -292,6 +360,8 @@ var name2Builtin = map[string]BuiltinOp{
 	"DATE_ADD_DAY":             DateAddDay,
 	"DATE_ADD_MONTH":           DateAddMonth,
 	"DATE_ADD_YEAR":            DateAddYear,
+	"DATE_ADD_QUARTER":         DateAddQuarter,
+	"DATE_ADD_WEEK":            DateAddWeek,
 	"DATE_DIFF_MICROSECOND":    DateDiffMicrosecond,
 	"DATE_DIFF_MILLISECOND":    DateDiffMillisecond,
 	"DATE_DIFF_SECOND":         DateDiffSecond,
@@ -300,6 +370,8 @@ var name2Builtin = map[string]BuiltinOp{
 	"DATE_DIFF_DAY":            DateDiffDay,
 	"DATE_DIFF_MONTH":          DateDiffMonth,
 	"DATE_DIFF_YEAR":           DateDiffYear,
+	"DATE_DIFF_QUARTER":        DateDiffQuarter,
+	"DATE_DIFF_WEEK":           DateDiffWeek,
 	"DATE_EXTRACT_MICROSECOND": DateExtractMicrosecond,
 	"DATE_EXTRACT_MILLISECOND": DateExtractMillisecond,
 	"DATE_EXTRACT_SECOND":      DateExtractSecond,
@@ -308,6 +380,12 @@ var name2Builtin = map[string]BuiltinOp{
 	"DATE_EXTRACT_DAY":         DateExtractDay,
 	"DATE_EXTRACT_MONTH":       DateExtractMonth,
 	"DATE_EXTRACT_YEAR":        DateExtractYear,
+	"DATE_EXTRACT_QUARTER":     DateExtractQuarter,
+	"DATE_EXTRACT_WEEK":        DateExtractWeek,
+	"DATE_EXTRACT_ISOWEEK":     DateExtractISOWeek,
+	"DATE_EXTRACT_ISOYEAR":     DateExtractISOYear,
+	"DATE_EXTRACT_DOW":         DateExtractDOW,
+	"DATE_EXTRACT_DOY":         DateExtractDOY,
 	"DATE_TRUNC_MICROSECOND":   DateTruncMicrosecond,
 	"DATE_TRUNC_MILLISECOND":   DateTruncMillisecond,
 	"DATE_TRUNC_SECOND":        DateTruncSecond,
@@ -316,6 +394,10 @@ var name2Builtin = map[string]BuiltinOp{
 	"DATE_TRUNC_DAY":           DateTruncDay,
 	"DATE_TRUNC_MONTH":         DateTruncMonth,
 	"DATE_TRUNC_YEAR":          DateTruncYear,
+	"DATE_TRUNC_QUARTER":       DateTruncQuarter,
+	"DATE_TRUNC_WEEK":          DateTruncWeek,
+	"DATE_TRUNC_ISOWEEK":       DateTruncISOWeek,
+	"DATE_TRUNC_ISOYEAR":       DateTruncISOYear,
 	"GEO_HASH":                 GeoHash,
 	"GEO_TILE_X":               GeoTileX,
 	"GEO_TILE_Y":               GeoTileY,
@@ -331,7 +413,19 @@ var name2Builtin = map[string]BuiltinOp{
 	"TIME_BUCKET":              TimeBucket,
 	"TO_UNIX_EPOCH":            DateToUnixEpoch,
 	"TO_UNIX_MICRO":            DateToUnixMicro,
+	"FROM_UNIXTIME":            FromUnixTime,
+	"FROM_UNIX_MICRO":          FromUnixMicro,
+	"PARSE_TIMESTAMP":          ParseTimestamp,
+	"FORMAT_TIMESTAMP":         FormatTimestamp,
+	"AT_TIME_ZONE":             AtTimeZone,
 	"SIZE":                     ObjectSize,
+	"ARRAY_LENGTH":             ArrayLength,
+	"CARDINALITY":              ArrayLength,
+	"ARRAY_CONTAINS":           ArrayContains,
+	"ARRAY_POSITION":           ArrayPosition,
+	"ARRAY_SLICE":              ArraySlice,
+	"ARRAY_DISTINCT":           ArrayDistinct,
+	"ARRAY_JOIN":               ArrayJoin,
 	"TABLE_GLOB":               TableGlob,
 	"TABLE_PATTERN":            TablePattern,
 	"MAKE_LIST":                MakeList,
@@ -510,6 +604,32 @@ func simplifyLower(h Hint, args []Node) Node {
 	return nil
 }
 
+// parseInet parses an IPv4 or IPv6 address;
+// addresses with a zone are not accepted
+func parseInet(str string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(str)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
+
+// prefixRange returns the first and the
+// last address of the CIDR subnet str
+func prefixRange(str string) (first, last netip.Addr, err error) {
+	prefix, err := netip.ParsePrefix(str)
+	if err != nil {
+		return first, last, err
+	}
+	first = prefix.Masked().Addr()
+	b := first.AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	last, _ = netip.AddrFromSlice(b)
+	return first, last, nil
+}
+
 func checkIsSubnetOf(h Hint, args []Node) error {
 	nArgs := len(args)
 	if nArgs != 2 && nArgs != 3 {
@@ -519,21 +639,29 @@ func checkIsSubnetOf(h Hint, args []Node) error {
 	if !ok {
 		return errtypef(args[0], "not a string but a %T", args[0])
 	}
-	arg1, ok := args[1].(String)
-	if !ok {
-		return errtypef(args[1], "not a string but a %T", args[1])
-	}
 	if nArgs == 2 {
-		if _, _, err := net.ParseCIDR(string(arg0)); err != nil {
+		if _, _, err := prefixRange(string(arg0)); err != nil {
 			return errtypef(args[0], "%s", err)
 		}
+		if !TypeOf(args[1], h).AnyOf(StringType) {
+			return errtypef(args[1], "not a string but a %T", args[1])
+		}
 	} else {
-		if net.ParseIP(string(arg0)) == nil {
+		arg1, ok := args[1].(String)
+		if !ok {
+			return errtypef(args[1], "not a string but a %T", args[1])
+		}
+		min, ok := parseInet(string(arg0))
+		if !ok {
 			return errtypef(args[0], "not an IP address")
 		}
-		if net.ParseIP(string(arg1)) == nil {
+		max, ok := parseInet(string(arg1))
+		if !ok {
 			return errtypef(args[1], "not an IP address")
 		}
+		if min.Is4() != max.Is4() {
+			return errtypef(args[1], "not an address of the same family as %s", args[0])
+		}
 		if !TypeOf(args[2], h).AnyOf(StringType) {
 			return errtypef(args[2], "not a string but a %T", args[2])
 		}
@@ -542,24 +670,15 @@ func checkIsSubnetOf(h Hint, args []Node) error {
 }
 
 func simplifyIsSubnetOf(h Hint, args []Node) Node {
-	if len(args) == 2 { // first argument is a CIDR subnet e.g. 192.1.2.3/8
+	if len(args) == 2 { // first argument is a CIDR subnet e.g. 192.1.2.3/8 or 2001:db8::/32
 		arg0, ok := args[0].(String)
 		if !ok {
 			return nil // found an error: let checkIsSubnetOf handle this
 		}
-		_, ipv4Net, err := net.ParseCIDR(string(arg0))
+		minIP, maxIP, err := prefixRange(string(arg0))
 		if err != nil {
 			return nil // found an error: let checkIsSubnetOf handle this
 		}
-		mask := binary.BigEndian.Uint32(ipv4Net.Mask)
-		start := binary.BigEndian.Uint32(ipv4Net.IP)
-		finish := (start & mask) | (mask ^ 0xffffffff)
-
-		minIP := make(net.IP, 4)
-		binary.BigEndian.PutUint32(minIP, start)
-		maxIP := make(net.IP, 4)
-		binary.BigEndian.PutUint32(maxIP, finish)
-
 		arg1 := missingUnless(args[1], h, StringType)
 		return CallOp(IsSubnetOf, Node(String(minIP.String())), Node(String(maxIP.String())), arg1)
 	} else if len(args) == 3 { // first and second argument are an IP address
@@ -571,18 +690,20 @@ func simplifyIsSubnetOf(h Hint, args []Node) Node {
 		if !ok {
 			return nil // found an invalid IP address: let checkIsSubnetOf handle this
 		}
-		minIP := net.ParseIP(string(arg0))
-		if minIP == nil {
+		minIP, ok := parseInet(string(arg0))
+		if !ok {
 			return nil // found an invalid IP address: let checkIsSubnetOf handle this
 		}
-		maxIP := net.ParseIP(string(arg1))
-		if maxIP == nil {
+		maxIP, ok := parseInet(string(arg1))
+		if !ok || minIP.Is4() != maxIP.Is4() {
 			return nil // found an invalid IP address: let checkIsSubnetOf handle this
 		}
 
-		switch bytes.Compare(minIP.To4(), maxIP.To4()) {
+		switch minIP.Compare(maxIP) {
 		case 0: // min == max: simplify to trivial str cmp
-			return Compare(Equals, args[0], args[1])
+			if minIP.Is4() {
+				return Compare(Equals, args[2], args[0])
+			}
 		case 1: // min > max has no solutions
 			return Bool(false)
 		}
@@ -590,6 +711,150 @@ func simplifyIsSubnetOf(h Hint, args []Node) Node {
 	return nil
 }
 
+func simplifyInetParse(h Hint, args []Node) Node {
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	addr, ok := parseInet(string(str))
+	if !ok {
+		return Missing{}
+	}
+	return String(addr.String())
+}
+
+func simplifyInetFamily(h Hint, args []Node) Node {
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	addr, ok := parseInet(string(str))
+	if !ok {
+		return Missing{}
+	}
+	if addr.Is4() {
+		return Integer(4)
+	}
+	return Integer(6)
+}
+
+func checkInetTrunc(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return errsyntaxf("INET_TRUNC expects 2 arguments, but found %d", len(args))
+	}
+	if !TypeOf(args[0], h).AnyOf(StringType) {
+		return errtype(args[0], "not a string")
+	}
+	bits, ok := args[1].(Integer)
+	if !ok {
+		return errsyntaxf("INET_TRUNC argument 1 is not a literal integer")
+	}
+	if bits < 0 || bits > 128 {
+		return errtypef(args[1], "prefix length %d is not between 0 and 128", bits)
+	}
+	return nil
+}
+
+func simplifyInetTrunc(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	bits, ok := args[1].(Integer)
+	if !ok {
+		return nil
+	}
+	addr, ok := parseInet(string(str))
+	if !ok {
+		return Missing{}
+	}
+	prefix, err := addr.Prefix(int(bits))
+	if err != nil {
+		// the prefix is longer than the address
+		return Missing{}
+	}
+	return String(prefix.Addr().String())
+}
+
+func simplifyJSONParse(h Hint, args []Node) Node {
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	d, ok := ParseJSON(string(str))
+	if !ok {
+		return Missing{}
+	}
+	c, ok := AsConstant(d)
+	if !ok {
+		return nil
+	}
+	return c
+}
+
+func checkJSONExtract(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return errsyntaxf("JSON_EXTRACT expects 2 arguments, but found %d", len(args))
+	}
+	if !TypeOf(args[0], h).AnyOf(StringType) {
+		return errtype(args[0], "not a string")
+	}
+	path, ok := args[1].(String)
+	if !ok {
+		return errsyntaxf("JSON_EXTRACT argument 1 is not a literal string")
+	}
+	_, err := ParseJSONPath(string(path))
+	if err != nil {
+		return errtypef(args[1], "%s", err)
+	}
+	return nil
+}
+
+func simplifyJSONExtract(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	path, ok := args[1].(String)
+	if !ok {
+		return nil
+	}
+	p, err := ParseJSONPath(string(path))
+	if err != nil {
+		return nil
+	}
+	d, ok := ExtractJSON(string(str), p)
+	if !ok {
+		return Missing{}
+	}
+	c, ok := AsConstant(d)
+	if !ok {
+		return nil
+	}
+	return c
+}
+
+func simplifyToJSONString(h Hint, args []Node) Node {
+	if _, ok := args[0].(Missing); ok {
+		return Missing{}
+	}
+	c, ok := args[0].(Constant)
+	if !ok {
+		return nil
+	}
+	str, ok := FormatJSON(c.Datum())
+	if !ok {
+		return nil
+	}
+	return String(str)
+}
+
 func simplifyCharLength(h Hint, args []Node) Node {
 	switch v := args[0].(type) {
 	case String:
@@ -741,138 +1006,772 @@ func checkSplitPart(h Hint, args []Node) error {
 	return nil
 }
 
-var unaryStringArgs = fixedArgs(StringType)
-var variadicNumeric = variadicArgs(NumericType)
-var fixedTime = fixedArgs(TimeType)
+// RegexpGroup returns the capture group
+// argument of REGEXP_EXTRACT
+func RegexpGroup(args []Node) int {
+	if len(args) == 3 {
+		if i, ok := args[2].(Integer); ok {
+			return int(i)
+		}
+	}
+	return 0
+}
 
-func simplifyDateExtract(part Timepart) func(Hint, []Node) Node {
-	return func(h Hint, args []Node) Node {
-		if len(args) != 1 {
-			return nil
+// RegexpMatcher compiles the pattern argument
+// of a REGEXP_EXTRACT, REGEXP_REPLACE, or
+// REGEXP_COUNT builtin, along with the parsed
+// replacement of REGEXP_REPLACE
+func RegexpMatcher(op BuiltinOp, args []Node) (*regexp2.Matcher, []regexp2.ReplaceItem, error) {
+	name := op.String()
+	if len(args) < 2 {
+		return nil, nil, errsyntaxf("%s expects at least 2 arguments, but found %d", name, len(args))
+	}
+	pattern, ok := args[1].(String)
+	if !ok {
+		return nil, nil, errsyntaxf("%s argument 1 is not a literal string", name)
+	}
+	re, err := regexp2.ParseRegexp(string(pattern))
+	if err != nil {
+		return nil, nil, errsyntaxf("%s: %s", name, err)
+	}
+	var groups []int
+	var repl []regexp2.ReplaceItem
+	switch op {
+	case RegexpExtract:
+		groups = []int{RegexpGroup(args)}
+	case RegexpReplace:
+		if len(args) != 3 {
+			return nil, nil, errsyntaxf("%s expects 3 arguments, but found %d", name, len(args))
 		}
-		if ts, ok := args[0].(*Timestamp); ok {
-			return DateExtract(part, ts)
+		str, ok := args[2].(String)
+		if !ok {
+			return nil, nil, errsyntaxf("%s argument 2 is not a literal string", name)
 		}
-		return nil
+		repl, err = regexp2.ParseReplacement(string(str), re)
+		if err != nil {
+			return nil, nil, errsyntaxf("%s: %s", name, err)
+		}
+		groups = regexp2.ReplaceGroups(repl)
 	}
+	m, err := regexp2.NewMatcher(string(pattern), groups)
+	if err != nil {
+		return nil, nil, errsyntaxf("%s: %s", name, err)
+	}
+	return m, repl, nil
 }
 
-func simplifyToUnixEpoch(h Hint, args []Node) Node {
-	if len(args) != 1 {
+func checkRegexp(op BuiltinOp, h Hint, args []Node) error {
+	if !TypeOf(args[0], h).AnyOf(StringType) {
+		return errtype(args[0], "not a string")
+	}
+	_, _, err := RegexpMatcher(op, args)
+	return err
+}
+
+func checkRegexpExtract(h Hint, args []Node) error {
+	nArgs := len(args)
+	if nArgs != 2 && nArgs != 3 {
+		return errsyntaxf("REGEXP_EXTRACT expects 2 or 3 arguments, but found %d", nArgs)
+	}
+	if nArgs == 3 {
+		if i, ok := args[2].(Integer); !ok || i < 0 {
+			return errsyntaxf("REGEXP_EXTRACT argument 2 is not a non-negative integer literal")
+		}
+	}
+	return checkRegexp(RegexpExtract, h, args)
+}
+
+func checkRegexpReplace(h Hint, args []Node) error {
+	if len(args) != 3 {
+		return errsyntaxf("REGEXP_REPLACE expects 3 arguments, but found %d", len(args))
+	}
+	return checkRegexp(RegexpReplace, h, args)
+}
+
+func checkRegexpCount(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return errsyntaxf("REGEXP_COUNT expects 2 arguments, but found %d", len(args))
+	}
+	return checkRegexp(RegexpCount, h, args)
+}
+
+func simplifyRegexpExtract(h Hint, args []Node) Node {
+	if len(args) < 2 {
 		return nil
 	}
-	ts, ok := args[0].(*Timestamp)
+	str, ok := args[0].(String)
 	if !ok {
 		return nil
 	}
-	return Integer(ts.Value.Unix())
+	m, _, err := RegexpMatcher(RegexpExtract, args)
+	if err != nil {
+		return nil
+	}
+	out, ok := m.Extract([]byte(str), RegexpGroup(args))
+	if !ok {
+		return Missing{}
+	}
+	return String(out)
 }
 
-func simplifyToUnixMicro(h Hint, args []Node) Node {
-	if len(args) != 1 {
+func simplifyRegexpReplace(h Hint, args []Node) Node {
+	if len(args) < 2 {
 		return nil
 	}
-	ts, ok := args[0].(*Timestamp)
+	str, ok := args[0].(String)
 	if !ok {
 		return nil
 	}
-	return Integer(ts.Value.UnixMicro())
+	m, repl, err := RegexpMatcher(RegexpReplace, args)
+	if err != nil {
+		return nil
+	}
+	return String(m.Replace([]byte(str), repl))
 }
 
-func simplifyDateTrunc(part Timepart) func(Hint, []Node) Node {
-	return func(h Hint, args []Node) Node {
-		if len(args) != 1 {
-			return nil
-		}
-		if ts, ok := args[0].(*Timestamp); ok {
-			return DateTrunc(part, ts)
-		}
+func simplifyRegexpCount(h Hint, args []Node) Node {
+	if len(args) < 2 {
 		return nil
 	}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	m, _, err := RegexpMatcher(RegexpCount, args)
+	if err != nil {
+		return nil
+	}
+	return Integer(m.Count([]byte(str)))
 }
 
-func checkInSubquery(h Hint, args []Node) error {
+func checkStrPos(h Hint, args []Node) error {
 	if len(args) != 2 {
-		return mismatch(2, len(args))
+		return errsyntaxf("STRPOS expects 2 arguments, but found %d", len(args))
 	}
-	if _, ok := args[1].(*Select); !ok {
-		return errsyntaxf("second argument to IN_SUBQUERY is %q", args[1])
+	if !TypeOf(args[0], h).AnyOf(StringType) {
+		return errtype(args[0], "not a string")
+	}
+	if _, ok := args[1].(String); !ok {
+		return errsyntaxf("STRPOS argument 1 is not a literal string")
 	}
 	return nil
 }
 
-// HASH_LOOKUP(value, if_first, then_first, ..., [otherwise])
-func checkHashLookup(h Hint, args []Node) error {
-	if len(args) < 3 || len(args)&1 == 0 {
-		return mismatch(3, len(args))
+func simplifyStrPos(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
 	}
-	tail := args[1:]
-	for i := range tail {
-		_, ok := tail[i].(Constant)
-		if !ok {
-			errsyntaxf("argument %s to HASH_LOOKUP not a literal", tail[i])
-		}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
 	}
-	return nil
+	sub, ok := args[1].(String)
+	if !ok {
+		return nil
+	}
+	i := strings.Index(string(str), string(sub))
+	if i < 0 {
+		return Integer(0)
+	}
+	return Integer(utf8.RuneCountInString(string(str[:i])) + 1)
 }
 
-func checkInReplacement(h Hint, args []Node) error {
-	if len(args) != 2 {
-		return mismatch(2, len(args))
+func checkReplace(h Hint, args []Node) error {
+	if len(args) != 3 {
+		return errsyntaxf("REPLACE expects 3 arguments, but found %d", len(args))
 	}
-	if _, ok := args[1].(Integer); !ok {
-		return errsyntaxf("second argument to IN_REPLACEMENT is %q", args[1])
+	if !TypeOf(args[0], h).AnyOf(StringType) {
+		return errtype(args[0], "not a string")
+	}
+	for i := 1; i < 3; i++ {
+		if _, ok := args[i].(String); !ok {
+			return errsyntaxf("REPLACE argument %d is not a literal string", i)
+		}
 	}
 	return nil
 }
 
-// HASH_REPLACEMENT(id, kind, key, x [, default])
-func checkHashReplacement(h Hint, args []Node) error {
-	if len(args) != 4 && len(args) != 5 {
-		return mismatch(4, len(args))
+func simplifyReplace(h Hint, args []Node) Node {
+	if len(args) != 3 {
+		return nil
 	}
-	if _, ok := args[0].(Integer); !ok {
-		return errsyntaxf("first argument to HASH_REPLACEMENT is %q", ToString(args[0]))
+	from, ok := args[1].(String)
+	if !ok {
+		return nil
 	}
-	kind, ok := args[1].(String)
+	to, ok := args[2].(String)
 	if !ok {
-		return errsyntaxf("second argument to HASH_REPLACEMENT is %q", ToString(args[1]))
+		return nil
 	}
-	switch k := string(kind); k {
-	case "scalar", "struct", "list":
-		// ok
-	default:
-		return errsyntaxf("second argument to HASH_REPLACEMENT is %q", k)
+	if from == "" || from == to {
+		// REPLACE(x, '', y) -> x
+		return missingUnless(args[0], h, StringType)
 	}
-	if _, ok := args[2].(String); !ok {
-		return errsyntaxf("third argument to HASH_REPLACEMENT is %q", ToString(args[2]))
+	if str, ok := args[0].(String); ok {
+		return String(strings.ReplaceAll(string(str), string(from), string(to)))
 	}
 	return nil
 }
 
-func checkScalarReplacement(h Hint, args []Node) error {
-	if len(args) != 1 {
-		return mismatch(1, len(args))
+func simplifyReverse(h Hint, args []Node) Node {
+	arg0 := missingUnless(args[0], h, StringType)
+	if s, ok := arg0.(String); ok && utf8.ValidString(string(s)) {
+		r := []rune(string(s))
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return String(r)
 	}
-	if _, ok := args[0].(Integer); !ok {
-		return errsyntaxf("bad argument to SCALAR_REPLACEMENT %q", args[0])
+	if b, ok := arg0.(*Builtin); ok && b.Func == Reverse {
+		// REVERSE(REVERSE(x)) -> x
+		return missingUnless(b.Args[0], h, StringType)
 	}
 	return nil
 }
 
-func nodeTypeName(node Node) string {
-	switch node.(type) {
-	case String:
-		return "string"
-	case Integer:
-		return "integer"
-	case Float:
-		return "float"
-	case Bool:
-		return "bool"
-	case *Rational:
-		return "rational"
-	case *Timestamp:
+// initcap upper-cases the first ASCII letter of
+// each word in s and lower-cases the other ASCII
+// letters; words are sequences of ASCII letters,
+// digits, and non-ASCII bytes
+func initcap(s string) string {
+	b := []byte(s)
+	word := false
+	for i, c := range b {
+		switch {
+		case c|0x20 >= 'a' && c|0x20 <= 'z':
+			if word {
+				b[i] = c | 0x20
+			} else {
+				b[i] = c &^ 0x20
+			}
+			word = true
+		case c >= 0x80 || (c >= '0' && c <= '9'):
+			word = true
+		default:
+			word = false
+		}
+	}
+	return string(b)
+}
+
+func simplifyInitcap(h Hint, args []Node) Node {
+	arg0 := missingUnless(args[0], h, StringType)
+	if s, ok := arg0.(String); ok {
+		return String(initcap(string(s)))
+	}
+	return nil
+}
+
+// maxFoldedRepeat is the maximum length
+// of a constant-folded REPEAT, LPAD, or RPAD
+const maxFoldedRepeat = 1024
+
+func simplifyRepeat(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil
+	}
+	n, ok := args[1].(Integer)
+	if !ok {
+		return nil
+	}
+	if n <= 0 || str == "" {
+		return String("")
+	}
+	if int64(n)*int64(len(str)) > maxFoldedRepeat {
+		return nil
+	}
+	return String(strings.Repeat(string(str), int(n)))
+}
+
+func checkPad(op BuiltinOp) func(Hint, []Node) error {
+	return func(h Hint, args []Node) error {
+		if len(args) != 2 && len(args) != 3 {
+			return errsyntaxf("%s expects 2 or 3 arguments, but found %d", op, len(args))
+		}
+		if !TypeOf(args[0], h).AnyOf(StringType) {
+			return errtype(args[0], "not a string")
+		}
+		if !TypeOf(args[1], h).AnyOf(NumericType) {
+			return errtype(args[1], "not a number")
+		}
+		if len(args) == 3 {
+			if _, ok := args[2].(String); !ok {
+				return errsyntaxf("%s argument 2 is not a literal string", op)
+			}
+		}
+		return nil
+	}
+}
+
+// PadFill returns the fill argument of LPAD or RPAD
+func PadFill(args []Node) string {
+	if len(args) == 3 {
+		if s, ok := args[2].(String); ok {
+			return string(s)
+		}
+	}
+	return " "
+}
+
+// pad pads s to n code-points with copies of
+// fill (or truncates s to n code-points)
+func pad(s string, n int, fill string, left bool) string {
+	if n <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) >= n || fill == "" {
+		if len(r) > n {
+			r = r[:n]
+		}
+		return string(r)
+	}
+	var extra []rune
+	f := []rune(fill)
+	for i := 0; len(r)+len(extra) < n; i++ {
+		extra = append(extra, f[i%len(f)])
+	}
+	if left {
+		return string(extra) + s
+	}
+	return s + string(extra)
+}
+
+func simplifyPad(left bool) func(Hint, []Node) Node {
+	return func(h Hint, args []Node) Node {
+		if len(args) < 2 {
+			return nil
+		}
+		str, ok := args[0].(String)
+		if !ok || !utf8.ValidString(string(str)) {
+			return nil
+		}
+		n, ok := args[1].(Integer)
+		if !ok || n > maxFoldedRepeat {
+			return nil
+		}
+		fill := PadFill(args)
+		if len(args) == 3 {
+			if _, ok := args[2].(String); !ok {
+				return nil
+			}
+		}
+		return String(pad(string(str), int(n), fill, left))
+	}
+}
+
+func checkHasPrefix(op BuiltinOp) func(Hint, []Node) error {
+	return func(h Hint, args []Node) error {
+		if len(args) != 2 {
+			return errsyntaxf("%s expects 2 arguments, but found %d", op, len(args))
+		}
+		if !TypeOf(args[0], h).AnyOf(StringType) {
+			return errtype(args[0], "not a string")
+		}
+		if _, ok := args[1].(String); !ok {
+			return errsyntaxf("%s argument 1 is not a literal string", op)
+		}
+		return nil
+	}
+}
+
+func simplifyHasPrefix(suffix bool) func(Hint, []Node) Node {
+	return func(h Hint, args []Node) Node {
+		if len(args) != 2 {
+			return nil
+		}
+		str, ok := args[0].(String)
+		if !ok {
+			return nil
+		}
+		affix, ok := args[1].(String)
+		if !ok {
+			return nil
+		}
+		if suffix {
+			return Bool(strings.HasSuffix(string(str), string(affix)))
+		}
+		return Bool(strings.HasPrefix(string(str), string(affix)))
+	}
+}
+
+// leftRight returns the first (left) or last n
+// code-points of s, or all but the last (left)
+// or first -n code-points if n is negative
+func leftRight(s string, n int64, left bool) string {
+	r := []rune(s)
+	keep := int64(len(r))
+	if n >= 0 && n < keep {
+		keep = n
+	} else if n < 0 {
+		keep += n
+		if keep < 0 {
+			keep = 0
+		}
+	}
+	if left {
+		return string(r[:keep])
+	}
+	return string(r[int64(len(r))-keep:])
+}
+
+func simplifyLeftRight(left bool) func(Hint, []Node) Node {
+	return func(h Hint, args []Node) Node {
+		if len(args) != 2 {
+			return nil
+		}
+		str, ok := args[0].(String)
+		if !ok || !utf8.ValidString(string(str)) {
+			return nil
+		}
+		n, ok := args[1].(Integer)
+		if !ok {
+			return nil
+		}
+		return String(leftRight(string(str), int64(n), left))
+	}
+}
+
+var unaryStringArgs = fixedArgs(StringType)
+var variadicNumeric = variadicArgs(NumericType)
+var fixedTime = fixedArgs(TimeType)
+var zonedTime = timeZoneArgs()
+
+func simplifyDateExtract(part Timepart) func(Hint, []Node) Node {
+	return func(h Hint, args []Node) Node {
+		if len(args) == 2 {
+			return DateExtractIn(part, args[0], args[1])
+		}
+		if len(args) != 1 {
+			return nil
+		}
+		if ts, ok := args[0].(*Timestamp); ok {
+			return DateExtract(part, ts)
+		}
+		return nil
+	}
+}
+
+func simplifyToUnixEpoch(h Hint, args []Node) Node {
+	if len(args) != 1 {
+		return nil
+	}
+	ts, ok := args[0].(*Timestamp)
+	if !ok {
+		return nil
+	}
+	return Integer(ts.Value.Unix())
+}
+
+func simplifyToUnixMicro(h Hint, args []Node) Node {
+	if len(args) != 1 {
+		return nil
+	}
+	ts, ok := args[0].(*Timestamp)
+	if !ok {
+		return nil
+	}
+	return Integer(ts.Value.UnixMicro())
+}
+
+// FROM_UNIXTIME(x) -> FROM_UNIX_MICRO(x * 1000000)
+func simplifyFromUnixTime(h Hint, args []Node) Node {
+	if len(args) != 1 {
+		return nil
+	}
+	switch n := args[0].(type) {
+	case Integer:
+		return &Timestamp{Value: date.Unix(int64(n), 0)}
+	case Float:
+		return fromUnixMicro(float64(n) * 1e6)
+	}
+	return CallOp(FromUnixMicro, Mul(args[0], Integer(1000000)))
+}
+
+func simplifyFromUnixMicro(h Hint, args []Node) Node {
+	if len(args) != 1 {
+		return nil
+	}
+	switch n := args[0].(type) {
+	case Integer:
+		return &Timestamp{Value: date.UnixMicro(int64(n))}
+	case Float:
+		return fromUnixMicro(float64(n))
+	}
+	return nil
+}
+
+func fromUnixMicro(f float64) Node {
+	return &Timestamp{Value: date.UnixMicro(int64(math.RoundToEven(f)))}
+}
+
+// checkTimestampFormat checks PARSE_TIMESTAMP(fmt, x)
+// and FORMAT_TIMESTAMP(fmt, x), where fmt must be a
+// literal string and x must have type arg
+func checkTimestampFormat(op BuiltinOp, arg TypeSet) func(Hint, []Node) error {
+	return func(h Hint, args []Node) error {
+		if len(args) != 2 {
+			return errsyntaxf("%s expects 2 arguments, but found %d", op, len(args))
+		}
+		if _, ok := args[0].(String); !ok {
+			return errsyntaxf("%s argument 0 is not a literal string", op)
+		}
+		if _, err := TimestampFormat(args); err != nil {
+			return errsyntaxf("%s: %s", op, err)
+		}
+		if !TypeOf(args[1], h).AnyOf(arg) {
+			return errtypef(args[1], "not compatible with type %s", arg)
+		}
+		return nil
+	}
+}
+
+func simplifyParseTimestamp(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	str, ok := args[1].(String)
+	if !ok {
+		return nil
+	}
+	f, err := TimestampFormat(args)
+	if err != nil {
+		return nil
+	}
+	t, ok := f.Parse([]byte(str))
+	if !ok {
+		return Missing{}
+	}
+	return &Timestamp{Value: t}
+}
+
+func simplifyFormatTimestamp(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	ts, ok := args[1].(*Timestamp)
+	if !ok {
+		return nil
+	}
+	f, err := TimestampFormat(args)
+	if err != nil {
+		return nil
+	}
+	out, ok := f.Append(nil, ts.Value)
+	if !ok {
+		return Missing{}
+	}
+	return String(out)
+}
+
+// TimestampFormat returns the parsed format
+// argument of PARSE_TIMESTAMP or FORMAT_TIMESTAMP
+func TimestampFormat(args []Node) (date.Format, error) {
+	if len(args) == 0 {
+		return nil, errsyntaxf("missing format argument")
+	}
+	f, ok := args[0].(String)
+	if !ok {
+		return nil, errsyntaxf("format %s is not a literal string", ToString(args[0]))
+	}
+	return date.ParseFormat(string(f))
+}
+
+func simplifyDateTrunc(part Timepart) func(Hint, []Node) Node {
+	return func(h Hint, args []Node) Node {
+		if len(args) == 2 {
+			n := DateTruncIn(part, args[0], args[1])
+			if b, ok := n.(*Builtin); !ok || len(b.Args) == 1 {
+				return n
+			}
+			return nil
+		}
+		if len(args) != 1 {
+			return nil
+		}
+		if ts, ok := args[0].(*Timestamp); ok {
+			return DateTrunc(part, ts)
+		}
+		return nil
+	}
+}
+
+// TimeZone returns the time zone named
+// by the literal string n
+func TimeZone(n Node) (*date.Zone, error) {
+	name, ok := n.(String)
+	if !ok {
+		return nil, errsyntaxf("time zone %s is not a literal string", ToString(n))
+	}
+	z, err := date.LoadZone(string(name))
+	if err != nil {
+		return nil, errsyntaxf("%s", err)
+	}
+	return z, nil
+}
+
+func isUTC(z *date.Zone) bool {
+	return z.Fixed() && z.Transitions()[0].Offset == 0
+}
+
+// timeZoneArgs checks for a timestamp followed by
+// arguments of the types in rest and an optional
+// literal time zone
+func timeZoneArgs(rest ...TypeSet) func(Hint, []Node) error {
+	check := fixedArgs(append([]TypeSet{TimeType}, rest...)...)
+	return func(h Hint, args []Node) error {
+		if n := len(rest) + 1; len(args) == n+1 {
+			if _, err := TimeZone(args[n]); err != nil {
+				return err
+			}
+			args = args[:n]
+		}
+		return check(h, args)
+	}
+}
+
+func checkAtTimeZone(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return mismatch(2, len(args))
+	}
+	return zonedTime(h, args)
+}
+
+func simplifyAtTimeZone(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	z, err := TimeZone(args[1])
+	if err != nil {
+		return nil
+	}
+	if ts, ok := args[0].(*Timestamp); ok {
+		return &Timestamp{Value: z.ToLocal(ts.Value)}
+	}
+	return nil
+}
+
+func atTimeZoneText(args []Node, dst *strings.Builder, redact bool) {
+	if len(args) != 2 {
+		dst.WriteString("AT_TIME_ZONE(")
+		for i := range args {
+			if i > 0 {
+				dst.WriteString(", ")
+			}
+			args[i].text(dst, redact)
+		}
+		dst.WriteByte(')')
+		return
+	}
+	parens := infix(args[0])
+	if parens {
+		dst.WriteByte('(')
+	}
+	args[0].text(dst, redact)
+	if parens {
+		dst.WriteByte(')')
+	}
+	dst.WriteString(" AT TIME ZONE ")
+	args[1].text(dst, redact)
+}
+
+// TIME_BUCKET(t, i, 'UTC') -> TIME_BUCKET(t, i)
+func simplifyTimeBucket(h Hint, args []Node) Node {
+	if len(args) != 3 {
+		return nil
+	}
+	if z, err := TimeZone(args[2]); err == nil && isUTC(z) {
+		return CallOp(TimeBucket, args[0], args[1])
+	}
+	return nil
+}
+
+func checkInSubquery(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return mismatch(2, len(args))
+	}
+	if _, ok := args[1].(*Select); !ok {
+		return errsyntaxf("second argument to IN_SUBQUERY is %q", args[1])
+	}
+	return nil
+}
+
+// HASH_LOOKUP(value, if_first, then_first, ..., [otherwise])
+func checkHashLookup(h Hint, args []Node) error {
+	if len(args) < 3 || len(args)&1 == 0 {
+		return mismatch(3, len(args))
+	}
+	tail := args[1:]
+	for i := range tail {
+		_, ok := tail[i].(Constant)
+		if !ok {
+			errsyntaxf("argument %s to HASH_LOOKUP not a literal", tail[i])
+		}
+	}
+	return nil
+}
+
+func checkInReplacement(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return mismatch(2, len(args))
+	}
+	if _, ok := args[1].(Integer); !ok {
+		return errsyntaxf("second argument to IN_REPLACEMENT is %q", args[1])
+	}
+	return nil
+}
+
+// HASH_REPLACEMENT(id, kind, key, x [, default])
+func checkHashReplacement(h Hint, args []Node) error {
+	if len(args) != 4 && len(args) != 5 {
+		return mismatch(4, len(args))
+	}
+	if _, ok := args[0].(Integer); !ok {
+		return errsyntaxf("first argument to HASH_REPLACEMENT is %q", ToString(args[0]))
+	}
+	kind, ok := args[1].(String)
+	if !ok {
+		return errsyntaxf("second argument to HASH_REPLACEMENT is %q", ToString(args[1]))
+	}
+	switch k := string(kind); k {
+	case "scalar", "struct", "list":
+		// ok
+	default:
+		return errsyntaxf("second argument to HASH_REPLACEMENT is %q", k)
+	}
+	if _, ok := args[2].(String); !ok {
+		return errsyntaxf("third argument to HASH_REPLACEMENT is %q", ToString(args[2]))
+	}
+	return nil
+}
+
+func checkScalarReplacement(h Hint, args []Node) error {
+	if len(args) != 1 {
+		return mismatch(1, len(args))
+	}
+	if _, ok := args[0].(Integer); !ok {
+		return errsyntaxf("bad argument to SCALAR_REPLACEMENT %q", args[0])
+	}
+	return nil
+}
+
+func nodeTypeName(node Node) string {
+	switch node.(type) {
+	case String:
+		return "string"
+	case Integer:
+		return "integer"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case *Rational:
+		return "rational"
+	case *Timestamp:
 		return "timestamp"
 	case Null:
 		return "null"
@@ -912,6 +1811,178 @@ func simplifyObjectSize(h Hint, args []Node) (result Node) {
 	return nil
 }
 
+func simplifyArrayLength(h Hint, args []Node) Node {
+	switch v := args[0].(type) {
+	case *List:
+		return Integer(len(v.Values))
+	case Missing:
+		return v
+	}
+	return nil
+}
+
+func checkArraySearch(op BuiltinOp) func(Hint, []Node) error {
+	return func(h Hint, args []Node) error {
+		if len(args) != 2 {
+			return errsyntaxf("%s expects 2 arguments, but found %d", op, len(args))
+		}
+		if !TypeOf(args[0], h).AnyOf(ListType) {
+			return errtype(args[0], "not a list")
+		}
+		switch args[1].(type) {
+		case *List, *Struct:
+			return errtypef(args[1], "%s cannot search for a list or structure", op)
+		case Constant:
+			return nil
+		}
+		return errsyntaxf("%s argument 1 is not a literal value", op)
+	}
+}
+
+// arrayIndex returns the index of the first
+// element of lst equal to c, or -1
+func arrayIndex(lst []Constant, c Constant) int {
+	d := c.Datum()
+	for i := range lst {
+		if lst[i].Datum().Equal(d) {
+			return i
+		}
+	}
+	return -1
+}
+
+func simplifyArraySearch(op BuiltinOp) func(Hint, []Node) Node {
+	return func(h Hint, args []Node) Node {
+		if len(args) != 2 {
+			return nil
+		}
+		if _, ok := args[0].(Missing); ok {
+			return Missing{}
+		}
+		lst, ok := args[0].(*List)
+		if !ok {
+			return nil
+		}
+		c, ok := args[1].(Constant)
+		if !ok {
+			return nil
+		}
+		i := arrayIndex(lst.Values, c)
+		if op == ArrayContains {
+			return Bool(i >= 0)
+		}
+		if i < 0 {
+			return Missing{}
+		}
+		return Integer(i)
+	}
+}
+
+func checkArraySlice(h Hint, args []Node) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errsyntaxf("ARRAY_SLICE expects 2 or 3 arguments, but found %d", len(args))
+	}
+	if !TypeOf(args[0], h).AnyOf(ListType) {
+		return errtype(args[0], "not a list")
+	}
+	for i := 1; i < len(args); i++ {
+		n, ok := args[i].(Integer)
+		if !ok {
+			return errsyntaxf("ARRAY_SLICE argument %d is not a literal integer", i)
+		}
+		if n < 0 || n > math.MaxUint32-1 {
+			return errtypef(args[i], "index %d is out of range", n)
+		}
+	}
+	return nil
+}
+
+func simplifyArraySlice(h Hint, args []Node) Node {
+	if len(args) != 2 && len(args) != 3 {
+		return nil
+	}
+	if _, ok := args[0].(Missing); ok {
+		return Missing{}
+	}
+	lst, ok := args[0].(*List)
+	if !ok {
+		return nil
+	}
+	from, ok := args[1].(Integer)
+	if !ok {
+		return nil
+	}
+	to := Integer(len(lst.Values))
+	if len(args) == 3 {
+		if to, ok = args[2].(Integer); !ok {
+			return nil
+		}
+	}
+	if to > Integer(len(lst.Values)) {
+		to = Integer(len(lst.Values))
+	}
+	if from > to {
+		from = to
+	}
+	if from < 0 {
+		return nil
+	}
+	return &List{Values: slices.Clone(lst.Values[from:to])}
+}
+
+func simplifyArrayDistinct(h Hint, args []Node) Node {
+	switch v := args[0].(type) {
+	case *List:
+		var out []Constant
+		for i := range v.Values {
+			if arrayIndex(out, v.Values[i]) < 0 {
+				out = append(out, v.Values[i])
+			}
+		}
+		return &List{Values: out}
+	case Missing:
+		return v
+	}
+	return nil
+}
+
+func checkArrayJoin(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return errsyntaxf("ARRAY_JOIN expects 2 arguments, but found %d", len(args))
+	}
+	if !TypeOf(args[0], h).AnyOf(ListType) {
+		return errtype(args[0], "not a list")
+	}
+	if _, ok := args[1].(String); !ok {
+		return errsyntaxf("ARRAY_JOIN argument 1 is not a literal string")
+	}
+	return nil
+}
+
+func simplifyArrayJoin(h Hint, args []Node) Node {
+	if len(args) != 2 {
+		return nil
+	}
+	if _, ok := args[0].(Missing); ok {
+		return Missing{}
+	}
+	lst, ok := args[0].(*List)
+	if !ok {
+		return nil
+	}
+	sep, ok := args[1].(String)
+	if !ok {
+		return nil
+	}
+	var strs []string
+	for i := range lst.Values {
+		if s, ok := lst.Values[i].(String); ok {
+			strs = append(strs, string(s))
+		}
+	}
+	return String(strings.Join(strs, string(sep)))
+}
+
 // Flattens all CONCATs into the output array of nodes with literals joined and empty strings removed
 func flattenConcatRecurse(output []Node, args []Node) []Node {
 	for _, node := range args {
@@ -1140,6 +2211,10 @@ func adjpart(part Timepart) func(x int64, val date.Time) date.Time {
 			month += int(x)
 		case Year:
 			year += int(x)
+		case Quarter:
+			month += 3 * int(x)
+		case Week:
+			day += 7 * int(x)
 		}
 		return date.Date(year, month, day, hour, minute, sec, val.Nanosecond())
 	}
@@ -1153,12 +2228,14 @@ var (
 		us := val.UnixMicro() + (1000 * x)
 		return date.UnixMicro(us)
 	})
-	dateAddSecond = adjtime(adjpart(Second))
-	dateAddMinute = adjtime(adjpart(Minute))
-	dateAddHour   = adjtime(adjpart(Hour))
-	dateAddDay    = adjtime(adjpart(Day))
-	dateAddMonth  = adjtime(adjpart(Month))
-	dateAddYear   = adjtime(adjpart(Year))
+	dateAddSecond  = adjtime(adjpart(Second))
+	dateAddMinute  = adjtime(adjpart(Minute))
+	dateAddHour    = adjtime(adjpart(Hour))
+	dateAddDay     = adjtime(adjpart(Day))
+	dateAddMonth   = adjtime(adjpart(Month))
+	dateAddYear    = adjtime(adjpart(Year))
+	dateAddQuarter = adjtime(adjpart(Quarter))
+	dateAddWeek    = adjtime(adjpart(Week))
 )
 
 var builtinInfo = [maxBuiltin]binfo{
@@ -1176,6 +2253,30 @@ var builtinInfo = [maxBuiltin]binfo{
 	SplitPart:  {check: checkSplitPart, ret: StringType | MissingType},
 	EqualsCI:   {ret: LogicalType},
 
+	StrPos:     {check: checkStrPos, ret: IntegerType | MissingType, simplify: simplifyStrPos},
+	Replace:    {check: checkReplace, ret: StringType | MissingType, simplify: simplifyReplace},
+	Reverse:    {check: unaryStringArgs, ret: StringType | MissingType, simplify: simplifyReverse},
+	Initcap:    {check: unaryStringArgs, ret: StringType | MissingType, simplify: simplifyInitcap},
+	Repeat:     {check: fixedArgs(StringType, NumericType), ret: StringType | MissingType, simplify: simplifyRepeat},
+	Lpad:       {check: checkPad(Lpad), ret: StringType | MissingType, simplify: simplifyPad(true)},
+	Rpad:       {check: checkPad(Rpad), ret: StringType | MissingType, simplify: simplifyPad(false)},
+	StartsWith: {check: checkHasPrefix(StartsWith), ret: LogicalType, simplify: simplifyHasPrefix(false)},
+	EndsWith:   {check: checkHasPrefix(EndsWith), ret: LogicalType, simplify: simplifyHasPrefix(true)},
+	Left:       {check: fixedArgs(StringType, NumericType), ret: StringType | MissingType, simplify: simplifyLeftRight(true)},
+	Right:      {check: fixedArgs(StringType, NumericType), ret: StringType | MissingType, simplify: simplifyLeftRight(false)},
+
+	RegexpExtract: {check: checkRegexpExtract, ret: StringType | MissingType, simplify: simplifyRegexpExtract},
+	RegexpReplace: {check: checkRegexpReplace, ret: StringType | MissingType, simplify: simplifyRegexpReplace},
+	RegexpCount:   {check: checkRegexpCount, ret: IntegerType | MissingType, simplify: simplifyRegexpCount},
+
+	InetParse:  {check: unaryStringArgs, ret: StringType | MissingType, simplify: simplifyInetParse},
+	InetTrunc:  {check: checkInetTrunc, ret: StringType | MissingType, simplify: simplifyInetTrunc},
+	InetFamily: {check: unaryStringArgs, ret: IntegerType | MissingType, simplify: simplifyInetFamily},
+
+	JSONParse:    {check: unaryStringArgs, ret: AnyType, simplify: simplifyJSONParse},
+	JSONExtract:  {check: checkJSONExtract, ret: AnyType, simplify: simplifyJSONExtract},
+	ToJSONString: {check: fixedArgs(AnyType), ret: StringType | MissingType, simplify: simplifyToJSONString},
+
 	BitCount:  {check: fixedArgs(NumericType), ret: IntegerType},
 	Abs:       {check: fixedArgs(NumericType), ret: NumericType, simplify: simplifyAbs},
 	Sign:      {check: fixedArgs(NumericType), ret: NumericType, simplify: simplifySign},
@@ -1220,6 +2321,8 @@ var builtinInfo = [maxBuiltin]binfo{
 	DateAddDay:             {check: fixedArgs(IntegerType, TimeType), private: true, ret: TimeType | MissingType, simplify: dateAddDay},
 	DateAddMonth:           {check: fixedArgs(IntegerType, TimeType), private: true, ret: TimeType | MissingType, simplify: dateAddMonth},
 	DateAddYear:            {check: fixedArgs(IntegerType, TimeType), private: true, ret: TimeType | MissingType, simplify: dateAddYear},
+	DateAddQuarter:         {check: fixedArgs(IntegerType, TimeType), private: true, ret: TimeType | MissingType, simplify: dateAddQuarter},
+	DateAddWeek:            {check: fixedArgs(IntegerType, TimeType), private: true, ret: TimeType | MissingType, simplify: dateAddWeek},
 	DateDiffMicrosecond:    {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
 	DateDiffMillisecond:    {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
 	DateDiffSecond:         {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
@@ -1228,24 +2331,41 @@ var builtinInfo = [maxBuiltin]binfo{
 	DateDiffDay:            {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
 	DateDiffMonth:          {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
 	DateDiffYear:           {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
-	DateExtractMicrosecond: {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Microsecond)},
-	DateExtractMillisecond: {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Millisecond)},
-	DateExtractSecond:      {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Second)},
-	DateExtractMinute:      {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Minute)},
-	DateExtractHour:        {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Hour)},
-	DateExtractDay:         {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Day)},
-	DateExtractMonth:       {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Month)},
-	DateExtractYear:        {check: fixedArgs(TimeType), private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Year)},
-	DateTruncMicrosecond:   {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Microsecond)},
-	DateTruncMillisecond:   {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Millisecond)},
-	DateTruncSecond:        {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Second)},
-	DateTruncMinute:        {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Minute)},
-	DateTruncHour:          {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Hour)},
-	DateTruncDay:           {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Day)},
-	DateTruncMonth:         {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Month)},
-	DateTruncYear:          {check: fixedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Year)},
+	DateDiffQuarter:        {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
+	DateDiffWeek:           {check: fixedArgs(TimeType, TimeType), private: true, ret: IntegerType | MissingType},
+	DateExtractMicrosecond: {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Microsecond)},
+	DateExtractMillisecond: {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Millisecond)},
+	DateExtractSecond:      {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Second)},
+	DateExtractMinute:      {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Minute)},
+	DateExtractHour:        {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Hour)},
+	DateExtractDay:         {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Day)},
+	DateExtractMonth:       {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Month)},
+	DateExtractYear:        {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Year)},
+	DateExtractQuarter:     {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Quarter)},
+	DateExtractWeek:        {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(Week)},
+	DateExtractISOWeek:     {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(ISOWeek)},
+	DateExtractISOYear:     {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(ISOYear)},
+	DateExtractDOW:         {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(DOW)},
+	DateExtractDOY:         {check: zonedTime, private: true, ret: IntegerType | MissingType, simplify: simplifyDateExtract(DOY)},
+	DateTruncMicrosecond:   {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Microsecond)},
+	DateTruncMillisecond:   {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Millisecond)},
+	DateTruncSecond:        {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Second)},
+	DateTruncMinute:        {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Minute)},
+	DateTruncHour:          {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Hour)},
+	DateTruncDay:           {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Day)},
+	DateTruncMonth:         {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Month)},
+	DateTruncYear:          {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Year)},
+	DateTruncQuarter:       {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Quarter)},
+	DateTruncWeek:          {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(Week)},
+	DateTruncISOWeek:       {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(ISOWeek)},
+	DateTruncISOYear:       {check: zonedTime, private: true, ret: TimeType | MissingType, simplify: simplifyDateTrunc(ISOYear)},
 	DateToUnixEpoch:        {check: fixedTime, ret: IntegerType | MissingType, simplify: simplifyToUnixEpoch},
 	DateToUnixMicro:        {check: fixedTime, ret: IntegerType | MissingType, simplify: simplifyToUnixMicro},
+	FromUnixTime:           {check: fixedArgs(NumericType), ret: TimeType | MissingType, simplify: simplifyFromUnixTime},
+	FromUnixMicro:          {check: fixedArgs(NumericType), ret: TimeType | MissingType, simplify: simplifyFromUnixMicro},
+	ParseTimestamp:         {check: checkTimestampFormat(ParseTimestamp, StringType), ret: TimeType | MissingType, simplify: simplifyParseTimestamp},
+	FormatTimestamp:        {check: checkTimestampFormat(FormatTimestamp, TimeType), ret: StringType | MissingType, simplify: simplifyFormatTimestamp},
+	AtTimeZone:             {check: checkAtTimeZone, private: true, ret: TimeType | MissingType, simplify: simplifyAtTimeZone, text: atTimeZoneText},
 
 	GeoHash:     {check: fixedArgs(NumericType, NumericType, IntegerType), ret: StringType | MissingType},
 	GeoTileX:    {check: fixedArgs(NumericType, IntegerType), ret: StringType | MissingType},
@@ -1255,6 +2375,13 @@ var builtinInfo = [maxBuiltin]binfo{
 
 	ObjectSize: {check: checkObjectSize, ret: NumericType | MissingType, simplify: simplifyObjectSize},
 
+	ArrayLength:   {check: fixedArgs(ListType), ret: IntegerType | MissingType, simplify: simplifyArrayLength},
+	ArrayContains: {check: checkArraySearch(ArrayContains), ret: LogicalType, simplify: simplifyArraySearch(ArrayContains)},
+	ArrayPosition: {check: checkArraySearch(ArrayPosition), ret: IntegerType | MissingType, simplify: simplifyArraySearch(ArrayPosition)},
+	ArraySlice:    {check: checkArraySlice, ret: ListType | MissingType, simplify: simplifyArraySlice},
+	ArrayDistinct: {check: fixedArgs(ListType), ret: ListType | MissingType, simplify: simplifyArrayDistinct},
+	ArrayJoin:     {check: checkArrayJoin, ret: StringType | MissingType, simplify: simplifyArrayJoin},
+
 	InSubquery:        {check: checkInSubquery, private: true, ret: LogicalType},
 	HashLookup:        {check: checkHashLookup, private: true, ret: AnyType},
 	InReplacement:     {check: checkInReplacement, private: true, ret: LogicalType},
@@ -1263,7 +2390,7 @@ var builtinInfo = [maxBuiltin]binfo{
 	ListReplacement:   {check: checkScalarReplacement, private: true, ret: ListType},
 	StructReplacement: {check: checkScalarReplacement, private: true, ret: StructType},
 
-	TimeBucket: {check: fixedArgs(TimeType, NumericType), ret: NumericType},
+	TimeBucket: {check: timeZoneArgs(NumericType), ret: NumericType, simplify: simplifyTimeBucket},
 
 	MakeList:   {simplify: simplifyMakeList, ret: ListType, private: true, text: makeListText},
 	MakeStruct: {simplify: simplifyMakeStruct, ret: StructType, private: true, text: makeStructText},
@@ -122,7 +122,7 @@ func (c *checktable) Visit(n Node) Visitor {
 	case *Path:
 		// ok
 		return nil
-	case *Select:
+	case *Select, *Union:
 		// ok
 		return c.parent
 	case String:
@@ -304,3 +304,13 @@ func (c *Cast) check(h Hint) error {
 	}
 	return nil
 }
+
+func (e *Exists) check(h Hint) error {
+	if !TypeOf(e.List, h).AnyOf(ListType) {
+		return errtype(e.List, "not a list")
+	}
+	if !TypeOf(e.Where, h).Logical() {
+		return errtype(e.Where, "not a logical expression")
+	}
+	return nil
+}
@@ -53,6 +53,41 @@ func TestCheck(t *testing.T) {
 			expr: Compare(Like, path("x"), Bool(true)),
 			kind: &SyntaxError{},
 		},
+		{
+			// APPROX_PERCENTILE(x, 1.5)
+			expr: &Aggregate{Op: OpApproxPercentile, Inner: path("x"), Args: []Node{Float(1.5)}},
+			kind: &SyntaxError{},
+		},
+		{
+			// APPROX_PERCENTILE(x, y)
+			expr: &Aggregate{Op: OpApproxPercentile, Inner: path("x"), Args: []Node{path("y")}},
+			kind: &SyntaxError{},
+		},
+		{
+			// APPROX_PERCENTILE(x)
+			expr: &Aggregate{Op: OpApproxPercentile, Inner: path("x")},
+			kind: &SyntaxError{},
+		},
+		{
+			// CORR(x)
+			expr: &Aggregate{Op: OpCorr, Inner: path("x")},
+			kind: &SyntaxError{},
+		},
+		{
+			// ARRAY_AGG(x ORDER BY y, z)
+			expr: &Aggregate{Op: OpArrayAgg, Inner: path("x"), OrderBy: []Order{{Column: path("y")}, {Column: path("z")}}},
+			kind: &SyntaxError{},
+		},
+		{
+			// ARRAY_AGG(x LIMIT 0)
+			expr: &Aggregate{Op: OpArrayAgg, Inner: path("x"), Args: []Node{Integer(0)}},
+			kind: &SyntaxError{},
+		},
+		{
+			// SUM(x ORDER BY y)
+			expr: &Aggregate{Op: OpSum, Inner: path("x"), OrderBy: []Order{{Column: path("y")}}},
+			kind: &SyntaxError{},
+		},
 		{
 			// case with non-boolean arms
 			expr: casen(Integer(3), path("x"), path("y")),
@@ -143,6 +178,126 @@ func TestCheck(t *testing.T) {
 			&TypeError{},
 			"illegal index",
 		},
+		{
+			CallOp(RegexpExtract, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 1 is not a literal string",
+		},
+		{
+			CallOp(RegexpExtract, path("x"), String("(a)+"), Integer(1)),
+			&SyntaxError{},
+			"inside of a repetition",
+		},
+		{
+			CallOp(RegexpExtract, path("x"), String("(a)"), Integer(2)),
+			&SyntaxError{},
+			"capture group 2 does not exist",
+		},
+		{
+			CallOp(RegexpCount, path("x"), String(`\bfoo`)),
+			&SyntaxError{},
+			"word boundaries",
+		},
+		{
+			CallOp(RegexpReplace, path("x"), String("(a)"), String("$2")),
+			&SyntaxError{},
+			"unknown capture group",
+		},
+		{
+			CallOp(RegexpCount, Integer(1), String("a")),
+			&TypeError{},
+			"not a string",
+		},
+		{
+			CallOp(StrPos, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 1 is not a literal string",
+		},
+		{
+			CallOp(Lpad, path("x"), Integer(3), path("y")),
+			&SyntaxError{},
+			"argument 2 is not a literal string",
+		},
+		{
+			CallOp(Left, path("x"), String("y")),
+			&TypeError{},
+			"not compatible with type",
+		},
+		{
+			CallOp(InetTrunc, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 1 is not a literal integer",
+		},
+		{
+			CallOp(InetTrunc, path("x"), Integer(129)),
+			&TypeError{},
+			"not between 0 and 128",
+		},
+		{
+			CallOp(IsSubnetOf, String("2001:db8::/129"), path("x")),
+			&TypeError{},
+			"prefix length out of range",
+		},
+		{
+			CallOp(JSONExtract, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 1 is not a literal string",
+		},
+		{
+			CallOp(JSONExtract, path("x"), String("a.b")),
+			&TypeError{},
+			"does not begin with '$'",
+		},
+		{
+			CallOp(JSONExtract, path("x"), String("$[x]")),
+			&TypeError{},
+			"invalid index",
+		},
+		{
+			CallOp(JSONParse, Integer(3)),
+			&TypeError{},
+			"not compatible with type",
+		},
+		{
+			CallOp(ArrayContains, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 1 is not a literal value",
+		},
+		{
+			CallOp(ArrayPosition, String("x"), Integer(1)),
+			&TypeError{},
+			"not a list",
+		},
+		{
+			CallOp(ArraySlice, path("x"), Integer(-1)),
+			&TypeError{},
+			"index -1 is out of range",
+		},
+		{
+			CallOp(ArrayJoin, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 1 is not a literal string",
+		},
+		{
+			CallOp(IsSubnetOf, String("10.0.0.0"), String("::1"), path("x")),
+			&TypeError{},
+			"not an address of the same family",
+		},
+		{
+			CallOp(ParseTimestamp, path("x"), path("y")),
+			&SyntaxError{},
+			"argument 0 is not a literal string",
+		},
+		{
+			CallOp(FormatTimestamp, String("%Y-%q"), path("y")),
+			&SyntaxError{},
+			"unsupported conversion %q",
+		},
+		{
+			CallOp(FormatTimestamp, String("%Y"), String("y")),
+			&TypeError{},
+			"not compatible with type",
+		},
 	}
 	for i := range testcases {
 		err := Check(testcases[i].expr)
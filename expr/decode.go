@@ -128,6 +128,8 @@ func getEmpty(name string) composite {
 		return &IsKey{}
 	case "select":
 		return &Select{}
+	case "union":
+		return &Union{}
 	case "on":
 		return &OnEquals{}
 	case "join":
@@ -142,6 +144,8 @@ func getEmpty(name string) composite {
 		return &Cast{}
 	case "member":
 		return &Member{}
+	case "exists":
+		return &Exists{}
 	case "struct":
 		return &Struct{}
 	case "list":
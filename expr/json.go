@@ -0,0 +1,173 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+)
+
+// JSONPath is a parsed JSON_EXTRACT path
+// expression like
+//
+//	$.foo.bar[0]["baz"]
+type JSONPath []JSONPathStep
+
+// JSONPathStep is one step of a JSONPath
+type JSONPathStep struct {
+	// Field is the name of the structure
+	// field selected by this step
+	Field string
+	// Index is the list index selected by
+	// this step, or -1 if the step selects Field
+	Index int
+}
+
+// ParseJSONPath parses a JSON_EXTRACT path expression.
+//
+// A path starts with '$' (the whole value) and is
+// followed by any number of field accessors
+// (either .name or ["name"]) and list indexes ([n]).
+func ParseJSONPath(s string) (JSONPath, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("JSON path %q does not begin with '$'", s)
+	}
+	rest := s[1:]
+	var p JSONPath
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSON path %q has an empty field name", s)
+			}
+			p = append(p, JSONPathStep{Field: rest[:end], Index: -1})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSON path %q has an unterminated '['", s)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') &&
+				inner[len(inner)-1] == inner[0] {
+				p = append(p, JSONPathStep{Field: inner[1 : len(inner)-1], Index: -1})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("JSON path %q has an invalid index %q", s, inner)
+				}
+				p = append(p, JSONPathStep{Index: n})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSON path %q has an unexpected character %q", s, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// Eval evaluates p against d and returns the
+// selected value, or false if the value does not exist
+func (p JSONPath) Eval(d ion.Datum) (ion.Datum, bool) {
+	for i := range p {
+		if p[i].Index < 0 {
+			s, ok := d.Struct()
+			if !ok {
+				return ion.Empty, false
+			}
+			f, ok := s.FieldByName(p[i].Field)
+			if !ok {
+				return ion.Empty, false
+			}
+			d = f.Value
+			continue
+		}
+		l, ok := d.List()
+		if !ok {
+			return ion.Empty, false
+		}
+		items := l.Items(nil)
+		if p[i].Index >= len(items) {
+			return ion.Empty, false
+		}
+		d = items[p[i].Index]
+	}
+	return d, true
+}
+
+// JSONParser parses JSON text for JSON_PARSE
+// and JSON_EXTRACT. It reuses its state across
+// calls, so a stream of values should be parsed
+// with one JSONParser. The results of each call
+// are only valid until the next call.
+//
+// The zero value of JSONParser is ready to use.
+type JSONParser struct {
+	vp jsonrl.ValueParser
+}
+
+// Parse implements JSON_PARSE by parsing s
+// as a JSON value; it returns false if s is not
+// valid JSON
+func (j *JSONParser) Parse(s string) (ion.Datum, bool) {
+	d, err := j.vp.Parse([]byte(s))
+	if err != nil {
+		return ion.Empty, false
+	}
+	return d, true
+}
+
+// Extract implements JSON_EXTRACT by parsing s
+// as a JSON value and evaluating p against it
+func (j *JSONParser) Extract(s string, p JSONPath) (ion.Datum, bool) {
+	d, ok := j.Parse(s)
+	if !ok {
+		return ion.Empty, false
+	}
+	return p.Eval(d)
+}
+
+// ParseJSON is equivalent to JSONParser.Parse
+// using a new JSONParser
+func ParseJSON(s string) (ion.Datum, bool) {
+	var j JSONParser
+	return j.Parse(s)
+}
+
+// ExtractJSON is equivalent to JSONParser.Extract
+// using a new JSONParser
+func ExtractJSON(s string, p JSONPath) (ion.Datum, bool) {
+	var j JSONParser
+	return j.Extract(s, p)
+}
+
+// FormatJSON implements TO_JSON_STRING by
+// producing the JSON text representation of d
+func FormatJSON(d ion.Datum) (string, bool) {
+	buf, err := ion.AppendJSON(nil, d)
+	if err != nil {
+		return "", false
+	}
+	return string(buf), true
+}
@@ -16,6 +16,7 @@ package expr
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 	"strconv"
 	"strings"
@@ -163,15 +164,175 @@ const (
 	// LATEST() function is used by Sneller to distinguish
 	// between arithmetic vs timestamp aggregation
 	OpLatest
+
+	// Describes SQL ROW_NUMBER() window function.
+	OpRowNumber
+
+	// Describes SQL RANK() window function.
+	OpRank
+
+	// Describes SQL DENSE_RANK() window function.
+	OpDenseRank
+
+	// Describes SQL LAG(expr [, offset [, default]]) window function.
+	OpLag
+
+	// Describes SQL LEAD(expr [, offset [, default]]) window function.
+	OpLead
+
+	// Describes SQL FIRST_VALUE(expr) window function.
+	OpFirstValue
+
+	// Describes SQL LAST_VALUE(expr) window function.
+	OpLastValue
+
+	// Describes SQL APPROX_COUNT_DISTINCT(...) aggregate operation.
+	OpApproxCountDistinct
+
+	// OpApproxCountDistinctPartial is the mapping-step
+	// half of APPROX_COUNT_DISTINCT; it produces the
+	// HyperLogLog registers (as a blob) rather than
+	// the final estimate
+	OpApproxCountDistinctPartial
+
+	// OpApproxCountDistinctMerge is the reduction-step
+	// half of APPROX_COUNT_DISTINCT; it merges the registers
+	// produced by OpApproxCountDistinctPartial and
+	// produces the final estimate
+	OpApproxCountDistinctMerge
+
+	// Describes SQL MEDIAN(...) aggregate operation.
+	//
+	// MEDIAN(x) is converted into APPROX_PERCENTILE(x, 0.5)
+	// by the parser.
+	OpMedian
+
+	// Describes SQL APPROX_PERCENTILE(expr, p) and
+	// PERCENTILE_CONT(p) WITHIN GROUP (ORDER BY expr)
+	// aggregate operations; the percentile p is
+	// stored in Aggregate.Args.
+	OpApproxPercentile
+
+	// OpApproxPercentilePartial is the mapping-step
+	// half of APPROX_PERCENTILE; it produces the
+	// sketch (as a blob) rather than the final result
+	OpApproxPercentilePartial
+
+	// OpApproxPercentileMerge is the reduction-step
+	// half of APPROX_PERCENTILE; it merges the sketches
+	// produced by OpApproxPercentilePartial and
+	// produces the final result
+	OpApproxPercentileMerge
+
+	// Describes SQL VAR_POP(...) and VAR_SAMP(...)
+	// aggregate operations.
+	OpVarPop
+	OpVarSamp
+
+	// Describes SQL STDDEV_POP(...) and STDDEV_SAMP(...)
+	// aggregate operations.
+	OpStddevPop
+	OpStddevSamp
+
+	// Describes SQL COVAR_POP(x, y) and CORR(x, y)
+	// aggregate operations; y is stored in Aggregate.Args.
+	OpCovarPop
+	OpCorr
+
+	// OpMomentsPartial is the mapping-step half
+	// of the statistical aggregates above; it produces
+	// the count, means, and (co-)moments of its
+	// argument(s) as a blob
+	OpMomentsPartial
+
+	// The reduction-step halves of the statistical
+	// aggregates above; they merge the blobs produced
+	// by OpMomentsPartial and produce the final result
+	OpVarPopMerge
+	OpVarSampMerge
+	OpStddevPopMerge
+	OpStddevSampMerge
+	OpCovarPopMerge
+	OpCorrMerge
+
+	// Describes SQL ARRAY_AGG(expr [ORDER BY ...] [LIMIT n])
+	// and ARRAY_AGG(DISTINCT ...) aggregate operations;
+	// the ORDER BY column is stored in Aggregate.OrderBy,
+	// and the limit (if any) is stored in Aggregate.Args.
+	OpArrayAgg
+	OpArrayAggDistinct
+
+	// The mapping-step halves of ARRAY_AGG;
+	// they produce the bounded list of values
+	// (and their ordering keys) as a blob
+	OpArrayAggPartial
+	OpArrayAggDistinctPartial
+
+	// The reduction-step halves of ARRAY_AGG;
+	// they merge the blobs produced by the
+	// partial operations and produce the final list
+	OpArrayAggMerge
+	OpArrayAggDistinctMerge
 )
 
 func (a AggregateOp) IsBoolOp() bool {
 	return a == OpBoolAnd || a == OpBoolOr
 }
 
+// WindowOnly returns whether the operation is a
+// window function that can only be used with OVER
+// (as opposed to an aggregate that may also be
+// used as a window function)
+func (a AggregateOp) WindowOnly() bool {
+	switch a {
+	case OpRowNumber, OpRank, OpDenseRank, OpLag, OpLead, OpFirstValue, OpLastValue:
+		return true
+	default:
+		return false
+	}
+}
+
+// MomentsMerge returns the reduction-step
+// operation that merges OpMomentsPartial results
+// to produce the result of a, or false if a
+// is not a statistical aggregate.
+func (a AggregateOp) MomentsMerge() (AggregateOp, bool) {
+	if a < OpVarPop || a > OpCorr {
+		return 0, false
+	}
+	return a - OpVarPop + OpVarPopMerge, true
+}
+
+// IsArrayAgg returns whether the operation
+// is one of the ARRAY_AGG operations
+func (a AggregateOp) IsArrayAgg() bool {
+	return a >= OpArrayAgg && a <= OpArrayAggDistinctMerge
+}
+
+// ArrayAggSplit returns the mapping-step and
+// reduction-step operations that compute a,
+// or false if a is not ARRAY_AGG or ARRAY_AGG(DISTINCT ...)
+func (a AggregateOp) ArrayAggSplit() (partial, merge AggregateOp, ok bool) {
+	switch a {
+	case OpArrayAgg:
+		return OpArrayAggPartial, OpArrayAggMerge, true
+	case OpArrayAggDistinct:
+		return OpArrayAggDistinctPartial, OpArrayAggDistinctMerge, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// NoArgs returns whether the operation
+// is written without any arguments
+// (i.e. ROW_NUMBER(), RANK(), DENSE_RANK())
+func (a AggregateOp) NoArgs() bool {
+	return a == OpRowNumber || a == OpRank || a == OpDenseRank
+}
+
 func (a AggregateOp) defaultResult() string {
 	switch a {
-	case OpCount, OpCountDistinct, OpSumCount:
+	case OpCount, OpCountDistinct, OpSumCount, OpApproxCountDistinct, OpApproxCountDistinctMerge:
 		return "count"
 	case OpSum, OpSumInt:
 		return "sum"
@@ -181,6 +342,36 @@ func (a AggregateOp) defaultResult() string {
 		return "min"
 	case OpMax, OpLatest:
 		return "max"
+	case OpRowNumber:
+		return "row_number"
+	case OpRank:
+		return "rank"
+	case OpDenseRank:
+		return "dense_rank"
+	case OpLag:
+		return "lag"
+	case OpLead:
+		return "lead"
+	case OpFirstValue:
+		return "first_value"
+	case OpLastValue:
+		return "last_value"
+	case OpApproxPercentile, OpApproxPercentileMerge:
+		return "percentile"
+	case OpVarPop, OpVarPopMerge:
+		return "var_pop"
+	case OpVarSamp, OpVarSampMerge:
+		return "var_samp"
+	case OpStddevPop, OpStddevPopMerge:
+		return "stddev_pop"
+	case OpStddevSamp, OpStddevSampMerge:
+		return "stddev_samp"
+	case OpCovarPop, OpCovarPopMerge:
+		return "covar_pop"
+	case OpCorr, OpCorrMerge:
+		return "corr"
+	case OpArrayAgg, OpArrayAggDistinct, OpArrayAggMerge, OpArrayAggDistinctMerge:
+		return "array_agg"
 	default:
 		return ""
 	}
@@ -218,6 +409,66 @@ func (a AggregateOp) String() string {
 		return "BOOL_AND"
 	case OpBoolOr:
 		return "BOOL_OR"
+	case OpRowNumber:
+		return "ROW_NUMBER"
+	case OpRank:
+		return "RANK"
+	case OpDenseRank:
+		return "DENSE_RANK"
+	case OpLag:
+		return "LAG"
+	case OpLead:
+		return "LEAD"
+	case OpFirstValue:
+		return "FIRST_VALUE"
+	case OpLastValue:
+		return "LAST_VALUE"
+	case OpApproxCountDistinct:
+		return "APPROX_COUNT_DISTINCT"
+	case OpApproxCountDistinctPartial:
+		return "APPROX_COUNT_DISTINCT_PARTIAL"
+	case OpApproxCountDistinctMerge:
+		return "APPROX_COUNT_DISTINCT_MERGE"
+	case OpMedian:
+		return "MEDIAN"
+	case OpApproxPercentile:
+		return "APPROX_PERCENTILE"
+	case OpApproxPercentilePartial:
+		return "APPROX_PERCENTILE_PARTIAL"
+	case OpApproxPercentileMerge:
+		return "APPROX_PERCENTILE_MERGE"
+	case OpVarPop:
+		return "VAR_POP"
+	case OpVarSamp:
+		return "VAR_SAMP"
+	case OpStddevPop:
+		return "STDDEV_POP"
+	case OpStddevSamp:
+		return "STDDEV_SAMP"
+	case OpCovarPop:
+		return "COVAR_POP"
+	case OpCorr:
+		return "CORR"
+	case OpMomentsPartial:
+		return "MOMENTS_PARTIAL"
+	case OpVarPopMerge:
+		return "VAR_POP_MERGE"
+	case OpVarSampMerge:
+		return "VAR_SAMP_MERGE"
+	case OpStddevPopMerge:
+		return "STDDEV_POP_MERGE"
+	case OpStddevSampMerge:
+		return "STDDEV_SAMP_MERGE"
+	case OpCovarPopMerge:
+		return "COVAR_POP_MERGE"
+	case OpCorrMerge:
+		return "CORR_MERGE"
+	case OpArrayAgg, OpArrayAggDistinct:
+		return "ARRAY_AGG"
+	case OpArrayAggPartial, OpArrayAggDistinctPartial:
+		return "ARRAY_AGG_PARTIAL"
+	case OpArrayAggMerge, OpArrayAggDistinctMerge:
+		return "ARRAY_AGG_MERGE"
 	default:
 		return "none"
 	}
@@ -230,11 +481,18 @@ type Aggregate struct {
 	Op AggregateOp
 	// Inner is the expression to be aggregated
 	Inner Node
+	// Args are the arguments that follow Inner,
+	// if any (e.g. the offset and default value
+	// of LAG(x, offset, default))
+	Args []Node
 	// Over, if non-nil, is the OVER part
 	// of the aggregation
 	Over *Window
 	// Filter is an optional filtering expression
 	Filter Node
+	// OrderBy is the optional ORDER BY part
+	// of an ordered aggregate (i.e. ARRAY_AGG)
+	OrderBy []Order
 }
 
 func (a *Aggregate) Equals(e Node) bool {
@@ -245,6 +503,9 @@ func (a *Aggregate) Equals(e Node) bool {
 	if ea.Op != a.Op || !a.Inner.Equals(ea.Inner) {
 		return false
 	}
+	if !slices.EqualFunc(a.Args, ea.Args, Equivalent) {
+		return false
+	}
 
 	if (a.Filter != nil) != (ea.Filter != nil) {
 		return false
@@ -252,6 +513,9 @@ func (a *Aggregate) Equals(e Node) bool {
 	if (a.Filter != nil) && !a.Filter.Equals(ea.Filter) {
 		return false
 	}
+	if !slices.EqualFunc(a.OrderBy, ea.OrderBy, Order.Equals) {
+		return false
+	}
 
 	if a.Over == nil {
 		return ea.Over == nil
@@ -275,6 +539,15 @@ func (a *Aggregate) Encode(dst *ion.Buffer, st *ion.Symtab) {
 	dst.BeginField(st.Intern("inner"))
 	a.Inner.Encode(dst, st)
 
+	if len(a.Args) > 0 {
+		dst.BeginField(st.Intern("args"))
+		dst.BeginList(-1)
+		for i := range a.Args {
+			a.Args[i].Encode(dst, st)
+		}
+		dst.EndList()
+	}
+
 	if a.Over != nil {
 		dst.BeginField(st.Intern("over_partition"))
 		dst.BeginList(-1)
@@ -293,6 +566,11 @@ func (a *Aggregate) Encode(dst *ion.Buffer, st *ion.Symtab) {
 		a.Filter.Encode(dst, st)
 	}
 
+	if len(a.OrderBy) > 0 {
+		dst.BeginField(st.Intern("order_by"))
+		EncodeOrder(a.OrderBy, dst, st)
+	}
+
 	dst.EndStruct()
 }
 
@@ -308,6 +586,16 @@ func (a *Aggregate) setfield(name string, st *ion.Symtab, body []byte) error {
 		var err error
 		a.Inner, _, err = Decode(st, body)
 		return err
+	case "args":
+		_, err := ion.UnpackList(body, func(field []byte) error {
+			item, _, err := Decode(st, field)
+			if err != nil {
+				return err
+			}
+			a.Args = append(a.Args, item)
+			return nil
+		})
+		return err
 	case "over_partition":
 		if a.Over == nil {
 			a.Over = new(Window)
@@ -332,6 +620,10 @@ func (a *Aggregate) setfield(name string, st *ion.Symtab, body []byte) error {
 		var err error
 		a.Filter, _, err = Decode(st, body)
 		return err
+	case "order_by":
+		var err error
+		a.OrderBy, err = decodeOrder(st, body)
+		return err
 	default:
 		return fmt.Errorf("expr.Aggregate: setfield: unexpected field %q", name)
 	}
@@ -343,10 +635,39 @@ func (a *Aggregate) text(dst *strings.Builder, redact bool) {
 		dst.WriteString("COUNT(DISTINCT ")
 		a.Inner.text(dst, redact)
 		dst.WriteByte(')')
+	} else if a.Op.NoArgs() {
+		dst.WriteString(a.Op.String())
+		dst.WriteString("()")
+	} else if a.Op.IsArrayAgg() {
+		dst.WriteString(a.Op.String())
+		dst.WriteByte('(')
+		switch a.Op {
+		case OpArrayAggDistinct, OpArrayAggDistinctPartial, OpArrayAggDistinctMerge:
+			dst.WriteString("DISTINCT ")
+		}
+		a.Inner.text(dst, redact)
+		if len(a.OrderBy) > 0 {
+			dst.WriteString(" ORDER BY ")
+			for i := range a.OrderBy {
+				if i > 0 {
+					dst.WriteString(", ")
+				}
+				a.OrderBy[i].text(dst, redact)
+			}
+		}
+		for i := range a.Args {
+			dst.WriteString(" LIMIT ")
+			a.Args[i].text(dst, redact)
+		}
+		dst.WriteByte(')')
 	} else {
 		dst.WriteString(a.Op.String())
 		dst.WriteByte('(')
 		a.Inner.text(dst, redact)
+		for i := range a.Args {
+			dst.WriteString(", ")
+			a.Args[i].text(dst, redact)
+		}
 		dst.WriteByte(')')
 	}
 
@@ -357,7 +678,10 @@ func (a *Aggregate) text(dst *strings.Builder, redact bool) {
 	}
 
 	if a.Over != nil {
-		dst.WriteString(" OVER (PARTITION BY ")
+		dst.WriteString(" OVER (")
+		if len(a.Over.PartitionBy) > 0 {
+			dst.WriteString("PARTITION BY ")
+		}
 		for i := range a.Over.PartitionBy {
 			if i > 0 {
 				dst.WriteString(", ")
@@ -365,7 +689,10 @@ func (a *Aggregate) text(dst *strings.Builder, redact bool) {
 			a.Over.PartitionBy[i].text(dst, redact)
 		}
 		if len(a.Over.OrderBy) > 0 {
-			dst.WriteString(" ORDER BY ")
+			if len(a.Over.PartitionBy) > 0 {
+				dst.WriteByte(' ')
+			}
+			dst.WriteString("ORDER BY ")
 			for i := range a.Over.OrderBy {
 				if i > 0 {
 					dst.WriteString(", ")
@@ -379,6 +706,9 @@ func (a *Aggregate) text(dst *strings.Builder, redact bool) {
 
 func (a *Aggregate) walk(v Visitor) {
 	Walk(v, a.Inner)
+	for i := range a.Args {
+		Walk(v, a.Args[i])
+	}
 	if a.Over != nil {
 		for i := range a.Over.PartitionBy {
 			Walk(v, a.Over.PartitionBy[i])
@@ -390,10 +720,16 @@ func (a *Aggregate) walk(v Visitor) {
 	if a.Filter != nil {
 		Walk(v, a.Filter)
 	}
+	for i := range a.OrderBy {
+		Walk(v, a.OrderBy[i].Column)
+	}
 }
 
 func (a *Aggregate) rewrite(r Rewriter) Node {
 	a.Inner = Rewrite(r, a.Inner)
+	for i := range a.Args {
+		a.Args[i] = Rewrite(r, a.Args[i])
+	}
 	if a.Over != nil {
 		for i := range a.Over.PartitionBy {
 			a.Over.PartitionBy[i] = Rewrite(r, a.Over.PartitionBy[i])
@@ -405,13 +741,26 @@ func (a *Aggregate) rewrite(r Rewriter) Node {
 	if a.Filter != nil {
 		a.Filter = Rewrite(r, a.Filter)
 	}
+	for i := range a.OrderBy {
+		a.OrderBy[i].Column = Rewrite(r, a.OrderBy[i].Column)
+	}
 	return a
 }
 
 func (a *Aggregate) typeof(h Hint) TypeSet {
 	switch a.Op {
-	case OpCount, OpCountDistinct, OpSumCount:
+	case OpCount, OpCountDistinct, OpSumCount, OpApproxCountDistinct, OpApproxCountDistinctMerge:
 		return UnsignedType
+	case OpApproxCountDistinctPartial, OpApproxPercentilePartial, OpMomentsPartial,
+		OpArrayAggPartial, OpArrayAggDistinctPartial:
+		return TypeSet(1 << ion.BlobType)
+	case OpArrayAgg, OpArrayAggDistinct, OpArrayAggMerge, OpArrayAggDistinctMerge:
+		return ListType | NullType
+	case OpMedian, OpApproxPercentile, OpApproxPercentileMerge:
+		return FloatType | NullType
+	case OpVarPop, OpVarSamp, OpStddevPop, OpStddevSamp, OpCovarPop, OpCorr,
+		OpVarPopMerge, OpVarSampMerge, OpStddevPopMerge, OpStddevSampMerge, OpCovarPopMerge, OpCorrMerge:
+		return FloatType | NullType
 	case OpSumInt:
 		// if the inner type is only ever unsigned,
 		// then the result is only ever unsigned,
@@ -419,11 +768,125 @@ func (a *Aggregate) typeof(h Hint) TypeSet {
 		return TypeOf(a.Inner, h)
 	case OpLatest, OpEarliest:
 		return TimeType | NullType
+	case OpRowNumber, OpRank, OpDenseRank:
+		return UnsignedType
+	case OpLag, OpLead, OpFirstValue, OpLastValue:
+		return AnyType
 	default:
 		return NumericType | NullType
 	}
 }
 
+func (a *Aggregate) check(h Hint) error {
+	if a.Op.IsArrayAgg() {
+		return a.checkArrayAgg()
+	}
+	if len(a.OrderBy) > 0 {
+		return errsyntaxf("%s does not accept ORDER BY", a.Op)
+	}
+	if a.Op == OpApproxPercentile || a.Op == OpApproxPercentileMerge {
+		if a.Over != nil {
+			return errsyntaxf("%s cannot be used as a window function", a.Op)
+		}
+		if len(a.Args) != 1 {
+			return errsyntaxf("%s requires a percentile argument", a.Op)
+		}
+		if _, ok := a.Percentile(); !ok {
+			return errsyntaxf("the percentile of %s must be a constant between 0 and 1", a.Op)
+		}
+		return nil
+	}
+	switch a.Op {
+	case OpCovarPop, OpCorr:
+		if len(a.Args) != 1 {
+			return errsyntaxf("%s requires two arguments", a.Op)
+		}
+		return nil
+	case OpMomentsPartial:
+		if len(a.Args) > 1 {
+			return errsyntaxf("%s accepts at most two arguments", a.Op)
+		}
+		return nil
+	}
+	if !a.Op.WindowOnly() {
+		if len(a.Args) > 0 {
+			return errsyntaxf("%s accepts only one argument", a.Op)
+		}
+		return nil
+	}
+	if a.Over == nil {
+		return errsyntaxf("%s requires an OVER clause", a.Op)
+	}
+	if a.Filter != nil {
+		return errsyntaxf("%s does not accept a FILTER clause", a.Op)
+	}
+	if a.Op.NoArgs() {
+		if _, ok := a.Inner.(Star); !ok || len(a.Args) > 0 {
+			return errsyntaxf("%s does not accept arguments", a.Op)
+		}
+		return nil
+	}
+	if _, ok := a.Inner.(Star); ok {
+		return errsyntaxf("%s requires an argument", a.Op)
+	}
+	switch a.Op {
+	case OpLag, OpLead:
+		if len(a.Args) > 2 {
+			return errsyntaxf("%s accepts at most 3 arguments", a.Op)
+		}
+		if len(a.Args) > 0 {
+			if i, ok := a.Args[0].(Integer); !ok || i < 0 {
+				return errsyntaxf("the offset of %s must be a non-negative integer", a.Op)
+			}
+		}
+	default:
+		if len(a.Args) > 0 {
+			return errsyntaxf("%s accepts only one argument", a.Op)
+		}
+	}
+	return nil
+}
+
+func (a *Aggregate) checkArrayAgg() error {
+	if a.Over != nil {
+		return errsyntaxf("%s cannot be used as a window function", a.Op)
+	}
+	if _, ok := a.Inner.(Star); ok {
+		return errsyntaxf("%s requires an argument", a.Op)
+	}
+	if len(a.OrderBy) > 1 {
+		return errsyntaxf("%s accepts only one ORDER BY column", a.Op)
+	}
+	switch a.Op {
+	case OpArrayAggMerge, OpArrayAggDistinctMerge:
+		if len(a.OrderBy) > 0 {
+			return errsyntaxf("%s does not accept ORDER BY", a.Op)
+		}
+	}
+	if len(a.Args) > 1 {
+		return errsyntaxf("%s accepts only one LIMIT", a.Op)
+	}
+	if len(a.Args) > 0 {
+		if _, ok := a.Limit(); !ok {
+			return errsyntaxf("the LIMIT of %s must be a positive integer", a.Op)
+		}
+	}
+	return nil
+}
+
+// Limit returns the LIMIT of ARRAY_AGG, or
+// false if there is no valid limit
+func (a *Aggregate) Limit() (int, bool) {
+	if !a.Op.IsArrayAgg() || len(a.Args) != 1 {
+		return 0, false
+	}
+	i, ok := a.Args[0].(Integer)
+	if !ok || i <= 0 || i > math.MaxUint32 {
+		return 0, false
+	}
+	return int(i), true
+}
+
 // Count produces the COUNT(e) aggregate
 func Count(e Node) *Aggregate { return &Aggregate{Op: OpCount, Inner: e} }
 
@@ -465,6 +928,35 @@ func Earliest(e Node) *Aggregate { return &Aggregate{Op: OpEarliest, Inner: e} }
 // Latest produces the LATEST(timestamp) aggregate
 func Latest(e Node) *Aggregate { return &Aggregate{Op: OpLatest, Inner: e} }
 
+// Percentile returns the percentile argument
+// of APPROX_PERCENTILE(expr, p) as a number
+// between 0 and 1, or false if the argument is
+// not a constant in that range.
+func (a *Aggregate) Percentile() (float64, bool) {
+	if len(a.Args) != 1 {
+		return 0, false
+	}
+	n, ok := a.Args[0].(number)
+	if !ok {
+		return 0, false
+	}
+	f, _ := n.rat().Float64()
+	if f < 0 || f > 1 {
+		return 0, false
+	}
+	return f, true
+}
+
+// ApproxPercentile produces the APPROX_PERCENTILE(e, p) aggregate
+func ApproxPercentile(e Node, p float64) *Aggregate {
+	return &Aggregate{Op: OpApproxPercentile, Inner: e, Args: []Node{Float(p)}}
+}
+
+// ApproxCountDistinct produces the APPROX_COUNT_DISTINCT(e) aggregate
+func ApproxCountDistinct(e Node) *Aggregate {
+	return &Aggregate{Op: OpApproxCountDistinct, Inner: e}
+}
+
 // Equivalent returns whether two nodes
 // are equivalent.
 //
@@ -1444,6 +1936,75 @@ func In(val Node, cmp ...Node) Node {
 	return top
 }
 
+// Exists is a predicate over the elements of a list:
+//
+//   EXISTS(<var> IN <list> WHERE <where>)
+//
+// is TRUE if <where> is TRUE for at least one
+// element of <list> when the element is bound
+// to <var>, FALSE if it is not, and MISSING
+// if <list> is not a list.
+type Exists struct {
+	Var   string // the variable bound to each element
+	List  Node   // the list to be searched
+	Where Node   // the condition for each element
+}
+
+func (e *Exists) text(dst *strings.Builder, redact bool) {
+	dst.WriteString("EXISTS(")
+	dst.WriteString(QuoteID(e.Var))
+	dst.WriteString(" IN ")
+	e.List.text(dst, redact)
+	dst.WriteString(" WHERE ")
+	e.Where.text(dst, redact)
+	dst.WriteByte(')')
+}
+
+func (e *Exists) walk(v Visitor) {
+	Walk(v, e.List)
+	Walk(v, e.Where)
+}
+
+func (e *Exists) rewrite(r Rewriter) Node {
+	e.List = Rewrite(r, e.List)
+	e.Where = Rewrite(r, e.Where)
+	return e
+}
+
+func (e *Exists) Type() TypeSet {
+	return LogicalType
+}
+
+func (e *Exists) Equals(x Node) bool {
+	xe, ok := x.(*Exists)
+	return ok && e.Var == xe.Var && e.List.Equals(xe.List) && e.Where.Equals(xe.Where)
+}
+
+func (e *Exists) Encode(dst *ion.Buffer, st *ion.Symtab) {
+	dst.BeginStruct(-1)
+	settype(dst, st, "exists")
+	dst.BeginField(st.Intern("var"))
+	dst.WriteString(e.Var)
+	dst.BeginField(st.Intern("list"))
+	e.List.Encode(dst, st)
+	dst.BeginField(st.Intern("where"))
+	e.Where.Encode(dst, st)
+	dst.EndStruct()
+}
+
+func (e *Exists) setfield(name string, st *ion.Symtab, body []byte) error {
+	var err error
+	switch name {
+	case "var":
+		e.Var, _, err = ion.ReadString(body)
+	case "list":
+		e.List, _, err = Decode(st, body)
+	case "where":
+		e.Where, _, err = Decode(st, body)
+	}
+	return err
+}
+
 type Comparison struct {
 	Op          CmpOp
 	Left, Right Node
@@ -2933,6 +3494,12 @@ const (
 	Day
 	Month
 	Year
+	Quarter // quarter of the year (1 to 4)
+	Week    // week starting on Sunday
+	ISOWeek // ISO 8601 week starting on Monday
+	ISOYear // ISO 8601 week-numbering year
+	DOW     // day of the week (0 is Sunday)
+	DOY     // day of the year (1 to 366)
 )
 
 // time part -> string LUT
@@ -2945,6 +3512,12 @@ var partstring = []string{
 	Day:         "DAY",
 	Month:       "MONTH",
 	Year:        "YEAR",
+	Quarter:     "QUARTER",
+	Week:        "WEEK",
+	ISOWeek:     "ISOWEEK",
+	ISOYear:     "ISOYEAR",
+	DOW:         "DOW",
+	DOY:         "DOY",
 }
 
 func (t Timepart) String() string {
@@ -2954,6 +3527,14 @@ func (t Timepart) String() string {
 	return "UNKNOWN"
 }
 
+// CanAdd returns whether t can be used
+// with DATE_ADD and DATE_DIFF
+func (t Timepart) CanAdd() bool { return t >= Microsecond && t <= Week }
+
+// CanTrunc returns whether t can be
+// used with DATE_TRUNC
+func (t Timepart) CanTrunc() bool { return t >= Microsecond && t <= ISOYear }
+
 func DateAdd(part Timepart, value, date Node) Node {
 	return Call("DATE_ADD_"+part.String(), value, date)
 }
@@ -2981,6 +3562,21 @@ func DateExtract(part Timepart, from Node) Node {
 			return Integer(ts.Value.Month())
 		case Year:
 			return Integer(ts.Value.Year())
+		case Quarter:
+			return Integer((ts.Value.Month()-1)/3 + 1)
+		case Week:
+			yday := ts.Value.Time().YearDay() - 1
+			return Integer((yday + 7 - int(ts.Value.Time().Weekday())) / 7)
+		case ISOWeek:
+			_, week := ts.Value.Time().ISOWeek()
+			return Integer(week)
+		case ISOYear:
+			year, _ := ts.Value.Time().ISOWeek()
+			return Integer(year)
+		case DOW:
+			return Integer(ts.Value.Time().Weekday())
+		case DOY:
+			return Integer(ts.Value.Time().YearDay())
 		}
 	}
 	return Call("DATE_EXTRACT_"+part.String(), from)
@@ -2997,8 +3593,24 @@ func DateTrunc(part Timepart, from Node) Node {
 		nsec := ts.Value.Nanosecond()
 
 		switch part {
-		case Year:
-			month = 1
+		case ISOYear:
+			// the first ISO week contains January 4th
+			year, _ = ts.Value.Time().ISOWeek()
+			month, day = 1, 4
+			fallthrough
+		case ISOWeek:
+			wday := date.Date(year, month, day, 0, 0, 0, 0).Time().Weekday()
+			day -= (int(wday) + 6) % 7
+			hour, minute, second, nsec = 0, 0, 0, 0
+		case Week:
+			day -= int(ts.Value.Time().Weekday())
+			hour, minute, second, nsec = 0, 0, 0, 0
+		case Year, Quarter:
+			if part == Year {
+				month = 1
+			} else {
+				month -= (month - 1) % 3
+			}
 			fallthrough
 		case Month:
 			day = 1
@@ -3024,6 +3636,37 @@ func DateTrunc(part Timepart, from Node) Node {
 	return Call("DATE_TRUNC_"+part.String(), from)
 }
 
+// DateExtractIn is like DateExtract, but it extracts
+// part of the local time of from in the time zone zone
+// (which should be a String naming the zone)
+func DateExtractIn(part Timepart, from, zone Node) Node {
+	if z, err := TimeZone(zone); err == nil {
+		if isUTC(z) {
+			return DateExtract(part, from)
+		}
+		if ts, ok := from.(*Timestamp); ok {
+			return DateExtract(part, &Timestamp{Value: z.ToLocal(ts.Value)})
+		}
+	}
+	return DateExtract(part, CallOp(AtTimeZone, from, zone))
+}
+
+// DateTruncIn is like DateTrunc, but it truncates
+// the local time of from in the time zone zone
+// (which should be a String naming the zone)
+func DateTruncIn(part Timepart, from, zone Node) Node {
+	if z, err := TimeZone(zone); err == nil {
+		if isUTC(z) {
+			return DateTrunc(part, from)
+		}
+		if ts, ok := from.(*Timestamp); ok {
+			local := DateTrunc(part, &Timestamp{Value: z.ToLocal(ts.Value)}).(*Timestamp)
+			return &Timestamp{Value: z.FromLocal(local.Value)}
+		}
+	}
+	return Call("DATE_TRUNC_"+part.String(), from, zone)
+}
+
 // Field is a field in a Struct literal,
 type Field struct {
 	// Label is the label for the field
@@ -204,7 +204,16 @@ func (s *scanner) Lex(l *yySymType) int {
 		s.notkw = false
 		s.pos++
 		return int(b)
-	case ',', '*', '-', '/', '%', ':', '&', '^', '[', ']', '(', ')', '{', '}':
+	case '[':
+		// [*] in ANY(list[*].field)
+		if s.peekat(1) == '*' && s.peekat(2) == ']' {
+			s.pos += 3
+			return STAR_INDEX
+		}
+		s.notkw = false
+		s.pos++
+		return int(b)
+	case ',', '*', '-', '/', '%', ':', '&', '^', ']', '(', ')', '{', '}':
 		// literal operators
 		s.notkw = false
 		s.pos++
@@ -258,6 +267,12 @@ func (s *scanner) lexIdent(l *yySymType) int {
 	if !s.notkw && wordend {
 		// don't perform string allocation if we have a keyword
 		term := kwterms.get(s.from[startpos:s.pos])
+		// POSITION, ANY, and SOME are common column
+		// names, so they are only keywords when they
+		// are called
+		if (term == POSITION || term == ANY || term == SOME) && !s.peekParen() {
+			term = -1
+		}
 		if term != -1 {
 			// following AS or BY, interpret the
 			// next word as a case-sensitive identifier
@@ -268,7 +283,13 @@ func (s *scanner) lexIdent(l *yySymType) int {
 			return term
 		}
 		aggop := aggterms.get(s.from[startpos:s.pos])
-		if aggop != -1 {
+		if aggop == -1 {
+			aggop = longagg(s.from[startpos:s.pos])
+		}
+		// window function names are common column
+		// names (e.g. "rank"), so they are only
+		// treated as keywords when they are called
+		if aggop != -1 && (!expr.AggregateOp(aggop).WindowOnly() || s.peekParen()) {
 			l.integer = aggop
 			return AGGREGATE
 		}
@@ -278,6 +299,17 @@ func (s *scanner) lexIdent(l *yySymType) int {
 	return ID
 }
 
+// peekParen returns whether the next
+// non-whitespace character is '('
+func (s *scanner) peekParen() bool {
+	for i := s.pos; i < len(s.from); i++ {
+		if !isspace(s.from[i]) {
+			return s.from[i] == '('
+		}
+	}
+	return false
+}
+
 // lexNumber lexes a number-like thing
 // (NOTE: this is too permissive; we do the actual
 // checking for valid numbers at parse time)
@@ -486,5 +518,29 @@ func toAggregate(op expr.AggregateOp, body expr.Node, distinct bool, filter expr
 	if distinct && op == expr.OpCount {
 		op = expr.OpCountDistinct
 	}
+	if distinct && op == expr.OpArrayAgg {
+		op = expr.OpArrayAggDistinct
+	}
+	if op == expr.OpMedian {
+		// MEDIAN(x) is APPROX_PERCENTILE(x, 0.5)
+		agg := expr.ApproxPercentile(body, 0.5)
+		agg.Over = over
+		agg.Filter = filter
+		return agg
+	}
 	return &expr.Aggregate{Op: op, Inner: body, Over: over, Filter: filter}
 }
+
+// toOrderedAggregate produces an aggregate
+// with ORDER BY and/or LIMIT clauses (i.e. ARRAY_AGG)
+func toOrderedAggregate(op expr.AggregateOp, body expr.Node, distinct bool, order []expr.Order, limit *expr.Integer, filter expr.Node) (*expr.Aggregate, error) {
+	if op != expr.OpArrayAgg {
+		return nil, fmt.Errorf("%s does not accept ORDER BY or LIMIT", op)
+	}
+	agg := toAggregate(op, body, distinct, filter, nil)
+	agg.OrderBy = order
+	if limit != nil {
+		agg.Args = []expr.Node{*limit}
+	}
+	return agg, nil
+}
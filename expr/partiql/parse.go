@@ -20,6 +20,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/expr"
 )
 
@@ -100,6 +101,31 @@ func buildCast(inner expr.Node, id string) (expr.Node, bool) {
 	return &expr.Cast{From: inner, To: ts}, true
 }
 
+// typedLiteral builds a literal from the
+// standard SQL syntax <type> '<string>';
+// only TIMESTAMP literals are supported,
+// and they accept either a date or a date
+// and time (with an optional UTC offset)
+func typedLiteral(typ, str string) (expr.Node, bool) {
+	if !strings.EqualFold(typ, "TIMESTAMP") {
+		return nil, false
+	}
+	t, ok := date.Parse([]byte(str))
+	if !ok {
+		t, ok = date.Parse([]byte(str + "T00:00:00Z"))
+		if !ok {
+			return nil, false
+		}
+	}
+	return &expr.Timestamp{Value: t}, true
+}
+
+// isTimeZone returns whether a and b
+// are the words TIME ZONE
+func isTimeZone(a, b string) bool {
+	return strings.EqualFold(a, "TIME") && strings.EqualFold(b, "ZONE")
+}
+
 func timePart(id string) (expr.Timepart, bool) {
 	var part expr.Timepart
 	switch strings.ToUpper(id) {
@@ -119,6 +145,18 @@ func timePart(id string) (expr.Timepart, bool) {
 		part = expr.Month
 	case "YEAR":
 		part = expr.Year
+	case "QUARTER":
+		part = expr.Quarter
+	case "WEEK":
+		part = expr.Week
+	case "ISOWEEK":
+		part = expr.ISOWeek
+	case "ISOYEAR":
+		part = expr.ISOYear
+	case "DOW":
+		part = expr.DOW
+	case "DOY":
+		part = expr.DOY
 	default:
 		return 0, false
 	}
@@ -140,6 +178,52 @@ func exists(s *expr.Select) expr.Node {
 	return expr.Is(s, expr.IsNotMissing)
 }
 
+// quantified is the list operand of ANY or ALL
+// and the path (following [*]) that is applied
+// to each of its elements
+type quantified struct {
+	list expr.Node
+	rest expr.PathComponent
+}
+
+// quantify produces the EXISTS expression for
+//
+//   <left> <op> ANY(<list>[*]<rest>)
+//
+// or the NOT EXISTS expression for
+//
+//   <left> <op> ALL(<list>[*]<rest>)
+func quantify(op expr.CmpOp, left expr.Node, all bool, q quantified) expr.Node {
+	// pick a variable name that
+	// does not shadow anything in left
+	used := make(map[string]bool)
+	expr.Walk(visitfn(func(e expr.Node) {
+		if p, ok := e.(*expr.Path); ok {
+			used[p.First] = true
+		}
+	}), left)
+	name := "x"
+	for i := 0; used[name]; i++ {
+		name = fmt.Sprintf("x%d", i)
+	}
+	cmp := expr.Compare(op, left, &expr.Path{First: name, Rest: q.rest})
+	if !all {
+		return &expr.Exists{Var: name, List: q.list, Where: cmp}
+	}
+	// ALL holds when there is no element
+	// for which the comparison is not TRUE
+	return &expr.Not{Expr: &expr.Exists{Var: name, List: q.list, Where: expr.Is(cmp, expr.IsNotTrue)}}
+}
+
+type visitfn func(expr.Node)
+
+func (v visitfn) Visit(e expr.Node) expr.Visitor {
+	if e != nil {
+		v(e)
+	}
+	return v
+}
+
 // decodeDistinct inteprets the node list collected by `maybe_toplevel_distinct`
 // as inputs for `expr.Select`. The matching is as follows:
 // if nodes == nil   then SELECT ...
@@ -156,3 +240,80 @@ func decodeDistinct(nodes []expr.Node) (distinct bool, distinctExpr []expr.Node)
 
 	return false, nodes
 }
+
+// setarm is one trailing arm of a set operation;
+// it is a SELECT preceded by UNION, INTERSECT, or EXCEPT
+type setarm struct {
+	op  expr.UnionType
+	sel *expr.Select
+}
+
+func hasOrderLimit(s *expr.Select) bool {
+	return s.OrderBy != nil || s.Limit != nil || s.Offset != nil
+}
+
+// setquery combines first and the trailing
+// set operation arms into one query.
+// INTERSECT binds more tightly than UNION and EXCEPT,
+// and otherwise set operations are left-associative.
+//
+// ORDER BY, LIMIT, and OFFSET following the final
+// arm apply to the result of the whole set operation,
+// so they are hoisted into a SELECT * that
+// wraps the set operation.
+func setquery(first *expr.Select, arms []setarm) (expr.Node, error) {
+	if len(arms) == 0 {
+		return first, nil
+	}
+	prev := first
+	for i := range arms {
+		if hasOrderLimit(prev) {
+			return nil, fmt.Errorf("ORDER BY, LIMIT, and OFFSET cannot precede %s", arms[i].op)
+		}
+		prev = arms[i].sel
+	}
+	last := arms[len(arms)-1].sel
+	order, limit, offset := last.OrderBy, last.Limit, last.Offset
+	last.OrderBy, last.Limit, last.Offset = nil, nil, nil
+
+	// INTERSECT binds first, then everything else
+	// is evaluated left-to-right
+	terms := []expr.Node{first}
+	var ops []expr.UnionType
+	for i := range arms {
+		if arms[i].op == expr.Intersect {
+			n := len(terms) - 1
+			terms[n] = &expr.Union{Type: expr.Intersect, Left: terms[n], Right: arms[i].sel}
+			continue
+		}
+		terms = append(terms, arms[i].sel)
+		ops = append(ops, arms[i].op)
+	}
+	body := terms[0]
+	for i := range ops {
+		body = &expr.Union{Type: ops[i], Left: body, Right: terms[i+1]}
+	}
+	if order == nil && limit == nil && offset == nil {
+		return body, nil
+	}
+	return &expr.Select{
+		Columns: []expr.Binding{expr.Bind(expr.Star{}, "")},
+		From:    &expr.Table{Binding: expr.Bind(body, "")},
+		OrderBy: order,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
+
+// toSelect converts the result of setquery
+// into a query that can be used in positions
+// that require a SELECT (IN, EXISTS, WITH, etc.)
+func toSelect(n expr.Node) *expr.Select {
+	if s, ok := n.(*expr.Select); ok {
+		return s
+	}
+	return &expr.Select{
+		Columns: []expr.Binding{expr.Bind(expr.Star{}, "")},
+		From:    &expr.Table{Binding: expr.Bind(n, "")},
+	}
+}
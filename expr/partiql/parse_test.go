@@ -39,6 +39,10 @@ var sameq = []string{
 	"SELECT x AS \"join\" FROM table WHERE x = 'foo' OR y = 'bar'",
 	// test parsing of escape sequences
 	`SELECT SPLIT_PART(text, '\n', 1) AS line FROM x`,
+	`SELECT REGEXP_EXTRACT(line, 'user=([a-z]+)', 1) AS usr, REGEXP_COUNT(line, '[0-9]+') AS n FROM x`,
+	`SELECT REGEXP_REPLACE(line, '([0-9]+)', '<$1>') AS r FROM x`,
+	`SELECT LEFT(x, 3) AS l, RIGHT(x, -1) AS r, LPAD(x, 5, '0') AS p FROM x`,
+	`SELECT STRPOS(x, 'lo') AS p, REPLACE(x, 'a', 'b') AS r FROM x WHERE STARTS_WITH(x, 'ab')`,
 	`SELECT '\u2408' AS y`,
 	"SELECT x FROM table WHERE x LIKE '%xyz'",
 	"SELECT x FROM table WHERE x IS NULL",
@@ -71,6 +75,13 @@ var sameq = []string{
 	"SELECT * FROM (t1 ++ t2 ++ t3)",
 	"SELECT x, y INTO db.xyz FROM db.foo WHERE x = 'foo' AND y = 'bar'",
 	"SELECT x, SUM(x) OVER (PARTITION BY y, z ORDER BY col0 ASC NULLS FIRST, col1 DESC NULLS FIRST) FROM db.foo",
+	"SELECT x, ROW_NUMBER() OVER (PARTITION BY y ORDER BY z DESC NULLS FIRST) AS rn FROM db.foo",
+	"SELECT RANK() OVER (ORDER BY x ASC NULLS FIRST), DENSE_RANK() OVER (PARTITION BY y, z) FROM db.foo",
+	"SELECT LAG(x, 2, 0) OVER (PARTITION BY y ORDER BY z ASC NULLS FIRST), LEAD(x) OVER (ORDER BY z ASC NULLS FIRST) FROM db.foo",
+	"SELECT FIRST_VALUE(x) OVER (PARTITION BY y ORDER BY z ASC NULLS FIRST), LAST_VALUE(x) OVER (PARTITION BY y) FROM db.foo",
+	"SELECT APPROX_PERCENTILE(x, 0.9) AS p90 FROM db.foo GROUP BY y",
+	"SELECT STDDEV_POP(x), VAR_SAMP(x) AS v, CORR(x, y) FROM db.foo GROUP BY z",
+	"SELECT ARRAY_AGG(x ORDER BY y DESC NULLS FIRST LIMIT 10) AS lst, ARRAY_AGG(DISTINCT z) FROM db.foo GROUP BY w",
 	"SELECT COUNT(*) FROM table",
 	"SELECT COUNT(*) AS total, COUNT(x) FILTER (WHERE x > 0) AS greater FROM table",
 	"SELECT [a, b, c] AS lst FROM foo",
@@ -80,6 +91,14 @@ var sameq = []string{
 	"SELECT * FROM UNPIVOT t AS a AT b",
 	"SELECT * FROM UNPIVOT t AS a",
 	"SELECT * FROM UNPIVOT {'x': 'y'} AS a",
+	"SELECT x FROM a UNION ALL SELECT y FROM b",
+	"SELECT x FROM a UNION SELECT x FROM b UNION ALL SELECT x FROM c",
+	"SELECT x FROM a EXCEPT SELECT x FROM b INTERSECT SELECT x FROM c",
+	"SELECT x FROM a INTERSECT SELECT x FROM b EXCEPT SELECT x FROM c",
+	"SELECT * FROM (SELECT x FROM a UNION ALL SELECT x FROM b) ORDER BY x DESC NULLS FIRST LIMIT 3",
+	"SELECT COUNT(*) FROM (SELECT x FROM a INTERSECT SELECT x FROM b) WHERE x > 0",
+	"SELECT x, (SELECT * FROM (SELECT y FROM a UNION ALL SELECT y FROM b) LIMIT 1) FROM foo",
+	"SELECT * FROM foo WHERE EXISTS(r IN records WHERE r.status = 500 AND r.size > 0)",
 }
 
 func TestParseSFW(t *testing.T) {
@@ -175,6 +194,35 @@ func TestParseNormalization(t *testing.T) {
 			"select x[\"y\"] from foo",
 			"SELECT x.y FROM foo",
 		},
+		{
+			"select position('lo' in x), position from foo",
+			`SELECT STRPOS(x, 'lo'), "position" FROM foo`,
+		},
+		{
+			// the substring must be parenthesized
+			// unless it is a literal or a path;
+			// the string may be any expression
+			"select position((y || 'o') in (x || y)) from foo",
+			`SELECT STRPOS(CONCAT(x, y), CONCAT(y, 'o')) FROM foo`,
+		},
+		{
+			// window function names are only
+			// keywords when they are called
+			"select rank, lag from foo",
+			`SELECT "rank", "lag" FROM foo`,
+		},
+		{
+			"select median(x), percentile_cont(0.25) within group (order by y) from foo",
+			"SELECT APPROX_PERCENTILE(x, 0.5), APPROX_PERCENTILE(y, 0.25) FROM foo",
+		},
+		{
+			"select stddev(x), variance(x), covar_pop(x, y) from foo",
+			"SELECT STDDEV_SAMP(x), VAR_SAMP(x), COVAR_POP(x, y) FROM foo",
+		},
+		{
+			"select array_agg(x limit 5), array_agg(distinct y order by z nulls last) from foo",
+			"SELECT ARRAY_AGG(x LIMIT 5), ARRAY_AGG(DISTINCT y ORDER BY z ASC NULLS LAST) FROM foo",
+		},
 		{
 			// test parens
 			"select * from foo where ((a IS NULL) AND b IS NULL) OR c IS NULL",
@@ -211,6 +259,27 @@ func TestParseNormalization(t *testing.T) {
 			"SELECT * FROM foo WHERE date > UTCNOW()",
 			"SELECT * FROM foo WHERE BEFORE(`2006-01-02T15:04:05.999Z`, date)",
 		},
+		{
+			"SELECT * FROM foo WHERE ts >= TIMESTAMP '2022-01-02 03:04:05' AND ts < timestamp '2022-01-03'",
+			"SELECT * FROM foo WHERE BEFORE(`2022-01-02T03:04:04.999999Z`, ts) AND BEFORE(ts, `2022-01-03T00:00:00Z`)",
+		},
+		{
+			"SELECT * FROM foo WHERE ts BETWEEN TIMESTAMP '2022-01-02T00:00:00+01:00' AND y",
+			"SELECT * FROM foo WHERE BEFORE(`2022-01-01T22:59:59.999999Z`, ts) AND ts <= y",
+		},
+		{
+			"SELECT * FROM foo WHERE 500 = ANY(t.records[*].status)",
+			"SELECT * FROM foo WHERE EXISTS(x IN t.records WHERE 500 = x.status)",
+		},
+		{
+			"SELECT * FROM foo WHERE x < some(tags) OR y <> ALL(lst[*][0])",
+			"SELECT * FROM foo WHERE EXISTS(x0 IN tags WHERE x < x0) OR !(EXISTS(x IN lst WHERE y <> x[0] IS NOT TRUE))",
+		},
+		{
+			// ANY and SOME are only keywords when they are called
+			"SELECT any, some FROM foo",
+			`SELECT "any", "some" FROM foo`,
+		},
 		{
 			"SELECT EXTRACT(minute FROM x) FROM foo",
 			"SELECT DATE_EXTRACT_MINUTE(x) FROM foo",
@@ -227,6 +296,34 @@ func TestParseNormalization(t *testing.T) {
 			"SELECT DATE_TRUNC(minute, UTCNOW()) FROM foo",
 			"SELECT `2006-01-02T15:04:00Z` FROM foo",
 		},
+		{
+			"SELECT EXTRACT(dow FROM x), EXTRACT(ISOyear FROM UTCNOW()), DATE_TRUNC(week, x), DATE_ADD(quarter, 1, x) FROM foo",
+			"SELECT DATE_EXTRACT_DOW(x), 2006, DATE_TRUNC_WEEK(x), DATE_ADD_QUARTER(1, x) FROM foo",
+		},
+		{
+			"SELECT DATE_TRUNC(isoweek, UTCNOW()), DATE_TRUNC(quarter, UTCNOW()) FROM foo",
+			"SELECT `2006-01-02T00:00:00Z`, `2006-01-01T00:00:00Z` FROM foo",
+		},
+		{
+			"SELECT DATE_TRUNC(day, UTCNOW(), 'America/New_York') FROM foo",
+			"SELECT `2006-01-02T05:00:00Z` FROM foo",
+		},
+		{
+			"SELECT DATE_TRUNC(day, x, 'UTC'), DATE_TRUNC(hour, x, '+05:30') FROM foo",
+			"SELECT DATE_TRUNC_DAY(x), DATE_TRUNC_HOUR(x, '+05:30') FROM foo",
+		},
+		{
+			"SELECT x AT TIME ZONE 'Europe/Warsaw' AS y FROM foo",
+			"SELECT x AT TIME ZONE 'Europe/Warsaw' AS y FROM foo",
+		},
+		{
+			"SELECT EXTRACT(hour FROM x AT time zone 'Asia/Kolkata') FROM foo",
+			"SELECT DATE_EXTRACT_HOUR(x AT TIME ZONE 'Asia/Kolkata') FROM foo",
+		},
+		{
+			"SELECT EXTRACT(day FROM x, 'Asia/Kolkata'), EXTRACT(day FROM UTCNOW(), '+09:00') FROM foo",
+			"SELECT DATE_EXTRACT_DAY(x AT TIME ZONE 'Asia/Kolkata'), 3 FROM foo",
+		},
 		{
 			"SELECT * FROM foo WHERE x IN (SELECT COUNT(x) FROM foo ORDER BY COUNT(x) DESC NULLS FIRST LIMIT 5)",
 			"SELECT * FROM foo WHERE IN_SUBQUERY(x, (SELECT COUNT(x) FROM foo ORDER BY COUNT(x) DESC NULLS FIRST LIMIT 5))",
@@ -239,6 +336,23 @@ func TestParseNormalization(t *testing.T) {
 			"SELECT EXISTS(SELECT x, y FROM foo WHERE x = 3) AS exist",
 			"SELECT (SELECT x, y FROM foo WHERE x = 3 LIMIT 1) IS NOT MISSING AS exist",
 		},
+		{
+			// trailing ORDER BY and LIMIT apply to the whole set operation
+			"SELECT x FROM a UNION ALL SELECT x FROM b ORDER BY x LIMIT 10",
+			"SELECT * FROM (SELECT x FROM a UNION ALL SELECT x FROM b) ORDER BY x ASC NULLS FIRST LIMIT 10",
+		},
+		{
+			"SELECT x FROM a UNION DISTINCT SELECT x FROM b",
+			"SELECT x FROM a UNION SELECT x FROM b",
+		},
+		{
+			"SELECT * FROM foo WHERE x IN (SELECT y FROM a INTERSECT SELECT y FROM b)",
+			"SELECT * FROM foo WHERE IN_SUBQUERY(x, (SELECT * FROM (SELECT y FROM a INTERSECT SELECT y FROM b)))",
+		},
+		{
+			"WITH t AS (SELECT x FROM a UNION ALL SELECT x FROM b) SELECT COUNT(*) FROM t",
+			"WITH t AS (SELECT * FROM (SELECT x FROM a UNION ALL SELECT x FROM b)) SELECT COUNT(*) FROM t",
+		},
 	}
 
 	tm, ok := date.Parse([]byte("2006-01-02T15:04:05.999Z"))
@@ -281,6 +395,21 @@ func TestParseGarbage(t *testing.T) {
 		"select CAST(x AS notatype) from y",
 		"select a[1E100] from y",
 		"seleCt CoAlesC%(CoAlesC%(A[10000000000000000000]))",
+		"select x from a limit 1 union all select x from b",
+		"select x into db.out from a union all select x from b",
+		"select x from a intersect all select x from b",
+		"select count() from t",
+		"select sum(x) within group (order by y) from t",
+		"select percentile_cont(0.5) within group (order by y) over (partition by z) from t",
+		"select sum(x order by y) from t",
+		"select date_add(dow, 1, x) from t",
+		"select * from t where x[*] = 3",
+		"select * from t where 3 = any(x[*].y[*].z)",
+		"select date_diff(isoyear, x, y) from t",
+		"select date_trunc(doy, x) from t",
+		"select extract(fortnight from x) from t",
+		"select * from t where x < timestamp 'yesterday'",
+		"select * from t where x < interval '1 day'",
 	}
 	for i := range queries {
 		_, err := Parse([]byte(queries[i]))
@@ -52,13 +52,17 @@ import (
     limbs    []expr.CaseLimb
     values   []expr.Node
     orders   []expr.Order
+    quant    quantified
+    setop    expr.UnionType
+    arms     []setarm
 }
 
 %token ERROR EOF
-%left UNION
+%left UNION INTERSECT EXCEPT
 %token SELECT FROM WHERE GROUP ORDER BY HAVING LIMIT OFFSET WITH INTO
-%token DISTINCT ALL AS EXISTS NULLS FIRST LAST ASC DESC UNPIVOT AT
-%token PARTITION
+%token DISTINCT ALL AS EXISTS NULLS FIRST LAST ASC DESC UNPIVOT
+%token PARTITION WITHIN POSITION
+%token ANY SOME STAR_INDEX
 %token VALUE
 %right COALESCE NULLIF EXTRACT DATE_TRUNC
 %right CAST UTCNOW
@@ -83,23 +87,27 @@ import (
 %left <empty> '+' '-'
 %left <empty> '*' '/' '%'
 %left <empty> CONCAT APPEND
+%left AT
 %left NEGATION_PRECEDENCE
 %nonassoc <empty> '.'
 
 %token <expr> NUMBER ION
 %token <str> STRING
 
-%type <expr> query expr datum datum_or_parens path_expression maybe_into
+%type <expr> query set_query expr datum datum_or_parens path_expression maybe_into
 %type <expr> where_expr having_expr case_optional_else parenthesized_expr
 %type <expr> optional_filter
 %type <expr> unpivot explicit_struct_definition explicit_list_definition
 %type <expr> tuple_reference
 %type <with> maybe_cte_bindings cte_bindings
 %type <pc> path_component
-%type <yesno> ascdesc nullslast maybe_distinct
+%type <yesno> ascdesc nullslast maybe_distinct quantifier
 %type <str> identifier
-%type <integer> literal_int
-%type <sel> select_stmt
+%type <integer> literal_int quantified_cmp
+%type <quant> quantified_list
+%type <sel> select_stmt simple_select
+%type <setop> set_op
+%type <arms> set_arms
 %type <bindings> group_expr binding_list
 %type <bind> value_binding
 %type <from> from_expr lhs_from_expr
@@ -116,15 +124,51 @@ import (
 %%
 
 query:
-maybe_cte_bindings SELECT maybe_toplevel_distinct binding_list maybe_into from_expr where_expr group_expr having_expr order_expr limit_expr offset_expr
+maybe_cte_bindings SELECT maybe_toplevel_distinct binding_list maybe_into from_expr where_expr group_expr having_expr order_expr limit_expr offset_expr set_arms
 {
   yylex.(*scanner).with = $1
   yylex.(*scanner).into = $5
+  if $5 != nil && $13 != nil {
+    yylex.Error("INTO cannot be combined with set operations")
+    return 1
+  }
   distinct, distinctExpr := decodeDistinct($3)
-  yylex.(*scanner).result = &expr.Select{Distinct: distinct, DistinctExpr: distinctExpr, Columns: $4, From: $6, Where: $7, GroupBy: $8, Having: $9, OrderBy: $10, Limit: $11, Offset: $12};
+  body, err := setquery(&expr.Select{Distinct: distinct, DistinctExpr: distinctExpr, Columns: $4, From: $6, Where: $7, GroupBy: $8, Having: $9, OrderBy: $10, Limit: $11, Offset: $12}, $13)
+  if err != nil {
+    yylex.Error(err.Error())
+    return 1
+  }
+  yylex.(*scanner).result = body
+}
+
+// a SELECT-FROM-WHERE query followed by
+// zero or more UNION/INTERSECT/EXCEPT arms
+set_query:
+simple_select set_arms
+{
+  body, err := setquery($1, $2)
+  if err != nil {
+    yylex.Error(err.Error())
+    return 1
+  }
+  $$ = body
 }
 
 select_stmt:
+set_query { $$ = toSelect($1) }
+
+set_arms:
+{ $$ = nil } |
+set_arms set_op simple_select { $$ = append($1, setarm{op: $2, sel: $3}) }
+
+set_op:
+UNION ALL { $$ = expr.UnionAll } |
+UNION { $$ = expr.UnionDistinct } |
+UNION DISTINCT { $$ = expr.UnionDistinct } |
+INTERSECT { $$ = expr.Intersect } |
+EXCEPT { $$ = expr.Except }
+
+simple_select:
 SELECT maybe_toplevel_distinct binding_list from_expr where_expr group_expr having_expr order_expr limit_expr offset_expr
 {
     distinct, distinctExpr := decodeDistinct($2)
@@ -162,6 +206,15 @@ NULL { $$ = expr.Null{} } |
 MISSING { $$ = expr.Missing{} } |
 STRING { $$ = expr.String($1) } |
 ION { $$ = $1 } |
+ID STRING
+{
+  lit, ok := typedLiteral($1, $2)
+  if !ok {
+    yylex.Error(__yyfmt__.Sprintf("bad %s literal %q", $1, $2))
+    return 1
+  }
+  $$ = lit
+} |
 path_expression { $$ = $1 }
 
 // datum_or_parens is guaranteed to
@@ -178,7 +231,7 @@ datum { $$ = $1 } |
 '(' parenthesized_expr ')' { $$ = $2 }
 
 parenthesized_expr:
-select_stmt { $$ = $1 } |
+set_query { $$ = $1 } |
 expr { $$ = $1 }
 
 maybe_distinct:
@@ -200,11 +253,57 @@ datum_or_parens
 {
   $$ = toAggregate(expr.AggregateOp($1), $4, $3, $6, $7)
 }
+| AGGREGATE '(' maybe_distinct expr ',' value_list ')' optional_filter maybe_window
+{
+  agg := toAggregate(expr.AggregateOp($1), $4, $3, $8, $9)
+  agg.Args = $6
+  $$ = agg
+}
+| AGGREGATE '(' maybe_distinct expr ')' WITHIN GROUP '(' ORDER BY expr ')' optional_filter // PERCENTILE_CONT(p) WITHIN GROUP (ORDER BY x)
+{
+  op := expr.AggregateOp($1)
+  if op != expr.OpApproxPercentile || $3 {
+    yylex.Error(__yyfmt__.Sprintf("%s does not accept WITHIN GROUP", op))
+    return 1
+  }
+  agg := toAggregate(op, $11, false, $13, nil)
+  agg.Args = []expr.Node{$4}
+  $$ = agg
+}
+| AGGREGATE '(' maybe_distinct expr ORDER BY order_cols limit_expr ')' optional_filter // ARRAY_AGG(x ORDER BY y LIMIT n)
+{
+  agg, err := toOrderedAggregate(expr.AggregateOp($1), $4, $3, $7, $8, $10)
+  if err != nil {
+    yylex.Error(err.Error())
+    return 1
+  }
+  $$ = agg
+}
+| AGGREGATE '(' maybe_distinct expr LIMIT literal_int ')' optional_filter // ARRAY_AGG(x LIMIT n)
+{
+  n := expr.Integer($6)
+  agg, err := toOrderedAggregate(expr.AggregateOp($1), $4, $3, nil, &n, $8)
+  if err != nil {
+    yylex.Error(err.Error())
+    return 1
+  }
+  $$ = agg
+}
 | AGGREGATE '(' '*' ')' optional_filter maybe_window // realistically only COUNT(*)
 {
   distinct := false
   $$ = toAggregate(expr.AggregateOp($1), expr.Star{}, distinct, $5, $6)
 }
+| AGGREGATE '(' ')' optional_filter maybe_window // ROW_NUMBER(), RANK(), etc.
+{
+  op := expr.AggregateOp($1)
+  if !op.NoArgs() {
+    yylex.Error(__yyfmt__.Sprintf("%s requires an argument", op))
+    return 1
+  }
+  distinct := false
+  $$ = toAggregate(op, expr.Star{}, distinct, $4, $5)
+}
 | CASE case_limbs case_optional_else END
 {
   $$ = &expr.Case{Limbs: $2, Else: $3}
@@ -229,7 +328,7 @@ datum_or_parens
 | DATE_ADD '(' ID ',' expr ',' expr ')'
 {
   part, ok := timePart($3)
-  if !ok {
+  if !ok || !part.CanAdd() {
     yylex.Error(__yyfmt__.Sprintf("bad DATE_ADD part %q", $3))
   }
   $$ = expr.DateAdd(part, $5, $7)
@@ -237,7 +336,7 @@ datum_or_parens
 | DATE_DIFF '(' ID ',' expr ',' expr ')'
 {
   part, ok := timePart($3)
-  if !ok {
+  if !ok || !part.CanAdd() {
     yylex.Error(__yyfmt__.Sprintf("bad DATE_DIFF part %q", $3))
   }
   $$ = expr.DateDiff(part, $5, $7)
@@ -245,11 +344,19 @@ datum_or_parens
 | DATE_TRUNC '(' ID ',' expr ')'
 {
   part, ok := timePart($3)
-  if !ok {
+  if !ok || !part.CanTrunc() {
     yylex.Error(__yyfmt__.Sprintf("bad DATE_TRUNC part %q", $3))
   }
   $$ = expr.DateTrunc(part, $5)
 }
+| DATE_TRUNC '(' ID ',' expr ',' expr ')'
+{
+  part, ok := timePart($3)
+  if !ok || !part.CanTrunc() {
+    yylex.Error(__yyfmt__.Sprintf("bad DATE_TRUNC part %q", $3))
+  }
+  $$ = expr.DateTruncIn(part, $5, $7)
+}
 | EXTRACT '(' ID FROM expr ')'
 {
   part, ok := timePart($3)
@@ -258,6 +365,26 @@ datum_or_parens
   }
   $$ = expr.DateExtract(part, $5)
 }
+| EXTRACT '(' ID FROM expr ',' expr ')'
+{
+  part, ok := timePart($3)
+  if !ok {
+    yylex.Error(__yyfmt__.Sprintf("bad EXTRACT part %q", $3))
+  }
+  $$ = expr.DateExtractIn(part, $5, $7)
+}
+| POSITION '(' datum_or_parens IN expr ')' // the substring is not an expr so that IN is unambiguous
+{
+  $$ = expr.CallOp(expr.StrPos, $5, $3)
+}
+| LEFT '(' expr ',' expr ')'
+{
+  $$ = expr.CallOp(expr.Left, $3, $5)
+}
+| RIGHT '(' expr ',' expr ')'
+{
+  $$ = expr.CallOp(expr.Right, $3, $5)
+}
 | UTCNOW '(' ')'
 {
   $$ = yylex.(*scanner).utcnow()
@@ -290,6 +417,14 @@ datum_or_parens
 {
   $$ = exists($3)
 }
+| EXISTS '(' identifier IN expr WHERE expr ')'
+{
+  $$ = &expr.Exists{Var: $3, List: $5, Where: $7}
+}
+| expr quantified_cmp quantifier '(' quantified_list ')'
+{
+  $$ = quantify(expr.CmpOp($2), $1, $3, $5)
+}
 | expr '|' expr
 {
   $$ = expr.BitOr($1, $3)
@@ -342,6 +477,14 @@ datum_or_parens
 {
   $$ = expr.Append($1, $3)
 }
+| expr AT ID ID STRING %prec AT
+{
+  if !isTimeZone($3, $4) {
+    yylex.Error(__yyfmt__.Sprintf("unexpected %s %s after AT", $3, $4))
+    return 1
+  }
+  $$ = expr.CallOp(expr.AtTimeZone, $1, expr.String($5))
+}
 | '-' expr %prec NEGATION_PRECEDENCE
 {
   $$ = expr.Neg($2)
@@ -515,6 +658,10 @@ OVER '(' PARTITION BY value_list order_expr ')'
 {
   $$ = &expr.Window{PartitionBy: $5, OrderBy: $6}
 }
+| OVER '(' order_expr ')'
+{
+  $$ = &expr.Window{OrderBy: $3}
+}
 | { $$ = nil }
 
 join_kind:
@@ -560,6 +707,29 @@ path_component:
 identifier:
 ID { $$ = $1 }
 
+// match the comparison in <expr> <cmp> ANY(<list>)
+quantified_cmp:
+EQ { $$ = int(expr.Equals) } |
+NE { $$ = int(expr.NotEquals) } |
+LT { $$ = int(expr.Less) } |
+LE { $$ = int(expr.LessEquals) } |
+GT { $$ = int(expr.Greater) } |
+GE { $$ = int(expr.GreaterEquals) }
+
+// match ANY/SOME (false) or ALL (true)
+quantifier:
+ANY { $$ = false } |
+SOME { $$ = false } |
+ALL { $$ = true }
+
+// match <list> or <list>[*]<path>
+quantified_list:
+expr { $$ = quantified{list: $1} } |
+identifier path_component STAR_INDEX path_component
+{
+  $$ = quantified{list: &expr.Path{First: $1, Rest: $2}, rest: $4}
+}
+
 case_optional_else:
 { $$ = nil } |
 ELSE expr { $$ = $2 }
@@ -617,7 +787,7 @@ offset_expr:
 OFFSET literal_int { n := expr.Integer($2); $$ = &n }
 
 unpivot:
-UNPIVOT tuple_reference AS identifier { $$ = &expr.Unpivot{ TupleRef: $2, As: $4, At: "" } } |
+UNPIVOT tuple_reference AS identifier %prec UNION { $$ = &expr.Unpivot{ TupleRef: $2, As: $4, At: "" } } |
 UNPIVOT tuple_reference AS identifier AT identifier { $$ = &expr.Unpivot{ TupleRef: $2, As: $4, At: $6 } } |
 UNPIVOT tuple_reference AT identifier AS identifier { $$ = &expr.Unpivot{ TupleRef: $2, As: $6, At: $4 } }
 
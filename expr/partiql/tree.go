@@ -16,6 +16,7 @@ package partiql
 
 import (
 	"sort"
+	"strings"
 
 	"github.com/SnellerInc/sneller/expr"
 )
@@ -28,6 +29,30 @@ var kwterms termlist
 
 var aggterms termlist
 
+// longaggterms is the list of aggregate
+// names that are too long to be represented
+// as a wordcode
+var longaggterms = []struct {
+	name string
+	op   expr.AggregateOp
+}{
+	{"APPROX_COUNT_DISTINCT", expr.OpApproxCountDistinct},
+	{"APPROX_PERCENTILE", expr.OpApproxPercentile},
+	{"PERCENTILE_CONT", expr.OpApproxPercentile},
+}
+
+// longagg returns the aggregate op
+// for a long aggregate name, or -1
+func longagg(b []byte) int {
+	for i := range longaggterms {
+		name := longaggterms[i].name
+		if len(name) == len(b) && strings.EqualFold(name, string(b)) {
+			return int(longaggterms[i].op)
+		}
+	}
+	return -1
+}
+
 func init() {
 	type pair struct {
 		name string
@@ -50,6 +75,8 @@ func init() {
 		{"EXTRACT", EXTRACT},
 		{"EXISTS", EXISTS},
 		{"UNION", UNION},
+		{"INTERSECT", INTERSECT},
+		{"EXCEPT", EXCEPT},
 		{"OR", OR},
 		{"ON", ON},
 		{"OVER", OVER},
@@ -69,17 +96,21 @@ func init() {
 		{"NULLS", NULLS},
 		{"NULLIF", NULLIF},
 		{"PARTITION", PARTITION},
+		{"POSITION", POSITION},
 		{"MISSING", MISSING},
 		{"IS", IS},
 		{"IN", IN},
 		{"INTO", INTO},
 		{"NOT", NOT},
 		{"ALL", ALL},
+		{"ANY", ANY},
+		{"SOME", SOME},
 		{"LEFT", LEFT},
 		{"RIGHT", RIGHT},
 		{"CROSS", CROSS},
 		{"JOIN", JOIN},
 		{"INNER", INNER},
+		{"FULL", FULL},
 		{"TRUE", TRUE},
 		{"FALSE", FALSE},
 		{"BETWEEN", BETWEEN},
@@ -94,6 +125,7 @@ func init() {
 		{"UTCNOW", UTCNOW},
 		{"WITH", WITH},
 		{"FILTER", FILTER},
+		{"WITHIN", WITHIN},
 		{"UNPIVOT", UNPIVOT},
 	} {
 		code, ok := wordcode([]byte(pair.name))
@@ -117,6 +149,23 @@ func init() {
 		{"BIT_AND", int(expr.OpBitAnd)},
 		{"BIT_OR", int(expr.OpBitOr)},
 		{"BIT_XOR", int(expr.OpBitXor)},
+		{"ROW_NUMBER", int(expr.OpRowNumber)},
+		{"RANK", int(expr.OpRank)},
+		{"DENSE_RANK", int(expr.OpDenseRank)},
+		{"LAG", int(expr.OpLag)},
+		{"LEAD", int(expr.OpLead)},
+		{"FIRST_VALUE", int(expr.OpFirstValue)},
+		{"LAST_VALUE", int(expr.OpLastValue)},
+		{"MEDIAN", int(expr.OpMedian)},
+		{"VAR_POP", int(expr.OpVarPop)},
+		{"VAR_SAMP", int(expr.OpVarSamp)},
+		{"VARIANCE", int(expr.OpVarSamp)},
+		{"STDDEV_POP", int(expr.OpStddevPop)},
+		{"STDDEV_SAMP", int(expr.OpStddevSamp)},
+		{"STDDEV", int(expr.OpStddevSamp)},
+		{"COVAR_POP", int(expr.OpCovarPop)},
+		{"CORR", int(expr.OpCorr)},
+		{"ARRAY_AGG", int(expr.OpArrayAgg)},
 	} {
 		code, ok := wordcode([]byte(pair.name))
 		if !ok {
@@ -126,7 +175,7 @@ func init() {
 	}
 	sort.Sort(aggterms)
 	expr.IsKeyword = func(x string) bool {
-		return kwterms.contains(x) || aggterms.contains(x)
+		return kwterms.contains(x) || aggterms.contains(x) || longagg([]byte(x)) != -1
 	}
 }
 
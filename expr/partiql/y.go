@@ -44,92 +44,102 @@ type yySymType struct {
 	limbs    []expr.CaseLimb
 	values   []expr.Node
 	orders   []expr.Order
+	quant    quantified
+	setop    expr.UnionType
+	arms     []setarm
 }
 
 const ERROR = 57346
 const EOF = 57347
 const UNION = 57348
-const SELECT = 57349
-const FROM = 57350
-const WHERE = 57351
-const GROUP = 57352
-const ORDER = 57353
-const BY = 57354
-const HAVING = 57355
-const LIMIT = 57356
-const OFFSET = 57357
-const WITH = 57358
-const INTO = 57359
-const DISTINCT = 57360
-const ALL = 57361
-const AS = 57362
-const EXISTS = 57363
-const NULLS = 57364
-const FIRST = 57365
-const LAST = 57366
-const ASC = 57367
-const DESC = 57368
-const UNPIVOT = 57369
-const AT = 57370
-const PARTITION = 57371
-const VALUE = 57372
-const COALESCE = 57373
-const NULLIF = 57374
-const EXTRACT = 57375
-const DATE_TRUNC = 57376
-const CAST = 57377
-const UTCNOW = 57378
-const DATE_ADD = 57379
-const DATE_DIFF = 57380
-const EARLIEST = 57381
-const LATEST = 57382
-const JOIN = 57383
-const LEFT = 57384
-const RIGHT = 57385
-const CROSS = 57386
-const INNER = 57387
-const OUTER = 57388
-const FULL = 57389
-const ON = 57390
-const AGGREGATE = 57391
-const ID = 57392
-const NULL = 57393
-const TRUE = 57394
-const FALSE = 57395
-const MISSING = 57396
-const OR = 57397
-const AND = 57398
-const NOT = 57399
-const BETWEEN = 57400
-const CASE = 57401
-const WHEN = 57402
-const THEN = 57403
-const ELSE = 57404
-const END = 57405
-const TO = 57406
-const EQ = 57407
-const NE = 57408
-const LT = 57409
-const LE = 57410
-const GT = 57411
-const GE = 57412
-const SIMILAR = 57413
-const REGEXP_MATCH_CI = 57414
-const ILIKE = 57415
-const LIKE = 57416
-const IN = 57417
-const IS = 57418
-const OVER = 57419
-const FILTER = 57420
-const SHIFT_LEFT_LOGICAL = 57421
-const SHIFT_RIGHT_ARITHMETIC = 57422
-const SHIFT_RIGHT_LOGICAL = 57423
-const CONCAT = 57424
-const APPEND = 57425
-const NEGATION_PRECEDENCE = 57426
-const NUMBER = 57427
-const ION = 57428
-const STRING = 57429
+const INTERSECT = 57349
+const EXCEPT = 57350
+const SELECT = 57351
+const FROM = 57352
+const WHERE = 57353
+const GROUP = 57354
+const ORDER = 57355
+const BY = 57356
+const HAVING = 57357
+const LIMIT = 57358
+const OFFSET = 57359
+const WITH = 57360
+const INTO = 57361
+const DISTINCT = 57362
+const ALL = 57363
+const AS = 57364
+const EXISTS = 57365
+const NULLS = 57366
+const FIRST = 57367
+const LAST = 57368
+const ASC = 57369
+const DESC = 57370
+const UNPIVOT = 57371
+const PARTITION = 57372
+const WITHIN = 57373
+const POSITION = 57374
+const ANY = 57375
+const SOME = 57376
+const STAR_INDEX = 57377
+const VALUE = 57378
+const COALESCE = 57379
+const NULLIF = 57380
+const EXTRACT = 57381
+const DATE_TRUNC = 57382
+const CAST = 57383
+const UTCNOW = 57384
+const DATE_ADD = 57385
+const DATE_DIFF = 57386
+const EARLIEST = 57387
+const LATEST = 57388
+const JOIN = 57389
+const LEFT = 57390
+const RIGHT = 57391
+const CROSS = 57392
+const INNER = 57393
+const OUTER = 57394
+const FULL = 57395
+const ON = 57396
+const AGGREGATE = 57397
+const ID = 57398
+const NULL = 57399
+const TRUE = 57400
+const FALSE = 57401
+const MISSING = 57402
+const OR = 57403
+const AND = 57404
+const NOT = 57405
+const BETWEEN = 57406
+const CASE = 57407
+const WHEN = 57408
+const THEN = 57409
+const ELSE = 57410
+const END = 57411
+const TO = 57412
+const EQ = 57413
+const NE = 57414
+const LT = 57415
+const LE = 57416
+const GT = 57417
+const GE = 57418
+const SIMILAR = 57419
+const REGEXP_MATCH_CI = 57420
+const ILIKE = 57421
+const LIKE = 57422
+const IN = 57423
+const IS = 57424
+const OVER = 57425
+const FILTER = 57426
+const SHIFT_LEFT_LOGICAL = 57427
+const SHIFT_RIGHT_ARITHMETIC = 57428
+const SHIFT_RIGHT_LOGICAL = 57429
+const CONCAT = 57430
+const APPEND = 57431
+const AT = 57432
+const NEGATION_PRECEDENCE = 57433
+const NUMBER = 57434
+const ION = 57435
+const STRING = 57436
 
 var yyToknames = [...]string{
 	"$end",
@@ -138,6 +148,8 @@ var yyToknames = [...]string{
 	"ERROR",
 	"EOF",
 	"UNION",
+	"INTERSECT",
+	"EXCEPT",
 	"SELECT",
 	"FROM",
 	"WHERE",
@@ -159,8 +171,12 @@ var yyToknames = [...]string{
 	"ASC",
 	"DESC",
 	"UNPIVOT",
-	"AT",
 	"PARTITION",
+	"WITHIN",
+	"POSITION",
+	"ANY",
+	"SOME",
+	"STAR_INDEX",
 	"VALUE",
 	"COALESCE",
 	"NULLIF",
@@ -232,6 +248,7 @@ var yyToknames = [...]string{
 	"'%'",
 	"CONCAT",
 	"APPEND",
+	"AT",
 	"NEGATION_PRECEDENCE",
 	"'.'",
 	"NUMBER",
@@ -251,372 +268,469 @@ var yyExca = [...]int16{
 	-1, 1,
 	1, -1,
 	-2, 0,
-	-1, 336,
-	62, 67,
-	63, 67,
-	65, 67,
-	66, 67,
-	67, 67,
-	74, 67,
-	75, 67,
-	76, 67,
-	77, 67,
-	78, 67,
-	79, 67,
-	-2, 123,
+	-1, 421,
+	68, 90,
+	69, 90,
+	71, 90,
+	72, 90,
+	73, 90,
+	80, 90,
+	81, 90,
+	82, 90,
+	83, 90,
+	84, 90,
+	85, 90,
+	-2, 147,
 }
 
 const yyPrivate = 57344
 
-const yyLast = 1648
+const yyLast = 2272
 
 var yyAct = [...]int16{
-	15, 330, 334, 310, 186, 321, 256, 290, 173, 297,
-	13, 203, 273, 122, 98, 113, 17, 9, 14, 196,
-	271, 107, 64, 65, 66, 67, 68, 69, 70, 114,
-	223, 103, 104, 105, 8, 37, 222, 220, 108, 111,
-	219, 217, 44, 42, 43, 45, 59, 60, 61, 63,
-	62, 64, 65, 66, 67, 68, 69, 70, 146, 129,
-	130, 131, 132, 133, 134, 135, 136, 137, 138, 139,
-	140, 141, 125, 121, 145, 143, 142, 147, 148, 149,
-	150, 151, 152, 187, 188, 159, 160, 41, 47, 46,
-	172, 174, 176, 177, 274, 99, 101, 28, 101, 153,
-	174, 168, 7, 298, 11, 69, 70, 221, 184, 144,
-	230, 218, 89, 56, 66, 67, 68, 69, 70, 170,
-	200, 171, 192, 194, 189, 193, 341, 191, 195, 174,
-	245, 161, 164, 165, 163, 8, 188, 216, 118, 162,
-	354, 39, 201, 214, 100, 53, 100, 244, 235, 269,
-	215, 311, 118, 127, 60, 61, 63, 62, 64, 65,
-	66, 67, 68, 69, 70, 254, 253, 228, 235, 242,
-	302, 231, 232, 224, 226, 227, 225, 48, 268, 167,
-	118, 61, 63, 62, 64, 65, 66, 67, 68, 69,
-	70, 157, 235, 248, 235, 234, 255, 250, 185, 124,
-	243, 246, 247, 229, 202, 258, 156, 158, 155, 154,
-	249, 209, 211, 212, 208, 210, 190, 213, 116, 182,
-	240, 54, 207, 239, 259, 260, 238, 6, 316, 299,
-	128, 120, 126, 119, 276, 270, 277, 278, 102, 280,
-	281, 282, 283, 53, 97, 96, 95, 94, 93, 92,
-	91, 90, 87, 51, 293, 289, 53, 8, 279, 284,
-	285, 286, 181, 180, 179, 178, 49, 265, 263, 295,
-	294, 267, 266, 264, 262, 261, 300, 325, 34, 197,
-	287, 352, 353, 349, 288, 296, 50, 198, 12, 10,
-	4, 331, 312, 322, 314, 251, 252, 291, 307, 337,
-	323, 292, 311, 313, 318, 319, 315, 257, 317, 204,
-	241, 124, 109, 5, 320, 205, 118, 88, 326, 117,
-	206, 333, 199, 112, 335, 336, 110, 332, 329, 123,
-	166, 348, 342, 339, 3, 2, 340, 115, 174, 33,
-	109, 35, 335, 106, 347, 346, 345, 169, 351, 350,
-	52, 36, 1, 0, 29, 0, 0, 0, 0, 0,
-	40, 0, 0, 0, 20, 21, 26, 25, 22, 27,
-	23, 24, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 18, 8, 37, 308, 309, 38, 0, 39,
-	0, 44, 42, 43, 45, 0, 0, 0, 32, 31,
-	0, 19, 73, 75, 71, 72, 57, 86, 0, 0,
-	58, 59, 60, 61, 63, 62, 64, 65, 66, 67,
-	68, 69, 70, 0, 0, 0, 0, 0, 30, 175,
-	0, 0, 0, 29, 0, 0, 41, 47, 46, 40,
-	0, 0, 0, 20, 21, 26, 25, 22, 27, 23,
-	24, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 18, 8, 37, 0, 183, 38, 0, 39, 0,
-	44, 42, 43, 45, 0, 0, 0, 32, 31, 0,
-	19, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	15, 417, 255, 373, 395, 213, 207, 383, 316, 191,
+	382, 341, 291, 134, 226, 118, 13, 107, 124, 14,
+	9, 17, 132, 66, 68, 67, 69, 70, 71, 72,
+	73, 74, 75, 76, 112, 113, 114, 219, 209, 41,
+	40, 117, 310, 122, 308, 125, 248, 48, 46, 47,
+	49, 64, 65, 66, 68, 67, 69, 70, 71, 72,
+	73, 74, 75, 76, 145, 146, 147, 148, 149, 150,
+	151, 152, 153, 154, 155, 156, 157, 137, 247, 245,
+	244, 242, 163, 164, 165, 166, 167, 168, 169, 162,
+	160, 176, 177, 45, 51, 50, 190, 192, 194, 195,
+	159, 120, 220, 338, 110, 201, 202, 31, 76, 192,
+	170, 256, 7, 108, 11, 317, 110, 277, 205, 74,
+	75, 76, 269, 60, 350, 200, 208, 246, 161, 257,
+	243, 223, 95, 188, 210, 189, 215, 275, 274, 216,
+	214, 192, 69, 70, 71, 72, 73, 74, 75, 76,
+	239, 439, 129, 109, 224, 186, 237, 71, 72, 73,
+	74, 75, 76, 238, 424, 109, 129, 139, 65, 66,
+	68, 67, 69, 70, 71, 72, 73, 74, 75, 76,
+	209, 409, 174, 217, 253, 8, 256, 221, 218, 258,
+	259, 43, 262, 381, 185, 52, 129, 173, 175, 172,
+	171, 178, 181, 182, 180, 249, 251, 252, 250, 179,
+	374, 129, 262, 304, 289, 288, 283, 206, 396, 211,
+	285, 262, 272, 385, 273, 262, 261, 359, 293, 347,
+	303, 282, 136, 290, 276, 254, 284, 184, 225, 212,
+	127, 306, 203, 58, 403, 57, 267, 266, 294, 295,
+	265, 6, 400, 355, 138, 262, 318, 315, 240, 119,
+	403, 320, 140, 321, 322, 309, 324, 325, 326, 327,
+	328, 329, 330, 232, 234, 235, 231, 233, 333, 236,
+	57, 8, 57, 323, 230, 131, 130, 111, 106, 105,
+	340, 104, 331, 332, 334, 103, 337, 102, 101, 100,
+	99, 98, 97, 96, 93, 55, 8, 241, 199, 198,
+	197, 196, 158, 192, 349, 344, 53, 346, 300, 345,
+	357, 353, 351, 301, 354, 348, 298, 302, 286, 287,
+	297, 299, 296, 37, 133, 378, 427, 144, 374, 434,
+	435, 339, 54, 375, 12, 377, 336, 335, 307, 142,
+	143, 129, 370, 384, 10, 386, 4, 388, 379, 387,
+	376, 389, 390, 418, 391, 396, 392, 342, 432, 408,
+	393, 397, 352, 343, 374, 116, 422, 292, 128, 380,
+	227, 356, 268, 394, 401, 136, 119, 402, 407, 279,
+	280, 281, 228, 5, 94, 229, 399, 222, 384, 421,
+	123, 121, 135, 419, 384, 278, 416, 305, 420, 192,
+	423, 425, 62, 141, 183, 426, 404, 3, 428, 2,
+	429, 126, 119, 430, 36, 431, 38, 433, 115, 187,
+	56, 39, 436, 438, 437, 1, 32, 0, 0, 440,
+	0, 0, 44, 0, 442, 27, 371, 372, 0, 0,
+	20, 21, 26, 25, 22, 30, 23, 24, 0, 0,
+	0, 28, 29, 0, 0, 0, 0, 0, 18, 41,
+	40, 0, 0, 42, 0, 43, 0, 48, 46, 47,
+	49, 0, 0, 0, 35, 34, 0, 19, 79, 81,
+	77, 78, 61, 92, 0, 0, 63, 64, 65, 66,
+	68, 67, 69, 70, 71, 72, 73, 74, 75, 76,
+	0, 0, 32, 0, 33, 193, 0, 0, 44, 0,
+	0, 27, 0, 45, 51, 50, 20, 21, 26, 25,
+	22, 30, 23, 24, 0, 0, 0, 28, 29, 0,
+	0, 0, 0, 0, 18, 41, 40, 0, 204, 42,
+	0, 43, 0, 48, 46, 47, 49, 0, 0, 0,
+	35, 34, 0, 19, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 32, 0,
+	33, 193, 0, 0, 44, 0, 0, 27, 0, 45,
+	51, 50, 20, 21, 26, 25, 22, 30, 23, 24,
+	0, 0, 0, 28, 29, 0, 0, 0, 0, 0,
+	18, 41, 40, 0, 0, 42, 0, 43, 0, 48,
+	46, 47, 49, 0, 0, 0, 35, 34, 0, 19,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 30, 175, 0,
-	0, 0, 29, 0, 0, 41, 47, 46, 40, 0,
-	0, 0, 20, 21, 26, 25, 22, 27, 23, 24,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	18, 8, 37, 0, 0, 38, 0, 39, 0, 44,
-	42, 43, 45, 0, 0, 0, 32, 31, 0, 19,
-	343, 344, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 32, 0, 33, 193, 0, 0,
+	44, 0, 0, 27, 0, 45, 51, 50, 20, 21,
+	26, 25, 22, 30, 23, 24, 0, 0, 0, 28,
+	29, 0, 0, 0, 0, 0, 18, 41, 40, 313,
+	0, 42, 314, 43, 0, 48, 46, 47, 49, 0,
+	0, 0, 35, 34, 0, 19, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 30, 175, 0, 0,
-	0, 0, 0, 0, 41, 47, 46, 85, 84, 0,
-	74, 83, 82, 0, 0, 0, 0, 0, 0, 76,
-	77, 78, 79, 80, 81, 73, 75, 71, 72, 57,
-	86, 0, 0, 58, 59, 60, 61, 63, 62, 64,
-	65, 66, 67, 68, 69, 70, 29, 0, 0, 0,
-	0, 0, 40, 0, 0, 0, 20, 21, 26, 25,
-	22, 27, 23, 24, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 18, 8, 37, 0, 0, 38,
-	0, 39, 0, 44, 42, 43, 45, 0, 0, 0,
-	32, 31, 0, 19, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 109, 0, 0, 0, 0, 0, 0, 0, 0,
-	30, 16, 0, 0, 0, 29, 0, 0, 41, 47,
-	46, 40, 0, 0, 0, 20, 21, 26, 25, 22,
-	27, 23, 24, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 18, 8, 37, 0, 0, 38, 55,
-	39, 0, 44, 42, 43, 45, 0, 0, 0, 32,
-	31, 0, 19, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 8,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 30,
-	0, 85, 84, 0, 74, 83, 82, 41, 47, 46,
-	0, 0, 0, 76, 77, 78, 79, 80, 81, 73,
-	75, 71, 72, 57, 86, 0, 0, 58, 59, 60,
-	61, 63, 62, 64, 65, 66, 67, 68, 69, 70,
-	29, 0, 0, 0, 0, 0, 40, 0, 0, 0,
-	20, 21, 26, 25, 22, 27, 23, 24, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 18, 8,
-	37, 0, 0, 38, 0, 39, 0, 44, 42, 43,
-	45, 0, 0, 0, 32, 31, 0, 19, 0, 0,
+	0, 0, 33, 16, 312, 311, 0, 0, 0, 0,
+	0, 45, 51, 50, 91, 90, 0, 80, 89, 88,
+	0, 0, 0, 0, 0, 0, 82, 83, 84, 85,
+	86, 87, 79, 81, 77, 78, 61, 92, 0, 0,
+	63, 64, 65, 66, 68, 67, 69, 70, 71, 72,
+	73, 74, 75, 76, 119, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 32, 0,
+	0, 0, 0, 0, 44, 0, 0, 27, 0, 0,
+	0, 0, 20, 21, 26, 25, 22, 30, 23, 24,
+	0, 0, 0, 28, 29, 0, 0, 0, 0, 0,
+	18, 41, 40, 0, 0, 42, 0, 43, 0, 48,
+	46, 47, 49, 0, 0, 0, 35, 34, 0, 19,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 338, 0, 0, 0,
-	0, 0, 0, 0, 30, 85, 84, 0, 74, 83,
-	82, 0, 41, 47, 46, 0, 0, 76, 77, 78,
-	79, 80, 81, 73, 75, 71, 72, 57, 86, 0,
-	0, 58, 59, 60, 61, 63, 62, 64, 65, 66,
-	67, 68, 69, 70, 328, 0, 0, 0, 0, 0,
-	0, 0, 0, 85, 84, 0, 74, 83, 82, 0,
-	0, 0, 0, 0, 0, 76, 77, 78, 79, 80,
-	81, 73, 75, 71, 72, 57, 86, 0, 0, 58,
-	59, 60, 61, 63, 62, 64, 65, 66, 67, 68,
-	69, 70, 327, 0, 0, 0, 0, 0, 0, 0,
-	0, 85, 84, 0, 74, 83, 82, 0, 0, 0,
-	0, 0, 0, 76, 77, 78, 79, 80, 81, 73,
-	75, 71, 72, 57, 86, 0, 0, 58, 59, 60,
-	61, 63, 62, 64, 65, 66, 67, 68, 69, 70,
-	306, 0, 0, 0, 0, 0, 0, 0, 0, 85,
-	84, 0, 74, 83, 82, 0, 0, 0, 0, 0,
-	0, 76, 77, 78, 79, 80, 81, 73, 75, 71,
-	72, 57, 86, 0, 0, 58, 59, 60, 61, 63,
-	62, 64, 65, 66, 67, 68, 69, 70, 305, 0,
-	0, 0, 0, 0, 0, 0, 0, 85, 84, 0,
-	74, 83, 82, 0, 0, 0, 0, 0, 0, 76,
-	77, 78, 79, 80, 81, 73, 75, 71, 72, 57,
-	86, 0, 0, 58, 59, 60, 61, 63, 62, 64,
-	65, 66, 67, 68, 69, 70, 304, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 85, 84, 0, 74,
-	83, 82, 0, 0, 0, 0, 0, 0, 76, 77,
-	78, 79, 80, 81, 73, 75, 71, 72, 57, 86,
-	0, 0, 58, 59, 60, 61, 63, 62, 64, 65,
-	66, 67, 68, 69, 70, 303, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 85, 84, 0, 74, 83,
-	82, 0, 0, 0, 0, 0, 0, 76, 77, 78,
-	79, 80, 81, 73, 75, 71, 72, 57, 86, 0,
-	0, 58, 59, 60, 61, 63, 62, 64, 65, 66,
-	67, 68, 69, 70, 301, 0, 0, 0, 0, 0,
-	0, 0, 0, 85, 84, 0, 74, 83, 82, 0,
-	0, 0, 0, 0, 0, 76, 77, 78, 79, 80,
-	81, 73, 75, 71, 72, 57, 86, 0, 0, 58,
-	59, 60, 61, 63, 62, 64, 65, 66, 67, 68,
-	69, 70, 85, 84, 0, 74, 83, 82, 0, 0,
-	275, 0, 0, 0, 76, 77, 78, 79, 80, 81,
-	73, 75, 71, 72, 57, 86, 0, 0, 58, 59,
-	60, 61, 63, 62, 64, 65, 66, 67, 68, 69,
-	70, 272, 0, 0, 0, 0, 0, 237, 0, 0,
-	85, 84, 0, 74, 83, 82, 0, 0, 0, 0,
-	0, 0, 76, 77, 78, 79, 80, 81, 73, 75,
-	71, 72, 57, 86, 0, 0, 58, 59, 60, 61,
-	63, 62, 64, 65, 66, 67, 68, 69, 70, 85,
-	84, 0, 74, 83, 82, 0, 0, 0, 0, 0,
-	0, 76, 77, 78, 79, 80, 81, 73, 75, 71,
-	72, 57, 86, 0, 0, 58, 59, 60, 61, 63,
-	62, 64, 65, 66, 67, 68, 69, 70, 236, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 85, 84,
-	0, 74, 83, 82, 0, 0, 0, 0, 0, 0,
-	76, 77, 78, 79, 80, 81, 73, 75, 71, 72,
-	57, 86, 0, 0, 58, 59, 60, 61, 63, 62,
-	64, 65, 66, 67, 68, 69, 70, 85, 84, 0,
-	74, 83, 82, 0, 0, 233, 0, 0, 0, 76,
-	77, 78, 79, 80, 81, 73, 75, 71, 72, 57,
-	86, 0, 0, 58, 59, 60, 61, 63, 62, 64,
-	65, 66, 67, 68, 69, 70, 85, 84, 0, 74,
-	83, 82, 0, 0, 0, 0, 0, 0, 324, 77,
-	78, 79, 80, 81, 73, 75, 71, 72, 57, 86,
-	0, 0, 58, 59, 60, 61, 63, 62, 64, 65,
-	66, 67, 68, 69, 70, 85, 84, 0, 74, 83,
-	82, 0, 0, 0, 0, 0, 0, 76, 77, 78,
-	79, 80, 81, 73, 75, 71, 72, 57, 86, 0,
-	0, 58, 59, 60, 61, 63, 62, 64, 65, 66,
-	67, 68, 69, 70, 84, 0, 74, 83, 82, 0,
-	0, 0, 0, 0, 0, 76, 77, 78, 79, 80,
-	81, 73, 75, 71, 72, 57, 86, 0, 0, 58,
-	59, 60, 61, 63, 62, 64, 65, 66, 67, 68,
-	69, 70, 74, 83, 82, 0, 0, 0, 0, 0,
-	0, 76, 77, 78, 79, 80, 81, 73, 75, 71,
-	72, 57, 86, 0, 0, 58, 59, 60, 61, 63,
-	62, 64, 65, 66, 67, 68, 69, 70,
+	0, 0, 0, 32, 0, 0, 0, 0, 0, 44,
+	0, 0, 27, 0, 0, 0, 33, 20, 21, 26,
+	25, 22, 30, 23, 24, 45, 51, 50, 28, 29,
+	0, 0, 0, 0, 0, 18, 41, 40, 0, 0,
+	42, 0, 43, 0, 48, 46, 47, 49, 0, 0,
+	0, 35, 34, 0, 19, 405, 406, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 33, 0, 0, 0, 0, 0, 0, 0, 0,
+	45, 51, 50, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 365, 364, 0, 0,
+	0, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 363, 362, 0, 0,
+	0, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 59, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 8, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 91, 90,
+	0, 80, 89, 88, 0, 0, 0, 0, 0, 0,
+	82, 83, 84, 85, 86, 87, 79, 81, 77, 78,
+	61, 92, 0, 0, 63, 64, 65, 66, 68, 67,
+	69, 70, 71, 72, 73, 74, 75, 76, 441, 0,
+	0, 0, 0, 0, 0, 0, 0, 91, 90, 0,
+	80, 89, 88, 0, 0, 0, 0, 0, 0, 82,
+	83, 84, 85, 86, 87, 79, 81, 77, 78, 61,
+	92, 0, 0, 63, 64, 65, 66, 68, 67, 69,
+	70, 71, 72, 73, 74, 75, 76, 415, 0, 0,
+	0, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 414, 0, 0, 0,
+	0, 0, 0, 0, 0, 91, 90, 0, 80, 89,
+	88, 0, 0, 0, 0, 0, 0, 82, 83, 84,
+	85, 86, 87, 79, 81, 77, 78, 61, 92, 0,
+	0, 63, 64, 65, 66, 68, 67, 69, 70, 71,
+	72, 73, 74, 75, 76, 413, 0, 0, 0, 0,
+	0, 0, 0, 0, 91, 90, 0, 80, 89, 88,
+	0, 0, 0, 0, 0, 0, 82, 83, 84, 85,
+	86, 87, 79, 81, 77, 78, 61, 92, 0, 0,
+	63, 64, 65, 66, 68, 67, 69, 70, 71, 72,
+	73, 74, 75, 76, 412, 0, 0, 0, 0, 0,
+	0, 0, 0, 91, 90, 0, 80, 89, 88, 0,
+	0, 0, 0, 0, 0, 82, 83, 84, 85, 86,
+	87, 79, 81, 77, 78, 61, 92, 0, 0, 63,
+	64, 65, 66, 68, 67, 69, 70, 71, 72, 73,
+	74, 75, 76, 411, 0, 0, 0, 0, 0, 0,
+	0, 0, 91, 90, 0, 80, 89, 88, 0, 0,
+	0, 0, 0, 0, 82, 83, 84, 85, 86, 87,
+	79, 81, 77, 78, 61, 92, 0, 0, 63, 64,
+	65, 66, 68, 67, 69, 70, 71, 72, 73, 74,
+	75, 76, 410, 369, 0, 0, 0, 0, 0, 0,
+	0, 91, 90, 0, 80, 89, 88, 0, 0, 0,
+	0, 0, 0, 82, 83, 84, 85, 86, 87, 79,
+	81, 77, 78, 61, 92, 0, 0, 63, 64, 65,
+	66, 68, 67, 69, 70, 71, 72, 73, 74, 75,
+	76, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	91, 90, 0, 80, 89, 88, 0, 0, 0, 0,
+	0, 0, 82, 83, 84, 85, 86, 87, 79, 81,
+	77, 78, 61, 92, 0, 0, 63, 64, 65, 66,
+	68, 67, 69, 70, 71, 72, 73, 74, 75, 76,
+	368, 0, 0, 0, 0, 0, 0, 0, 0, 91,
+	90, 0, 80, 89, 88, 0, 0, 0, 0, 0,
+	0, 82, 83, 84, 85, 86, 87, 79, 81, 77,
+	78, 61, 92, 0, 0, 63, 64, 65, 66, 68,
+	67, 69, 70, 71, 72, 73, 74, 75, 76, 367,
+	0, 0, 0, 0, 0, 0, 0, 0, 91, 90,
+	0, 80, 89, 88, 0, 0, 0, 0, 0, 0,
+	82, 83, 84, 85, 86, 87, 79, 81, 77, 78,
+	61, 92, 0, 0, 63, 64, 65, 66, 68, 67,
+	69, 70, 71, 72, 73, 74, 75, 76, 366, 0,
+	0, 0, 0, 0, 0, 0, 0, 91, 90, 0,
+	80, 89, 88, 0, 0, 0, 0, 0, 0, 82,
+	83, 84, 85, 86, 87, 79, 81, 77, 78, 61,
+	92, 0, 0, 63, 64, 65, 66, 68, 67, 69,
+	70, 71, 72, 73, 74, 75, 76, 361, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 91, 90, 0,
+	80, 89, 88, 0, 0, 0, 0, 0, 0, 82,
+	83, 84, 85, 86, 87, 79, 81, 77, 78, 61,
+	92, 0, 0, 63, 64, 65, 66, 68, 67, 69,
+	70, 71, 72, 73, 74, 75, 76, 360, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 91, 90, 0,
+	80, 89, 88, 0, 0, 0, 0, 0, 0, 82,
+	83, 84, 85, 86, 87, 79, 81, 77, 78, 61,
+	92, 0, 0, 63, 64, 65, 66, 68, 67, 69,
+	70, 71, 72, 73, 74, 75, 76, 358, 0, 0,
+	0, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 91, 90, 0, 80,
+	89, 88, 0, 0, 319, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 271, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 270, 0, 0, 0,
+	264, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 263, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 91, 90, 0, 80,
+	89, 88, 0, 0, 260, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 398, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 91, 90, 0, 80,
+	89, 88, 0, 0, 0, 0, 0, 0, 82, 83,
+	84, 85, 86, 87, 79, 81, 77, 78, 61, 92,
+	0, 0, 63, 64, 65, 66, 68, 67, 69, 70,
+	71, 72, 73, 74, 75, 76, 90, 0, 80, 89,
+	88, 0, 0, 0, 0, 0, 0, 82, 83, 84,
+	85, 86, 87, 79, 81, 77, 78, 61, 92, 0,
+	0, 63, 64, 65, 66, 68, 67, 69, 70, 71,
+	72, 73, 74, 75, 76, 80, 89, 88, 0, 0,
+	0, 0, 0, 0, 82, 83, 84, 85, 86, 87,
+	79, 81, 77, 78, 61, 92, 0, 0, 63, 64,
+	65, 66, 68, 67, 69, 70, 71, 72, 73, 74,
+	75, 76,
 }
 
 var yyPact = [...]int16{
-	274, -1000, 306, 175, 207, 271, 207, 268, -1000, 615,
-	218, 266, 202, 204, -1000, 729, -1000, -1000, 201, 43,
-	200, 199, 198, 197, 196, 195, 194, 193, 44, 187,
-	809, 809, 809, -1000, -1000, -1000, -1000, 694, 809, -76,
-	85, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, 182,
-	180, 305, 303, 615, 207, 207, -1000, 179, 809, 809,
-	809, 809, 809, 809, 809, 809, 809, 809, 809, 809,
-	809, -29, -30, 36, -31, -47, 809, 809, 809, 809,
-	809, 809, -16, 126, 809, 809, 73, 83, 50, 809,
-	491, 809, 809, 215, 214, 213, 212, 166, -1000, 412,
-	207, 33, 305, -1000, 1547, 1547, 163, -1000, 1473, 271,
-	70, 1473, 71, -1000, -87, 259, -1000, -1000, 42, 809,
-	305, 151, 300, 170, 615, -1000, -1000, -1000, 333, -43,
-	64, 90, -72, -72, -72, 18, 18, 6, 6, 6,
-	-1000, -1000, -1000, -1000, -64, -1000, -1000, 322, 322, 322,
-	322, 322, 322, 48, -65, -68, 34, -69, -75, 1547,
-	1511, -1000, 115, -1000, -1000, -1000, 809, 150, -1000, 38,
-	809, 809, 1395, 142, 1473, -1000, 1356, 1307, 174, 171,
-	168, 302, -1000, -1000, 116, 42, 92, 75, -1000, 148,
-	-1000, 615, 809, -1000, -76, -1000, 809, 207, 207, 113,
-	1473, 143, -1000, 297, 809, 615, 615, -1000, 234, -1000,
-	233, 227, 226, 230, -1000, 125, 96, -1000, -16, -1000,
-	-1000, -85, -1000, -1000, -1000, -1000, -1000, -1000, 1268, 7,
-	-1000, 1220, 1473, 809, -1000, 809, 809, 208, 809, 809,
-	809, 809, -1000, -1000, 42, 42, -1000, 191, 1473, -1000,
-	1473, 252, 264, -1000, 809, -1000, 284, 289, 1473, -1000,
-	206, -1000, -1000, -1000, 229, -1000, 228, -1000, -1000, -1000,
-	-1000, -1000, 7, 17, 178, 809, 1473, 1473, 1181, 117,
-	1133, 1084, 1035, 987, -1000, -1000, 300, 207, 207, 1473,
-	291, 809, 615, 809, -1000, -1000, 17, -1000, 177, 299,
-	1473, -1000, -1000, 809, 809, -1000, -1000, 297, -1000, -1000,
-	279, 288, 1473, 93, 1434, -1000, 248, 809, 939, 891,
-	284, 276, -19, 809, 809, 287, 843, -1000, -1000, 291,
-	-1000, -19, -1000, 74, -1000, 535, 322, 491, -1000, 279,
-	-1000, 809, 261, -1000, -1000, 140, 276, -1000, -1000, 258,
-	87, -1000, -1000, -1000, -1000,
+	338, -1000, 384, 193, 225, 334, 225, 322, -1000, 641,
+	262, 320, 248, 224, -1000, 1050, -1000, -1000, 247, 57,
+	246, 245, 244, 243, 242, 241, 240, 238, 234, 232,
+	231, 56, 230, 850, 850, 850, -1000, -1000, -1000, -1000,
+	785, -11, 850, -67, 129, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, 229, 228, 377, 375, 641, 225, 225,
+	-1000, 205, 316, 850, 850, 850, 850, 850, 850, 850,
+	850, 850, 850, 850, 850, 850, 256, -12, -22, 49,
+	-23, -30, 850, 850, 850, 850, 850, 850, -17, 111,
+	850, 850, 137, 135, 58, 850, 565, 850, 850, 255,
+	254, 253, 252, -17, 850, 850, 183, -1000, 489, 225,
+	70, 250, -1000, 2164, 2164, 180, -1000, 2088, -1000, 334,
+	-1000, 78, 2088, 125, -1000, -76, 80, -1000, -1000, 44,
+	850, 377, 179, -1000, 369, 226, 641, -1000, -1000, -1000,
+	413, 201, -1000, -1000, -1000, -44, 72, -74, 42, 42,
+	42, 55, 55, 14, 14, 14, 1, 1, 251, -1000,
+	-1000, -31, -1000, -1000, 402, 402, 402, 402, 402, 402,
+	61, -32, -33, 48, -34, -66, 2164, 2127, -1000, 141,
+	-1000, -1000, -1000, 850, 176, 18, -1000, 51, 850, 850,
+	2008, 167, 2088, -1000, 1968, 1918, 192, 189, 188, 372,
+	32, 1878, 1828, -1000, -1000, 163, 44, 77, 76, -1000,
+	175, 27, -1000, 383, 641, 850, -1000, -67, -1000, 850,
+	225, 225, 156, 2088, 174, -1000, 365, 850, 641, 641,
+	-1000, 285, -1000, 283, 279, 271, 280, -1000, 171, 154,
+	850, -68, -1000, -17, -1000, -1000, -70, -1000, -1000, -1000,
+	-1000, -1000, -1000, 686, 18, 23, 199, -1000, 1778, 2088,
+	850, -1000, 850, 850, 227, 850, 850, 850, 850, 850,
+	850, 850, -1000, -1000, 44, 44, -1000, 850, 377, 326,
+	-1000, -1000, 222, 2088, -1000, 2088, -4, 319, -1000, 850,
+	-1000, 352, 359, 2088, -1000, 261, -1000, -1000, -1000, 272,
+	-1000, 270, -1000, -1000, -1000, 170, 2088, 56, -1000, -1000,
+	-1000, 93, 565, 358, -72, 23, -1000, 196, 370, 850,
+	2088, 2088, 1738, 168, 1689, 1639, 998, 948, 1589, 1540,
+	1491, -1000, -1000, 1442, -1000, -1000, -1000, 369, 225, 225,
+	2088, 361, 850, 641, 850, -1000, -1000, -1000, 300, 23,
+	367, 134, 850, 164, -1000, 325, 850, 2088, -1000, -1000,
+	850, 850, -1000, 850, -1000, 850, -1000, -1000, -1000, 850,
+	365, -1000, -1000, 349, 357, 2088, 187, 2048, 44, -1000,
+	195, 18, 202, -1000, 898, 18, 355, 122, 1393, 1344,
+	1295, 1246, 1197, 1148, 352, 346, -72, 850, 850, -1000,
+	363, 23, 105, 850, 312, -1000, -1000, -1000, 565, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, 361, -1000, -72, -1000,
+	186, 402, 354, -1000, 18, -1000, -1000, 314, 197, 349,
+	383, -1000, 850, -1000, -1000, -1000, 92, 346, 1099, -1000,
+	-1000, 18, -1000,
 }
 
 var yyPgo = [...]int16{
-	0, 352, 0, 351, 16, 177, 350, 11, 7, 347,
-	343, 12, 341, 278, 339, 337, 335, 334, 14, 332,
-	331, 330, 97, 4, 21, 6, 10, 18, 13, 329,
-	8, 326, 323, 15, 322, 17, 2, 3, 321, 320,
-	5, 1, 317, 9, 315,
+	0, 435, 334, 0, 431, 21, 195, 430, 14, 11,
+	429, 428, 2, 426, 333, 424, 421, 419, 417, 17,
+	416, 415, 414, 413, 107, 6, 412, 407, 22, 15,
+	405, 5, 12, 16, 19, 13, 402, 9, 401, 400,
+	18, 397, 20, 7, 3, 10, 395, 4, 1, 394,
+	8, 392,
 }
 
 var yyR1 = [...]int8{
-	0, 1, 24, 6, 6, 16, 16, 17, 17, 27,
-	27, 27, 27, 5, 3, 3, 3, 3, 3, 3,
-	3, 3, 4, 4, 10, 10, 21, 21, 35, 35,
-	35, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 26, 26, 34, 34, 30, 30,
-	30, 31, 31, 31, 32, 32, 32, 33, 43, 43,
-	39, 39, 39, 39, 39, 39, 39, 44, 44, 28,
-	28, 29, 29, 29, 23, 18, 18, 18, 18, 22,
-	9, 9, 42, 42, 11, 11, 7, 7, 8, 8,
-	25, 25, 20, 20, 20, 19, 19, 19, 36, 38,
-	38, 37, 37, 40, 40, 41, 41, 12, 12, 12,
-	15, 15, 13, 14,
+	0, 1, 2, 28, 31, 31, 30, 30, 30, 30,
+	30, 29, 7, 7, 17, 17, 18, 18, 34, 34,
+	34, 34, 6, 4, 4, 4, 4, 4, 4, 4,
+	4, 4, 5, 5, 11, 11, 22, 22, 42, 42,
+	42, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 33, 33, 41,
+	41, 37, 37, 37, 38, 38, 38, 39, 39, 39,
+	40, 50, 50, 50, 46, 46, 46, 46, 46, 46,
+	46, 51, 51, 35, 35, 36, 36, 36, 25, 19,
+	19, 19, 19, 24, 26, 26, 26, 26, 26, 26,
+	23, 23, 23, 27, 27, 10, 10, 49, 49, 12,
+	12, 8, 8, 9, 9, 32, 32, 21, 21, 21,
+	20, 20, 20, 43, 45, 45, 44, 44, 47, 47,
+	48, 48, 13, 13, 13, 16, 16, 14, 15,
 }
 
 var yyR2 = [...]int8{
-	0, 12, 10, 2, 0, 1, 0, 6, 7, 3,
-	2, 1, 1, 2, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 3, 1, 1, 1, 0, 5, 1,
-	0, 1, 7, 6, 4, 4, 6, 6, 8, 8,
-	6, 6, 3, 3, 4, 5, 5, 4, 3, 3,
+	0, 13, 2, 1, 0, 3, 2, 1, 2, 1,
+	1, 10, 2, 0, 1, 0, 6, 7, 3, 2,
+	1, 1, 2, 1, 1, 1, 1, 1, 1, 1,
+	2, 1, 1, 3, 1, 1, 1, 0, 5, 1,
+	0, 1, 7, 9, 13, 10, 8, 6, 5, 4,
+	4, 6, 6, 8, 8, 6, 8, 6, 8, 6,
+	6, 6, 3, 3, 4, 5, 5, 4, 8, 6,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 2, 3, 3, 4, 3, 3, 3, 3, 3,
-	3, 3, 3, 5, 4, 4, 5, 4, 4, 2,
-	2, 3, 3, 3, 4, 3, 4, 3, 4, 3,
-	4, 1, 1, 1, 1, 3, 1, 3, 1, 1,
-	3, 1, 3, 0, 1, 3, 0, 3, 7, 0,
-	1, 2, 2, 3, 2, 3, 2, 1, 2, 1,
-	0, 2, 3, 7, 1, 0, 3, 4, 4, 1,
-	0, 2, 4, 5, 0, 5, 0, 2, 0, 2,
-	0, 3, 0, 2, 2, 0, 1, 1, 3, 3,
-	1, 0, 3, 0, 2, 0, 2, 4, 6, 6,
-	1, 1, 3, 3,
+	3, 3, 3, 5, 2, 3, 3, 4, 3, 3,
+	3, 3, 3, 3, 3, 3, 5, 4, 4, 5,
+	4, 4, 2, 2, 3, 3, 3, 4, 3, 4,
+	3, 4, 3, 4, 1, 1, 1, 1, 3, 1,
+	3, 1, 1, 3, 1, 3, 0, 1, 3, 0,
+	3, 7, 4, 0, 1, 2, 2, 3, 2, 3,
+	2, 1, 2, 1, 0, 2, 3, 7, 1, 0,
+	3, 4, 4, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 4, 0, 2, 4, 5, 0,
+	5, 0, 2, 0, 2, 0, 3, 0, 2, 2,
+	0, 1, 1, 3, 3, 1, 0, 3, 0, 2,
+	0, 2, 4, 6, 6, 1, 1, 3, 3,
 }
 
 var yyChk = [...]int16{
-	-1000, -1, -16, -17, 16, 7, 52, -22, 50, -35,
-	18, -22, 20, -26, -27, -2, 96, -4, 49, 68,
-	31, 32, 35, 37, 38, 34, 33, 36, -22, 21,
-	95, 66, 65, -14, -13, -12, -3, 51, 54, 56,
-	27, 103, 59, 60, 58, 61, 105, 104, -5, 48,
-	20, 51, -6, 52, 17, 20, -22, 84, 88, 89,
-	90, 91, 93, 92, 94, 95, 96, 97, 98, 99,
-	100, 82, 83, 80, 65, 81, 74, 75, 76, 77,
-	78, 79, 67, 66, 63, 62, 85, 51, -42, 69,
-	51, 51, 51, 51, 51, 51, 51, 51, -18, 51,
-	102, 54, 51, -2, -2, -2, -10, -24, -2, 7,
-	-31, -2, -32, -33, 105, -15, -5, -13, -22, 51,
-	51, -24, -28, -29, 8, -27, -5, -22, 51, -2,
-	-2, -2, -2, -2, -2, -2, -2, -2, -2, -2,
-	-2, -2, 105, 105, 73, 105, 105, -2, -2, -2,
-	-2, -2, -2, -4, 83, 82, 80, 65, 81, -2,
-	-2, 58, 66, 61, 59, 60, -21, 96, 18, -9,
-	69, 71, -2, -30, -2, 96, -2, -2, 50, 50,
-	50, 50, 53, 53, -30, -22, -23, 50, 103, -24,
-	53, -35, 52, 55, 52, 57, 106, 20, 28, -34,
-	-2, -24, 53, -7, 9, -44, -39, 52, 44, 41,
-	45, 42, 43, 47, -27, -24, -30, 105, 63, 105,
-	105, 73, 105, 105, 58, 61, 59, 60, -2, 53,
-	72, -2, -2, 70, 53, 52, 52, 20, 52, 52,
-	52, 8, 53, -18, 55, 55, 53, -26, -2, -33,
-	-2, -22, -22, 53, 52, 53, -25, 10, -2, -27,
-	-27, 41, 41, 41, 46, 41, 46, 41, 53, 53,
-	-4, 105, 53, -11, 87, 70, -2, -2, -2, 50,
-	-2, -2, -2, -2, -18, -18, -28, 28, 20, -2,
-	-8, 13, 12, 48, 41, 41, -11, -43, 86, 51,
-	-2, 53, 53, 52, 52, 53, 53, -7, -22, -22,
-	-37, 11, -2, -26, -2, -43, 51, 9, -2, -2,
-	-25, -40, 14, 12, 74, 29, -2, 53, 53, -8,
-	-41, 15, -23, -38, -36, -2, -2, 12, 53, -37,
-	-23, 52, -19, 25, 26, -30, -40, -36, -20, 22,
-	-37, -41, 23, 24, 53,
+	-1000, -1, -17, -18, 18, 9, 58, -24, 56, -42,
+	20, -24, 22, -33, -34, -3, 102, -5, 55, 74,
+	37, 38, 41, 43, 44, 40, 39, 32, 48, 49,
+	42, -24, 23, 101, 72, 71, -15, -14, -13, -4,
+	57, 56, 60, 62, 29, 110, 65, 66, 64, 67,
+	112, 111, -6, 54, 22, 57, -7, 58, 19, 22,
+	-24, 90, -26, 94, 95, 96, 97, 99, 98, 100,
+	101, 102, 103, 104, 105, 106, 107, 88, 89, 86,
+	71, 87, 80, 81, 82, 83, 84, 85, 73, 72,
+	69, 68, 91, 57, -49, 75, 57, 57, 57, 57,
+	57, 57, 57, 57, 57, 57, 57, -19, 57, 109,
+	60, 57, -3, -3, -3, -11, -2, -3, -29, 9,
+	112, -38, -3, -39, -40, 112, -16, -6, -14, -24,
+	57, 57, -28, -2, -35, -36, 10, -34, -6, -24,
+	57, -23, 33, 34, 21, -3, -3, -3, -3, -3,
+	-3, -3, -3, -3, -3, -3, -3, -3, 56, 112,
+	112, 79, 112, 112, -3, -3, -3, -3, -3, -3,
+	-5, 89, 88, 86, 71, 87, -3, -3, 64, 72,
+	67, 65, 66, -22, 102, 59, 20, -10, 75, 77,
+	-3, -37, -3, 102, -3, -3, 56, 56, 56, 56,
+	-5, -3, -3, 59, 59, -37, -24, -25, 56, 110,
+	-28, -24, 59, -31, -42, 58, 61, 58, 63, 113,
+	22, 107, -41, -3, -28, 59, -8, 11, -51, -46,
+	58, 50, 47, 51, 48, 49, 53, -34, -28, -37,
+	57, 56, 112, 69, 112, 112, 79, 112, 112, 64,
+	67, 65, 66, -3, 59, -12, 93, 78, -3, -3,
+	76, 59, 58, 58, 22, 58, 58, 58, 10, 90,
+	58, 58, 59, -19, 61, 61, 59, 90, -30, 6,
+	7, 8, -33, -3, -40, -3, -24, -24, 59, 58,
+	59, -32, 12, -3, -34, -34, 47, 47, 47, 52,
+	47, 52, 47, 59, 59, -27, -3, -24, 112, -5,
+	112, 59, 58, 13, 16, -12, -50, 92, 57, 76,
+	-3, -3, -3, 56, -3, -3, -3, -3, -3, -3,
+	-3, -19, -19, -3, -29, 21, 20, -35, 107, 22,
+	-3, -9, 15, 14, 54, 47, 47, 59, -19, -12,
+	31, -37, 14, -25, -50, 57, 11, -3, 59, 59,
+	58, 58, 59, 58, 59, 58, 59, 59, 59, 11,
+	-8, -24, -24, -44, 13, -3, -33, -3, 35, -50,
+	12, 59, -45, -43, -3, 59, 30, -44, -3, -3,
+	-3, -3, -3, -3, -32, -47, 16, 14, 80, -19,
+	57, -12, -47, 58, -20, 27, 28, -12, 14, 59,
+	59, 59, 59, 59, 59, 59, -9, -48, 17, -25,
+	-45, -3, 13, -50, 59, -43, -21, 24, -37, -44,
+	-31, -25, 14, -12, 25, 26, -44, -47, -3, 59,
+	-48, 59, -12,
 }
 
 var yyDef = [...]int16{
-	6, -2, 0, 5, 0, 30, 0, 0, 129, 0,
-	29, 0, 0, 4, 94, 11, 12, 31, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 125, 0,
-	0, 0, 0, 91, 92, 93, 22, 0, 103, 106,
-	0, 14, 15, 16, 17, 18, 19, 20, 21, 0,
-	0, 0, 120, 0, 0, 0, 10, 0, 0, 0,
+	15, -2, 0, 14, 0, 40, 0, 0, 153, 0,
+	39, 0, 0, 13, 117, 20, 21, 41, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 149, 0, 0, 0, 0, 114, 115, 116, 32,
+	0, 153, 126, 129, 0, 23, 24, 25, 26, 27,
+	28, 29, 31, 0, 0, 0, 144, 0, 0, 0,
+	19, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 27, 130, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 13, 0,
-	0, 0, 0, 61, 79, 80, 0, 24, 25, 30,
-	0, 101, 0, 104, 0, 0, 160, 161, 125, 0,
-	0, 0, 136, 119, 0, 95, 3, 9, 0, 48,
-	49, 50, 51, 52, 53, 54, 55, 56, 57, 58,
-	59, 60, 62, 63, 0, 65, 66, 67, 68, 69,
-	70, 71, 72, 0, 0, 0, 0, 0, 0, 81,
-	82, 83, 0, 85, 87, 89, 0, 0, 26, 0,
-	0, 0, 0, 0, 98, 99, 0, 0, 0, 0,
-	0, 0, 42, 43, 0, 125, 0, 0, 124, 0,
-	23, 0, 0, 163, 0, 162, 0, 0, 0, 0,
-	96, 0, 7, 140, 0, 0, 0, 117, 0, 110,
-	0, 0, 0, 0, 121, 0, 0, 64, 0, 74,
-	75, 0, 77, 78, 84, 86, 88, 90, 0, 134,
-	34, 0, 131, 0, 35, 0, 0, 0, 0, 0,
-	0, 0, 44, 126, 125, 125, 47, 120, 102, 105,
-	107, 157, 0, 28, 0, 8, 138, 0, 137, 122,
-	0, 118, 111, 112, 0, 114, 0, 116, 45, 46,
-	73, 76, 134, 109, 0, 0, 132, 100, 0, 0,
-	0, 0, 0, 0, 127, 128, 136, 0, 0, 97,
-	151, 0, 0, 0, 113, 115, 109, 33, 0, 0,
-	133, 36, 37, 0, 0, 40, 41, 140, 158, 159,
-	153, 0, 139, 141, 0, 32, 0, 0, 0, 0,
-	138, 155, 0, 0, 0, 0, 0, 38, 39, 151,
-	1, 0, 154, 152, 150, 145, -2, 0, 135, 153,
-	156, 0, 142, 146, 147, 151, 155, 149, 148, 0,
-	0, 2, 143, 144, 108,
+	0, 0, 154, 155, 156, 157, 158, 159, 0, 0,
+	0, 0, 0, 37, 165, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 22, 0, 0,
+	0, 0, 84, 102, 103, 0, 34, 35, 4, 40,
+	30, 0, 124, 0, 127, 0, 0, 195, 196, 149,
+	0, 0, 0, 3, 171, 143, 0, 118, 12, 18,
+	0, 0, 160, 161, 162, 70, 71, 72, 73, 74,
+	75, 76, 77, 78, 79, 80, 81, 82, 0, 85,
+	86, 0, 88, 89, 90, 91, 92, 93, 94, 95,
+	0, 0, 0, 0, 0, 0, 104, 105, 106, 0,
+	108, 110, 112, 0, 0, 169, 36, 0, 0, 0,
+	0, 0, 121, 122, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 62, 63, 0, 149, 0, 0, 148,
+	0, 0, 33, 2, 0, 0, 198, 0, 197, 0,
+	0, 0, 0, 119, 0, 16, 175, 0, 0, 0,
+	141, 0, 134, 0, 0, 0, 0, 145, 0, 0,
+	0, 0, 87, 0, 97, 98, 0, 100, 101, 107,
+	109, 111, 113, 0, 169, 133, 0, 49, 0, 166,
+	0, 50, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 64, 150, 149, 149, 67, 0, 0, 7,
+	9, 10, 144, 125, 128, 130, 192, 0, 38, 0,
+	17, 173, 0, 172, 146, 0, 142, 135, 136, 0,
+	138, 0, 140, 65, 66, 0, 163, 149, 83, 96,
+	99, 169, 0, 0, 0, 133, 48, 0, 0, 0,
+	167, 123, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 151, 152, 0, 5, 6, 8, 171, 0, 0,
+	120, 186, 0, 0, 0, 137, 139, 69, 22, 133,
+	0, 0, 0, 0, 47, 186, 0, 168, 51, 52,
+	0, 0, 55, 0, 57, 0, 59, 60, 61, 0,
+	175, 193, 194, 188, 0, 174, 176, 0, 149, 42,
+	0, 169, 188, 185, 180, 169, 0, 0, 0, 0,
+	0, 0, 0, 0, 173, 190, 0, 0, 154, 164,
+	0, 133, 0, 0, 177, 181, 182, 46, 0, 132,
+	170, 53, 54, 56, 58, 68, 186, 4, 0, 189,
+	187, -2, 0, 43, 169, 184, 183, 0, 186, 188,
+	1, 191, 0, 45, 178, 179, 0, 190, 0, 131,
+	11, 169, 44,
 }
 
 var yyTok1 = [...]int8{
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 64, 3, 3, 3, 98, 90, 3,
-	51, 53, 96, 94, 52, 95, 102, 97, 3, 3,
-	3, 3, 3, 3, 3, 3, 3, 3, 106, 3,
+	3, 3, 3, 70, 3, 3, 3, 104, 96, 3,
+	57, 59, 102, 100, 58, 101, 109, 103, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 113, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 54, 3, 55, 89, 3, 3, 3, 3, 3,
+	3, 60, 3, 61, 95, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 56, 88, 57, 65,
+	3, 3, 3, 62, 94, 63, 71,
 }
 
 var yyTok2 = [...]int8{
@@ -624,11 +738,12 @@ var yyTok2 = [...]int8{
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
 	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
 	32, 33, 34, 35, 36, 37, 38, 39, 40, 41,
-	42, 43, 44, 45, 46, 47, 48, 49, 50, 58,
-	59, 60, 61, 62, 63, 66, 67, 68, 69, 70,
-	71, 72, 73, 74, 75, 76, 77, 78, 79, 80,
-	81, 82, 83, 84, 85, 86, 87, 91, 92, 93,
-	99, 100, 101, 103, 104, 105,
+	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
+	52, 53, 54, 55, 56, 64, 65, 66, 67, 68,
+	69, 72, 73, 74, 75, 76, 77, 78, 79, 80,
+	81, 82, 83, 84, 85, 86, 87, 88, 89, 90,
+	91, 92, 93, 97, 98, 99, 105, 106, 107, 108,
+	110, 111, 112,
 }
 
 var yyTok3 = [...]int8{
@@ -973,229 +1088,364 @@ yydefault:
 	switch yynt {
 
 	case 1:
-		yyDollar = yyS[yypt-12 : yypt+1]
-//line partiql.y:120
+		yyDollar = yyS[yypt-13 : yypt+1]
+//line partiql.y:128
 		{
 			yylex.(*scanner).with = yyDollar[1].with
 			yylex.(*scanner).into = yyDollar[5].expr
+			if yyDollar[5].expr != nil && yyDollar[13].arms != nil {
+				yylex.Error("INTO cannot be combined with set operations")
+				return 1
+			}
 			distinct, distinctExpr := decodeDistinct(yyDollar[3].values)
-			yylex.(*scanner).result = &expr.Select{Distinct: distinct, DistinctExpr: distinctExpr, Columns: yyDollar[4].bindings, From: yyDollar[6].from, Where: yyDollar[7].expr, GroupBy: yyDollar[8].bindings, Having: yyDollar[9].expr, OrderBy: yyDollar[10].orders, Limit: yyDollar[11].exprint, Offset: yyDollar[12].exprint}
+			body, err := setquery(&expr.Select{Distinct: distinct, DistinctExpr: distinctExpr, Columns: yyDollar[4].bindings, From: yyDollar[6].from, Where: yyDollar[7].expr, GroupBy: yyDollar[8].bindings, Having: yyDollar[9].expr, OrderBy: yyDollar[10].orders, Limit: yyDollar[11].exprint, Offset: yyDollar[12].exprint}, yyDollar[13].arms)
+			if err != nil {
+				yylex.Error(err.Error())
+				return 1
+			}
+			yylex.(*scanner).result = body
 		}
 	case 2:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:148
+		{
+			body, err := setquery(yyDollar[1].sel, yyDollar[2].arms)
+			if err != nil {
+				yylex.Error(err.Error())
+				return 1
+			}
+			yyVAL.expr = body
+		}
+	case 3:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:158
+		{
+			yyVAL.sel = toSelect(yyDollar[1].expr)
+		}
+	case 4:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line partiql.y:161
+		{
+			yyVAL.arms = nil
+		}
+	case 5:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line partiql.y:162
+		{
+			yyVAL.arms = append(yyDollar[1].arms, setarm{op: yyDollar[2].setop, sel: yyDollar[3].sel})
+		}
+	case 6:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:165
+		{
+			yyVAL.setop = expr.UnionAll
+		}
+	case 7:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:166
+		{
+			yyVAL.setop = expr.UnionDistinct
+		}
+	case 8:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:167
+		{
+			yyVAL.setop = expr.UnionDistinct
+		}
+	case 9:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:168
+		{
+			yyVAL.setop = expr.Intersect
+		}
+	case 10:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:169
+		{
+			yyVAL.setop = expr.Except
+		}
+	case 11:
 		yyDollar = yyS[yypt-10 : yypt+1]
-//line partiql.y:129
+//line partiql.y:173
 		{
 			distinct, distinctExpr := decodeDistinct(yyDollar[2].values)
 			yyVAL.sel = &expr.Select{Distinct: distinct, DistinctExpr: distinctExpr, Columns: yyDollar[3].bindings, From: yyDollar[4].from, Where: yyDollar[5].expr, GroupBy: yyDollar[6].bindings, Having: yyDollar[7].expr, OrderBy: yyDollar[8].orders, Limit: yyDollar[9].exprint, Offset: yyDollar[10].exprint}
 		}
-	case 3:
+	case 12:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:135
+//line partiql.y:179
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 4:
+	case 13:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:135
+//line partiql.y:179
 		{
 			yyVAL.expr = nil
 		}
-	case 5:
+	case 14:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:138
+//line partiql.y:182
 		{
 			yyVAL.with = yyDollar[1].with
 		}
-	case 6:
+	case 15:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:138
+//line partiql.y:182
 		{
 			yyVAL.with = nil
 		}
-	case 7:
+	case 16:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:141
+//line partiql.y:185
 		{
 			yyVAL.with = []expr.CTE{{Table: yyDollar[2].str, As: yyDollar[5].sel}}
 		}
-	case 8:
+	case 17:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line partiql.y:142
+//line partiql.y:186
 		{
 			yyVAL.with = append(yyDollar[1].with, expr.CTE{Table: yyDollar[3].str, As: yyDollar[6].sel})
 		}
-	case 9:
+	case 18:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:148
+//line partiql.y:192
 		{
 			yyVAL.bind = expr.Bind(yyDollar[1].expr, yyDollar[3].str)
 		}
-	case 10:
+	case 19:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:149
+//line partiql.y:193
 		{
 			yyVAL.bind = expr.Bind(yyDollar[1].expr, yyDollar[2].str)
 		}
-	case 11:
+	case 20:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:150
+//line partiql.y:194
 		{
 			yyVAL.bind = expr.Bind(yyDollar[1].expr, "")
 		}
-	case 12:
+	case 21:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:151
+//line partiql.y:195
 		{
 			yyVAL.bind = expr.Bind(expr.Star{}, "")
 		}
-	case 13:
+	case 22:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:154
+//line partiql.y:198
 		{
 			yyVAL.expr = &expr.Path{First: yyDollar[1].str, Rest: yyDollar[2].pc}
 		}
-	case 14:
+	case 23:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:158
+//line partiql.y:202
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 15:
+	case 24:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:159
+//line partiql.y:203
 		{
 			yyVAL.expr = expr.Bool(true)
 		}
-	case 16:
+	case 25:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:160
+//line partiql.y:204
 		{
 			yyVAL.expr = expr.Bool(false)
 		}
-	case 17:
+	case 26:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:161
+//line partiql.y:205
 		{
 			yyVAL.expr = expr.Null{}
 		}
-	case 18:
+	case 27:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:162
+//line partiql.y:206
 		{
 			yyVAL.expr = expr.Missing{}
 		}
-	case 19:
+	case 28:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:163
+//line partiql.y:207
 		{
 			yyVAL.expr = expr.String(yyDollar[1].str)
 		}
-	case 20:
+	case 29:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:164
+//line partiql.y:208
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 21:
+	case 30:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:210
+		{
+			lit, ok := typedLiteral(yyDollar[1].str, yyDollar[2].str)
+			if !ok {
+				yylex.Error(__yyfmt__.Sprintf("bad %s literal %q", yyDollar[1].str, yyDollar[2].str))
+				return 1
+			}
+			yyVAL.expr = lit
+		}
+	case 31:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:165
+//line partiql.y:218
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 22:
+	case 32:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:177
+//line partiql.y:230
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 23:
+	case 33:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:178
+//line partiql.y:231
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 24:
+	case 34:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:181
+//line partiql.y:234
 		{
-			yyVAL.expr = yyDollar[1].sel
+			yyVAL.expr = yyDollar[1].expr
 		}
-	case 25:
+	case 35:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:182
+//line partiql.y:235
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 26:
+	case 36:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:185
+//line partiql.y:238
 		{
 			yyVAL.yesno = true
 		}
-	case 27:
+	case 37:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:185
+//line partiql.y:238
 		{
 			yyVAL.yesno = false
 		}
-	case 28:
+	case 38:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:188
+//line partiql.y:241
 		{
 			yyVAL.values = yyDollar[4].values
 		}
-	case 29:
+	case 39:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:189
+//line partiql.y:242
 		{
 			yyVAL.values = []expr.Node{}
 		}
-	case 30:
+	case 40:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:190
+//line partiql.y:243
 		{
 			yyVAL.values = nil
 		}
-	case 31:
+	case 41:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:196
+//line partiql.y:249
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 32:
+	case 42:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line partiql.y:200
+//line partiql.y:253
 		{
 			yyVAL.expr = toAggregate(expr.AggregateOp(yyDollar[1].integer), yyDollar[4].expr, yyDollar[3].yesno, yyDollar[6].expr, yyDollar[7].wind)
 		}
-	case 33:
+	case 43:
+		yyDollar = yyS[yypt-9 : yypt+1]
+//line partiql.y:257
+		{
+			agg := toAggregate(expr.AggregateOp(yyDollar[1].integer), yyDollar[4].expr, yyDollar[3].yesno, yyDollar[8].expr, yyDollar[9].wind)
+			agg.Args = yyDollar[6].values
+			yyVAL.expr = agg
+		}
+	case 44:
+		yyDollar = yyS[yypt-13 : yypt+1]
+//line partiql.y:263
+		{
+			op := expr.AggregateOp(yyDollar[1].integer)
+			if op != expr.OpApproxPercentile || yyDollar[3].yesno {
+				yylex.Error(__yyfmt__.Sprintf("%s does not accept WITHIN GROUP", op))
+				return 1
+			}
+			agg := toAggregate(op, yyDollar[11].expr, false, yyDollar[13].expr, nil)
+			agg.Args = []expr.Node{yyDollar[4].expr}
+			yyVAL.expr = agg
+		}
+	case 45:
+		yyDollar = yyS[yypt-10 : yypt+1]
+//line partiql.y:274
+		{
+			agg, err := toOrderedAggregate(expr.AggregateOp(yyDollar[1].integer), yyDollar[4].expr, yyDollar[3].yesno, yyDollar[7].orders, yyDollar[8].exprint, yyDollar[10].expr)
+			if err != nil {
+				yylex.Error(err.Error())
+				return 1
+			}
+			yyVAL.expr = agg
+		}
+	case 46:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line partiql.y:283
+		{
+			n := expr.Integer(yyDollar[6].integer)
+			agg, err := toOrderedAggregate(expr.AggregateOp(yyDollar[1].integer), yyDollar[4].expr, yyDollar[3].yesno, nil, &n, yyDollar[8].expr)
+			if err != nil {
+				yylex.Error(err.Error())
+				return 1
+			}
+			yyVAL.expr = agg
+		}
+	case 47:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:204
+//line partiql.y:293
 		{
 			distinct := false
 			yyVAL.expr = toAggregate(expr.AggregateOp(yyDollar[1].integer), expr.Star{}, distinct, yyDollar[5].expr, yyDollar[6].wind)
 		}
-	case 34:
+	case 48:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line partiql.y:298
+		{
+			op := expr.AggregateOp(yyDollar[1].integer)
+			if !op.NoArgs() {
+				yylex.Error(__yyfmt__.Sprintf("%s requires an argument", op))
+				return 1
+			}
+			distinct := false
+			yyVAL.expr = toAggregate(op, expr.Star{}, distinct, yyDollar[4].expr, yyDollar[5].wind)
+		}
+	case 49:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:209
+//line partiql.y:308
 		{
 			yyVAL.expr = &expr.Case{Limbs: yyDollar[2].limbs, Else: yyDollar[3].expr}
 		}
-	case 35:
+	case 50:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:213
+//line partiql.y:312
 		{
 			yyVAL.expr = expr.Coalesce(yyDollar[3].values)
 		}
-	case 36:
+	case 51:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:217
+//line partiql.y:316
 		{
 			yyVAL.expr = expr.NullIf(yyDollar[3].expr, yyDollar[5].expr)
 		}
-	case 37:
+	case 52:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:221
+//line partiql.y:320
 		{
 			nod, ok := buildCast(yyDollar[3].expr, yyDollar[5].str)
 			if !ok {
@@ -1204,39 +1454,49 @@ yydefault:
 			}
 			yyVAL.expr = nod
 		}
-	case 38:
+	case 53:
 		yyDollar = yyS[yypt-8 : yypt+1]
-//line partiql.y:230
+//line partiql.y:329
 		{
 			part, ok := timePart(yyDollar[3].str)
-			if !ok {
+			if !ok || !part.CanAdd() {
 				yylex.Error(__yyfmt__.Sprintf("bad DATE_ADD part %q", yyDollar[3].str))
 			}
 			yyVAL.expr = expr.DateAdd(part, yyDollar[5].expr, yyDollar[7].expr)
 		}
-	case 39:
+	case 54:
 		yyDollar = yyS[yypt-8 : yypt+1]
-//line partiql.y:238
+//line partiql.y:337
 		{
 			part, ok := timePart(yyDollar[3].str)
-			if !ok {
+			if !ok || !part.CanAdd() {
 				yylex.Error(__yyfmt__.Sprintf("bad DATE_DIFF part %q", yyDollar[3].str))
 			}
 			yyVAL.expr = expr.DateDiff(part, yyDollar[5].expr, yyDollar[7].expr)
 		}
-	case 40:
+	case 55:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:246
+//line partiql.y:345
 		{
 			part, ok := timePart(yyDollar[3].str)
-			if !ok {
+			if !ok || !part.CanTrunc() {
 				yylex.Error(__yyfmt__.Sprintf("bad DATE_TRUNC part %q", yyDollar[3].str))
 			}
 			yyVAL.expr = expr.DateTrunc(part, yyDollar[5].expr)
 		}
-	case 41:
+	case 56:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line partiql.y:353
+		{
+			part, ok := timePart(yyDollar[3].str)
+			if !ok || !part.CanTrunc() {
+				yylex.Error(__yyfmt__.Sprintf("bad DATE_TRUNC part %q", yyDollar[3].str))
+			}
+			yyVAL.expr = expr.DateTruncIn(part, yyDollar[5].expr, yyDollar[7].expr)
+		}
+	case 57:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:254
+//line partiql.y:361
 		{
 			part, ok := timePart(yyDollar[3].str)
 			if !ok {
@@ -1244,15 +1504,43 @@ yydefault:
 			}
 			yyVAL.expr = expr.DateExtract(part, yyDollar[5].expr)
 		}
-	case 42:
+	case 58:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line partiql.y:369
+		{
+			part, ok := timePart(yyDollar[3].str)
+			if !ok {
+				yylex.Error(__yyfmt__.Sprintf("bad EXTRACT part %q", yyDollar[3].str))
+			}
+			yyVAL.expr = expr.DateExtractIn(part, yyDollar[5].expr, yyDollar[7].expr)
+		}
+	case 59:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line partiql.y:377
+		{
+			yyVAL.expr = expr.CallOp(expr.StrPos, yyDollar[5].expr, yyDollar[3].expr)
+		}
+	case 60:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line partiql.y:381
+		{
+			yyVAL.expr = expr.CallOp(expr.Left, yyDollar[3].expr, yyDollar[5].expr)
+		}
+	case 61:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line partiql.y:385
+		{
+			yyVAL.expr = expr.CallOp(expr.Right, yyDollar[3].expr, yyDollar[5].expr)
+		}
+	case 62:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:262
+//line partiql.y:389
 		{
 			yyVAL.expr = yylex.(*scanner).utcnow()
 		}
-	case 43:
+	case 63:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:266
+//line partiql.y:393
 		{
 			op := expr.Call(yyDollar[1].str)
 			if op.Private() {
@@ -1260,9 +1548,9 @@ yydefault:
 			}
 			yyVAL.expr = op
 		}
-	case 44:
+	case 64:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:274
+//line partiql.y:401
 		{
 			op := expr.Call(yyDollar[1].str, yyDollar[3].values...)
 			if op.Private() {
@@ -1270,471 +1558,499 @@ yydefault:
 			}
 			yyVAL.expr = op
 		}
-	case 45:
+	case 65:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:282
+//line partiql.y:409
 		{
 			yyVAL.expr = expr.CallOp(expr.InSubquery, yyDollar[1].expr, yyDollar[4].sel)
 		}
-	case 46:
+	case 66:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:286
+//line partiql.y:413
 		{
 			yyVAL.expr = expr.In(yyDollar[1].expr, yyDollar[4].values...)
 		}
-	case 47:
+	case 67:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:290
+//line partiql.y:417
 		{
 			yyVAL.expr = exists(yyDollar[3].sel)
 		}
-	case 48:
+	case 68:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line partiql.y:421
+		{
+			yyVAL.expr = &expr.Exists{Var: yyDollar[3].str, List: yyDollar[5].expr, Where: yyDollar[7].expr}
+		}
+	case 69:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line partiql.y:425
+		{
+			yyVAL.expr = quantify(expr.CmpOp(yyDollar[2].integer), yyDollar[1].expr, yyDollar[3].yesno, yyDollar[5].quant)
+		}
+	case 70:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:294
+//line partiql.y:429
 		{
 			yyVAL.expr = expr.BitOr(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 49:
+	case 71:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:298
+//line partiql.y:433
 		{
 			yyVAL.expr = expr.BitXor(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 50:
+	case 72:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:302
+//line partiql.y:437
 		{
 			yyVAL.expr = expr.BitAnd(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 51:
+	case 73:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:306
+//line partiql.y:441
 		{
 			yyVAL.expr = expr.ShiftLeftLogical(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 52:
+	case 74:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:310
+//line partiql.y:445
 		{
 			yyVAL.expr = expr.ShiftRightLogical(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 53:
+	case 75:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:314
+//line partiql.y:449
 		{
 			yyVAL.expr = expr.ShiftRightArithmetic(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 54:
+	case 76:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:318
+//line partiql.y:453
 		{
 			yyVAL.expr = expr.Add(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 55:
+	case 77:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:322
+//line partiql.y:457
 		{
 			yyVAL.expr = expr.Sub(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 56:
+	case 78:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:326
+//line partiql.y:461
 		{
 			yyVAL.expr = expr.Mul(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 57:
+	case 79:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:330
+//line partiql.y:465
 		{
 			yyVAL.expr = expr.Div(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 58:
+	case 80:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:334
+//line partiql.y:469
 		{
 			yyVAL.expr = expr.Mod(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 59:
+	case 81:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:338
+//line partiql.y:473
 		{
 			yyVAL.expr = expr.Call("CONCAT", yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 60:
+	case 82:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:342
+//line partiql.y:477
 		{
 			yyVAL.expr = expr.Append(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 61:
+	case 83:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line partiql.y:481
+		{
+			if !isTimeZone(yyDollar[3].str, yyDollar[4].str) {
+				yylex.Error(__yyfmt__.Sprintf("unexpected %s %s after AT", yyDollar[3].str, yyDollar[4].str))
+				return 1
+			}
+			yyVAL.expr = expr.CallOp(expr.AtTimeZone, yyDollar[1].expr, expr.String(yyDollar[5].str))
+		}
+	case 84:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:346
+//line partiql.y:489
 		{
 			yyVAL.expr = expr.Neg(yyDollar[2].expr)
 		}
-	case 62:
+	case 85:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:350
+//line partiql.y:493
 		{
 			yyVAL.expr = expr.Compare(expr.Ilike, yyDollar[1].expr, expr.String(yyDollar[3].str))
 		}
-	case 63:
+	case 86:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:354
+//line partiql.y:497
 		{
 			yyVAL.expr = expr.Compare(expr.Like, yyDollar[1].expr, expr.String(yyDollar[3].str))
 		}
-	case 64:
+	case 87:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:358
+//line partiql.y:501
 		{
 			yyVAL.expr = expr.Compare(expr.SimilarTo, yyDollar[1].expr, expr.String(yyDollar[4].str))
 		}
-	case 65:
+	case 88:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:362
+//line partiql.y:505
 		{
 			yyVAL.expr = expr.Compare(expr.RegexpMatch, yyDollar[1].expr, expr.String(yyDollar[3].str))
 		}
-	case 66:
+	case 89:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:366
+//line partiql.y:509
 		{
 			yyVAL.expr = expr.Compare(expr.RegexpMatchCi, yyDollar[1].expr, expr.String(yyDollar[3].str))
 		}
-	case 67:
+	case 90:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:370
+//line partiql.y:513
 		{
 			yyVAL.expr = expr.Compare(expr.Equals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 68:
+	case 91:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:374
+//line partiql.y:517
 		{
 			yyVAL.expr = expr.Compare(expr.NotEquals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 69:
+	case 92:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:378
+//line partiql.y:521
 		{
 			yyVAL.expr = expr.Compare(expr.Less, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 70:
+	case 93:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:382
+//line partiql.y:525
 		{
 			yyVAL.expr = expr.Compare(expr.LessEquals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 71:
+	case 94:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:386
+//line partiql.y:529
 		{
 			yyVAL.expr = expr.Compare(expr.Greater, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 72:
+	case 95:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:390
+//line partiql.y:533
 		{
 			yyVAL.expr = expr.Compare(expr.GreaterEquals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 73:
+	case 96:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:394
+//line partiql.y:537
 		{
 			yyVAL.expr = expr.Between(yyDollar[1].expr, yyDollar[3].expr, yyDollar[5].expr)
 		}
-	case 74:
+	case 97:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:398
+//line partiql.y:541
 		{
 			yyVAL.expr = &expr.Not{Expr: expr.Compare(expr.Like, yyDollar[1].expr, expr.String(yyDollar[4].str))}
 		}
-	case 75:
+	case 98:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:402
+//line partiql.y:545
 		{
 			yyVAL.expr = &expr.Not{Expr: expr.Compare(expr.Ilike, yyDollar[1].expr, expr.String(yyDollar[4].str))}
 		}
-	case 76:
+	case 99:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:406
+//line partiql.y:549
 		{
 			yyVAL.expr = &expr.Not{Expr: expr.Compare(expr.SimilarTo, yyDollar[1].expr, expr.String(yyDollar[5].str))}
 		}
-	case 77:
+	case 100:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:410
+//line partiql.y:553
 		{
 			yyVAL.expr = &expr.Not{Expr: expr.Compare(expr.RegexpMatch, yyDollar[1].expr, expr.String(yyDollar[4].str))}
 		}
-	case 78:
+	case 101:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:414
+//line partiql.y:557
 		{
 			yyVAL.expr = &expr.Not{Expr: expr.Compare(expr.RegexpMatchCi, yyDollar[1].expr, expr.String(yyDollar[4].str))}
 		}
-	case 79:
+	case 102:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:418
+//line partiql.y:561
 		{
 			yyVAL.expr = &expr.Not{Expr: yyDollar[2].expr}
 		}
-	case 80:
+	case 103:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:422
+//line partiql.y:565
 		{
 			yyVAL.expr = expr.BitNot(yyDollar[2].expr)
 		}
-	case 81:
+	case 104:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:426
+//line partiql.y:569
 		{
 			yyVAL.expr = expr.And(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 82:
+	case 105:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:430
+//line partiql.y:573
 		{
 			yyVAL.expr = expr.Or(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 83:
+	case 106:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:434
+//line partiql.y:577
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNull, Expr: yyDollar[1].expr}
 		}
-	case 84:
+	case 107:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:438
+//line partiql.y:581
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotNull, Expr: yyDollar[1].expr}
 		}
-	case 85:
+	case 108:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:442
+//line partiql.y:585
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsMissing, Expr: yyDollar[1].expr}
 		}
-	case 86:
+	case 109:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:446
+//line partiql.y:589
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotMissing, Expr: yyDollar[1].expr}
 		}
-	case 87:
+	case 110:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:450
+//line partiql.y:593
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsTrue, Expr: yyDollar[1].expr}
 		}
-	case 88:
+	case 111:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:454
+//line partiql.y:597
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotTrue, Expr: yyDollar[1].expr}
 		}
-	case 89:
+	case 112:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:458
+//line partiql.y:601
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsFalse, Expr: yyDollar[1].expr}
 		}
-	case 90:
+	case 113:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:462
+//line partiql.y:605
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotFalse, Expr: yyDollar[1].expr}
 		}
-	case 91:
+	case 114:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:467
+//line partiql.y:610
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 92:
+	case 115:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:472
+//line partiql.y:615
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 93:
+	case 116:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:477
+//line partiql.y:620
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 94:
+	case 117:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:483
+//line partiql.y:626
 		{
 			yyVAL.bindings = []expr.Binding{yyDollar[1].bind}
 		}
-	case 95:
+	case 118:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:484
+//line partiql.y:627
 		{
 			yyVAL.bindings = append(yyDollar[1].bindings, yyDollar[3].bind)
 		}
-	case 96:
+	case 119:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:488
+//line partiql.y:631
 		{
 			yyVAL.values = []expr.Node{yyDollar[1].expr}
 		}
-	case 97:
+	case 120:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:489
+//line partiql.y:632
 		{
 			yyVAL.values = append(yyDollar[1].values, yyDollar[3].expr)
 		}
-	case 98:
+	case 121:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:493
+//line partiql.y:636
 		{
 			yyVAL.values = []expr.Node{yyDollar[1].expr}
 		}
-	case 99:
+	case 122:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:494
+//line partiql.y:637
 		{
 			yyVAL.values = []expr.Node{expr.Star{}}
 		}
-	case 100:
+	case 123:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:495
+//line partiql.y:638
 		{
 			yyVAL.values = append(yyDollar[1].values, yyDollar[3].expr)
 		}
-	case 101:
+	case 124:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:499
+//line partiql.y:642
 		{
 			yyVAL.values = []expr.Node{yyDollar[1].expr}
 		}
-	case 102:
+	case 125:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:500
+//line partiql.y:643
 		{
 			yyVAL.values = append(yyDollar[1].values, yyDollar[3].expr)
 		}
-	case 103:
+	case 126:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:501
+//line partiql.y:644
 		{
 			yyVAL.values = nil
 		}
-	case 104:
+	case 127:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:505
+//line partiql.y:648
 		{
 			yyVAL.values = yyDollar[1].values
 		}
-	case 105:
+	case 128:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:506
+//line partiql.y:649
 		{
 			yyVAL.values = append(yyDollar[1].values, yyDollar[3].values...)
 		}
-	case 106:
+	case 129:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:507
+//line partiql.y:650
 		{
 			yyVAL.values = nil
 		}
-	case 107:
+	case 130:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:511
+//line partiql.y:654
 		{
 			yyVAL.values = []expr.Node{expr.String(yyDollar[1].str), yyDollar[3].expr}
 		}
-	case 108:
+	case 131:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line partiql.y:515
+//line partiql.y:658
 		{
 			yyVAL.wind = &expr.Window{PartitionBy: yyDollar[5].values, OrderBy: yyDollar[6].orders}
 		}
-	case 109:
+	case 132:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line partiql.y:662
+		{
+			yyVAL.wind = &expr.Window{OrderBy: yyDollar[3].orders}
+		}
+	case 133:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:518
+//line partiql.y:665
 		{
 			yyVAL.wind = nil
 		}
-	case 110:
+	case 134:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:521
+//line partiql.y:668
 		{
 			yyVAL.jk = expr.InnerJoin
 		}
-	case 111:
+	case 135:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:522
+//line partiql.y:669
 		{
 			yyVAL.jk = expr.InnerJoin
 		}
-	case 112:
+	case 136:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:523
+//line partiql.y:670
 		{
 			yyVAL.jk = expr.LeftJoin
 		}
-	case 113:
+	case 137:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:524
+//line partiql.y:671
 		{
 			yyVAL.jk = expr.LeftJoin
 		}
-	case 114:
+	case 138:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:525
+//line partiql.y:672
 		{
 			yyVAL.jk = expr.RightJoin
 		}
-	case 115:
+	case 139:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:526
+//line partiql.y:673
 		{
 			yyVAL.jk = expr.RightJoin
 		}
-	case 116:
+	case 140:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:527
+//line partiql.y:674
 		{
 			yyVAL.jk = expr.FullJoin
 		}
-	case 119:
+	case 143:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:532
+//line partiql.y:679
 		{
 			yyVAL.from = yyDollar[1].from
 		}
-	case 120:
+	case 144:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:533
+//line partiql.y:680
 		{
 			yyVAL.from = nil
 		}
-	case 121:
+	case 145:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:540
+//line partiql.y:687
 		{
 			yyVAL.from = &expr.Table{Binding: yyDollar[2].bind}
 		}
-	case 122:
+	case 146:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:541
+//line partiql.y:688
 		{
 			yyVAL.from = &expr.Join{Kind: expr.CrossJoin, Left: yyDollar[1].from, Right: yyDollar[3].bind}
 		}
-	case 123:
+	case 147:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line partiql.y:543
+//line partiql.y:690
 		{
 			yyVAL.from = &expr.Join{Kind: yyDollar[2].jk, Left: yyDollar[1].from, Right: yyDollar[3].bind, On: &expr.OnEquals{Left: yyDollar[5].expr, Right: yyDollar[7].expr}}
 		}
-	case 124:
+	case 148:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:546
+//line partiql.y:693
 		{
 			var idxerr error
 			yyVAL.integer, idxerr = toint(yyDollar[1].expr)
@@ -1742,239 +2058,305 @@ yydefault:
 				yylex.Error(idxerr.Error())
 			}
 		}
-	case 125:
+	case 149:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:549
+//line partiql.y:696
 		{
 			yyVAL.pc = nil
 		}
-	case 126:
+	case 150:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:550
+//line partiql.y:697
 		{
 			yyVAL.pc = &expr.Dot{Field: yyDollar[2].str, Rest: yyDollar[3].pc}
 		}
-	case 127:
+	case 151:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:551
+//line partiql.y:698
 		{
 			yyVAL.pc = &expr.LiteralIndex{Field: yyDollar[2].integer, Rest: yyDollar[4].pc}
 		}
-	case 128:
+	case 152:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:552
+//line partiql.y:699
 		{
 			yyVAL.pc = &expr.Dot{Field: yyDollar[2].str, Rest: yyDollar[4].pc}
 		}
-	case 129:
+	case 153:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:561
+//line partiql.y:708
 		{
 			yyVAL.str = yyDollar[1].str
 		}
-	case 130:
+	case 154:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:712
+		{
+			yyVAL.integer = int(expr.Equals)
+		}
+	case 155:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:713
+		{
+			yyVAL.integer = int(expr.NotEquals)
+		}
+	case 156:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:714
+		{
+			yyVAL.integer = int(expr.Less)
+		}
+	case 157:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:715
+		{
+			yyVAL.integer = int(expr.LessEquals)
+		}
+	case 158:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:716
+		{
+			yyVAL.integer = int(expr.Greater)
+		}
+	case 159:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:717
+		{
+			yyVAL.integer = int(expr.GreaterEquals)
+		}
+	case 160:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:721
+		{
+			yyVAL.yesno = false
+		}
+	case 161:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:722
+		{
+			yyVAL.yesno = false
+		}
+	case 162:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:723
+		{
+			yyVAL.yesno = true
+		}
+	case 163:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:727
+		{
+			yyVAL.quant = quantified{list: yyDollar[1].expr}
+		}
+	case 164:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line partiql.y:729
+		{
+			yyVAL.quant = quantified{list: &expr.Path{First: yyDollar[1].str, Rest: yyDollar[2].pc}, rest: yyDollar[4].pc}
+		}
+	case 165:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:564
+//line partiql.y:734
 		{
 			yyVAL.expr = nil
 		}
-	case 131:
+	case 166:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:565
+//line partiql.y:735
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 132:
+	case 167:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:568
+//line partiql.y:738
 		{
 			yyVAL.limbs = []expr.CaseLimb{{When: yyDollar[2].expr, Then: yyDollar[4].expr}}
 		}
-	case 133:
+	case 168:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:569
+//line partiql.y:739
 		{
 			yyVAL.limbs = append(yyDollar[1].limbs, expr.CaseLimb{When: yyDollar[3].expr, Then: yyDollar[5].expr})
 		}
-	case 134:
+	case 169:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:572
+//line partiql.y:742
 		{
 			yyVAL.expr = nil
 		}
-	case 135:
+	case 170:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:573
+//line partiql.y:743
 		{
 			yyVAL.expr = yyDollar[4].expr
 		}
-	case 136:
+	case 171:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:576
+//line partiql.y:746
 		{
 			yyVAL.expr = nil
 		}
-	case 137:
+	case 172:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:577
+//line partiql.y:747
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 138:
+	case 173:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:580
+//line partiql.y:750
 		{
 			yyVAL.expr = nil
 		}
-	case 139:
+	case 174:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:581
+//line partiql.y:751
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 140:
+	case 175:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:584
+//line partiql.y:754
 		{
 			yyVAL.bindings = nil
 		}
-	case 141:
+	case 176:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:585
+//line partiql.y:755
 		{
 			yyVAL.bindings = yyDollar[3].bindings
 		}
-	case 142:
+	case 177:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:589
+//line partiql.y:759
 		{
 			yyVAL.yesno = false
 		}
-	case 143:
+	case 178:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:590
+//line partiql.y:760
 		{
 			yyVAL.yesno = false
 		}
-	case 144:
+	case 179:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:591
+//line partiql.y:761
 		{
 			yyVAL.yesno = true
 		}
-	case 145:
+	case 180:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:595
+//line partiql.y:765
 		{
 			yyVAL.yesno = false
 		}
-	case 146:
+	case 181:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:596
+//line partiql.y:766
 		{
 			yyVAL.yesno = false
 		}
-	case 147:
+	case 182:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:597
+//line partiql.y:767
 		{
 			yyVAL.yesno = true
 		}
-	case 148:
+	case 183:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:601
+//line partiql.y:771
 		{
 			yyVAL.order = expr.Order{Column: yyDollar[1].expr, Desc: yyDollar[2].yesno, NullsLast: yyDollar[3].yesno}
 		}
-	case 149:
+	case 184:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:604
+//line partiql.y:774
 		{
 			yyVAL.orders = append(yyDollar[1].orders, yyDollar[3].order)
 		}
-	case 150:
+	case 185:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:605
+//line partiql.y:775
 		{
 			yyVAL.orders = []expr.Order{yyDollar[1].order}
 		}
-	case 151:
+	case 186:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:608
+//line partiql.y:778
 		{
 			yyVAL.orders = nil
 		}
-	case 152:
+	case 187:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:609
+//line partiql.y:779
 		{
 			yyVAL.orders = yyDollar[3].orders
 		}
-	case 153:
+	case 188:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:612
+//line partiql.y:782
 		{
 			yyVAL.exprint = nil
 		}
-	case 154:
+	case 189:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:613
+//line partiql.y:783
 		{
 			n := expr.Integer(yyDollar[2].integer)
 			yyVAL.exprint = &n
 		}
-	case 155:
+	case 190:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:616
+//line partiql.y:786
 		{
 			yyVAL.exprint = nil
 		}
-	case 156:
+	case 191:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:617
+//line partiql.y:787
 		{
 			n := expr.Integer(yyDollar[2].integer)
 			yyVAL.exprint = &n
 		}
-	case 157:
+	case 192:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:620
+//line partiql.y:790
 		{
 			yyVAL.expr = &expr.Unpivot{TupleRef: yyDollar[2].expr, As: yyDollar[4].str, At: ""}
 		}
-	case 158:
+	case 193:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:621
+//line partiql.y:791
 		{
 			yyVAL.expr = &expr.Unpivot{TupleRef: yyDollar[2].expr, As: yyDollar[4].str, At: yyDollar[6].str}
 		}
-	case 159:
+	case 194:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:622
+//line partiql.y:792
 		{
 			yyVAL.expr = &expr.Unpivot{TupleRef: yyDollar[2].expr, As: yyDollar[6].str, At: yyDollar[4].str}
 		}
-	case 160:
+	case 195:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:625
+//line partiql.y:795
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 161:
+	case 196:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:626
+//line partiql.y:796
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 162:
+	case 197:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:629
+//line partiql.y:799
 		{
 			yyVAL.expr = expr.Call("MAKE_STRUCT", yyDollar[2].values...)
 		}
-	case 163:
+	case 198:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:632
+//line partiql.y:802
 		{
 			yyVAL.expr = expr.Call("MAKE_LIST", yyDollar[2].values...)
 		}
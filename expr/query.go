@@ -49,9 +49,8 @@ type Query struct {
 	// Body is the body of the query.
 	// Body can be:
 	//   - A SELECT expression
-	// TODO:
-	//   - A UNION expression
-	//   - A UNION ALL expression
+	//   - A UNION, UNION ALL, INTERSECT,
+	//     or EXCEPT expression
 	Body Node
 }
 
@@ -81,6 +80,8 @@ func (q *Query) text(dst *strings.Builder, redact bool) {
 	if s, ok := q.Body.(*Select); ok {
 		// do not parenthesize final SELECT
 		s.write(dst, redact, q.Into)
+	} else if u, ok := q.Body.(*Union); ok {
+		u.write(dst, redact)
 	} else {
 		q.Body.text(dst, redact)
 	}
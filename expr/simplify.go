@@ -19,6 +19,7 @@ import (
 	"math/big"
 	"strings"
 
+	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/ion"
 )
 
@@ -348,6 +349,21 @@ func constcmp(op CmpOp, left, right *big.Rat) Bool {
 	}
 }
 
+func consttimecmp(op CmpOp, left, right date.Time) Bool {
+	switch op {
+	case Greater:
+		return Bool(right.Before(left))
+	case GreaterEquals:
+		return Bool(!left.Before(right))
+	case Less:
+		return Bool(left.Before(right))
+	case LessEquals:
+		return Bool(!right.Before(left))
+	default:
+		panic("???")
+	}
+}
+
 type logical interface {
 	invert() Node
 }
@@ -435,6 +451,11 @@ func (c *Comparison) simplify(h Hint) Node {
 		}
 		return constcmp(c.Op, ln.rat(), rn.rat())
 	}
+	lt, okl := left.(*Timestamp)
+	rt, okr := right.(*Timestamp)
+	if okl && okr && c.Op.ordinal() {
+		return consttimecmp(c.Op, lt.Value, rt.Value)
+	}
 
 	// if the lhs or rhs is a CASE expression,
 	// insert the comparisons into the arms of the case
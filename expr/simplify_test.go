@@ -112,6 +112,25 @@ func TestSimplify(t *testing.T) {
 			Compare(Less, Integer(3), Float(3.5)),
 			Bool(true),
 		},
+		{
+			&Comparison{Op: GreaterEquals, Left: ts("2022-01-02T00:00:00Z"), Right: ts("2022-01-02T00:00:00Z")},
+			Bool(true),
+		},
+		{
+			&Comparison{Op: Greater, Left: ts("2022-01-02T00:00:00Z"), Right: ts("2022-01-02T00:00:00Z")},
+			Bool(false),
+		},
+		{
+			// a timestamp comparison with a
+			// constant argument is folded
+			&Comparison{Op: LessEquals, Left: Call("DATE_ADD_DAY", Integer(1), ts("2022-01-02T00:00:00Z")), Right: ts("2022-01-03T00:00:00Z")},
+			Bool(true),
+		},
+		{
+			// BETWEEN with constant timestamps
+			Between(ts("2022-01-02T00:00:00Z"), ts("2022-01-01T00:00:00Z"), ts("2022-01-02T00:00:00Z")),
+			Bool(true),
+		},
 		{
 			// expr <> TRUE -> NOT expr
 			Xor(Compare(Less, path("x"), path("y")), Bool(true)),
@@ -134,6 +153,182 @@ func TestSimplify(t *testing.T) {
 			Div(Float(3.0), Float(3.0)),
 			Float(1.0),
 		},
+		{
+			Call("REGEXP_EXTRACT", String("user=bob ok"), String(`user=(\w+)`), Integer(1)),
+			String("bob"),
+		},
+		{
+			Call("REGEXP_EXTRACT", String("no match"), String(`[0-9]+`)),
+			Missing{},
+		},
+		{
+			Call("REGEXP_REPLACE", String("a1b22"), String(`[0-9]+`), String("#")),
+			String("a#b#"),
+		},
+		{
+			Call("REGEXP_COUNT", String("a1b22"), String(`[0-9]+`)),
+			Integer(2),
+		},
+		{
+			Call("INET_PARSE", String("2001:DB8:0:0:1:0:0:1")),
+			String("2001:db8::1:0:0:1"),
+		},
+		{
+			Call("INET_PARSE", String("1.2.3.04")),
+			Missing{},
+		},
+		{
+			Call("INET_FAMILY", String("::ffff:1.2.3.4")),
+			Integer(6),
+		},
+		{
+			Call("INET_TRUNC", String("192.168.17.5"), Integer(20)),
+			String("192.168.16.0"),
+		},
+		{
+			Call("INET_TRUNC", String("192.168.17.5"), Integer(33)),
+			Missing{},
+		},
+		{
+			Call("JSON_PARSE", String(`{"a": [1, "x"]}`)),
+			&Struct{Fields: []Field{{Label: "a", Value: &List{Values: []Constant{Integer(1), String("x")}}}}},
+		},
+		{
+			Call("JSON_PARSE", String(`{"a": `)),
+			Missing{},
+		},
+		{
+			Call("JSON_EXTRACT", String(`{"a": {"b": [1, 2.5]}}`), String("$.a.b[1]")),
+			Float(2.5),
+		},
+		{
+			Call("JSON_EXTRACT", String(`{"a b": true}`), String(`$["a b"]`)),
+			Bool(true),
+		},
+		{
+			Call("JSON_EXTRACT", String(`{"a": 1}`), String("$.b")),
+			Missing{},
+		},
+		{
+			Call("TO_JSON_STRING", &List{Values: []Constant{Integer(1), String("x"), Null{}}}),
+			String(`[1, "x", null]`),
+		},
+		{
+			Call("TO_JSON_STRING", Missing{}),
+			Missing{},
+		},
+		{
+			Call("CARDINALITY", &List{Values: []Constant{Integer(1), Integer(2)}}),
+			Integer(2),
+		},
+		{
+			Call("ARRAY_CONTAINS", &List{Values: []Constant{String("x")}}, String("y")),
+			Bool(false),
+		},
+		{
+			Call("ARRAY_POSITION", &List{Values: []Constant{String("x"), String("y")}}, String("y")),
+			Integer(1),
+		},
+		{
+			Call("ARRAY_POSITION", &List{Values: []Constant{String("x"), String("y")}}, String("z")),
+			Missing{},
+		},
+		{
+			Call("ARRAY_SLICE", &List{Values: []Constant{Integer(1), Integer(2), Integer(3)}}, Integer(1), Integer(5)),
+			&List{Values: []Constant{Integer(2), Integer(3)}},
+		},
+		{
+			Call("ARRAY_DISTINCT", &List{Values: []Constant{Integer(1), Integer(2), Integer(1)}}),
+			&List{Values: []Constant{Integer(1), Integer(2)}},
+		},
+		{
+			Call("ARRAY_JOIN", &List{Values: []Constant{String("x"), Integer(3), String("y")}}, String("-")),
+			String("x-y"),
+		},
+		{
+			Call("IS_SUBNET_OF", String("2001:db8::/32"), path("x")),
+			Call("IS_SUBNET_OF", String("2001:db8::"), String("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"), path("x")),
+		},
+		{
+			Call("IS_SUBNET_OF", String("10.1.2.3"), String("10.1.2.3"), path("x")),
+			Compare(Equals, path("x"), String("10.1.2.3")),
+		},
+		{
+			Call("STRPOS", String("héllo"), String("llo")),
+			Integer(3),
+		},
+		{
+			Call("REPLACE", String("abcabc"), String("b"), String("")),
+			String("acac"),
+		},
+		{
+			Call("REPLACE", path("x"), String(""), String("y")),
+			path("x"),
+		},
+		{
+			Call("REVERSE", String("aéb")),
+			String("béa"),
+		},
+		{
+			Call("REVERSE", Call("REVERSE", path("x"))),
+			path("x"),
+		},
+		{
+			Call("INITCAP", String("hello WORLD 2nd")),
+			String("Hello World 2nd"),
+		},
+		{
+			Call("REPEAT", String("ab"), Integer(3)),
+			String("ababab"),
+		},
+		{
+			Call("LPAD", String("7"), Integer(3), String("0")),
+			String("007"),
+		},
+		{
+			Call("RPAD", String("abcdef"), Integer(3)),
+			String("abc"),
+		},
+		{
+			Call("STARTS_WITH", String("abc"), String("ab")),
+			Bool(true),
+		},
+		{
+			Call("ENDS_WITH", String("abc"), String("ab")),
+			Bool(false),
+		},
+		{
+			Call("LEFT", String("héllo"), Integer(-2)),
+			String("hél"),
+		},
+		{
+			Call("RIGHT", String("héllo"), Integer(4)),
+			String("éllo"),
+		},
+		{
+			Call("PARSE_TIMESTAMP", String("%d/%b/%Y:%T %z"), String("10/Oct/2000:13:55:36 -0700")),
+			&Timestamp{Value: date.Date(2000, 10, 10, 20, 55, 36, 0)},
+		},
+		{
+			Call("PARSE_TIMESTAMP", String("%F"), String("2022-02-29")),
+			Missing{},
+		},
+		{
+			Call("FORMAT_TIMESTAMP", String("%b %e %T"), &Timestamp{Value: date.Date(2000, 10, 1, 13, 55, 36, 0)}),
+			String("Oct  1 13:55:36"),
+		},
+		{
+			Call("FROM_UNIXTIME", Integer(971186136)),
+			&Timestamp{Value: date.Date(2000, 10, 10, 13, 55, 36, 0)},
+		},
+		{
+			Call("FROM_UNIXTIME", Float(-0.5)),
+			&Timestamp{Value: date.Date(1969, 12, 31, 23, 59, 59, 500000000)},
+		},
+		{
+			Call("FROM_UNIXTIME", path("x")),
+			Call("FROM_UNIX_MICRO", Mul(path("x"), Integer(1000000))),
+		},
 		{
 			Call("ROUND", Float(3.1)),
 			Float(3.0),
@@ -556,6 +751,60 @@ func TestSimplify(t *testing.T) {
 			DateTrunc(Minute, ts("2009-01-14T23:59:59Z")),
 			ts("2009-01-14T23:59:00Z"),
 		},
+		{
+			// 2009-01-01 is a Thursday, so the
+			// first ISO week of 2009 starts in 2008
+			DateTrunc(ISOYear, ts("2009-06-14T23:59:59Z")),
+			ts("2008-12-29T00:00:00Z"),
+		},
+		{
+			DateTrunc(Week, ts("2009-01-01T12:00:00Z")),
+			ts("2008-12-28T00:00:00Z"),
+		},
+		{
+			DateTrunc(Quarter, ts("2009-06-14T23:59:59Z")),
+			ts("2009-04-01T00:00:00Z"),
+		},
+		{
+			DateExtract(ISOYear, ts("2010-01-03T00:00:00Z")),
+			Integer(2009),
+		},
+		{
+			DateExtract(ISOWeek, ts("2010-01-03T00:00:00Z")),
+			Integer(53),
+		},
+		{
+			DateExtract(Week, ts("2010-01-03T00:00:00Z")),
+			Integer(1),
+		},
+		{
+			DateExtract(DOY, ts("2008-12-31T00:00:00Z")),
+			Integer(366),
+		},
+		{
+			CallOp(DateAddQuarter, Integer(-1), ts("2009-01-14T23:59:59Z")),
+			ts("2008-10-14T23:59:59Z"),
+		},
+		{
+			CallOp(DateAddWeek, Integer(2), ts("2009-01-14T23:59:59Z")),
+			ts("2009-01-28T23:59:59Z"),
+		},
+		{
+			Call("DATE_TRUNC_DAY", ts("2009-01-14T23:59:59Z"), String("America/New_York")),
+			ts("2009-01-14T05:00:00Z"),
+		},
+		{
+			Call("DATE_TRUNC_DAY", path("x"), String("UTC")),
+			DateTrunc(Day, path("x")),
+		},
+		{
+			CallOp(AtTimeZone, ts("2009-01-14T23:59:59Z"), String("+05:30")),
+			ts("2009-01-15T05:29:59Z"),
+		},
+		{
+			CallOp(TimeBucket, path("x"), Integer(60), String("UTC")),
+			CallOp(TimeBucket, path("x"), Integer(60)),
+		},
 		{
 			In(String("foo"), Float(3.5), String("bar"), String("foo"), Bool(false)),
 			Bool(true),
@@ -0,0 +1,170 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// UnionType is the kind of set operation
+// performed by a Union.
+type UnionType int
+
+const (
+	// UnionAll concatenates the results
+	// of both queries.
+	UnionAll UnionType = iota
+	// UnionDistinct produces the distinct
+	// rows produced by either query.
+	UnionDistinct
+	// Intersect produces the distinct
+	// rows produced by both queries.
+	Intersect
+	// Except produces the distinct rows
+	// produced by the left-hand-side query
+	// that are not produced by the right-hand-side query.
+	Except
+)
+
+func (u UnionType) String() string {
+	switch u {
+	case UnionAll:
+		return "UNION ALL"
+	case UnionDistinct:
+		return "UNION"
+	case Intersect:
+		return "INTERSECT"
+	case Except:
+		return "EXCEPT"
+	default:
+		return fmt.Sprintf("<UnionType %d>", int(u))
+	}
+}
+
+// Union is a set operation
+// (UNION ALL, UNION, INTERSECT, or EXCEPT)
+// over the results of two queries.
+//
+// Left and Right are either *Select or *Union.
+type Union struct {
+	Type        UnionType
+	Left, Right Node
+}
+
+func (u *Union) walk(v Visitor) {
+	Walk(v, u.Left)
+	Walk(v, u.Right)
+}
+
+func (u *Union) rewrite(r Rewriter) Node {
+	u.Left = Rewrite(r, u.Left)
+	u.Right = Rewrite(r, u.Right)
+	return u
+}
+
+// Arms returns the list of queries
+// that are combined with u.Type
+// when u is evaluated left-to-right.
+// (In other words, a chain of left-associative
+// set operations of the same type is flattened.)
+func (u *Union) Arms() []Node {
+	var lhs []Node
+	if l, ok := u.Left.(*Union); ok && l.Type == u.Type {
+		lhs = l.Arms()
+	} else {
+		lhs = []Node{u.Left}
+	}
+	return append(lhs, u.Right)
+}
+
+func (u *Union) Equals(x Node) bool {
+	xu, ok := x.(*Union)
+	return ok && u.Type == xu.Type &&
+		u.Left.Equals(xu.Left) &&
+		u.Right.Equals(xu.Right)
+}
+
+func (u *Union) Encode(dst *ion.Buffer, st *ion.Symtab) {
+	dst.BeginStruct(-1)
+	settype(dst, st, "union")
+	dst.BeginField(st.Intern("kind"))
+	dst.WriteUint(uint64(u.Type))
+	dst.BeginField(st.Intern("left"))
+	u.Left.Encode(dst, st)
+	dst.BeginField(st.Intern("right"))
+	u.Right.Encode(dst, st)
+	dst.EndStruct()
+}
+
+func (u *Union) setfield(name string, st *ion.Symtab, body []byte) error {
+	var err error
+	switch name {
+	case "kind":
+		var k uint64
+		k, _, err = ion.ReadUint(body)
+		u.Type = UnionType(k)
+	case "left":
+		u.Left, _, err = Decode(st, body)
+	case "right":
+		u.Right, _, err = Decode(st, body)
+	}
+	return err
+}
+
+// writeArm writes one side of a set operation;
+// the right-hand-side of an operation is
+// parenthesized unless it binds more tightly
+func writeArm(out *strings.Builder, redact bool, arm Node, tighter bool) {
+	switch a := arm.(type) {
+	case *Select:
+		a.write(out, redact, nil)
+	case *Union:
+		if tighter {
+			a.write(out, redact)
+		} else {
+			a.text(out, redact)
+		}
+	default:
+		arm.text(out, redact)
+	}
+}
+
+func (u *Union) write(out *strings.Builder, redact bool) {
+	l, ok := u.Left.(*Union)
+	writeArm(out, redact, u.Left, !ok || l.Type == Intersect || u.Type != Intersect)
+	out.WriteByte(' ')
+	out.WriteString(u.Type.String())
+	out.WriteByte(' ')
+	r, ok := u.Right.(*Union)
+	writeArm(out, redact, u.Right, ok && r.Type == Intersect && u.Type != Intersect)
+}
+
+func (u *Union) text(out *strings.Builder, redact bool) {
+	out.WriteByte('(')
+	u.write(out, redact)
+	out.WriteByte(')')
+}
+
+// Text is like ToString(u), but it
+// does not insert parentheses around
+// the query.
+func (u *Union) Text() string {
+	var out strings.Builder
+	u.write(&out, false)
+	return out.String()
+}
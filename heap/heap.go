@@ -49,9 +49,8 @@ func PushSlice[T any](x *[]T, item T, less func(x, y T) bool) {
 // If len(x) > 0, the "smallest" element in x will
 // always be x[0].
 func OrderSlice[T any](x []T, less func(x, y T) bool) {
-	for i := len(x) - 1; i >= 0; i-- {
+	for i := len(x)/2 - 1; i >= 0; i-- {
 		siftDown(x, i, less)
-		siftUp(x, i, less)
 	}
 }
 
@@ -50,4 +50,16 @@ func TestHeap(t *testing.T) {
 	if !slices.IsSorted(sorted) {
 		t.Fatal("not sorted after FixSlice")
 	}
+
+	for len(x) < cap(x) {
+		x = append(x, rand.Int())
+	}
+	OrderSlice(x, less)
+	sorted = sorted[:0]
+	for len(x) > 0 {
+		sorted = append(sorted, PopSlice(&x, less))
+	}
+	if !slices.IsSorted(sorted) {
+		t.Fatal("not sorted after OrderSlice")
+	}
 }
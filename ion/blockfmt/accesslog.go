@@ -0,0 +1,196 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bytes"
+
+	"github.com/SnellerInc/sneller/date"
+)
+
+var (
+	accessRemoteAddr = []byte("remote_addr")
+	accessIdent      = []byte("ident")
+	accessUser       = []byte("remote_user")
+	accessTime       = []byte("time")
+	accessRequest    = []byte("request")
+	accessMethod     = []byte("method")
+	accessURI        = []byte("uri")
+	accessProtocol   = []byte("protocol")
+	accessStatus     = []byte("status")
+	accessBytes      = []byte("bytes")
+	accessReferer    = []byte("referer")
+	accessUserAgent  = []byte("user_agent")
+
+	// accessTimeFormat is the format of
+	// times in access logs, i.e.
+	// 10/Oct/2000:13:55:36 -0700
+	accessTimeFormat = func() date.Format {
+		f, err := date.ParseFormat("%d/%b/%Y:%H:%M:%S %z")
+		if err != nil {
+			panic(err)
+		}
+		return f
+	}()
+)
+
+// parseAccessLog parses a line of an access log
+// in the combined format used by Apache and nginx
+// (or the common format, which is the same without
+// the referer and user agent), i.e.
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"
+//
+// into a record with the fields remote_addr, ident,
+// remote_user, time, request, method, uri, protocol,
+// status, bytes, referer and user_agent.
+// Fields that are - are omitted, and any
+// fields following the user agent are ignored.
+func parseAccessLog(line []byte, rec *logRecord) bool {
+	addr, rest, ok := cutToken(line)
+	if !ok {
+		return false
+	}
+	rec.addString(accessRemoteAddr, addr)
+	for _, name := range [][]byte{accessIdent, accessUser} {
+		var tok []byte
+		tok, rest, ok = cutToken(rest)
+		if !ok {
+			return false
+		}
+		if !isNil(tok) {
+			rec.addString(name, tok)
+		}
+	}
+
+	if len(rest) == 0 || rest[0] != '[' {
+		return false
+	}
+	end := bytes.IndexByte(rest, ']')
+	if end < 0 {
+		return false
+	}
+	t, ok := accessTimeFormat.Parse(rest[1:end])
+	if !ok {
+		return false
+	}
+	rec.addTime(accessTime, t)
+	rest, ok = cutSpace(rest[end+1:])
+	if !ok {
+		return false
+	}
+
+	var req []byte
+	req, rest, ok = cutQuoted(rest, rec)
+	if !ok {
+		return false
+	}
+	rec.addString(accessRequest, req)
+	if i, j := bytes.IndexByte(req, ' '), bytes.LastIndexByte(req, ' '); i > 0 && j > i {
+		rec.addString(accessMethod, req[:i])
+		rec.addString(accessURI, req[i+1:j])
+		rec.addString(accessProtocol, req[j+1:])
+	}
+	rest, ok = cutSpace(rest)
+	if !ok {
+		return false
+	}
+
+	var tok []byte
+	tok, rest, ok = cutToken(rest)
+	if !ok {
+		return false
+	}
+	status, ok := parseDigits(tok)
+	if !ok {
+		return false
+	}
+	rec.addInt(accessStatus, int64(status))
+	tok, rest = cutLast(rest)
+	if !isNil(tok) {
+		n, ok := parseDigits(tok)
+		if !ok {
+			return false
+		}
+		rec.addInt(accessBytes, int64(n))
+	}
+	if len(rest) == 0 {
+		// common log format
+		return true
+	}
+	for _, name := range [][]byte{accessReferer, accessUserAgent} {
+		tok, rest, ok = cutQuoted(rest, rec)
+		if !ok {
+			return false
+		}
+		if !isNil(tok) && len(tok) > 0 {
+			rec.addString(name, tok)
+		}
+		if len(rest) == 0 {
+			break
+		}
+		rest, ok = cutSpace(rest)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cutToken returns the non-empty token at the
+// start of text and the text following the
+// space that ends it
+func cutToken(text []byte) (tok, rest []byte, ok bool) {
+	i := bytes.IndexByte(text, ' ')
+	if i <= 0 {
+		return nil, nil, false
+	}
+	return text[:i], text[i+1:], true
+}
+
+// cutLast is like cutToken, but the
+// token may also end the text
+func cutLast(text []byte) (tok, rest []byte) {
+	i := bytes.IndexByte(text, ' ')
+	if i < 0 {
+		return text, nil
+	}
+	return text[:i], text[i+1:]
+}
+
+func cutSpace(text []byte) ([]byte, bool) {
+	if len(text) == 0 || text[0] != ' ' {
+		return nil, false
+	}
+	return text[1:], true
+}
+
+func accessEscape(c byte) bool {
+	return c == '"' || c == '\\'
+}
+
+// cutQuoted returns the unescaped text of the
+// quoted string at the start of text and
+// the text following it
+func cutQuoted(text []byte, rec *logRecord) (tok, rest []byte, ok bool) {
+	if len(text) == 0 || text[0] != '"' {
+		return nil, nil, false
+	}
+	end := quoteEnd(text, 1)
+	if end < 0 {
+		return nil, nil, false
+	}
+	return rec.unescape(text[1:end], accessEscape), text[end+1:], true
+}
@@ -0,0 +1,832 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/snappy"
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/compr"
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+)
+
+const (
+	// maxAvroBlock is the maximum size of a
+	// block of an avro file, both before
+	// and after decompression
+	maxAvroBlock = 256 * 1024 * 1024
+	// maxAvroMeta is the maximum size of
+	// a value in the file metadata
+	maxAvroMeta = 16 * 1024 * 1024
+	// maxAvroDepth is the maximum nesting
+	// depth of an avro value
+	maxAvroDepth = 100
+)
+
+var errAvroFormat = errors.New("invalid avro data")
+
+func avroErrorf(f string, args ...interface{}) error {
+	return fmt.Errorf("avro: "+f+": %w", append(args, errAvroFormat)...)
+}
+
+// avroConverter converts avro object container files
+// using the writer schema embedded in each file.
+//
+// Records become structures, arrays become lists
+// and maps become structures with one field per key.
+// A union is written as the value of its selected
+// branch, and a null in a record or map is omitted
+// entirely. Enums become symbols, fixed and bytes
+// become blobs, and decimals become numbers.
+// The date and timestamp logical types become
+// timestamps; the ones that are not inside an
+// array or map are added to the sparse index.
+//
+// The converter accepts the same hints as the
+// json format, but only ignore and no_index
+// have any effect. The values of maps and
+// arrays only match the wildcards * and ?.
+type avroConverter struct {
+	hints *jsonrl.Hint
+}
+
+func (a *avroConverter) Name() string { return "avro" }
+
+func (a *avroConverter) UseHints(hints []byte) error {
+	if hints == nil {
+		a.hints = nil
+		return nil
+	}
+	h, err := jsonrl.ParseHint(hints)
+	if err != nil {
+		return err
+	}
+	a.hints = h
+	return nil
+}
+
+func (a *avroConverter) Convert(r io.Reader, dst *ion.Chunker) error {
+	ar := avroReader{r: bufio.NewReader(r)}
+	err := ar.readHeader()
+	if err != nil {
+		return err
+	}
+	w := avroWriter{dst: dst, hints: a.hints}
+	w.root = w.plan(ar.schema, nil, true)
+	for {
+		count, block, err := ar.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		off := 0
+		for i := int64(0); i < count; i++ {
+			off, err = w.writeDatum(block, off)
+			if err != nil {
+				return err
+			}
+		}
+		if off != len(block) {
+			return avroErrorf("%d bytes of trailing data in block", len(block)-off)
+		}
+	}
+}
+
+// avroReader reads the blocks of
+// an avro object container file
+type avroReader struct {
+	r      *bufio.Reader
+	schema *avroSchema
+	codec  string
+	sync   [16]byte
+
+	raw, block []byte
+}
+
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// readLong reads a zig-zag encoded varint
+func (a *avroReader) readLong() (int64, error) {
+	var u uint64
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		c, err := a.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		u |= uint64(c&0x7f) << (7 * i)
+		if c < 0x80 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, avroErrorf("invalid varint")
+}
+
+// truncated turns the end of the input
+// into an error about the part of the
+// file that could not be read
+func truncated(err error, what string) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return avroErrorf("truncated %s", what)
+	}
+	return err
+}
+
+func (a *avroReader) readBytes(max int) ([]byte, error) {
+	n, err := a.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > int64(max) {
+		return nil, avroErrorf("invalid length %d", n)
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(a.r, buf)
+	return buf, err
+}
+
+func (a *avroReader) readHeader() error {
+	var magic [4]byte
+	_, err := io.ReadFull(a.r, magic[:])
+	if err != nil {
+		return truncated(err, "header")
+	}
+	if !bytes.Equal(magic[:], avroMagic) {
+		return avroErrorf("not an object container file")
+	}
+	meta := make(map[string][]byte)
+	for {
+		n, err := a.readLong()
+		if err != nil {
+			return truncated(err, "header")
+		}
+		if n == 0 {
+			break
+		}
+		if n < 0 {
+			n = -n
+			// block size in bytes
+			_, err = a.readLong()
+			if err != nil {
+				return truncated(err, "header")
+			}
+		}
+		for ; n > 0; n-- {
+			key, err := a.readBytes(maxAvroMeta)
+			if err != nil {
+				return truncated(err, "header")
+			}
+			val, err := a.readBytes(maxAvroMeta)
+			if err != nil {
+				return truncated(err, "header")
+			}
+			meta[string(key)] = val
+		}
+	}
+	_, err = io.ReadFull(a.r, a.sync[:])
+	if err != nil {
+		return truncated(err, "header")
+	}
+	text, ok := meta["avro.schema"]
+	if !ok {
+		return avroErrorf("no schema in header")
+	}
+	a.schema, err = parseAvroSchema(text)
+	if err != nil {
+		return err
+	}
+	a.codec = string(meta["avro.codec"])
+	switch a.codec {
+	case "", "null", "deflate", "snappy", "zstandard":
+	default:
+		return avroErrorf("unsupported codec %q", a.codec)
+	}
+	return nil
+}
+
+// next returns the number of values in the
+// next block and the decompressed block;
+// it returns io.EOF at the end of the file
+func (a *avroReader) next() (int64, []byte, error) {
+	count, err := a.readLong()
+	if err != nil {
+		// io.EOF here is the end of the file
+		return 0, nil, err
+	}
+	size, err := a.readLong()
+	if err != nil {
+		return 0, nil, truncated(err, "block")
+	}
+	if count < 0 || size < 0 || size > maxAvroBlock {
+		return 0, nil, avroErrorf("invalid block with %d values in %d bytes", count, size)
+	}
+	a.raw = slices.Grow(a.raw[:0], int(size))[:size]
+	_, err = io.ReadFull(a.r, a.raw)
+	if err != nil {
+		return 0, nil, truncated(err, "block")
+	}
+	var sync [16]byte
+	_, err = io.ReadFull(a.r, sync[:])
+	if err != nil {
+		return 0, nil, truncated(err, "block")
+	}
+	if sync != a.sync {
+		return 0, nil, avroErrorf("sync marker mismatch")
+	}
+	err = a.decompress()
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, a.block, nil
+}
+
+func (a *avroReader) decompress() error {
+	var err error
+	switch a.codec {
+	case "", "null":
+		a.block = a.raw
+		return nil
+	case "deflate":
+		buf := bytes.NewBuffer(a.block[:0])
+		_, err = buf.ReadFrom(io.LimitReader(flate.NewReader(bytes.NewReader(a.raw)), maxAvroBlock+1))
+		a.block = buf.Bytes()
+	case "snappy":
+		// the compressed data is followed
+		// by the crc32 of the uncompressed data
+		if len(a.raw) < 4 {
+			return avroErrorf("snappy: block too short")
+		}
+		src := a.raw[:len(a.raw)-4]
+		var n int
+		n, err = snappy.DecodedLen(src)
+		if err != nil {
+			break
+		}
+		if n > maxAvroBlock {
+			return avroErrorf("decompressed block larger than %d bytes", maxAvroBlock)
+		}
+		a.block, err = snappy.Decode(slices.Grow(a.block[:0], n)[:n], src)
+		if err == nil && crc32.ChecksumIEEE(a.block) != binary.BigEndian.Uint32(a.raw[len(src):]) {
+			return avroErrorf("snappy: checksum mismatch")
+		}
+	case "zstandard":
+		a.block, err = compr.DecodeZstd(a.raw, a.block[:0])
+	}
+	if err != nil {
+		return avroErrorf("%s: %s", a.codec, err)
+	}
+	if len(a.block) > maxAvroBlock {
+		return avroErrorf("decompressed block larger than %d bytes", maxAvroBlock)
+	}
+	return nil
+}
+
+// avroPlan is an avroSchema at a particular
+// path in the output; plans are built lazily
+// because a recursive schema can describe
+// values of any depth
+type avroPlan struct {
+	schema *avroSchema
+	path   []string
+	plain  bool // no array or map ancestors
+	ignore bool
+	index  bool
+	// children are the plans for record fields,
+	// union branches, or array items and map values
+	children []*avroPlan
+
+	// syms are the symbols for the fields of
+	// a record and order is the order in which
+	// they are written; they are recomputed
+	// whenever the symbol table changes
+	syms  []ion.Symbol
+	order []int
+	// starts are the offsets of the fields
+	// of the record being written
+	starts []int
+	// entries are the keys of the map
+	// being written and their values
+	entries []avroEntry
+}
+
+type avroEntry struct {
+	sym ion.Symbol
+	off int
+}
+
+// avroWriter writes the values of
+// an avro file to an ion.Chunker
+type avroWriter struct {
+	dst   *ion.Chunker
+	hints *jsonrl.Hint
+	root  *avroPlan
+	depth int
+	// path is the list of symbols leading
+	// to the current record field
+	path   []ion.Symbol
+	symbuf ion.Symbuf
+}
+
+func (w *avroWriter) plan(s *avroSchema, path []string, plain bool) *avroPlan {
+	p := &avroPlan{schema: s, path: path, plain: plain}
+	noindex := false
+	if w.hints != nil && len(path) > 0 {
+		p.ignore, noindex = w.hints.Lookup(path)
+	}
+	p.index = plain && !noindex && s.isTime() && len(path) < jsonrl.MaxIndexingDepth
+	return p
+}
+
+// child returns the i-th child plan of p
+func (w *avroWriter) child(p *avroPlan, i int) *avroPlan {
+	s := p.schema
+	if p.children == nil {
+		switch s.typ {
+		case avroRecord:
+			p.children = make([]*avroPlan, len(s.fields))
+		case avroUnion:
+			p.children = make([]*avroPlan, len(s.branches))
+		default:
+			p.children = make([]*avroPlan, 1)
+		}
+	}
+	if c := p.children[i]; c != nil {
+		return c
+	}
+	var c *avroPlan
+	switch s.typ {
+	case avroRecord:
+		c = w.plan(s.fields[i].typ, append(p.path[:len(p.path):len(p.path)], s.fields[i].name), p.plain)
+	case avroUnion:
+		c = w.plan(s.branches[i], p.path, p.plain)
+	default:
+		c = w.plan(s.items, append(p.path[:len(p.path):len(p.path)], ""), false)
+	}
+	p.children[i] = c
+	return c
+}
+
+// symbolize makes sure that p.syms holds the
+// symbols for the fields of a record that are
+// not ignored
+func (w *avroWriter) symbolize(p *avroPlan) {
+	st := &w.dst.Symbols
+	fields := p.schema.fields
+	same := len(p.syms) == len(fields)
+	for j := 0; same && j < len(p.order); j++ {
+		i := p.order[j]
+		same = st.Get(p.syms[i]) == fields[i].name
+	}
+	if same {
+		return
+	}
+	p.syms = p.syms[:0]
+	p.order = p.order[:0]
+	for i := range fields {
+		var sym ion.Symbol
+		if !w.child(p, i).ignore {
+			sym = st.Intern(fields[i].name)
+			p.order = append(p.order, i)
+		}
+		p.syms = append(p.syms, sym)
+	}
+	slices.SortStableFunc(p.order, func(i, j int) bool {
+		return p.syms[i] < p.syms[j]
+	})
+}
+
+func readAvroLong(buf []byte, off int) (int64, int, error) {
+	u, n := binary.Uvarint(buf[off:])
+	if n <= 0 {
+		return 0, off, avroErrorf("invalid varint at offset %d", off)
+	}
+	return int64(u>>1) ^ -int64(u&1), off + n, nil
+}
+
+func readAvroFixed(buf []byte, off, n int) ([]byte, int, error) {
+	if n > len(buf)-off {
+		return nil, off, avroErrorf("unexpected end of block")
+	}
+	return buf[off : off+n], off + n, nil
+}
+
+func readAvroBytes(buf []byte, off int) ([]byte, int, error) {
+	n, off, err := readAvroLong(buf, off)
+	if err != nil {
+		return nil, off, err
+	}
+	if n < 0 || n > int64(len(buf)-off) {
+		return nil, off, avroErrorf("invalid length %d", n)
+	}
+	return buf[off : off+int(n)], off + int(n), nil
+}
+
+// avroItems calls fn for each item of
+// the array or map that starts at off
+func avroItems(buf []byte, off int, fn func(off int) (int, error)) (int, error) {
+	total := int64(0)
+	for {
+		n, next, err := readAvroLong(buf, off)
+		if err != nil {
+			return next, err
+		}
+		off = next
+		if n == 0 {
+			return off, nil
+		}
+		if n < 0 {
+			n = -n
+			// block size in bytes
+			_, off, err = readAvroLong(buf, off)
+			if err != nil {
+				return off, err
+			}
+		}
+		// items may have no encoded size (i.e. null),
+		// so the number of items is bounded by the
+		// size of the block to keep this from spinning
+		total += n
+		if n < 0 || total > int64(len(buf)) {
+			return off, avroErrorf("invalid item count %d", n)
+		}
+		for ; n > 0; n-- {
+			off, err = fn(off)
+			if err != nil {
+				return off, err
+			}
+		}
+	}
+}
+
+// avroBranch reads the index of the branch
+// of the union s that starts at off
+func avroBranch(s *avroSchema, buf []byte, off int) (int, int, error) {
+	i, off, err := readAvroLong(buf, off)
+	if err != nil {
+		return 0, off, err
+	}
+	if i < 0 || i >= int64(len(s.branches)) {
+		return 0, off, avroErrorf("invalid union branch %d", i)
+	}
+	return int(i), off, nil
+}
+
+// avroIsNull returns whether the value of s
+// at off is null
+func avroIsNull(s *avroSchema, buf []byte, off int) bool {
+	switch s.typ {
+	case avroNull:
+		return true
+	case avroUnion:
+		i, _, err := avroBranch(s, buf, off)
+		return err == nil && s.branches[i].typ == avroNull
+	}
+	return false
+}
+
+func (w *avroWriter) enter() error {
+	if w.depth >= maxAvroDepth {
+		return avroErrorf("value nested more than %d levels deep", maxAvroDepth)
+	}
+	w.depth++
+	return nil
+}
+
+// skip returns the offset following
+// the value of s that starts at off
+func (w *avroWriter) skip(s *avroSchema, buf []byte, off int) (int, error) {
+	var err error
+	switch s.typ {
+	case avroNull:
+		return off, nil
+	case avroBoolean:
+		_, off, err = readAvroFixed(buf, off, 1)
+		return off, err
+	case avroInt, avroLong, avroEnum:
+		_, off, err = readAvroLong(buf, off)
+		return off, err
+	case avroFloat:
+		_, off, err = readAvroFixed(buf, off, 4)
+		return off, err
+	case avroDouble:
+		_, off, err = readAvroFixed(buf, off, 8)
+		return off, err
+	case avroBytes, avroString:
+		_, off, err = readAvroBytes(buf, off)
+		return off, err
+	case avroFixed:
+		_, off, err = readAvroFixed(buf, off, s.size)
+		return off, err
+	}
+	if err := w.enter(); err != nil {
+		return off, err
+	}
+	defer func() { w.depth-- }()
+	switch s.typ {
+	case avroRecord:
+		for i := range s.fields {
+			off, err = w.skip(s.fields[i].typ, buf, off)
+			if err != nil {
+				return off, err
+			}
+		}
+		return off, nil
+	case avroArray:
+		return avroItems(buf, off, func(off int) (int, error) {
+			return w.skip(s.items, buf, off)
+		})
+	case avroMap:
+		return avroItems(buf, off, func(off int) (int, error) {
+			_, off, err := readAvroBytes(buf, off)
+			if err != nil {
+				return off, err
+			}
+			return w.skip(s.items, buf, off)
+		})
+	case avroUnion:
+		var i int
+		i, off, err = avroBranch(s, buf, off)
+		if err != nil {
+			return off, err
+		}
+		return w.skip(s.branches[i], buf, off)
+	}
+	return off, avroErrorf("unexpected type %d", s.typ)
+}
+
+// writeDatum writes the top-level value
+// that starts at off as one record
+func (w *avroWriter) writeDatum(buf []byte, off int) (int, error) {
+	p := w.root
+	for p.schema.typ == avroUnion {
+		i, next, err := avroBranch(p.schema, buf, off)
+		if err != nil {
+			return next, err
+		}
+		off = next
+		p = w.child(p, i)
+	}
+	if p.schema.typ != avroRecord {
+		return off, avroErrorf("top-level value is not a record")
+	}
+	off, err := w.writeRecord(p, buf, off)
+	if err != nil {
+		return off, err
+	}
+	return off, w.dst.Commit()
+}
+
+func (w *avroWriter) writeRecord(p *avroPlan, buf []byte, off int) (int, error) {
+	if err := w.enter(); err != nil {
+		return off, err
+	}
+	defer func() { w.depth-- }()
+	// fields are encoded in schema order
+	// but written in symbol order, so
+	// find where each of them starts first
+	var err error
+	fields := p.schema.fields
+	p.starts = p.starts[:0]
+	for i := range fields {
+		p.starts = append(p.starts, off)
+		off, err = w.skip(fields[i].typ, buf, off)
+		if err != nil {
+			return off, err
+		}
+	}
+	w.symbolize(p)
+	b := &w.dst.Buffer
+	b.BeginStruct(-1)
+	for _, i := range p.order {
+		c := w.child(p, i)
+		start := p.starts[i]
+		if avroIsNull(c.schema, buf, start) {
+			continue
+		}
+		b.BeginField(p.syms[i])
+		w.path = append(w.path, p.syms[i])
+		_, err = w.write(c, buf, start)
+		w.path = w.path[:len(w.path)-1]
+		if err != nil {
+			return off, err
+		}
+	}
+	b.EndStruct()
+	return off, nil
+}
+
+func (w *avroWriter) writeMap(p *avroPlan, buf []byte, off int) (int, error) {
+	c := w.child(p, 0)
+	st := &w.dst.Symbols
+	p.entries = p.entries[:0]
+	off, err := avroItems(buf, off, func(off int) (int, error) {
+		key, off, err := readAvroBytes(buf, off)
+		if err != nil {
+			return off, err
+		}
+		p.entries = append(p.entries, avroEntry{sym: st.InternBytes(key), off: off})
+		return w.skip(c.schema, buf, off)
+	})
+	if err != nil {
+		return off, err
+	}
+	slices.SortStableFunc(p.entries, func(x, y avroEntry) bool {
+		return x.sym < y.sym
+	})
+	b := &w.dst.Buffer
+	b.BeginStruct(-1)
+	var prev ion.Symbol
+	for i := range p.entries {
+		e := p.entries[i]
+		if i > 0 && e.sym == prev {
+			// duplicate key; the first one wins
+			continue
+		}
+		prev = e.sym
+		if c.ignore || avroIsNull(c.schema, buf, e.off) {
+			continue
+		}
+		b.BeginField(e.sym)
+		_, err = w.write(c, buf, e.off)
+		if err != nil {
+			return off, err
+		}
+	}
+	b.EndStruct()
+	return off, nil
+}
+
+// write writes the value of p at off
+// and returns the offset following it
+func (w *avroWriter) write(p *avroPlan, buf []byte, off int) (int, error) {
+	s := p.schema
+	b := &w.dst.Buffer
+	var err error
+	var data []byte
+	switch s.typ {
+	case avroNull:
+		b.WriteNull()
+		return off, nil
+	case avroBoolean:
+		data, off, err = readAvroFixed(buf, off, 1)
+		if err == nil {
+			b.WriteBool(data[0] != 0)
+		}
+		return off, err
+	case avroInt, avroLong:
+		var v int64
+		v, off, err = readAvroLong(buf, off)
+		if err != nil {
+			return off, err
+		}
+		if s.isTime() {
+			w.writeTime(p, v)
+		} else if s.logical == "decimal" && s.scale != 0 {
+			b.WriteFloat64(float64(v) / math.Pow10(s.scale))
+		} else {
+			b.WriteInt(v)
+		}
+		return off, nil
+	case avroFloat:
+		data, off, err = readAvroFixed(buf, off, 4)
+		if err == nil {
+			b.WriteFloat64(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))))
+		}
+		return off, err
+	case avroDouble:
+		data, off, err = readAvroFixed(buf, off, 8)
+		if err == nil {
+			b.WriteFloat64(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		}
+		return off, err
+	case avroString:
+		data, off, err = readAvroBytes(buf, off)
+		if err == nil {
+			b.BeginString(len(data))
+			b.UnsafeAppend(data)
+		}
+		return off, err
+	case avroBytes, avroFixed:
+		if s.typ == avroFixed {
+			data, off, err = readAvroFixed(buf, off, s.size)
+		} else {
+			data, off, err = readAvroBytes(buf, off)
+		}
+		if err != nil {
+			return off, err
+		}
+		if s.logical == "decimal" {
+			writeDecimal(b, data, s.scale)
+		} else {
+			b.WriteBlob(data)
+		}
+		return off, nil
+	case avroEnum:
+		var i int64
+		i, off, err = readAvroLong(buf, off)
+		if err != nil {
+			return off, err
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return off, avroErrorf("invalid enum index %d", i)
+		}
+		b.WriteSymbol(w.dst.Symbols.Intern(s.symbols[i]))
+		return off, nil
+	case avroRecord:
+		return w.writeRecord(p, buf, off)
+	}
+	if err := w.enter(); err != nil {
+		return off, err
+	}
+	defer func() { w.depth-- }()
+	switch s.typ {
+	case avroArray:
+		c := w.child(p, 0)
+		b.BeginList(-1)
+		off, err = avroItems(buf, off, func(off int) (int, error) {
+			if c.ignore {
+				return w.skip(c.schema, buf, off)
+			}
+			return w.write(c, buf, off)
+		})
+		b.EndList()
+		return off, err
+	case avroMap:
+		return w.writeMap(p, buf, off)
+	case avroUnion:
+		var i int
+		i, off, err = avroBranch(s, buf, off)
+		if err != nil {
+			return off, err
+		}
+		return w.write(w.child(p, i), buf, off)
+	}
+	return off, avroErrorf("unexpected type %d", s.typ)
+}
+
+// writeDecimal writes a big-endian two's
+// complement integer scaled by 10^-scale
+func writeDecimal(b *ion.Buffer, data []byte, scale int) {
+	v := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+	if scale == 0 && v.IsInt64() {
+		b.WriteInt(v.Int64())
+		return
+	}
+	f, _ := new(big.Float).SetInt(v).Float64()
+	b.WriteFloat64(f / math.Pow10(scale))
+}
+
+func (w *avroWriter) writeTime(p *avroPlan, v int64) {
+	var t date.Time
+	switch p.schema.logical {
+	case "date":
+		t = date.Unix(v*86400, 0)
+	case "timestamp-millis", "local-timestamp-millis":
+		t = date.Unix(v/1000, (v%1000)*1e6)
+	case "timestamp-micros", "local-timestamp-micros":
+		t = date.UnixMicro(v)
+	default:
+		t = date.Unix(0, v)
+	}
+	w.dst.Buffer.WriteTime(t)
+	if !p.index {
+		return
+	}
+	w.symbuf.Prepare(len(w.path))
+	for i := range w.path {
+		w.symbuf.Push(w.path[i])
+	}
+	w.dst.Ranges.AddTime(w.symbuf, t)
+}
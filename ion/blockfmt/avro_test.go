@@ -0,0 +1,297 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// avroEncoder produces avro binary data
+type avroEncoder struct {
+	buf []byte
+}
+
+func (e *avroEncoder) long(v int64) *avroEncoder {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64((v<<1)^(v>>63)))
+	e.buf = append(e.buf, tmp[:n]...)
+	return e
+}
+
+func (e *avroEncoder) bytes(b []byte) *avroEncoder {
+	e.long(int64(len(b)))
+	e.buf = append(e.buf, b...)
+	return e
+}
+
+func (e *avroEncoder) string(s string) *avroEncoder {
+	return e.bytes([]byte(s))
+}
+
+func (e *avroEncoder) double(f float64) *avroEncoder {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(f))
+	e.buf = append(e.buf, tmp[:]...)
+	return e
+}
+
+func (e *avroEncoder) boolean(b bool) *avroEncoder {
+	if b {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+	return e
+}
+
+func (e *avroEncoder) raw(b ...byte) *avroEncoder {
+	e.buf = append(e.buf, b...)
+	return e
+}
+
+var testAvroSync = []byte("0123456789abcdef")
+
+// avroFile produces an object container file
+// with one block for each list of records
+func avroFile(t *testing.T, schema, codec string, blocks ...[][]byte) []byte {
+	var e avroEncoder
+	e.raw(avroMagic...)
+	e.long(2)
+	e.string("avro.schema").string(schema)
+	e.string("avro.codec").string(codec)
+	e.long(0)
+	e.raw(testAvroSync...)
+	for _, recs := range blocks {
+		data := bytes.Join(recs, nil)
+		switch codec {
+		case "null":
+		case "deflate":
+			var out bytes.Buffer
+			fw, _ := flate.NewWriter(&out, flate.BestCompression)
+			fw.Write(data)
+			fw.Close()
+			data = out.Bytes()
+		case "snappy":
+			sum := crc32.ChecksumIEEE(data)
+			data = snappy.Encode(nil, data)
+			data = append(data, byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum))
+		case "zstandard":
+			enc, _ := zstd.NewWriter(nil)
+			data = enc.EncodeAll(data, nil)
+			enc.Close()
+		default:
+			t.Fatalf("unknown codec %q", codec)
+		}
+		e.long(int64(len(recs)))
+		e.bytes(data)
+		e.raw(testAvroSync...)
+	}
+	return e.buf
+}
+
+const testAvroSchema = `{
+  "type": "record", "name": "Event", "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "long"},
+    {"name": "name", "type": ["null", "string"]},
+    {"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "day", "type": {"type": "int", "logicalType": "date"}},
+    {"name": "score", "type": "double"},
+    {"name": "ok", "type": "boolean"},
+    {"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "attrs", "type": {"type": "map", "values": ["null", "int"]}},
+    {"name": "raw", "type": {"type": "fixed", "name": "Raw", "size": 2}},
+    {"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+    {"name": "next", "type": ["null", "Event"]}
+  ]
+}`
+
+// event encodes a record with testAvroSchema;
+// next is written only if it is not nil
+func event(id int64, name string, at int64, next []byte) []byte {
+	var e avroEncoder
+	e.long(id)
+	if name == "" {
+		e.long(0)
+	} else {
+		e.long(1).string(name)
+	}
+	e.long(at)
+	e.long(at / 86400000)
+	e.double(float64(id) / 2)
+	e.boolean(id%2 == 0)
+	e.long(id % 2)
+	// tags as a block with a byte size
+	// followed by a regular block
+	var tags avroEncoder
+	tags.string("x").string("y")
+	e.long(-2).long(int64(len(tags.buf))).raw(tags.buf...)
+	e.long(1).string("z").long(0)
+	// duplicate and null map values
+	e.long(3)
+	e.string("b").long(1).long(id)
+	e.string("a").long(0)
+	e.string("b").long(1).long(-id)
+	e.long(0)
+	e.raw('h', 'i')
+	e.bytes([]byte{0xff, 0x38}) // -200
+	if next == nil {
+		e.long(0)
+	} else {
+		e.long(1).raw(next...)
+	}
+	return e.buf
+}
+
+func TestConvertAvro(t *testing.T) {
+	t0 := int64(1664625600000) // 2022-10-01T12:00:00Z
+	recs := [][]byte{
+		event(1, "first", t0, nil),
+		event(2, "", t0+86400000, event(3, "inner", t0, nil)),
+	}
+	want := []string{
+		`{"name": "first", "id": 1, "at": "2022-10-01T12:00:00Z", "day": "2022-10-01T00:00:00Z", "score": 0.5, "ok": false, "kind": "B", "tags": ["x", "y", "z"], "attrs": {"b": 1}, "raw": "aGk=", "price": -2}`,
+		`{"id": 2, "at": "2022-10-02T12:00:00Z", "day": "2022-10-02T00:00:00Z", "score": 1, "ok": true, "kind": "A", "tags": ["x", "y", "z"], "attrs": {"b": 2}, "raw": "aGk=", "price": -2, "next": {"name": "inner", "id": 3, "at": "2022-10-01T12:00:00Z", "day": "2022-10-01T00:00:00Z", "score": 1.5, "ok": false, "kind": "B", "tags": ["x", "y", "z"], "attrs": {"b": 3}, "raw": "aGk=", "price": -2}}`,
+	}
+	for _, codec := range []string{"null", "deflate", "snappy", "zstandard"} {
+		t.Run(codec, func(t *testing.T) {
+			file := avroFile(t, testAvroSchema, codec, recs[:1], recs[1:])
+			rows, err := convertRows(t, ".avro", "", file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(want) {
+				t.Fatalf("got rows %s, want %s", rows, want)
+			}
+			for i := range rows {
+				if rows[i] != want[i] {
+					t.Errorf("row %d:\ngot  %s\nwant %s", i, rows[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// avroRanges collects the ranges
+// written by the converter
+type avroRanges struct {
+	bytes.Buffer
+	ranges map[string][2]date.Time
+}
+
+func (r *avroRanges) SetMinMax(path []string, min, max ion.Datum) {
+	lo, _ := min.Timestamp()
+	hi, _ := max.Timestamp()
+	if r.ranges == nil {
+		r.ranges = make(map[string][2]date.Time)
+	}
+	r.ranges[strings.Join(path, ".")] = [2]date.Time{lo, hi}
+}
+
+func TestConvertAvroHints(t *testing.T) {
+	t0 := int64(1664625600000)
+	file := avroFile(t, testAvroSchema, "null", [][]byte{
+		event(1, "first", t0, nil),
+		event(2, "", t0+86400000, event(3, "inner", t0-86400000, nil)),
+	})
+	f := SuffixToFormat[".avro"]()
+	err := f.UseHints([]byte(`{"tags": "ignore", "attrs.*": "ignore", "next.day": "no_index"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out avroRanges
+	cn := ion.Chunker{Align: 4096, W: &out}
+	err = f.Convert(bytes.NewReader(file), &cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cn.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := jsonRows(t, out.Bytes())
+	want := []string{
+		`{"name": "first", "id": 1, "at": "2022-10-01T12:00:00Z", "day": "2022-10-01T00:00:00Z", "score": 0.5, "ok": false, "kind": "B", "attrs": {}, "raw": "aGk=", "price": -2}`,
+		`{"id": 2, "at": "2022-10-02T12:00:00Z", "day": "2022-10-02T00:00:00Z", "score": 1, "ok": true, "kind": "A", "attrs": {}, "raw": "aGk=", "price": -2, "next": {"name": "inner", "id": 3, "at": "2022-09-30T12:00:00Z", "day": "2022-09-30T00:00:00Z", "score": 1.5, "ok": false, "kind": "B", "attrs": {"b": 3}, "raw": "aGk=", "price": -2, "tags": ["x", "y", "z"]}}`,
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got rows %s, want %s", rows, want)
+	}
+	for i := range rows {
+		if rows[i] != want[i] {
+			t.Errorf("row %d:\ngot  %s\nwant %s", i, rows[i], want[i])
+		}
+	}
+	ranges := map[string][2]date.Time{
+		"at":      {date.Date(2022, 10, 1, 12, 0, 0, 0), date.Date(2022, 10, 2, 12, 0, 0, 0)},
+		"next.at": {date.Date(2022, 9, 30, 12, 0, 0, 0), date.Date(2022, 9, 30, 12, 0, 0, 0)},
+		"day":     {date.Date(2022, 10, 1, 0, 0, 0, 0), date.Date(2022, 10, 2, 0, 0, 0, 0)},
+	}
+	if len(out.ranges) != len(ranges) {
+		t.Fatalf("got ranges %v, want %v", out.ranges, ranges)
+	}
+	for path, r := range ranges {
+		got, ok := out.ranges[path]
+		if !ok || !got[0].Equal(r[0]) || !got[1].Equal(r[1]) {
+			t.Errorf("path %s: got %v, want %v", path, got, r)
+		}
+	}
+}
+
+func TestConvertAvroErrors(t *testing.T) {
+	rec := event(1, "first", 0, nil)
+	file := avroFile(t, testAvroSchema, "snappy", [][]byte{rec})
+	bad := map[string][]byte{
+		"magic":        append([]byte("Obj\x02"), file[4:]...),
+		"truncated":    file[:len(file)-1],
+		"sync":         append(file[:len(file)-1:len(file)-1], 'x'),
+		"schema":       avroFile(t, `{"type": "record", "name": "X", "fields": [{"name": "a", "type": "Y"}]}`, "null", [][]byte{rec}),
+		"not a record": avroFile(t, `"long"`, "null", [][]byte{{2}}),
+		"trailing":     avroFile(t, testAvroSchema, "null", [][]byte{append(rec[:len(rec):len(rec)], 0)}),
+		"branch":       avroFile(t, `{"type": "record", "name": "X", "fields": [{"name": "a", "type": ["null", "long"]}]}`, "null", [][]byte{{4}}),
+		"items":        avroFile(t, `{"type": "record", "name": "X", "fields": [{"name": "a", "type": {"type": "array", "items": "null"}}]}`, "null", [][]byte{{0xfe, 0xff, 0xff, 0xff, 0x0f}}),
+		"checksum":     append(append(file[:len(file)-20:len(file)-20], 'x', 'y', 'z', 'w'), testAvroSync...),
+	}
+	for name, buf := range bad {
+		_, err := convertRows(t, ".avro", "", buf)
+		if err == nil || !IsFatal(err) {
+			t.Errorf("%s: expected a fatal error; got %v", name, err)
+		}
+	}
+	// a recursive schema with deeply nested data
+	var deep avroEncoder
+	for i := 0; i < 2*maxAvroDepth; i++ {
+		deep.long(1)
+	}
+	deep.long(0)
+	schema := `{"type": "record", "name": "L", "fields": [{"name": "next", "type": ["null", "L"]}]}`
+	_, err := convertRows(t, ".avro", "", avroFile(t, schema, "null", [][]byte{deep.buf}))
+	if err == nil || !IsFatal(err) {
+		t.Errorf("expected a fatal error; got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type avroType uint8
+
+const (
+	avroNull avroType = iota
+	avroBoolean
+	avroInt
+	avroLong
+	avroFloat
+	avroDouble
+	avroBytes
+	avroString
+	avroRecord
+	avroEnum
+	avroArray
+	avroMap
+	avroUnion
+	avroFixed
+)
+
+var avroPrimitives = map[string]avroType{
+	"null":    avroNull,
+	"boolean": avroBoolean,
+	"int":     avroInt,
+	"long":    avroLong,
+	"float":   avroFloat,
+	"double":  avroDouble,
+	"bytes":   avroBytes,
+	"string":  avroString,
+}
+
+// maxAvroSchemaDepth is the maximum
+// nesting depth of an avro schema
+const maxAvroSchemaDepth = 64
+
+// avroSchema is a parsed avro schema;
+// named types may refer to themselves,
+// so the graph of schemas may have cycles
+type avroSchema struct {
+	typ     avroType
+	name    string // full name of a named type
+	logical string // logicalType attribute
+	scale   int    // for the decimal logical type
+
+	fields   []avroField   // record fields
+	symbols  []string      // enum symbols
+	items    *avroSchema   // array items or map values
+	branches []*avroSchema // union branches
+	size     int           // size of a fixed
+}
+
+type avroField struct {
+	name string
+	typ  *avroSchema
+}
+
+// isTime returns whether values of s
+// are written as timestamps
+func (s *avroSchema) isTime() bool {
+	switch s.typ {
+	case avroInt:
+		return s.logical == "date"
+	case avroLong:
+		switch s.logical {
+		case "timestamp-millis", "timestamp-micros", "timestamp-nanos",
+			"local-timestamp-millis", "local-timestamp-micros", "local-timestamp-nanos":
+			return true
+		}
+	}
+	return false
+}
+
+// avroSchemaParser parses the JSON
+// representation of an avro schema
+type avroSchemaParser struct {
+	named map[string]*avroSchema
+}
+
+func parseAvroSchema(text []byte) (*avroSchema, error) {
+	var v interface{}
+	err := json.Unmarshal(text, &v)
+	if err != nil {
+		return nil, fmt.Errorf("avro schema: %s: %w", err, errAvroFormat)
+	}
+	p := avroSchemaParser{named: make(map[string]*avroSchema)}
+	return p.parse(v, "", 0)
+}
+
+func (p *avroSchemaParser) errorf(f string, args ...interface{}) error {
+	return fmt.Errorf("avro schema: "+f+": %w", append(args, errAvroFormat)...)
+}
+
+// fullname returns the full name of a named type
+func fullname(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func (p *avroSchemaParser) parse(v interface{}, namespace string, depth int) (*avroSchema, error) {
+	if depth > maxAvroSchemaDepth {
+		return nil, p.errorf("schema nested too deeply")
+	}
+	switch v := v.(type) {
+	case string:
+		if t, ok := avroPrimitives[v]; ok {
+			return &avroSchema{typ: t}, nil
+		}
+		if s := p.named[fullname(v, namespace)]; s != nil {
+			return s, nil
+		}
+		if s := p.named[v]; s != nil {
+			return s, nil
+		}
+		return nil, p.errorf("unknown type %q", v)
+	case []interface{}:
+		s := &avroSchema{typ: avroUnion}
+		for i := range v {
+			b, err := p.parse(v[i], namespace, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			s.branches = append(s.branches, b)
+		}
+		if len(s.branches) == 0 {
+			return nil, p.errorf("empty union")
+		}
+		return s, nil
+	case map[string]interface{}:
+		return p.parseObject(v, namespace, depth)
+	}
+	return nil, p.errorf("unexpected %T", v)
+}
+
+func (p *avroSchemaParser) parseObject(v map[string]interface{}, namespace string, depth int) (*avroSchema, error) {
+	t, ok := v["type"].(string)
+	if !ok {
+		// {"type": {...}} or {"type": [...]}
+		return p.parse(v["type"], namespace, depth+1)
+	}
+	s := &avroSchema{}
+	s.logical, _ = v["logicalType"].(string)
+	if scale, ok := v["scale"].(float64); ok {
+		s.scale = int(scale)
+	}
+	if prim, ok := avroPrimitives[t]; ok {
+		s.typ = prim
+		return s, nil
+	}
+	switch t {
+	case "record", "error", "enum", "fixed":
+		name, _ := v["name"].(string)
+		if name == "" {
+			return nil, p.errorf("%s without a name", t)
+		}
+		if ns, ok := v["namespace"].(string); ok {
+			namespace = ns
+		}
+		s.name = fullname(name, namespace)
+		if i := strings.LastIndexByte(s.name, '.'); i >= 0 {
+			namespace = s.name[:i]
+		}
+		// register the name first so that
+		// a record can refer to itself
+		p.named[s.name] = s
+	}
+	switch t {
+	case "record", "error":
+		s.typ = avroRecord
+		fields, _ := v["fields"].([]interface{})
+		for i := range fields {
+			f, _ := fields[i].(map[string]interface{})
+			name, _ := f["name"].(string)
+			if name == "" {
+				return nil, p.errorf("record %s: field without a name", s.name)
+			}
+			ft, err := p.parse(f["type"], namespace, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			s.fields = append(s.fields, avroField{name: name, typ: ft})
+		}
+	case "enum":
+		s.typ = avroEnum
+		symbols, _ := v["symbols"].([]interface{})
+		for i := range symbols {
+			sym, _ := symbols[i].(string)
+			s.symbols = append(s.symbols, sym)
+		}
+	case "fixed":
+		s.typ = avroFixed
+		size, _ := v["size"].(float64)
+		if size < 0 || size > 1<<20 {
+			return nil, p.errorf("fixed %s has size %v", s.name, size)
+		}
+		s.size = int(size)
+	case "array", "map":
+		s.typ = avroArray
+		key := "items"
+		if t == "map" {
+			s.typ = avroMap
+			key = "values"
+		}
+		items, err := p.parse(v[key], namespace, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		s.items = items
+	default:
+		return nil, p.errorf("unknown type %q", t)
+	}
+	return s, nil
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"archive/zip"
+	"compress/bzip2"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/snappy"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// codec is a compression or archive format
+// that can be applied to the input of any RowFormat
+type codec struct {
+	// name is the suffix that the codec
+	// adds to the name of a format,
+	// i.e. "gz" in "json.gz"
+	name string
+	// decode calls convert with the decoded
+	// contents of r, or with each member
+	// of r in turn if r is an archive;
+	// tmp determines where (and how large)
+	// a temporary copy of r can be written
+	decode func(r io.Reader, tmp *tempfiles, convert func(r io.Reader) error) error
+}
+
+// codecs is the list of codecs that
+// can be applied to every row format
+var codecs = []codec{
+	{name: "zst", decode: stream(zstdDecomp)},
+	{name: "gz", decode: stream(gzipDecomp)},
+	{name: "bz2", decode: stream(bzip2Decomp)},
+	{name: "xz", decode: stream(xzDecomp)},
+	{name: "lz4", decode: stream(lz4Decomp)},
+	{name: "sz", decode: stream(snappyDecomp)},
+	{name: "zip", decode: zipMembers},
+}
+
+// stream produces the decode function
+// of a codec from a stream decompressor
+func stream(decomp func(r io.Reader) (io.Reader, error)) func(io.Reader, *tempfiles, func(io.Reader) error) error {
+	return func(r io.Reader, _ *tempfiles, convert func(r io.Reader) error) error {
+		return decompress(r, decomp, convert)
+	}
+}
+
+func bzip2Decomp(r io.Reader) (io.Reader, error) {
+	return bzip2.NewReader(r), nil
+}
+
+// snappyDecomp decompresses the snappy framing
+// format (not raw snappy blocks, which
+// cannot be streamed)
+func snappyDecomp(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+// zipMembers calls convert with each of the
+// files in the zip archive r in the order
+// in which they appear in the archive;
+// directories are skipped
+func zipMembers(r io.Reader, tmp *tempfiles, convert func(r io.Reader) error) error {
+	return readerAt(r, "zip", tmp, func(ra io.ReaderAt, size int64) error {
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			return fmt.Errorf("zip: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.Mode().IsDir() {
+				continue
+			}
+			err := zipMember(f, convert)
+			if err != nil {
+				return fmt.Errorf("zip member %s: %w", f.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func zipMember(f *zip.File, convert func(r io.Reader) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	err = convert(rc)
+	if err != nil {
+		return err
+	}
+	// the checksum of a member is only
+	// checked once all of it has been read
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// DefaultMaxTempSize is the default maximum
+// size of a temporary copy of an input
+// (see Converter.MaxTempSize)
+const DefaultMaxTempSize = 4 << 30
+
+// ErrTempTooLarge is returned when an input
+// that has to be copied to a temporary file
+// is larger than the maximum size of the copy
+var ErrTempTooLarge = errors.New("input too large for a temporary copy")
+
+// tempfiles determines where temporary copies
+// of inputs are written and how large they can be
+type tempfiles struct {
+	dir     string
+	maxsize int64
+}
+
+// tempUser is implemented by RowFormats that
+// may write a temporary copy of their input
+type tempUser interface {
+	useTemp(tmp *tempfiles)
+}
+
+// readerAt calls fn with r if it can be read
+// at arbitrary offsets, or otherwise with a
+// temporary file holding a copy of r; name
+// is the name of the format for error messages
+func readerAt(r io.Reader, name string, tmp *tempfiles, fn func(ra io.ReaderAt, size int64) error) error {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		size, err := ra.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		return fn(ra, size)
+	}
+	dir := ""
+	maxsize := int64(DefaultMaxTempSize)
+	if tmp != nil {
+		dir = tmp.dir
+		if tmp.maxsize > 0 {
+			maxsize = tmp.maxsize
+		}
+	}
+	f, err := os.CreateTemp(dir, name+"-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, io.LimitReader(r, maxsize+1))
+	if err != nil {
+		return err
+	}
+	if size > maxsize {
+		return fmt.Errorf("%s: more than %d bytes: %w", name, maxsize, ErrTempTooLarge)
+	}
+	return fn(f, size)
+}
+
+// compressed is a RowFormat that decodes
+// its input with a codec before passing
+// it to another RowFormat
+type compressed struct {
+	format RowFormat
+	codec  *codec
+	errors int64
+	tmp    *tempfiles
+}
+
+func (c *compressed) Name() string {
+	return c.format.Name() + "." + c.codec.name
+}
+
+func (c *compressed) UseHints(hints []byte) error {
+	return c.format.UseHints(hints)
+}
+
+func (c *compressed) Convert(r io.Reader, dst *ion.Chunker) error {
+	c.errors = 0
+	return c.codec.decode(r, c.tmp, func(r io.Reader) error {
+		err := c.format.Convert(r, dst)
+		if pc, ok := c.format.(ParseErrorCounter); ok {
+			c.errors += pc.ParseErrors()
+		}
+		return err
+	})
+}
+
+func (c *compressed) useTemp(tmp *tempfiles) {
+	c.tmp = tmp
+	if tu, ok := c.format.(tempUser); ok {
+		tu.useTemp(tmp)
+	}
+}
+
+// ParseErrors implements ParseErrorCounter;
+// it is the total for all the members
+// of an archive
+func (c *compressed) ParseErrors() int64 { return c.errors }
+
+// withCodec returns f wrapped in the codec
+// for the suffix (i.e. ".gz"), or f itself if
+// the suffix is empty, or nil if the suffix
+// is not the suffix of a known codec
+func withCodec(f RowFormat, suffix string) RowFormat {
+	if suffix == "" {
+		return f
+	}
+	for i := range codecs {
+		if suffix == "."+codecs[i].name {
+			return &compressed{format: f, codec: &codecs[i]}
+		}
+	}
+	return nil
+}
+
+// withCodecs adds the combination of each
+// of the formats with each codec to formats
+func withCodecs(formats map[string]func() RowFormat) map[string]func() RowFormat {
+	var suffixes []string
+	for suffix := range formats {
+		suffixes = append(suffixes, suffix)
+	}
+	for _, suffix := range suffixes {
+		cons := formats[suffix]
+		for i := range codecs {
+			c := &codecs[i]
+			formats[suffix+"."+c.name] = func() RowFormat {
+				return &compressed{format: cons(), codec: c}
+			}
+		}
+	}
+	return formats
+}
@@ -0,0 +1,338 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// mixedData produces the uncompressed contents
+// of testdata/codecs/mixed.{xz,lz4}, which
+// starts with incompressible data so that
+// there are uncompressed blocks and chunks
+func mixedData(t *testing.T) []byte {
+	out := make([]byte, 0, 74000)
+	x := uint32(1)
+	for i := 0; i < 66000; i++ {
+		x = x*1103515245 + 12345
+		out = append(out, byte(x>>24))
+	}
+	json, err := os.ReadFile("../../testdata/parking3.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(out, json[:8000]...)
+}
+
+func TestDecompressors(t *testing.T) {
+	parking2, err := os.ReadFile("../../testdata/parking2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking3, err := os.ReadFile("../../testdata/parking3.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := mixedData(t)
+	tcs := []struct {
+		file   string
+		decomp func(io.Reader) (io.Reader, error)
+		want   []byte
+	}{
+		{"parking3.json.xz", xzDecomp, parking3},
+		// several blocks with a sha256 check
+		{"parking3-blocks.json.xz", xzDecomp, parking3},
+		// two streams (with crc32 and no checks)
+		// separated by padding
+		{"parking3-streams.json.xz", xzDecomp, parking3},
+		{"mixed.xz", xzDecomp, mixed},
+		{"parking3.json.lz4", lz4Decomp, parking3},
+		// linked blocks with block checksums
+		// and the content size
+		{"parking2-linked.json.lz4", lz4Decomp, parking2},
+		{"parking3-legacy.json.lz4", lz4Decomp, parking3},
+		{"mixed.lz4", lz4Decomp, mixed},
+		{"parking3.json.bz2", bzip2Decomp, parking3},
+	}
+	for i := range tcs {
+		tc := &tcs[i]
+		t.Run(tc.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata/codecs", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			r, err := tc.decomp(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Fatalf("got %d bytes, want %d", len(got), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestDecompressorErrors(t *testing.T) {
+	tcs := []struct {
+		file   string
+		decomp func(io.Reader) (io.Reader, error)
+	}{
+		{"parking3.json.xz", xzDecomp},
+		{"parking3-blocks.json.xz", xzDecomp},
+		{"mixed.xz", xzDecomp},
+		{"parking3.json.lz4", lz4Decomp},
+		{"parking2-linked.json.lz4", lz4Decomp},
+		{"mixed.lz4", lz4Decomp},
+	}
+	decode := func(decomp func(io.Reader) (io.Reader, error), buf []byte) error {
+		r, err := decomp(bytes.NewReader(buf))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(io.Discard, r)
+		return err
+	}
+	for i := range tcs {
+		tc := &tcs[i]
+		t.Run(tc.file, func(t *testing.T) {
+			buf, err := os.ReadFile(filepath.Join("testdata/codecs", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = decode(tc.decomp, nil)
+			if !IsFatal(err) {
+				t.Errorf("empty input: got %v", err)
+			}
+			for _, n := range []int{1, 7, 20, len(buf) / 2, len(buf) - 1} {
+				err = decode(tc.decomp, buf[:n])
+				if err == nil {
+					t.Errorf("truncated to %d bytes: no error", n)
+				}
+			}
+			err = decode(tc.decomp, append(buf[:len(buf):len(buf)], "garbage"...))
+			if !IsFatal(err) {
+				t.Errorf("trailing garbage: got %v", err)
+			}
+			// every corruption of the input should be
+			// detected, either by a checksum or because
+			// the result is invalid
+			corrupt := make([]byte, len(buf))
+			for i := 0; i < len(buf); i += 1 + len(buf)/200 {
+				copy(corrupt, buf)
+				corrupt[i] ^= 0x41
+				err = decode(tc.decomp, corrupt)
+				if !IsFatal(err) {
+					t.Errorf("corrupting byte %d: got %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertCodecs(t *testing.T) {
+	text, err := os.ReadFile("../../testdata/parking3.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := convertRows(t, ".json", "", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(t *testing.T, suffix string, buf []byte) {
+		got, err := convertRows(t, suffix, "", buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d rows, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("row %d: got %s, want %s", i, got[i], want[i])
+			}
+		}
+	}
+
+	compressors := map[string]func(w io.Writer) io.WriteCloser{
+		".json.gz": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		".json.zst": func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		},
+		".json.sz": func(w io.Writer) io.WriteCloser {
+			return snappy.NewBufferedWriter(w)
+		},
+	}
+	for suffix, comp := range compressors {
+		t.Run(suffix, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := comp(&buf)
+			w.Write(text)
+			w.Close()
+			check(t, suffix, buf.Bytes())
+		})
+	}
+	for _, file := range []string{
+		"parking3.json.xz",
+		"parking3-streams.json.xz",
+		"parking3.json.lz4",
+		"parking3.json.bz2",
+	} {
+		t.Run(file, func(t *testing.T) {
+			buf, err := os.ReadFile(filepath.Join("testdata/codecs", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, file[strings.Index(file, "."):], buf)
+		})
+	}
+	t.Run(".json.zip", func(t *testing.T) {
+		// split the records across several members,
+		// including an empty one and a directory
+		mid := bytes.IndexByte(text[len(text)/2:], '\n') + len(text)/2 + 1
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		zw.Create("dir/")
+		for i, part := range [][]byte{text[:0], text[:mid], text[mid:]} {
+			method := zip.Deflate
+			if i == 1 {
+				method = zip.Store
+			}
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("dir/part%d", i), Method: method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(part)
+		}
+		zw.Close()
+		check(t, ".json.zip", buf.Bytes())
+	})
+}
+
+func TestConvertZipMembers(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	members := []string{
+		"a,b\n1,2\n",
+		"b,a\nx,y\n3,4\n",
+	}
+	for i := range members {
+		w, err := zw.Create(fmt.Sprintf("part%d.csv", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(members[i]))
+	}
+	zw.Close()
+
+	// each member has its own header
+	rows, err := convertRows(t, ".csv.zip", "", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"a": "1", "b": "2"}`,
+		`{"a": "y", "b": "x"}`,
+		`{"a": "4", "b": "3"}`,
+	}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got rows %s, want %s", rows, want)
+	}
+
+	// parse errors are counted for all members
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	for _, text := range []string{"a=1\n\"\n", "=\nb=2\n"} {
+		w, _ := zw.Create("x.logfmt")
+		w.Write([]byte(text))
+	}
+	zw.Close()
+	testLines(t, ".logfmt.zip", "", buf.String(), []string{`{"a": 1}`, `{"b": 2}`}, 2)
+
+	// the archive is copied to a temporary
+	// file if it cannot be read at offsets
+	f := SuffixToFormat[".logfmt.zip"]()
+	var out bytes.Buffer
+	cn := ion.Chunker{Align: 4096, W: &out}
+	err = f.Convert(io.MultiReader(bytes.NewReader(buf.Bytes())), &cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn.Flush()
+	if rows := jsonRows(t, out.Bytes()); len(rows) != 2 {
+		t.Errorf("got rows %s", rows)
+	}
+
+	// ... but only up to the maximum size
+	// of a temporary file
+	f.(tempUser).useTemp(&tempfiles{dir: t.TempDir(), maxsize: int64(buf.Len() - 1)})
+	err = f.Convert(io.MultiReader(bytes.NewReader(buf.Bytes())), &cn)
+	if !errors.Is(err, ErrTempTooLarge) || !IsFatal(err) {
+		t.Errorf("got error %v", err)
+	}
+	f.(tempUser).useTemp(&tempfiles{dir: t.TempDir(), maxsize: int64(buf.Len())})
+	err = f.Convert(io.MultiReader(bytes.NewReader(buf.Bytes())), &cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupt member is a fatal error
+	b := buf.Bytes()
+	i := bytes.Index(b, []byte("x.logfmt")) + len("x.logfmt")
+	b[i] ^= 0xff
+	_, err = convertRows(t, ".logfmt.zip", "", b)
+	if !IsFatal(err) {
+		t.Errorf("got error %v", err)
+	}
+	_, err = convertRows(t, ".json.zip", "", []byte("not a zip file"))
+	if !IsFatal(err) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestCodecNames(t *testing.T) {
+	for suffix, cons := range SuffixToFormat {
+		if name := cons().Name(); "."+name != suffix {
+			t.Errorf("format for %s is named %q", suffix, name)
+		}
+	}
+	if f := CloudtrailJSON(".xz"); f == nil || f.Name() != "json.xz" {
+		t.Errorf("CloudtrailJSON(.xz) = %v", f)
+	}
+	if f := CloudtrailJSON(".rar"); f != nil {
+		t.Errorf("CloudtrailJSON(.rar) = %v", f)
+	}
+}
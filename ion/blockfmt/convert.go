@@ -15,6 +15,8 @@
 package blockfmt
 
 import (
+	"archive/zip"
+	"compress/bzip2"
 	"compress/flate"
 	"compress/gzip"
 	"errors"
@@ -26,6 +28,9 @@ import (
 	"github.com/SnellerInc/sneller/aws/s3"
 	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/jsonrl"
+	"github.com/SnellerInc/sneller/parquet"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/snappy"
 	"github.com/klauspost/compress/zstd"
 )
 
@@ -45,6 +50,16 @@ type RowFormat interface {
 	UseHints(schema []byte) error
 }
 
+// ParseErrorCounter is implemented by RowFormats
+// that skip the parts of their input that they
+// cannot parse rather than failing the conversion.
+type ParseErrorCounter interface {
+	// ParseErrors returns the number of parse
+	// errors in the input of the most recent
+	// call to Convert.
+	ParseErrors() int64
+}
+
 // Input is a combination of
 // an input stream and a row-formatting function.
 // Together they produce output blocks.
@@ -63,37 +78,49 @@ type Input struct {
 	// to this input that is populated
 	// by Converter.Run.
 	Err error
+	// ParseErrors is the number of parse
+	// errors that F skipped over; it is
+	// populated by Converter.Run if F
+	// is a ParseErrorCounter.
+	ParseErrors int64
+}
+
+// countErrors sets in.ParseErrors
+// after in has been converted
+func (in *Input) countErrors() {
+	if pc, ok := in.F.(ParseErrorCounter); ok {
+		in.ParseErrors = pc.ParseErrors()
+	}
 }
 
 type jsonConverter struct {
-	decomp       func(r io.Reader) (io.Reader, error)
-	compname     string
 	hints        *jsonrl.Hint
 	isCloudtrail bool
 }
 
-func (j *jsonConverter) Name() string {
-	if j.compname == "" {
-		return "json"
+func (j *jsonConverter) Name() string { return "json" }
+
+func (j *jsonConverter) Convert(r io.Reader, dst *ion.Chunker) error {
+	if j.isCloudtrail {
+		return jsonrl.ConvertCloudtrail(r, dst)
 	}
-	return "json." + j.compname
+	return jsonrl.Convert(r, dst, j.hints)
 }
 
-func (j *jsonConverter) Convert(r io.Reader, dst *ion.Chunker) error {
+// decompress calls convert with the output of
+// decomp applied to r, or with r itself
+// if decomp is nil
+func decompress(r io.Reader, decomp func(r io.Reader) (io.Reader, error), convert func(r io.Reader) error) error {
 	rc := r
 	var err, err2 error
-	if j.decomp != nil {
-		rc, err = j.decomp(r)
+	if decomp != nil {
+		rc, err = decomp(r)
 		if err != nil {
 			return err
 		}
 	}
-	if j.isCloudtrail {
-		err = jsonrl.ConvertCloudtrail(rc, dst)
-	} else {
-		err = jsonrl.Convert(rc, dst, j.hints)
-	}
-	if j.decomp != nil {
+	err = convert(rc)
+	if decomp != nil {
 		// if the decompressor (i.e. gzip.Reader)
 		// has a Close() method, then use that;
 		// this lets us check the integrity of
@@ -157,45 +184,59 @@ func UnsafeION() RowFormat {
 // filename suffixes that correspond
 // to known constructors for RowFormat
 // objects.
-var SuffixToFormat = map[string]func() RowFormat{
+//
+// Each format may also be compressed with
+// zstd, gzip, bzip2, xz, lz4 or snappy (framed)
+// or be a member of a zip archive, which
+// is indicated by the additional suffix
+// .zst, .gz, .bz2, .xz, .lz4, .sz or .zip
+var SuffixToFormat = withCodecs(map[string]func() RowFormat{
 	".json": func() RowFormat {
-		return &jsonConverter{decomp: nil}
+		return &jsonConverter{}
 	},
-	".json.zst": func() RowFormat {
-		return &jsonConverter{
-			decomp: func(r io.Reader) (io.Reader, error) {
-				rz, err := zstd.NewReader(r)
-				err = noEOF(err, zstd.ErrMagicMismatch)
-				return rz, err
-			},
-			compname: "zst",
-		}
+	".csv": func() RowFormat {
+		return newCSVConverter("csv")
 	},
-	".json.gz": func() RowFormat {
-		return &jsonConverter{
-			decomp: func(r io.Reader) (io.Reader, error) {
-				rz, err := gzip.NewReader(r)
-				err = noEOF(err, gzip.ErrHeader)
-				return rz, err
-			},
-			compname: "gz",
-		}
+	".tsv": func() RowFormat {
+		return newCSVConverter("tsv")
 	},
+	".parquet": func() RowFormat {
+		return &parquetConverter{}
+	},
+	".avro": func() RowFormat {
+		return &avroConverter{}
+	},
+	".logfmt": func() RowFormat {
+		return &lineConverter{name: "logfmt", parse: parseLogfmt}
+	},
+	".syslog": func() RowFormat {
+		return &lineConverter{name: "syslog", parse: parseSyslog}
+	},
+	".accesslog": func() RowFormat {
+		return &lineConverter{name: "accesslog", parse: parseAccessLog}
+	},
+})
+
+func zstdDecomp(r io.Reader) (io.Reader, error) {
+	rz, err := zstd.NewReader(r)
+	err = noEOF(err, zstd.ErrMagicMismatch)
+	return rz, err
+}
+
+func gzipDecomp(r io.Reader) (io.Reader, error) {
+	rz, err := gzip.NewReader(r)
+	err = noEOF(err, gzip.ErrHeader)
+	return rz, err
 }
 
 // CloudtrailJSON produces the RowFormat associated
 // with parsing AWS Cloudtrail data compressed with
-// the given compression name, which should be one
-// of ".gz" ".zst" or "" (none). (The only compression
-// used in practice by AWS is ".gz")
+// the given compression name, which should be
+// the suffix of one of the codecs in SuffixToFormat
+// (i.e. ".gz" or ".zst") or "" (none). (The only
+// compression used in practice by AWS is ".gz")
 func CloudtrailJSON(compression string) RowFormat {
-	fn := SuffixToFormat[".json"+compression]
-	if fn == nil {
-		return nil
-	}
-	ret := fn()
-	ret.(*jsonConverter).isCloudtrail = true
-	return ret
+	return withCodec(&jsonConverter{isCloudtrail: true}, compression)
 }
 
 // Converter performs single- or
@@ -241,6 +282,16 @@ type Converter struct {
 	// DisablePrefetch, if true, disables
 	// prefetching of inputs.
 	DisablePrefetch bool
+	// TempDir is the directory in which inputs
+	// that cannot be read as a stream (zip archives
+	// and parquet files) are copied to temporary files.
+	// If TempDir is empty, os.TempDir() is used.
+	TempDir string
+	// MaxTempSize is the maximum size of such
+	// a temporary file; larger inputs fail with
+	// ErrTempTooLarge. If MaxTempSize is zero,
+	// DefaultMaxTempSize is used.
+	MaxTempSize int64
 
 	// trailer built by the writer. This is only
 	// set if the object was written successfully.
@@ -252,6 +303,15 @@ var isFatal = []error{
 	jsonrl.ErrNoMatch,
 	jsonrl.ErrTooLarge,
 	ion.ErrTooLarge,
+	errCSVSyntax,
+	errCSVTooLarge,
+	ErrTempTooLarge,
+	parquet.ErrCorrupt,
+	parquet.ErrUnsupported,
+	parquet.ErrTooLarge,
+	errAvroFormat,
+	errXZFormat,
+	errLZ4Format,
 	gzip.ErrHeader,
 	zstd.ErrReservedBlockType,
 	zstd.ErrMagicMismatch,
@@ -259,6 +319,13 @@ var isFatal = []error{
 	zstd.ErrWindowSizeExceeded,
 	zstd.ErrWindowSizeTooSmall,
 	zstd.ErrBlockTooSmall,
+	snappy.ErrCorrupt,
+	snappy.ErrUnsupported,
+	snappy.ErrTooLarge,
+	s2.ErrCRC,
+	zip.ErrFormat,
+	zip.ErrAlgorithm,
+	zip.ErrChecksum,
 
 	// these can be produced from the first
 	// fs.File.Read call on at least s3.File
@@ -287,7 +354,8 @@ func IsFatal(err error) bool {
 		}
 	}
 	var cie flate.CorruptInputError
-	return errors.As(err, &cie)
+	var bse bzip2.StructuralError
+	return errors.As(err, &cie) || errors.As(err, &bse)
 }
 
 // MultiStream returns whether the configuration of Converter
@@ -374,6 +442,7 @@ func (c *Converter) runSingle() error {
 			next++
 		}
 
+		c.useTemp(c.Inputs[i].F)
 		err := c.Inputs[i].F.Convert(c.Inputs[i].R, &cn)
 		err2 := c.Inputs[i].R.Close()
 		if err == nil {
@@ -394,6 +463,7 @@ func (c *Converter) runSingle() error {
 			c.Inputs[i].Err = err
 			return err
 		}
+		c.Inputs[i].countErrors()
 	}
 	err = cn.Flush()
 	if err != nil {
@@ -404,6 +474,14 @@ func (c *Converter) runSingle() error {
 	return err
 }
 
+// useTemp passes the settings for
+// temporary files on to f if it uses them
+func (c *Converter) useTemp(f RowFormat) {
+	if tu, ok := f.(tempUser); ok {
+		tu.useTemp(&tempfiles{dir: c.TempDir, maxsize: c.MaxTempSize})
+	}
+}
+
 func (c *Converter) runPrepend(cn *ion.Chunker) error {
 	if c.Prepend.R == nil {
 		return nil
@@ -482,6 +560,7 @@ func (c *Converter) runMulti() error {
 				}
 			}
 			for in := range startc {
+				c.useTemp(in.F)
 				err := in.F.Convert(in.R, &cn)
 				err2 := in.R.Close()
 				if err == nil {
@@ -493,6 +572,7 @@ func (c *Converter) runMulti() error {
 					errs <- fmt.Errorf("%s: %w", in.Path, err)
 					return
 				}
+				in.countErrors()
 			}
 			err := cn.Flush()
 			if err != nil {
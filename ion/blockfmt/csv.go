@@ -0,0 +1,537 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// csvHints are the hints accepted by the
+// csv and tsv formats:
+//
+//	{
+//	  "separator": ",",
+//	  "quote": "\"",
+//	  "no_header": false,
+//	  "skip_records": 0,
+//	  "missing_values": ["", "NULL"],
+//	  "fields": [
+//	    {"name": "id", "type": "int"},
+//	    {"name": "at", "type": "datetime", "format": "%Y/%m/%d %H:%M:%S"},
+//	    {"name": "note", "type": "ignore"}
+//	  ]
+//	}
+//
+// The separator and quote are single characters;
+// an empty quote disables quoting. The first record
+// (after skip_records records have been skipped) names
+// the columns unless no_header is set, in which case
+// the columns are named by fields in order.
+// Columns that have no name are named _1, _2, etc.
+// by their (1-based) position.
+//
+// Fields that are not explicitly typed are strings.
+// The supported types are string, number (int or float),
+// int, float, bool, datetime (RFC3339, or the strftime-style
+// format given by "format"), unix_seconds, and ignore.
+// Fields with "no_index": true are not added to the
+// sparse index. Columns whose text is one of the
+// missing_values (by default, only the empty string)
+// are omitted from their records.
+type csvHints struct {
+	Separator     *string    `json:"separator"`
+	Quote         *string    `json:"quote"`
+	NoHeader      bool       `json:"no_header"`
+	SkipRecords   int        `json:"skip_records"`
+	MissingValues []string   `json:"missing_values"`
+	Fields        []csvField `json:"fields"`
+}
+
+type csvField struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Format  string `json:"format"`
+	NoIndex bool   `json:"no_index"`
+}
+
+type csvType uint8
+
+const (
+	csvString csvType = iota
+	csvNumber
+	csvInt
+	csvFloat
+	csvBool
+	csvDateTime
+	csvUnixSeconds
+	csvIgnore
+)
+
+var csvTypes = map[string]csvType{
+	"":             csvString,
+	"string":       csvString,
+	"number":       csvNumber,
+	"int":          csvInt,
+	"float":        csvFloat,
+	"bool":         csvBool,
+	"datetime":     csvDateTime,
+	"unix_seconds": csvUnixSeconds,
+	"ignore":       csvIgnore,
+}
+
+// csvColumn is the parsed form of a csvField
+type csvColumn struct {
+	name    string
+	typ     csvType
+	format  date.Format // for csvDateTime, or nil for RFC3339
+	noindex bool
+}
+
+func (c *csvColumn) parse(f *csvField) error {
+	typ, ok := csvTypes[f.Type]
+	if !ok {
+		return fmt.Errorf("field %q: unknown type %q", f.Name, f.Type)
+	}
+	c.name = f.Name
+	c.typ = typ
+	c.noindex = f.NoIndex
+	if f.Format != "" {
+		if typ != csvDateTime {
+			return fmt.Errorf("field %q: format is only supported for datetime", f.Name)
+		}
+		format, err := date.ParseFormat(f.Format)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", f.Name, err)
+		}
+		c.format = format
+	}
+	return nil
+}
+
+type csvConverter struct {
+	name string // "csv" or "tsv"
+
+	sep, quote byte
+	noheader   bool
+	skip       int
+	missing    [][]byte
+	// columns are the hinted columns;
+	// with a header they are matched
+	// to the columns by name
+	columns []csvColumn
+}
+
+func newCSVConverter(name string) *csvConverter {
+	c := &csvConverter{name: name}
+	c.defaults()
+	return c
+}
+
+// defaults resets c to its un-hinted state;
+// tsv has no quoting by default
+func (c *csvConverter) defaults() {
+	c.sep, c.quote = ',', '"'
+	if c.name == "tsv" {
+		c.sep, c.quote = '\t', 0
+	}
+	c.noheader = false
+	c.skip = 0
+	c.missing = [][]byte{{}}
+	c.columns = nil
+}
+
+func (c *csvConverter) Name() string { return c.name }
+
+func (c *csvConverter) UseHints(hints []byte) error {
+	c.defaults()
+	if hints == nil {
+		return nil
+	}
+	var h csvHints
+	d := json.NewDecoder(bytes.NewReader(hints))
+	d.DisallowUnknownFields()
+	err := d.Decode(&h)
+	if err != nil {
+		return fmt.Errorf("%s hints: %w", c.name, err)
+	}
+	if h.Separator != nil {
+		if len(*h.Separator) != 1 {
+			return fmt.Errorf("%s hints: separator %q is not a single character", c.name, *h.Separator)
+		}
+		c.sep = (*h.Separator)[0]
+	}
+	if h.Quote != nil {
+		switch len(*h.Quote) {
+		case 0:
+			c.quote = 0
+		case 1:
+			c.quote = (*h.Quote)[0]
+		default:
+			return fmt.Errorf("%s hints: quote %q is not a single character", c.name, *h.Quote)
+		}
+	}
+	if c.quote != 0 && c.quote == c.sep {
+		return fmt.Errorf("%s hints: the quote and separator must be different", c.name)
+	}
+	if c.sep == '\n' || c.sep == '\r' || c.quote == '\n' || c.quote == '\r' {
+		return fmt.Errorf("%s hints: the quote and separator cannot be line breaks", c.name)
+	}
+	if h.SkipRecords < 0 {
+		return fmt.Errorf("%s hints: negative skip_records", c.name)
+	}
+	c.noheader = h.NoHeader
+	c.skip = h.SkipRecords
+	if h.MissingValues != nil {
+		c.missing = c.missing[:0]
+		for i := range h.MissingValues {
+			c.missing = append(c.missing, []byte(h.MissingValues[i]))
+		}
+	}
+	c.columns = make([]csvColumn, len(h.Fields))
+	for i := range h.Fields {
+		if h.Fields[i].Name == "" {
+			return fmt.Errorf("%s hints: field %d has no name", c.name, i)
+		}
+		err := c.columns[i].parse(&h.Fields[i])
+		if err != nil {
+			return fmt.Errorf("%s hints: %w", c.name, err)
+		}
+	}
+	if c.noheader && len(c.columns) == 0 {
+		return fmt.Errorf("%s hints: no_header requires a list of fields", c.name)
+	}
+	return nil
+}
+
+func (c *csvConverter) Convert(r io.Reader, dst *ion.Chunker) error {
+	cr := &csvReader{
+		r:     bufio.NewReader(r),
+		sep:   c.sep,
+		quote: c.quote,
+	}
+	for i := 0; i < c.skip; i++ {
+		_, err := cr.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	var columns []csvColumn
+	if c.noheader {
+		columns = c.columns
+	} else {
+		header, err := cr.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		columns = make([]csvColumn, len(header))
+		for i := range header {
+			columns[i].name = string(header[i])
+			for j := range c.columns {
+				if c.columns[j].name == columns[i].name {
+					columns[i] = c.columns[j]
+					break
+				}
+			}
+		}
+	}
+	w := csvWriter{dst: dst, columns: columns, missing: c.missing}
+	for {
+		rec, err := cr.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		err = w.write(rec)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", c.name, cr.line, err)
+		}
+	}
+}
+
+// csvWriter writes records to an ion.Chunker
+type csvWriter struct {
+	dst     *ion.Chunker
+	columns []csvColumn
+	missing [][]byte
+
+	// syms are the symbols for the columns
+	// and order is the order in which they
+	// are written; they are recomputed whenever
+	// the symbol table changes
+	syms  []ion.Symbol
+	order []int
+	path  ion.Symbuf
+}
+
+func (w *csvWriter) name(i int) string {
+	if i < len(w.columns) && w.columns[i].name != "" {
+		return w.columns[i].name
+	}
+	return "_" + strconv.Itoa(i+1)
+}
+
+// symbolize makes sure that w.syms
+// holds the symbols for n columns
+func (w *csvWriter) symbolize(n int) {
+	st := &w.dst.Symbols
+	same := len(w.syms) >= n
+	for i := 0; same && i < n; i++ {
+		same = st.Get(w.syms[i]) == w.name(i)
+	}
+	if same {
+		return
+	}
+	w.syms = w.syms[:0]
+	w.order = w.order[:0]
+	for i := 0; i < n; i++ {
+		w.syms = append(w.syms, st.Intern(w.name(i)))
+		w.order = append(w.order, i)
+	}
+	slices.SortStableFunc(w.order, func(i, j int) bool {
+		return w.syms[i] < w.syms[j]
+	})
+}
+
+func (w *csvWriter) isMissing(b []byte) bool {
+	for i := range w.missing {
+		if bytes.Equal(b, w.missing[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errCSVSyntax = errors.New("syntax error")
+	// errCSVTooLarge is returned for a
+	// record longer than csvMaxRecord bytes
+	errCSVTooLarge = errors.New("record too large")
+)
+
+// csvMaxRecord is the maximum size of a line
+// of input (or of a record with quoted line breaks)
+const csvMaxRecord = 1024 * 1024
+
+func (w *csvWriter) write(rec [][]byte) error {
+	w.symbolize(len(rec))
+	b := &w.dst.Buffer
+	b.BeginStruct(-1)
+	var prev ion.Symbol
+	for _, i := range w.order {
+		if i >= len(rec) {
+			continue
+		}
+		var col csvColumn
+		if i < len(w.columns) {
+			col = w.columns[i]
+		}
+		text := rec[i]
+		sym := w.syms[i]
+		if col.typ == csvIgnore || w.isMissing(text) {
+			continue
+		}
+		if sym == prev {
+			// duplicate column name;
+			// the first one wins
+			continue
+		}
+		prev = sym
+		b.BeginField(sym)
+		switch col.typ {
+		case csvString:
+			b.BeginString(len(text))
+			b.UnsafeAppend(text)
+		case csvNumber, csvInt, csvFloat:
+			s := string(bytes.TrimSpace(text))
+			if col.typ != csvFloat {
+				if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+					b.WriteInt(n)
+					break
+				} else if col.typ == csvInt {
+					return fmt.Errorf("column %q: %q is not an integer: %w", w.name(i), text, errCSVSyntax)
+				}
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a number: %w", w.name(i), text, errCSVSyntax)
+			}
+			b.WriteFloat64(f)
+		case csvBool:
+			v, err := strconv.ParseBool(string(bytes.TrimSpace(text)))
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a bool: %w", w.name(i), text, errCSVSyntax)
+			}
+			b.WriteBool(v)
+		case csvDateTime, csvUnixSeconds:
+			var t date.Time
+			var ok bool
+			if col.typ == csvUnixSeconds {
+				n, err := strconv.ParseInt(string(bytes.TrimSpace(text)), 10, 64)
+				ok = err == nil
+				t = date.Unix(n, 0)
+			} else if col.format != nil {
+				t, ok = col.format.Parse(text)
+			} else {
+				t, ok = date.Parse(text)
+			}
+			if !ok {
+				return fmt.Errorf("column %q: %q is not a timestamp: %w", w.name(i), text, errCSVSyntax)
+			}
+			b.WriteTime(t)
+			if !col.noindex {
+				w.path.Prepare(1)
+				w.path.Push(sym)
+				w.dst.Ranges.AddTime(w.path, t)
+			}
+		}
+	}
+	b.EndStruct()
+	return w.dst.Commit()
+}
+
+// csvReader splits its input into records
+type csvReader struct {
+	r          *bufio.Reader
+	sep, quote byte
+	line       int // line number of the last line read
+
+	text   []byte // the current line
+	buf    []byte // field contents
+	ends   []int  // end of each field in buf
+	fields [][]byte
+}
+
+// readLine reads the next line of input
+// without its trailing line break
+func (c *csvReader) readLine() error {
+	c.text = c.text[:0]
+	for {
+		part, err := c.r.ReadSlice('\n')
+		c.text = append(c.text, part...)
+		if len(c.text) > csvMaxRecord {
+			return fmt.Errorf("line %d: %w", c.line+1, errCSVTooLarge)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(c.text) > 0 {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+	c.line++
+	c.text = bytes.TrimSuffix(c.text, []byte{'\n'})
+	c.text = bytes.TrimSuffix(c.text, []byte{'\r'})
+	return nil
+}
+
+// next returns the fields of the next record;
+// the fields are only valid until the next call
+func (c *csvReader) next() ([][]byte, error) {
+	for {
+		err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		// blank lines are skipped
+		if len(c.text) > 0 {
+			break
+		}
+	}
+	c.buf = c.buf[:0]
+	c.ends = c.ends[:0]
+	line := c.text
+	for {
+		if c.quote != 0 && len(line) > 0 && line[0] == c.quote {
+			var err error
+			line, err = c.quoted(line[1:])
+			if err != nil {
+				return nil, err
+			}
+		}
+		i := bytes.IndexByte(line, c.sep)
+		if i < 0 {
+			c.buf = append(c.buf, line...)
+			c.ends = append(c.ends, len(c.buf))
+			break
+		}
+		c.buf = append(c.buf, line[:i]...)
+		c.ends = append(c.ends, len(c.buf))
+		line = line[i+1:]
+	}
+	c.fields = c.fields[:0]
+	start := 0
+	for _, end := range c.ends {
+		c.fields = append(c.fields, c.buf[start:end:end])
+		start = end
+	}
+	return c.fields, nil
+}
+
+// quoted appends the contents of a quoted field
+// that begins at line to c.buf and returns the
+// text that follows the closing quote
+func (c *csvReader) quoted(line []byte) ([]byte, error) {
+	for {
+		i := bytes.IndexByte(line, c.quote)
+		if i < 0 {
+			// the field continues on the next line
+			c.buf = append(c.buf, line...)
+			c.buf = append(c.buf, '\n')
+			if len(c.buf) > csvMaxRecord {
+				return nil, fmt.Errorf("line %d: %w", c.line, errCSVTooLarge)
+			}
+			start := c.line
+			err := c.readLine()
+			if err == io.EOF {
+				return nil, fmt.Errorf("line %d: unterminated quoted field: %w", start, errCSVSyntax)
+			} else if err != nil {
+				return nil, err
+			}
+			line = c.text
+			continue
+		}
+		c.buf = append(c.buf, line[:i]...)
+		line = line[i+1:]
+		if len(line) > 0 && line[0] == c.quote {
+			// doubled quote
+			c.buf = append(c.buf, c.quote)
+			line = line[1:]
+			continue
+		}
+		// any text between the closing quote
+		// and the separator is kept as-is
+		return line, nil
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// convertRows converts text with the format for
+// the given suffix and returns the rows as JSON
+func convertRows(t *testing.T, suffix, hints string, text []byte) ([]string, error) {
+	f := SuffixToFormat[suffix]()
+	var h []byte
+	if hints != "" {
+		h = []byte(hints)
+	}
+	err := f.UseHints(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cn := ion.Chunker{
+		Align: 4096,
+		W:     &out,
+	}
+	err = f.Convert(bytes.NewReader(text), &cn)
+	if err != nil {
+		return nil, err
+	}
+	err = cn.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jsonRows(t, out.Bytes()), nil
+}
+
+// jsonRows returns the rows of the
+// converted output as JSON
+func jsonRows(t *testing.T, buf []byte) []string {
+	var rows []string
+	var st ion.Symtab
+	var d ion.Datum
+	var err error
+	for len(buf) > 0 {
+		if ion.TypeOf(buf) == ion.NullType {
+			// nop pad
+			buf = buf[ion.SizeOf(buf):]
+			continue
+		}
+		d, buf, err = ion.ReadDatum(&st, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Empty() {
+			continue
+		}
+		js, err := ion.AppendJSON(nil, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, string(js))
+	}
+	return rows
+}
+
+func TestConvertCSV(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("a,b\n1,2\n"))
+	w.Close()
+
+	tcs := []struct {
+		suffix, hints string
+		input         string
+		rows          []string
+	}{
+		{
+			suffix: ".csv",
+			input:  "name,count\nfoo,3\n\"bar, baz\",\"4\"\n",
+			rows: []string{
+				`{"name": "foo", "count": "3"}`,
+				`{"name": "bar, baz", "count": "4"}`,
+			},
+		},
+		{
+			// quoted line breaks and quotes,
+			// CRLF line endings, blank lines,
+			// and missing columns
+			suffix: ".csv",
+			input:  "x,y\r\n\"a\nb\",\"say \"\"hi\"\"\"\r\n\r\n,z\r\nonly\r\n",
+			rows: []string{
+				`{"x": "a\nb", "y": "say \"hi\""}`,
+				`{"y": "z"}`,
+				`{"x": "only"}`,
+			},
+		},
+		{
+			suffix: ".csv",
+			hints: `{"fields": [
+				{"name": "id", "type": "int"},
+				{"name": "score", "type": "number"},
+				{"name": "ok", "type": "bool"},
+				{"name": "at", "type": "datetime"},
+				{"name": "secret", "type": "ignore"}
+			], "missing_values": ["", "NULL"]}`,
+			input: "id,score,ok,at,secret,extra\n" +
+				"1,2.5,true,2022-10-01T12:00:00Z,xyz,e\n" +
+				"-2,3,false,NULL,xyz\n",
+			rows: []string{
+				`{"id": 1, "score": 2.5, "ok": true, "at": "2022-10-01T12:00:00Z", "extra": "e"}`,
+				`{"id": -2, "score": 3, "ok": false}`,
+			},
+		},
+		{
+			suffix: ".tsv",
+			hints: `{"no_header": true, "skip_records": 1, "fields": [
+				{"name": "when", "type": "datetime", "format": "%Y/%m/%d %H:%M:%S"},
+				{"name": "text"}
+			]}`,
+			input: "# comment\n2022/10/01 12:00:00\t\"quoted\"\textra\n",
+			rows: []string{
+				`{"when": "2022-10-01T12:00:00Z", "text": "\"quoted\"", "_3": "extra"}`,
+			},
+		},
+		{
+			suffix: ".csv",
+			hints:  `{"separator": ";", "quote": "'"}`,
+			input:  "a;b\n'x;y';1\n",
+			rows: []string{
+				`{"a": "x;y", "b": "1"}`,
+			},
+		},
+		{
+			suffix: ".csv.gz",
+			input:  gz.String(),
+			rows: []string{
+				`{"a": "1", "b": "2"}`,
+			},
+		},
+	}
+	for i := range tcs {
+		rows, err := convertRows(t, tcs[i].suffix, tcs[i].hints, []byte(tcs[i].input))
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if len(rows) != len(tcs[i].rows) {
+			t.Fatalf("case %d: got rows %s, want %s", i, rows, tcs[i].rows)
+		}
+		for j := range rows {
+			want := tcs[i].rows[j]
+			if rows[j] != want {
+				t.Errorf("case %d row %d: got %s, want %s", i, j, rows[j], want)
+			}
+		}
+	}
+}
+
+func TestConvertCSVErrors(t *testing.T) {
+	tcs := []struct {
+		hints, input string
+	}{
+		{`{"fields": [{"name": "n", "type": "int"}]}`, "n\n1.5\n"},
+		{`{"fields": [{"name": "t", "type": "datetime"}]}`, "t\nyesterday\n"},
+		{"", "a\n\"unterminated\n"},
+	}
+	for i := range tcs {
+		_, err := convertRows(t, ".csv", tcs[i].hints, []byte(tcs[i].input))
+		if err == nil {
+			t.Errorf("case %d: expected an error", i)
+		} else if !IsFatal(err) {
+			t.Errorf("case %d: error %q is not fatal", i, err)
+		}
+	}
+
+	bad := []string{
+		`{"separator": ",,"}`,
+		`{"quote": ","}`,
+		`{"no_header": true}`,
+		`{"fields": [{"name": "x", "type": "decimal"}]}`,
+		`{"unknown": 1}`,
+	}
+	for _, h := range bad {
+		err := SuffixToFormat[".csv"]().UseHints([]byte(h))
+		if err == nil {
+			t.Errorf("hints %s: expected an error", h)
+		}
+	}
+}
+
+func TestConvertCSVTooLarge(t *testing.T) {
+	long := strings.Repeat("x", csvMaxRecord+1)
+	inputs := []string{
+		"a,b\n1," + long + "\n",
+		// a quoted field spanning many lines
+		"a,b\n1,\"" + strings.Repeat("x\n", csvMaxRecord/2+1) + "\"\n",
+	}
+	for i := range inputs {
+		_, err := convertRows(t, ".csv", "", []byte(inputs[i]))
+		if !errors.Is(err, errCSVTooLarge) {
+			t.Errorf("case %d: got error %v", i, err)
+		} else if !IsFatal(err) {
+			t.Errorf("case %d: error %q is not fatal", i, err)
+		}
+	}
+}
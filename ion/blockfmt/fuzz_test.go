@@ -0,0 +1,167 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build go1.18
+
+package blockfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// maxFuzzOutput is the most output
+// that is read from a decompressor
+// for one fuzzer input
+const maxFuzzOutput = 16 << 20
+
+// addCodecSeeds adds each of the files in
+// testdata/codecs with the given suffix to
+// the seed corpus of f, transformed by fn
+func addCodecSeeds(f *testing.F, suffix string, fn func([]byte) []byte) {
+	files, err := filepath.Glob(filepath.Join("testdata/codecs", "*"+suffix))
+	if err != nil {
+		f.Fatal(err)
+	}
+	if len(files) == 0 {
+		f.Fatalf("no seed files for %s", suffix)
+	}
+	for _, file := range files {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if b := fn(buf); b != nil {
+			f.Add(b)
+		}
+	}
+}
+
+func identity(b []byte) []byte { return b }
+
+// fuzzStream reads the output of decomp
+// for the input buf; it should never panic
+// and should only produce an error or io.EOF
+func fuzzStream(t *testing.T, decomp func(io.Reader) (io.Reader, error), buf []byte) {
+	r, err := decomp(bytes.NewReader(buf))
+	if err != nil {
+		return
+	}
+	io.CopyN(io.Discard, r, maxFuzzOutput)
+}
+
+func FuzzXZ(f *testing.F) {
+	addCodecSeeds(f, ".xz", identity)
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		fuzzStream(t, xzDecomp, buf)
+	})
+}
+
+// lzma2Payload returns the LZMA2 data of
+// the first block of the xz stream in buf
+func lzma2Payload(buf []byte) []byte {
+	if len(buf) <= xzHeaderSize {
+		return nil
+	}
+	start := xzHeaderSize + (int(buf[xzHeaderSize])+1)*4
+	if start >= len(buf) {
+		return nil
+	}
+	return buf[start:]
+}
+
+// FuzzLZMA2 exercises the LZMA2 decoder without
+// the xz container, since the container's header
+// checksums would reject most mutated inputs
+// before they reached the decoder
+func FuzzLZMA2(f *testing.F) {
+	addCodecSeeds(f, ".xz", lzma2Payload)
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		in := &xzInput{r: bufio.NewReader(bytes.NewReader(buf))}
+		z := &lzma2Reader{r: in}
+		err := z.reset(1 << 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []byte
+		total := 0
+		for total < maxFuzzOutput {
+			out, err = z.chunk(out[:0])
+			if err != nil {
+				return
+			}
+			total += len(out)
+		}
+	})
+}
+
+func FuzzLZ4(f *testing.F) {
+	addCodecSeeds(f, ".lz4", identity)
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		fuzzStream(t, lz4Decomp, buf)
+	})
+}
+
+// lz4Payload returns the first compressed
+// block of the lz4 frame in buf
+func lz4Payload(buf []byte) []byte {
+	// magic, FLG, BD, optional content size, HC
+	if len(buf) < 7 || binary.LittleEndian.Uint32(buf) != lz4Magic {
+		return nil
+	}
+	pos := 6
+	if buf[4]&0x08 != 0 {
+		pos += 8
+	}
+	pos++
+	if pos+4 > len(buf) {
+		return nil
+	}
+	size := binary.LittleEndian.Uint32(buf[pos:])
+	pos += 4
+	if size&0x80000000 != 0 || pos+int(size) > len(buf) {
+		// not a compressed block
+		return nil
+	}
+	return buf[pos : pos+int(size)]
+}
+
+// FuzzLZ4Block exercises the lz4 block decoder
+// without the frame format, whose checksums would
+// reject most mutated inputs before they reached it
+func FuzzLZ4Block(f *testing.F) {
+	addCodecSeeds(f, ".lz4", lz4Payload)
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		const max = 4 << 20
+		// a block may refer to the output
+		// that precedes it
+		prefix := []byte("0123456789abcdef")
+		dst := append([]byte(nil), prefix...)
+		out, err := lz4Block(dst, buf, max)
+		if err != nil {
+			return
+		}
+		if len(out) < len(prefix) || len(out)-len(prefix) > max {
+			t.Fatalf("got %d bytes of output (max %d)", len(out)-len(prefix), max)
+		}
+		if !bytes.Equal(out[:len(prefix)], prefix) {
+			t.Fatal("the preceding output was modified")
+		}
+	})
+}
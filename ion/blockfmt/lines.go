@@ -0,0 +1,332 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+)
+
+// lineParser parses one line of input into rec;
+// it returns false if the line is malformed
+type lineParser func(line []byte, rec *logRecord) bool
+
+// lineConverter converts line-oriented
+// log formats with one record per line.
+//
+// Lines that cannot be parsed are skipped
+// and counted rather than failing the
+// conversion; see ParseErrors.
+//
+// The converter accepts the same hints as the
+// json format, but only ignore and no_index
+// have any effect.
+type lineConverter struct {
+	name   string
+	parse  lineParser
+	hints  *jsonrl.Hint
+	errors int64
+}
+
+func (c *lineConverter) Name() string { return c.name }
+
+func (c *lineConverter) UseHints(hints []byte) error {
+	if hints == nil {
+		c.hints = nil
+		return nil
+	}
+	h, err := jsonrl.ParseHint(hints)
+	if err != nil {
+		return err
+	}
+	c.hints = h
+	return nil
+}
+
+// ParseErrors implements ParseErrorCounter
+func (c *lineConverter) ParseErrors() int64 { return c.errors }
+
+func (c *lineConverter) Convert(r io.Reader, dst *ion.Chunker) error {
+	c.errors = 0
+	br := bufio.NewReader(r)
+	w := logWriter{dst: dst, hints: c.hints}
+	var rec logRecord
+	var line []byte
+	for {
+		line = line[:0]
+		var err error
+		for {
+			var part []byte
+			part, err = br.ReadSlice('\n')
+			line = append(line, part...)
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec.reset(len(line))
+		if !utf8.Valid(line) || !c.parse(line, &rec) {
+			c.errors++
+			continue
+		}
+		err = w.write(&rec)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type logKind uint8
+
+const (
+	logString logKind = iota
+	logInt
+	logFloat
+	logBool
+	logTime
+	logStruct
+)
+
+// logField is one field of a parsed line
+type logField struct {
+	name []byte
+	kind logKind
+	text []byte // logString
+	n    int64  // logInt, or 1 for a true logBool
+	f    float64
+	t    date.Time
+	sub  []logField // logStruct
+
+	// sym is the symbol for name, or zero if
+	// the field is ignored; both are set by
+	// logWriter.writeStruct
+	sym     ion.Symbol
+	noindex bool
+}
+
+// logRecord is the parsed form of a line;
+// its fields refer to the line itself or
+// to the record's scratch buffer, so they
+// are only valid until the next line is read
+type logRecord struct {
+	fields  []logField
+	scratch []byte
+}
+
+func (r *logRecord) reset(size int) {
+	r.fields = r.fields[:0]
+	// unescaping never makes text longer,
+	// so the scratch buffer is never
+	// reallocated while parsing a line
+	r.scratch = slices.Grow(r.scratch[:0], size)
+}
+
+// addTo appends a field to *lst and returns
+// it; the capacity of the field's sub-fields
+// is kept between lines
+func addTo(lst *[]logField, name []byte, kind logKind) *logField {
+	n := len(*lst)
+	if n < cap(*lst) {
+		*lst = (*lst)[:n+1]
+	} else {
+		*lst = append(*lst, logField{})
+	}
+	f := &(*lst)[n]
+	*f = logField{name: name, kind: kind, sub: f.sub[:0]}
+	return f
+}
+
+func (r *logRecord) add(name []byte, kind logKind) *logField {
+	return addTo(&r.fields, name, kind)
+}
+
+func (r *logRecord) addString(name, text []byte) {
+	r.add(name, logString).text = text
+}
+
+func (r *logRecord) addInt(name []byte, n int64) {
+	r.add(name, logInt).n = n
+}
+
+func (r *logRecord) addTime(name []byte, t date.Time) {
+	r.add(name, logTime).t = t
+}
+
+// unescape appends text to the scratch buffer with
+// the backslash escapes that esc accepts replaced
+// by the escaped character and returns the result
+func (r *logRecord) unescape(text []byte, esc func(c byte) bool) []byte {
+	return r.unescapeFunc(text, func(text []byte, i int) int {
+		if !esc(text[i]) {
+			r.scratch = append(r.scratch, '\\')
+			return i
+		}
+		r.scratch = append(r.scratch, text[i])
+		return i + 1
+	})
+}
+
+// unescapeFunc appends text to the scratch buffer
+// and returns the result; the escape following each
+// backslash (at text[i]) is handled by fn, which
+// appends its replacement to the scratch buffer and
+// returns the position following the escape, or -1
+// if it is invalid, in which case unescapeFunc
+// returns nil
+func (r *logRecord) unescapeFunc(text []byte, fn func(text []byte, i int) int) []byte {
+	i := 0
+	for i < len(text) && text[i] != '\\' {
+		i++
+	}
+	if i == len(text) {
+		return text
+	}
+	start := len(r.scratch)
+	r.scratch = append(r.scratch, text[:i]...)
+	for i < len(text) {
+		c := text[i]
+		if c != '\\' {
+			r.scratch = append(r.scratch, c)
+			i++
+			continue
+		}
+		if i+1 == len(text) {
+			return nil
+		}
+		i = fn(text, i+1)
+		if i < 0 {
+			return nil
+		}
+	}
+	return r.scratch[start:len(r.scratch):len(r.scratch)]
+}
+
+// maxHintCache is the maximum number of
+// paths for which hints are remembered
+const maxHintCache = 4096
+
+type logHint struct {
+	ignore, noindex bool
+}
+
+// logWriter writes logRecords to an ion.Chunker
+type logWriter struct {
+	dst   *ion.Chunker
+	hints *jsonrl.Hint
+	// cache maps dotted paths to their hints
+	cache map[string]logHint
+	names []string
+	path  []ion.Symbol
+	buf   ion.Symbuf
+}
+
+func (w *logWriter) lookup(name []byte) logHint {
+	if w.hints == nil {
+		return logHint{}
+	}
+	key := strings.Join(w.names, ".") + "." + string(name)
+	if h, ok := w.cache[key]; ok {
+		return h
+	}
+	var h logHint
+	h.ignore, h.noindex = w.hints.Lookup(append(w.names[:len(w.names):len(w.names)], string(name)))
+	if w.cache == nil || len(w.cache) >= maxHintCache {
+		w.cache = make(map[string]logHint)
+	}
+	w.cache[key] = h
+	return h
+}
+
+func (w *logWriter) write(rec *logRecord) error {
+	w.writeStruct(rec.fields)
+	return w.dst.Commit()
+}
+
+func (w *logWriter) writeStruct(fields []logField) {
+	st := &w.dst.Symbols
+	b := &w.dst.Buffer
+	for i := range fields {
+		f := &fields[i]
+		h := w.lookup(f.name)
+		f.sym, f.noindex = 0, h.noindex
+		if !h.ignore {
+			f.sym = st.InternBytes(f.name)
+		}
+	}
+	slices.SortStableFunc(fields, func(x, y logField) bool {
+		return x.sym < y.sym
+	})
+	b.BeginStruct(-1)
+	for i := range fields {
+		f := &fields[i]
+		if f.sym == 0 || (i > 0 && f.sym == fields[i-1].sym) {
+			// ignored, or a duplicate field;
+			// the first one wins
+			continue
+		}
+		b.BeginField(f.sym)
+		switch f.kind {
+		case logString:
+			b.BeginString(len(f.text))
+			b.UnsafeAppend(f.text)
+		case logInt:
+			b.WriteInt(f.n)
+		case logFloat:
+			b.WriteFloat64(f.f)
+		case logBool:
+			b.WriteBool(f.n != 0)
+		case logTime:
+			b.WriteTime(f.t)
+			if !f.noindex && len(w.path)+1 < jsonrl.MaxIndexingDepth {
+				w.buf.Prepare(len(w.path) + 1)
+				for j := range w.path {
+					w.buf.Push(w.path[j])
+				}
+				w.buf.Push(f.sym)
+				w.dst.Ranges.AddTime(w.buf, f.t)
+			}
+		case logStruct:
+			w.path = append(w.path, f.sym)
+			if w.hints != nil {
+				w.names = append(w.names, string(f.name))
+			}
+			w.writeStruct(f.sub)
+			w.path = w.path[:len(w.path)-1]
+			if w.hints != nil {
+				w.names = w.names[:len(w.names)-1]
+			}
+		}
+	}
+	b.EndStruct()
+}
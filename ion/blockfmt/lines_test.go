@@ -0,0 +1,154 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func testLines(t *testing.T, suffix, hints, text string, want []string, errors int64) {
+	t.Helper()
+	f := SuffixToFormat[suffix]()
+	var h []byte
+	if hints != "" {
+		h = []byte(hints)
+	}
+	err := f.UseHints(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cn := ion.Chunker{Align: 4096, W: &out}
+	err = f.Convert(strings.NewReader(text), &cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cn.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := jsonRows(t, out.Bytes())
+	if len(rows) != len(want) {
+		t.Fatalf("got rows %s, want %s", rows, want)
+	}
+	for i := range rows {
+		if rows[i] != want[i] {
+			t.Errorf("row %d:\ngot  %s\nwant %s", i, rows[i], want[i])
+		}
+	}
+	if got := f.(ParseErrorCounter).ParseErrors(); got != errors {
+		t.Errorf("got %d parse errors, want %d", got, errors)
+	}
+}
+
+func TestConvertLogfmt(t *testing.T) {
+	text := strings.Join([]string{
+		`at=2022-10-01T12:00:00Z level=info msg="hello \"world\"" n=3 f=1.5 ok=true debug`,
+		``,
+		`at="2022-10-01T12:00:01Z" level=warn msg="\u00e9t\u00e9" id=0x10 v=nan empty= path=/a=b`,
+		`level=error msg="unterminated`,
+		`=oops`,
+		`level=error n=1 n=2`,
+	}, "\n")
+	want := []string{
+		`{"at": "2022-10-01T12:00:00Z", "level": "info", "msg": "hello \"world\"", "n": 3, "f": 1.5, "ok": true, "debug": true}`,
+		`{"at": "2022-10-01T12:00:01Z", "level": "warn", "msg": "été", "id": "0x10", "v": "nan", "empty": "", "path": "/a=b"}`,
+		`{"level": "error", "n": 1}`,
+	}
+	testLines(t, ".logfmt", "", text, want, 2)
+	want[0] = `{"at": "2022-10-01T12:00:00Z", "level": "info", "n": 3, "f": 1.5, "ok": true, "debug": true}`
+	want[1] = `{"at": "2022-10-01T12:00:01Z", "level": "warn", "id": "0x10", "v": "nan", "empty": "", "path": "/a=b"}`
+	testLines(t, ".logfmt", `{"msg": "ignore"}`, text, want, 2)
+}
+
+func TestConvertSyslog(t *testing.T) {
+	text := strings.Join([]string{
+		`<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - BOM'su root' failed for lonvick on /dev/pts/8`,
+		`<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts.`,
+		`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication\]" eventID="1011"][examplePriority@32473 class="high"]`,
+		`<0>1 - - - - - -`,
+		`<192>1 - - - - - -`,
+		`<34>0 - - - - - -`,
+		`<34>1 yesterday - - - - -`,
+		`<34>1 - - - - - [unterminated x="1"`,
+		`Oct 11 22:14:15 mymachine su: 'su root' failed`,
+	}, "\n")
+	want := []string{
+		`{"version": 1, "facility": 4, "severity": 2, "timestamp": "2003-10-11T22:14:15.003Z", "hostname": "mymachine.example.com", "app_name": "su", "msgid": "ID47", "message": "BOM'su root' failed for lonvick on /dev/pts/8"}`,
+		`{"version": 1, "facility": 20, "severity": 5, "timestamp": "2003-08-24T12:14:15.000003Z", "hostname": "192.0.2.1", "app_name": "myproc", "message": "%% It's time to make the do-nuts.", "procid": "8710"}`,
+		`{"version": 1, "facility": 20, "severity": 5, "timestamp": "2003-10-11T22:14:15.003Z", "hostname": "mymachine.example.com", "app_name": "evntslog", "msgid": "ID47", "structured_data": {"exampleSDID@32473": {"iut": "3", "eventSource": "App\"lication]", "eventID": "1011"}, "examplePriority@32473": {"class": "high"}}}`,
+		`{"version": 1, "facility": 0, "severity": 0}`,
+	}
+	testLines(t, ".syslog", "", text, want, 5)
+}
+
+func TestConvertAccessLog(t *testing.T) {
+	text := strings.Join([]string{
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+		`10.0.0.1 - - [01/Oct/2022:12:00:00 +0000] "POST /api?q=\"x\" HTTP/1.1" 404 - "-" "curl/7.79.1" "10.0.0.2"`,
+		`10.0.0.2 - - [01/Oct/2022:12:00:01 +0000] "\x16\x03\x01" 400 157`,
+		`10.0.0.3 - - [01/Oct/2022 12:00:02] "GET / HTTP/1.1" 200 1`,
+		`10.0.0.3 - - [01/Oct/2022:12:00:02 +0000] "GET / HTTP/1.1" OK 1`,
+	}, "\n")
+	want := []string{
+		`{"remote_addr": "127.0.0.1", "remote_user": "frank", "time": "2000-10-10T20:55:36Z", "request": "GET /apache_pb.gif HTTP/1.0", "method": "GET", "uri": "/apache_pb.gif", "protocol": "HTTP/1.0", "status": 200, "bytes": 2326, "referer": "http://www.example.com/start.html", "user_agent": "Mozilla/4.08 [en] (Win98; I ;Nav)"}`,
+		`{"remote_addr": "10.0.0.1", "time": "2022-10-01T12:00:00Z", "request": "POST /api?q=\"x\" HTTP/1.1", "method": "POST", "uri": "/api?q=\"x\"", "protocol": "HTTP/1.1", "status": 404, "user_agent": "curl/7.79.1"}`,
+		`{"remote_addr": "10.0.0.2", "time": "2022-10-01T12:00:01Z", "request": "\\x16\\x03\\x01", "status": 400, "bytes": 157}`,
+	}
+	testLines(t, ".accesslog", "", text, want, 2)
+}
+
+func TestLineRanges(t *testing.T) {
+	text := strings.Join([]string{
+		`at=2022-10-01T12:00:00Z sub.at=2022-10-03T00:00:00Z`,
+		`at=2022-10-02T12:00:00Z`,
+		`garbage"`,
+	}, "\n")
+	inputs := []Input{{
+		R: io.NopCloser(strings.NewReader(text)),
+		F: SuffixToFormat[".logfmt"](),
+	}}
+	var out BufferUploader
+	out.PartSize = 4096
+	c := Converter{
+		Output:    &out,
+		Comp:      "zstd",
+		Inputs:    inputs,
+		Align:     4096,
+		FlushMeta: 4096,
+	}
+	err := c.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inputs[0].ParseErrors != 1 {
+		t.Errorf("got %d parse errors, want 1", inputs[0].ParseErrors)
+	}
+	ranges := c.Trailer().Sparse.Get([]string{"at"})
+	if ranges == nil {
+		t.Fatal("no time ranges for at")
+	}
+	lo, _ := ranges.Min()
+	hi, _ := ranges.Max()
+	if !lo.Equal(date.Date(2022, 10, 1, 12, 0, 0, 0)) || !hi.Equal(date.Date(2022, 10, 2, 12, 0, 0, 0)) {
+		t.Errorf("got range %s to %s", lo, hi)
+	}
+}
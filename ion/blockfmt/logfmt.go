@@ -0,0 +1,184 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/SnellerInc/sneller/date"
+)
+
+// parseLogfmt parses a line of key=value pairs
+// separated by spaces, i.e.
+//
+//	at=2022-10-01T12:00:00Z level=info msg="hello world" n=3 ok
+//
+// Values may be double-quoted with backslash escapes.
+// A key without a value is true. Values that are
+// timestamps become timestamps (like strings in JSON),
+// and unquoted values that are numbers or true/false
+// become numbers or booleans; everything else
+// is a string.
+func parseLogfmt(line []byte, rec *logRecord) bool {
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return len(rec.fields) > 0
+		}
+		start := i
+		for i < len(line) && line[i] > ' ' && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		key := line[start:i]
+		if len(key) == 0 {
+			// i.e. "=value" or a stray quote
+			return false
+		}
+		if i == len(line) || line[i] != '=' {
+			if i < len(line) && line[i] == '"' {
+				return false
+			}
+			rec.add(key, logBool).n = 1
+			continue
+		}
+		i++
+		if i < len(line) && line[i] == '"' {
+			end := quoteEnd(line, i+1)
+			if end < 0 {
+				return false
+			}
+			text, ok := rec.unquote(line[i+1 : end])
+			if !ok {
+				return false
+			}
+			i = end + 1
+			if t, ok := date.Parse(text); ok {
+				rec.addTime(key, t)
+			} else {
+				rec.addString(key, text)
+			}
+			continue
+		}
+		start = i
+		for i < len(line) && line[i] > ' ' {
+			i++
+		}
+		logfmtValue(rec, key, line[start:i])
+	}
+}
+
+// quoteEnd returns the position of the quote
+// that ends the quoted text starting at i,
+// or -1 if it is not terminated
+func quoteEnd(line []byte, i int) int {
+	for ; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote replaces the escapes in quoted text,
+// which are the same as those in JSON strings
+func (r *logRecord) unquote(text []byte) ([]byte, bool) {
+	out := r.unescapeFunc(text, func(text []byte, i int) int {
+		switch text[i] {
+		case '"', '\\', '/':
+			r.scratch = append(r.scratch, text[i])
+			return i + 1
+		case 'b':
+			r.scratch = append(r.scratch, '\b')
+			return i + 1
+		case 'f':
+			r.scratch = append(r.scratch, '\f')
+			return i + 1
+		case 'n':
+			r.scratch = append(r.scratch, '\n')
+			return i + 1
+		case 'r':
+			r.scratch = append(r.scratch, '\r')
+			return i + 1
+		case 't':
+			r.scratch = append(r.scratch, '\t')
+			return i + 1
+		case 'u':
+			if i+5 > len(text) {
+				return -1
+			}
+			n, err := strconv.ParseUint(string(text[i+1:i+5]), 16, 16)
+			if err != nil {
+				return -1
+			}
+			rn := rune(n)
+			if utf8.RuneLen(rn) < 0 {
+				rn = utf8.RuneError
+			}
+			r.scratch = utf8.AppendRune(r.scratch, rn)
+			return i + 5
+		}
+		return -1
+	})
+	return out, out != nil
+}
+
+// logfmtValue adds the unquoted value text
+func logfmtValue(rec *logRecord, key, text []byte) {
+	if isNumeric(text) {
+		if n, err := strconv.ParseInt(string(text), 10, 64); err == nil {
+			rec.addInt(key, n)
+			return
+		}
+		if f, err := strconv.ParseFloat(string(text), 64); err == nil {
+			rec.add(key, logFloat).f = f
+			return
+		}
+	}
+	switch string(text) {
+	case "true":
+		rec.add(key, logBool).n = 1
+		return
+	case "false":
+		rec.add(key, logBool)
+		return
+	}
+	if t, ok := date.Parse(text); ok {
+		rec.addTime(key, t)
+		return
+	}
+	rec.addString(key, text)
+}
+
+// isNumeric returns whether text only has the
+// characters of a decimal number, which keeps
+// strconv from accepting hex, inf, nan, etc.
+func isNumeric(text []byte) bool {
+	if len(text) == 0 {
+		return false
+	}
+	for _, c := range text {
+		if (c < '0' || c > '9') && c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,463 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+const (
+	lz4Magic       = 0x184d2204
+	lz4LegacyMagic = 0x184c2102
+	// skippable frames have the magic
+	// numbers 0x184d2a50 through 0x184d2a5f
+	lz4SkipMagic = 0x184d2a50
+	lz4SkipMask  = 0xfffffff0
+
+	// lz4Window is the maximum match offset
+	lz4Window = 64 * 1024
+	// lz4LegacyBlock is the size of the
+	// blocks of the legacy format
+	lz4LegacyBlock = 8 * 1024 * 1024
+)
+
+var errLZ4Format = errors.New("invalid lz4 data")
+
+func lz4Errorf(f string, args ...interface{}) error {
+	return fmt.Errorf("lz4: "+f+": %w", append(args, errLZ4Format)...)
+}
+
+// lz4Reader decompresses a sequence of
+// lz4 frames, which may include legacy
+// frames and skippable frames
+type lz4Reader struct {
+	r *bufio.Reader
+
+	// the current frame;
+	// maxBlock is zero between frames
+	maxBlock   int
+	legacy     bool
+	indep      bool
+	blockSum   bool
+	contentSum bool
+	size       int64 // content size, or -1
+	n          int64 // decoded content size
+	sum        xxh32 // of the content
+
+	src []byte
+	// buf holds the window of previous
+	// output followed by the current block
+	buf []byte
+	out []byte // unread part of buf
+	err error
+}
+
+func lz4Decomp(r io.Reader) (io.Reader, error) {
+	z := &lz4Reader{r: bufio.NewReader(r)}
+	// there is always at least one frame,
+	// so check for it now
+	err := z.frame()
+	if err == io.EOF {
+		err = lz4Errorf("empty input")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+func (z *lz4Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+func (z *lz4Reader) uint32() (uint32, error) {
+	var b [4]byte
+	_, err := io.ReadFull(z.r, b[:])
+	return binary.LittleEndian.Uint32(b[:]), err
+}
+
+// next decodes the next block into z.out
+func (z *lz4Reader) next() error {
+	if z.maxBlock == 0 {
+		err := z.frame()
+		if err != nil {
+			return err
+		}
+	}
+	size, err := z.uint32()
+	if z.legacy {
+		// legacy frames simply end at the end
+		// of the input or at another frame
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return noEOF(err, io.ErrUnexpectedEOF)
+		}
+		if size == lz4LegacyMagic || size == lz4Magic || size&lz4SkipMask == lz4SkipMagic {
+			z.maxBlock = 0
+			return z.magic(size)
+		}
+		// the largest possible compressed size
+		if size > lz4LegacyBlock+lz4LegacyBlock/255+16 {
+			return lz4Errorf("legacy block size %d too large", size)
+		}
+		return z.block(int(size), false)
+	}
+	if err != nil {
+		return noEOF(err, io.ErrUnexpectedEOF)
+	}
+	if size == 0 {
+		return z.end()
+	}
+	raw := size&(1<<31) != 0
+	size &^= 1 << 31
+	if int64(size) > int64(z.maxBlock) {
+		return lz4Errorf("block size %d exceeds the maximum %d", size, z.maxBlock)
+	}
+	return z.block(int(size), raw)
+}
+
+// frame reads the header of the next frame
+func (z *lz4Reader) frame() error {
+	magic, err := z.uint32()
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return lz4Errorf("trailing garbage")
+		}
+		return err
+	}
+	return z.magic(magic)
+}
+
+// magic reads the rest of the header of
+// a frame starting with the given magic number
+func (z *lz4Reader) magic(magic uint32) error {
+	switch {
+	case magic == lz4LegacyMagic:
+		z.legacy, z.indep = true, true
+		z.blockSum, z.contentSum = false, false
+		z.maxBlock = lz4LegacyBlock
+		z.size = -1
+		z.n = 0
+		return nil
+	case magic&lz4SkipMask == lz4SkipMagic:
+		size, err := z.uint32()
+		if err != nil {
+			return noEOF(err, io.ErrUnexpectedEOF)
+		}
+		_, err = z.r.Discard(int(size))
+		if err != nil {
+			return noEOF(err, io.ErrUnexpectedEOF)
+		}
+		return z.frame()
+	case magic != lz4Magic:
+		return lz4Errorf("bad magic number %#x", magic)
+	}
+	var desc [2 + 8 + 4 + 1]byte
+	_, err := io.ReadFull(z.r, desc[:2])
+	if err != nil {
+		return noEOF(err, io.ErrUnexpectedEOF)
+	}
+	flg, bd := desc[0], desc[1]
+	if flg>>6 != 1 {
+		return lz4Errorf("unsupported version %d", flg>>6)
+	}
+	if flg&0x02 != 0 || bd&0x8f != 0 {
+		return lz4Errorf("reserved bits set in frame descriptor")
+	}
+	if flg&0x01 != 0 {
+		return lz4Errorf("dictionaries are not supported")
+	}
+	bsize := int(bd>>4) & 7
+	if bsize < 4 {
+		return lz4Errorf("invalid block maximum size %d", bsize)
+	}
+	n := 2
+	if flg&0x08 != 0 {
+		n += 8
+	}
+	_, err = io.ReadFull(z.r, desc[2:n+1])
+	if err != nil {
+		return noEOF(err, io.ErrUnexpectedEOF)
+	}
+	if byte(xxh32Sum(desc[:n])>>8) != desc[n] {
+		return lz4Errorf("frame descriptor checksum mismatch")
+	}
+	z.legacy = false
+	z.indep = flg&0x20 != 0
+	z.blockSum = flg&0x10 != 0
+	z.contentSum = flg&0x04 != 0
+	z.maxBlock = 1 << (2*bsize + 8)
+	z.size = -1
+	if flg&0x08 != 0 {
+		z.size = int64(binary.LittleEndian.Uint64(desc[2:]))
+	}
+	z.n = 0
+	z.sum.reset()
+	z.buf = z.buf[:0]
+	return nil
+}
+
+// end reads the end of the current frame
+func (z *lz4Reader) end() error {
+	if z.contentSum {
+		sum, err := z.uint32()
+		if err != nil {
+			return noEOF(err, io.ErrUnexpectedEOF)
+		}
+		if sum != z.sum.sum() {
+			return lz4Errorf("content checksum mismatch")
+		}
+	}
+	if z.size >= 0 && z.size != z.n {
+		return lz4Errorf("content size %d does not match the frame descriptor (%d)", z.n, z.size)
+	}
+	z.maxBlock = 0
+	return nil
+}
+
+// block reads and decodes a block
+// of the given compressed size
+func (z *lz4Reader) block(size int, raw bool) error {
+	if cap(z.src) < size {
+		z.src = make([]byte, size)
+	}
+	src := z.src[:size]
+	_, err := io.ReadFull(z.r, src)
+	if err != nil {
+		return noEOF(err, io.ErrUnexpectedEOF)
+	}
+	if z.blockSum {
+		sum, err := z.uint32()
+		if err != nil {
+			return noEOF(err, io.ErrUnexpectedEOF)
+		}
+		if sum != xxh32Sum(src) {
+			return lz4Errorf("block checksum mismatch")
+		}
+	}
+	// keep the window that the block may refer
+	// to at the start of the output buffer
+	if z.indep {
+		z.buf = z.buf[:0]
+	} else if len(z.buf) > lz4Window {
+		z.buf = z.buf[:copy(z.buf, z.buf[len(z.buf)-lz4Window:])]
+	}
+	if cap(z.buf) < lz4Window+z.maxBlock {
+		buf := make([]byte, len(z.buf), lz4Window+z.maxBlock)
+		copy(buf, z.buf)
+		z.buf = buf
+	}
+	start := len(z.buf)
+	if raw {
+		z.buf = append(z.buf, src...)
+	} else {
+		z.buf, err = lz4Block(z.buf, src, z.maxBlock)
+		if err != nil {
+			return err
+		}
+	}
+	z.out = z.buf[start:]
+	z.n += int64(len(z.out))
+	if z.contentSum {
+		z.sum.write(z.out)
+	}
+	return nil
+}
+
+// lz4Block decodes the lz4 block src, appending
+// at most max bytes to dst; matches may refer
+// to the data already in dst
+func lz4Block(dst, src []byte, max int) ([]byte, error) {
+	limit := len(dst) + max
+	i := 0
+	for {
+		if i >= len(src) {
+			return nil, lz4Errorf("truncated block")
+		}
+		token := src[i]
+		i++
+		lit := int(token >> 4)
+		if lit == 15 {
+			var ok bool
+			lit, i, ok = lz4Length(src, i, lit)
+			if !ok {
+				return nil, lz4Errorf("truncated block")
+			}
+		}
+		if lit > len(src)-i || lit > limit-len(dst) {
+			return nil, lz4Errorf("literals out of range")
+		}
+		dst = append(dst, src[i:i+lit]...)
+		i += lit
+		if i == len(src) {
+			// the last sequence has only literals
+			return dst, nil
+		}
+		if i+2 > len(src) {
+			return nil, lz4Errorf("truncated block")
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, lz4Errorf("match offset %d out of range", offset)
+		}
+		length := int(token & 15)
+		if length == 15 {
+			var ok bool
+			length, i, ok = lz4Length(src, i, length)
+			if !ok {
+				return nil, lz4Errorf("truncated block")
+			}
+		}
+		length += 4
+		if length > limit-len(dst) {
+			return nil, lz4Errorf("match length out of range")
+		}
+		// the match may overlap the data it
+		// produces, so copy it in pieces that
+		// are no longer than the data available
+		pos := len(dst) - offset
+		for length > 0 {
+			n := length
+			if n > len(dst)-pos {
+				n = len(dst) - pos
+			}
+			dst = append(dst, dst[pos:pos+n]...)
+			pos += n
+			length -= n
+		}
+	}
+}
+
+// lz4Length reads the extra bytes of a length
+// starting at src[i] and adds them to n
+func lz4Length(src []byte, i, n int) (int, int, bool) {
+	for i < len(src) {
+		b := src[i]
+		i++
+		n += int(b)
+		if b != 255 {
+			return n, i, true
+		}
+		if n > lz4LegacyBlock {
+			// longer than any block
+			return n, i, false
+		}
+	}
+	return n, i, false
+}
+
+const (
+	xxh32Prime1 = 2654435761
+	xxh32Prime2 = 2246822519
+	xxh32Prime3 = 3266489917
+	xxh32Prime4 = 668265263
+	xxh32Prime5 = 374761393
+)
+
+// xxh32 computes the 32-bit xxHash
+// (with a zero seed) of a stream of data
+type xxh32 struct {
+	v     [4]uint32
+	total uint64
+	mem   [16]byte
+	n     int
+}
+
+func xxh32Sum(b []byte) uint32 {
+	var h xxh32
+	h.reset()
+	h.write(b)
+	return h.sum()
+}
+
+func (h *xxh32) reset() {
+	p1, p2 := uint32(xxh32Prime1), uint32(xxh32Prime2)
+	h.v = [4]uint32{p1 + p2, p2, 0, -p1}
+	h.total = 0
+	h.n = 0
+}
+
+func xxh32Round(acc, input uint32) uint32 {
+	acc += input * xxh32Prime2
+	return bits.RotateLeft32(acc, 13) * xxh32Prime1
+}
+
+func (h *xxh32) stripe(b []byte) {
+	h.v[0] = xxh32Round(h.v[0], binary.LittleEndian.Uint32(b))
+	h.v[1] = xxh32Round(h.v[1], binary.LittleEndian.Uint32(b[4:]))
+	h.v[2] = xxh32Round(h.v[2], binary.LittleEndian.Uint32(b[8:]))
+	h.v[3] = xxh32Round(h.v[3], binary.LittleEndian.Uint32(b[12:]))
+}
+
+func (h *xxh32) write(b []byte) {
+	h.total += uint64(len(b))
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.n += c
+		b = b[c:]
+		if h.n < len(h.mem) {
+			return
+		}
+		h.stripe(h.mem[:])
+		h.n = 0
+	}
+	for len(b) >= 16 {
+		h.stripe(b)
+		b = b[16:]
+	}
+	h.n = copy(h.mem[:], b)
+}
+
+func (h *xxh32) sum() uint32 {
+	var s uint32
+	if h.total >= 16 {
+		s = bits.RotateLeft32(h.v[0], 1) + bits.RotateLeft32(h.v[1], 7) +
+			bits.RotateLeft32(h.v[2], 12) + bits.RotateLeft32(h.v[3], 18)
+	} else {
+		s = h.v[2] + xxh32Prime5
+	}
+	s += uint32(h.total)
+	b := h.mem[:h.n]
+	for ; len(b) >= 4; b = b[4:] {
+		s += binary.LittleEndian.Uint32(b) * xxh32Prime3
+		s = bits.RotateLeft32(s, 17) * xxh32Prime4
+	}
+	for _, c := range b {
+		s += uint32(c) * xxh32Prime5
+		s = bits.RotateLeft32(s, 11) * xxh32Prime1
+	}
+	s ^= s >> 15
+	s *= xxh32Prime2
+	s ^= s >> 13
+	s *= xxh32Prime3
+	s ^= s >> 16
+	return s
+}
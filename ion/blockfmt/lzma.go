@@ -0,0 +1,553 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	lzmaStates        = 12
+	lzmaPosBitsMax    = 4
+	lzmaLenToPosState = 4
+	lzmaAlignBits     = 4
+	lzmaEndPosModel   = 14
+	lzmaFullDistances = 1 << (lzmaEndPosModel >> 1)
+	lzmaMatchMinLen   = 2
+	lzmaProbBits      = 11
+	lzmaProbInit      = 1 << (lzmaProbBits - 1)
+	lzmaMoveBits      = 5
+	lzmaTopValue      = 1 << 24
+
+	// maxLZMADict is the largest dictionary
+	// that we are willing to allocate;
+	// xz -9 uses 64MiB
+	maxLZMADict = 256 * 1024 * 1024
+)
+
+type lzmaProb uint16
+
+// rangeDecoder decodes the range-coded
+// data of one LZMA2 chunk
+type rangeDecoder struct {
+	src  []byte
+	pos  int
+	rng  uint32
+	code uint32
+}
+
+func (d *rangeDecoder) init(src []byte) bool {
+	d.src, d.pos = src, 0
+	if len(src) < 5 || src[0] != 0 {
+		return false
+	}
+	d.rng = 0xffffffff
+	d.code = binary.BigEndian.Uint32(src[1:])
+	d.pos = 5
+	return d.code != d.rng
+}
+
+// next returns the next input byte; reading
+// past the end of the input returns zeros,
+// which is detected by overrun
+func (d *rangeDecoder) next() byte {
+	d.pos++
+	if d.pos > len(d.src) {
+		return 0
+	}
+	return d.src[d.pos-1]
+}
+
+func (d *rangeDecoder) overrun() bool { return d.pos > len(d.src) }
+
+func (d *rangeDecoder) normalize() {
+	if d.rng < lzmaTopValue {
+		d.rng <<= 8
+		d.code = d.code<<8 | uint32(d.next())
+	}
+}
+
+func (d *rangeDecoder) bit(p *lzmaProb) uint32 {
+	bound := (d.rng >> lzmaProbBits) * uint32(*p)
+	var bit uint32
+	if d.code < bound {
+		*p += ((1 << lzmaProbBits) - *p) >> lzmaMoveBits
+		d.rng = bound
+	} else {
+		*p -= *p >> lzmaMoveBits
+		d.code -= bound
+		d.rng -= bound
+		bit = 1
+	}
+	d.normalize()
+	return bit
+}
+
+func (d *rangeDecoder) direct(n uint32) uint32 {
+	var res uint32
+	for ; n > 0; n-- {
+		d.rng >>= 1
+		d.code -= d.rng
+		t := 0 - (d.code >> 31)
+		d.code += d.rng & t
+		d.normalize()
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+// tree decodes a symbol of n bits
+// with the bit tree of probabilities p
+func (d *rangeDecoder) tree(p []lzmaProb, n uint32) uint32 {
+	m := uint32(1)
+	for i := uint32(0); i < n; i++ {
+		m = m<<1 | d.bit(&p[m])
+	}
+	return m - (1 << n)
+}
+
+// reverse is like tree, but the
+// bits are in the reverse order
+func (d *rangeDecoder) reverse(p []lzmaProb, n uint32) uint32 {
+	m := uint32(1)
+	var sym uint32
+	for i := uint32(0); i < n; i++ {
+		bit := d.bit(&p[m])
+		m = m<<1 | bit
+		sym |= bit << i
+	}
+	return sym
+}
+
+func initProbs(p []lzmaProb) {
+	for i := range p {
+		p[i] = lzmaProbInit
+	}
+}
+
+type lzmaLenDecoder struct {
+	choice  lzmaProb
+	choice2 lzmaProb
+	low     [1 << lzmaPosBitsMax][1 << 3]lzmaProb
+	mid     [1 << lzmaPosBitsMax][1 << 3]lzmaProb
+	high    [1 << 8]lzmaProb
+}
+
+func (l *lzmaLenDecoder) reset() {
+	l.choice, l.choice2 = lzmaProbInit, lzmaProbInit
+	for i := range l.low {
+		initProbs(l.low[i][:])
+		initProbs(l.mid[i][:])
+	}
+	initProbs(l.high[:])
+}
+
+// decode returns the length of a match
+// less lzmaMatchMinLen
+func (l *lzmaLenDecoder) decode(d *rangeDecoder, posState uint32) uint32 {
+	if d.bit(&l.choice) == 0 {
+		return d.tree(l.low[posState][:], 3)
+	}
+	if d.bit(&l.choice2) == 0 {
+		return 8 + d.tree(l.mid[posState][:], 3)
+	}
+	return 16 + d.tree(l.high[:], 8)
+}
+
+// lzmaWindow is the dictionary of an LZMA
+// decoder; it grows up to the dictionary
+// size and then wraps around
+type lzmaWindow struct {
+	buf  []byte
+	size int
+	pos  int   // the position of the next byte in buf
+	n    int64 // the number of bytes since the last reset
+	full bool
+}
+
+func (w *lzmaWindow) reset() {
+	w.buf = w.buf[:0]
+	w.pos, w.n, w.full = 0, 0, false
+}
+
+func (w *lzmaWindow) put(b byte) {
+	if w.pos < len(w.buf) {
+		w.buf[w.pos] = b
+	} else {
+		w.buf = append(w.buf, b)
+	}
+	w.pos++
+	w.n++
+	if w.pos == w.size {
+		w.pos, w.full = 0, true
+	}
+}
+
+// has returns whether the window
+// holds the byte dist bytes back
+func (w *lzmaWindow) has(dist uint32) bool {
+	if w.full {
+		return int64(dist) <= int64(w.size)
+	}
+	return int64(dist) <= int64(w.pos)
+}
+
+// get returns the byte dist bytes back,
+// which must be in the window
+func (w *lzmaWindow) get(dist uint32) byte {
+	i := w.pos - int(dist)
+	if i < 0 {
+		i += w.size
+	}
+	return w.buf[i]
+}
+
+// lzmaDecoder holds the state of an LZMA
+// decoder, which persists across the chunks
+// of an LZMA2 stream unless it is reset
+type lzmaDecoder struct {
+	lc, lp, pb uint32
+
+	literal    []lzmaProb
+	posSlot    [lzmaLenToPosState][1 << 6]lzmaProb
+	posDecoder [1 + lzmaFullDistances - lzmaEndPosModel]lzmaProb
+	align      [1 << lzmaAlignBits]lzmaProb
+	isMatch    [lzmaStates << lzmaPosBitsMax]lzmaProb
+	isRep      [lzmaStates]lzmaProb
+	isRepG0    [lzmaStates]lzmaProb
+	isRepG1    [lzmaStates]lzmaProb
+	isRepG2    [lzmaStates]lzmaProb
+	isRep0Long [lzmaStates << lzmaPosBitsMax]lzmaProb
+	length     lzmaLenDecoder
+	repLength  lzmaLenDecoder
+
+	state uint32
+	rep   [4]uint32
+}
+
+// setProps sets the lc, lp and pb properties
+// from their encoding in props
+func (s *lzmaDecoder) setProps(props byte) bool {
+	if props >= 9*5*5 {
+		return false
+	}
+	p := uint32(props)
+	s.lc, p = p%9, p/9
+	s.lp, s.pb = p%5, p/5
+	// LZMA2 restricts lc+lp
+	if s.lc+s.lp > 4 {
+		return false
+	}
+	n := 0x300 << (s.lc + s.lp)
+	if cap(s.literal) < n {
+		s.literal = make([]lzmaProb, n)
+	}
+	s.literal = s.literal[:n]
+	return true
+}
+
+func (s *lzmaDecoder) reset() {
+	initProbs(s.literal)
+	for i := range s.posSlot {
+		initProbs(s.posSlot[i][:])
+	}
+	initProbs(s.posDecoder[:])
+	initProbs(s.align[:])
+	initProbs(s.isMatch[:])
+	initProbs(s.isRep[:])
+	initProbs(s.isRepG0[:])
+	initProbs(s.isRepG1[:])
+	initProbs(s.isRepG2[:])
+	initProbs(s.isRep0Long[:])
+	s.length.reset()
+	s.repLength.reset()
+	s.state = 0
+	s.rep = [4]uint32{}
+}
+
+// distance decodes the distance of a match
+// (less one) given the length of the match
+// (less lzmaMatchMinLen)
+func (s *lzmaDecoder) distance(d *rangeDecoder, length uint32) uint32 {
+	lenState := length
+	if lenState > lzmaLenToPosState-1 {
+		lenState = lzmaLenToPosState - 1
+	}
+	posSlot := d.tree(s.posSlot[lenState][:], 6)
+	if posSlot < 4 {
+		return posSlot
+	}
+	direct := (posSlot >> 1) - 1
+	dist := (2 | posSlot&1) << direct
+	if posSlot < lzmaEndPosModel {
+		return dist + d.reverse(s.posDecoder[dist-posSlot:], direct)
+	}
+	dist += d.direct(direct-lzmaAlignBits) << lzmaAlignBits
+	return dist + d.reverse(s.align[:], lzmaAlignBits)
+}
+
+// decode decodes n bytes from d into w,
+// appending them to out as well
+func (s *lzmaDecoder) decode(d *rangeDecoder, w *lzmaWindow, out []byte, n int) ([]byte, error) {
+	pbMask := uint32(1)<<s.pb - 1
+	lpMask := uint32(1)<<s.lp - 1
+	for n > 0 {
+		if d.overrun() {
+			return nil, xzErrorf("truncated LZMA chunk")
+		}
+		posState := uint32(w.n) & pbMask
+		state := s.state
+		if d.bit(&s.isMatch[state<<lzmaPosBitsMax+posState]) == 0 {
+			var prev uint32
+			if w.has(1) {
+				prev = uint32(w.get(1))
+			}
+			litState := (uint32(w.n)&lpMask)<<s.lc + prev>>(8-s.lc)
+			probs := s.literal[0x300*litState : 0x300*(litState+1)]
+			sym := uint32(1)
+			if state >= 7 {
+				// the bits of the byte following the
+				// last match are used as context
+				// for as long as they match
+				if !w.has(s.rep[0] + 1) {
+					return nil, xzErrorf("LZMA match distance out of range")
+				}
+				match := uint32(w.get(s.rep[0] + 1))
+				for sym < 0x100 {
+					matchBit := (match >> 7) & 1
+					match <<= 1
+					bit := d.bit(&probs[(1+matchBit)<<8+sym])
+					sym = sym<<1 | bit
+					if matchBit != bit {
+						break
+					}
+				}
+			}
+			for sym < 0x100 {
+				sym = sym<<1 | d.bit(&probs[sym])
+			}
+			w.put(byte(sym))
+			out = append(out, byte(sym))
+			n--
+			switch {
+			case state < 4:
+				s.state = 0
+			case state < 10:
+				s.state = state - 3
+			default:
+				s.state = state - 6
+			}
+			continue
+		}
+		var length uint32
+		if d.bit(&s.isRep[state]) != 0 {
+			if !w.has(1) {
+				return nil, xzErrorf("LZMA match before any data")
+			}
+			if d.bit(&s.isRepG0[state]) == 0 {
+				if d.bit(&s.isRep0Long[state<<lzmaPosBitsMax+posState]) == 0 {
+					// a "short rep" of one byte
+					s.state = 9
+					if state >= 7 {
+						s.state = 11
+					}
+					if !w.has(s.rep[0] + 1) {
+						return nil, xzErrorf("LZMA match distance out of range")
+					}
+					b := w.get(s.rep[0] + 1)
+					w.put(b)
+					out = append(out, b)
+					n--
+					continue
+				}
+			} else {
+				var dist uint32
+				if d.bit(&s.isRepG1[state]) == 0 {
+					dist = s.rep[1]
+				} else {
+					if d.bit(&s.isRepG2[state]) == 0 {
+						dist = s.rep[2]
+					} else {
+						dist = s.rep[3]
+						s.rep[3] = s.rep[2]
+					}
+					s.rep[2] = s.rep[1]
+				}
+				s.rep[1] = s.rep[0]
+				s.rep[0] = dist
+			}
+			length = s.repLength.decode(d, posState)
+			s.state = 8
+			if state >= 7 {
+				s.state = 11
+			}
+		} else {
+			s.rep[3], s.rep[2], s.rep[1] = s.rep[2], s.rep[1], s.rep[0]
+			length = s.length.decode(d, posState)
+			s.state = 7
+			if state >= 7 {
+				s.state = 10
+			}
+			s.rep[0] = s.distance(d, length)
+			if s.rep[0] == 0xffffffff {
+				return nil, xzErrorf("unexpected LZMA end marker")
+			}
+		}
+		length += lzmaMatchMinLen
+		if int(length) > n {
+			return nil, xzErrorf("LZMA match past the end of the chunk")
+		}
+		dist := s.rep[0] + 1
+		if dist == 0 || !w.has(dist) {
+			return nil, xzErrorf("LZMA match distance %d out of range", dist)
+		}
+		for i := uint32(0); i < length; i++ {
+			b := w.get(dist)
+			w.put(b)
+			out = append(out, b)
+		}
+		n -= int(length)
+	}
+	return out, nil
+}
+
+// lzma2Reader decodes an LZMA2 stream
+// one chunk at a time
+type lzma2Reader struct {
+	r      *xzInput
+	window lzmaWindow
+	lzma   lzmaDecoder
+	rc     rangeDecoder
+	src    []byte
+
+	needDict, needProps bool
+}
+
+// lzma2DictSize decodes the dictionary
+// size in the properties of the LZMA2 filter
+func lzma2DictSize(props byte) (int, bool) {
+	if props > 40 {
+		return 0, false
+	}
+	if props == 40 {
+		return 0xffffffff, true
+	}
+	return (2 | int(props&1)) << (props/2 + 11), true
+}
+
+// reset prepares z to decode a new LZMA2 stream
+// with the given dictionary size
+func (z *lzma2Reader) reset(dict int) error {
+	if dict > maxLZMADict {
+		return xzErrorf("dictionary size %d exceeds the maximum %d", dict, maxLZMADict)
+	}
+	z.window.size = dict
+	z.window.reset()
+	z.needDict, z.needProps = true, true
+	return nil
+}
+
+func (z *lzma2Reader) uint16() (int, error) {
+	hi, err := z.r.byte()
+	if err != nil {
+		return 0, err
+	}
+	lo, err := z.r.byte()
+	return int(hi)<<8 | int(lo), err
+}
+
+// chunk decodes the next chunk, appending it to
+// out, or returns io.EOF at the end of the stream
+func (z *lzma2Reader) chunk(out []byte) ([]byte, error) {
+	control, err := z.r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if control == 0 {
+		return nil, io.EOF
+	}
+	if control >= 0xe0 || control == 1 {
+		z.needProps, z.needDict = true, false
+		z.window.reset()
+	} else if z.needDict {
+		return nil, xzErrorf("LZMA2 stream does not start with a dictionary reset")
+	}
+	if control < 0x80 {
+		if control > 2 {
+			return nil, xzErrorf("invalid LZMA2 control byte %#x", control)
+		}
+		size, err := z.uint16()
+		if err != nil {
+			return nil, err
+		}
+		size++
+		start := len(out)
+		out = append(out, make([]byte, size)...)
+		err = z.r.full(out[start:])
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range out[start:] {
+			z.window.put(b)
+		}
+		return out, nil
+	}
+	size := int(control&0x1f) << 16
+	n, err := z.uint16()
+	if err != nil {
+		return nil, err
+	}
+	size += n + 1
+	packed, err := z.uint16()
+	if err != nil {
+		return nil, err
+	}
+	packed++
+	if control >= 0xc0 {
+		props, err := z.r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if !z.lzma.setProps(props) {
+			return nil, xzErrorf("invalid LZMA properties %#x", props)
+		}
+		z.needProps = false
+	} else if z.needProps {
+		return nil, xzErrorf("LZMA2 chunk without properties")
+	}
+	if control >= 0xa0 {
+		z.lzma.reset()
+	}
+	if cap(z.src) < packed {
+		z.src = make([]byte, packed)
+	}
+	src := z.src[:packed]
+	err = z.r.full(src)
+	if err != nil {
+		return nil, err
+	}
+	if !z.rc.init(src) {
+		return nil, xzErrorf("invalid LZMA chunk")
+	}
+	out, err = z.lzma.decode(&z.rc, &z.window, out, size)
+	if err != nil {
+		return nil, err
+	}
+	if z.rc.pos != len(src) || z.rc.code != 0 {
+		return nil, xzErrorf("LZMA chunk size mismatch")
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"io"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+	"github.com/SnellerInc/sneller/parquet"
+)
+
+// parquetConverter converts parquet files;
+// it accepts the same hints as the json
+// format, but only ignore and no_index
+// have any effect
+type parquetConverter struct {
+	hints *jsonrl.Hint
+	tmp   *tempfiles
+}
+
+func (p *parquetConverter) useTemp(tmp *tempfiles) { p.tmp = tmp }
+
+func (p *parquetConverter) Name() string { return "parquet" }
+
+func (p *parquetConverter) UseHints(hints []byte) error {
+	if hints == nil {
+		p.hints = nil
+		return nil
+	}
+	h, err := jsonrl.ParseHint(hints)
+	if err != nil {
+		return err
+	}
+	p.hints = h
+	return nil
+}
+
+func (p *parquetConverter) Convert(r io.Reader, dst *ion.Chunker) error {
+	// the metadata is at the end of a parquet
+	// file and the columns are read from different
+	// places in it, so it must be read at arbitrary
+	// offsets (from a temporary file if necessary)
+	return readerAt(r, "parquet", p.tmp, func(ra io.ReaderAt, size int64) error {
+		return parquet.Convert(ra, size, dst, p.hints)
+	})
+}
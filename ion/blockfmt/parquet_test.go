@@ -0,0 +1,74 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestConvertParquet(t *testing.T) {
+	buf, err := os.ReadFile("testdata/example.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"id": 1, "at": "2022-10-01T12:00:00Z", "tags": ["a", "b"]}`,
+		`{"id": 2, "at": "2022-10-02T12:00:00Z", "tags": []}`,
+	}
+	rows, err := convertRows(t, ".parquet", "", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got rows %s, want %s", rows, want)
+	}
+	for i := range rows {
+		if rows[i] != want[i] {
+			t.Errorf("row %d: got %s, want %s", i, rows[i], want[i])
+		}
+	}
+
+	// a stream that cannot be read at arbitrary
+	// offsets is copied to a temporary file
+	f := SuffixToFormat[".parquet"]()
+	err = f.UseHints([]byte(`{"tags": "ignore"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cn := ion.Chunker{Align: 4096, W: &out}
+	err = f.Convert(io.MultiReader(bytes.NewReader(buf)), &cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cn.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out.Bytes(), []byte("tags")) {
+		t.Error("tags should have been ignored")
+	}
+
+	// corrupt files produce fatal errors
+	_, err = convertRows(t, ".parquet", "", buf[:len(buf)-1])
+	if err == nil || !IsFatal(err) {
+		t.Errorf("expected a fatal error; got %v", err)
+	}
+}
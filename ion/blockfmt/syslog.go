@@ -0,0 +1,192 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bytes"
+
+	"github.com/SnellerInc/sneller/date"
+)
+
+var (
+	syslogFacility   = []byte("facility")
+	syslogSeverity   = []byte("severity")
+	syslogVersion    = []byte("version")
+	syslogTimestamp  = []byte("timestamp")
+	syslogHostname   = []byte("hostname")
+	syslogAppName    = []byte("app_name")
+	syslogProcID     = []byte("procid")
+	syslogMsgID      = []byte("msgid")
+	syslogStructured = []byte("structured_data")
+	syslogMessage    = []byte("message")
+
+	utf8BOM = []byte{0xef, 0xbb, 0xbf}
+)
+
+// parseSyslog parses an RFC5424 syslog message, i.e.
+//
+//	<165>1 2003-10-11T22:14:15.003Z host app 1234 ID47 [id@1 k="v"] message
+//
+// into a record with the fields facility, severity,
+// version, timestamp, hostname, app_name, procid, msgid,
+// structured_data and message. The structured data is a
+// structure with one field for each element, each of which
+// is a structure of its parameters. Fields that are
+// nil (-) in the message are omitted.
+func parseSyslog(line []byte, rec *logRecord) bool {
+	if len(line) < 3 || line[0] != '<' {
+		return false
+	}
+	end := bytes.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return false
+	}
+	pri, ok := parseDigits(line[1:end])
+	if !ok || pri > 191 {
+		return false
+	}
+	rec.addInt(syslogFacility, int64(pri/8))
+	rec.addInt(syslogSeverity, int64(pri%8))
+	rest := line[end+1:]
+
+	var tok []byte
+	tok, rest, ok = cutToken(rest)
+	if !ok || len(tok) > 3 || tok[0] == '0' {
+		return false
+	}
+	version, ok := parseDigits(tok)
+	if !ok {
+		return false
+	}
+	rec.addInt(syslogVersion, int64(version))
+
+	tok, rest, ok = cutToken(rest)
+	if !ok {
+		return false
+	}
+	if !isNil(tok) {
+		t, ok := date.Parse(tok)
+		if !ok {
+			return false
+		}
+		rec.addTime(syslogTimestamp, t)
+	}
+	for _, name := range [][]byte{syslogHostname, syslogAppName, syslogProcID, syslogMsgID} {
+		tok, rest, ok = cutToken(rest)
+		if !ok {
+			return false
+		}
+		if !isNil(tok) {
+			rec.addString(name, tok)
+		}
+	}
+
+	rest, ok = parseStructured(rest, rec)
+	if !ok {
+		return false
+	}
+	if len(rest) == 0 {
+		return true
+	}
+	if rest[0] != ' ' {
+		return false
+	}
+	msg := bytes.TrimPrefix(rest[1:], utf8BOM)
+	if len(msg) > 0 {
+		rec.addString(syslogMessage, msg)
+	}
+	return true
+}
+
+func isNil(tok []byte) bool {
+	return len(tok) == 1 && tok[0] == '-'
+}
+
+// parseDigits parses a short decimal number
+func parseDigits(text []byte) (int, bool) {
+	if len(text) == 0 || len(text) > 9 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// sdName returns the length of the SD-ID or
+// PARAM-NAME at the start of text
+func sdName(text []byte) int {
+	i := 0
+	for i < len(text) && text[i] > ' ' && text[i] < 0x7f && text[i] != '=' && text[i] != ']' && text[i] != '"' {
+		i++
+	}
+	return i
+}
+
+func sdEscape(c byte) bool {
+	return c == '"' || c == '\\' || c == ']'
+}
+
+// parseStructured parses the structured data
+// at the start of text and returns the rest
+func parseStructured(text []byte, rec *logRecord) ([]byte, bool) {
+	if len(text) == 0 {
+		return nil, false
+	}
+	if text[0] == '-' {
+		return text[1:], true
+	}
+	sd := rec.add(syslogStructured, logStruct)
+	for len(text) > 0 && text[0] == '[' {
+		n := sdName(text[1:])
+		if n == 0 {
+			return nil, false
+		}
+		elem := addTo(&sd.sub, text[1:1+n], logStruct)
+		text = text[1+n:]
+		for {
+			if len(text) == 0 {
+				return nil, false
+			}
+			if text[0] == ']' {
+				text = text[1:]
+				break
+			}
+			if text[0] != ' ' {
+				return nil, false
+			}
+			n := sdName(text[1:])
+			if n == 0 || len(text) < n+3 || text[1+n] != '=' || text[2+n] != '"' {
+				return nil, false
+			}
+			name := text[1 : 1+n]
+			text = text[3+n:]
+			end := quoteEnd(text, 0)
+			if end < 0 {
+				return nil, false
+			}
+			addTo(&elem.sub, name, logString).text = rec.unescape(text[:end], sdEscape)
+			text = text[end+1:]
+		}
+	}
+	if len(sd.sub) == 0 {
+		return nil, false
+	}
+	return text, true
+}
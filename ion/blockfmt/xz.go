@@ -0,0 +1,450 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+var errXZFormat = errors.New("invalid xz data")
+
+func xzErrorf(f string, args ...interface{}) error {
+	return fmt.Errorf("xz: "+f+": %w", append(args, errXZFormat)...)
+}
+
+const (
+	xzHeaderSize = 12
+	xzFooterSize = 12
+
+	xzCheckCRC32  = 0x01
+	xzCheckCRC64  = 0x04
+	xzCheckSHA256 = 0x0a
+
+	xzFilterLZMA2 = 0x21
+)
+
+var (
+	xzHeaderMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0}
+	xzFooterMagic = []byte{'Y', 'Z'}
+
+	// xzCheckSize is the size of each
+	// of the possible integrity checks,
+	// including the reserved ones
+	xzCheckSize = [16]int{0, 4, 4, 4, 8, 8, 8, 16, 16, 16, 32, 32, 32, 64, 64, 64}
+
+	crc64Table = crc64.MakeTable(crc64.ECMA)
+)
+
+// xzInput counts the bytes read from an xz stream
+type xzInput struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (in *xzInput) byte() (byte, error) {
+	b, err := in.r.ReadByte()
+	if err != nil {
+		return 0, noEOF(err, io.ErrUnexpectedEOF)
+	}
+	in.n++
+	return b, nil
+}
+
+func (in *xzInput) full(p []byte) error {
+	n, err := io.ReadFull(in.r, p)
+	in.n += int64(n)
+	return noEOF(err, io.ErrUnexpectedEOF)
+}
+
+// xzVLI decodes the variable-length integer at
+// the start of b and returns it and its size,
+// or a size of zero if it is invalid
+func xzVLI(b []byte) (int64, int) {
+	var v int64
+	for i := 0; i < len(b) && i < 9; i++ {
+		v |= int64(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			if i > 0 && b[i] == 0 {
+				// not the shortest encoding
+				return 0, 0
+			}
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+type xzRecord struct {
+	unpadded, uncompressed int64
+}
+
+// xzReader decompresses a sequence of xz streams;
+// the only filter supported is LZMA2, which is
+// the only one used by xz(1) unless a BCJ or
+// delta filter is requested explicitly
+type xzReader struct {
+	in    xzInput
+	lzma2 lzma2Reader
+	flags [2]byte // the stream flags
+	// check is the integrity check of
+	// each block, or nil if there is none
+	// or it is not a known kind
+	check    hash.Hash
+	checkID  byte
+	sum      [64]byte
+	records  []xzRecord
+	inBlock  bool
+	header   int64 // the size of the block header
+	start    int64 // the position of the compressed data
+	packed   int64 // the compressed size, or -1
+	unpacked int64 // the uncompressed size, or -1
+	size     int64 // the uncompressed size so far
+
+	hdr [1024]byte
+	buf []byte
+	out []byte // unread part of buf
+	err error
+}
+
+func xzDecomp(r io.Reader) (io.Reader, error) {
+	x := &xzReader{}
+	x.in.r = bufio.NewReader(r)
+	x.lzma2.r = &x.in
+	n, err := io.ReadFull(x.in.r, x.hdr[:xzHeaderSize])
+	if n == 0 && err == io.EOF {
+		return nil, xzErrorf("empty input")
+	}
+	if err != nil {
+		return nil, noEOF(err, io.ErrUnexpectedEOF)
+	}
+	err = x.streamHeader(x.hdr[:xzHeaderSize])
+	if err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xzReader) Read(p []byte) (int, error) {
+	for len(x.out) == 0 {
+		if x.err != nil {
+			return 0, x.err
+		}
+		x.err = x.next()
+	}
+	n := copy(p, x.out)
+	x.out = x.out[n:]
+	return n, nil
+}
+
+// next decodes the next LZMA2
+// chunk into x.out
+func (x *xzReader) next() error {
+	if !x.inBlock {
+		size, err := x.in.byte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return x.index()
+		}
+		return x.blockHeader(size)
+	}
+	out, err := x.lzma2.chunk(x.buf[:0])
+	if err == io.EOF {
+		return x.endBlock()
+	}
+	if err != nil {
+		return err
+	}
+	x.buf, x.out = out, out
+	x.size += int64(len(out))
+	if x.unpacked >= 0 && x.size > x.unpacked {
+		return xzErrorf("block larger than its uncompressed size %d", x.unpacked)
+	}
+	if x.check != nil {
+		x.check.Write(out)
+	}
+	return nil
+}
+
+func (x *xzReader) streamHeader(hdr []byte) error {
+	if !bytes.Equal(hdr[:len(xzHeaderMagic)], xzHeaderMagic) {
+		return xzErrorf("bad magic number")
+	}
+	flags := hdr[len(xzHeaderMagic):][:2]
+	if crc32.ChecksumIEEE(flags) != binary.LittleEndian.Uint32(hdr[8:]) {
+		return xzErrorf("stream header checksum mismatch")
+	}
+	if flags[0] != 0 || flags[1]&0xf0 != 0 {
+		return xzErrorf("unsupported stream flags %#x", flags)
+	}
+	x.flags = [2]byte{flags[0], flags[1]}
+	x.checkID = flags[1]
+	switch x.checkID {
+	case xzCheckCRC32:
+		x.check = crc32.NewIEEE()
+	case xzCheckCRC64:
+		x.check = crc64.New(crc64Table)
+	case xzCheckSHA256:
+		x.check = sha256.New()
+	default:
+		// the check is skipped for unknown
+		// kinds, as xz(1) does
+		x.check = nil
+	}
+	x.records = x.records[:0]
+	return nil
+}
+
+// blockHeader reads the header of a block
+// which has the given encoded size
+func (x *xzReader) blockHeader(size byte) error {
+	hsize := (int(size) + 1) * 4
+	hdr := x.hdr[:hsize]
+	hdr[0] = size
+	err := x.in.full(hdr[1:])
+	if err != nil {
+		return err
+	}
+	end := hsize - 4
+	if crc32.ChecksumIEEE(hdr[:end]) != binary.LittleEndian.Uint32(hdr[end:]) {
+		return xzErrorf("block header checksum mismatch")
+	}
+	flags := hdr[1]
+	if flags&0x3c != 0 {
+		return xzErrorf("unsupported block flags %#x", flags)
+	}
+	p := 2
+	x.packed, x.unpacked = -1, -1
+	if flags&0x40 != 0 {
+		v, n := xzVLI(hdr[p:end])
+		if n == 0 || v == 0 {
+			return xzErrorf("invalid compressed size")
+		}
+		x.packed, p = v, p+n
+	}
+	if flags&0x80 != 0 {
+		v, n := xzVLI(hdr[p:end])
+		if n == 0 {
+			return xzErrorf("invalid uncompressed size")
+		}
+		x.unpacked, p = v, p+n
+	}
+	if flags&3 != 0 {
+		return xzErrorf("filter chains are not supported")
+	}
+	id, n := xzVLI(hdr[p:end])
+	if n == 0 {
+		return xzErrorf("invalid filter id")
+	}
+	p += n
+	if id != xzFilterLZMA2 {
+		return xzErrorf("filter %#x is not supported", id)
+	}
+	props, n := xzVLI(hdr[p:end])
+	if n == 0 || props != 1 || p+n >= end {
+		return xzErrorf("invalid LZMA2 properties")
+	}
+	p += n
+	dict, ok := lzma2DictSize(hdr[p])
+	if !ok {
+		return xzErrorf("invalid LZMA2 dictionary size %#x", hdr[p])
+	}
+	for _, b := range hdr[p+1 : end] {
+		if b != 0 {
+			return xzErrorf("non-zero block header padding")
+		}
+	}
+	err = x.lzma2.reset(dict)
+	if err != nil {
+		return err
+	}
+	if x.check != nil {
+		x.check.Reset()
+	}
+	x.inBlock = true
+	x.header = int64(hsize)
+	x.start = x.in.n
+	x.size = 0
+	return nil
+}
+
+// endBlock reads the padding and
+// check at the end of a block
+func (x *xzReader) endBlock() error {
+	packed := x.in.n - x.start
+	if x.packed >= 0 && packed != x.packed {
+		return xzErrorf("compressed size %d does not match the block header (%d)", packed, x.packed)
+	}
+	if x.unpacked >= 0 && x.size != x.unpacked {
+		return xzErrorf("uncompressed size %d does not match the block header (%d)", x.size, x.unpacked)
+	}
+	for pad := packed; pad%4 != 0; pad++ {
+		b, err := x.in.byte()
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			return xzErrorf("non-zero block padding")
+		}
+	}
+	size := xzCheckSize[x.checkID]
+	sum := x.sum[:size]
+	err := x.in.full(sum)
+	if err != nil {
+		return err
+	}
+	if x.check != nil {
+		want := x.check.Sum(x.sum[size:size])
+		if x.checkID != xzCheckSHA256 {
+			// CRCs are stored in little-endian order
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+		if !bytes.Equal(sum, want) {
+			return xzErrorf("block check mismatch")
+		}
+	}
+	x.records = append(x.records, xzRecord{
+		unpadded:     x.header + packed + int64(size),
+		uncompressed: x.size,
+	})
+	x.inBlock = false
+	return nil
+}
+
+// index reads the index, footer and padding
+// of a stream once its index indicator has
+// been read, and then the header of the next
+// stream; it returns io.EOF if there are no
+// more streams
+func (x *xzReader) index() error {
+	start := x.in.n - 1
+	crc := crc32.Update(0, crc32.IEEETable, []byte{0})
+	vli := func() (int64, error) {
+		var b [9]byte
+		for i := range b {
+			c, err := x.in.byte()
+			if err != nil {
+				return 0, err
+			}
+			b[i] = c
+			if c&0x80 == 0 {
+				crc = crc32.Update(crc, crc32.IEEETable, b[:i+1])
+				v, n := xzVLI(b[:i+1])
+				if n == 0 {
+					return 0, xzErrorf("invalid integer in index")
+				}
+				return v, nil
+			}
+		}
+		return 0, xzErrorf("invalid integer in index")
+	}
+	count, err := vli()
+	if err != nil {
+		return err
+	}
+	if count != int64(len(x.records)) {
+		return xzErrorf("index has %d records for %d blocks", count, len(x.records))
+	}
+	for i := range x.records {
+		unpadded, err := vli()
+		if err != nil {
+			return err
+		}
+		uncompressed, err := vli()
+		if err != nil {
+			return err
+		}
+		if unpadded != x.records[i].unpadded || uncompressed != x.records[i].uncompressed {
+			return xzErrorf("index does not match block %d", i)
+		}
+	}
+	for (x.in.n-start)%4 != 0 {
+		b, err := x.in.byte()
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			return xzErrorf("non-zero index padding")
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, []byte{0})
+	}
+	var sum [4]byte
+	err = x.in.full(sum[:])
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(sum[:]) != crc {
+		return xzErrorf("index checksum mismatch")
+	}
+	size := x.in.n - start
+
+	footer := x.hdr[:xzFooterSize]
+	err = x.in.full(footer)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(footer[10:], xzFooterMagic) {
+		return xzErrorf("bad footer magic number")
+	}
+	if crc32.ChecksumIEEE(footer[4:10]) != binary.LittleEndian.Uint32(footer) {
+		return xzErrorf("stream footer checksum mismatch")
+	}
+	if (int64(binary.LittleEndian.Uint32(footer[4:]))+1)*4 != size {
+		return xzErrorf("stream footer does not match the index size")
+	}
+	if footer[8] != x.flags[0] || footer[9] != x.flags[1] {
+		return xzErrorf("stream footer flags do not match the header")
+	}
+	return x.padding()
+}
+
+// padding reads the padding following a stream
+// and then the header of the next stream,
+// or returns io.EOF if there is none
+func (x *xzReader) padding() error {
+	for {
+		hdr := x.hdr[:xzHeaderSize]
+		n, err := io.ReadFull(x.in.r, hdr[:4])
+		if n == 0 && err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return xzErrorf("invalid stream padding")
+		}
+		if hdr[0] == 0 && hdr[1] == 0 && hdr[2] == 0 && hdr[3] == 0 {
+			continue
+		}
+		if !bytes.Equal(hdr[:4], xzHeaderMagic[:4]) {
+			return xzErrorf("trailing garbage")
+		}
+		err = x.in.full(hdr[4:])
+		if err != nil {
+			return err
+		}
+		return x.streamHeader(hdr)
+	}
+}
@@ -15,6 +15,7 @@
 package ion
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -149,3 +150,18 @@ func FromJSON(st *Symtab, d *json.Decoder) (Datum, error) {
 	}
 	return dat, err
 }
+
+// AppendJSON appends the JSON text representation
+// of d to dst using the same conventions as ToJSON.
+func AppendJSON(dst []byte, d Datum) ([]byte, error) {
+	if d.Empty() {
+		return dst, fmt.Errorf("ion.AppendJSON: empty datum")
+	}
+	var st Symtab
+	var buf Buffer
+	var s scratch
+	d.Encode(&buf, &st)
+	out := bytes.NewBuffer(dst)
+	_, _, err := toJSON(&st, out, buf.Bytes(), &s, false)
+	return out.Bytes(), err
+}
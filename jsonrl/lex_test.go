@@ -314,6 +314,38 @@ func TestParseWithHints(t *testing.T) {
 	}
 }
 
+func TestHintLookup(t *testing.T) {
+	h, err := ParseHint([]byte(`{"a": "ignore", "b.c": "no_index", "d.[*]": "no_index", "e.*": "ignore"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		path            []string
+		ignore, noIndex bool
+	}{
+		{[]string{"a"}, true, false},
+		{[]string{"a", "x"}, true, false},
+		{[]string{"b"}, false, false},
+		{[]string{"b", "c"}, false, true},
+		{[]string{"b", "d"}, false, false},
+		{[]string{"d"}, false, false},
+		{[]string{"d", ""}, false, true},
+		{[]string{"e"}, false, false},
+		{[]string{"e", "f", "", "g"}, true, false},
+		{[]string{"z"}, false, false},
+	}
+	for _, tc := range tcs {
+		ignore, noIndex := h.Lookup(tc.path)
+		if ignore != tc.ignore || noIndex != tc.noIndex {
+			t.Errorf("path %q: got ignore=%v noIndex=%v", tc.path, ignore, noIndex)
+		}
+	}
+	var none *Hint
+	if ignore, noIndex := none.Lookup([]string{"a"}); ignore || noIndex {
+		t.Error("nil hint should not match anything")
+	}
+}
+
 func timestamp(s string) ion.Datum {
 	t, ok := date.Parse([]byte(s))
 	if !ok {
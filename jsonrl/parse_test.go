@@ -16,6 +16,7 @@ package jsonrl_test
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -76,3 +77,76 @@ func TestConvertTestdata(t *testing.T) {
 	}
 	fs.WalkDir(dir, ".", walk)
 }
+
+func TestValueParser(t *testing.T) {
+	tcs := []struct {
+		text string
+		want string // empty for an error
+	}{
+		{`1`, `1`},
+		{` "xyz" `, `"xyz"`},
+		{`"2022-03-04T05:06:07Z"`, `"2022-03-04T05:06:07Z"`},
+		{`[1, {"a": null}]`, `[1, {"a": null}]`},
+		{`{"a": {"b": [true]}, "c": "d"}`, `{"a": {"b": [true]}, "c": "d"}`},
+		{`{"a": `, ""},
+		{`1, "$": 2`, ""},
+		{`1} {"$": 2`, ""},
+		{`[[[`, ""},
+		{``, ""},
+		{`{"b": 2, "a": 1}`, `{"a": 1, "b": 2}`},
+	}
+	var p jsonrl.ValueParser
+	// parse every value twice in order to
+	// check that no state leaks between calls
+	for round := 0; round < 2; round++ {
+		for i := range tcs {
+			d, err := p.Parse([]byte(tcs[i].text))
+			if tcs[i].want == "" {
+				if err == nil {
+					t.Errorf("%q: expected an error", tcs[i].text)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%q: %s", tcs[i].text, err)
+				continue
+			}
+			want, err := jsonrl.ParseValue([]byte(tcs[i].text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.Equal(want) {
+				t.Errorf("%q: results differ", tcs[i].text)
+			}
+			text, err := ion.AppendJSON(nil, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(text) != tcs[i].want {
+				t.Errorf("%q: got %s, want %s", tcs[i].text, text, tcs[i].want)
+			}
+		}
+	}
+	d, err := p.Parse([]byte(`"2022-03-04T05:06:07Z"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Timestamp(); !ok {
+		t.Errorf("got %s, want a timestamp", d.Type())
+	}
+	// the symbol table is bounded
+	for i := 0; i < 10000; i++ {
+		d, err := p.Parse([]byte(fmt.Sprintf(`{"field%d": %d}`, i, i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := d.Struct()
+		f, ok := s.FieldByName(fmt.Sprintf("field%d", i))
+		if !ok {
+			t.Fatalf("value %d: missing field", i)
+		}
+		if n, _ := f.Value.Uint(); n != uint64(i) {
+			t.Fatalf("value %d: got %d", i, n)
+		}
+	}
+}
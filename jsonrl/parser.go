@@ -415,3 +415,100 @@ func Convert(src io.Reader, dst *ion.Chunker, hints *Hint) error {
 	}
 	return dst.Flush()
 }
+
+// maxValueSymbols is the number of symbols
+// at which a ValueParser discards its symbol table
+const maxValueSymbols = 4096
+
+// ValueParser parses individual JSON values
+// (which need not be records) into their ion
+// representation. Values are interpreted
+// the same way that Convert interprets them,
+// so, for example, strings that look like
+// timestamps are converted to timestamps.
+//
+// A ValueParser reuses its buffers and parser
+// state across calls to Parse, so a stream of
+// values should be parsed with one ValueParser.
+// The zero value of ValueParser is ready to use.
+type ValueParser struct {
+	cn   ion.Chunker
+	st   *state
+	tb   parser
+	in   reader
+	wrap []byte
+}
+
+// Parse parses src as a single JSON value.
+// The returned datum refers to memory owned
+// by p, so it is only valid until the next
+// call to Parse.
+func (p *ValueParser) Parse(src []byte) (ion.Datum, error) {
+	if p.st == nil {
+		p.st = newState(&p.cn)
+		p.tb.output = p.st
+	}
+	// wrap the value in a record so that
+	// it can be parsed as a top-level object
+	p.wrap = append(p.wrap[:0], `{"$":`...)
+	p.wrap = append(p.wrap, src...)
+	p.wrap = append(p.wrap, '}')
+	p.in = reader{buf: p.wrap, atEOF: true}
+
+	// reset the state left behind
+	// by an earlier parse error
+	p.tb.depth = 0
+	p.st.stack = p.st.stack[:0]
+	p.st.flags = 0
+	p.st.oldflags = p.st.oldflags[:0]
+	p.st.UseHints(nil)
+	p.cn.Reset()
+	if p.cn.Symbols.MaxID() > maxValueSymbols {
+		p.cn.Symbols.Reset()
+	}
+	// the ion representation of a value is
+	// never more than a small multiple of
+	// the size of its JSON representation
+	p.cn.Align = 8*len(p.wrap) + 1024
+
+	err := p.tb.lexToplevel(&p.in)
+	if err != nil {
+		return ion.Empty, err
+	}
+	if p.tb.tok != tokLBrace {
+		return ion.Empty, ErrNoMatch
+	}
+	err = p.tb.parseRecord(&p.in)
+	if err != nil {
+		return ion.Empty, err
+	}
+	err = p.tb.lexToplevel(&p.in)
+	if err != nil {
+		return ion.Empty, err
+	}
+	if p.tb.tok != tokEOF {
+		return ion.Empty, fmt.Errorf("%w: more than one value", ErrNoMatch)
+	}
+	d, _, err := ion.ReadDatum(&p.cn.Symbols, p.cn.Bytes())
+	if err != nil {
+		return ion.Empty, err
+	}
+	s, ok := d.Struct()
+	if !ok || s.Len() != 1 {
+		return ion.Empty, fmt.Errorf("%w: more than one value", ErrNoMatch)
+	}
+	f, ok := s.FieldByName("$")
+	if !ok {
+		return ion.Empty, ErrNoMatch
+	}
+	return f.Value, nil
+}
+
+// ParseValue parses src as a single JSON value
+// using a new ValueParser.
+//
+// See also: ValueParser.Parse
+func ParseValue(src []byte) (ion.Datum, error) {
+	var p ValueParser
+	return p.Parse(src)
+}
@@ -301,6 +301,23 @@ func (n *Hint) getNext(label []byte) *Hint {
 	return n
 }
 
+// Lookup returns whether the value at path
+// should be ignored or left out of the sparse index.
+// Each element of path is a field label, and
+// an empty label stands for the elements of a list.
+// A value is ignored if any of its parents is ignored.
+func (n *Hint) Lookup(path []string) (ignore, noIndex bool) {
+	s := makeHintState(n)
+	for i := range path {
+		s.enter()
+		s.field([]byte(path[i]))
+		if s.hints&hintIgnore != 0 {
+			return true, false
+		}
+	}
+	return false, s.hints&hintNoIndex != 0
+}
+
 func (n *Hint) encodeRuleString(path string, hints hints) error {
 	segments := strings.Split(path, ".")
 	return n.encodeRule(segments, hints)
@@ -0,0 +1,404 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/klauspost/compress/snappy"
+
+	"github.com/SnellerInc/sneller/compr"
+)
+
+// maxPageSize is the largest
+// uncompressed page that we accept
+const maxPageSize = 256 << 20
+
+// maxChunkSize is the largest compressed
+// column chunk that we accept; the whole
+// chunk is read into memory at once
+const maxChunkSize = 2 * maxPageSize
+
+// maxSymbolDict is the largest dictionary
+// of strings that is written as symbols;
+// strings from larger dictionaries are
+// written as ordinary strings so that
+// they do not flood the symbol table
+const maxSymbolDict = 256
+
+// column holds the contents of one
+// leaf column of the current row group
+type column struct {
+	leaf *node
+
+	// rep and def are the repetition and
+	// definition levels; they are empty if the
+	// maximum repetition or definition level is 0
+	rep, def []int32
+	levels   int
+	values
+	// symbol indicates, for each value in
+	// values.bytes, whether the value should
+	// be written as a symbol
+	symbol []bool
+
+	dict        values
+	symbolic    bool // dict can be written as symbols
+	indexes     []int32
+	pos, valpos int // read position in levels and values
+}
+
+// load reads the chunk c of the column
+func (c *column) load(r io.ReaderAt, size int64, cc *columnChunk) error {
+	c.rep = c.rep[:0]
+	c.def = c.def[:0]
+	c.levels = 0
+	c.values.reset()
+	c.symbol = c.symbol[:0]
+	c.dict.reset()
+	c.symbolic = false
+	c.pos, c.valpos = 0, 0
+
+	m := &cc.meta
+	start := m.dataPageOffset
+	if m.dictionaryPageOffset > 0 && m.dictionaryPageOffset < start {
+		start = m.dictionaryPageOffset
+	}
+	if m.typ != c.leaf.typ {
+		return corrupt("column %s: type %d does not match the schema", c.leaf.path(), m.typ)
+	}
+	if start < 0 || m.totalCompressedSize < 0 || start+m.totalCompressedSize > size {
+		return corrupt("column %s: chunk out of range", c.leaf.path())
+	}
+	if m.totalCompressedSize > maxChunkSize {
+		return fmt.Errorf("column %s: chunk of %d bytes: %w", c.leaf.path(), m.totalCompressedSize, ErrTooLarge)
+	}
+	buf := make([]byte, m.totalCompressedSize)
+	_, err := r.ReadAt(buf, start)
+	if err != nil {
+		return err
+	}
+	for int64(c.levels) < m.numValues && len(buf) > 0 {
+		t := thrift{buf: buf}
+		var h pageHeader
+		err := t.pageHeader(&h)
+		if err != nil {
+			return err
+		}
+		buf = t.buf
+		if h.compressedSize < 0 || int(h.compressedSize) > len(buf) {
+			return corrupt("column %s: page size %d out of range", c.leaf.path(), h.compressedSize)
+		}
+		if h.uncompressedSize < 0 || h.uncompressedSize > maxPageSize || h.numValues < 0 {
+			return corrupt("column %s: bad page header", c.leaf.path())
+		}
+		if h.typ != pageDictionary && int64(c.levels)+int64(h.numValues) > m.numValues {
+			return corrupt("column %s: too many values", c.leaf.path())
+		}
+		page := buf[:h.compressedSize]
+		buf = buf[h.compressedSize:]
+		switch h.typ {
+		case pageDictionary:
+			err = c.loadDict(&h, m.codec, page)
+		case pageData:
+			err = c.loadData(&h, m.codec, page)
+		case pageDataV2:
+			err = c.loadDataV2(&h, m.codec, page)
+		}
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.leaf.path(), err)
+		}
+	}
+	if int64(c.levels) != m.numValues {
+		return corrupt("column %s: found %d values; expected %d", c.leaf.path(), c.levels, m.numValues)
+	}
+	return nil
+}
+
+func decompress(codec int32, src []byte, size int32) ([]byte, error) {
+	var dst []byte
+	var err error
+	switch codec {
+	case codecUncompressed:
+		dst = src
+	case codecSnappy:
+		n, err := snappy.DecodedLen(src)
+		if err != nil {
+			return nil, corrupt("snappy: %s", err)
+		}
+		if n != int(size) {
+			break
+		}
+		dst, err = snappy.Decode(make([]byte, n), src)
+		if err != nil {
+			return nil, corrupt("snappy: %s", err)
+		}
+	case codecGzip:
+		var zr *gzip.Reader
+		zr, err = gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, corrupt("gzip: %s", err)
+		}
+		dst = make([]byte, size)
+		_, err = io.ReadFull(zr, dst)
+		if err != nil {
+			return nil, corrupt("gzip: %s", err)
+		}
+	case codecZstd:
+		dst, err = compr.DecodeZstd(src, make([]byte, 0, size))
+		if err != nil {
+			return nil, corrupt("zstd: %s", err)
+		}
+	default:
+		return nil, fmt.Errorf("compression codec %d: %w", codec, ErrUnsupported)
+	}
+	if len(dst) != int(size) {
+		return nil, corrupt("page decompressed to %d bytes; expected %d", len(dst), size)
+	}
+	return dst, nil
+}
+
+func (c *column) loadDict(h *pageHeader, codec int32, page []byte) error {
+	data, err := decompress(codec, page, h.uncompressedSize)
+	if err != nil {
+		return err
+	}
+	if h.encoding != encPlain && h.encoding != encPlainDictionary {
+		return fmt.Errorf("dictionary encoding %d: %w", h.encoding, ErrUnsupported)
+	}
+	c.dict.reset()
+	err = decodePlain(&c.dict, c.leaf.typ, c.leaf.typeLength, data, int(h.numValues))
+	if err != nil {
+		return err
+	}
+	c.symbolic = c.leaf.kind == kindString && len(c.dict.bytes) <= maxSymbolDict
+	return nil
+}
+
+// readLevels appends n levels with
+// the given maximum from src to dst
+func readLevels(dst []int32, max int, src []byte, n int) ([]int32, error) {
+	if max == 0 {
+		return dst, nil
+	}
+	return decodeHybrid(dst, src, bits.Len(uint(max)), n)
+}
+
+func (c *column) loadData(h *pageHeader, codec int32, page []byte) error {
+	data, err := decompress(codec, page, h.uncompressedSize)
+	if err != nil {
+		return err
+	}
+	n := int(h.numValues)
+	// levels are prefixed by their length
+	prefixed := func(max int) ([]byte, error) {
+		if max == 0 {
+			return nil, nil
+		}
+		if len(data) < 4 {
+			return nil, corrupt("truncated levels")
+		}
+		size := binary.LittleEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, corrupt("levels length %d out of range", size)
+		}
+		lv := data[4 : 4+size]
+		data = data[4+size:]
+		return lv, nil
+	}
+	rep, err := prefixed(c.leaf.maxRep)
+	if err == nil {
+		c.rep, err = readLevels(c.rep, c.leaf.maxRep, rep, n)
+	}
+	if err != nil {
+		return err
+	}
+	def, err := prefixed(c.leaf.maxDef)
+	if err == nil {
+		c.def, err = readLevels(c.def, c.leaf.maxDef, def, n)
+	}
+	if err != nil {
+		return err
+	}
+	return c.loadValues(h.encoding, data, n)
+}
+
+func (c *column) loadDataV2(h *pageHeader, codec int32, page []byte) error {
+	if h.repLength < 0 || h.defLength < 0 || int64(h.repLength)+int64(h.defLength) > int64(len(page)) {
+		return corrupt("levels length out of range")
+	}
+	n := int(h.numValues)
+	var err error
+	c.rep, err = readLevels(c.rep, c.leaf.maxRep, page[:h.repLength], n)
+	if err != nil {
+		return err
+	}
+	page = page[h.repLength:]
+	c.def, err = readLevels(c.def, c.leaf.maxDef, page[:h.defLength], n)
+	if err != nil {
+		return err
+	}
+	page = page[h.defLength:]
+	if !h.uncompressed {
+		size := h.uncompressedSize - h.repLength - h.defLength
+		if size < 0 {
+			return corrupt("bad page header")
+		}
+		page, err = decompress(codec, page, size)
+		if err != nil {
+			return err
+		}
+	}
+	return c.loadValues(h.encoding, page, n)
+}
+
+// loadValues decodes the values of a data page
+// with the given number of levels
+func (c *column) loadValues(encoding int32, data []byte, n int) error {
+	start := c.levels
+	c.levels += n
+	count := n
+	if c.leaf.maxDef > 0 {
+		count = 0
+		for _, d := range c.def[start:] {
+			if int(d) == c.leaf.maxDef {
+				count++
+			}
+		}
+	}
+	typ := c.leaf.typ
+	before := c.values.len()
+	var err error
+	switch encoding {
+	case encPlain:
+		err = decodePlain(&c.values, typ, c.leaf.typeLength, data, count)
+	case encPlainDictionary, encRLEDictionary:
+		err = c.loadIndexes(data, count)
+	case encRLE:
+		if typ != typeBoolean {
+			return fmt.Errorf("RLE encoding of type %d: %w", typ, ErrUnsupported)
+		}
+		if len(data) < 4 {
+			return corrupt("truncated page")
+		}
+		c.indexes, err = decodeHybrid(c.indexes[:0], data[4:], 1, count)
+		for _, v := range c.indexes {
+			c.ints = append(c.ints, int64(v))
+		}
+	case encDeltaBinaryPacked:
+		if typ != typeInt32 && typ != typeInt64 {
+			return fmt.Errorf("DELTA_BINARY_PACKED encoding of type %d: %w", typ, ErrUnsupported)
+		}
+		c.ints, _, err = decodeDeltaBinary(c.ints, data, count)
+		if typ == typeInt32 {
+			for i := before; i < len(c.ints); i++ {
+				c.ints[i] = int64(int32(c.ints[i]))
+			}
+		}
+	case encDeltaLengthByteArray:
+		if typ != typeByteArray {
+			return fmt.Errorf("DELTA_LENGTH_BYTE_ARRAY encoding of type %d: %w", typ, ErrUnsupported)
+		}
+		c.bytes, _, err = decodeDeltaLength(c.bytes, data, count)
+	case encDeltaByteArray:
+		if typ != typeByteArray && typ != typeFixedLenByteArray {
+			return fmt.Errorf("DELTA_BYTE_ARRAY encoding of type %d: %w", typ, ErrUnsupported)
+		}
+		c.bytes, err = decodeDeltaByteArray(c.bytes, data, count)
+	case encByteStreamSplit:
+		err = decodeByteStreamSplit(&c.values, typ, c.leaf.typeLength, data, count)
+	default:
+		return fmt.Errorf("encoding %d: %w", encoding, ErrUnsupported)
+	}
+	if err != nil {
+		return err
+	}
+	if c.values.len() != before+count {
+		return corrupt("page has %d values; expected %d", c.values.len()-before, count)
+	}
+	if c.leaf.kind == kindString {
+		dict := c.symbolic && (encoding == encPlainDictionary || encoding == encRLEDictionary)
+		for len(c.symbol) < len(c.bytes) {
+			c.symbol = append(c.symbol, dict)
+		}
+	}
+	return nil
+}
+
+// loadIndexes decodes count dictionary indexes
+// from data and appends the values they refer to
+func (c *column) loadIndexes(data []byte, count int) error {
+	if len(data) == 0 {
+		if count == 0 {
+			return nil
+		}
+		return corrupt("truncated page")
+	}
+	var err error
+	c.indexes, err = decodeHybrid(c.indexes[:0], data[1:], int(data[0]), count)
+	if err != nil {
+		return err
+	}
+	d := &c.dict
+	size := d.len()
+	for _, i := range c.indexes {
+		if i < 0 || int(i) >= size {
+			return corrupt("dictionary index %d out of range", i)
+		}
+		switch c.leaf.typ {
+		case typeBoolean, typeInt32, typeInt64:
+			c.ints = append(c.ints, d.ints[i])
+		case typeFloat, typeDouble:
+			c.floats = append(c.floats, d.floats[i])
+		default:
+			c.bytes = append(c.bytes, d.bytes[i])
+		}
+	}
+	return nil
+}
+
+// peek returns the repetition and definition
+// levels at the current position; past the
+// end of the column both are zero
+func (c *column) peek() (rep, def int) {
+	if c.pos >= c.levels {
+		return 0, 0
+	}
+	if c.leaf.maxRep > 0 {
+		rep = int(c.rep[c.pos])
+	}
+	if c.leaf.maxDef > 0 {
+		def = int(c.def[c.pos])
+	}
+	return rep, def
+}
+
+// advance moves past the current position
+// and returns the index of its value, or
+// -1 if there is no value at the position
+func (c *column) advance() int {
+	_, def := c.peek()
+	c.pos++
+	if def != c.leaf.maxDef {
+		return -1
+	}
+	c.valpos++
+	return c.valpos - 1
+}
@@ -0,0 +1,393 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package parquet converts Apache Parquet files into ion.
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+)
+
+var (
+	// ErrCorrupt is returned when a file
+	// is not a valid parquet file.
+	ErrCorrupt = errors.New("parquet: corrupt file")
+	// ErrUnsupported is returned when a file
+	// uses a feature that is not supported.
+	ErrUnsupported = errors.New("parquet: unsupported feature")
+	// ErrTooLarge is returned when a column
+	// chunk is larger than we are willing
+	// to hold in memory.
+	ErrTooLarge = errors.New("parquet: column chunk too large")
+)
+
+const (
+	magic = "PAR1"
+	// maxFooterSize is the largest
+	// file metadata that we accept
+	maxFooterSize = 64 << 20
+)
+
+// julianEpoch is the Julian day
+// of the Unix epoch
+const julianEpoch = 2440588
+
+func readFooter(r io.ReaderAt, size int64) (*fileMetaData, error) {
+	if size < 12 {
+		return nil, corrupt("file too small")
+	}
+	var tail [8]byte
+	_, err := r.ReadAt(tail[:], size-8)
+	if err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != magic {
+		if string(tail[4:]) == "PARE" {
+			return nil, fmt.Errorf("encrypted footer: %w", ErrUnsupported)
+		}
+		return nil, corrupt("bad magic number")
+	}
+	length := int64(binary.LittleEndian.Uint32(tail[:]))
+	if length > size-12 || length > maxFooterSize {
+		return nil, corrupt("footer length %d out of range", length)
+	}
+	buf := make([]byte, length)
+	_, err = r.ReadAt(buf, size-8-length)
+	if err != nil {
+		return nil, err
+	}
+	m := new(fileMetaData)
+	t := thrift{buf: buf}
+	err = t.fileMetaData(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Convert reads the parquet file in r, which
+// is size bytes long, and writes each of its rows
+// to dst as an ion structure. The file is read one
+// row group at a time.
+//
+// Groups are written as structures, and both LIST
+// and MAP groups and repeated fields are written as
+// lists. (The elements of a MAP are structures with
+// key and value fields.) Missing values are omitted
+// from structures and written as null in lists.
+// TIMESTAMP, DATE and INT96 values are written as
+// timestamps, and strings from small dictionaries
+// are written as symbols.
+//
+// If hints is non-nil, values matching an ignore
+// hint are not written, and timestamps matching
+// a no_index hint are not added to the sparse
+// index. (Other hints are ignored, since the
+// file already describes the type of each value.)
+func Convert(r io.ReaderAt, size int64, dst *ion.Chunker, hints *jsonrl.Hint) error {
+	m, err := readFooter(r, size)
+	if err != nil {
+		return err
+	}
+	root, leaves, err := buildSchema(m.schema)
+	if err != nil {
+		return err
+	}
+	root.prepare(hints, nil, true)
+	w := writer{dst: dst}
+	for i := range m.rowGroups {
+		rg := &m.rowGroups[i]
+		if len(rg.columns) != len(leaves) {
+			return corrupt("row group %d has %d columns; expected %d", i, len(rg.columns), len(leaves))
+		}
+		for j, l := range leaves {
+			if l.col == nil {
+				continue
+			}
+			if !slices.Equal(rg.columns[j].meta.path, l.names()) {
+				return corrupt("row group %d: column %d is not %s", i, j, l.path())
+			}
+			err := l.col.load(r, size, &rg.columns[j])
+			if err != nil {
+				return err
+			}
+		}
+		for row := int64(0); row < rg.numRows; row++ {
+			for _, c := range root.cols {
+				if rep, _ := c.peek(); c.pos >= c.levels || rep != 0 {
+					return corrupt("column %s: row %d does not begin a record", c.leaf.path(), row)
+				}
+			}
+			w.writeStruct(root)
+			if w.err != nil {
+				return w.err
+			}
+			err := dst.Commit()
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range root.cols {
+			if c.pos != c.levels {
+				return corrupt("column %s has %d values after the last row", c.leaf.path(), c.levels-c.pos)
+			}
+		}
+	}
+	return nil
+}
+
+// writer writes rows to an ion.Chunker
+type writer struct {
+	dst  *ion.Chunker
+	path ion.Symbuf
+	err  error
+}
+
+// present returns whether the value of n is present
+func (w *writer) present(n *node) bool {
+	_, def := n.cols[0].peek()
+	return def >= n.maxDef
+}
+
+// skip moves past a value of n that is not present
+func (w *writer) skip(n *node) {
+	for _, c := range n.cols {
+		c.advance()
+	}
+}
+
+// symbolize computes the symbols
+// of the fields of g and the order
+// in which they are written
+func (w *writer) symbolize(g *node) {
+	st := &w.dst.Symbols
+	same := len(g.order) == len(g.fields)
+	for i := 0; same && i < len(g.fields); i++ {
+		same = st.Get(g.fields[i].sym) == g.fields[i].name
+	}
+	if same {
+		return
+	}
+	g.order = g.order[:0]
+	for i, f := range g.fields {
+		f.sym = st.Intern(f.name)
+		g.order = append(g.order, i)
+	}
+	slices.SortFunc(g.order, func(i, j int) bool {
+		return g.fields[i].sym < g.fields[j].sym
+	})
+}
+
+func (w *writer) writeStruct(g *node) {
+	w.symbolize(g)
+	b := &w.dst.Buffer
+	b.BeginStruct(-1)
+	for _, i := range g.order {
+		f := g.fields[i]
+		if !w.present(f) {
+			w.skip(f)
+			continue
+		}
+		b.BeginField(f.sym)
+		if f.repetition == repeated {
+			b.BeginList(-1)
+			w.writeElems(f, f)
+			b.EndList()
+			continue
+		}
+		w.writeValue(f)
+	}
+	b.EndStruct()
+}
+
+// writeValue writes the value of n, which is present
+func (w *writer) writeValue(n *node) {
+	switch n.kind {
+	case kindGroup:
+		w.writeStruct(n)
+	case kindList:
+		b := &w.dst.Buffer
+		b.BeginList(-1)
+		if w.present(n.list) {
+			w.writeElems(n.list, n.elem)
+		} else {
+			w.skip(n.list)
+		}
+		b.EndList()
+	default:
+		w.writePrimitive(n)
+	}
+}
+
+// writeElems writes the elements of the
+// repeated node r, which has at least one element
+func (w *writer) writeElems(r, elem *node) {
+	first := r.cols[0]
+	for {
+		if elem != r && !w.present(elem) {
+			w.dst.Buffer.WriteNull()
+			w.skip(elem)
+		} else {
+			w.writeValue(elem)
+		}
+		rep, _ := first.peek()
+		if first.pos >= first.levels || rep != r.maxRep {
+			return
+		}
+	}
+}
+
+func (w *writer) writePrimitive(n *node) {
+	b := &w.dst.Buffer
+	c := n.col
+	i := c.advance()
+	if i < 0 {
+		b.WriteNull()
+		return
+	}
+	if i >= c.values.len() {
+		if w.err == nil {
+			w.err = corrupt("column %s: missing values", n.path())
+		}
+		b.WriteNull()
+		return
+	}
+	switch n.kind {
+	case kindBool:
+		b.WriteBool(c.ints[i] != 0)
+	case kindInt:
+		b.WriteInt(c.ints[i])
+	case kindUint:
+		if n.typ == typeInt32 {
+			b.WriteUint(uint64(uint32(c.ints[i])))
+		} else {
+			b.WriteUint(uint64(c.ints[i]))
+		}
+	case kindFloat:
+		b.WriteFloat64(c.floats[i])
+	case kindDecimal:
+		w.writeDecimal(n, c, i)
+	case kindString:
+		if c.symbol[i] {
+			b.WriteSymbol(w.dst.Symbols.InternBytes(c.bytes[i]))
+			break
+		}
+		b.BeginString(len(c.bytes[i]))
+		b.UnsafeAppend(c.bytes[i])
+	case kindBytes:
+		if !utf8.Valid(c.bytes[i]) {
+			b.WriteBlob(c.bytes[i])
+			break
+		}
+		b.BeginString(len(c.bytes[i]))
+		b.UnsafeAppend(c.bytes[i])
+	case kindBlob:
+		b.WriteBlob(c.bytes[i])
+	case kindUUID:
+		var buf [36]byte
+		u := c.bytes[i]
+		hex.Encode(buf[0:], u[0:4])
+		buf[8] = '-'
+		hex.Encode(buf[9:], u[4:6])
+		buf[13] = '-'
+		hex.Encode(buf[14:], u[6:8])
+		buf[18] = '-'
+		hex.Encode(buf[19:], u[8:10])
+		buf[23] = '-'
+		hex.Encode(buf[24:], u[10:])
+		b.BeginString(len(buf))
+		b.UnsafeAppend(buf[:])
+	case kindTimestamp, kindDate, kindInt96:
+		w.writeTime(n, c, i)
+	}
+}
+
+func (w *writer) writeDecimal(n *node, c *column, i int) {
+	b := &w.dst.Buffer
+	if n.typ == typeInt32 || n.typ == typeInt64 {
+		if n.scale == 0 {
+			b.WriteInt(c.ints[i])
+			return
+		}
+		b.WriteFloat64(float64(c.ints[i]) / math.Pow10(int(n.scale)))
+		return
+	}
+	// big-endian two's complement
+	buf := c.bytes[i]
+	v := new(big.Int).SetBytes(buf)
+	if len(buf) > 0 && buf[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(buf))))
+	}
+	if n.scale == 0 && v.IsInt64() {
+		b.WriteInt(v.Int64())
+		return
+	}
+	f, _ := new(big.Float).SetInt(v).Float64()
+	b.WriteFloat64(f / math.Pow10(int(n.scale)))
+}
+
+func (w *writer) writeTime(n *node, c *column, i int) {
+	var t date.Time
+	switch n.kind {
+	case kindTimestamp:
+		v := c.ints[i]
+		switch n.unit {
+		case unitMillis:
+			t = date.Unix(v/1000, (v%1000)*1e6)
+		case unitMicros:
+			t = date.UnixMicro(v)
+		default:
+			t = date.Unix(0, v)
+		}
+	case kindDate:
+		t = date.Unix(c.ints[i]*86400, 0)
+	case kindInt96:
+		buf := c.bytes[i]
+		ns := int64(binary.LittleEndian.Uint64(buf))
+		day := int64(binary.LittleEndian.Uint32(buf[8:]))
+		t = date.Unix((day-julianEpoch)*86400, ns)
+	}
+	w.dst.Buffer.WriteTime(t)
+	if !n.index {
+		return
+	}
+	depth := 0
+	for p := n; p.parent != nil; p = p.parent {
+		depth++
+	}
+	w.path.Prepare(depth)
+	w.pushPath(n)
+	w.dst.Ranges.AddTime(w.path, t)
+}
+
+func (w *writer) pushPath(n *node) {
+	if n.parent == nil {
+		return
+	}
+	w.pushPath(n.parent)
+	w.path.Push(n.sym)
+}
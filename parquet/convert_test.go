@@ -0,0 +1,852 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+)
+
+// thriftWriter encodes the thrift compact protocol
+type thriftWriter struct {
+	buf  []byte
+	last []int16
+}
+
+func (w *thriftWriter) uvarint(u uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], u)
+	w.buf = append(w.buf, tmp[:n]...)
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		w.buf = append(w.buf, byte(d)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.uvarint(uint64(id<<1) ^ uint64(id>>15))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) int(id int16, typ byte, v int64) {
+	w.field(id, typ)
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (w *thriftWriter) i32(id int16, v int32) { w.int(id, ctI32, int64(v)) }
+func (w *thriftWriter) i64(id int16, v int64) { w.int(id, ctI64, v) }
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, ctTrue)
+	} else {
+		w.field(id, ctFalse)
+	}
+}
+
+func (w *thriftWriter) str(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// begin begins a struct, which is a field
+// with the given id unless id is zero
+func (w *thriftWriter) begin(id int16) {
+	if id != 0 {
+		w.field(id, ctStruct)
+	}
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, ctStop)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) list(id int16, elem byte, n int) {
+	w.field(id, ctList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n<<4)|elem)
+		return
+	}
+	w.buf = append(w.buf, 0xf0|elem)
+	w.uvarint(uint64(n))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+type testElem struct {
+	name     string
+	typ      int32
+	rep      int32
+	children int32
+	conv     int32
+	logical  int16
+	unit     int16
+	length   int32
+	scale    int32
+}
+
+func group(name string, rep, children int32) testElem {
+	return testElem{name: name, typ: typeGroup, rep: rep, children: children, conv: convNone}
+}
+
+func leaf(name string, typ, rep int32) testElem {
+	return testElem{name: name, typ: typ, rep: rep, conv: convNone}
+}
+
+func (e testElem) withConv(conv int32) testElem {
+	e.conv = conv
+	return e
+}
+
+func (e testElem) withLogical(kind, unit int16) testElem {
+	e.logical, e.unit = kind, unit
+	return e
+}
+
+type testColumn struct {
+	path           []string
+	typ            int32
+	maxRep, maxDef int
+	rep, def       []int32
+	values         interface{} // []int64, []float64 or [][]byte
+	dict           bool        // dictionary-encode the values
+	v2             bool        // write a DataPageHeaderV2
+	delta          bool        // use DELTA_BINARY_PACKED
+	codec          int32
+}
+
+// encodeLevels encodes levels as one bit-packed run
+func encodeLevels(levels []int32, max int) []byte {
+	width := bits.Len(uint(max))
+	groups := (len(levels) + 7) / 8
+	var w thriftWriter
+	w.uvarint(uint64(groups<<1 | 1))
+	packed := make([]byte, groups*width)
+	for i, l := range levels {
+		for b := 0; b < width; b++ {
+			if l&(1<<b) != 0 {
+				bit := i*width + b
+				packed[bit/8] |= 1 << (bit % 8)
+			}
+		}
+	}
+	return append(w.buf, packed...)
+}
+
+// encodeIndexes encodes dictionary
+// indexes as a series of RLE runs
+func encodeIndexes(idx []int) []byte {
+	out := []byte{8}
+	var w thriftWriter
+	for i := 0; i < len(idx); {
+		j := i
+		for j < len(idx) && idx[j] == idx[i] {
+			j++
+		}
+		w.uvarint(uint64((j - i) << 1))
+		w.buf = append(w.buf, byte(idx[i]))
+		i = j
+	}
+	return append(out, w.buf...)
+}
+
+// encodeDelta encodes up to 129 values
+// with DELTA_BINARY_PACKED
+func encodeDelta(vals []int64) []byte {
+	var w thriftWriter
+	w.uvarint(128)
+	w.uvarint(4)
+	w.uvarint(uint64(len(vals)))
+	w.uvarint(uint64(vals[0]<<1) ^ uint64(vals[0]>>63))
+	if len(vals) == 1 {
+		return w.buf
+	}
+	min := int64(math.MaxInt64)
+	for i := 1; i < len(vals); i++ {
+		if d := vals[i] - vals[i-1]; d < min {
+			min = d
+		}
+	}
+	width := 0
+	for i := 1; i < len(vals); i++ {
+		if n := bits.Len64(uint64(vals[i] - vals[i-1] - min)); n > width {
+			width = n
+		}
+	}
+	w.uvarint(uint64(min<<1) ^ uint64(min>>63))
+	w.buf = append(w.buf, byte(width), 0, 0, 0)
+	packed := make([]byte, 32*width/8)
+	for i := 1; i < len(vals); i++ {
+		d := uint64(vals[i] - vals[i-1] - min)
+		for b := 0; b < width; b++ {
+			if d&(1<<b) != 0 {
+				bit := (i-1)*width + b
+				packed[bit/8] |= 1 << (bit % 8)
+			}
+		}
+	}
+	return append(w.buf, packed...)
+}
+
+func encodePlain(typ int32, vals interface{}) []byte {
+	var out []byte
+	switch v := vals.(type) {
+	case []int64:
+		switch typ {
+		case typeBoolean:
+			out = make([]byte, (len(v)+7)/8)
+			for i := range v {
+				if v[i] != 0 {
+					out[i/8] |= 1 << (i % 8)
+				}
+			}
+		case typeInt32:
+			for i := range v {
+				out = appendUint32(out, uint32(v[i]))
+			}
+		default:
+			for i := range v {
+				out = appendUint64(out, uint64(v[i]))
+			}
+		}
+	case []float64:
+		for i := range v {
+			if typ == typeFloat {
+				out = appendUint32(out, math.Float32bits(float32(v[i])))
+			} else {
+				out = appendUint64(out, math.Float64bits(v[i]))
+			}
+		}
+	case [][]byte:
+		for i := range v {
+			if typ == typeByteArray {
+				out = appendUint32(out, uint32(len(v[i])))
+			}
+			out = append(out, v[i]...)
+		}
+	}
+	return out
+}
+
+func compress(t *testing.T, codec int32, data []byte) []byte {
+	switch codec {
+	case codecSnappy:
+		return snappy.Encode(nil, data)
+	case codecGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	case codecUncompressed:
+		return data
+	}
+	t.Fatalf("unexpected codec %d", codec)
+	return nil
+}
+
+func pageHeaderBytes(typ int32, size, csize int, body func(w *thriftWriter)) []byte {
+	var w thriftWriter
+	w.begin(0)
+	w.i32(1, typ)
+	w.i32(2, int32(size))
+	w.i32(3, int32(csize))
+	body(&w)
+	w.end()
+	return w.buf
+}
+
+// writeColumn appends a column chunk to file
+// and returns the ColumnChunk metadata
+func writeColumn(t *testing.T, file []byte, c *testColumn) ([]byte, func(w *thriftWriter)) {
+	start := int64(len(file))
+	levels := len(c.rep)
+	if levels == 0 {
+		levels = len(c.def)
+	}
+	vals := c.values
+	var dictOffset int64
+	encoding := int32(encPlain)
+	var data []byte
+	if c.dict {
+		// dictionary-encode byte arrays
+		in := vals.([][]byte)
+		var dict [][]byte
+		idx := make([]int, len(in))
+	outer:
+		for i := range in {
+			for j := range dict {
+				if bytes.Equal(dict[j], in[i]) {
+					idx[i] = j
+					continue outer
+				}
+			}
+			idx[i] = len(dict)
+			dict = append(dict, in[i])
+		}
+		page := encodePlain(c.typ, dict)
+		cpage := compress(t, c.codec, page)
+		dictOffset = start
+		file = append(file, pageHeaderBytes(pageDictionary, len(page), len(cpage), func(w *thriftWriter) {
+			w.begin(7)
+			w.i32(1, int32(len(dict)))
+			w.i32(2, encPlain)
+			w.end()
+		})...)
+		file = append(file, cpage...)
+		encoding = encRLEDictionary
+		data = encodeIndexes(idx)
+	} else if c.delta {
+		encoding = encDeltaBinaryPacked
+		data = encodeDelta(vals.([]int64))
+	} else {
+		data = encodePlain(c.typ, vals)
+	}
+	if levels == 0 {
+		levels = reflect.ValueOf(vals).Len()
+	}
+	dataOffset := int64(len(file))
+	var rep, def []byte
+	if c.maxRep > 0 {
+		rep = encodeLevels(c.rep, c.maxRep)
+	}
+	if c.maxDef > 0 {
+		def = encodeLevels(c.def, c.maxDef)
+	}
+	if c.v2 {
+		cdata := compress(t, c.codec, data)
+		size := len(rep) + len(def) + len(data)
+		csize := len(rep) + len(def) + len(cdata)
+		file = append(file, pageHeaderBytes(pageDataV2, size, csize, func(w *thriftWriter) {
+			w.begin(8)
+			w.i32(1, int32(levels))
+			w.i32(2, 0)
+			w.i32(3, 0)
+			w.i32(4, encoding)
+			w.i32(5, int32(len(def)))
+			w.i32(6, int32(len(rep)))
+			w.bool(7, c.codec != codecUncompressed)
+			w.end()
+		})...)
+		file = append(file, rep...)
+		file = append(file, def...)
+		file = append(file, cdata...)
+	} else {
+		var page []byte
+		if c.maxRep > 0 {
+			page = appendUint32(page, uint32(len(rep)))
+			page = append(page, rep...)
+		}
+		if c.maxDef > 0 {
+			page = appendUint32(page, uint32(len(def)))
+			page = append(page, def...)
+		}
+		page = append(page, data...)
+		cpage := compress(t, c.codec, page)
+		file = append(file, pageHeaderBytes(pageData, len(page), len(cpage), func(w *thriftWriter) {
+			w.begin(5)
+			w.i32(1, int32(levels))
+			w.i32(2, encoding)
+			w.i32(3, encRLE)
+			w.i32(4, encRLE)
+			w.end()
+		})...)
+		file = append(file, cpage...)
+	}
+	size := int64(len(file)) - start
+	return file, func(w *thriftWriter) {
+		w.begin(0)
+		w.i64(2, start)
+		w.begin(3)
+		w.i32(1, c.typ)
+		w.list(2, ctI32, 1)
+		w.uvarint(uint64(encoding) << 1)
+		w.list(3, ctBinary, len(c.path))
+		for _, p := range c.path {
+			w.str(p)
+		}
+		w.i32(4, c.codec)
+		w.i64(5, int64(levels))
+		w.i64(6, size)
+		w.i64(7, size)
+		w.i64(9, dataOffset)
+		if dictOffset != 0 {
+			w.i64(11, dictOffset)
+		}
+		w.end()
+		w.end()
+	}
+}
+
+// writeFile builds a parquet file
+// with the given schema and row groups
+func writeFile(t *testing.T, schema []testElem, groups [][]testColumn) []byte {
+	file := []byte(magic)
+	type rowGroup struct {
+		rows    int64
+		columns []func(*thriftWriter)
+	}
+	var rgs []rowGroup
+	total := int64(0)
+	for _, g := range groups {
+		var rg rowGroup
+		for i := range g {
+			var meta func(*thriftWriter)
+			file, meta = writeColumn(t, file, &g[i])
+			rg.columns = append(rg.columns, meta)
+		}
+		// count the rows in the first column
+		c := &g[0]
+		switch {
+		case c.maxRep > 0:
+			for _, r := range c.rep {
+				if r == 0 {
+					rg.rows++
+				}
+			}
+		case c.maxDef > 0:
+			rg.rows = int64(len(c.def))
+		default:
+			rg.rows = int64(reflect.ValueOf(c.values).Len())
+		}
+		total += rg.rows
+		rgs = append(rgs, rg)
+	}
+
+	var w thriftWriter
+	w.begin(0)
+	w.i32(1, 1)
+	w.list(2, ctStruct, len(schema))
+	for _, e := range schema {
+		w.begin(0)
+		if e.typ != typeGroup {
+			w.i32(1, e.typ)
+		}
+		if e.length != 0 {
+			w.i32(2, e.length)
+		}
+		w.i32(3, e.rep)
+		w.field(4, ctBinary)
+		w.str(e.name)
+		if e.children != 0 {
+			w.i32(5, e.children)
+		}
+		if e.conv != convNone {
+			w.i32(6, e.conv)
+		}
+		if e.scale != 0 {
+			w.i32(7, e.scale)
+			w.i32(8, 18)
+		}
+		if e.logical != logicalNone {
+			w.begin(10)
+			w.begin(e.logical)
+			if e.logical == logicalTimestamp {
+				w.bool(1, true)
+				w.begin(2)
+				w.begin(e.unit)
+				w.end()
+				w.end()
+			}
+			w.end()
+			w.end()
+		}
+		w.end()
+	}
+	w.i64(3, total)
+	w.list(4, ctStruct, len(rgs))
+	for _, rg := range rgs {
+		w.begin(0)
+		w.list(1, ctStruct, len(rg.columns))
+		for _, meta := range rg.columns {
+			meta(&w)
+		}
+		w.i64(2, 0)
+		w.i64(3, rg.rows)
+		w.end()
+	}
+	w.field(6, ctBinary)
+	w.str("sneller test")
+	w.end()
+
+	file = append(file, w.buf...)
+	file = appendUint32(file, uint32(len(w.buf)))
+	return append(file, magic...)
+}
+
+// rangeWriter collects the output
+// and the ranges that are written
+type rangeWriter struct {
+	bytes.Buffer
+	ranges map[string][2]date.Time
+}
+
+func (r *rangeWriter) SetMinMax(path []string, min, max ion.Datum) {
+	lo, _ := min.Timestamp()
+	hi, _ := max.Timestamp()
+	if r.ranges == nil {
+		r.ranges = make(map[string][2]date.Time)
+	}
+	r.ranges[string(bytes.Join(toBytes(path), []byte(".")))] = [2]date.Time{lo, hi}
+}
+
+func toBytes(s []string) [][]byte {
+	out := make([][]byte, len(s))
+	for i := range s {
+		out[i] = []byte(s[i])
+	}
+	return out
+}
+
+// convert converts the file and returns
+// the records as JSON along with the
+// records themselves
+func convert(t *testing.T, file []byte, hints string) ([]string, []ion.Struct, *rangeWriter) {
+	var h *jsonrl.Hint
+	if hints != "" {
+		var err error
+		h, err = jsonrl.ParseHint([]byte(hints))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out rangeWriter
+	cn := ion.Chunker{Align: 4096, W: &out}
+	err := Convert(bytes.NewReader(file), int64(len(file)), &cn, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cn.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []string
+	var structs []ion.Struct
+	var st ion.Symtab
+	var d ion.Datum
+	for buf := out.Bytes(); len(buf) > 0; {
+		if ion.TypeOf(buf) == ion.NullType {
+			buf = buf[ion.SizeOf(buf):]
+			continue
+		}
+		d, buf, err = ion.ReadDatum(&st, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Empty() {
+			continue
+		}
+		js, err := ion.AppendJSON(nil, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, string(js))
+		s, _ := d.Struct()
+		structs = append(structs, s)
+	}
+	return rows, structs, &out
+}
+
+func checkRows(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d:\n%s", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("row %d:\ngot  %s\nwant %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestConvertFlat(t *testing.T) {
+	const epochDay = 19266 // 2022-10-01
+	int96 := func(day, ns int) []byte {
+		b := appendUint64(nil, uint64(ns))
+		return appendUint32(b, uint32(julianEpoch+day))
+	}
+	schema := []testElem{
+		group("schema", required, 9),
+		leaf("id", typeInt64, required),
+		leaf("name", typeByteArray, optional).withConv(convUTF8),
+		leaf("ts", typeInt64, optional).withLogical(logicalTimestamp, unitMillis),
+		leaf("legacy", typeInt96, required),
+		leaf("day", typeInt32, required).withConv(convDate),
+		leaf("score", typeDouble, optional),
+		leaf("n", typeInt32, required),
+		leaf("dec", typeInt64, required).withConv(convDecimal),
+		leaf("raw", typeByteArray, required),
+	}
+	schema[8].scale = 2
+	columns := []testColumn{{
+		path:   []string{"id"},
+		typ:    typeInt64,
+		values: []int64{1, 2, 3},
+	}, {
+		path:   []string{"name"},
+		typ:    typeByteArray,
+		maxDef: 1,
+		def:    []int32{1, 0, 1},
+		values: [][]byte{[]byte("x"), []byte("x")},
+		dict:   true,
+	}, {
+		path:   []string{"ts"},
+		typ:    typeInt64,
+		maxDef: 1,
+		def:    []int32{1, 1, 0},
+		values: []int64{1664625600000, 1664712000000},
+		codec:  codecSnappy,
+	}, {
+		path:   []string{"legacy"},
+		typ:    typeInt96,
+		values: [][]byte{int96(epochDay, 0), int96(epochDay, 3600e9), int96(epochDay, 0)},
+	}, {
+		path:   []string{"day"},
+		typ:    typeInt32,
+		values: []int64{epochDay, epochDay + 1, epochDay},
+	}, {
+		path:   []string{"score"},
+		typ:    typeDouble,
+		maxDef: 1,
+		def:    []int32{1, 0, 1},
+		values: []float64{1.5, 2.5},
+		v2:     true,
+		codec:  codecSnappy,
+	}, {
+		path:   []string{"n"},
+		typ:    typeInt32,
+		values: []int64{7, 5, 100},
+		v2:     true,
+		delta:  true,
+		codec:  codecGzip,
+	}, {
+		path:   []string{"dec"},
+		typ:    typeInt64,
+		values: []int64{1234, -5, 0},
+	}, {
+		path:   []string{"raw"},
+		typ:    typeByteArray,
+		values: [][]byte{{0xff}, []byte("ok"), []byte("z")},
+	}}
+	file := writeFile(t, schema, [][]testColumn{columns})
+	rows, structs, out := convert(t, file, "")
+	checkRows(t, rows, []string{
+		`{"name": "x", "id": 1, "ts": "2022-10-01T12:00:00Z", "legacy": "2022-10-01T00:00:00Z", "day": "2022-10-01T00:00:00Z", "score": 1.5, "n": 7, "dec": 12.34, "raw": "/w=="}`,
+		`{"id": 2, "ts": "2022-10-02T12:00:00Z", "legacy": "2022-10-01T01:00:00Z", "day": "2022-10-02T00:00:00Z", "n": 5, "dec": -0.05, "raw": "ok"}`,
+		`{"name": "x", "id": 3, "legacy": "2022-10-01T00:00:00Z", "day": "2022-10-01T00:00:00Z", "score": 2.5, "n": 100, "dec": 0, "raw": "z"}`,
+	})
+	// dictionary strings are symbols
+	f, ok := structs[0].FieldByName("name")
+	if !ok || f.Value.Type() != ion.SymbolType {
+		t.Errorf("name is not a symbol: %v", f.Value)
+	}
+	f, ok = structs[1].FieldByName("raw")
+	if !ok || f.Value.Type() != ion.StringType {
+		t.Errorf("raw is not a string: %v", f.Value)
+	}
+
+	ts := func(s string) date.Time {
+		t, _ := date.Parse([]byte(s))
+		return t
+	}
+	want := map[string][2]date.Time{
+		"ts":     {ts("2022-10-01T12:00:00Z"), ts("2022-10-02T12:00:00Z")},
+		"legacy": {ts("2022-10-01T00:00:00Z"), ts("2022-10-01T01:00:00Z")},
+		"day":    {ts("2022-10-01T00:00:00Z"), ts("2022-10-02T00:00:00Z")},
+	}
+	if !reflect.DeepEqual(out.ranges, want) {
+		t.Errorf("got ranges %v, want %v", out.ranges, want)
+	}
+
+	// no_index hints are honored
+	_, _, out = convert(t, file, `{"ts": "no_index", "legacy": "ignore"}`)
+	want = map[string][2]date.Time{
+		"day": want["day"],
+	}
+	if !reflect.DeepEqual(out.ranges, want) {
+		t.Errorf("with hints: got ranges %v, want %v", out.ranges, want)
+	}
+}
+
+func TestConvertNested(t *testing.T) {
+	schema := []testElem{
+		group("schema", required, 4),
+		group("user", optional, 3),
+		leaf("name", typeByteArray, required).withLogical(logicalString, 0),
+		group("addr", optional, 1),
+		leaf("city", typeByteArray, optional).withConv(convUTF8),
+		leaf("since", typeInt64, optional).withConv(convTimestampMicros),
+		group("tags", optional, 1).withLogical(logicalList, 0),
+		group("list", repeated, 1),
+		leaf("element", typeByteArray, optional).withConv(convUTF8),
+		leaf("nums", typeInt32, repeated),
+		group("attrs", optional, 1).withConv(convMap),
+		group("key_value", repeated, 2),
+		leaf("key", typeByteArray, required).withConv(convUTF8),
+		leaf("value", typeInt32, optional),
+	}
+	str := func(s ...string) [][]byte {
+		return toBytes(s)
+	}
+	columns := []testColumn{{
+		path:   []string{"user", "name"},
+		typ:    typeByteArray,
+		maxDef: 1,
+		def:    []int32{1, 0, 1},
+		values: str("a", "b"),
+	}, {
+		path:   []string{"user", "addr", "city"},
+		typ:    typeByteArray,
+		maxDef: 3,
+		def:    []int32{3, 0, 1},
+		values: str("x"),
+		dict:   true,
+	}, {
+		path:   []string{"user", "since"},
+		typ:    typeInt64,
+		maxDef: 2,
+		def:    []int32{2, 0, 1},
+		values: []int64{1664625600000000},
+	}, {
+		path:   []string{"tags", "list", "element"},
+		typ:    typeByteArray,
+		maxRep: 1,
+		maxDef: 3,
+		rep:    []int32{0, 1, 0, 0},
+		def:    []int32{3, 2, 0, 1},
+		values: str("t1"),
+		v2:     true,
+	}, {
+		path:   []string{"nums"},
+		typ:    typeInt32,
+		maxRep: 1,
+		maxDef: 1,
+		rep:    []int32{0, 1, 0, 0},
+		def:    []int32{1, 1, 0, 1},
+		values: []int64{1, 2, 3},
+	}, {
+		path:   []string{"attrs", "key_value", "key"},
+		typ:    typeByteArray,
+		maxRep: 1,
+		maxDef: 2,
+		rep:    []int32{0, 0, 0, 1},
+		def:    []int32{2, 1, 2, 2},
+		values: str("k", "a", "b"),
+	}, {
+		path:   []string{"attrs", "key_value", "value"},
+		typ:    typeInt32,
+		maxRep: 1,
+		maxDef: 3,
+		rep:    []int32{0, 0, 0, 1},
+		def:    []int32{3, 1, 2, 3},
+		values: []int64{1, 2},
+		codec:  codecGzip,
+	}}
+	rows := []string{
+		`{"user": {"name": "a", "addr": {"city": "x"}, "since": "2022-10-01T12:00:00Z"}, "tags": ["t1", null], "nums": [1, 2], "attrs": [{"key": "k", "value": 1}]}`,
+		`{"attrs": []}`,
+		`{"user": {"name": "b"}, "tags": [], "nums": [3], "attrs": [{"key": "a"}, {"key": "b", "value": 2}]}`,
+	}
+	file := writeFile(t, schema, [][]testColumn{columns, columns})
+	got, _, out := convert(t, file, "")
+	checkRows(t, got, append(rows, rows...))
+	ts, _ := date.Parse([]byte("2022-10-01T12:00:00Z"))
+	want := map[string][2]date.Time{
+		"user.since": {ts, ts},
+	}
+	if !reflect.DeepEqual(out.ranges, want) {
+		t.Errorf("got ranges %v, want %v", out.ranges, want)
+	}
+
+	got, _, _ = convert(t, file, `{"user.addr": "ignore", "tags": "ignore", "nums": "ignore"}`)
+	rows = []string{
+		`{"user": {"name": "a", "since": "2022-10-01T12:00:00Z"}, "attrs": [{"key": "k", "value": 1}]}`,
+		`{"attrs": []}`,
+		`{"user": {"name": "b"}, "attrs": [{"key": "a"}, {"key": "b", "value": 2}]}`,
+	}
+	checkRows(t, got, append(rows, rows...))
+}
+
+func TestConvertCorrupt(t *testing.T) {
+	schema := []testElem{
+		group("schema", required, 1),
+		leaf("id", typeInt64, required),
+	}
+	file := writeFile(t, schema, [][]testColumn{{{
+		path:   []string{"id"},
+		typ:    typeInt64,
+		values: []int64{1, 2, 3},
+	}}})
+	// truncate or damage the file in various ways;
+	// every one of them should produce an error
+	// rather than a panic
+	for i := 4; i < len(file)-8; i++ {
+		for _, b := range []byte{0, 0x7f, 0xff} {
+			bad := append([]byte(nil), file...)
+			bad[i] ^= b
+			cn := ion.Chunker{Align: 4096, W: &bytes.Buffer{}}
+			Convert(bytes.NewReader(bad), int64(len(bad)), &cn, nil)
+		}
+	}
+	for _, size := range []int{0, 8, 12, len(file) - 1} {
+		cn := ion.Chunker{Align: 4096, W: &bytes.Buffer{}}
+		err := Convert(bytes.NewReader(file[:size]), int64(size), &cn, nil)
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("size %d: got error %v", size, err)
+		}
+	}
+}
+
+func TestLoadTooLarge(t *testing.T) {
+	c := column{leaf: &node{name: "x", typ: typeInt64}}
+	cc := columnChunk{meta: columnMetaData{
+		typ:                 typeInt64,
+		numValues:           1,
+		totalCompressedSize: maxChunkSize + 1,
+		dataPageOffset:      4,
+	}}
+	// the chunk is rejected before
+	// anything is read from the file
+	err := c.load(bytes.NewReader(nil), 1<<40, &cc)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got error %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+func corrupt(f string, args ...interface{}) error {
+	return fmt.Errorf(f+": %w", append(args, ErrCorrupt)...)
+}
+
+// values holds decoded column values;
+// which of the slices is used depends
+// on the physical type of the column
+type values struct {
+	ints   []int64   // BOOLEAN, INT32, INT64
+	floats []float64 // FLOAT, DOUBLE
+	bytes  [][]byte  // INT96, BYTE_ARRAY, FIXED_LEN_BYTE_ARRAY
+}
+
+func (v *values) reset() {
+	v.ints = v.ints[:0]
+	v.floats = v.floats[:0]
+	v.bytes = v.bytes[:0]
+}
+
+func (v *values) len() int {
+	return len(v.ints) + len(v.floats) + len(v.bytes)
+}
+
+// bitReader reads little-endian
+// bit-packed integers
+type bitReader struct {
+	src  []byte
+	acc  uint64
+	bits int
+}
+
+func (r *bitReader) read(width int) uint64 {
+	if width > 56 {
+		lo := r.read(32)
+		return lo | r.read(width-32)<<32
+	}
+	for r.bits < width {
+		if len(r.src) > 0 {
+			r.acc |= uint64(r.src[0]) << r.bits
+			r.src = r.src[1:]
+		}
+		r.bits += 8
+	}
+	v := r.acc & (1<<width - 1)
+	r.acc >>= width
+	r.bits -= width
+	return v
+}
+
+// decodeHybrid appends n values from the
+// RLE/bit-packed hybrid encoding in src to dst
+func decodeHybrid(dst []int32, src []byte, width, n int) ([]int32, error) {
+	if width > 32 {
+		return dst, corrupt("bit width %d out of range", width)
+	}
+	for n > 0 {
+		h, k := binary.Uvarint(src)
+		if k <= 0 {
+			return dst, corrupt("bad run header")
+		}
+		src = src[k:]
+		if h&1 == 0 {
+			// RLE run
+			size := (width + 7) / 8
+			if len(src) < size {
+				return dst, corrupt("truncated run")
+			}
+			var v uint32
+			for i := 0; i < size; i++ {
+				v |= uint32(src[i]) << (8 * i)
+			}
+			src = src[size:]
+			count := h >> 1
+			if count > uint64(n) {
+				count = uint64(n)
+			}
+			for i := uint64(0); i < count; i++ {
+				dst = append(dst, int32(v))
+			}
+			n -= int(count)
+			continue
+		}
+		// bit-packed run of groups of 8
+		groups := h >> 1
+		if groups > uint64(len(src)) {
+			return dst, corrupt("truncated bit-packed run")
+		}
+		size := int(groups) * width
+		if size > len(src) {
+			return dst, corrupt("truncated bit-packed run")
+		}
+		count := int(groups) * 8
+		if count > n {
+			count = n
+		}
+		r := bitReader{src: src[:size]}
+		for i := 0; i < count; i++ {
+			dst = append(dst, int32(r.read(width)))
+		}
+		src = src[size:]
+		n -= count
+	}
+	return dst, nil
+}
+
+// decodePlain appends n PLAIN-encoded values to v
+func decodePlain(v *values, typ, size int32, src []byte, n int) error {
+	need := 0
+	switch typ {
+	case typeBoolean:
+		need = (n + 7) / 8
+	case typeInt32, typeFloat:
+		need = 4 * n
+	case typeInt64, typeDouble:
+		need = 8 * n
+	case typeInt96:
+		need = 12 * n
+	case typeFixedLenByteArray:
+		if size <= 0 {
+			return corrupt("bad fixed length %d", size)
+		}
+		need = int(size) * n
+	}
+	if n < 0 || need > len(src) || need < 0 {
+		return corrupt("truncated page")
+	}
+	switch typ {
+	case typeBoolean:
+		for i := 0; i < n; i++ {
+			v.ints = append(v.ints, int64(src[i/8]>>(i%8))&1)
+		}
+	case typeInt32:
+		for i := 0; i < n; i++ {
+			v.ints = append(v.ints, int64(int32(binary.LittleEndian.Uint32(src[4*i:]))))
+		}
+	case typeInt64:
+		for i := 0; i < n; i++ {
+			v.ints = append(v.ints, int64(binary.LittleEndian.Uint64(src[8*i:])))
+		}
+	case typeFloat:
+		for i := 0; i < n; i++ {
+			v.floats = append(v.floats, float64(math.Float32frombits(binary.LittleEndian.Uint32(src[4*i:]))))
+		}
+	case typeDouble:
+		for i := 0; i < n; i++ {
+			v.floats = append(v.floats, math.Float64frombits(binary.LittleEndian.Uint64(src[8*i:])))
+		}
+	case typeInt96, typeFixedLenByteArray:
+		w := 12
+		if typ == typeFixedLenByteArray {
+			w = int(size)
+		}
+		for i := 0; i < n; i++ {
+			v.bytes = append(v.bytes, src[i*w:(i+1)*w:(i+1)*w])
+		}
+	case typeByteArray:
+		for i := 0; i < n; i++ {
+			if len(src) < 4 {
+				return corrupt("truncated page")
+			}
+			size := binary.LittleEndian.Uint32(src)
+			src = src[4:]
+			if uint64(size) > uint64(len(src)) {
+				return corrupt("byte array length %d out of range", size)
+			}
+			v.bytes = append(v.bytes, src[:size:size])
+			src = src[size:]
+		}
+	default:
+		return corrupt("unknown physical type %d", typ)
+	}
+	return nil
+}
+
+// decodeDeltaBinary appends n DELTA_BINARY_PACKED values
+// from src to dst and returns the rest of src
+func decodeDeltaBinary(dst []int64, src []byte, n int) ([]int64, []byte, error) {
+	t := thrift{buf: src}
+	blockSize, err := t.uvarint()
+	if err != nil {
+		return dst, nil, err
+	}
+	miniBlocks, err := t.uvarint()
+	if err != nil {
+		return dst, nil, err
+	}
+	total, err := t.uvarint()
+	if err != nil {
+		return dst, nil, err
+	}
+	v, err := t.zigzag()
+	if err != nil {
+		return dst, nil, err
+	}
+	if blockSize == 0 || blockSize%128 != 0 || miniBlocks == 0 ||
+		blockSize%miniBlocks != 0 || (blockSize/miniBlocks)%32 != 0 {
+		return dst, nil, corrupt("bad delta block size %d/%d", blockSize, miniBlocks)
+	}
+	if total != uint64(n) {
+		return dst, nil, corrupt("delta encoding has %d values; want %d", total, n)
+	}
+	perMini := int(blockSize / miniBlocks)
+	src = t.buf
+	if n > 0 {
+		dst = append(dst, v)
+	}
+	left := n - 1
+	for left > 0 {
+		t.buf = src
+		minDelta, err := t.zigzag()
+		if err != nil {
+			return dst, nil, err
+		}
+		if uint64(len(t.buf)) < miniBlocks {
+			return dst, nil, corrupt("truncated delta block")
+		}
+		widths := t.buf[:miniBlocks]
+		src = t.buf[miniBlocks:]
+		for _, w := range widths {
+			if left <= 0 {
+				break
+			}
+			if w > 64 {
+				return dst, nil, corrupt("bit width %d out of range", w)
+			}
+			size := perMini * int(w) / 8
+			if size > len(src) {
+				return dst, nil, corrupt("truncated delta miniblock")
+			}
+			r := bitReader{src: src[:size]}
+			for i := 0; i < perMini && left > 0; i++ {
+				v += minDelta + int64(r.read(int(w)))
+				dst = append(dst, v)
+				left--
+			}
+			src = src[size:]
+		}
+	}
+	return dst, src, nil
+}
+
+// decodeDeltaLength appends n DELTA_LENGTH_BYTE_ARRAY
+// values from src to dst and returns the rest of src
+func decodeDeltaLength(dst [][]byte, src []byte, n int) ([][]byte, []byte, error) {
+	lengths, src, err := decodeDeltaBinary(nil, src, n)
+	if err != nil {
+		return dst, nil, err
+	}
+	for _, l := range lengths {
+		if l < 0 || l > int64(len(src)) {
+			return dst, nil, corrupt("byte array length %d out of range", l)
+		}
+		dst = append(dst, src[:l:l])
+		src = src[l:]
+	}
+	return dst, src, nil
+}
+
+// decodeDeltaByteArray appends n DELTA_BYTE_ARRAY
+// values from src to dst
+func decodeDeltaByteArray(dst [][]byte, src []byte, n int) ([][]byte, error) {
+	prefixes, src, err := decodeDeltaBinary(nil, src, n)
+	if err != nil {
+		return dst, err
+	}
+	suffixes, _, err := decodeDeltaLength(nil, src, n)
+	if err != nil {
+		return dst, err
+	}
+	var prev []byte
+	for i := range suffixes {
+		p := prefixes[i]
+		if p < 0 || p > int64(len(prev)) {
+			return dst, corrupt("prefix length %d out of range", p)
+		}
+		b := make([]byte, int(p)+len(suffixes[i]))
+		copy(b, prev[:p])
+		copy(b[p:], suffixes[i])
+		dst = append(dst, b)
+		prev = b
+	}
+	return dst, nil
+}
+
+// decodeByteStreamSplit appends n BYTE_STREAM_SPLIT
+// values of the given width to v
+func decodeByteStreamSplit(v *values, typ, width int32, src []byte, n int) error {
+	switch typ {
+	case typeFloat, typeInt32:
+		width = 4
+	case typeDouble, typeInt64:
+		width = 8
+	case typeFixedLenByteArray:
+	default:
+		return corrupt("BYTE_STREAM_SPLIT encoding of type %d", typ)
+	}
+	if width <= 0 || n < 0 || int(width)*n > len(src) {
+		return corrupt("truncated page")
+	}
+	// transpose the streams back into
+	// PLAIN-encoded values
+	plain := make([]byte, int(width)*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < int(width); j++ {
+			plain[i*int(width)+j] = src[j*n+i]
+		}
+	}
+	return decodePlain(v, typ, width, plain, n)
+}
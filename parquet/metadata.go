@@ -0,0 +1,364 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parquet
+
+// The structures in this file mirror the
+// subset of parquet.thrift that is needed
+// to read a file; the field IDs in the
+// decoding functions are the thrift field IDs.
+
+// physical types
+const (
+	typeBoolean           = 0
+	typeInt32             = 1
+	typeInt64             = 2
+	typeInt96             = 3
+	typeFloat             = 4
+	typeDouble            = 5
+	typeByteArray         = 6
+	typeFixedLenByteArray = 7
+
+	// typeGroup is used for schema
+	// elements without a physical type
+	typeGroup = -1
+)
+
+// field repetition types
+const (
+	required = 0
+	optional = 1
+	repeated = 2
+)
+
+// converted types
+const (
+	convNone            = -1
+	convUTF8            = 0
+	convMap             = 1
+	convMapKeyValue     = 2
+	convList            = 3
+	convEnum            = 4
+	convDecimal         = 5
+	convDate            = 6
+	convTimestampMillis = 9
+	convTimestampMicros = 10
+	convUint8           = 11
+	convUint16          = 12
+	convUint32          = 13
+	convUint64          = 14
+	convJSON            = 19
+)
+
+// logical types (the field IDs
+// of the LogicalType union)
+const (
+	logicalNone      = 0
+	logicalString    = 1
+	logicalMap       = 2
+	logicalList      = 3
+	logicalEnum      = 4
+	logicalDecimal   = 5
+	logicalDate      = 6
+	logicalTime      = 7
+	logicalTimestamp = 8
+	logicalInteger   = 10
+	logicalJSON      = 12
+	logicalUUID      = 14
+)
+
+// time units (the field IDs
+// of the TimeUnit union)
+const (
+	unitMillis = 1
+	unitMicros = 2
+	unitNanos  = 3
+)
+
+// encodings
+const (
+	encPlain                = 0
+	encPlainDictionary      = 2
+	encRLE                  = 3
+	encBitPacked            = 4
+	encDeltaBinaryPacked    = 5
+	encDeltaLengthByteArray = 6
+	encDeltaByteArray       = 7
+	encRLEDictionary        = 8
+	encByteStreamSplit      = 9
+)
+
+// compression codecs
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+)
+
+// page types
+const (
+	pageData       = 0
+	pageIndex      = 1
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+type fileMetaData struct {
+	schema    []schemaElement
+	numRows   int64
+	rowGroups []rowGroup
+}
+
+type logicalType struct {
+	kind     int16
+	unit     int16 // for logicalTimestamp
+	unsigned bool  // for logicalInteger
+}
+
+type schemaElement struct {
+	typ         int32
+	typeLength  int32
+	repetition  int32
+	name        string
+	numChildren int32
+	converted   int32
+	scale       int32
+	logical     logicalType
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	numRows int64
+}
+
+type columnChunk struct {
+	meta columnMetaData
+}
+
+type columnMetaData struct {
+	typ                  int32
+	path                 []string
+	codec                int32
+	numValues            int64
+	totalCompressedSize  int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+}
+
+type pageHeader struct {
+	typ              int32
+	uncompressedSize int32
+	compressedSize   int32
+
+	// from the DataPageHeader, DataPageHeaderV2
+	// or DictionaryPageHeader, depending on typ
+	numValues int32
+	encoding  int32
+
+	// for DataPageHeaderV2
+	defLength, repLength int32
+	uncompressed         bool
+}
+
+func (t *thrift) fileMetaData(m *fileMetaData) error {
+	return t.readStruct(ctStruct, func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 2:
+			err = t.readList(typ, func(elem byte) error {
+				m.schema = append(m.schema, schemaElement{})
+				return t.schemaElement(elem, &m.schema[len(m.schema)-1])
+			})
+		case 3:
+			m.numRows, err = t.int(typ)
+		case 4:
+			err = t.readList(typ, func(elem byte) error {
+				m.rowGroups = append(m.rowGroups, rowGroup{})
+				return t.rowGroup(elem, &m.rowGroups[len(m.rowGroups)-1])
+			})
+		default:
+			err = t.skip(typ)
+		}
+		return err
+	})
+}
+
+func (t *thrift) schemaElement(typ byte, s *schemaElement) error {
+	s.typ = typeGroup
+	s.converted = convNone
+	return t.readStruct(typ, func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			s.typ, err = t.i32(typ)
+		case 2:
+			s.typeLength, err = t.i32(typ)
+		case 3:
+			s.repetition, err = t.i32(typ)
+		case 4:
+			s.name, err = t.string(typ)
+		case 5:
+			s.numChildren, err = t.i32(typ)
+		case 6:
+			s.converted, err = t.i32(typ)
+		case 7:
+			s.scale, err = t.i32(typ)
+		case 10:
+			err = t.logicalType(typ, &s.logical)
+		default:
+			err = t.skip(typ)
+		}
+		return err
+	})
+}
+
+func (t *thrift) logicalType(typ byte, l *logicalType) error {
+	return t.readStruct(typ, func(id int16, typ byte) error {
+		l.kind = id
+		switch id {
+		case logicalTimestamp:
+			return t.readStruct(typ, func(id int16, typ byte) error {
+				if id != 2 {
+					return t.skip(typ)
+				}
+				// TimeUnit is a union of empty structs
+				return t.readStruct(typ, func(id int16, typ byte) error {
+					l.unit = id
+					return t.skip(typ)
+				})
+			})
+		case logicalInteger:
+			return t.readStruct(typ, func(id int16, typ byte) error {
+				if id != 2 {
+					return t.skip(typ)
+				}
+				signed, err := t.bool(typ)
+				l.unsigned = !signed
+				return err
+			})
+		default:
+			return t.skip(typ)
+		}
+	})
+}
+
+func (t *thrift) rowGroup(typ byte, rg *rowGroup) error {
+	return t.readStruct(typ, func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			err = t.readList(typ, func(elem byte) error {
+				rg.columns = append(rg.columns, columnChunk{})
+				return t.columnChunk(elem, &rg.columns[len(rg.columns)-1])
+			})
+		case 3:
+			rg.numRows, err = t.int(typ)
+		default:
+			err = t.skip(typ)
+		}
+		return err
+	})
+}
+
+func (t *thrift) columnChunk(typ byte, c *columnChunk) error {
+	return t.readStruct(typ, func(id int16, typ byte) error {
+		if id != 3 {
+			return t.skip(typ)
+		}
+		return t.columnMetaData(typ, &c.meta)
+	})
+}
+
+func (t *thrift) columnMetaData(typ byte, m *columnMetaData) error {
+	return t.readStruct(typ, func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			m.typ, err = t.i32(typ)
+		case 3:
+			err = t.readList(typ, func(elem byte) error {
+				s, err := t.string(elem)
+				m.path = append(m.path, s)
+				return err
+			})
+		case 4:
+			m.codec, err = t.i32(typ)
+		case 5:
+			m.numValues, err = t.int(typ)
+		case 7:
+			m.totalCompressedSize, err = t.int(typ)
+		case 9:
+			m.dataPageOffset, err = t.int(typ)
+		case 11:
+			m.dictionaryPageOffset, err = t.int(typ)
+		default:
+			err = t.skip(typ)
+		}
+		return err
+	})
+}
+
+func (t *thrift) pageHeader(h *pageHeader) error {
+	return t.readStruct(ctStruct, func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			h.typ, err = t.i32(typ)
+		case 2:
+			h.uncompressedSize, err = t.i32(typ)
+		case 3:
+			h.compressedSize, err = t.i32(typ)
+		case 5, 7:
+			// DataPageHeader and DictionaryPageHeader
+			// both begin with num_values and encoding
+			err = t.readStruct(typ, func(id int16, typ byte) error {
+				var err error
+				switch id {
+				case 1:
+					h.numValues, err = t.i32(typ)
+				case 2:
+					h.encoding, err = t.i32(typ)
+				default:
+					err = t.skip(typ)
+				}
+				return err
+			})
+		case 8:
+			err = t.readStruct(typ, func(id int16, typ byte) error {
+				var err error
+				switch id {
+				case 1:
+					h.numValues, err = t.i32(typ)
+				case 4:
+					h.encoding, err = t.i32(typ)
+				case 5:
+					h.defLength, err = t.i32(typ)
+				case 6:
+					h.repLength, err = t.i32(typ)
+				case 7:
+					var compressed bool
+					compressed, err = t.bool(typ)
+					h.uncompressed = !compressed
+				default:
+					err = t.skip(typ)
+				}
+				return err
+			})
+		default:
+			err = t.skip(typ)
+		}
+		return err
+	})
+}
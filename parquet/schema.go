@@ -0,0 +1,296 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parquet
+
+import (
+	"strings"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/jsonrl"
+)
+
+// maxSchemaDepth is the maximum
+// nesting depth of a schema
+const maxSchemaDepth = 64
+
+// kind determines how a schema node is written
+type kind uint8
+
+const (
+	kindGroup kind = iota // struct
+	kindList              // LIST or MAP group
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindDecimal
+	kindString
+	kindBytes // string if valid UTF-8, otherwise blob
+	kindBlob
+	kindUUID
+	kindTimestamp
+	kindDate
+	kindInt96 // legacy timestamp
+)
+
+// node is a node in the schema tree
+type node struct {
+	name       string
+	parent     *node
+	typ        int32
+	typeLength int32
+	repetition int32
+	kind       kind
+	unit       int16 // for kindTimestamp
+	scale      int32 // for kindDecimal
+
+	maxDef, maxRep int
+	children       []*node
+
+	// for kindList, list is the repeated
+	// child and elem is the list element,
+	// which is either list or its only child
+	list, elem *node
+
+	// ignore is set if the node is not written;
+	// index is set if the node is a timestamp
+	// that is added to the sparse index
+	ignore, index bool
+
+	// col is set for leaves that are written,
+	// and cols holds the columns for all of
+	// the leaves under the node that are written
+	col  *column
+	cols []*column
+
+	// sym is the symbol for the name of the node;
+	// fields holds the children of a group that
+	// are written and order is the order in which
+	// they are written, sorted by symbol
+	sym    ion.Symbol
+	fields []*node
+	order  []int
+}
+
+// names returns the names of the nodes
+// on the path from the root to n
+func (n *node) names() []string {
+	var names []string
+	for ; n.parent != nil; n = n.parent {
+		names = append(names, n.name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+func (n *node) path() string {
+	return strings.Join(n.names(), ".")
+}
+
+// buildSchema builds the schema tree from
+// the flattened list of schema elements and
+// returns the root along with the leaves
+// in column order
+func buildSchema(elems []schemaElement) (*node, []*node, error) {
+	if len(elems) == 0 {
+		return nil, nil, corrupt("empty schema")
+	}
+	var leaves []*node
+	var build func(parent *node, depth int) (*node, error)
+	build = func(parent *node, depth int) (*node, error) {
+		if len(elems) == 0 {
+			return nil, corrupt("schema is missing elements")
+		}
+		if depth > maxSchemaDepth {
+			return nil, corrupt("schema nested too deeply")
+		}
+		e := &elems[0]
+		elems = elems[1:]
+		n := &node{
+			name:       e.name,
+			parent:     parent,
+			typ:        e.typ,
+			typeLength: e.typeLength,
+			repetition: e.repetition,
+			scale:      e.scale,
+		}
+		if parent != nil {
+			n.maxDef, n.maxRep = parent.maxDef, parent.maxRep
+			if n.repetition != required {
+				n.maxDef++
+			}
+			if n.repetition == repeated {
+				n.maxRep++
+			}
+		}
+		if n.typ == typeGroup {
+			if e.numChildren <= 0 || int(e.numChildren) > len(elems) {
+				return nil, corrupt("group %q has %d children", e.name, e.numChildren)
+			}
+			for i := 0; i < int(e.numChildren); i++ {
+				c, err := build(n, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				n.children = append(n.children, c)
+			}
+			if parent != nil {
+				n.kind = groupKind(n, e)
+			}
+			return n, nil
+		}
+		if parent == nil {
+			return nil, corrupt("schema root is not a group")
+		}
+		n.kind, n.unit = leafKind(e)
+		leaves = append(leaves, n)
+		return n, nil
+	}
+	root, err := build(nil, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(elems) != 0 {
+		return nil, nil, corrupt("schema has %d extra elements", len(elems))
+	}
+	return root, leaves, nil
+}
+
+// groupKind determines whether a group is
+// a LIST or MAP, and if it is, sets n.list
+// and n.elem following the backward-compatibility
+// rules in the parquet LogicalTypes documentation
+func groupKind(n *node, e *schemaElement) kind {
+	isList := e.logical.kind == logicalList || e.converted == convList
+	isMap := e.logical.kind == logicalMap || e.converted == convMap || e.converted == convMapKeyValue
+	if !isList && !isMap || len(n.children) != 1 || n.children[0].repetition != repeated {
+		return kindGroup
+	}
+	r := n.children[0]
+	n.list, n.elem = r, r
+	if isList && r.typ == typeGroup && len(r.children) == 1 &&
+		r.name != "array" && r.name != n.name+"_tuple" {
+		n.elem = r.children[0]
+	}
+	return kindList
+}
+
+func leafKind(e *schemaElement) (kind, int16) {
+	l := &e.logical
+	switch e.typ {
+	case typeBoolean:
+		return kindBool, 0
+	case typeInt32:
+		switch {
+		case l.kind == logicalDate || e.converted == convDate:
+			return kindDate, 0
+		case l.kind == logicalDecimal || e.converted == convDecimal:
+			return kindDecimal, 0
+		case l.kind == logicalInteger && l.unsigned,
+			e.converted >= convUint8 && e.converted <= convUint32:
+			return kindUint, 0
+		}
+		return kindInt, 0
+	case typeInt64:
+		switch {
+		case l.kind == logicalTimestamp:
+			return kindTimestamp, l.unit
+		case e.converted == convTimestampMillis:
+			return kindTimestamp, unitMillis
+		case e.converted == convTimestampMicros:
+			return kindTimestamp, unitMicros
+		case l.kind == logicalDecimal || e.converted == convDecimal:
+			return kindDecimal, 0
+		case l.kind == logicalInteger && l.unsigned, e.converted == convUint64:
+			return kindUint, 0
+		}
+		return kindInt, 0
+	case typeInt96:
+		return kindInt96, 0
+	case typeFloat, typeDouble:
+		return kindFloat, 0
+	case typeByteArray, typeFixedLenByteArray:
+		switch {
+		case l.kind == logicalString || l.kind == logicalEnum || l.kind == logicalJSON,
+			e.converted == convUTF8 || e.converted == convEnum || e.converted == convJSON:
+			return kindString, 0
+		case l.kind == logicalDecimal || e.converted == convDecimal:
+			return kindDecimal, 0
+		case l.kind == logicalUUID && e.typeLength == 16:
+			return kindUUID, 0
+		case e.typ == typeByteArray && l.kind == logicalNone && e.converted == convNone:
+			return kindBytes, 0
+		}
+		return kindBlob, 0
+	}
+	return kindBlob, 0
+}
+
+// prepare applies hints to the tree rooted at n,
+// whose position in a record is given by path,
+// and collects the columns that are written;
+// plain is set if n is only nested in structures
+func (n *node) prepare(hints *jsonrl.Hint, path []string, plain bool) {
+	ignore, noIndex := hints.Lookup(path)
+	if !ignore && n.repetition == repeated && n.parent != nil && n.parent.list != n {
+		// a bare repeated field is a list of its elements
+		path = append(path, "")
+		ignore, noIndex = hints.Lookup(path)
+		plain = false
+	}
+	if ignore {
+		n.ignore = true
+		return
+	}
+	switch n.kind {
+	case kindGroup:
+		seen := make(map[string]bool)
+		for _, c := range n.children {
+			c.prepare(hints, append(path[:len(path):len(path)], c.name), plain)
+			// when field names are duplicated,
+			// the first one wins
+			if c.ignore || seen[c.name] {
+				c.ignore = true
+				continue
+			}
+			seen[c.name] = true
+			n.fields = append(n.fields, c)
+			n.cols = append(n.cols, c.cols...)
+		}
+	case kindList:
+		path = append(path[:len(path):len(path)], "")
+		e := n.elem
+		e.prepare(hints, path, false)
+		if e.ignore {
+			n.ignore = true
+			return
+		}
+		n.cols = e.cols
+		n.list.cols = e.cols
+	default:
+		n.col = &column{leaf: n}
+		n.cols = []*column{n.col}
+		switch n.kind {
+		case kindTimestamp, kindDate, kindInt96:
+			n.index = plain && !noIndex && len(path) < jsonrl.MaxIndexingDepth
+		}
+	}
+	if len(n.cols) == 0 {
+		// nothing under this node is written
+		n.ignore = true
+	}
+}
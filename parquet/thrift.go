@@ -0,0 +1,259 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// thrift compact protocol type codes
+const (
+	ctStop   = 0
+	ctTrue   = 1
+	ctFalse  = 2
+	ctByte   = 3
+	ctI16    = 4
+	ctI32    = 5
+	ctI64    = 6
+	ctDouble = 7
+	ctBinary = 8
+	ctList   = 9
+	ctSet    = 10
+	ctMap    = 11
+	ctStruct = 12
+)
+
+// maxThriftDepth is the maximum nesting
+// depth of thrift structures
+const maxThriftDepth = 32
+
+// thrift decodes the thrift compact protocol,
+// which is the encoding used for all of
+// the metadata in a parquet file
+type thrift struct {
+	buf   []byte
+	depth int
+}
+
+func (t *thrift) errorf(f string, args ...interface{}) error {
+	return fmt.Errorf("thrift: "+f+": %w", append(args, ErrCorrupt)...)
+}
+
+func (t *thrift) byte() (byte, error) {
+	if len(t.buf) == 0 {
+		return 0, t.errorf("unexpected end of input")
+	}
+	b := t.buf[0]
+	t.buf = t.buf[1:]
+	return b, nil
+}
+
+func (t *thrift) uvarint() (uint64, error) {
+	u, n := binary.Uvarint(t.buf)
+	if n <= 0 {
+		return 0, t.errorf("bad varint")
+	}
+	t.buf = t.buf[n:]
+	return u, nil
+}
+
+// zigzag reads a zigzag-encoded varint
+func (t *thrift) zigzag() (int64, error) {
+	u, err := t.uvarint()
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+// int reads an integer field of type typ
+func (t *thrift) int(typ byte) (int64, error) {
+	switch typ {
+	case ctByte:
+		b, err := t.byte()
+		return int64(int8(b)), err
+	case ctI16, ctI32, ctI64:
+		return t.zigzag()
+	default:
+		return 0, t.errorf("type %d is not an integer", typ)
+	}
+}
+
+func (t *thrift) i32(typ byte) (int32, error) {
+	n, err := t.int(typ)
+	return int32(n), err
+}
+
+// bool reads a boolean struct field of type typ
+func (t *thrift) bool(typ byte) (bool, error) {
+	switch typ {
+	case ctTrue:
+		return true, nil
+	case ctFalse:
+		return false, nil
+	default:
+		return false, t.errorf("type %d is not a bool", typ)
+	}
+}
+
+func (t *thrift) binary(typ byte) ([]byte, error) {
+	if typ != ctBinary {
+		return nil, t.errorf("type %d is not binary", typ)
+	}
+	n, err := t.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(t.buf)) {
+		return nil, t.errorf("binary length %d out of range", n)
+	}
+	b := t.buf[:n:n]
+	t.buf = t.buf[n:]
+	return b, nil
+}
+
+func (t *thrift) string(typ byte) (string, error) {
+	b, err := t.binary(typ)
+	return string(b), err
+}
+
+// readStruct reads a struct of type typ
+// and calls fn for each of its fields;
+// fn must consume the field, either by
+// reading it or by calling t.skip
+func (t *thrift) readStruct(typ byte, fn func(id int16, typ byte) error) error {
+	if typ != ctStruct {
+		return t.errorf("type %d is not a struct", typ)
+	}
+	if t.depth >= maxThriftDepth {
+		return t.errorf("structures nested too deeply")
+	}
+	t.depth++
+	defer func() { t.depth-- }()
+	var id int16
+	for {
+		b, err := t.byte()
+		if err != nil {
+			return err
+		}
+		if b == ctStop {
+			return nil
+		}
+		if delta := b >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			n, err := t.zigzag()
+			if err != nil {
+				return err
+			}
+			id = int16(n)
+		}
+		err = fn(id, b&0xf)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readList reads a list of type typ
+// and calls fn for each of its elements
+func (t *thrift) readList(typ byte, fn func(elem byte) error) error {
+	if typ != ctList && typ != ctSet {
+		return t.errorf("type %d is not a list", typ)
+	}
+	b, err := t.byte()
+	if err != nil {
+		return err
+	}
+	n := uint64(b >> 4)
+	if n == 15 {
+		n, err = t.uvarint()
+		if err != nil {
+			return err
+		}
+	}
+	// every element takes at least one byte
+	if n > uint64(len(t.buf)) {
+		return t.errorf("list length %d out of range", n)
+	}
+	elem := b & 0xf
+	for i := uint64(0); i < n; i++ {
+		err = fn(elem)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip skips a value of type typ
+func (t *thrift) skip(typ byte) error {
+	var err error
+	switch typ {
+	case ctTrue, ctFalse:
+		// encoded in the field header
+	case ctByte:
+		_, err = t.byte()
+	case ctI16, ctI32, ctI64:
+		_, err = t.uvarint()
+	case ctDouble:
+		if len(t.buf) < 8 {
+			return t.errorf("unexpected end of input")
+		}
+		t.buf = t.buf[8:]
+	case ctBinary:
+		_, err = t.binary(typ)
+	case ctList, ctSet:
+		err = t.readList(typ, t.skipElem)
+	case ctMap:
+		var n uint64
+		n, err = t.uvarint()
+		if err != nil || n == 0 {
+			break
+		}
+		if n > uint64(len(t.buf)) {
+			return t.errorf("map length %d out of range", n)
+		}
+		var kv byte
+		kv, err = t.byte()
+		for i := uint64(0); err == nil && i < n; i++ {
+			err = t.skipElem(kv >> 4)
+			if err == nil {
+				err = t.skipElem(kv & 0xf)
+			}
+		}
+	case ctStruct:
+		err = t.readStruct(typ, func(_ int16, typ byte) error {
+			return t.skip(typ)
+		})
+	default:
+		err = t.errorf("unknown type %d", typ)
+	}
+	return err
+}
+
+// skipElem skips a list or map element;
+// unlike struct fields, booleans in
+// collections take up one byte
+func (t *thrift) skipElem(typ byte) error {
+	if typ == ctTrue || typ == ctFalse {
+		_, err := t.byte()
+		return err
+	}
+	if t.depth >= maxThriftDepth {
+		return t.errorf("structures nested too deeply")
+	}
+	t.depth++
+	defer func() { t.depth-- }()
+	return t.skip(typ)
+}
@@ -233,10 +233,20 @@ func empty(name string) Op {
 		return &Project{}
 	case "filter":
 		return &Filter{}
+	case "exists":
+		return &Exists{}
 	case "unnest":
 		return &Unnest{}
+	case "hashjoin":
+		return &HashJoin{}
 	case "unionmap":
 		return &UnionMap{}
+	case "unionall":
+		return &UnionAll{}
+	case "window":
+		return &Window{}
+	case "json":
+		return &JSON{}
 	case "outpart":
 		return &OutputPart{}
 	case "outidx":
@@ -124,3 +124,50 @@ func (b *benchenv) Stat(_ expr.Node, _ *Hints) (TableHandle, error) {
 	}
 	return &blobHandle{&blob.List{lst}}, nil
 }
+
+func TestEncodeUnionAllRewrite(t *testing.T) {
+	query := `SELECT x FROM 'a' UNION ALL SELECT x FROM 'b'`
+	s, err := partiql.Parse([]byte(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := New(s, &benchenv{blocks: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrites := 0
+	rw := func(tbl *expr.Table, th TableHandle) (*expr.Table, TableHandle) {
+		rewrites++
+		return &expr.Table{Binding: expr.Bind(expr.String("rewritten"), "")}, nil
+	}
+	var buf ion.Buffer
+	var st ion.Symtab
+	err = tree.EncodePart(&buf, &st, rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrites != 2 {
+		t.Errorf("%d tables rewritten; expected 2", rewrites)
+	}
+	out, err := Decode(&benchenv{}, &st, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u *UnionAll
+	for op := out.Root.Op; op != nil; op = op.input() {
+		if o, ok := op.(*UnionAll); ok {
+			u = o
+		}
+	}
+	if u == nil || len(u.Inputs) != 2 {
+		t.Fatalf("unexpected plan %s", out.String())
+	}
+	for i := range u.Inputs {
+		for j := range u.Inputs[i].Inputs {
+			tbl := u.Inputs[i].Inputs[j].Table
+			if s := expr.ToString(tbl.Expr); s != `'rewritten'` {
+				t.Errorf("input %d table %d is %s", i, j, s)
+			}
+		}
+	}
+}
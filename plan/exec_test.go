@@ -51,6 +51,9 @@ type testenv struct {
 	// Stat failure message, for testing
 	// query planning errors
 	mustfail string
+
+	// spill is returned by Spill
+	spill bool
 }
 
 func (t *testenv) get(fname string) *os.File {
@@ -113,6 +116,10 @@ func (t *testenv) Schema(tbl expr.Node) expr.Hint {
 
 var _ Indexer = (*testenv)(nil)
 
+var _ Spiller = (*testenv)(nil)
+
+func (t *testenv) Spill() bool { return t.spill }
+
 func (t *testenv) Index(tbl expr.Node) (Index, error) {
 	if t.indexer == nil {
 		return nil, nil
@@ -295,6 +302,9 @@ func TestExec(t *testing.T) {
 		// expectBytes, if non-zero, is the number
 		// of bytes we expect the query to scan
 		expectBytes int
+		// spill, if set, permits the query
+		// to spill to disk
+		spill bool
 	}{
 		{
 			query:       `select * from 'nyc-taxi.block'`,
@@ -307,6 +317,56 @@ func TestExec(t *testing.T) {
 			firstrow:    countmsg(8560),
 			expectBytes: nycTaxiBytes,
 		},
+		{
+			// self-join on a unique key
+			query:    `select COUNT(*) from 'parking.10n' a join 'parking.10n' b on a.Ticket = b.Ticket`,
+			rows:     1,
+			firstrow: countmsg(1023),
+		},
+		{
+			// there are 122 Make="HOND" rows;
+			// every other row is unmatched
+			query:    `select COUNT(*) from 'parking.10n' a left join (select Make from 'parking.10n' where Make = 'HOND' limit 1) b on a.Make = b.Make where b.Make is missing`,
+			rows:     1,
+			firstrow: countmsg(1023 - 122),
+		},
+		{
+			query:    `select COUNT(*) from (select Ticket from 'parking.10n' union all select Ticket from 'parking.10n' where Make = 'HOND')`,
+			rows:     1,
+			firstrow: countmsg(1023 + 122),
+		},
+		{
+			query:    `select Make, AVG(Fine) AS f from (select Make, Fine from 'parking.10n' union all select Make, Fine from 'parking.10n' where Make = 'HOND') where Make = 'HOND' group by Make`,
+			rows:     1,
+			firstrow: `{"Make": "HOND", "f": 71.43442622950819}`,
+		},
+		{
+			query:    `select COUNT(*) from (select Make from 'parking.10n' where Make = 'HOND' union select Make from 'parking.10n' where Make = 'HOND')`,
+			rows:     1,
+			firstrow: countmsg(1),
+		},
+		{
+			// Ticket is unique
+			query:    `select COUNT(*) from (select Ticket from 'parking.10n' except select Ticket from 'parking.10n' where Make = 'HOND')`,
+			rows:     1,
+			firstrow: countmsg(1023 - 122),
+		},
+		{
+			query:    `select COUNT(*) from (select Ticket from 'parking.10n' intersect select Ticket from 'parking.10n' where Make = 'HOND')`,
+			rows:     1,
+			firstrow: countmsg(122),
+		},
+		{
+			query:     `select Ticket from 'parking.10n' where Make = 'HOND' union all select Ticket from 'parking.10n' where Make <> 'HOND'`,
+			rows:      1023,
+			matchPlan: []string{`UNION ALL \(`},
+		},
+		{
+			// round-trip through JSON text
+			query:    `select COUNT(*) from 'parking.10n' where JSON_EXTRACT(TO_JSON_STRING(Make), '$') = 'HOND'`,
+			rows:     1,
+			firstrow: countmsg(122),
+		},
 		{
 			query:       `select COUNT(*) from 'parking.10n' where Make is missing`,
 			rows:        1,
@@ -957,6 +1017,54 @@ from 'parking.10n' limit 1`,
 			rows:     1,
 			firstrow: `{"count": 24}`,
 		},
+		{
+			// there are 24 distinct colors, but two of
+			// them happen to land in the same register
+			query:    `select approx_count_distinct(Color) from 'parking.10n'`,
+			rows:     1,
+			firstrow: `{"count": 23}`,
+		},
+		{
+			// 1023 distinct tickets; the estimate
+			// is within the expected error
+			query:    `select approx_count_distinct(Ticket) from 'parking.10n'`,
+			rows:     1,
+			firstrow: `{"count": 996}`,
+		},
+		{
+			query: `select approx_count_distinct(Ticket) as tickets, Color from 'parking.10n' group by Color order by approx_count_distinct(Ticket) desc limit 1`,
+			rows:  1,
+		},
+		{
+			// the exact median and 90th percentile
+			// are 73 and 93, respectively
+			query:    `select median(Fine) as med, approx_percentile(Fine, 0.9) as p90 from 'parking.10n'`,
+			rows:     1,
+			firstrow: `{"med": 71.79310344827586, "p90": 91.44615384615385}`,
+		},
+		{
+			query:    `select Color, percentile_cont(0.5) within group (order by Fine) as med from 'parking.10n' group by Color order by med desc, Color limit 1`,
+			rows:     1,
+			firstrow: `{"Color": "TN", "med": 93}`,
+		},
+		{
+			// compare against the naive computations
+			query:    `select round(var_pop(Fine) - (avg(Fine*Fine) - avg(Fine)*avg(Fine))) as diff, round(stddev_pop(Fine)*1000) as sd, round(corr(Fine, Fine)*1000) as c from 'parking.10n'`,
+			rows:     1,
+			firstrow: `{"diff": 0, "sd": 38262, "c": 1000}`,
+		},
+		{
+			// the largest tickets, and the colors
+			// of the two smallest tickets
+			query:    `select array_agg(Ticket order by Ticket desc limit 3) as top, array_agg(distinct Color order by Ticket limit 2) as colors from 'parking.10n'`,
+			rows:     1,
+			firstrow: `{"top": [4272473892, 4272473881, 4272473870], "colors": ["GY", "WH"]}`,
+		},
+		{
+			query:    `select Color, array_agg(Ticket order by Ticket desc limit 2) as top from 'parking.10n' group by Color order by Color limit 1`,
+			rows:     1,
+			firstrow: `{"Color": "BG", "top": [4272277660, 4271686871]}`,
+		},
 		{
 			// count the number of distinct colors occuring for each Make
 			query:    `select count(distinct Color), Make from 'parking.10n' group by Make order by count(distinct Color), Make desc`,
@@ -1079,6 +1187,59 @@ where Make in (
 				`{"Ticket": 1104820732}`,
 			},
 		},
+		{
+			// test ORDER BY with LIMIT and OFFSET
+			query: `select count(*) from (select Ticket from 'parking.10n' order by Ticket limit 100 offset 1000)`,
+			expectedRows: []string{
+				`{"count": 23}`,
+			},
+		},
+		{
+			// test GROUP BY with ORDER BY, LIMIT, and OFFSET
+			query: `select Make, count(*) as c from 'parking.10n' group by Make order by c desc, Make limit 2 offset 1`,
+			expectedRows: []string{
+				`{"Make": "TOYO", "c": 96}`,
+				`{"Make": "FORD", "c": 88}`,
+			},
+		},
+		{
+			// test GROUP BY with spilling permitted
+			query: `select Make, count(*) as c from 'parking.10n' group by Make order by c desc, Make limit 2 offset 1`,
+			spill: true,
+			expectedRows: []string{
+				`{"Make": "TOYO", "c": 96}`,
+				`{"Make": "FORD", "c": 88}`,
+			},
+			matchPlan: []string{`LIMIT 3 \(spill\)`},
+		},
+		{
+			// test GROUP BY and ORDER BY without LIMIT
+			// with spilling permitted; the hash aggregate
+			// orders the groups itself (with an external
+			// sort if it has to write them to disk)
+			query:     `select Make, count(*) as c from 'parking.10n' group by Make order by c desc, Make`,
+			spill:     true,
+			rows:      59,
+			firstrow:  `{"Make": "HOND", "c": 122}`,
+			matchPlan: []string{`HASH AGGREGATE .* GROUP BY .* ORDER BY`},
+		},
+		{
+			// test ORDER BY without LIMIT using an external sort
+			query:     `select Ticket from 'parking.10n' order by Ticket`,
+			spill:     true,
+			rows:      1023,
+			firstrow:  `{"Ticket": 1103341116}`,
+			matchPlan: []string{`ORDER BY Ticket ASC NULLS FIRST \(spill\)`},
+		},
+		{
+			// test ORDER BY with a LIMIT too large
+			// for an in-memory sort
+			query:     `select Ticket from 'parking.10n' order by Ticket desc limit 20000 offset 1020`,
+			spill:     true,
+			rows:      3,
+			firstrow:  `{"Ticket": 1104803000}`,
+			matchPlan: []string{`LIMIT 20000 OFFSET 1020`, `\(spill\)`},
+		},
 		{
 			// test projection of a computed number
 			// that is sometimes an integer and sometimes a float
@@ -1274,6 +1435,7 @@ where Make in (
 		indexer := tcs[i].indexer
 		pmatch := tcs[i].matchPlan
 		scanned := tcs[i].expectBytes
+		spill := tcs[i].spill
 		t.Run(fmt.Sprintf("case-%d", i+1), func(t *testing.T) {
 			var dst bytes.Buffer
 			q, err := partiql.Parse([]byte(text))
@@ -1291,6 +1453,7 @@ where Make in (
 			t.Logf("query: %s", expr.ToString(q))
 			env.schema = schema
 			env.indexer = indexer
+			env.spill = spill
 			tree, err := New(q, env)
 			if err != nil {
 				t.Errorf("case %d: %s", i, err)
@@ -0,0 +1,140 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"fmt"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// Exists is a plan that filters the input
+// rows on an EXISTS predicate over the
+// elements of a list within each row
+type Exists struct {
+	Nonterminal
+	Expr *expr.Exists
+	// Negated is set if the rows for which
+	// Expr is not TRUE should be passed instead
+	Negated bool
+}
+
+func (e *Exists) String() string {
+	if e.Negated {
+		return "WHERE " + expr.ToString(&expr.Not{Expr: e.Expr})
+	}
+	return "WHERE " + expr.ToString(e.Expr)
+}
+
+func (e *Exists) rewrite(rw expr.Rewriter) {
+	e.From.rewrite(rw)
+	if ex, ok := expr.Rewrite(rw, e.Expr).(*expr.Exists); ok {
+		e.Expr = ex
+	}
+}
+
+func (e *Exists) exec(dst vm.QuerySink, ep *execParams) error {
+	return e.From.exec(vm.NewExists(dst, e.Expr, e.Negated), ep)
+}
+
+func (e *Exists) encode(dst *ion.Buffer, st *ion.Symtab) error {
+	dst.BeginStruct(-1)
+	settype("exists", dst, st)
+	dst.BeginField(st.Intern("expr"))
+	e.Expr.Encode(dst, st)
+	if e.Negated {
+		dst.BeginField(st.Intern("negated"))
+		dst.WriteBool(true)
+	}
+	dst.EndStruct()
+	return nil
+}
+
+func (e *Exists) setfield(d Decoder, name string, st *ion.Symtab, body []byte) error {
+	switch name {
+	case "expr":
+		n, _, err := expr.Decode(st, body)
+		if err != nil {
+			return err
+		}
+		ex, ok := n.(*expr.Exists)
+		if !ok {
+			return fmt.Errorf("cannot use node of type %T as plan.Exists.Expr", n)
+		}
+		e.Expr = ex
+	case "negated":
+		var err error
+		e.Negated, _, err = ion.ReadBool(body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lowerWhere lowers a WHERE clause into
+// a Filter on the conjunctions that are not
+// EXISTS predicates over a list followed by an
+// Exists for each (possibly negated) one that is
+func lowerWhere(where expr.Node, from Op) Op {
+	var rest expr.Node
+	var exists []*Exists
+	and := func(e expr.Node) {
+		if rest == nil {
+			rest = e
+		} else {
+			rest = expr.And(rest, e)
+		}
+	}
+	var split func(e expr.Node)
+	split = func(e expr.Node) {
+		if l, ok := e.(*expr.Logical); ok && l.Op == expr.OpAnd {
+			split(l.Left)
+			split(l.Right)
+			return
+		}
+		negated := false
+		if n, ok := e.(*expr.Not); ok {
+			if _, ok := n.Expr.(*expr.Exists); ok {
+				e, negated = n.Expr, true
+			}
+		}
+		ex, ok := e.(*expr.Exists)
+		if !ok {
+			and(e)
+			return
+		}
+		if negated {
+			// NOT EXISTS is MISSING rather than
+			// TRUE for rows without a list
+			and(expr.Is(expr.CallOp(expr.ArrayLength, ex.List), expr.IsNotMissing))
+		}
+		exists = append(exists, &Exists{Expr: ex, Negated: negated})
+	}
+	split(where)
+	if rest != nil {
+		from = &Filter{
+			Nonterminal: Nonterminal{From: from},
+			Expr:        rest,
+		}
+	}
+	for i := range exists {
+		exists[i].From = from
+		from = exists[i]
+	}
+	return from
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"fmt"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// HashJoin joins each row of its input
+// with the rows of Build that have an equal
+// join key and binds the matching row to Bind
+type HashJoin struct {
+	Nonterminal
+	Kind  expr.JoinKind // InnerJoin, LeftJoin, or FullJoin
+	Bind  string        // binding for the build row
+	Probe *expr.Path    // join key for input rows
+	Key   *expr.Path    // join key for build rows
+	Build expr.Node     // build rows; *expr.List once replaced
+}
+
+func (h *HashJoin) rewrite(rw expr.Rewriter) {
+	h.From.rewrite(rw)
+	h.Build = expr.Rewrite(rw, h.Build)
+}
+
+func (h *HashJoin) encode(dst *ion.Buffer, st *ion.Symtab) error {
+	dst.BeginStruct(-1)
+	settype("hashjoin", dst, st)
+	dst.BeginField(st.Intern("kind"))
+	dst.WriteInt(int64(h.Kind))
+	dst.BeginField(st.Intern("bind"))
+	dst.WriteString(h.Bind)
+	dst.BeginField(st.Intern("probe"))
+	h.Probe.Encode(dst, st)
+	dst.BeginField(st.Intern("key"))
+	h.Key.Encode(dst, st)
+	dst.BeginField(st.Intern("build"))
+	h.Build.Encode(dst, st)
+	dst.EndStruct()
+	return nil
+}
+
+func decodePath(dst **expr.Path, st *ion.Symtab, body []byte) error {
+	e, _, err := expr.Decode(st, body)
+	if err != nil {
+		return err
+	}
+	p, ok := e.(*expr.Path)
+	if !ok {
+		return fmt.Errorf("unexpected join key of type %T", e)
+	}
+	*dst = p
+	return nil
+}
+
+func (h *HashJoin) setfield(d Decoder, name string, st *ion.Symtab, body []byte) error {
+	switch name {
+	case "kind":
+		i, _, err := ion.ReadInt(body)
+		if err != nil {
+			return err
+		}
+		h.Kind = expr.JoinKind(i)
+	case "bind":
+		s, _, err := ion.ReadString(body)
+		if err != nil {
+			return err
+		}
+		h.Bind = s
+	case "probe":
+		return decodePath(&h.Probe, st, body)
+	case "key":
+		return decodePath(&h.Key, st, body)
+	case "build":
+		e, _, err := expr.Decode(st, body)
+		if err != nil {
+			return err
+		}
+		h.Build = e
+	}
+	return nil
+}
+
+func (h *HashJoin) String() string {
+	return fmt.Sprintf("%s %s AS %s ON %s = %s.%s", h.Kind, expr.ToString(h.Build), h.Bind, expr.ToString(h.Probe), h.Bind, expr.ToString(h.Key))
+}
+
+func (h *HashJoin) exec(dst vm.QuerySink, ep *execParams) error {
+	lst, ok := h.Build.(*expr.List)
+	if !ok {
+		return fmt.Errorf("hash join: unexpected build rows %s", expr.ToString(h.Build))
+	}
+	rows := make([]ion.Struct, 0, len(lst.Values))
+	for i := range lst.Values {
+		s, ok := lst.Values[i].Datum().Struct()
+		if !ok {
+			return fmt.Errorf("hash join: cannot join non-structure %s", expr.ToString(lst.Values[i]))
+		}
+		rows = append(rows, s)
+	}
+	hj, err := vm.NewHashJoin(dst, h.Kind, h.Probe, h.Key, h.Bind, rows)
+	if err != nil {
+		return err
+	}
+	return h.From.exec(hj, ep)
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"strings"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// JSON evaluates JSON_PARSE, JSON_EXTRACT,
+// and TO_JSON_STRING over the rows produced
+// by its input and binds each result to each row
+type JSON struct {
+	Nonterminal
+	Funcs []expr.Binding
+}
+
+func (j *JSON) rewrite(rw expr.Rewriter) {
+	j.From.rewrite(rw)
+	for i := range j.Funcs {
+		j.Funcs[i].Expr = expr.Rewrite(rw, j.Funcs[i].Expr)
+	}
+}
+
+func (j *JSON) String() string {
+	var out strings.Builder
+	out.WriteString("JSON ")
+	for i := range j.Funcs {
+		if i != 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(expr.ToString(&j.Funcs[i]))
+	}
+	return out.String()
+}
+
+func (j *JSON) exec(dst vm.QuerySink, ep *execParams) error {
+	js, err := vm.NewJSON(j.Funcs, dst)
+	if err != nil {
+		return err
+	}
+	return j.From.exec(js, ep)
+}
+
+func (j *JSON) encode(dst *ion.Buffer, st *ion.Symtab) error {
+	dst.BeginStruct(-1)
+	settype("json", dst, st)
+	dst.BeginField(st.Intern("funcs"))
+	expr.EncodeBindings(j.Funcs, dst, st)
+	dst.EndStruct()
+	return nil
+}
+
+func (j *JSON) setfield(d Decoder, name string, st *ion.Symtab, buf []byte) error {
+	switch name {
+	case "funcs":
+		bind, err := expr.DecodeBindings(st, buf)
+		if err != nil {
+			return err
+		}
+		j.Funcs = bind
+	}
+	return nil
+}
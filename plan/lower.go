@@ -60,9 +60,35 @@ func lowerIterValue(in *pir.IterValue, from Op) (Op, error) {
 }
 
 func lowerFilter(in *pir.Filter, from Op) (Op, error) {
-	return &Filter{
+	return lowerWhere(in.Where, from), nil
+}
+
+func lowerHashJoin(in *pir.HashJoin, from Op) (Op, error) {
+	probe, ok := in.Probe.(*expr.Path)
+	if !ok {
+		return nil, reject("join on non-path expression")
+	}
+	return &HashJoin{
+		Nonterminal: Nonterminal{From: from},
+		Kind:        in.Kind,
+		Bind:        in.Bind,
+		Probe:       probe,
+		Key:         in.Key,
+		Build:       in.Build,
+	}, nil
+}
+
+func lowerWindow(in *pir.Window, from Op) (Op, error) {
+	return &Window{
 		Nonterminal: Nonterminal{From: from},
-		Expr:        in.Where,
+		Funcs:       in.Funcs,
+	}, nil
+}
+
+func lowerJSON(in *pir.JSON, from Op) (Op, error) {
+	return &JSON{
+		Nonterminal: Nonterminal{From: from},
+		Funcs:       in.Funcs,
 	}, nil
 }
 
@@ -78,15 +104,34 @@ func lowerLimit(in *pir.Limit, from Op) (Op, error) {
 		return NoOutput{}, nil
 	}
 
-	// some operations accept Limit natively
+	// some operations accept Limit natively;
+	// the ones that do not accept an offset
+	// produce the rows up to the end of the
+	// limit, and the offset is applied
+	// by a Limit on top of them
+	//
+	// an offset is only meaningful if the
+	// rows are in a total order, since otherwise
+	// the rows that are skipped can differ
+	// from one execution to the next
 	switch f := from.(type) {
 	case *HashAggregate:
-		f.Limit = int(in.Count)
-		if in.Offset != 0 {
-			return nil, reject("non-zero OFFSET of hash aggregate result")
+		if in.Offset != 0 && !f.ordered() {
+			return nil, reject("non-zero OFFSET of hash aggregate result without ORDER BY on every grouping column")
+		}
+		f.Limit = int(in.Count + in.Offset)
+		if in.Offset == 0 {
+			return f, nil
 		}
-		return f, nil
 	case *OrderBy:
+		if in.Offset != 0 && !f.total() {
+			return nil, reject("non-zero OFFSET without ORDER BY on every output column")
+		}
+		if f.Spill {
+			// external sorts do not
+			// support a limit natively
+			break
+		}
 		f.Limit = int(in.Count)
 		f.Offset = int(in.Offset)
 		return f, nil
@@ -96,13 +141,15 @@ func lowerLimit(in *pir.Limit, from Op) (Op, error) {
 		}
 		f.Limit = in.Count
 		return f, nil
-	}
-	if in.Offset != 0 {
-		return nil, reject("OFFSET without GROUP BY/ORDER BY not implemented")
+	default:
+		if in.Offset != 0 {
+			return nil, reject("OFFSET without GROUP BY/ORDER BY not implemented")
+		}
 	}
 	return &Limit{
 		Nonterminal: Nonterminal{From: from},
 		Num:         in.Count,
+		Offset:      in.Offset,
 	}, nil
 }
 
@@ -139,12 +186,14 @@ func lowerAggregate(in *pir.Aggregate, from Op) (Op, error) {
 		Nonterminal: Nonterminal{From: from},
 		Agg:         in.Agg,
 		By:          in.GroupBy,
+		Spill:       in.Spill,
 	}, nil
 }
 
 func lowerOrder(in *pir.Order, from Op) (Op, error) {
-	if ha, ok := from.(*HashAggregate); ok {
+	if ha, ok := from.(*HashAggregate); ok && !in.Spill {
 		// hash aggregates can accept ORDER BY directly
+		// (unless the ordering requires an external sort)
 	outer:
 		for i := range in.Columns {
 			ex := in.Columns[i].Column
@@ -207,6 +256,7 @@ func lowerOrder(in *pir.Order, from Op) (Op, error) {
 	return &OrderBy{
 		Nonterminal: Nonterminal{From: from},
 		Columns:     columns,
+		Spill:       in.Spill,
 	}, nil
 }
 
@@ -248,6 +298,20 @@ func (w *walker) lowerUnionMap(in *pir.UnionMap) (Op, error) {
 	}, nil
 }
 
+func (w *walker) lowerUnionAll(in *pir.UnionAll) (Op, error) {
+	u := &UnionAll{Inputs: make([]Tree, len(in.Inputs))}
+	for i := range in.Inputs {
+		// each input has its own set
+		// of tables and replacements
+		t, err := toTree(in.Inputs[i], w.env, w.split)
+		if err != nil {
+			return nil, err
+		}
+		u.Inputs[i] = *t
+	}
+	return u, nil
+}
+
 // doSplit calls s.Split(tbl, th) with special handling
 // for tableHandles.
 func doSplit(s Splitter, tbl expr.Node, th TableHandle) (Subtables, error) {
@@ -408,10 +472,7 @@ func (w *walker) walkBuild(in pir.Step) (Op, error) {
 			Input: w.put(it),
 		})
 		if it.Filter != nil {
-			out = &Filter{
-				Nonterminal: Nonterminal{From: out},
-				Expr:        it.Filter,
-			}
+			out = lowerWhere(it.Filter, out)
 		}
 		return out, nil
 	}
@@ -427,6 +488,10 @@ func (w *walker) walkBuild(in pir.Step) (Op, error) {
 	if u, ok := in.(*pir.UnionMap); ok {
 		return w.lowerUnionMap(u)
 	}
+	// ... and UnionAll
+	if u, ok := in.(*pir.UnionAll); ok {
+		return w.lowerUnionAll(u)
+	}
 
 	input, err := w.walkBuild(pir.Input(in))
 	if err != nil {
@@ -437,6 +502,8 @@ func (w *walker) walkBuild(in pir.Step) (Op, error) {
 		return lowerIterValue(n, input)
 	case *pir.Filter:
 		return lowerFilter(n, input)
+	case *pir.HashJoin:
+		return lowerHashJoin(n, input)
 	case *pir.Distinct:
 		return lowerDistinct(n, input)
 	case *pir.Bind:
@@ -447,6 +514,10 @@ func (w *walker) walkBuild(in pir.Step) (Op, error) {
 		return lowerLimit(n, input)
 	case *pir.Order:
 		return lowerOrder(n, input)
+	case *pir.Window:
+		return lowerWindow(n, input)
+	case *pir.JSON:
+		return lowerJSON(n, input)
 	case *pir.OutputIndex:
 		return lowerOutputIndex(n, w.env, input)
 	case *pir.OutputPart:
@@ -551,6 +622,11 @@ func (e pirenv) Schema(tbl expr.Node) expr.Hint {
 	return s.Schema(tbl)
 }
 
+func (e pirenv) Spill() bool {
+	s, ok := e.env.(Spiller)
+	return ok && s.Spill()
+}
+
 func (e pirenv) Index(tbl expr.Node) (pir.Index, error) {
 	idx, ok := e.env.(Indexer)
 	if !ok {
@@ -39,12 +39,30 @@ func TestLoweringErrors(t *testing.T) {
 		},
 		{
 			query: `select x, count(*) from 'tbl' group by x limit 10 offset 15`,
-			msg:   `plan: query not supported: non-zero OFFSET of hash aggregate result`,
+			msg:   `plan: query not supported: non-zero OFFSET of hash aggregate result without ORDER BY on every grouping column`,
+		},
+		{
+			query: `select x, y, count(*) as c from 'tbl' group by x, y order by x limit 10 offset 15`,
+			msg:   `plan: query not supported: non-zero OFFSET of hash aggregate result without ORDER BY on every grouping column`,
 		},
 		{
 			query: `select distinct x from 'tbl' limit 5 offset 10`,
 			msg:   `plan: query not supported: non-zero OFFSET of distinct result`,
 		},
+		{
+			// x can have duplicates, so the rows
+			// skipped by the offset are not well-defined
+			query: `select x, y from 'tbl' order by x limit 10 offset 20`,
+			msg:   `plan: query not supported: non-zero OFFSET without ORDER BY on every output column`,
+		},
+		{
+			query: `select * from 'tbl' order by x limit 10 offset 20`,
+			msg:   `plan: query not supported: non-zero OFFSET without ORDER BY on every output column`,
+		},
+		{
+			query: `select x from 'tbl' limit 5 offset 10`,
+			msg:   `plan: query not supported: OFFSET without GROUP BY/ORDER BY not implemented`,
+		},
 	}
 
 	for i := range tcs {
@@ -187,7 +187,8 @@ func (f *flattener) Rewrite(e expr.Node) expr.Node {
 }
 
 func (f *flattener) Walk(e expr.Node) expr.Rewriter {
-	if _, ok := e.(*expr.Select); ok {
+	switch e.(type) {
+	case *expr.Select, *expr.Union:
 		// don't walk into sub-queries;
 		// otherwise we will end up breaking
 		// subquery correlation detection
@@ -63,7 +63,7 @@ func (b *Trace) walkFrom(f expr.From, e Env) error {
 		return errorf(f, "unexpected expression %q", f)
 	case *expr.Join:
 		if f.Kind != expr.CrossJoin {
-			return errorf(f, "join %q not yet supported", f.Kind)
+			return b.walkJoin(f, e)
 		}
 		err := b.walkFrom(f.Left, e)
 		if err != nil {
@@ -75,17 +75,103 @@ func (b *Trace) walkFrom(f expr.From, e Env) error {
 		return b.Iterate(&f.Right)
 	case *expr.Table:
 		if s, ok := f.Expr.(*expr.Select); ok {
-			b.walkSelect(s, e)
 			// TODO: if any subsequent expressions
 			// refer to a binding created by
 			//   FROM (SELECT ...) AS x,
 			// we should strip 'x.' from those
 			// bindings...
-		} else {
-			return b.Begin(f, e)
+			return b.walkSelect(s, e)
+		} else if u, ok := f.Expr.(*expr.Union); ok {
+			return b.walkUnion(u, e)
 		}
+		return b.Begin(f, e)
 	}
-	return nil
+}
+
+// walkJoin walks an explicit
+//   <left> [LEFT|RIGHT|FULL] JOIN <right> ON <a> = <b>
+// and produces a HashJoin step where the
+// right-hand-side is computed as a replacement
+func (b *Trace) walkJoin(f *expr.Join, e Env) error {
+	on, ok := f.On.(*expr.OnEquals)
+	if !ok {
+		return errorf(f, "%s requires an ON <left> = <right> condition", f.Kind)
+	}
+	kind := f.Kind
+	left, right := f.Left, f.Right
+	if kind == expr.RightJoin {
+		// <a> RIGHT JOIN <b> -> <b> LEFT JOIN <a>
+		lt, ok := left.(*expr.Table)
+		if !ok {
+			return errorf(f, "RIGHT JOIN of more than two tables is not supported")
+		}
+		left, right = &expr.Table{Binding: right}, lt.Binding
+		kind = expr.LeftJoin
+	}
+	bind := right.Result()
+	if bind == "" {
+		return errorf(right.Expr, "the right-hand-side of %s requires a binding", f.Kind)
+	}
+	probe, key := on.Left, on.Right
+	if !refersTo(key, bind) {
+		probe, key = key, probe
+	}
+	kp, ok := key.(*expr.Path)
+	if !ok || !refersTo(kp, bind) {
+		return errorf(f.On, "ON condition must compare a field of %s", bind)
+	}
+	d, ok := kp.Rest.(*expr.Dot)
+	if !ok {
+		return errorf(kp, "cannot compute %s on table %s", kp.Rest, bind)
+	}
+	pp, ok := probe.(*expr.Path)
+	if !ok || refersTo(pp, bind) {
+		return errorf(f.On, "ON condition must compare a field of %s with a field of the left-hand-side", bind)
+	}
+
+	// make the left-hand-side binding explicit
+	// so that references through the binding
+	// are resolved to the table; the binding is
+	// copied so that the query is not modified
+	if lt, ok := left.(*expr.Table); ok && !lt.Explicit() {
+		left = &expr.Table{Binding: expr.Bind(lt.Expr, lt.Result())}
+	}
+	err := b.walkFrom(left, e)
+	if err != nil {
+		return err
+	}
+	b.cur = b.top
+	expr.Walk(b, pp)
+	if b.err != nil {
+		return b.combine()
+	}
+
+	// the build side is simply every row
+	// of the right-hand-side; its size is
+	// capped in the same way as other replacements
+	sel := &expr.Select{
+		Columns: []expr.Binding{expr.Bind(expr.Star{}, "")},
+		From:    &expr.Table{Binding: right},
+	}
+	t, err := build(nil, sel, e)
+	if err != nil {
+		return err
+	}
+	id := len(b.Replacements)
+	b.Replacements = append(b.Replacements, t)
+	b.cur = &HashJoin{
+		Kind:  kind,
+		Bind:  bind,
+		Probe: pp,
+		Key:   &expr.Path{First: d.Field, Rest: d.Rest},
+		Build: expr.Call("LIST_REPLACEMENT", expr.Integer(id)),
+	}
+	return b.push()
+}
+
+func refersTo(e expr.Node, bind string) bool {
+	p, ok := e.(*expr.Path)
+	return ok && p.First == bind
 }
 
 // walk a list of bindings and determine if
@@ -136,6 +222,16 @@ type Env interface {
 	Index(expr.Node) (Index, error)
 }
 
+// Spiller may be implemented by an Env
+// to indicate that queries may spill their
+// intermediate results to disk rather than
+// being rejected for exceeding in-memory limits.
+type Spiller interface {
+	// Spill returns true if spilling
+	// to disk is permitted.
+	Spill() bool
+}
+
 type Index interface {
 	// TimeRange returns the inclusive time range
 	// for the given path expression across the
@@ -158,6 +254,12 @@ func Build(q *expr.Query, e Env) (*Trace, error) {
 			return nil, err
 		}
 	}
+	if u, ok := body.(*expr.Union); ok {
+		body, err = unionSelect(u)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if sel, ok := body.(*expr.Select); ok {
 		t, err := build(nil, sel, e)
 		if err != nil {
@@ -177,13 +279,15 @@ func Build(q *expr.Query, e Env) (*Trace, error) {
 			}
 			t.Into(p, path.Join("db", p.First, tbl.Field))
 		}
+		if s, ok := e.(Spiller); ok && s.Spill() {
+			allowSpill(t)
+		}
 		err = postcheck(t)
 		if err != nil {
 			return nil, err
 		}
 		return t, nil
 	}
-	// TODO: body can be UNION ALL, UNION, etc.
 	return nil, errorf(body, "cannot pir.Build %T", body)
 }
 
@@ -334,7 +438,8 @@ func (h *hoistwalk) Walk(e expr.Node) expr.Rewriter {
 	if b, ok := e.(*expr.Builtin); ok && b.Func == expr.InSubquery {
 		return nil
 	}
-	if _, ok := e.(*expr.Select); ok {
+	switch e.(type) {
+	case *expr.Select, *expr.Union:
 		return nil
 	}
 	return h
@@ -435,6 +540,14 @@ func (h *hoistwalk) Rewrite(e expr.Node) expr.Node {
 		is.Expr = h.rewriteScalarArg(is.Expr)
 		return is
 	}
+	if u, ok := e.(*expr.Union); ok {
+		sel, err := unionSelect(u)
+		if err != nil {
+			h.err = err
+			return e
+		}
+		e = sel
+	}
 	s, ok := e.(*expr.Select)
 	if !ok {
 		return e
@@ -516,6 +629,14 @@ func (h *hoistwalk) rewriteInSubquery(b *expr.Builtin) expr.Node {
 // an SFW expression on either side of a comparison
 // or arithmetic operation must be coerced to a scalar:
 func (h *hoistwalk) rewriteScalarArg(e expr.Node) expr.Node {
+	if u, ok := e.(*expr.Union); ok {
+		sel, err := unionSelect(u)
+		if err != nil {
+			h.err = err
+			return e
+		}
+		e = sel
+	}
 	s, ok := e.(*expr.Select)
 	if !ok {
 		return e
@@ -552,7 +673,7 @@ func (h *hoistwalk) rewriteScalarArg(e expr.Node) expr.Node {
 // hoist takes subqueries and hoists them
 // into b.Inputs
 func (b *Trace) hoist(e Env) error {
-	hw := &hoistwalk{env: e, parent: b}
+	hw := &hoistwalk{env: e, parent: b, in: b.Replacements}
 	for s := b.top; s != nil; s = s.parent() {
 		s.rewrite(func(e expr.Node, _ bool) expr.Node {
 			if hw.err != nil {
@@ -564,7 +685,7 @@ func (b *Trace) hoist(e Env) error {
 			return hw.err
 		}
 	}
-	b.Replacements = append(b.Replacements, hw.in...)
+	b.Replacements = hw.in
 	return nil
 }
 
@@ -620,17 +741,16 @@ func (w *windowHoist) Rewrite(e expr.Node) expr.Node {
 	// other aggregates, we can rewrite it to
 	// work more like a window function:
 	if agg.Op == expr.OpCountDistinct &&
+		agg.Over == nil &&
 		len(w.outer.GroupBy) == 1 &&
 		!hasOnlyOneAggregate(w.outer) {
 		agg.Over = &expr.Window{
 			PartitionBy: expr.BindingValues(w.outer.GroupBy),
 		}
 	}
-	if agg.Over == nil {
-		return e
-	}
-	if len(agg.Over.PartitionBy) != 1 {
-		w.err = errorf(agg, "only 1 PARTITION BY column supported (for now)")
+	if agg.Over == nil || !simpleWindow(agg) {
+		// general window functions are
+		// handled by walkWindows
 		return e
 	}
 	partition := agg.Over.PartitionBy[0]
@@ -690,12 +810,6 @@ func (w *windowHoist) Rewrite(e expr.Node) expr.Node {
 		expr.String("$__key"),
 		key, def)
 
-	// FIXME: this doesn't do anything yet
-	// because we don't have true window functions;
-	// other aggregates (COUNT, SUM, etc.) are insensitive
-	// to the input order:
-	// self.OrderBy = partition.OrderBy
-
 	t, err := build(w.trace, self, w.env)
 	if err != nil {
 		w.err = err
@@ -745,12 +859,24 @@ func (b *Trace) walkSelect(s *expr.Select, e Env) error {
 	if err != nil {
 		return err
 	}
+	if hasWindows(s) {
+		return b.walkWindows(s, e)
+	}
+	if hasJSON(s) {
+		return b.walkJSON(s, e)
+	}
 
 	err = b.walkFrom(s.From, e)
 	if err != nil {
 		return err
 	}
+	return b.walkBody(s, e)
+}
 
+// walkBody walks everything in s
+// that follows the FROM clause
+func (b *Trace) walkBody(s *expr.Select, e Env) error {
+	var err error
 	if s.Where != nil {
 		err = b.Where(s.Where)
 		if err != nil {
@@ -891,6 +1017,15 @@ func (b *Trace) walkSelect(s *expr.Select, e Env) error {
 	}
 
 	// finally, LIMIT
+	err = b.walkLimit(s)
+	if err != nil {
+		return err
+	}
+	return b.hoist(e)
+}
+
+// walkLimit handles the LIMIT and OFFSET clauses of s
+func (b *Trace) walkLimit(s *expr.Select) error {
 	if s.Limit != nil {
 		offset := int64(0)
 		if s.Offset != nil {
@@ -903,17 +1038,12 @@ func (b *Trace) walkSelect(s *expr.Select, e Env) error {
 		if limit < 0 {
 			return errorf(s, "negative limit %d not supported", limit)
 		}
-		err = b.LimitOffset(limit, offset)
-		if err != nil {
-			return err
-		}
-	} else {
-		if s.Offset != nil {
-			return errorf(s, "OFFSET without LIMIT is not supported")
-		}
+		return b.LimitOffset(limit, offset)
 	}
-
-	return b.hoist(e)
+	if s.Offset != nil {
+		return errorf(s, "OFFSET without LIMIT is not supported")
+	}
+	return nil
 }
 
 // isselectall checks if there's only a single '*' in select
@@ -134,6 +134,22 @@ func TestBuildError(t *testing.T) {
 			input: `select x, y from tbl group by sum(x) over (partition by y)`,
 			rx:    "window",
 		},
+		{
+			input: `SELECT * FROM foo f JOIN bar b ON f.x + 1 = b.y`,
+			rx:    "ON condition",
+		},
+		{
+			input: `SELECT * FROM foo f JOIN bar b ON f.x = f.y`,
+			rx:    "ON condition",
+		},
+		{
+			input: `SELECT x, y FROM foo UNION ALL SELECT a FROM bar`,
+			rx:    "2 and 1 columns",
+		},
+		{
+			input: `SELECT * FROM foo UNION SELECT * FROM bar`,
+			rx:    "explicit list of columns",
+		},
 		{
 			input: `SELECT x FROM table WHERE AVG(x) > 1.5`,
 			rx:    "aggregate functions are not allowed in WHERE",
@@ -158,6 +174,31 @@ func TestBuildError(t *testing.T) {
 			input: `SELECT DISTINCT x, y, sum(z) AS s, avg(w) AS a FROM table GROUP BY y, x`,
 			rx:    "set of DISTINCT expressions has to be equal to GROUP BY expressions",
 		},
+		{
+			// the input to a window function must be bounded
+			input: `SELECT x, ROW_NUMBER() OVER (PARTITION BY y ORDER BY z) AS rn FROM table`,
+			rx:    "bounded cardinality",
+		},
+		{
+			input: `SELECT x, EXISTS(y IN ys WHERE y > 0) AS e FROM table`,
+			rx:    "EXISTS over a list",
+		},
+		{
+			input: `SELECT x FROM table WHERE x = 0 OR 3 = ANY(ys)`,
+			rx:    "EXISTS over a list",
+		},
+		{
+			input: `SELECT x, RANK() AS r FROM table GROUP BY x`,
+			rx:    "requires an OVER clause",
+		},
+		{
+			input: `SELECT x, LAG(x, y) OVER (ORDER BY x) AS prev FROM table GROUP BY x`,
+			rx:    "LAG",
+		},
+		{
+			input: `SELECT SUM(ROW_NUMBER() OVER (ORDER BY x)) OVER () AS s FROM table GROUP BY x`,
+			rx:    "cannot be nested",
+		},
 	}
 	for i := range tests {
 		in := tests[i].input
@@ -187,6 +228,111 @@ func TestBuildError(t *testing.T) {
 	}
 }
 
+// walkJoin must not modify the bindings of the query
+func TestBuildJoinPreservesQuery(t *testing.T) {
+	query := `SELECT foo.x, b.y FROM foo JOIN bar AS b ON foo.id = b.id`
+	s, err := partiql.Parse([]byte(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := s.Body.(*expr.Select).From.(*expr.Join)
+	left := join.Left.(*expr.Table)
+	_, err = Build(s, mkenv(nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left.Explicit() {
+		t.Error("Build made the binding of the left-hand-side explicit")
+	}
+}
+
+type spillenv struct {
+	testenv
+}
+
+func (e *spillenv) Spill() bool { return true }
+
+func TestBuildSpill(t *testing.T) {
+	tests := []struct {
+		input string
+		spill bool
+	}{
+		{`select x, y, z from foo order by x`, true},
+		{`select * from tbl order by timestamp desc limit 10 offset 99999`, true},
+		{`select * from tbl order by timestamp desc limit 10`, false},
+		{`select count(*), x from foo group by x order by x`, false},
+	}
+	for i := range tests {
+		in := tests[i].input
+		spill := tests[i].spill
+		t.Run(fmt.Sprintf("case-%d", i), func(t *testing.T) {
+			s, err := partiql.Parse([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Build(s, &spillenv{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order *Order
+			for s := b.Final(); s != nil; s = Input(s) {
+				if o, ok := s.(*Order); ok {
+					order = o
+					break
+				}
+			}
+			if order == nil {
+				t.Fatal("no ORDER BY step")
+			}
+			if order.Spill != spill {
+				t.Errorf("got Spill=%v, want %v", order.Spill, spill)
+			}
+		})
+	}
+}
+
+func TestBuildAggregateSpill(t *testing.T) {
+	aggregates := func(b *Trace) []*Aggregate {
+		var out []*Aggregate
+		for s := b.Final(); s != nil; s = Input(s) {
+			if a, ok := s.(*Aggregate); ok {
+				out = append(out, a)
+			}
+		}
+		return out
+	}
+	const query = `select count(*), x from foo group by x order by count(*) desc limit 10`
+	for _, spill := range []bool{false, true} {
+		s, err := partiql.Parse([]byte(query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var env Env = &testenv{}
+		if spill {
+			env = &spillenv{}
+		}
+		b, err := Build(s, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reduce, err := Split(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// both the mapping and the reduction
+		// steps are permitted to spill
+		aggs := append(aggregates(b), aggregates(reduce)...)
+		if len(aggs) != 2 {
+			t.Fatalf("got %d aggregates, want 2", len(aggs))
+		}
+		for _, a := range aggs {
+			if a.Spill != spill {
+				t.Errorf("got Spill=%v, want %v", a.Spill, spill)
+			}
+		}
+	}
+}
+
 type testenv struct {
 	hint expr.Hint
 	idx  *blockfmt.Index
@@ -357,6 +503,63 @@ func TestBuild(t *testing.T) {
 			},
 			results: []expr.TypeSet{stringType, countType},
 		},
+		{
+			input:  "select x, approx_count_distinct(y) from foo group by x",
+			schema: mkschema("x", stringType),
+			expect: []string{
+				"ITERATE foo FIELDS [x, y]",
+				"AGGREGATE APPROX_COUNT_DISTINCT(y) AS \"count\" BY x AS x",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo FIELDS [x, y]",
+				"	AGGREGATE APPROX_COUNT_DISTINCT_PARTIAL(y) AS $_2_0 BY x AS x)",
+				"AGGREGATE APPROX_COUNT_DISTINCT_MERGE($_2_0) AS \"count\" BY x AS x",
+			},
+			results: []expr.TypeSet{stringType, countType},
+		},
+		{
+			input:  "select x, median(y) from foo group by x",
+			schema: mkschema("x", stringType),
+			expect: []string{
+				"ITERATE foo FIELDS [x, y]",
+				"AGGREGATE APPROX_PERCENTILE(y, 0.5) AS percentile BY x AS x",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo FIELDS [x, y]",
+				"	AGGREGATE APPROX_PERCENTILE_PARTIAL(y) AS $_2_0 BY x AS x)",
+				"AGGREGATE APPROX_PERCENTILE_MERGE($_2_0, 0.5) AS percentile BY x AS x",
+			},
+		},
+		{
+			input:  "select x, corr(y, z) from foo group by x",
+			schema: mkschema("x", stringType),
+			expect: []string{
+				"ITERATE foo FIELDS [x, y, z]",
+				"AGGREGATE CORR(y, z) AS \"corr\" BY x AS x",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo FIELDS [x, y, z]",
+				"	AGGREGATE MOMENTS_PARTIAL(y, z) AS $_2_0 BY x AS x)",
+				"AGGREGATE CORR_MERGE($_2_0) AS \"corr\" BY x AS x",
+			},
+		},
+		{
+			input:  "select x, array_agg(y order by z desc limit 3) from foo group by x",
+			schema: mkschema("x", stringType),
+			expect: []string{
+				"ITERATE foo FIELDS [x, y, z]",
+				"AGGREGATE ARRAY_AGG(y ORDER BY z DESC NULLS FIRST LIMIT 3) AS \"array_agg\" BY x AS x",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo FIELDS [x, y, z]",
+				"	AGGREGATE ARRAY_AGG_PARTIAL(y ORDER BY z DESC NULLS FIRST LIMIT 3) AS $_2_0 BY x AS x)",
+				"AGGREGATE ARRAY_AGG_MERGE($_2_0 LIMIT 3) AS \"array_agg\" BY x AS x",
+			},
+		},
 		{
 			input: `select avg(x), y from foo group by y`,
 			expect: []string{
@@ -389,6 +592,24 @@ func TestBuild(t *testing.T) {
 				"PROJECT $_0_0 AS x, $_0_1 AS y",
 			},
 		},
+		{
+			// references to the element variable
+			// are not references to fields
+			input: `select o.x from foo as o where exists(i in o.arr where i.y = 3 and o.z > i.w)`,
+			expect: []string{
+				"ITERATE foo AS o FIELDS [arr, x, z] WHERE EXISTS(i IN arr WHERE i.y = 3 AND z > i.w)",
+				"PROJECT x AS x",
+			},
+		},
+		{
+			// the element variable shadows a binding
+			// when the filter is pushed down
+			input: `select x from (select a as x, r from foo) where exists(x in r where x.a = 1)`,
+			expect: []string{
+				"ITERATE foo FIELDS [a, r] WHERE EXISTS(x IN r WHERE x.a = 1)",
+				"PROJECT a AS x",
+			},
+		},
 		{
 			// test that nested projection is flattened
 			input: `select x+2 as x, y - 3 as y from (select 3 + a as x, b - 5 as y from foo)`,
@@ -590,6 +811,42 @@ where out.Make = 'CHRY' and entry.BodyStyle = 'PA'`,
 				"AGGREGATE COUNT(*) AS \"count\"",
 			},
 		},
+		{
+			// the mapping step produces the rows
+			// up to the end of the limit, and
+			// the offset is applied once
+			input: `select x from foo where x < 3 limit 10 offset 5`,
+			expect: []string{
+				"ITERATE foo FIELDS [x] WHERE x < 3",
+				"LIMIT 10 OFFSET 5",
+				"PROJECT x AS x",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo FIELDS [x] WHERE x < 3",
+				"	LIMIT 15)",
+				"LIMIT 10 OFFSET 5",
+				"PROJECT x AS x",
+			},
+		},
+		{
+			input: `select x, y from t order by x limit 10 offset 20`,
+			expect: []string{
+				"ITERATE t FIELDS [x, y]",
+				"PROJECT x AS x, y AS y",
+				"ORDER BY x ASC NULLS FIRST",
+				"LIMIT 10 OFFSET 20",
+			},
+			split: []string{
+				"UNION MAP t (",
+				"	ITERATE PART t FIELDS [x, y]",
+				"	PROJECT x AS x, y AS y",
+				"	ORDER BY x ASC NULLS FIRST",
+				"	LIMIT 30)",
+				"ORDER BY x ASC NULLS FIRST",
+				"LIMIT 10 OFFSET 20",
+			},
+		},
 		{
 			input: `select x, y, z from t order by x LIMIT 9999`,
 			expect: []string{
@@ -1100,6 +1357,19 @@ ORDER BY m, d, h`,
 				"PROJECT SUBSTRING(str, 2, 2) AS x, HASH_REPLACEMENT(0, 'scalar', '$__key', SUBSTRING(str, 2, 2), NULL) AS ysum",
 			},
 		},
+		{
+			// general window functions are computed
+			// over the (bounded) result of the inner query
+			input: `SELECT x, y, RANK() OVER (PARTITION BY x, y ORDER BY COUNT(*) DESC) AS r FROM foo GROUP BY x, y, z LIMIT 10`,
+			expect: []string{
+				"ITERATE foo FIELDS [x, y, z]",
+				"AGGREGATE COUNT(*) AS $_0_2 BY x AS $_0_0, y AS $_0_1, z",
+				"PROJECT $_0_0 AS $_4_0, $_0_1 AS $_4_1, $_0_2 AS $_4_2",
+				"WINDOW RANK() OVER (PARTITION BY $_4_0, $_4_1 ORDER BY $_4_2 DESC NULLS FIRST) AS $_4_3",
+				"LIMIT 10",
+				"PROJECT $_4_0 AS x, $_4_1 AS y, $_4_3 AS r",
+			},
+		},
 		{
 			input: `SELECT (x + 1) AS y, (y + 1) AS z FROM table`,
 			expect: []string{
@@ -1144,6 +1414,156 @@ z = (SELECT a FROM bar LIMIT 1)`,
 				"ITERATE foo FIELDS * WHERE x = SCALAR_REPLACEMENT(0) AND y = SCALAR_REPLACEMENT(0) AND z = SCALAR_REPLACEMENT(0)",
 			},
 		},
+		{
+			// explicit equi-join: the right-hand-side
+			// is hoisted into a replacement
+			input: `SELECT f.x, b.y FROM foo AS f JOIN bar AS b ON f.id = b.id WHERE b.z > 0`,
+			expect: []string{
+				"WITH (",
+				"	ITERATE bar AS b FIELDS *",
+				") AS REPLACEMENT(0)",
+				"ITERATE foo AS f FIELDS [id, x]",
+				"JOIN LIST_REPLACEMENT(0) AS b ON id = b.id",
+				"FILTER b.z > 0",
+				"PROJECT x AS x, b.y AS y",
+			},
+			split: []string{
+				"WITH (",
+				"	UNION MAP bar AS b (",
+				"		ITERATE PART bar AS b FIELDS *)",
+				") AS REPLACEMENT(0)",
+				"UNION MAP foo AS f (",
+				"	ITERATE PART foo AS f FIELDS [id, x]",
+				"	JOIN LIST_REPLACEMENT(0) AS b ON id = b.id",
+				"	FILTER b.z > 0",
+				"	PROJECT x AS x, b.y AS y)",
+			},
+		},
+		{
+			// RIGHT JOIN is a LEFT JOIN with
+			// the two sides swapped; a FULL JOIN
+			// must be performed in the reduction step
+			input: `SELECT f.x, b.y FROM foo f RIGHT JOIN bar b ON b.id = f.id`,
+			expect: []string{
+				"WITH (",
+				"	ITERATE foo AS f FIELDS *",
+				") AS REPLACEMENT(0)",
+				"ITERATE bar AS b FIELDS [id, y]",
+				"LEFT JOIN LIST_REPLACEMENT(0) AS f ON id = f.id",
+				"PROJECT f.x AS x, y AS y",
+			},
+		},
+		{
+			input: `SELECT f.x, b.y FROM foo f FULL JOIN bar b ON f.id = b.id`,
+			expect: []string{
+				"WITH (",
+				"	ITERATE bar AS b FIELDS *",
+				") AS REPLACEMENT(0)",
+				"ITERATE foo AS f FIELDS [id, x]",
+				"FULL JOIN LIST_REPLACEMENT(0) AS b ON id = b.id",
+				"PROJECT x AS x, b.y AS y",
+			},
+			split: []string{
+				"WITH (",
+				"	UNION MAP bar AS b (",
+				"		ITERATE PART bar AS b FIELDS *)",
+				") AS REPLACEMENT(0)",
+				"UNION MAP foo AS f (",
+				"	ITERATE PART foo AS f FIELDS [id, x])",
+				"FULL JOIN LIST_REPLACEMENT(0) AS b ON id = b.id",
+				"PROJECT x AS x, b.y AS y",
+			},
+		},
+		{
+			// UNION ALL columns are named after
+			// the columns in the first query
+			input: `SELECT x, y FROM foo UNION ALL SELECT a, b FROM bar WHERE c > 0`,
+			expect: []string{
+				"UNION ALL (",
+				"	ITERATE foo FIELDS [x, y]",
+				"	PROJECT x AS x, y AS y)",
+				"UNION ALL (",
+				"	ITERATE bar FIELDS [a, b, c] WHERE c > 0",
+				"	PROJECT a AS x, b AS y)",
+			},
+			split: []string{
+				"UNION ALL (",
+				"	UNION MAP foo (",
+				"		ITERATE PART foo FIELDS [x, y]",
+				"		PROJECT x AS x, y AS y))",
+				"UNION ALL (",
+				"	UNION MAP bar (",
+				"		ITERATE PART bar FIELDS [a, b, c] WHERE c > 0",
+				"		PROJECT a AS x, b AS y))",
+			},
+		},
+		{
+			// aggregates of a UNION ALL are computed
+			// partially in the mapping step of each input
+			input: `SELECT COUNT(*), SUM(x) AS s FROM (SELECT x FROM foo UNION ALL SELECT a FROM bar) WHERE x > 0`,
+			expect: []string{
+				"UNION ALL (",
+				"	ITERATE foo FIELDS [x]",
+				"	PROJECT x AS x)",
+				"UNION ALL (",
+				"	ITERATE bar FIELDS [a]",
+				"	PROJECT a AS x)",
+				"FILTER x > 0",
+				"AGGREGATE COUNT(*) AS \"count\", SUM(x) AS s",
+			},
+			split: []string{
+				"UNION ALL (",
+				"	UNION MAP foo (",
+				"		ITERATE PART foo FIELDS [x]",
+				"		PROJECT x AS x",
+				"		FILTER x > 0",
+				"		AGGREGATE COUNT(*) AS $_2_0, SUM(x) AS $_2_1))",
+				"UNION ALL (",
+				"	UNION MAP bar (",
+				"		ITERATE PART bar FIELDS [a]",
+				"		PROJECT a AS x",
+				"		FILTER x > 0",
+				"		AGGREGATE COUNT(*) AS $_2_0, SUM(x) AS $_2_1))",
+				"AGGREGATE SUM_COUNT($_2_0) AS \"count\", SUM($_2_1) AS s",
+			},
+		},
+		{
+			// INTERSECT and EXCEPT are computed
+			// by grouping the tagged rows of each query
+			input: `SELECT x FROM foo INTERSECT SELECT a FROM bar`,
+			expect: []string{
+				"UNION ALL (",
+				"	ITERATE foo FIELDS [x]",
+				"	PROJECT x AS x, 1 AS $__arm)",
+				"UNION ALL (",
+				"	ITERATE bar FIELDS [a]",
+				"	PROJECT a AS x, 2 AS $__arm)",
+				"AGGREGATE BIT_OR($__arm) AS $__arm BY x AS x",
+				"FILTER $__arm = 3",
+				"PROJECT x AS x",
+			},
+		},
+		{
+			// JSON functions are evaluated in the
+			// mapping step after the JSON-free
+			// part of the WHERE clause
+			input: `SELECT id, JSON_EXTRACT(doc, '$.a') AS a FROM foo WHERE JSON_EXTRACT(doc, '$.b') = 1 AND id > 0`,
+			expect: []string{
+				"ITERATE foo FIELDS [doc, id] WHERE id > 0",
+				"PROJECT doc AS $_5_0, id AS $_5_2",
+				"JSON JSON_EXTRACT($_5_0, '$.b') AS $_5_1, JSON_EXTRACT($_5_0, '$.a') AS $_5_3",
+				"FILTER $_5_1 = 1",
+				"PROJECT $_5_2 AS id, $_5_3 AS a",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo FIELDS [doc, id] WHERE id > 0",
+				"	PROJECT doc AS $_5_0, id AS $_5_2",
+				"	JSON JSON_EXTRACT($_5_0, '$.b') AS $_5_1, JSON_EXTRACT($_5_0, '$.a') AS $_5_3",
+				"	FILTER $_5_1 = 1",
+				"	PROJECT $_5_2 AS id, $_5_3 AS a)",
+			},
+		},
 	}
 
 	for i := range tests {
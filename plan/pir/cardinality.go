@@ -123,6 +123,24 @@ func (b *Trace) Class() SizeClass {
 			}
 		case *Distinct:
 			next = SizeColumnCardinality
+		case *UnionAll:
+			next = SizeZero
+			for i := range step.Inputs {
+				c := step.Inputs[i].Class()
+				if c > SizeZero && next > SizeZero && c < SizeExactSmall {
+					// the sum of two exact sizes is still
+					// exact, but it is no longer one row
+					c = SizeExactSmall
+				}
+				if c > next {
+					next = c
+				}
+			}
+		case *HashJoin:
+			// a join can produce more rows
+			// than its input, so nothing below
+			// this step bounds the output
+			return cur
 		}
 		if next < cur {
 			cur = next
@@ -90,7 +90,7 @@ func (b *Trace) decorrelate() (k, v expr.Node, err error) {
 		// FIXME: we can't support list results
 		// unless we have a way to filter N
 		// distinct results for a given column
-		if li.Count > 1 {
+		if li.Count > 1 || li.Offset != 0 {
 			return nil, nil, decorrerr(v, x)
 		}
 		if b.top == s {
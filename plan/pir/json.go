@@ -0,0 +1,224 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pir
+
+import (
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/expr"
+)
+
+func isJSON(e expr.Node) bool {
+	b, ok := e.(*expr.Builtin)
+	if !ok {
+		return false
+	}
+	switch b.Func {
+	case expr.JSONParse, expr.JSONExtract, expr.ToJSONString:
+		return true
+	}
+	return false
+}
+
+// containsJSON returns whether e contains
+// a JSON function (not including sub-queries)
+func containsJSON(e expr.Node) bool {
+	found := false
+	visit := func(e expr.Node) bool {
+		if found {
+			return false
+		}
+		if _, ok := e.(*expr.Select); ok {
+			return false
+		}
+		if isJSON(e) {
+			found = true
+			return false
+		}
+		return true
+	}
+	expr.Walk(visitfn(visit), e)
+	return found
+}
+
+// hasJSON returns whether s contains
+// JSON functions that are not constant-folded
+func hasJSON(s *expr.Select) bool {
+	for i := range s.Columns {
+		if containsJSON(s.Columns[i].Expr) {
+			return true
+		}
+	}
+	for i := range s.GroupBy {
+		if containsJSON(s.GroupBy[i].Expr) {
+			return true
+		}
+	}
+	for i := range s.OrderBy {
+		if containsJSON(s.OrderBy[i].Column) {
+			return true
+		}
+	}
+	for i := range s.DistinctExpr {
+		if containsJSON(s.DistinctExpr[i]) {
+			return true
+		}
+	}
+	return (s.Where != nil && containsJSON(s.Where)) ||
+		(s.Having != nil && containsJSON(s.Having))
+}
+
+// jsonSplit splits a SELECT containing JSON
+// functions into an inner query that computes
+// every expression that the JSON functions
+// (and the rest of the query) depend upon
+// and an outer query that computes the results
+// from the inner expressions and the JSON
+// function results
+type jsonSplit struct {
+	splitter
+	// funcs is the list of JSON functions
+	funcs []expr.Binding
+	// orig holds the original form
+	// of each function in funcs
+	orig []expr.Node
+}
+
+func newJSONSplit() *jsonSplit {
+	j := &jsonSplit{}
+	j.splitter = splitter{
+		names:      make(map[string]bool),
+		node:       5,
+		isfunc:     isJSON,
+		replace:    func(e expr.Node) expr.Node { return j.call(e.(*expr.Builtin)) },
+		aggregates: true,
+	}
+	return j
+}
+
+// call replaces b with a reference to
+// the result of the JSON function
+func (j *jsonSplit) call(b *expr.Builtin) expr.Node {
+	for i := range j.orig {
+		if expr.Equivalent(j.orig[i], b) {
+			return expr.Identifier(j.funcs[i].Result())
+		}
+	}
+	orig := expr.Copy(b)
+	args := make([]expr.Node, len(b.Args))
+	for i := range b.Args {
+		arg := b.Args[i]
+		switch {
+		case isJSON(arg):
+			args[i] = j.call(arg.(*expr.Builtin))
+		case containsJSON(arg):
+			j.err = errorf(arg, "the argument to %s cannot contain a JSON function unless it is one", expr.ToString(b))
+			return b
+		default:
+			args[i] = j.temp(arg)
+		}
+	}
+	name := j.result()
+	j.orig = append(j.orig, orig)
+	j.funcs = append(j.funcs, expr.Bind(&expr.Builtin{Func: b.Func, Args: args}, name))
+	return expr.Identifier(name)
+}
+
+// walkJSON walks a SELECT that contains
+// JSON functions by splitting it into
+//
+//	SELECT <outer> FROM (
+//	  JSON <funcs> FROM (
+//	    SELECT <inner> FROM ... WHERE <inner-where>))
+//	WHERE <outer-where> GROUP BY ... HAVING ... ORDER BY ... LIMIT ...
+//
+// where <inner> contains every expression that
+// the JSON functions (and the rest of the query)
+// depend upon, and <inner-where> contains the
+// conjuncts of the WHERE clause that do not
+// depend upon any JSON functions
+func (b *Trace) walkJSON(s *expr.Select, e Env) error {
+	for i := range s.Columns {
+		if _, ok := s.Columns[i].Expr.(expr.Star); ok {
+			return errorf(s, "JSON functions cannot be used with '*'")
+		}
+	}
+	j := newJSONSplit()
+	outer := *s
+	outer.From = nil
+	outer.Where = nil
+	var where expr.Node
+	if s.Where != nil {
+		for _, c := range conjunctions(s.Where, nil) {
+			if !containsJSON(c) {
+				where = and(where, c)
+				continue
+			}
+			outer.Where = and(outer.Where, j.rewrite(c))
+		}
+	}
+	outer.GroupBy = slices.Clone(s.GroupBy)
+	for i := range outer.GroupBy {
+		outer.GroupBy[i].Expr = j.rewrite(outer.GroupBy[i].Expr)
+	}
+	// the outer query may refer to
+	// explicitly-named GROUP BY bindings
+	for i := range s.GroupBy {
+		if s.GroupBy[i].Explicit() {
+			j.names[s.GroupBy[i].Result()] = true
+		}
+	}
+	outer.Columns = make([]expr.Binding, len(s.Columns))
+	for i := range s.Columns {
+		outer.Columns[i] = expr.Bind(j.rewrite(s.Columns[i].Expr), s.Columns[i].Result())
+	}
+	outer.Having = j.rewrite(s.Having)
+	outer.DistinctExpr = slices.Clone(s.DistinctExpr)
+	for i := range outer.DistinctExpr {
+		outer.DistinctExpr[i] = j.rewrite(outer.DistinctExpr[i])
+	}
+	// ORDER BY may refer to output columns
+	for i := range s.Columns {
+		j.names[s.Columns[i].Result()] = true
+	}
+	outer.OrderBy = slices.Clone(s.OrderBy)
+	for i := range outer.OrderBy {
+		outer.OrderBy[i].Column = j.rewrite(outer.OrderBy[i].Column)
+	}
+	if j.err != nil {
+		return j.err
+	}
+	inner := &expr.Select{
+		Columns: j.columns(),
+		From:    s.From,
+		Where:   where,
+	}
+	err := b.walkSelect(inner, e)
+	if err != nil {
+		return err
+	}
+	err = b.JSON(j.funcs)
+	if err != nil {
+		return err
+	}
+	return b.walkBody(&outer, e)
+}
+
+func and(x, y expr.Node) expr.Node {
+	if x == nil {
+		return y
+	}
+	return expr.And(x, y)
+}
@@ -17,6 +17,8 @@ package pir
 import (
 	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/vm"
+
+	"golang.org/x/exp/slices"
 )
 
 // Split splits a query plan into a mapping
@@ -81,6 +83,22 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 			reduce.top = s
 			return false, nil
 		}
+		if u, ok := s.(*UnionAll); ok {
+			// each of the inputs is split
+			// independently, and the results
+			// are concatenated in the reduction step
+			for i := range u.Inputs {
+				in, err := Split(u.Inputs[i])
+				if err != nil {
+					return false, err
+				}
+				u.Inputs[i] = in
+			}
+			reduce.top = u
+			reduce.scope = mapping.scope
+			reduce.final = slices.Clone(mapping.final)
+			return false, nil
+		}
 		// must just be IterTable;
 		// this can always be split and
 		// assigned to the mapping step
@@ -94,6 +112,13 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 	// if we are already in the reduction step,
 	// just push the result there straight away
 	if !split {
+		// an aggregate of a UNION ALL can be
+		// computed partially in each of the inputs
+		if a, ok := s.(*Aggregate); ok {
+			if u, steps, ok := unionMapping(reduce.top); ok {
+				return false, reduceUnionAggregate(a, u, steps, reduce)
+			}
+		}
 		// if we have ORDER+LIMIT N, we can clone
 		// those nodes into the mapping step so
 		// that only the top/bottom N results
@@ -128,9 +153,17 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 	// reduction
 	switch n := s.(type) {
 	case *Limit:
-		// clone LIMIT so that we do it in both places
+		// clone LIMIT so that we do it in both places;
+		// 'LIMIT x OFFSET y' becomes 'LIMIT x+y'
+		// in the mapping step, since any of the
+		// mapping steps may produce the rows
+		// that precede the offset
 		mapping.top = n
 		l2 := n.clone()
+		if n.Offset != 0 {
+			n.Count += n.Offset
+			n.Offset = 0
+		}
 		l2.setparent(reduce.top)
 		reduce.top = l2
 		return false, nil
@@ -148,13 +181,24 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 		reduce.top = d2
 		// no longer in mapping step
 		return false, nil
-	case *Order:
+	case *Order, *Window:
 		mapping.top = par
 		n.setparent(reduce.top)
 		reduce.top = n
 		return false, nil
 	case *Aggregate:
 		return false, reduceAggregate(n, mapping, reduce)
+	case *HashJoin:
+		if n.Kind != expr.FullJoin {
+			return true, nil
+		}
+		// unmatched build rows can only be
+		// determined once all of the mapping
+		// steps have produced their output
+		mapping.top = par
+		n.setparent(reduce.top)
+		reduce.top = n
+		return false, nil
 	case *OutputIndex:
 		mapping.top = par
 		n.setparent(reduce.top)
@@ -167,6 +211,64 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 	}
 }
 
+// unionMapping determines if the steps from top
+// down to a UNION ALL can be performed in the mapping
+// step of each of the inputs of the UNION ALL;
+// it returns the UNION ALL and the steps
+// above it, starting from top
+func unionMapping(top Step) (*UnionAll, []Step, bool) {
+	var steps []Step
+	for {
+		switch s := top.(type) {
+		case *Filter, *Bind:
+			steps = append(steps, s)
+			top = s.parent()
+		case *UnionAll:
+			for i := range s.Inputs {
+				if _, ok := s.Inputs[i].top.(*UnionMap); !ok {
+					return nil, nil, false
+				}
+			}
+			return s, steps, true
+		default:
+			return nil, nil, false
+		}
+	}
+}
+
+// reduceUnionAggregate splits an aggregate of
+// a UNION ALL so that each of the inputs computes
+// the partial aggregate in its mapping step and
+// the reduction step merges the results of
+// all of the inputs
+func reduceUnionAggregate(a *Aggregate, u *UnionAll, steps []Step, reduce *Trace) error {
+	reduce.top = u
+	err := reduceAggregate(a, &Trace{}, reduce)
+	if err != nil {
+		return err
+	}
+	for i := range u.Inputs {
+		child := u.Inputs[i].top.(*UnionMap).Child
+		for j := len(steps) - 1; j >= 0; j-- {
+			var c Step
+			switch s := steps[j].(type) {
+			case *Filter:
+				f := *s
+				c = &f
+			case *Bind:
+				b := *s
+				c = &b
+			}
+			c.setparent(child.top)
+			child.top = c
+		}
+		ca := *a
+		ca.setparent(child.top)
+		child.top = &ca
+	}
+	return nil
+}
+
 // numberOrMissing takes the expression e
 // and produces an expression that evaluates
 // to MISSING if e is non-numeric
@@ -186,6 +288,18 @@ func numberOrMissing(e expr.Node) expr.Node {
 //    COUNT(x) AS count -> map: (COUNT(x) AS c)
 //      -> map:    COUNT(x) AS c
 //      -> reduce: SUM_INT(c) AS count
+//    APPROX_COUNT_DISTINCT(x) AS count
+//      -> map:    APPROX_COUNT_DISTINCT_PARTIAL(x) AS c
+//      -> reduce: APPROX_COUNT_DISTINCT_MERGE(c) AS count
+//    APPROX_PERCENTILE(x, p) AS pct
+//      -> map:    APPROX_PERCENTILE_PARTIAL(x) AS s
+//      -> reduce: APPROX_PERCENTILE_MERGE(s, p) AS pct
+//    CORR(x, y) AS corr
+//      -> map:    MOMENTS_PARTIAL(x, y) AS m
+//      -> reduce: CORR_MERGE(m) AS corr
+//    ARRAY_AGG(x ORDER BY y LIMIT n) AS lst
+//      -> map:    ARRAY_AGG_PARTIAL(x ORDER BY y LIMIT n) AS l
+//      -> reduce: ARRAY_AGG_MERGE(l LIMIT n) AS lst
 func reduceAggregate(a *Aggregate, mapping, reduce *Trace) error {
 	// transform AVG into two aggregations
 	orig := len(a.Agg)
@@ -242,6 +356,33 @@ func reduceAggregate(a *Aggregate, mapping, reduce *Trace) error {
 		case expr.OpSum, expr.OpMin, expr.OpMax, expr.OpSumInt, expr.OpSumCount, expr.OpBitAnd, expr.OpBitOr, expr.OpBitXor, expr.OpBoolAnd, expr.OpBoolOr, expr.OpEarliest, expr.OpLatest:
 			// these are all distributive
 			out = append(out, vm.AggBinding{Expr: &expr.Aggregate{Op: age.Op, Inner: innerref}, Result: result})
+		case expr.OpApproxCountDistinct:
+			// the mapping step produces the HyperLogLog
+			// registers, and the reduction step merges them
+			age.Op = expr.OpApproxCountDistinctPartial
+			out = append(out, vm.AggBinding{Expr: &expr.Aggregate{Op: expr.OpApproxCountDistinctMerge, Inner: innerref}, Result: result})
+		case expr.OpApproxPercentile:
+			// the mapping step produces the histogram
+			// sketch, and the reduction step merges the
+			// sketches and computes the percentile
+			out = append(out, vm.AggBinding{Expr: &expr.Aggregate{Op: expr.OpApproxPercentileMerge, Inner: innerref, Args: age.Args}, Result: result})
+			age.Op = expr.OpApproxPercentilePartial
+			age.Args = nil
+		case expr.OpVarPop, expr.OpVarSamp, expr.OpStddevPop, expr.OpStddevSamp, expr.OpCovarPop, expr.OpCorr:
+			// the mapping step produces the count, means,
+			// and (co-)moments, and the reduction step
+			// merges them and computes the final result
+			merge, _ := age.Op.MomentsMerge()
+			out = append(out, vm.AggBinding{Expr: &expr.Aggregate{Op: merge, Inner: innerref}, Result: result})
+			age.Op = expr.OpMomentsPartial
+		case expr.OpArrayAgg, expr.OpArrayAggDistinct:
+			// the mapping step produces the bounded lists
+			// along with their ordering keys, and the
+			// reduction step merges them (the keys
+			// already encode the ORDER BY clause)
+			partial, merge, _ := age.Op.ArrayAggSplit()
+			out = append(out, vm.AggBinding{Expr: &expr.Aggregate{Op: merge, Inner: innerref, Args: age.Args}, Result: result})
+			age.Op = partial
 		}
 	}
 	// the mapping step terminates here
@@ -252,7 +393,8 @@ func reduceAggregate(a *Aggregate, mapping, reduce *Trace) error {
 	// the old aggregate expression now point
 	// to the new reduction aggregate step
 	red := &Aggregate{
-		Agg: out,
+		Agg:   out,
+		Spill: a.Spill,
 	}
 	if a.GroupBy != nil {
 		// insert the set of identity bindings
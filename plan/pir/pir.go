@@ -231,6 +231,58 @@ func (i *IterValue) References() []*expr.Path {
 	return i.refs
 }
 
+// HashJoin is an equi-join of the rows
+// produced by its parent with the rows produced
+// by a replacement sub-query (the "build" side).
+//
+// Each output row is the parent row with
+// the matching build row bound to Bind.
+type HashJoin struct {
+	parented
+	// Kind is one of expr.InnerJoin,
+	// expr.LeftJoin, or expr.FullJoin
+	Kind expr.JoinKind
+	// Bind is the binding of the
+	// matching build row
+	Bind string
+	// Probe is the join key computed
+	// for each row produced by the parent
+	Probe expr.Node
+	// Key is the join key relative
+	// to each row of the build side
+	Key *expr.Path
+	// Build produces the list of rows
+	// in the build side of the join;
+	// it is LIST_REPLACEMENT(id) before
+	// replacements have been performed
+	Build expr.Node
+}
+
+func (h *HashJoin) equals(x Step) bool {
+	h2, ok := x.(*HashJoin)
+	return ok && (h == h2 || h.Kind == h2.Kind &&
+		h.Bind == h2.Bind &&
+		expr.Equal(h.Probe, h2.Probe) &&
+		h.Key.EqualsPath(h2.Key) &&
+		expr.Equal(h.Build, h2.Build))
+}
+
+func (h *HashJoin) describe(dst io.Writer) {
+	fmt.Fprintf(dst, "%s %s AS %s ON %s = %s.%s\n", h.Kind, expr.ToString(h.Build), h.Bind, expr.ToString(h.Probe), h.Bind, expr.ToString(h.Key))
+}
+
+func (h *HashJoin) rewrite(rw func(expr.Node, bool) expr.Node) {
+	h.Probe = rw(h.Probe, false)
+	h.Build = rw(h.Build, false)
+}
+
+func (h *HashJoin) get(x string) (Step, expr.Node) {
+	if x == h.Bind {
+		return h, nil
+	}
+	return h.par.get(x)
+}
+
 type parented struct {
 	par Step
 }
@@ -449,6 +501,10 @@ type Aggregate struct {
 	// note that the groups form part
 	// of the binding set
 	GroupBy []expr.Binding
+	// Spill is set if a grouped aggregation
+	// may write its groups to disk when there
+	// are too many of them to hold in memory
+	Spill bool
 
 	complete bool
 }
@@ -495,9 +551,87 @@ func (a *Aggregate) get(x string) (Step, expr.Node) {
 	return nil, nil
 }
 
+// Window computes window functions
+// over the rows produced by its parent;
+// each row is passed through with the
+// additional Funcs results bound
+type Window struct {
+	parented
+	Funcs vm.Aggregation
+}
+
+func (w *Window) equals(x Step) bool {
+	w2, ok := x.(*Window)
+	return ok && (w == w2 || w.Funcs.Equals(w2.Funcs))
+}
+
+func (w *Window) describe(dst io.Writer) {
+	fmt.Fprintf(dst, "WINDOW %s\n", w.Funcs)
+}
+
+func (w *Window) rewrite(rw func(expr.Node, bool) expr.Node) {
+	for i := range w.Funcs {
+		w.Funcs[i].Expr = rw(w.Funcs[i].Expr, false).(*expr.Aggregate)
+	}
+}
+
+func (w *Window) get(x string) (Step, expr.Node) {
+	for i := len(w.Funcs) - 1; i >= 0; i-- {
+		if w.Funcs[i].Result == x {
+			return w, w.Funcs[i].Expr
+		}
+	}
+	return w.par.get(x)
+}
+
+// JSON evaluates JSON_PARSE, JSON_EXTRACT,
+// and TO_JSON_STRING over the rows produced
+// by its parent; each row is passed through
+// with the additional Funcs results bound
+type JSON struct {
+	parented
+	Funcs []expr.Binding
+}
+
+func (j *JSON) equals(x Step) bool {
+	j2, ok := x.(*JSON)
+	return ok && (j == j2 ||
+		slices.EqualFunc(j.Funcs, j2.Funcs, expr.Binding.Equals))
+}
+
+func (j *JSON) describe(dst io.Writer) {
+	io.WriteString(dst, "JSON ")
+	for i := range j.Funcs {
+		if i != 0 {
+			io.WriteString(dst, ", ")
+		}
+		io.WriteString(dst, expr.ToString(&j.Funcs[i]))
+	}
+	io.WriteString(dst, "\n")
+}
+
+func (j *JSON) rewrite(rw func(expr.Node, bool) expr.Node) {
+	for i := range j.Funcs {
+		j.Funcs[i].Expr = rw(j.Funcs[i].Expr, false)
+	}
+}
+
+func (j *JSON) get(x string) (Step, expr.Node) {
+	for i := len(j.Funcs) - 1; i >= 0; i-- {
+		if j.Funcs[i].Result() == x {
+			return j, j.Funcs[i].Expr
+		}
+	}
+	return j.par.get(x)
+}
+
 type Order struct {
 	parented
 	Columns []expr.Order
+	// Spill is set if the ordering has to be
+	// performed with an external sort because
+	// the size of its input is not bounded
+	Spill bool
 }
 
 func (o *Order) equals(x Step) bool {
@@ -507,7 +641,7 @@ func (o *Order) equals(x Step) bool {
 }
 
 func (o *Order) clone() *Order {
-	return &Order{Columns: o.Columns}
+	return &Order{Columns: o.Columns, Spill: o.Spill}
 }
 
 func (o *Order) describe(dst io.Writer) {
@@ -870,6 +1004,57 @@ func (b *Trace) Aggregate(agg vm.Aggregation, groups []expr.Binding) error {
 	return b.push()
 }
 
+// Window pushes a window function
+// computation to the stack
+func (b *Trace) Window(funcs vm.Aggregation) error {
+	b.cur = b.top
+	for i := range funcs {
+		expr.Walk(b, funcs[i].Expr)
+		if b.err != nil {
+			return b.combine()
+		}
+	}
+	for i := range funcs {
+		if err := expr.CheckHint(funcs[i].Expr, b); err != nil {
+			return err
+		}
+	}
+	w := &Window{Funcs: funcs}
+	bind := slices.Clone(b.final)
+	for i := range funcs {
+		bind = append(bind, expr.Bind(funcs[i].Expr, funcs[i].Result))
+	}
+	b.final = bind
+	b.cur = w
+	return b.push()
+}
+
+// JSON pushes the evaluation of JSON
+// functions to the stack
+func (b *Trace) JSON(funcs []expr.Binding) error {
+	j := &JSON{Funcs: funcs}
+	// functions may refer to the results
+	// of the preceding functions, so resolve
+	// references starting from j itself
+	j.setparent(b.top)
+	b.cur = j
+	for i := range funcs {
+		expr.Walk(b, funcs[i].Expr)
+		if b.err != nil {
+			return b.combine()
+		}
+	}
+	for i := range funcs {
+		if err := b.Check(funcs[i].Expr); err != nil {
+			return err
+		}
+	}
+	bind := slices.Clone(b.final)
+	bind = append(bind, funcs...)
+	b.final = bind
+	return b.push()
+}
+
 // Order pushes an ordering to the stack
 func (b *Trace) Order(cols []expr.Order) error {
 	// ... now the variable references should be correct
@@ -20,6 +20,7 @@ import (
 
 var rules = []func(t *Trace) error{
 	checkSortSize,
+	checkExists,
 }
 
 func checkAggregateWorkInProgress(e expr.Node) error {
@@ -83,10 +84,105 @@ func checkNoAggregateInCondition(e expr.Node, context string) error {
 	return err
 }
 
+func checkNoExists(e expr.Node) error {
+	var err error
+	v := visitfn(func(e expr.Node) bool {
+		if err != nil {
+			return false
+		}
+		if _, ok := e.(*expr.Exists); ok {
+			err = errorf(e, "EXISTS over a list is only supported as a top-level AND condition in WHERE")
+			return false
+		}
+		return true
+	})
+	expr.Walk(v, e)
+	return err
+}
+
+// checkExists checks that EXISTS over a list only
+// appears as a (possibly negated) conjunction of
+// a WHERE clause, since it is evaluated by a
+// dedicated filtering step
+func checkExists(t *Trace) error {
+	var err error
+	for s := t.top; s != nil && err == nil; s = s.parent() {
+		var where expr.Node
+		switch s := s.(type) {
+		case *Filter:
+			where = s.Where
+		case *IterTable:
+			where = s.Filter
+		}
+		s.rewrite(func(e expr.Node, _ bool) expr.Node {
+			if err == nil && e != nil && e != where {
+				err = checkNoExists(e)
+			}
+			return e
+		})
+		if where == nil || err != nil {
+			continue
+		}
+		for _, c := range conjunctions(where, nil) {
+			if n, ok := c.(*expr.Not); ok {
+				c = n.Expr
+			}
+			ex, ok := c.(*expr.Exists)
+			if !ok {
+				err = checkNoExists(c)
+			} else if err = checkNoExists(ex.List); err == nil {
+				err = checkNoExists(ex.Where)
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	for i := range t.Replacements {
+		if err != nil {
+			break
+		}
+		err = checkExists(t.Replacements[i])
+	}
+	return err
+}
+
+// allowSpill marks the final ORDER BY in t
+// for an external sort if its size would
+// otherwise be rejected by checkSortSize,
+// and marks every GROUP BY so that it can
+// spill its groups to disk
+func allowSpill(t *Trace) {
+	allowAggregateSpill(t)
+	f, ok := t.Final().(*Order)
+	if ok {
+		f.Spill = !t.Class().Small()
+		return
+	}
+	l, ok := t.Final().(*Limit)
+	if !ok {
+		return
+	}
+	if p, ok := Input(l).(*Order); ok {
+		p.Spill = l.Count+l.Offset > LargeSize
+	}
+}
+
+func allowAggregateSpill(t *Trace) {
+	for s := t.top; s != nil; s = s.parent() {
+		if a, ok := s.(*Aggregate); ok && a.GroupBy != nil {
+			a.Spill = true
+		}
+	}
+	for i := range t.Replacements {
+		allowAggregateSpill(t.Replacements[i])
+	}
+}
+
 func checkSortSize(t *Trace) error {
 	f, ok := t.Final().(*Order)
 	if ok {
-		if c := t.Class(); !c.Small() {
+		if c := t.Class(); !c.Small() && !f.Spill {
 			return errorf(f.Columns[0].Column, "cannot perform ORDER BY with very large or unlimited cardinality")
 		}
 		return nil
@@ -96,7 +192,7 @@ func checkSortSize(t *Trace) error {
 		return nil
 	}
 	p, ok := Input(l).(*Order)
-	if !ok {
+	if !ok || p.Spill {
 		return nil
 	}
 	pos := l.Count + l.Offset
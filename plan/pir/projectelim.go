@@ -125,7 +125,21 @@ func (b *bindflattener) Rewrite(e expr.Node) expr.Node {
 }
 
 func (b *bindflattener) Walk(e expr.Node) expr.Rewriter {
-	return b
+	ex, ok := e.(*expr.Exists)
+	if !ok {
+		return b
+	}
+	// the element variable shadows
+	// any binding with the same name
+	ex.List = expr.Rewrite(b, ex.List)
+	inner := &bindflattener{}
+	for i := range b.from {
+		if b.from[i].Result() != ex.Var {
+			inner.from = append(inner.from, b.from[i])
+		}
+	}
+	ex.Where = expr.Rewrite(inner, ex.Where)
+	return nil
 }
 
 // simple projection push-down:
@@ -88,6 +88,10 @@ func (b *Trace) Visit(e expr.Node) expr.Visitor {
 	if _, ok := e.(*expr.Select); ok {
 		return nil
 	}
+	if ex, ok := e.(*expr.Exists); ok {
+		visitExists(b, ex)
+		return nil
+	}
 	if p, ok := e.(*expr.Path); ok {
 		if b.origin(p) != nil {
 			return nil
@@ -138,3 +142,35 @@ func (b *Trace) Visit(e expr.Node) expr.Visitor {
 	}
 	return b
 }
+
+// existsVisitor visits the WHERE clause of
+// an EXISTS expression; references to the
+// element variable are local to the expression,
+// and everything else is resolved by the parent
+type existsVisitor struct {
+	parent expr.Visitor
+	bind   string
+}
+
+func visitExists(v expr.Visitor, e *expr.Exists) {
+	expr.Walk(v, e.List)
+	expr.Walk(&existsVisitor{parent: v, bind: e.Var}, e.Where)
+}
+
+func (e *existsVisitor) Visit(n expr.Node) expr.Visitor {
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case *expr.Exists:
+		visitExists(e, n)
+		return nil
+	case *expr.Path:
+		if n.First == e.bind {
+			return nil
+		}
+	}
+	if e.parent.Visit(n) == nil {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pir
+
+import (
+	"github.com/SnellerInc/sneller/expr"
+)
+
+// splitter is an expr.Rewriter that splits
+// expressions between an inner query, which
+// computes temporaries, and an outer query,
+// which computes the results of a set of
+// functions from the temporaries and then
+// everything that depends on those results
+//
+// see jsonSplit and windowSplit
+type splitter struct {
+	// inner is the list of temporaries
+	// computed by the inner query
+	inner []expr.Binding
+	// names is the set of names that are
+	// bound in the outer query
+	names map[string]bool
+	// node is the gensym node number
+	node int
+	n    int
+	err  error
+
+	// isfunc returns whether e is one of
+	// the functions that are computed
+	// between the inner and outer query
+	isfunc func(e expr.Node) bool
+	// replace replaces a function for which
+	// isfunc returns true with a reference
+	// to its result
+	replace func(e expr.Node) expr.Node
+	// aggregates indicates that aggregates
+	// are computed by the outer query
+	aggregates bool
+}
+
+func (s *splitter) gensym() string {
+	name := gensym(s.node, s.n)
+	s.n++
+	return name
+}
+
+// outer returns whether e has to be computed
+// in the outer query, i.e. whether it references
+// one of the split functions or a name
+// bound in the outer query
+func (s *splitter) outer(e expr.Node) bool {
+	found := false
+	visit := func(e expr.Node) bool {
+		if found {
+			return false
+		}
+		switch e := e.(type) {
+		case *expr.Select:
+			return false
+		case *expr.Aggregate:
+			if s.aggregates {
+				found = true
+				return false
+			}
+		case *expr.Path:
+			found = s.names[e.First]
+			return false
+		}
+		if s.isfunc(e) {
+			found = true
+			return false
+		}
+		return true
+	}
+	expr.Walk(visitfn(visit), e)
+	return found
+}
+
+// temp returns a reference to the result
+// of computing e in the inner query
+func (s *splitter) temp(e expr.Node) expr.Node {
+	switch e.(type) {
+	case expr.Constant, expr.Missing:
+		return e
+	}
+	for i := range s.inner {
+		if expr.Equivalent(s.inner[i].Expr, e) {
+			return expr.Identifier(s.inner[i].Result())
+		}
+	}
+	name := s.gensym()
+	s.inner = append(s.inner, expr.Bind(e, name))
+	return expr.Identifier(name)
+}
+
+// result binds a new name in the outer query
+// to the result of a function
+func (s *splitter) result() string {
+	name := s.gensym()
+	s.names[name] = true
+	return name
+}
+
+// columns returns the columns of the inner query
+func (s *splitter) columns() []expr.Binding {
+	if len(s.inner) == 0 {
+		// we need at least one column
+		// in order to produce rows
+		s.inner = append(s.inner, expr.Bind(expr.Bool(true), s.gensym()))
+	}
+	return s.inner
+}
+
+func (s *splitter) Walk(e expr.Node) expr.Rewriter {
+	if s.err != nil || s.isfunc(e) || !s.outer(e) {
+		return nil
+	}
+	return s
+}
+
+func (s *splitter) Rewrite(e expr.Node) expr.Node {
+	if s.err != nil {
+		return e
+	}
+	if s.isfunc(e) {
+		return s.replace(e)
+	}
+	if _, ok := e.(expr.Star); ok || s.outer(e) {
+		return e
+	}
+	return s.temp(e)
+}
+
+// rewrite splits e, returning the expression
+// that the outer query computes
+func (s *splitter) rewrite(e expr.Node) expr.Node {
+	if e == nil {
+		return nil
+	}
+	return expr.Rewrite(s, e)
+}
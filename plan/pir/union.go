@@ -0,0 +1,285 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pir
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/SnellerInc/sneller/expr"
+
+	"golang.org/x/exp/slices"
+)
+
+// UnionAll represents a terminal
+// query Step that concatenates the
+// results of each of the Inputs.
+//
+// The other set operations (UNION, INTERSECT, EXCEPT)
+// are built from UnionAll plus DISTINCT or
+// grouping operations.
+type UnionAll struct {
+	// Inputs are the sub-queries
+	// whose results are concatenated.
+	// The output bindings of every
+	// input have the same names.
+	Inputs []*Trace
+}
+
+func (u *UnionAll) parent() Step   { return nil }
+func (u *UnionAll) setparent(Step) { panic("cannot UnionAll.setparent()") }
+
+func (u *UnionAll) equals(x Step) bool {
+	u2, ok := x.(*UnionAll)
+	return ok && (u == u2 || slices.EqualFunc(u.Inputs, u2.Inputs, (*Trace).Equals))
+}
+
+func (u *UnionAll) get(x string) (Step, expr.Node) {
+	// we don't know the result expression,
+	// since it is different in each input
+	return u, nil
+}
+
+func (u *UnionAll) describe(dst io.Writer) {
+	var buf bytes.Buffer
+	for i := range u.Inputs {
+		buf.Reset()
+		u.Inputs[i].Describe(&buf)
+		inner := buf.Bytes()
+		if inner[len(inner)-1] == '\n' {
+			inner = inner[:len(inner)-1]
+		}
+		inner = bytes.Replace(inner, []byte{'\n'}, []byte{'\n', '\t'}, -1)
+		io.WriteString(dst, "UNION ALL (\n\t")
+		dst.Write(inner)
+		io.WriteString(dst, ")\n")
+	}
+}
+
+func (u *UnionAll) rewrite(func(expr.Node, bool) expr.Node) {}
+
+// setTag is the name of the column used
+// to tag the rows produced by each arm
+// of an INTERSECT or EXCEPT operation
+const setTag = "$__arm"
+
+func identity(names []string) []expr.Binding {
+	out := make([]expr.Binding, len(names))
+	for i := range names {
+		out[i] = expr.Bind(expr.Identifier(names[i]), names[i])
+	}
+	return out
+}
+
+func selectStar(from expr.Node) *expr.Select {
+	return &expr.Select{
+		Columns: []expr.Binding{expr.Bind(expr.Star{}, "")},
+		From:    &expr.Table{Binding: expr.Bind(from, "")},
+	}
+}
+
+// armSelect produces the SELECT that
+// computes one arm of a set operation
+func armSelect(arm expr.Node) (*expr.Select, error) {
+	switch a := arm.(type) {
+	case *expr.Select:
+		return a, nil
+	case *expr.Union:
+		if a.Type == expr.UnionAll {
+			return selectStar(a), nil
+		}
+		return dedupSelect(a)
+	default:
+		return nil, errorf(arm, "unexpected set operation operand %s", expr.ToString(arm))
+	}
+}
+
+// columnNames returns the output names of s,
+// or nil if they cannot be determined
+// (because s uses '*')
+func columnNames(s *expr.Select) []string {
+	if isselectall(s) {
+		if t, ok := s.From.(*expr.Table); ok && s.Where == nil {
+			if u, ok := t.Expr.(*expr.Union); ok {
+				// SELECT * FROM (x UNION ALL y ...)
+				sel, err := armSelect(u.Arms()[0])
+				if err == nil {
+					return columnNames(sel)
+				}
+			}
+		}
+		return nil
+	}
+	for i := range s.Columns {
+		if _, ok := s.Columns[i].Expr.(expr.Star); ok {
+			return nil
+		}
+	}
+	pickOutputs(s)
+	names := make([]string, len(s.Columns))
+	for i := range s.Columns {
+		names[i] = s.Columns[i].Result()
+	}
+	return names
+}
+
+// setArms produces the SELECT for each
+// arm of a set operation such that the
+// columns in each arm are named positionally
+// after the columns of the first arm
+//
+// the returned list of names is nil if the
+// first arm does not have an explicit list of columns
+func setArms(u *expr.Union) ([]*expr.Select, []string, error) {
+	arms := u.Arms()
+	out := make([]*expr.Select, len(arms))
+	var names []string
+	for i := range arms {
+		sel, err := armSelect(arms[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		out[i] = sel
+		cols := columnNames(sel)
+		if i == 0 {
+			names = cols
+			continue
+		}
+		if names == nil || cols == nil {
+			if (names == nil) != (cols == nil) {
+				return nil, nil, errorf(u, "%s of queries with and without '*'", u.Type)
+			}
+			continue
+		}
+		if len(cols) != len(names) {
+			return nil, nil, errorf(u, "%s of queries with %d and %d columns", u.Type, len(names), len(cols))
+		}
+		if isselectall(sel) {
+			// rename the columns of the wrapped query
+			sel.Columns = make([]expr.Binding, len(cols))
+			for j := range cols {
+				sel.Columns[j] = expr.Bind(expr.Identifier(cols[j]), "")
+			}
+		}
+		for j := range sel.Columns {
+			sel.Columns[j].As(names[j])
+		}
+	}
+	return out, names, nil
+}
+
+// concat produces
+//   arms[0] UNION ALL arms[1] UNION ALL ...
+func concat(arms []*expr.Select) *expr.Union {
+	u := &expr.Union{Type: expr.UnionAll, Left: arms[0], Right: arms[1]}
+	for _, arm := range arms[2:] {
+		u = &expr.Union{Type: expr.UnionAll, Left: u, Right: arm}
+	}
+	return u
+}
+
+// dedupSelect produces a SELECT that computes
+// UNION, INTERSECT, or EXCEPT in terms of UNION ALL:
+//
+//   x UNION y
+//     -> SELECT DISTINCT cols FROM (x UNION ALL y)
+//   x INTERSECT y
+//     -> SELECT cols FROM (
+//          SELECT cols, BIT_OR(tag) AS tag
+//          FROM (SELECT cols, 1 AS tag FROM (x) UNION ALL SELECT cols, 2 AS tag FROM (y))
+//          GROUP BY cols)
+//        WHERE tag = 3
+//   x EXCEPT y
+//     -> (same as above, but) WHERE tag = 1
+func dedupSelect(u *expr.Union) (*expr.Select, error) {
+	arms, names, err := setArms(u)
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		return nil, errorf(u, "%s requires an explicit list of columns", u.Type)
+	}
+	if u.Type == expr.UnionDistinct {
+		return &expr.Select{
+			Distinct: true,
+			Columns:  identity(names),
+			From:     &expr.Table{Binding: expr.Bind(concat(arms), "")},
+		}, nil
+	}
+	if len(arms) > 62 {
+		return nil, errorf(u, "%s of %d queries is not supported", u.Type, len(arms))
+	}
+	tagged := make([]*expr.Select, len(arms))
+	for i := range arms {
+		cols := append(identity(names), expr.Bind(expr.Integer(1<<i), setTag))
+		tagged[i] = &expr.Select{
+			Columns: cols,
+			From:    &expr.Table{Binding: expr.Bind(arms[i], "")},
+		}
+	}
+	want := int64(1)
+	if u.Type == expr.Intersect {
+		want = (1 << len(arms)) - 1
+	}
+	agg := &expr.Select{
+		Columns: append(identity(names), expr.Bind(expr.AggregateOr(expr.Identifier(setTag)), setTag)),
+		From:    &expr.Table{Binding: expr.Bind(concat(tagged), "")},
+		GroupBy: identity(names),
+	}
+	return &expr.Select{
+		Columns: identity(names),
+		From:    &expr.Table{Binding: expr.Bind(agg, "")},
+		Where:   expr.Compare(expr.Equals, expr.Identifier(setTag), expr.Integer(want)),
+	}, nil
+}
+
+// unionSelect converts a set operation
+// into an equivalent SELECT so that it
+// can be used as a sub-query
+func unionSelect(u *expr.Union) (*expr.Select, error) {
+	if u.Type == expr.UnionAll {
+		return selectStar(u), nil
+	}
+	return dedupSelect(u)
+}
+
+// walkUnion walks a set operation
+// that appears in FROM position
+func (b *Trace) walkUnion(u *expr.Union, e Env) error {
+	if u.Type != expr.UnionAll {
+		sel, err := dedupSelect(u)
+		if err != nil {
+			return err
+		}
+		return b.walkSelect(sel, e)
+	}
+	arms, names, err := setArms(u)
+	if err != nil {
+		return err
+	}
+	ua := &UnionAll{}
+	for i := range arms {
+		t, err := build(nil, arms[i], e)
+		if err != nil {
+			return err
+		}
+		ua.Inputs = append(ua.Inputs, t)
+	}
+	b.top = ua
+	if names != nil {
+		b.final = identity(names)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pir
+
+import (
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// simpleWindow returns whether agg is an
+// aggregate with an OVER clause that can be
+// computed by windowHoist as a correlated
+// sub-query, i.e.
+//   AGG(x) OVER (PARTITION BY y)
+func simpleWindow(agg *expr.Aggregate) bool {
+	return !agg.Op.WindowOnly() &&
+		len(agg.Over.PartitionBy) == 1 &&
+		len(agg.Over.OrderBy) == 0
+}
+
+func isWindow(e expr.Node) bool {
+	agg, ok := e.(*expr.Aggregate)
+	return ok && agg.Over != nil
+}
+
+// containsWindow returns whether e contains
+// a window function (not including sub-queries)
+func containsWindow(e expr.Node) bool {
+	found := false
+	visit := func(e expr.Node) bool {
+		if found {
+			return false
+		}
+		if _, ok := e.(*expr.Select); ok {
+			return false
+		}
+		if isWindow(e) {
+			found = true
+			return false
+		}
+		return true
+	}
+	expr.Walk(visitfn(visit), e)
+	return found
+}
+
+// hasWindows returns whether s contains
+// window functions that were not handled by
+// hoistWindows
+func hasWindows(s *expr.Select) bool {
+	for i := range s.Columns {
+		if containsWindow(s.Columns[i].Expr) {
+			return true
+		}
+	}
+	for i := range s.OrderBy {
+		if containsWindow(s.OrderBy[i].Column) {
+			return true
+		}
+	}
+	return false
+}
+
+// windowSplit splits a SELECT containing window
+// functions into an inner query that computes
+// every expression that the window functions
+// depend upon and an outer projection that
+// computes the results from the inner
+// expressions and the window function results
+type windowSplit struct {
+	splitter
+	// funcs is the list of window functions
+	funcs vm.Aggregation
+	// orig holds the original form
+	// of each window function in funcs
+	orig []*expr.Aggregate
+}
+
+func newWindowSplit() *windowSplit {
+	w := &windowSplit{}
+	w.splitter = splitter{
+		names:   make(map[string]bool),
+		node:    4,
+		isfunc:  isWindow,
+		replace: func(e expr.Node) expr.Node { return w.window(e.(*expr.Aggregate)) },
+	}
+	return w
+}
+
+// arg returns a reference to the result
+// of computing the window function
+// argument e in the inner query
+func (w *windowSplit) arg(e expr.Node) expr.Node {
+	if containsWindow(e) {
+		w.err = errorf(e, "window functions cannot be nested")
+		return e
+	}
+	return w.temp(e)
+}
+
+// window replaces agg with a reference
+// to the result of the window function
+func (w *windowSplit) window(agg *expr.Aggregate) expr.Node {
+	for i := range w.orig {
+		if w.orig[i].Equals(agg) {
+			return expr.Identifier(w.funcs[i].Result)
+		}
+	}
+	w.orig = append(w.orig, expr.Copy(agg).(*expr.Aggregate))
+	if agg.Op.WindowOnly() && agg.Filter != nil {
+		w.err = errorf(agg, "%s does not accept a FILTER clause", agg.Op)
+		return agg
+	}
+	if _, ok := agg.Inner.(expr.Star); !ok {
+		agg.Inner = w.arg(agg.Inner)
+	}
+	for i := range agg.Args {
+		agg.Args[i] = w.arg(agg.Args[i])
+	}
+	if agg.Filter != nil {
+		agg.Filter = w.arg(agg.Filter)
+	}
+	over := agg.Over
+	for i := range over.PartitionBy {
+		over.PartitionBy[i] = w.arg(over.PartitionBy[i])
+	}
+	for i := range over.OrderBy {
+		over.OrderBy[i].Column = w.arg(over.OrderBy[i].Column)
+	}
+	name := w.result()
+	w.funcs = append(w.funcs, vm.AggBinding{Expr: agg, Result: name})
+	return expr.Identifier(name)
+}
+
+// walkWindows walks a SELECT that contains
+// window functions by splitting it into
+//
+//   SELECT <outer> FROM (
+//     WINDOW <funcs> FROM (
+//       SELECT <inner> FROM ... WHERE ... GROUP BY ... HAVING ...))
+//   ORDER BY ... LIMIT ...
+//
+// where <inner> contains every expression
+// that the window functions (and the rest of
+// the output columns) depend upon
+//
+// the input to the window functions must
+// be small, since all of the rows have to
+// be buffered in order to compute the results
+func (b *Trace) walkWindows(s *expr.Select, e Env) error {
+	if s.DistinctExpr != nil {
+		return errorf(s, "window functions cannot be used with DISTINCT ON")
+	}
+	for i := range s.Columns {
+		if _, ok := s.Columns[i].Expr.(expr.Star); ok {
+			return errorf(s, "window functions cannot be used with '*'")
+		}
+	}
+	w := newWindowSplit()
+	cols := make([]expr.Binding, len(s.Columns))
+	for i := range s.Columns {
+		cols[i] = expr.Bind(w.rewrite(s.Columns[i].Expr), s.Columns[i].Result())
+	}
+	// ORDER BY expressions that are not
+	// output columns are computed as
+	// auxiliary columns
+	var aux []expr.Binding
+	order := make([]expr.Order, len(s.OrderBy))
+	for i := range s.OrderBy {
+		order[i] = s.OrderBy[i]
+		if p, ok := order[i].Column.(*expr.Path); ok && p.Rest == nil &&
+			bindingIndex(cols, p.First) >= 0 {
+			continue
+		}
+		if s.Distinct {
+			return errorf(order[i].Column, "ORDER BY expression must appear in the SELECT DISTINCT list")
+		}
+		name := w.gensym()
+		aux = append(aux, expr.Bind(w.rewrite(order[i].Column), name))
+		order[i].Column = expr.Identifier(name)
+	}
+	if w.err != nil {
+		return w.err
+	}
+	inner := &expr.Select{
+		Columns: w.columns(),
+		From:    s.From,
+		Where:   s.Where,
+		GroupBy: s.GroupBy,
+		Having:  s.Having,
+	}
+	err := b.walkSelect(inner, e)
+	if err != nil {
+		return err
+	}
+	if !b.Class().Small() {
+		return errorf(s, "window functions require an input with bounded cardinality (try GROUP BY or LIMIT)")
+	}
+	err = b.Window(w.funcs)
+	if err != nil {
+		return err
+	}
+	if s.Distinct {
+		err = b.DistinctFromBindings(cols)
+		if err != nil {
+			return err
+		}
+	}
+	err = b.Bind(cols, aux)
+	if err != nil {
+		return err
+	}
+	if len(order) > 0 {
+		err = b.Order(order)
+		if err != nil {
+			return err
+		}
+		if len(aux) > 0 {
+			err = b.Bind(identityBindings(cols))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = b.walkLimit(s)
+	if err != nil {
+		return err
+	}
+	return b.hoist(e)
+}
+
+func bindingIndex(lst []expr.Binding, name string) int {
+	for i := range lst {
+		if lst[i].Result() == name {
+			return i
+		}
+	}
+	return -1
+}
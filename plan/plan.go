@@ -191,6 +191,17 @@ type Indexer interface {
 	Index(expr.Node) (Index, error)
 }
 
+// Spiller may optionally be implemented by Env to
+// permit queries to spill intermediate results to disk.
+// An ORDER BY with an unbounded number of rows is
+// only accepted if the Env permits spilling, in which
+// case it is executed using an external sort.
+type Spiller interface {
+	// Spill returns true if queries
+	// may spill to disk.
+	Spill() bool
+}
+
 // An Index may be returned by Indexer.Index to provide
 // additional table metadata that may be used during
 // optimization.
@@ -482,14 +493,21 @@ func (n DummyOutput) setfield(d Decoder, name string, st *ion.Symtab, buf []byte
 
 type Limit struct {
 	Nonterminal
-	Num int64
+	Num    int64
+	Offset int64
 }
 
 func (l *Limit) String() string {
+	if l.Offset > 0 {
+		return fmt.Sprintf("LIMIT %d OFFSET %d", l.Num, l.Offset)
+	}
 	return fmt.Sprintf("LIMIT %d", l.Num)
 }
 
 func (l *Limit) exec(dst vm.QuerySink, ep *execParams) error {
+	if l.Offset > 0 {
+		return l.From.exec(vm.NewLimitOffset(l.Num, l.Offset, dst), ep)
+	}
 	return l.From.exec(vm.NewLimit(l.Num, dst), ep)
 }
 
@@ -498,6 +516,10 @@ func (l *Limit) encode(dst *ion.Buffer, st *ion.Symtab) error {
 	settype("limit", dst, st)
 	dst.BeginField(st.Intern("limit"))
 	dst.WriteInt(l.Num)
+	if l.Offset > 0 {
+		dst.BeginField(st.Intern("offset"))
+		dst.WriteInt(l.Offset)
+	}
 	dst.EndStruct()
 	return nil
 }
@@ -510,6 +532,12 @@ func (l *Limit) setfield(d Decoder, name string, st *ion.Symtab, buf []byte) err
 			return err
 		}
 		l.Num = i
+	case "offset":
+		i, _, err := ion.ReadInt(buf)
+		if err != nil {
+			return err
+		}
+		l.Offset = i
 	}
 	return nil
 }
@@ -585,12 +613,21 @@ func (c *CountStar) setfield(d Decoder, name string, st *ion.Symtab, buf []byte)
 	return nil
 }
 
+// aggSpillSize is the number of bytes of groups
+// that each thread of a HashAggregate with Spill
+// set holds in memory before it writes them to disk
+const aggSpillSize = 64 * 1024 * 1024
+
 type HashAggregate struct {
 	Nonterminal
 	Agg     vm.Aggregation
 	By      vm.Selection
 	Limit   int
 	OrderBy []HashOrder
+	// Spill indicates that groups may
+	// be written to disk when there are
+	// too many to hold in memory
+	Spill bool
 }
 
 func (h *HashAggregate) rewrite(rw expr.Rewriter) {
@@ -609,6 +646,22 @@ type HashOrder struct {
 	NullsLast bool
 }
 
+// ordered returns whether the output of h
+// is in a total order, i.e. whether every
+// grouping column is part of the ORDER BY
+func (h *HashAggregate) ordered() bool {
+outer:
+	for i := range h.By {
+		for j := range h.OrderBy {
+			if h.OrderBy[j].Column == len(h.Agg)+i {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
 func (h *HashAggregate) String() string {
 	s := fmt.Sprintf("HASH AGGREGATE %s GROUP BY %s", h.Agg, h.By)
 	if h.OrderBy != nil {
@@ -628,6 +681,9 @@ func (h *HashAggregate) String() string {
 	if h.Limit > 0 {
 		s += fmt.Sprintf(" LIMIT %d", h.Limit)
 	}
+	if h.Spill {
+		s += " (spill)"
+	}
 	return s
 }
 
@@ -654,6 +710,10 @@ func (h *HashAggregate) encode(dst *ion.Buffer, st *ion.Symtab) error {
 		}
 		dst.EndList()
 	}
+	if h.Spill {
+		dst.BeginField(st.Intern("spill"))
+		dst.WriteBool(true)
+	}
 	dst.EndStruct()
 	return nil
 }
@@ -711,6 +771,12 @@ func (h *HashAggregate) setfield(d Decoder, name string, st *ion.Symtab, buf []b
 			h.OrderBy = append(h.OrderBy, o)
 			return nil
 		})
+	case "spill":
+		b, _, err := ion.ReadBool(buf)
+		if err != nil {
+			return fmt.Errorf("reading \"spill\": %w", err)
+		}
+		h.Spill = b
 	}
 	return nil
 }
@@ -723,6 +789,9 @@ func (h *HashAggregate) exec(dst vm.QuerySink, ep *execParams) error {
 	if h.Limit > 0 {
 		ha.Limit(h.Limit)
 	}
+	if h.Spill {
+		ha.Spill(ep.TempDir, aggSpillSize)
+	}
 	for i := range h.OrderBy {
 		col := h.OrderBy[i].Column
 		if col < len(h.Agg) {
@@ -742,12 +811,21 @@ type OrderByColumn struct {
 	NullsLast bool
 }
 
+// sortSpillSize is the number of bytes of rows
+// that an OrderBy with Spill set holds in memory
+// before it begins writing them to disk
+const sortSpillSize = 256 * 1024 * 1024
+
 // OrderBy implements ORDER BY clause (without GROUP BY).
 type OrderBy struct {
 	Nonterminal
 	Columns []OrderByColumn
 	Limit   int
 	Offset  int
+	// Spill indicates that the ordering
+	// is performed with an external sort
+	// (Limit and Offset are not supported)
+	Spill bool
 }
 
 func (o *OrderBy) rewrite(rw expr.Rewriter) {
@@ -785,9 +863,61 @@ func (o *OrderBy) String() string {
 		s += fmt.Sprintf(" OFFSET %d", o.Offset)
 	}
 
+	if o.Spill {
+		s += " (spill)"
+	}
+
 	return s
 }
 
+// total returns whether the rows of o are
+// in a total order, i.e. whether every column
+// that identifies an input row (every output
+// column, grouping column, or distinct column)
+// is one of the ORDER BY columns
+func (o *OrderBy) total() bool {
+	for op := o.From; op != nil; op = op.input() {
+		switch n := op.(type) {
+		case *Filter, *Limit, *OrderBy, *UnionMap:
+			continue
+		case *Project:
+			return o.binds(n.Using)
+		case *HashAggregate:
+			return o.binds(n.By)
+		case *Distinct:
+			for i := range n.Fields {
+				if !o.has(n.Fields[i], "") {
+					return false
+				}
+			}
+			return true
+		default:
+			// e.g. SELECT *
+			return false
+		}
+	}
+	return false
+}
+
+func (o *OrderBy) binds(lst []expr.Binding) bool {
+	for i := range lst {
+		if !o.has(lst[i].Expr, lst[i].Result()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *OrderBy) has(e expr.Node, name string) bool {
+	for i := range o.Columns {
+		col := o.Columns[i].Node
+		if expr.Equivalent(col, e) || (name != "" && expr.IsIdentifier(col, name)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (o *OrderBy) exec(dst vm.QuerySink, ep *execParams) error {
 	writer, err := dst.Open()
 	if err != nil {
@@ -825,7 +955,12 @@ func (o *OrderBy) exec(dst vm.QuerySink, ep *execParams) error {
 		}
 	}
 
-	sorter := vm.NewOrder(writer, orderBy, limit, ep.Parallel)
+	var sorter *vm.Order
+	if o.Spill {
+		sorter = vm.NewSpillingOrder(writer, orderBy, ep.TempDir, sortSpillSize, ep.Parallel)
+	} else {
+		sorter = vm.NewOrder(writer, orderBy, limit, ep.Parallel)
+	}
 	err = o.From.exec(sorter, ep)
 	err2 := writer.Close()
 	if err == nil {
@@ -857,6 +992,10 @@ func (o *OrderBy) encode(dst *ion.Buffer, st *ion.Symtab) error {
 		dst.BeginField(st.Intern("offset"))
 		dst.WriteInt(int64(o.Offset))
 	}
+	if o.Spill {
+		dst.BeginField(st.Intern("spill"))
+		dst.WriteBool(true)
+	}
 
 	dst.EndStruct()
 	return nil
@@ -899,6 +1038,12 @@ func (o *OrderBy) setfield(d Decoder, name string, st *ion.Symtab, buf []byte) e
 			return err
 		}
 		o.Offset = int(i)
+	case "spill":
+		b, _, err := ion.ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		o.Spill = b
 	}
 	return nil
 }
@@ -989,7 +1134,7 @@ func encoderec(p Op, dst *ion.Buffer, st *ion.Symtab, rw TableRewrite) error {
 		return p.encode(dst, st)
 	}
 	// nodes without parents:
-	// should just be Leaf or NoOutput
+	// should just be Leaf, NoOutput, etc.
 	if l, ok := p.(*Leaf); ok {
 		return l.encode(dst, st)
 	}
@@ -999,6 +1144,9 @@ func encoderec(p Op, dst *ion.Buffer, st *ion.Symtab, rw TableRewrite) error {
 	if one, ok := p.(DummyOutput); ok {
 		return one.encode(dst, st)
 	}
+	if u, ok := p.(*UnionAll); ok {
+		return u.encodePart(dst, st, rw)
+	}
 	return fmt.Errorf("cannot encode %T", p)
 }
 
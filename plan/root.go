@@ -43,6 +43,10 @@ type ExecParams struct {
 	// of the query. Transports are expected to
 	// stop processing queries after Context is canceled.
 	Context context.Context
+	// TempDir is the directory in which operations
+	// that spill to disk create temporary files.
+	// If TempDir is empty, os.TempDir() is used instead.
+	TempDir string
 }
 
 type execParams struct {
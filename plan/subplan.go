@@ -279,6 +279,14 @@ func (s *subreplacement) Write(buf []byte) (int, error) {
 	return orig, nil
 }
 
+// EndSegment implements vm.EndSegmentWriter.EndSegment
+//
+// A replacement may be the direct output of a table
+// (as with the build side of a join), and the
+// rows are copied out of each buffer as they are
+// written, so there is nothing to release here.
+func (s *subreplacement) EndSegment() {}
+
 func (s *subreplacement) Close() error {
 	return nil
 }
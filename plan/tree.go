@@ -195,6 +195,7 @@ func (n *Node) exec(dst vm.QuerySink, ep *execParams) error {
 					Parallel: subp,
 					Rewrite:  ep.Rewrite,
 					Context:  ep.Context,
+					TempDir:  ep.TempDir,
 				},
 				inputs: n.Inputs,
 			}
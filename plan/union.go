@@ -0,0 +1,111 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// UnionAll is a leaf op that
+// concatenates the results of
+// executing each of its Inputs.
+type UnionAll struct {
+	Inputs []Tree
+}
+
+func (u *UnionAll) rewrite(rw expr.Rewriter) {}
+func (u *UnionAll) input() Op                { return nil }
+func (u *UnionAll) setinput(o Op) {
+	panic("UnionAll: cannot setinput()")
+}
+
+func (u *UnionAll) String() string {
+	var out strings.Builder
+	out.WriteString("UNION ALL")
+	for i := range u.Inputs {
+		inner := strings.TrimSuffix(u.Inputs[i].String(), "\n")
+		out.WriteString(" (\n\t")
+		out.WriteString(strings.Replace(inner, "\n", "\n\t", -1))
+		out.WriteString(")")
+	}
+	return out.String()
+}
+
+// unionSink is a QuerySink that ignores
+// calls to Close so that multiple inputs
+// can be written to the same QuerySink
+type unionSink struct {
+	vm.QuerySink
+}
+
+func (u unionSink) Close() error { return nil }
+
+func (u *UnionAll) exec(dst vm.QuerySink, ep *execParams) error {
+	var err error
+	for i := range u.Inputs {
+		sub := &execParams{
+			ExecParams: ep.ExecParams,
+			inputs:     u.Inputs[i].Inputs,
+		}
+		err = u.Inputs[i].Root.exec(unionSink{dst}, sub)
+		if err != nil {
+			break
+		}
+	}
+	err2 := dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (u *UnionAll) encode(dst *ion.Buffer, st *ion.Symtab) error {
+	return u.encodePart(dst, st, nil)
+}
+
+func (u *UnionAll) encodePart(dst *ion.Buffer, st *ion.Symtab, rw TableRewrite) error {
+	dst.BeginStruct(-1)
+	settype("unionall", dst, st)
+	dst.BeginField(st.Intern("inputs"))
+	dst.BeginList(-1)
+	for i := range u.Inputs {
+		if err := u.Inputs[i].EncodePart(dst, st, rw); err != nil {
+			return err
+		}
+	}
+	dst.EndList()
+	dst.EndStruct()
+	return nil
+}
+
+func (u *UnionAll) setfield(d Decoder, name string, st *ion.Symtab, body []byte) error {
+	switch name {
+	case "inputs":
+		return unpackList(body, func(field []byte) error {
+			t, err := Decode(d, st, field)
+			if err != nil {
+				return fmt.Errorf("decoding UNION ALL input: %w", err)
+			}
+			u.Inputs = append(u.Inputs, *t)
+			return nil
+		})
+	}
+	return nil
+}
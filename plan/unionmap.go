@@ -178,6 +178,7 @@ func (u *UnionMap) exec(dst vm.QuerySink, ep *execParams) error {
 				Rewrite:  rw,
 				Parallel: ep.Parallel, // ...meaningful?
 				Context:  ep.Context,
+				TempDir:  ep.TempDir,
 			}
 			// wrap the rest of the query in a Tree;
 			// this makes it look to the Transport
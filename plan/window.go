@@ -0,0 +1,69 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// Window computes window functions
+// over the rows produced by its input
+// and binds each result to each row
+type Window struct {
+	Nonterminal
+	Funcs vm.Aggregation
+}
+
+func (w *Window) rewrite(rw expr.Rewriter) {
+	w.From.rewrite(rw)
+	for i := range w.Funcs {
+		agg := w.Funcs[i].Expr
+		agg.Inner = expr.Rewrite(rw, agg.Inner)
+		for j := range agg.Args {
+			agg.Args[j] = expr.Rewrite(rw, agg.Args[j])
+		}
+	}
+}
+
+func (w *Window) String() string {
+	return "WINDOW " + w.Funcs.String()
+}
+
+func (w *Window) exec(dst vm.QuerySink, ep *execParams) error {
+	win, err := vm.NewWindow(w.Funcs, dst)
+	if err != nil {
+		return err
+	}
+	return w.From.exec(win, ep)
+}
+
+func (w *Window) encode(dst *ion.Buffer, st *ion.Symtab) error {
+	dst.BeginStruct(-1)
+	settype("window", dst, st)
+	dst.BeginField(st.Intern("funcs"))
+	encodeAggregation(w.Funcs, dst, st)
+	dst.EndStruct()
+	return nil
+}
+
+func (w *Window) setfield(d Decoder, name string, st *ion.Symtab, buf []byte) error {
+	switch name {
+	case "funcs":
+		return decodeAggregation(&w.Funcs, st, buf)
+	}
+	return nil
+}
@@ -0,0 +1,439 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package regexp2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DsByte is a data structure for a DFA that consumes
+// one byte at a time. Unlike the other DFA data
+// structures, it is used to locate matches (see Matcher)
+// rather than to determine whether a string matches.
+//
+// The layout of the data is
+//
+//	[0:4]     entry of the start state at the start of the text
+//	[4:8]     entry of the start state elsewhere
+//	[8:12]    flags (DsByteEarliest)
+//	[12:16]   unused
+//	[16:272]  byte -> equivalence class
+//	[272:]    rows of transitions
+//
+// Each row contains one uint32 entry per equivalence class.
+// An entry is the offset of the row of the next state
+// (relative to the first row) or'd with DsByteAccept and
+// DsByteAcceptAtEnd. The first row belongs to the dead state,
+// so an entry of zero means that no match is possible.
+type DsByte struct {
+	data []byte
+}
+
+const (
+	// DsByteAccept is set in the entries of accepting states
+	DsByteAccept = 1
+	// DsByteAcceptAtEnd is set in the entries of
+	// states that accept at the end of the text
+	DsByteAcceptAtEnd = 2
+	// DsByteEarliest is set in the flags of a DFA
+	// that stops at the first accepting state
+	DsByteEarliest = 1
+	// DsByteHeaderSize is the offset of the first row
+	DsByteHeaderSize = 272
+)
+
+// byteNode is a node in the byte-level NFA
+//
+// a node either consumes a byte in [lo, hi]
+// and continues with next, or it continues with
+// each of eps (if its empty-width condition holds)
+type byteNode struct {
+	consume bool
+	lo, hi  byte
+	next    int
+	eps     []int
+	cond    syntax.EmptyOp
+	match   bool
+}
+
+type byteNFA struct {
+	nodes []byteNode
+	start int
+}
+
+func (n *byteNFA) add(node byteNode) int {
+	n.nodes = append(n.nodes, node)
+	return len(n.nodes) - 1
+}
+
+// runeRanges returns the sorted ranges of runes
+// matched by a rune instruction
+func runeRanges(i *syntax.Inst) [][2]rune {
+	switch i.Op {
+	case syntax.InstRuneAny:
+		return [][2]rune{{0, unicode.MaxRune}}
+	case syntax.InstRuneAnyNotNL:
+		return [][2]rune{{0, '\n' - 1}, {'\n' + 1, unicode.MaxRune}}
+	}
+	if len(i.Rune) == 1 {
+		r0 := i.Rune[0]
+		out := [][2]rune{{r0, r0}}
+		if syntax.Flags(i.Arg)&syntax.FoldCase != 0 {
+			for r := unicode.SimpleFold(r0); r != r0; r = unicode.SimpleFold(r) {
+				out = append(out, [2]rune{r, r})
+			}
+		}
+		return out
+	}
+	var out [][2]rune
+	for j := 0; j+1 < len(i.Rune); j += 2 {
+		out = append(out, [2]rune{i.Rune[j], i.Rune[j+1]})
+	}
+	return out
+}
+
+// utf8Sequences calls fn with each of the sequences
+// of byte ranges that together match exactly the
+// UTF-8 encodings of the runes in [lo, hi]
+func utf8Sequences(lo, hi rune, fn func(seq [][2]byte)) {
+	if lo > hi {
+		return
+	}
+	// surrogates cannot be encoded
+	if lo < 0xd800 && hi >= 0xd800 {
+		utf8Sequences(lo, 0xd7ff, fn)
+		utf8Sequences(0xe000, hi, fn)
+		return
+	}
+	if lo >= 0xd800 && lo <= 0xdfff {
+		utf8Sequences(0xe000, hi, fn)
+		return
+	}
+	// split on changes in the encoded length
+	for _, max := range []rune{0x7f, 0x7ff, 0xffff} {
+		if lo <= max && hi > max {
+			utf8Sequences(lo, max, fn)
+			utf8Sequences(max+1, hi, fn)
+			return
+		}
+	}
+	if hi < 0x80 {
+		fn([][2]byte{{byte(lo), byte(hi)}})
+		return
+	}
+	// split until each continuation byte
+	// spans its complete range
+	n := utf8.RuneLen(lo)
+	for i := 1; i < n; i++ {
+		m := rune(1)<<(6*i) - 1
+		if lo&^m != hi&^m {
+			if lo&m != 0 {
+				utf8Sequences(lo, lo|m, fn)
+				utf8Sequences((lo|m)+1, hi, fn)
+				return
+			}
+			if hi&m != m {
+				utf8Sequences(lo, (hi&^m)-1, fn)
+				utf8Sequences(hi&^m, hi, fn)
+				return
+			}
+		}
+	}
+	var a, b [utf8.UTFMax]byte
+	utf8.EncodeRune(a[:], lo)
+	utf8.EncodeRune(b[:], hi)
+	seq := make([][2]byte, n)
+	for i := range seq {
+		seq[i] = [2]byte{a[i], b[i]}
+	}
+	fn(seq)
+}
+
+// newByteNFA translates a compiled program
+// into an NFA that consumes bytes
+func newByteNFA(prog *syntax.Prog) (*byteNFA, error) {
+	n := &byteNFA{
+		nodes: make([]byteNode, len(prog.Inst)),
+		start: prog.Start,
+	}
+	for pc := range prog.Inst {
+		i := &prog.Inst[pc]
+		switch i.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			n.nodes[pc].eps = []int{int(i.Out), int(i.Arg)}
+		case syntax.InstCapture, syntax.InstNop:
+			n.nodes[pc].eps = []int{int(i.Out)}
+		case syntax.InstEmptyWidth:
+			cond := syntax.EmptyOp(i.Arg)
+			if cond&^(syntax.EmptyBeginText|syntax.EmptyEndText) != 0 {
+				return nil, fmt.Errorf("word boundaries and multi-line anchors are not supported")
+			}
+			n.nodes[pc].eps = []int{int(i.Out)}
+			n.nodes[pc].cond = cond
+		case syntax.InstMatch:
+			n.nodes[pc].match = true
+		case syntax.InstFail:
+			// no successors
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			var eps []int
+			for _, r := range runeRanges(i) {
+				utf8Sequences(r[0], r[1], func(seq [][2]byte) {
+					next := int(i.Out)
+					for j := len(seq) - 1; j >= 0; j-- {
+						next = n.add(byteNode{consume: true, lo: seq[j][0], hi: seq[j][1], next: next})
+					}
+					eps = append(eps, next)
+				})
+				if r[0] <= utf8.RuneError && utf8.RuneError <= r[1] {
+					// bytes that cannot start a UTF-8
+					// sequence are matched as RuneError
+					for _, b := range [][2]byte{{0x80, 0xc1}, {0xf5, 0xff}} {
+						eps = append(eps, n.add(byteNode{consume: true, lo: b[0], hi: b[1], next: int(i.Out)}))
+					}
+				}
+			}
+			n.nodes[pc].eps = eps
+		default:
+			return nil, fmt.Errorf("unexpected instruction %s", i.Op)
+		}
+	}
+	return n, nil
+}
+
+// closure returns the consuming nodes reachable
+// from the nodes in kernel and whether the match
+// node is reachable, given the empty-width
+// assertions that hold
+func (n *byteNFA) closure(kernel []int, cond syntax.EmptyOp) ([]int, bool) {
+	seen := make(map[int]bool)
+	var out []int
+	match := false
+	stack := append([]int(nil), kernel...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		node := &n.nodes[id]
+		switch {
+		case node.match:
+			match = true
+		case node.consume:
+			out = append(out, id)
+		case node.cond&^cond != 0:
+			// assertion does not hold
+		default:
+			stack = append(stack, node.eps...)
+		}
+	}
+	sort.Ints(out)
+	return out, match
+}
+
+type byteState struct {
+	kernel []int
+	begin  bool
+}
+
+func (s *byteState) key() string {
+	var b strings.Builder
+	if s.begin {
+		b.WriteString("^")
+	}
+	for _, id := range s.kernel {
+		fmt.Fprintf(&b, "%d,", id)
+	}
+	return b.String()
+}
+
+// NewDsByte compiles re into a DsByte. The DFA matches
+// re starting at the position where it starts running.
+// If unanchored is set, the DFA matches re starting at
+// any position at or after the position where it starts
+// running, and it stops at the first position where
+// a match ends.
+func NewDsByte(re *syntax.Regexp, unanchored bool, maxNodes int) (*DsByte, error) {
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	nfa, err := newByteNFA(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	// compute the byte equivalence classes
+	var bounds [257]bool
+	for i := range nfa.nodes {
+		if nfa.nodes[i].consume {
+			bounds[nfa.nodes[i].lo] = true
+			bounds[int(nfa.nodes[i].hi)+1] = true
+		}
+	}
+	var classes [256]byte
+	var reps []byte // representative byte of each class
+	for b := 0; b < 256; b++ {
+		if b == 0 || bounds[b] {
+			reps = append(reps, byte(b))
+		}
+		classes[b] = byte(len(reps) - 1)
+	}
+
+	states := []byteState{{}} // the dead state
+	index := map[string]int{states[0].key(): 0}
+	lookup := func(s byteState) (int, error) {
+		k := s.key()
+		if id, ok := index[k]; ok {
+			return id, nil
+		}
+		if len(states) >= maxNodes {
+			return 0, fmt.Errorf("regular expression is too complex")
+		}
+		index[k] = len(states)
+		states = append(states, s)
+		return len(states) - 1, nil
+	}
+	start0, err := lookup(byteState{kernel: []int{nfa.start}, begin: true})
+	if err != nil {
+		return nil, err
+	}
+	start, err := lookup(byteState{kernel: []int{nfa.start}})
+	if err != nil {
+		return nil, err
+	}
+
+	rowsize := 4 * len(reps)
+	var trans [][]uint32
+	var flags []uint32
+	for id := 0; id < len(states); id++ {
+		s := states[id]
+		cond := syntax.EmptyOp(0)
+		if s.begin {
+			cond |= syntax.EmptyBeginText
+		}
+		set, match := nfa.closure(s.kernel, cond)
+		_, matchEnd := nfa.closure(s.kernel, cond|syntax.EmptyEndText)
+		f := uint32(0)
+		if id != 0 {
+			if match {
+				f |= DsByteAccept
+			}
+			if matchEnd {
+				f |= DsByteAcceptAtEnd
+			}
+		}
+		flags = append(flags, f)
+		row := make([]uint32, len(reps))
+		if id != 0 {
+			for c, b := range reps {
+				var kernel []int
+				for _, x := range set {
+					if node := &nfa.nodes[x]; node.lo <= b && b <= node.hi {
+						kernel = append(kernel, node.next)
+					}
+				}
+				if unanchored {
+					kernel = append(kernel, nfa.start)
+				}
+				if len(kernel) == 0 {
+					continue // dead
+				}
+				sort.Ints(kernel)
+				next, err := lookup(byteState{kernel: dedup(kernel)})
+				if err != nil {
+					return nil, err
+				}
+				row[c] = uint32(next)
+			}
+		}
+		trans = append(trans, row)
+	}
+
+	entry := func(id int) uint32 {
+		return uint32(id*rowsize) | flags[id]
+	}
+	data := make([]byte, DsByteHeaderSize+len(states)*rowsize)
+	binary.LittleEndian.PutUint32(data[0:], entry(start0))
+	binary.LittleEndian.PutUint32(data[4:], entry(start))
+	if unanchored {
+		binary.LittleEndian.PutUint32(data[8:], DsByteEarliest)
+	}
+	copy(data[16:], classes[:])
+	for id := range trans {
+		row := data[DsByteHeaderSize+id*rowsize:]
+		for c, next := range trans[id] {
+			if next != 0 {
+				binary.LittleEndian.PutUint32(row[4*c:], entry(int(next)))
+			}
+		}
+	}
+	return &DsByte{data: data}, nil
+}
+
+func dedup(lst []int) []int {
+	out := lst[:0]
+	for i := range lst {
+		if i == 0 || lst[i] != lst[i-1] {
+			out = append(out, lst[i])
+		}
+	}
+	return out
+}
+
+// Data returns the data structure for the assembly implementation
+func (d *DsByte) Data() []byte { return d.data }
+
+// Run runs the DFA over s[from:limit] and returns the
+// largest position i such that the DFA accepts s[from:i],
+// or -1 if there is no such position. If the DFA was
+// compiled as unanchored, the smallest such position
+// is returned instead.
+//
+// (this is the same computation that is performed
+// by the assembly implementation)
+func (d *DsByte) Run(s []byte, from, limit int) int {
+	data := d.data
+	state := binary.LittleEndian.Uint32(data[4:])
+	if from == 0 {
+		state = binary.LittleEndian.Uint32(data[0:])
+	}
+	earliest := binary.LittleEndian.Uint32(data[8:])&DsByteEarliest != 0
+	rows := data[DsByteHeaderSize:]
+	result := -1
+	for i := from; ; i++ {
+		if state&DsByteAccept != 0 || (i == limit && limit == len(s) && state&DsByteAcceptAtEnd != 0) {
+			result = i
+			if earliest {
+				return result
+			}
+		}
+		if i == limit {
+			return result
+		}
+		class := uint32(data[16+int(s[i])])
+		state = binary.LittleEndian.Uint32(rows[state&^3+4*class:])
+		if state == 0 {
+			return result
+		}
+	}
+}
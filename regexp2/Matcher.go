@@ -0,0 +1,413 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package regexp2
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+// MaxPieces is the maximum number of pieces of a Matcher
+const MaxPieces = 32
+
+// Matcher locates the leftmost-longest matches of a
+// regular expression (and the spans of some of its
+// capture groups) using only byte DFAs.
+//
+// A match is found by running Find to determine the
+// earliest position at which any match ends, and
+// then running Match from each of the positions up
+// to that position in order to find the leftmost
+// start and the longest end of the match.
+//
+// When the spans of capture groups are needed,
+// the regular expression is split into a sequence
+// of pieces so that each of the capture groups is
+// exactly one piece. The boundaries of the pieces
+// within a match are determined left-to-right by
+// choosing the longest span for each piece that
+// still allows the remaining pieces to match the
+// rest of the match (this is the POSIX rule for
+// sub-expressions). Only capture groups that are
+// not inside a repetition or an alternation can
+// be located this way.
+type Matcher struct {
+	Find  *DsByte
+	Match *DsByte
+	// Pieces[j] matches piece j, and
+	// Rests[j] matches the concatenation
+	// of the pieces after piece j;
+	// both are nil if there are fewer
+	// than two pieces
+	Pieces []*DsByte
+	Rests  []*DsByte
+	// NumPieces is the number of pieces,
+	// or zero if no capture groups are needed
+	NumPieces int
+
+	groups map[int]int
+}
+
+// ParseRegexp parses a regular expression
+// for use with NewMatcher
+func ParseRegexp(pattern string) (*syntax.Regexp, error) {
+	return syntax.Parse(pattern, syntax.Perl)
+}
+
+// NewMatcher compiles a Matcher for pattern that is able
+// to locate each of the capture groups in groups.
+// Capture group 0 is the complete match.
+func NewMatcher(pattern string, groups []int) (*Matcher, error) {
+	re, err := ParseRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	m := &Matcher{}
+	m.Find, err = NewDsByte(re, true, MaxNodesAutomaton)
+	if err != nil {
+		return nil, err
+	}
+	m.Match, err = NewDsByte(re, false, MaxNodesAutomaton)
+	if err != nil {
+		return nil, err
+	}
+	want := make(map[int]bool)
+	for _, g := range groups {
+		if g < 0 || g > re.MaxCap() {
+			return nil, fmt.Errorf("capture group %d does not exist", g)
+		}
+		if g > 0 {
+			want[g] = true
+		}
+	}
+	if len(want) == 0 {
+		return m, nil
+	}
+	pieces, index, err := splitPieces(re, want)
+	if err != nil {
+		return nil, err
+	}
+	if len(pieces) > MaxPieces {
+		return nil, fmt.Errorf("too many capture groups")
+	}
+	m.NumPieces = len(pieces)
+	m.groups = index
+	for j := 0; j+1 < len(pieces); j++ {
+		p, err := NewDsByte(pieces[j], false, MaxNodesAutomaton)
+		if err != nil {
+			return nil, err
+		}
+		r, err := NewDsByte(concat(pieces[j+1:]), false, MaxNodesAutomaton)
+		if err != nil {
+			return nil, err
+		}
+		m.Pieces = append(m.Pieces, p)
+		m.Rests = append(m.Rests, r)
+	}
+	return m, nil
+}
+
+func concat(lst []*syntax.Regexp) *syntax.Regexp {
+	if len(lst) == 1 {
+		return lst[0]
+	}
+	return &syntax.Regexp{Op: syntax.OpConcat, Sub: lst}
+}
+
+func hasCapture(re *syntax.Regexp, want map[int]bool) bool {
+	if re.Op == syntax.OpCapture && want[re.Cap] {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasCapture(sub, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPieces splits re into a sequence of pieces
+// such that each of the wanted capture groups is
+// exactly one piece; it returns the pieces and
+// the index of the piece of each capture group
+func splitPieces(re *syntax.Regexp, want map[int]bool) ([]*syntax.Regexp, map[int]int, error) {
+	var pieces []*syntax.Regexp
+	var atoms []*syntax.Regexp
+	index := make(map[int]int)
+	flush := func() {
+		if len(atoms) > 0 {
+			pieces = append(pieces, concat(atoms))
+			atoms = nil
+		}
+	}
+	var err error
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch {
+		case err != nil:
+		case re.Op == syntax.OpConcat:
+			for _, sub := range re.Sub {
+				walk(sub)
+			}
+		case re.Op == syntax.OpCapture && want[re.Cap]:
+			if hasCapture(re.Sub[0], want) {
+				err = fmt.Errorf("capture group %d contains another capture group", re.Cap)
+				return
+			}
+			flush()
+			index[re.Cap] = len(pieces)
+			pieces = append(pieces, re)
+		case re.Op == syntax.OpCapture:
+			walk(re.Sub[0])
+		case hasCapture(re, want):
+			err = fmt.Errorf("capture groups inside of a repetition or an alternation are not supported")
+		default:
+			atoms = append(atoms, re)
+		}
+	}
+	walk(re)
+	if err != nil {
+		return nil, nil, err
+	}
+	flush()
+	return pieces, index, nil
+}
+
+// Piece returns the index of the piece of the
+// capture group g, or -1 if g is the complete match
+func (m *Matcher) Piece(g int) int {
+	if g == 0 {
+		return -1
+	}
+	return m.groups[g]
+}
+
+// Next returns the leftmost-longest match
+// in s that starts at or after pos, or -1, -1
+// if there is no such match
+func (m *Matcher) Next(s []byte, pos int) (start, end int) {
+	last := m.Find.Run(s, pos, len(s))
+	if last < 0 {
+		return -1, -1
+	}
+	for start = pos; start <= last; start++ {
+		if end = m.Match.Run(s, start, len(s)); end >= 0 {
+			return start, end
+		}
+	}
+	return -1, -1
+}
+
+// Bounds returns the boundaries of the pieces
+// within the match s[start:end]; the span of
+// piece j is [b[j], b[j+1])
+func (m *Matcher) Bounds(s []byte, start, end int) []int {
+	b := make([]int, m.NumPieces+1)
+	b[0] = start
+	b[m.NumPieces] = end
+	for j := 0; j+1 < m.NumPieces; j++ {
+		q := end
+		for ; q > b[j]; q-- {
+			if m.Pieces[j].Run(s, b[j], q) == q && m.Rests[j].Run(s, q, end) == end {
+				break
+			}
+		}
+		b[j+1] = q
+	}
+	return b
+}
+
+// Matches calls fn with each of the successive
+// non-overlapping matches in s; empty matches
+// abutting a preceding match are ignored
+func (m *Matcher) Matches(s []byte, fn func(start, end int)) {
+	prev := -1
+	for pos := 0; pos <= len(s); {
+		start, end := m.Next(s, pos)
+		if start < 0 {
+			return
+		}
+		if end == start && start == prev {
+			pos = start + charWidth(s, start)
+			continue
+		}
+		fn(start, end)
+		prev = end
+		if end > start {
+			pos = end
+		} else {
+			pos = end + charWidth(s, end)
+		}
+	}
+}
+
+// charWidth returns the number of bytes of the
+// UTF-8 sequence that starts at s[i]
+func charWidth(s []byte, i int) int {
+	if i >= len(s) {
+		return 1
+	}
+	n := 1
+	switch c := s[i]; {
+	case c >= 0xf5:
+		// invalid
+	case c >= 0xf0:
+		n = 4
+	case c >= 0xe0:
+		n = 3
+	case c >= 0xc2:
+		n = 2
+	}
+	if i+n > len(s) {
+		n = len(s) - i
+	}
+	return n
+}
+
+// Extract returns the span of capture group g
+// within the first match in s
+func (m *Matcher) Extract(s []byte, g int) ([]byte, bool) {
+	start, end := m.Next(s, 0)
+	if start < 0 {
+		return nil, false
+	}
+	j := m.Piece(g)
+	if j < 0 {
+		return s[start:end], true
+	}
+	b := m.Bounds(s, start, end)
+	return s[b[j]:b[j+1]], true
+}
+
+// Count returns the number of non-overlapping
+// matches in s
+func (m *Matcher) Count(s []byte) int {
+	n := 0
+	m.Matches(s, func(start, end int) { n++ })
+	return n
+}
+
+// Replace replaces each of the non-overlapping
+// matches in s with the expansion of repl
+func (m *Matcher) Replace(s []byte, repl []ReplaceItem) []byte {
+	var out []byte
+	last := 0
+	m.Matches(s, func(start, end int) {
+		out = append(out, s[last:start]...)
+		var b []int
+		for i := range repl {
+			if repl[i].Group < 0 {
+				out = append(out, repl[i].Literal...)
+				continue
+			}
+			j := m.Piece(repl[i].Group)
+			if j < 0 {
+				out = append(out, s[start:end]...)
+				continue
+			}
+			if b == nil {
+				b = m.Bounds(s, start, end)
+			}
+			out = append(out, s[b[j]:b[j+1]]...)
+		}
+		last = end
+	})
+	return append(out, s[last:]...)
+}
+
+// ReplaceItem is one element of a replacement string;
+// it is either a literal string or (if Group is
+// not negative) a reference to a capture group
+type ReplaceItem struct {
+	Literal string
+	Group   int
+}
+
+// ParseReplacement parses a replacement string
+// for the regular expression re. Within the
+// replacement, $n or ${n} is replaced with the
+// text of capture group n, ${name} is replaced
+// with the text of the named capture group name,
+// and $$ is replaced with a literal $.
+func ParseReplacement(repl string, re *syntax.Regexp) ([]ReplaceItem, error) {
+	var out []ReplaceItem
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			out = append(out, ReplaceItem{Literal: lit.String(), Group: -1})
+			lit.Reset()
+		}
+	}
+	names := re.CapNames()
+	for len(repl) > 0 {
+		i := strings.IndexByte(repl, '$')
+		if i < 0 {
+			lit.WriteString(repl)
+			break
+		}
+		lit.WriteString(repl[:i])
+		repl = repl[i+1:]
+		if strings.HasPrefix(repl, "$") {
+			lit.WriteByte('$')
+			repl = repl[1:]
+			continue
+		}
+		var name string
+		if strings.HasPrefix(repl, "{") {
+			end := strings.IndexByte(repl, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated group reference in replacement")
+			}
+			name, repl = repl[1:end], repl[end+1:]
+		} else {
+			end := 0
+			for end < len(repl) && repl[end] >= '0' && repl[end] <= '9' {
+				end++
+			}
+			name, repl = repl[:end], repl[end:]
+		}
+		g := -1
+		if n, err := strconv.Atoi(name); err == nil {
+			g = n
+		} else {
+			for j := range names {
+				if names[j] != "" && names[j] == name {
+					g = j
+				}
+			}
+		}
+		if g < 0 || g >= len(names) {
+			return nil, fmt.Errorf("replacement refers to unknown capture group %q", name)
+		}
+		flush()
+		out = append(out, ReplaceItem{Group: g})
+	}
+	flush()
+	return out, nil
+}
+
+// ReplaceGroups returns the capture groups
+// referenced by a replacement
+func ReplaceGroups(repl []ReplaceItem) []int {
+	var out []int
+	for i := range repl {
+		if repl[i].Group >= 0 {
+			out = append(out, repl[i].Group)
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package regexp2
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestMatcherGo compares the matches found by a
+// Matcher with the leftmost-longest matches found
+// by the Go regexp package
+func TestMatcherGo(t *testing.T) {
+	patterns := []string{
+		`a+`,
+		`[0-9]+`,
+		`x*`,
+		`^ab`,
+		`ab$`,
+		`^$`,
+		`(foo|foobar)`,
+		`(?i)hello`,
+		`[^ ]+=[^ ]+`,
+		`é+`,
+		`[à-ü]+`,
+		`\p{Greek}+`,
+		`.`,
+		`(?s).+`,
+		`a|b|`,
+		`[0-9]{1,3}(\.[0-9]{1,3}){3}`,
+	}
+	inputs := []string{
+		"",
+		"a",
+		"aaa baa",
+		"ab",
+		"abab",
+		"foobar foo",
+		"HeLLo hello",
+		"x=1 y=22 z",
+		"ééé e é",
+		"àéü ÀÉ",
+		"αβγ abc",
+		"line1\nline2",
+		"ip 10.0.0.1 and 192.168.100.200!",
+		"\xff\xfe",
+	}
+	for _, p := range patterns {
+		m, err := NewMatcher(p, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+		re := regexp.MustCompile(p)
+		re.Longest()
+		for _, in := range inputs {
+			var got [][]int
+			m.Matches([]byte(in), func(start, end int) {
+				got = append(got, []int{start, end})
+			})
+			want := re.FindAllStringIndex(in, -1)
+			if len(got) != len(want) {
+				t.Errorf("%q in %q: got %v want %v", p, in, got, want)
+				continue
+			}
+			for i := range got {
+				if got[i][0] != want[i][0] || got[i][1] != want[i][1] {
+					t.Errorf("%q in %q: got %v want %v", p, in, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestMatcherGroups(t *testing.T) {
+	tcs := []struct {
+		pattern, input string
+		group          int
+		want           string
+		ok             bool
+	}{
+		{`user=(\w+)`, "ts=1 user=bob ok", 1, "bob", true},
+		{`user=(\w+)`, "ts=1 usr=bob", 1, "", false},
+		{`(\w+)@(\w+)\.com`, "mail fred@example.com now", 2, "example", true},
+		{`(\w+)@(\w+)\.com`, "mail fred@example.com now", 1, "fred", true},
+		{`(\w+)@(\w+)\.com`, "mail fred@example.com now", 0, "fred@example.com", true},
+		{`(a*)(a*)`, "aaa", 1, "aaa", true},
+		{`(a*)(a*)`, "aaa", 2, "", true},
+		{`x(a|ab)(c|bcd)y`, "xabcdy", 1, "a", true},
+		{`x(a|ab)(c|bcd)y`, "xabcdy", 2, "bcd", true},
+		{`(a|ab)(b*)`, "abbb", 1, "ab", true},
+		{`(a|ab)(b*)`, "abbb", 2, "bb", true},
+		{`((\d+)-(\d+))`, "call 555-1234", 3, "1234", true},
+		{`^(\S+) (\S+)`, "GET /index.html HTTP/1.1", 2, "/index.html", true},
+	}
+	for _, tc := range tcs {
+		m, err := NewMatcher(tc.pattern, []int{tc.group})
+		if err != nil {
+			t.Fatalf("%s: %s", tc.pattern, err)
+		}
+		got, ok := m.Extract([]byte(tc.input), tc.group)
+		if ok != tc.ok || string(got) != tc.want {
+			t.Errorf("%s group %d in %q: got %q, %v", tc.pattern, tc.group, tc.input, got, ok)
+		}
+	}
+}
+
+func TestMatcherUnsupported(t *testing.T) {
+	tcs := []struct {
+		pattern string
+		groups  []int
+	}{
+		{`\bfoo`, nil},
+		{`(?m)^foo`, nil},
+		{`(a)+`, []int{1}},
+		{`(a)|b`, []int{1}},
+		{`((a)b)`, []int{1, 2}},
+		{`(a)`, []int{2}},
+	}
+	for _, tc := range tcs {
+		if _, err := NewMatcher(tc.pattern, tc.groups); err == nil {
+			t.Errorf("%s with groups %v: expected an error", tc.pattern, tc.groups)
+		}
+	}
+}
+
+func TestMatcherReplace(t *testing.T) {
+	tcs := []struct {
+		pattern, repl, input, want string
+	}{
+		{`[0-9]+`, "#", "a1b22c333", "a#b#c#"},
+		{`x*`, "-", "abc", "-a-b-c-"},
+		{`(\w+)@(\w+)`, "$2 at ${1}", "fred@example", "example at fred"},
+		{`(?P<key>\w+)=(?P<val>\w+)`, "${val}:${key}", "a=1 b=2", "1:a 2:b"},
+		{`\$`, "$$$$", "cost $5", "cost $$5"},
+		{`é`, "e", "ééé", "eee"},
+		{`a`, "$0$0", "banana", "baanaanaa"},
+	}
+	for _, tc := range tcs {
+		re, err := ParseRegexp(tc.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repl, err := ParseReplacement(tc.repl, re)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.repl, err)
+		}
+		m, err := NewMatcher(tc.pattern, ReplaceGroups(repl))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.pattern, err)
+		}
+		got := string(m.Replace([]byte(tc.input), repl))
+		if got != tc.want {
+			t.Errorf("replace %s with %s in %q: got %q want %q", tc.pattern, tc.repl, tc.input, got, tc.want)
+		}
+	}
+	re, _ := ParseRegexp(`(a)`)
+	for _, bad := range []string{"$2", "${x}", "${1", "$"} {
+		if _, err := ParseReplacement(bad, re); err == nil {
+			t.Errorf("replacement %q: expected an error", bad)
+		}
+	}
+}
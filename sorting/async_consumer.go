@@ -119,7 +119,7 @@ func (a *AsyncConsumer) Start(pool ThreadPool) {
 			}
 
 			// if the last range has just been flushed, we're done
-			if a.remaining.start >= a.remaining.end {
+			if a.remaining.start > a.remaining.end {
 				break
 			}
 		}
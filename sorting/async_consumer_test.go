@@ -52,6 +52,19 @@ func TestAsyncConsumerCase3(t *testing.T) {
 	testAsyncConsumer(t, notifications)
 }
 
+func TestAsyncConsumerCase4(t *testing.T) {
+
+	// the last range holds a single item
+	notifications := []indicesRange{
+		indicesRange{start: 0, end: 99},
+		indicesRange{start: 100, end: 100}}
+
+	writer := executeNotifications(notifications, nil)
+	if len(writer.items) != 101 || !isRange(writer.items, 0, 100) {
+		t.Errorf("Expected range from 0 to 100, got %v", writer.items)
+	}
+}
+
 func executeNotifications(notifications []indicesRange, limit *Limit) *FakeSortedDataWriter {
 	var writer FakeSortedDataWriter
 
@@ -117,16 +117,5 @@ func (k *Ktop) greater(leftnum, rightnum int) bool {
 }
 
 func (k *Ktop) recGreater(record1, record2 *IonRecord) bool {
-	for i := range k.orders {
-		raw1 := record1.UnsafeField(i)
-		raw2 := record2.UnsafeField(i)
-		cmp := k.orders[i].Compare(raw1, raw2)
-		if cmp > 0 {
-			return true
-		} else if cmp < 0 {
-			return false
-		}
-		// cmp == 0 -> continue
-	}
-	return false
+	return compareRecords(k.orders, record1, record2) > 0
 }
@@ -0,0 +1,428 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sorting
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/heap"
+)
+
+// spillBlock is the size of the blocks
+// from which record memory is allocated
+const spillBlock = 1024 * 1024
+
+// defaultFanIn is the maximum number
+// of runs that are merged at once
+const defaultFanIn = 64
+
+// Spill collects records for an external merge sort.
+//
+// Records are buffered in memory until their total
+// size exceeds a limit, at which point they are sorted
+// and written to a temporary file as a "run."
+// Walk produces all of the records in sorted order
+// by performing a k-way merge of the runs.
+// When there are too many runs to merge at once,
+// groups of runs are first merged into longer runs.
+type Spill struct {
+	dir    string
+	limit  int
+	orders []Ordering
+	// fanin is the maximum number
+	// of runs that are merged at once
+	fanin int
+
+	// records held in memory and their total size
+	records []IonRecord
+	size    int
+	// unused memory for records
+	free   []byte
+	delims [][2]uint32
+
+	// sorted runs written so far
+	runs []*os.File
+	tmp  []byte
+}
+
+// NewSpill constructs a new Spill that writes
+// runs to temporary files in dir (or os.TempDir()
+// if dir is empty) once more than limit bytes
+// of records are held in memory.
+func NewSpill(dir string, limit int, orders []Ordering) *Spill {
+	return &Spill{
+		dir:    dir,
+		limit:  limit,
+		orders: orders,
+		fanin:  defaultFanIn,
+	}
+}
+
+// Runs returns the number of runs
+// that have been written to disk.
+func (s *Spill) Runs() int { return len(s.runs) }
+
+func (s *Spill) alloc(n int) []byte {
+	if len(s.free) < n {
+		size := spillBlock
+		if n > size {
+			size = n
+		}
+		s.free = make([]byte, size)
+	}
+	mem := s.free[:n:n]
+	s.free = s.free[n:]
+	return mem
+}
+
+func (s *Spill) allocDelims(n int) [][2]uint32 {
+	if len(s.delims) < n {
+		s.delims = make([][2]uint32, 1024*n)
+	}
+	mem := s.delims[:n:n]
+	s.delims = s.delims[n:]
+	return mem
+}
+
+// Add adds a copy of rec to the collection.
+func (s *Spill) Add(rec *IonRecord) error {
+	r := IonRecord{
+		Raw:         s.alloc(len(rec.Raw)),
+		FieldDelims: s.allocDelims(len(rec.FieldDelims)),
+		Boxed:       rec.Boxed,
+		SymtabID:    rec.SymtabID,
+	}
+	copy(r.Raw, rec.Raw)
+	copy(r.FieldDelims, rec.FieldDelims)
+	return s.add(&r)
+}
+
+func (s *Spill) add(rec *IonRecord) error {
+	s.records = append(s.records, *rec)
+	s.size += len(rec.Raw) + 8*len(rec.FieldDelims)
+	if s.size > s.limit {
+		return s.spill()
+	}
+	return nil
+}
+
+// Merge adds all of the records from o to s
+// and takes ownership of the runs written by o.
+// The records held in memory by o are not copied,
+// so o must not be used after Merge is called.
+func (s *Spill) Merge(o *Spill) error {
+	s.runs = append(s.runs, o.runs...)
+	o.runs = nil
+	for i := range o.records {
+		err := s.add(&o.records[i])
+		if err != nil {
+			return err
+		}
+	}
+	o.records = nil
+	return nil
+}
+
+func (s *Spill) less(a, b *IonRecord) bool {
+	return compareRecords(s.orders, a, b) < 0
+}
+
+func (s *Spill) sort() {
+	slices.SortStableFunc(s.records, func(a, b IonRecord) bool {
+		return s.less(&a, &b)
+	})
+}
+
+// spill writes the records held in memory
+// to a new run and releases their memory
+func (s *Spill) spill() error {
+	s.sort()
+	f, w, err := s.create()
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f)
+	for i := range s.records {
+		err = s.write(w, &s.records[i])
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("sorting.Spill: %w", err)
+	}
+	s.records = s.records[:0]
+	s.size = 0
+	s.free = nil
+	s.delims = nil
+	return nil
+}
+
+// create creates a temporary file for a new run
+func (s *Spill) create() (*os.File, *bufio.Writer, error) {
+	f, err := os.CreateTemp(s.dir, "sort-run-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("sorting.Spill: %w", err)
+	}
+	// the file is unlinked right away so that
+	// it disappears once it is closed, even if
+	// the process exits before Close is called
+	os.Remove(f.Name())
+	return f, bufio.NewWriter(f), nil
+}
+
+func (s *Spill) write(w *bufio.Writer, rec *IonRecord) error {
+	s.tmp = encodeRecord(s.tmp[:0], rec)
+	_, err := w.Write(s.tmp)
+	if err != nil {
+		return fmt.Errorf("sorting.Spill: %w", err)
+	}
+	return nil
+}
+
+// A record in a run is encoded as
+//
+//	symtab ID, boxed size, raw size (uvarint)
+//	field delimiters (2 uvarints each)
+//	raw bytes
+//
+// The number of field delimiters is the
+// number of orderings used for sorting.
+func encodeRecord(dst []byte, rec *IonRecord) []byte {
+	dst = appendUvarint(dst, uint64(rec.SymtabID))
+	dst = appendUvarint(dst, uint64(rec.Boxed))
+	dst = appendUvarint(dst, uint64(len(rec.Raw)))
+	for i := range rec.FieldDelims {
+		dst = appendUvarint(dst, uint64(rec.FieldDelims[i][0]))
+		dst = appendUvarint(dst, uint64(rec.FieldDelims[i][1]))
+	}
+	return append(dst, rec.Raw...)
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(dst, tmp[:n]...)
+}
+
+// run is the state of a sorted run during Walk
+type run struct {
+	// r is the run on disk, or nil
+	// if the run is held in memory
+	r   *bufio.Reader
+	mem []IonRecord
+	// cur is the current record
+	cur IonRecord
+	// id breaks ties between runs
+	id int
+}
+
+func (r *run) next(fields int) (bool, error) {
+	if r.r == nil {
+		if len(r.mem) == 0 {
+			return false, nil
+		}
+		r.cur = r.mem[0]
+		r.mem = r.mem[1:]
+		return true, nil
+	}
+	id, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	boxed, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return false, err
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return false, err
+	}
+	r.cur.SymtabID = int(id)
+	r.cur.Boxed = uint32(boxed)
+	r.cur.FieldDelims = r.cur.FieldDelims[:0]
+	for i := 0; i < fields; i++ {
+		off, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return false, err
+		}
+		n, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return false, err
+		}
+		r.cur.FieldDelims = append(r.cur.FieldDelims, [2]uint32{uint32(off), uint32(n)})
+	}
+	if cap(r.cur.Raw) < int(size) {
+		r.cur.Raw = make([]byte, size)
+	}
+	r.cur.Raw = r.cur.Raw[:size]
+	_, err = io.ReadFull(r.r, r.cur.Raw)
+	return err == nil, err
+}
+
+// Walk calls fn for each of the records in s
+// in sorted order. The record passed to fn
+// is only valid until fn returns.
+// The order of records that compare equal
+// is unspecified.
+func (s *Spill) Walk(fn func(rec *IonRecord) error) error {
+	s.sort()
+	// leave room for the records held in memory
+	for len(s.runs) >= s.fanin {
+		err := s.mergePass()
+		if err != nil {
+			return err
+		}
+	}
+	runs := make([]*run, 0, len(s.runs)+1)
+	for i, f := range s.runs {
+		r, err := openRun(f, i)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, r)
+	}
+	runs = append(runs, &run{mem: s.records, id: len(s.runs)})
+	return s.merge(runs, fn)
+}
+
+// mergePass merges each group of (at most)
+// s.fanin runs on disk into a single run
+func (s *Spill) mergePass() error {
+	var out []*os.File
+	for len(s.runs) > 0 {
+		n := s.fanin
+		if n > len(s.runs) {
+			n = len(s.runs)
+		}
+		group := s.runs[:n]
+		if n == 1 {
+			out = append(out, group[0])
+			s.runs = s.runs[1:]
+			continue
+		}
+		f, w, err := s.create()
+		if err != nil {
+			return err
+		}
+		out = append(out, f)
+		runs := make([]*run, len(group))
+		for i := range group {
+			runs[i], err = openRun(group[i], i)
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = s.merge(runs, func(rec *IonRecord) error {
+				return s.write(w, rec)
+			})
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		// the merged runs are released
+		// whether or not the merge succeeded
+		for i := range group {
+			group[i].Close()
+		}
+		s.runs = s.runs[n:]
+		if err != nil {
+			s.runs = append(out, s.runs...)
+			return fmt.Errorf("sorting.Spill: merging runs: %w", err)
+		}
+	}
+	s.runs = out
+	return nil
+}
+
+func openRun(f *os.File, id int) (*run, error) {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("sorting.Spill: %w", err)
+	}
+	return &run{r: bufio.NewReader(f), id: id}, nil
+}
+
+// merge calls fn for each of the records in runs
+// in sorted order by performing a k-way merge
+func (s *Spill) merge(runs []*run, fn func(rec *IonRecord) error) error {
+	fields := len(s.orders)
+	less := func(a, b *run) bool {
+		c := compareRecords(s.orders, &a.cur, &b.cur)
+		return c < 0 || c == 0 && a.id < b.id
+	}
+	h := runs[:0]
+	for _, r := range runs {
+		ok, err := r.next(fields)
+		if err != nil {
+			return fmt.Errorf("sorting.Spill: reading run: %w", err)
+		}
+		if ok {
+			h = append(h, r)
+		}
+	}
+	heap.OrderSlice(h, less)
+	for len(h) > 0 {
+		err := fn(&h[0].cur)
+		if err != nil {
+			return err
+		}
+		ok, err := h[0].next(fields)
+		if err != nil {
+			return fmt.Errorf("sorting.Spill: reading run: %w", err)
+		}
+		if ok {
+			heap.FixSlice(h, 0, less)
+		} else {
+			heap.PopSlice(&h, less)
+		}
+	}
+	return nil
+}
+
+// Close releases the runs written to disk.
+func (s *Spill) Close() error {
+	var err error
+	for _, f := range s.runs {
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+	}
+	s.runs = nil
+	s.records = nil
+	return err
+}
+
+// compareRecords compares two records
+// according to the given orderings
+func compareRecords(orders []Ordering, a, b *IonRecord) int {
+	for i := range orders {
+		cmp := orders[i].Compare(a.UnsafeField(i), b.UnsafeField(i))
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sorting
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestSpillWalk(t *testing.T) {
+	// with a small fan-in, the runs
+	// are merged in several passes
+	for _, fanin := range []int{defaultFanIn, 2, 3} {
+		t.Run(fmt.Sprintf("fanin=%d", fanin), func(t *testing.T) {
+			testSpillWalk(t, fanin)
+		})
+	}
+}
+
+func testSpillWalk(t *testing.T, fanin int) {
+	const (
+		workers = 4
+		count   = 5000
+	)
+	orders := []Ordering{{NullsFirst, Descending}}
+	dir := t.TempDir()
+	spills := make([]*Spill, workers)
+	for i := range spills {
+		// small enough that every
+		// worker writes several runs
+		spills[i] = NewSpill(dir, 4096, orders)
+	}
+
+	var buf ion.Buffer
+	fields := make([][2]uint32, 1)
+	for _, n := range rand.Perm(count) {
+		// the record is just the boxed
+		// column followed by no data
+		buf.Reset()
+		buf.WriteInt(int64(n))
+		fields[0] = [2]uint32{0, uint32(buf.Size())}
+		rec := IonRecord{
+			Raw:         buf.Bytes(),
+			FieldDelims: fields,
+			Boxed:       uint32(buf.Size()),
+			SymtabID:    n % 7,
+		}
+		err := spills[n%workers].Add(&rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewSpill(dir, 1<<20, orders)
+	s.fanin = fanin
+	defer s.Close()
+	for i := range spills {
+		if spills[i].Runs() == 0 {
+			t.Errorf("spill %d did not write any runs", i)
+		}
+		err := s.Merge(spills[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := int64(count - 1)
+	err := s.Walk(func(rec *IonRecord) error {
+		n, _, err := ion.ReadInt(rec.UnsafeField(0))
+		if err != nil {
+			return err
+		}
+		if n != want {
+			t.Fatalf("got %d, want %d", n, want)
+		}
+		if rec.SymtabID != int(n%7) {
+			t.Fatalf("record %d has symtab ID %d", n, rec.SymtabID)
+		}
+		if len(rec.Bytes()) != 0 {
+			t.Fatalf("record %d has %d data bytes", n, len(rec.Bytes()))
+		}
+		want--
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != -1 {
+		t.Fatalf("walked %d records, want %d", count-1-want, count)
+	}
+	if s.Runs() >= fanin {
+		t.Errorf("%d runs left after merging", s.Runs())
+	}
+}
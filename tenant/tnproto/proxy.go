@@ -25,6 +25,7 @@ import (
 	"io"
 	"net"
 	"net/http/httputil"
+	"os"
 	"time"
 
 	"github.com/SnellerInc/sneller/ion"
@@ -428,6 +429,10 @@ func serveDirect(t *plan.Tree, conn io.WriteCloser, errpipe net.Conn) {
 	ep := plan.ExecParams{
 		Output:  conn,
 		Context: ctx,
+		// tenant processes are started with
+		// CACHEDIR pointing to a directory of
+		// their own (see tenant.DefaultEnv)
+		TempDir: os.Getenv("CACHEDIR"),
 	}
 	err := pl.Exec(t, &ep)
 	if err != nil {
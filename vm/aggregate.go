@@ -56,11 +56,24 @@ const (
 	AggregateKindMinTS
 	AggregateKindMaxTS
 	AggregateKindCount
+	AggregateKindApproxCount
+	AggregateKindApproxCountPartial
+	AggregateKindApproxPercentile
+	AggregateKindApproxPercentilePartial
+	AggregateKindVarPop
+	AggregateKindVarSamp
+	AggregateKindStddevPop
+	AggregateKindStddevSamp
+	AggregateKindCovarPop
+	AggregateKindCorr
+	AggregateKindMomentsPartial
+	AggregateKindArrayAgg
+	AggregateKindArrayAggPartial
 )
 
 type aggregateKindInfo struct {
 	isFloat    bool
-	dataSize   uint8
+	dataSize   uint16
 	firstValue uint64
 }
 
@@ -88,6 +101,24 @@ var aggregateKindInfoTable = [...]aggregateKindInfo{
 	AggregateKindMaxTS: {isFloat: false, dataSize: 16, firstValue: 0x8000000000000000},
 
 	AggregateKindCount: {isFloat: false, dataSize: 8, firstValue: 0},
+
+	AggregateKindApproxCount:        {isFloat: false, dataSize: hllRegisters, firstValue: 0},
+	AggregateKindApproxCountPartial: {isFloat: false, dataSize: hllRegisters, firstValue: 0},
+
+	// the sketch is initialized by initPercentileSketches
+	AggregateKindApproxPercentile:        {isFloat: true, dataSize: percentileSketchSize, firstValue: 0},
+	AggregateKindApproxPercentilePartial: {isFloat: true, dataSize: percentileSketchSize, firstValue: 0},
+
+	AggregateKindVarPop:         {isFloat: true, dataSize: momentsSize, firstValue: 0},
+	AggregateKindVarSamp:        {isFloat: true, dataSize: momentsSize, firstValue: 0},
+	AggregateKindStddevPop:      {isFloat: true, dataSize: momentsSize, firstValue: 0},
+	AggregateKindStddevSamp:     {isFloat: true, dataSize: momentsSize, firstValue: 0},
+	AggregateKindCovarPop:       {isFloat: true, dataSize: momentsSize, firstValue: 0},
+	AggregateKindCorr:           {isFloat: true, dataSize: momentsSize, firstValue: 0},
+	AggregateKindMomentsPartial: {isFloat: true, dataSize: momentsSize, firstValue: 0},
+
+	AggregateKindArrayAgg:        {isFloat: false, dataSize: arrayAggSize, firstValue: 0},
+	AggregateKindArrayAggPartial: {isFloat: false, dataSize: arrayAggSize, firstValue: 0},
 }
 
 func initAggregateValues(data []byte, aggregateKinds []AggregateKind) {
@@ -205,6 +236,27 @@ func mergeAggregatedValues(dst, src []byte, aggregateKinds []AggregateKind) {
 			bufferAddInt64(dst, src)
 			dst = dst[8:]
 			src = src[8:]
+
+		case AggregateKindApproxCount, AggregateKindApproxCountPartial:
+			hllMerge(dst, src)
+			dst = dst[hllRegisters:]
+			src = src[hllRegisters:]
+
+		case AggregateKindApproxPercentile, AggregateKindApproxPercentilePartial:
+			percentileMerge(dst, src)
+			dst = dst[percentileSketchSize:]
+			src = src[percentileSketchSize:]
+
+		case AggregateKindVarPop, AggregateKindVarSamp, AggregateKindStddevPop, AggregateKindStddevSamp,
+			AggregateKindCovarPop, AggregateKindCorr, AggregateKindMomentsPartial:
+			momentsMerge(dst, src)
+			dst = dst[momentsSize:]
+			src = src[momentsSize:]
+
+		case AggregateKindArrayAgg, AggregateKindArrayAggPartial:
+			arrayAggMerge(dst, src[:arrayAggSize])
+			dst = dst[arrayAggSize:]
+			src = src[arrayAggSize:]
 		}
 	}
 }
@@ -288,6 +340,27 @@ func mergeAggregatedValuesAtomically(dst, src []byte, aggregateKinds []Aggregate
 			atomic.AddUint64((*uint64)(unsafe.Pointer(&dst[0])), binary.LittleEndian.Uint64(src))
 			dst = dst[8:]
 			src = src[8:]
+
+		case AggregateKindApproxCount, AggregateKindApproxCountPartial:
+			hllMergeAtomically(dst, src)
+			dst = dst[hllRegisters:]
+			src = src[hllRegisters:]
+
+		case AggregateKindApproxPercentile, AggregateKindApproxPercentilePartial:
+			percentileMergeAtomically(dst, src)
+			dst = dst[percentileSketchSize:]
+			src = src[percentileSketchSize:]
+
+		case AggregateKindVarPop, AggregateKindVarSamp, AggregateKindStddevPop, AggregateKindStddevSamp,
+			AggregateKindCovarPop, AggregateKindCorr, AggregateKindMomentsPartial:
+			momentsMergeAtomically(dst, src)
+			dst = dst[momentsSize:]
+			src = src[momentsSize:]
+
+		case AggregateKindArrayAgg, AggregateKindArrayAggPartial:
+			arrayAggMergeAtomically(dst, src[:arrayAggSize])
+			dst = dst[arrayAggSize:]
+			src = src[arrayAggSize:]
 		}
 	}
 }
@@ -354,6 +427,41 @@ func writeAggregatedValue(b *ion.Buffer, data []byte, kind AggregateKind) int {
 		count := binary.LittleEndian.Uint64(data)
 		b.WriteUint(count)
 		return 8
+	case AggregateKindApproxCount:
+		b.WriteUint(hllEstimate(data))
+		return hllRegisters
+	case AggregateKindApproxCountPartial:
+		b.WriteBlob(data[:hllRegisters])
+		return hllRegisters
+	case AggregateKindApproxPercentile:
+		est, ok := percentileEstimate(data)
+		if !ok {
+			b.WriteNull()
+		} else {
+			b.WriteCanonicalFloat(est)
+		}
+		return percentileSketchSize
+	case AggregateKindApproxPercentilePartial:
+		b.WriteBlob(data[:percentileSketchSize])
+		return percentileSketchSize
+	case AggregateKindVarPop, AggregateKindVarSamp, AggregateKindStddevPop, AggregateKindStddevSamp,
+		AggregateKindCovarPop, AggregateKindCorr:
+		res, ok := momentsResult(data, kind)
+		if !ok {
+			b.WriteNull()
+		} else {
+			b.WriteCanonicalFloat(res)
+		}
+		return momentsSize
+	case AggregateKindMomentsPartial:
+		b.WriteBlob(data[:momentsSize])
+		return momentsSize
+	case AggregateKindArrayAgg:
+		writeArrayAgg(b, data)
+		return arrayAggSize
+	case AggregateKindArrayAggPartial:
+		b.WriteBlob(arrayAggPartial(data))
+		return arrayAggSize
 	default:
 		panic(fmt.Sprintf("Invalid aggregate kind: %v", kind))
 	}
@@ -550,6 +658,9 @@ func (q *Aggregate) compileAggregate(agg Aggregation) error {
 		}
 
 		op := agg[i].Expr.Op
+		if offset > math.MaxUint16 {
+			return fmt.Errorf("aggregate buffer too large for %s", &agg[i])
+		}
 
 		// COUNT(...) is the only aggregate op that doesn't accept numbers;
 		// additionally, it accepts '*', which has a special meaning in this context.
@@ -564,6 +675,70 @@ func (q *Aggregate) compileAggregate(agg Aggregation) error {
 				mem[i] = p.AggregateCount(v, filter, offset)
 			}
 			kinds[i] = AggregateKindCount
+		} else if op == expr.OpApproxCountDistinct || op == expr.OpApproxCountDistinctPartial {
+			argv, err := compile(p, agg[i].Expr.Inner)
+			if err != nil {
+				return err
+			}
+			mem[i] = p.AggregateApproxCount(argv, filter, offset)
+			kinds[i] = AggregateKindApproxCount
+			if op == expr.OpApproxCountDistinctPartial {
+				kinds[i] = AggregateKindApproxCountPartial
+			}
+		} else if op == expr.OpApproxCountDistinctMerge {
+			argv, err := compile(p, agg[i].Expr.Inner)
+			if err != nil {
+				return err
+			}
+			mem[i] = p.AggregateApproxCountMerge(argv, filter, offset)
+			kinds[i] = AggregateKindApproxCount
+		} else if op == expr.OpApproxPercentile || op == expr.OpApproxPercentilePartial {
+			argv, err := p.compileAsNumber(agg[i].Expr.Inner)
+			if err != nil {
+				return fmt.Errorf("don't know how to aggregate %q: %w", agg[i].Expr.Inner, err)
+			}
+			mem[i] = p.AggregateApproxPercentile(argv, filter, offset)
+			kinds[i] = AggregateKindApproxPercentile
+			if op == expr.OpApproxPercentilePartial {
+				kinds[i] = AggregateKindApproxPercentilePartial
+			}
+		} else if op == expr.OpApproxPercentileMerge {
+			argv, err := compile(p, agg[i].Expr.Inner)
+			if err != nil {
+				return err
+			}
+			mem[i] = p.AggregateApproxPercentileMerge(argv, filter, offset)
+			kinds[i] = AggregateKindApproxPercentile
+		} else if kind, merge, ok := momentsKind(op); ok {
+			if merge {
+				argv, err := compile(p, agg[i].Expr.Inner)
+				if err != nil {
+					return err
+				}
+				mem[i] = p.AggregateMomentsMerge(argv, filter, offset)
+			} else {
+				x, y, err := compileMomentsArgs(p, agg[i].Expr)
+				if err != nil {
+					return err
+				}
+				mem[i] = p.AggregateMoments(x, y, filter, offset)
+			}
+			kinds[i] = kind
+		} else if kind, merge, ok := arrayAggKind(op); ok {
+			if merge {
+				argv, err := compile(p, agg[i].Expr.Inner)
+				if err != nil {
+					return err
+				}
+				mem[i] = p.AggregateArrayMerge(argv, filter, offset)
+			} else {
+				val, key, err := compileArrayAggArgs(p, agg[i].Expr)
+				if err != nil {
+					return err
+				}
+				mem[i] = p.AggregateArray(val, key, filter, offset)
+			}
+			kinds[i] = kind
 		} else if op.IsBoolOp() {
 			argv, err := p.compileAsBool(agg[i].Expr.Inner)
 			if err != nil {
@@ -650,6 +825,8 @@ func (q *Aggregate) compileAggregate(agg Aggregation) error {
 	aggregateDataSize := offset
 	initialData := make([]byte, aggregateDataSize)
 	initAggregateValues(initialData, kinds)
+	initPercentileSketches(initialData, agg, kinds)
+	initArrayAggs(initialData, agg, kinds)
 
 	q.aggregateKinds = kinds
 	q.initialData = initialData
@@ -0,0 +1,257 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sync"
+)
+
+const (
+	// spillBits is the number of bits
+	// of a hash that select its partition
+	spillBits = radix + 2
+
+	// spillBuckets is the number of partitions
+	// into which a spilling HashAggregate
+	// writes its groups
+	spillBuckets = 1 << spillBits
+
+	// spillLevels is the number of times
+	// that the groups can be partitioned
+	// (each time using different bits of the hash)
+	spillLevels = 32 / spillBits
+)
+
+// spillBucket returns the partition for
+// a hash as stored in a radixTree64
+// at the given level of partitioning
+//
+// the tree may store a hash rotated by 32 bits,
+// so the partition is chosen from bits that
+// are identical in both forms
+func spillBucket(h uint64, level int) int {
+	return int(((h ^ bits.RotateLeft64(h, 32)) >> (level * spillBits)) & (spillBuckets - 1))
+}
+
+// aggspill holds the groups that a
+// HashAggregate has written to disk
+//
+// Each group is written to the bucket selected
+// by its hash, so every partial result for a
+// particular group ends up in the same bucket
+// and the buckets can be aggregated independently.
+// A bucket that holds too many groups to aggregate
+// in memory is partitioned again into an aggspill
+// with the next level.
+type aggspill struct {
+	dir    string
+	maxmem int
+	level  int
+
+	lock    sync.Mutex
+	buckets [spillBuckets]*os.File
+}
+
+// memsize returns the approximate
+// number of bytes used by a
+func (a *aggtable) memsize() int {
+	return len(a.repr) + cap(a.tree.values) +
+		len(a.tree.index)*tabsize*4 + len(a.pairs)*8
+}
+
+// maybeSpill writes all of the groups in a to disk
+// and resets a if a holds more than the
+// permitted amount of memory
+func (a *aggtable) maybeSpill() error {
+	s := a.parent.spill
+	if s == nil || a.memsize() <= s.maxmem {
+		return nil
+	}
+	err := s.write(a)
+	if err != nil {
+		return err
+	}
+	a.reset()
+	return nil
+}
+
+// reset drops all of the groups in a
+func (a *aggtable) reset() {
+	a.tree = newRadixTree(len(a.parent.initialData))
+	a.repr = a.repr[:0]
+	a.pairs = a.pairs[:0]
+}
+
+// A group in a bucket is encoded as
+//
+//	hash (8 bytes, little-endian)
+//	size of the grouping columns (uvarint)
+//	grouping columns
+//	aggregate data (len(initialData) bytes)
+func (s *aggspill) write(a *aggtable) error {
+	var bufs [spillBuckets][]byte
+	columns := len(a.parent.by)
+	datasize := len(a.parent.initialData)
+	var tmp [binary.MaxVarintLen64]byte
+	var hbuf [8]byte
+	for i := range a.pairs {
+		p := &a.pairs[i]
+		hash := a.hashof(p)
+		repr := a.fullrepr(p, columns)
+		b := &bufs[spillBucket(hash, s.level)]
+		binary.LittleEndian.PutUint64(hbuf[:], hash)
+		*b = append(*b, hbuf[:]...)
+		n := binary.PutUvarint(tmp[:], uint64(len(repr)))
+		*b = append(*b, tmp[:n]...)
+		*b = append(*b, repr...)
+		*b = append(*b, a.valueof(p)[:datasize]...)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := range bufs {
+		if len(bufs[i]) == 0 {
+			continue
+		}
+		if s.buckets[i] == nil {
+			f, err := os.CreateTemp(s.dir, "hashagg-*")
+			if err != nil {
+				return fmt.Errorf("hash aggregate: %w", err)
+			}
+			// unlinked right away so that the
+			// file disappears once it is closed
+			os.Remove(f.Name())
+			s.buckets[i] = f
+		}
+		_, err := s.buckets[i].Write(bufs[i])
+		if err != nil {
+			return fmt.Errorf("hash aggregate: %w", err)
+		}
+	}
+	return nil
+}
+
+// spilled returns true if any groups
+// have been written to disk
+func (s *aggspill) spilled() bool {
+	for i := range s.buckets {
+		if s.buckets[i] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// load aggregates all of the groups in bucket i into a
+// and closes the bucket
+//
+// If the groups in the bucket take up more than the
+// permitted amount of memory, they are partitioned
+// again using the next bits of their hashes, and
+// the returned aggspill holds the new partitions
+// (and a is left empty).
+func (s *aggspill) load(i int, a *aggtable) (*aggspill, error) {
+	f := s.buckets[i]
+	if f == nil {
+		return nil, nil
+	}
+	defer func() {
+		f.Close()
+		s.buckets[i] = nil
+	}()
+	var sub *aggspill
+	err := s.read(f, a, func() error {
+		if s.level+1 >= spillLevels || len(a.pairs) < 2 || a.memsize() <= s.maxmem {
+			return nil
+		}
+		if sub == nil {
+			sub = &aggspill{dir: s.dir, maxmem: s.maxmem, level: s.level + 1}
+		}
+		err := sub.write(a)
+		a.reset()
+		return err
+	})
+	if err == nil && sub != nil {
+		err = sub.write(a)
+		a.reset()
+	}
+	if err != nil {
+		if sub != nil {
+			sub.close()
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+// read inserts each of the groups in f into a,
+// calling check after each one
+func (s *aggspill) read(f *os.File, a *aggtable, check func() error) error {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("hash aggregate: %w", err)
+	}
+	r := bufio.NewReader(f)
+	var hash [8]byte
+	var repr []byte
+	value := make([]byte, len(a.parent.initialData))
+	for {
+		_, err := io.ReadFull(r, hash[:])
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("hash aggregate: reading bucket: %w", err)
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("hash aggregate: reading bucket: %w", err)
+		}
+		if cap(repr) < int(n) {
+			repr = make([]byte, n)
+		}
+		repr = repr[:n]
+		_, err = io.ReadFull(r, repr)
+		if err == nil {
+			_, err = io.ReadFull(r, value)
+		}
+		if err != nil {
+			return fmt.Errorf("hash aggregate: reading bucket: %w", err)
+		}
+		a.insert(binary.LittleEndian.Uint64(hash[:]), repr, value)
+		if err := check(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *aggspill) close() error {
+	var err error
+	for i, f := range s.buckets {
+		if f == nil {
+			continue
+		}
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+		s.buckets[i] = nil
+	}
+	return err
+}
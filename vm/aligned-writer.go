@@ -113,3 +113,31 @@ func (a *alignedWriter) Close() error {
 	}
 	return err
 }
+
+// writeAll writes the sequence of ion values
+// in body (encoded using st) to out in aligned
+// chunks and then closes out
+func writeAll(out io.WriteCloser, st *symtab, body []byte) error {
+	var aw alignedWriter
+	aw.init(out, nil, defaultAlign)
+	if err := aw.setpre(st); err != nil {
+		aw.Close()
+		return err
+	}
+	for len(body) > 0 {
+		size := ion.SizeOf(body)
+		if aw.space() < size {
+			if _, err := aw.flush(); err != nil {
+				aw.Close()
+				return err
+			}
+			if aw.space() < size {
+				aw.Close()
+				return fmt.Errorf("row of %d bytes too large", size)
+			}
+		}
+		copy(aw.reserve(size), body[:size])
+		body = body[size:]
+	}
+	return aw.Close()
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// The list functions walk the elements of each
+// list one lane at a time (like opsplit does for
+// every lane at once); elements are compared by
+// their encoded representation after symbols
+// have been replaced with their strings.
+
+// ArrayLength computes ARRAY_LENGTH(v), which is
+// SIZE(v) for lists and MISSING otherwise
+func (p *prog) ArrayLength(v *value) *value {
+	if v.primary() != stValue {
+		return p.errorf("cannot compute the length of %s", v)
+	}
+	v = p.checkTag(v, expr.ListType)
+	return p.ssa2(sobjectsize, v, p.mask(v))
+}
+
+// arrayNeedle encodes a value to be compared
+// with list elements; floating-point values
+// that are integers are encoded as integers
+// (see jsonrl), so that they compare equal
+// to the elements produced from JSON input
+func arrayNeedle(d ion.Datum) string {
+	if f, ok := d.Float(); ok && d.Type() == ion.FloatType {
+		if i := int64(f); float64(i) == f {
+			d = ion.Int(i)
+		}
+	}
+	var buf ion.Buffer
+	var st ion.Symtab
+	d.Encode(&buf, &st)
+	return string(buf.Bytes())
+}
+
+// ArrayPosition computes the 0-based index
+// of the first element of v equal to needle;
+// lanes without such an element are MISSING
+func (p *prog) ArrayPosition(v *value, needle ion.Datum) *value {
+	l := p.tolist(v)
+	return p.ssa2imm(sArrayPosition, l, p.mask(l), arrayNeedle(needle))
+}
+
+// ArrayContains returns whether v contains an
+// element equal to needle; the result is FALSE
+// (rather than MISSING) for lists without one
+func (p *prog) ArrayContains(v *value, needle ion.Datum) *value {
+	l := p.tolist(v)
+	pos := p.ssa2imm(sArrayPosition, l, p.mask(l), arrayNeedle(needle))
+	ret := p.And(p.mask(l), pos)
+	ret.notMissing = p.mask(l)
+	return ret
+}
+
+// ArraySlice computes the list of elements
+// of v from index from up to (but not including)
+// index to, or up to the end of the list if to < 0
+func (p *prog) ArraySlice(v *value, from, to int) *value {
+	l := p.tolist(v)
+	end := uint32(math.MaxUint32)
+	if to >= 0 {
+		end = uint32(to)
+	}
+	var enc [8]byte
+	binary.LittleEndian.PutUint32(enc[:], uint32(from))
+	binary.LittleEndian.PutUint32(enc[4:], end)
+	return p.ssa2imm(sArraySlice, l, p.mask(l), string(enc[:]))
+}
+
+// ArrayDistinct computes the list of the distinct
+// elements of v in the order of their first occurrence
+func (p *prog) ArrayDistinct(v *value) *value {
+	l := p.tolist(v)
+	return p.ssa2(sArrayDistinct, l, p.mask(l))
+}
+
+// ArrayJoin concatenates the strings in v
+// separated by sep; elements that are not
+// strings are ignored
+func (p *prog) ArrayJoin(v *value, sep string) *value {
+	l := p.tolist(v)
+	return p.ssa2imm(sArrayJoin, l, p.mask(l), sep)
+}
@@ -0,0 +1,326 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// ARRAY_AGG collects values into a fixed-size
+// buffer, so (like every other aggregate) it uses
+// a fixed amount of memory per group. Each value
+// is stored alongside a float64 ordering key;
+// once the buffer is full or holds LIMIT values,
+// a new value is only retained if its key is
+// smaller than the largest key in the buffer,
+// in which case the value with the largest key
+// is evicted. Values that are too large to ever
+// fit in the buffer are ignored.
+//
+// The keys of ARRAY_AGG(x ORDER BY y DESC) are
+// the negated values of y, so the buffer always
+// retains the values that are ordered first.
+// When there is no ORDER BY clause, all of the
+// keys are zero and the buffer retains the first
+// values that are added to it.
+//
+// The layout of the state is
+//
+//	[0:4]   the limit (uint32; never merged)
+//	[4:8]   flags (uint32; never merged)
+//	[8:12]  the number of entries (uint32)
+//	[12:16] the number of bytes of entries (uint32)
+//	[16:]   entries
+//
+// and the layout of each entry is
+//
+//	[0:8]   the ordering key (float64)
+//	[8:12]  the size of the value (uint32)
+//	[12:]   the ion-encoded value
+//
+// Partial results are serialized as the state
+// truncated to the entries in use.
+const (
+	arrayAggSize        = 2048
+	arrayAggHeaderSize  = 16
+	arrayAggCapacity    = arrayAggSize - arrayAggHeaderSize
+	arrayAggEntryHeader = 12
+
+	// arrayAggDistinct is set in the flags
+	// of ARRAY_AGG(DISTINCT ...) states
+	arrayAggDistinct = 1
+)
+
+// arrayAggLocked is stored in place of the
+// number of entries and bytes while the state
+// is being merged atomically
+const arrayAggLocked = ^uint64(0)
+
+// arrayAggKind returns the aggregate kind of
+// the ARRAY_AGG op, and whether op merges
+// partial states (as opposed to values)
+func arrayAggKind(op expr.AggregateOp) (kind AggregateKind, merge bool, ok bool) {
+	switch op {
+	case expr.OpArrayAgg, expr.OpArrayAggDistinct:
+		return AggregateKindArrayAgg, false, true
+	case expr.OpArrayAggPartial, expr.OpArrayAggDistinctPartial:
+		return AggregateKindArrayAggPartial, false, true
+	case expr.OpArrayAggMerge, expr.OpArrayAggDistinctMerge:
+		return AggregateKindArrayAgg, true, true
+	}
+	return 0, false, false
+}
+
+// compileArrayAggArgs compiles the value and the
+// ordering key of ARRAY_AGG; the key of a row
+// where the ORDER BY column is not a number or
+// a timestamp is ordered according to NULLS FIRST
+// or NULLS LAST
+func compileArrayAggArgs(p *prog, agg *expr.Aggregate) (val, key *value, err error) {
+	val, err = p.serialized(agg.Inner)
+	if err != nil {
+		return nil, nil, fmt.Errorf("don't know how to aggregate %q: %w", agg.Inner, err)
+	}
+	val = p.unsymbolized(val)
+	if len(agg.OrderBy) == 0 {
+		return val, p.ssa0imm(sbroadcastf, 0.0), nil
+	}
+	if len(agg.OrderBy) > 1 {
+		return nil, nil, fmt.Errorf("%s: cannot order by more than one column", expr.ToString(agg))
+	}
+	order := &agg.OrderBy[0]
+	col, err := compile(p, order.Column)
+	if err != nil {
+		return nil, nil, fmt.Errorf("don't know how to order by %q: %w", order.Column, err)
+	}
+	// timestamps are ordered by their
+	// (exactly representable) microseconds
+	var keyk *value
+	switch {
+	case col.op == sliteral:
+		key, keyk = p.coercefp(col)
+	case col.primary() == stTime || col.primary() == stTimeInt:
+		micros := p.DateToUnixMicro(col)
+		key = p.ssa2(scvtitof, micros, p.mask(micros))
+		keyk = p.mask(micros)
+	case col.primary() == stValue:
+		key, keyk = p.coercefp(col)
+		micros := p.DateToUnixMicro(col)
+		ts := p.ssa2(scvtitof, micros, p.mask(micros))
+		key = p.ssa3(sblendfloat, key, ts, p.mask(micros))
+		keyk = p.Or(keyk, p.mask(micros))
+	default:
+		key, keyk = p.coercefp(col)
+	}
+	if order.Desc {
+		key = p.ssa2(snegf, key, keyk)
+	}
+	missing := math.Inf(-1)
+	if order.NullsLast {
+		missing = math.Inf(1)
+	}
+	key = p.ssa3(sblendfloat, p.ssa0imm(sbroadcastf, missing), key, keyk)
+	return val, key, nil
+}
+
+// initArrayAggs initializes the limits and flags
+// of every ARRAY_AGG state in data, which must
+// have already been initialized with initAggregateValues
+func initArrayAggs(data []byte, agg Aggregation, kinds []AggregateKind) {
+	offset := 0
+	for i, kind := range kinds {
+		if kind == AggregateKindArrayAgg || kind == AggregateKindArrayAggPartial {
+			limit := uint32(math.MaxUint32)
+			if n, ok := agg[i].Expr.Limit(); ok {
+				limit = uint32(n)
+			}
+			flags := uint32(0)
+			switch agg[i].Expr.Op {
+			case expr.OpArrayAggDistinct, expr.OpArrayAggDistinctPartial, expr.OpArrayAggDistinctMerge:
+				flags |= arrayAggDistinct
+			}
+			binary.LittleEndian.PutUint32(data[offset:], limit)
+			binary.LittleEndian.PutUint32(data[offset+4:], flags)
+		}
+		offset += int(aggregateKindInfoTable[kind].dataSize)
+	}
+}
+
+// arrayAggEntries calls fn for each entry in
+// the state (or the serialized partial state) buf,
+// or returns false if buf is not a valid state
+func arrayAggEntries(buf []byte, fn func(key float64, val []byte)) bool {
+	if len(buf) < arrayAggHeaderSize {
+		return false
+	}
+	used := int(binary.LittleEndian.Uint32(buf[12:]))
+	if used > len(buf)-arrayAggHeaderSize || used > arrayAggCapacity {
+		return false
+	}
+	entries := buf[arrayAggHeaderSize : arrayAggHeaderSize+used]
+	for len(entries) > 0 {
+		if len(entries) < arrayAggEntryHeader {
+			return false
+		}
+		key := math.Float64frombits(binary.LittleEndian.Uint64(entries))
+		size := int(binary.LittleEndian.Uint32(entries[8:]))
+		if size > len(entries)-arrayAggEntryHeader {
+			return false
+		}
+		fn(key, entries[arrayAggEntryHeader:arrayAggEntryHeader+size])
+		entries = entries[arrayAggEntryHeader+size:]
+	}
+	return true
+}
+
+// arrayAggInsert adds a value with the given
+// ordering key to the state in buf
+//
+// (this is the same computation that is
+// performed by the bytecode aggregate ops)
+func arrayAggInsert(buf []byte, key float64, val []byte) {
+	_ = buf[:arrayAggSize]
+	size := arrayAggEntryHeader + len(val)
+	if size > arrayAggCapacity {
+		return
+	}
+	limit := binary.LittleEndian.Uint32(buf)
+	entries := buf[arrayAggHeaderSize:]
+	if binary.LittleEndian.Uint32(buf[4:])&arrayAggDistinct != 0 {
+		used := int(binary.LittleEndian.Uint32(buf[12:]))
+		for off := 0; off < used; {
+			n := int(binary.LittleEndian.Uint32(entries[off+8:]))
+			if bytes.Equal(entries[off+arrayAggEntryHeader:off+arrayAggEntryHeader+n], val) {
+				// keep the value ordered first
+				if key < math.Float64frombits(binary.LittleEndian.Uint64(entries[off:])) {
+					binary.LittleEndian.PutUint64(entries[off:], math.Float64bits(key))
+				}
+				return
+			}
+			off += arrayAggEntryHeader + n
+		}
+	}
+	for {
+		count := binary.LittleEndian.Uint32(buf[8:])
+		used := int(binary.LittleEndian.Uint32(buf[12:]))
+		if count < limit && used+size <= arrayAggCapacity {
+			binary.LittleEndian.PutUint64(entries[used:], math.Float64bits(key))
+			binary.LittleEndian.PutUint32(entries[used+8:], uint32(len(val)))
+			copy(entries[used+arrayAggEntryHeader:], val)
+			binary.LittleEndian.PutUint32(buf[8:], count+1)
+			binary.LittleEndian.PutUint32(buf[12:], uint32(used+size))
+			return
+		}
+		if count == 0 {
+			return
+		}
+		// find the last entry with the largest key
+		worst := 0
+		for off := 0; off < used; {
+			if math.Float64frombits(binary.LittleEndian.Uint64(entries[off:])) >=
+				math.Float64frombits(binary.LittleEndian.Uint64(entries[worst:])) {
+				worst = off
+			}
+			off += arrayAggEntryHeader + int(binary.LittleEndian.Uint32(entries[off+8:]))
+		}
+		if !(key < math.Float64frombits(binary.LittleEndian.Uint64(entries[worst:]))) {
+			return
+		}
+		wsize := arrayAggEntryHeader + int(binary.LittleEndian.Uint32(entries[worst+8:]))
+		copy(entries[worst:], entries[worst+wsize:used])
+		binary.LittleEndian.PutUint32(buf[8:], count-1)
+		binary.LittleEndian.PutUint32(buf[12:], uint32(used-wsize))
+	}
+}
+
+// arrayAggMerge merges the state (or the
+// serialized partial state) in src into dst
+func arrayAggMerge(dst, src []byte) {
+	arrayAggEntries(src, func(key float64, val []byte) {
+		arrayAggInsert(dst, key, val)
+	})
+}
+
+// arrayAggMergeAtomically is equivalent to
+// arrayAggMerge, but dst may be updated concurrently
+//
+// dst must be 8-byte aligned
+func arrayAggMergeAtomically(dst, src []byte) {
+	_ = dst[:arrayAggSize]
+	if binary.LittleEndian.Uint32(src[8:]) == 0 {
+		return
+	}
+	ptr := (*uint64)(unsafe.Pointer(&dst[8]))
+	var sizes uint64
+	for {
+		sizes = atomic.LoadUint64(ptr)
+		if sizes != arrayAggLocked && atomic.CompareAndSwapUint64(ptr, sizes, arrayAggLocked) {
+			break
+		}
+		runtime.Gosched()
+	}
+	var tmp [arrayAggSize]byte
+	copy(tmp[:], dst)
+	binary.LittleEndian.PutUint64(tmp[8:], sizes)
+	arrayAggMerge(tmp[:], src)
+	copy(dst[arrayAggHeaderSize:], tmp[arrayAggHeaderSize:])
+	atomic.StoreUint64(ptr, binary.LittleEndian.Uint64(tmp[8:]))
+}
+
+// writeArrayAgg writes the values in the
+// state in data as a list ordered by their
+// keys, or NULL if there are no values
+func writeArrayAgg(b *ion.Buffer, data []byte) {
+	type entry struct {
+		key float64
+		val []byte
+	}
+	var lst []entry
+	arrayAggEntries(data[:arrayAggSize], func(key float64, val []byte) {
+		lst = append(lst, entry{key: key, val: val})
+	})
+	if len(lst) == 0 {
+		b.WriteNull()
+		return
+	}
+	// entries with equal keys are
+	// kept in the order they were added
+	slices.SortStableFunc(lst, func(a, b entry) bool {
+		return a.key < b.key
+	})
+	b.BeginList(-1)
+	for i := range lst {
+		b.UnsafeAppend(lst[i].val)
+	}
+	b.EndList()
+}
+
+// arrayAggPartial returns the serialized
+// partial state of the state in data
+func arrayAggPartial(data []byte) []byte {
+	used := binary.LittleEndian.Uint32(data[12:])
+	return data[:arrayAggHeaderSize+used]
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func newArrayAgg(limit uint32, flags uint32) []byte {
+	buf := make([]byte, arrayAggSize)
+	binary.LittleEndian.PutUint32(buf, limit)
+	binary.LittleEndian.PutUint32(buf[4:], flags)
+	return buf
+}
+
+func arrayAggUints(t *testing.T, buf []byte) []uint64 {
+	var b ion.Buffer
+	writeArrayAgg(&b, buf)
+	d, _, err := ion.ReadDatum(nil, b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Null() {
+		return nil
+	}
+	lst, ok := d.List()
+	if !ok {
+		t.Fatalf("unexpected result %v", d)
+	}
+	var out []uint64
+	err = lst.Each(func(d ion.Datum) bool {
+		u, ok := d.Uint()
+		if !ok {
+			t.Fatalf("unexpected value %v", d)
+		}
+		out = append(out, u)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func ionUint(u uint64) []byte {
+	var b ion.Buffer
+	b.WriteUint(u)
+	return b.Bytes()
+}
+
+func TestArrayAggLimit(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	values := r.Perm(1000)
+
+	// insert the values in two halves
+	// to exercise merging
+	const limit = 10
+	left := newArrayAgg(limit, 0)
+	right := newArrayAgg(limit, 0)
+	for i, v := range values {
+		// ORDER BY v DESC
+		if i&1 == 0 {
+			arrayAggInsert(left, -float64(v), ionUint(uint64(v)))
+		} else {
+			arrayAggInsert(right, -float64(v), ionUint(uint64(v)))
+		}
+	}
+	arrayAggMerge(left, arrayAggPartial(right))
+	got := arrayAggUints(t, left)
+	want := []uint64{999, 998, 997, 996, 995, 994, 993, 992, 991, 990}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestArrayAggCapacity(t *testing.T) {
+	buf := newArrayAgg(math.MaxUint32, 0)
+	var b ion.Buffer
+	for i := 0; i < 1000; i++ {
+		b.Reset()
+		b.WriteString("a long string that takes up some room")
+		arrayAggInsert(buf, float64(1000-i), b.Bytes())
+	}
+	used := int(binary.LittleEndian.Uint32(buf[12:]))
+	count := int(binary.LittleEndian.Uint32(buf[8:]))
+	if used > arrayAggCapacity {
+		t.Fatalf("%d bytes used", used)
+	}
+	entry := arrayAggEntryHeader + len(b.Bytes())
+	if count != arrayAggCapacity/entry {
+		t.Fatalf("got %d entries, want %d", count, arrayAggCapacity/entry)
+	}
+	// the most recent values have the smallest keys
+	var keys []float64
+	if !arrayAggEntries(buf, func(key float64, val []byte) {
+		keys = append(keys, key)
+	}) {
+		t.Fatal("invalid state")
+	}
+	sort.Float64s(keys)
+	if keys[0] != 1 || keys[len(keys)-1] != float64(count) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	// values that can never fit are ignored
+	huge := make([]byte, arrayAggSize)
+	arrayAggInsert(buf, -1, huge)
+	if n := int(binary.LittleEndian.Uint32(buf[8:])); n != count {
+		t.Fatalf("got %d entries after inserting a huge value", n)
+	}
+}
+
+func TestArrayAggDistinct(t *testing.T) {
+	dst := newArrayAgg(2, arrayAggDistinct)
+	src := newArrayAgg(2, arrayAggDistinct)
+	arrayAggInsert(dst, 5, ionUint(1))
+	arrayAggInsert(dst, 6, ionUint(2))
+	arrayAggInsert(dst, 7, ionUint(1))
+	arrayAggInsert(src, 4, ionUint(2))
+	arrayAggInsert(src, 3, ionUint(3))
+	arrayAggMergeAtomically(dst, arrayAggPartial(src))
+	got := arrayAggUints(t, dst)
+	if len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Fatalf("got %v, want [3 2]", got)
+	}
+	empty := newArrayAgg(2, arrayAggDistinct)
+	if got := arrayAggUints(t, empty); got != nil {
+		t.Fatalf("got %v for an empty state", got)
+	}
+}
@@ -453,9 +453,9 @@ CONST_DATA_U64(constpool, CONSTPOOL_RECIPROCALS_INDEX + 72, $18764999)
 #define CONSTQ_1441151881() CONST_GET_PTR(constpool, CONSTPOOL_RECIPROCALS_INDEX + 80)
 CONST_DATA_U64(constpool, CONSTPOOL_RECIPROCALS_INDEX + 80, $1441151881)
 
-// Unsigned 44-bit division by 3600000000 => Result = (Value >> 12) * 2562048517 >> 51
-#define CONSTQ_2562048517() CONST_GET_PTR(constpool, CONSTPOOL_RECIPROCALS_INDEX + 88)
-CONST_DATA_U64(constpool, CONSTPOOL_RECIPROCALS_INDEX + 88, $2562048517)
+// Unsigned 37-bit division by 3600000000 => Result = (Value >> 9) * 2562047789 >> 54
+#define CONSTQ_2562047789() CONST_GET_PTR(constpool, CONSTPOOL_RECIPROCALS_INDEX + 88)
+CONST_DATA_U64(constpool, CONSTPOOL_RECIPROCALS_INDEX + 88, $2562047789)
 
 
 // 64-Bit Floating Point Constants
@@ -532,6 +532,9 @@ CONST_DATA_U64(constpool, CONSTPOOL_F64_INDEX + 152, $0xbfefff2e48e8a71e)
 #define CONSTF64_12742000() CONST_GET_PTR(constpool, CONSTPOOL_F64_INDEX + 160)
 CONST_DATA_U64(constpool, CONSTPOOL_F64_INDEX + 160, $0x41684dae00000000)
 
+#define CONSTF64_7() CONST_GET_PTR(constpool, CONSTPOOL_F64_INDEX + 168)
+CONST_DATA_U64(constpool, CONSTPOOL_F64_INDEX + 168, $0x401c000000000000)
+
 
 // Other Constants
 // ---------------
@@ -342,6 +342,7 @@ var opinfo = [_maxbcop]bcopinfo{
 	opdateextractday:         {text: "dateextractday", flags: bcReadK | bcReadWriteS},
 	opdateextractmonth:       {text: "dateextractmonth", flags: bcReadK | bcReadWriteS},
 	opdateextractyear:        {text: "dateextractyear", flags: bcReadK | bcReadWriteS},
+	opdateextractdow:         {text: "dateextractdow", flags: bcReadK | bcReadWriteS},
 	opdatetounixepoch:        {text: "datetounixepoch", flags: bcReadK | bcReadWriteS},
 	opdatetruncmillisecond:   {text: "datetruncmillisecond", flags: bcReadK | bcReadWriteS},
 	opdatetruncsecond:        {text: "datetruncsecond", flags: bcReadK | bcReadWriteS},
@@ -352,6 +353,9 @@ var opinfo = [_maxbcop]bcopinfo{
 	opdatetruncyear:          {text: "datetruncyear", flags: bcReadK | bcReadWriteS},
 	opunboxts:                {text: "unboxts", flags: bcReadK | bcWriteS},
 	opboxts:                  {text: "boxts", flags: bcReadK | bcReadS},
+	opparsets:                {text: "parsets", imms: bcImmsDict, flags: bcReadWriteK | bcReadWriteS},
+	opformatts:               {text: "formatts", imms: bcImmsDict, flags: bcReadWriteK | bcReadWriteS},
+	opconvtz:                 {text: "convtz", imms: bcImmsDict, flags: bcReadK | bcReadWriteS},
 	opconsttm:                {text: "consttm", imms: bcImmsDict, flags: bcReadWriteS},
 	optimelt:                 {text: "timelt", flags: bcReadWriteK | bcReadS},
 	optimegt:                 {text: "timegt", flags: bcReadWriteK | bcReadS},
@@ -437,6 +441,18 @@ var opinfo = [_maxbcop]bcopinfo{
 	opaggxori:  {text: "aggxor.i", imms: bcImmsS16, flags: bcReadK | bcReadS},
 	opaggcount: {text: "aggcount", imms: bcImmsS16, flags: bcReadK},
 
+	opaggapproxcount:      {text: "aggapproxcount", imms: bcImmsS16S16, flags: bcReadK | bcReadH},
+	opaggapproxcountmerge: {text: "aggapproxcountmerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
+	opaggapproxpercentile:      {text: "aggapproxpercentile", imms: bcImmsS16, flags: bcReadK | bcReadS},
+	opaggapproxpercentilemerge: {text: "aggapproxpercentilemerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
+	opaggmoments:      {text: "aggmoments", imms: bcImmsS16S16, flags: bcReadK | bcReadS},
+	opaggmomentsmerge: {text: "aggmomentsmerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
+	opaggarray:      {text: "aggarray", imms: bcImmsS16S16, flags: bcReadK | bcReadV},
+	opaggarraymerge: {text: "aggarraymerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
 	// Slot aggregate operations
 	opaggbucket:    {text: "aggbucket", imms: bcImmsS16, flags: bcReadK | bcWriteS | bcReadH},
 	opaggslotandk:  {text: "aggslotand.k", imms: bcImmsS16S16, flags: bcReadK},
@@ -454,6 +470,18 @@ var opinfo = [_maxbcop]bcopinfo{
 	opaggslotxori:  {text: "aggslotxor.i", imms: bcImmsS16, flags: bcReadK | bcReadS},
 	opaggslotcount: {text: "aggslotcount", imms: bcImmsS16, flags: bcReadK},
 
+	opaggslotapproxcount:      {text: "aggslotapproxcount", imms: bcImmsS16S16, flags: bcReadK | bcReadH},
+	opaggslotapproxcountmerge: {text: "aggslotapproxcountmerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
+	opaggslotapproxpercentile:      {text: "aggslotapproxpercentile", imms: bcImmsS16, flags: bcReadK | bcReadS},
+	opaggslotapproxpercentilemerge: {text: "aggslotapproxpercentilemerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
+	opaggslotmoments:      {text: "aggslotmoments", imms: bcImmsS16S16, flags: bcReadK | bcReadS},
+	opaggslotmomentsmerge: {text: "aggslotmomentsmerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
+	opaggslotarray:      {text: "aggslotarray", imms: bcImmsS16S16, flags: bcReadK | bcReadV},
+	opaggslotarraymerge: {text: "aggslotarraymerge", imms: bcImmsS16, flags: bcReadK | bcReadS},
+
 	// Uncategorized instructions
 	oplitref:     {text: "litref", imms: bcImmsH32H32, flags: bcWriteV},
 	opsplit:      {text: "split", flags: bcReadWriteK | bcReadWriteS | bcWriteV}, // split a list into head and tail components
@@ -462,6 +490,12 @@ var opinfo = [_maxbcop]bcopinfo{
 	opzerov:      {text: "zero.v", imms: bcImmsS16, flags: 0},   // zeroes all values in a slot
 	opobjectsize: {text: "objectsize", flags: bcReadWriteK | bcWriteS | bcReadV},
 
+	// list functions
+	oparrayposition: {text: "arrayposition", imms: bcImmsDict, flags: bcReadWriteK | bcReadWriteS},
+	oparrayslice:    {text: "arrayslice", imms: bcImmsDict, flags: bcReadWriteK | bcReadS | bcWriteV},
+	oparraydistinct: {text: "arraydistinct", flags: bcReadWriteK | bcReadS | bcWriteV},
+	oparrayjoin:     {text: "arrayjoin", imms: bcImmsDict, flags: bcReadWriteK | bcReadWriteS},
+
 	// string comparing operations
 	opCmpStrEqCs:     {text: "cmp_str_eq_cs", imms: bcImmsDict, flags: bcReadS | bcReadWriteK},
 	opCmpStrEqCi:     {text: "cmp_str_eq_ci", imms: bcImmsDict, flags: bcReadS | bcReadWriteK},
@@ -495,6 +529,9 @@ var opinfo = [_maxbcop]bcopinfo{
 
 	// ip matching operations
 	opIsSubnetOfIP4: {text: "is_subnet_of_ip4", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
+	opIsSubnetOfIP6: {text: "is_subnet_of_ip6", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
+	opinetparse:     {text: "inetparse", imms: bcImmsDict, flags: bcReadWriteK | bcReadWriteS},
+	opinetfamily:    {text: "inetfamily", flags: bcReadWriteK | bcReadWriteS},
 
 	// char skipping
 	opSkip1charLeft:  {text: "skip_1char_left", flags: bcReadWriteK | bcReadWriteS},
@@ -506,6 +543,14 @@ var opinfo = [_maxbcop]bcopinfo{
 	opSubstr:    {text: "substr", imms: bcImmsS16S16, flags: bcReadK | bcReadWriteS},
 	opSplitPart: {text: "split_part", imms: bcImmsDictS16, flags: bcReadWriteK | bcReadWriteS},
 
+	opStrPos:     {text: "strpos", imms: bcImmsDict, flags: bcReadK | bcReadWriteS},
+	opStrReplace: {text: "strreplace", imms: bcImmsDict, flags: bcReadK | bcReadWriteS},
+	opStrReverse: {text: "strreverse", flags: bcReadK | bcReadWriteS},
+	opStrInitcap: {text: "strinitcap", flags: bcReadK | bcReadWriteS},
+	opStrRepeat:  {text: "strrepeat", imms: bcImmsS16, flags: bcReadK | bcReadWriteS},
+	opStrLpad:    {text: "strlpad", imms: bcImmsDictS16, flags: bcReadK | bcReadWriteS},
+	opStrRpad:    {text: "strrpad", imms: bcImmsDictS16, flags: bcReadK | bcReadWriteS},
+
 	opDfaT6:  {text: "dfa_tiny6", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
 	opDfaT7:  {text: "dfa_tiny7", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
 	opDfaT8:  {text: "dfa_tiny8", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
@@ -515,6 +560,10 @@ var opinfo = [_maxbcop]bcopinfo{
 	opDfaL:   {text: "dfa_large", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
 	opDfaLZ:  {text: "dfa_largeZ", imms: bcImmsDict, flags: bcReadWriteK | bcReadS},
 
+	opregexpextract: {text: "regexp_extract", imms: bcImmsDict, flags: bcReadWriteK | bcReadWriteS},
+	opregexpcount:   {text: "regexp_count", imms: bcImmsDict, flags: bcReadK | bcReadWriteS},
+	opregexpreplace: {text: "regexp_replace", imms: bcImmsDict, flags: bcReadK | bcReadWriteS},
+
 	opslower:      {text: "slower", imms: bcImmsS16, flags: bcReadWriteK | bcReadWriteS},
 	opsupper:      {text: "supper", imms: bcImmsS16, flags: bcReadWriteK | bcReadWriteS},
 	opsadjustsize: {text: "saddjustsize", flags: bcReadWriteS},
@@ -0,0 +1,67 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// TestDatePartsBF compares the week, quarter,
+// and day-of-week date parts with package time
+// for random timestamps
+func TestDatePartsBF(t *testing.T) {
+	const rows = 1000
+	r := rand.New(rand.NewSource(0))
+	var st ion.Symtab
+	var in, out []ion.Datum
+	for i := 0; i < rows; i++ {
+		// years 1970..2100
+		us := r.Int63n(4102444800000000)
+		in = append(in, ion.NewStruct(&st, []ion.Field{
+			{Label: "t", Value: ion.Timestamp(date.UnixMicro(us))},
+		}).Datum())
+
+		t := time.UnixMicro(us).UTC()
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		isoyear, isoweek := t.ISOWeek()
+		monday := day.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		jan4 := time.Date(isoyear, 1, 4, 0, 0, 0, 0, time.UTC)
+		ts := func(t time.Time) ion.Datum { return ion.Timestamp(date.FromTime(t)) }
+		out = append(out, ion.NewStruct(&st, []ion.Field{
+			{Label: "q", Value: ion.Int((int64(t.Month())-1)/3 + 1)},
+			{Label: "w", Value: ion.Int(int64(t.YearDay()+6-int(t.Weekday())) / 7)},
+			{Label: "iw", Value: ion.Int(int64(isoweek))},
+			{Label: "iy", Value: ion.Int(int64(isoyear))},
+			{Label: "dow", Value: ion.Int(int64(t.Weekday()))},
+			{Label: "doy", Value: ion.Int(int64(t.YearDay()))},
+			{Label: "tq", Value: ts(time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC))},
+			{Label: "tw", Value: ts(day.AddDate(0, 0, -int(t.Weekday())))},
+			{Label: "tiw", Value: ts(monday)},
+			{Label: "tiy", Value: ts(jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7)))},
+		}).Datum())
+	}
+	query := `SELECT EXTRACT(QUARTER FROM t) AS q, EXTRACT(WEEK FROM t) AS w,
+EXTRACT(ISOWEEK FROM t) AS iw, EXTRACT(ISOYEAR FROM t) AS iy,
+EXTRACT(DOW FROM t) AS dow, EXTRACT(DOY FROM t) AS doy,
+DATE_TRUNC(QUARTER, t) AS tq, DATE_TRUNC(WEEK, t) AS tw,
+DATE_TRUNC(ISOWEEK, t) AS tiw, DATE_TRUNC(ISOYEAR, t) AS tiy
+FROM input`
+	testInput(t, []byte(query), &st, [][]ion.Datum{in}, out)
+}
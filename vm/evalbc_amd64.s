@@ -7191,9 +7191,9 @@ TEXT bcdateextracthour(SB), NOSPLIT|NOFRAME, $0
   KSHIFTRW $8, K1, K2
   VPBROADCASTQ CONSTQ_86400000000(), Z4
   BC_MODU64_IMPL(Z2, Z3, Z2, Z3, Z4, Z4, K1, K2, Z6, Z7, Z8, Z9, Z10, Z11, K3, K4)
-  VPSRLQ $12, Z2, K1, Z2
-  VPSRLQ $12, Z3, K2, Z3
-  BC_DIV_U64_WITH_CONST_RECIPROCAL_BCST_MASKED(Z2, Z3, Z2, Z3, K1, K2, CONSTQ_2562048517(), 51)
+  VPSRLQ $9, Z2, K1, Z2
+  VPSRLQ $9, Z3, K2, Z3
+  BC_DIV_U64_WITH_CONST_RECIPROCAL_BCST_MASKED(Z2, Z3, Z2, Z3, K1, K2, CONSTQ_2562047789(), 54)
   NEXT()
 
 // EXTRACT(DAY FROM timestamp)
@@ -7246,6 +7246,47 @@ TEXT bcdateextractyear(SB), NOSPLIT|NOFRAME, $0
   VMOVDQA64 Z9, K2, Z3
   NEXT()
 
+// EXTRACT(DOW FROM timestamp) - the day of the week, where 0 is Sunday
+TEXT bcdateextractdow(SB), NOSPLIT|NOFRAME, $0
+  KSHIFTRW $8, K1, K2
+
+  // Z4/Z5 <- Number of days since 0000-03-01 (see BC_DECOMPOSE_TIMESTAMP_PARTS).
+  VPSRAQ $13, Z2, Z4
+  VPSRAQ $13, Z3, Z5
+  VPADDQ.BCST CONSTQ_1970_01_01_TO_0000_03_01_US_OFFSET_SHR_13(), Z4, Z4
+  VPADDQ.BCST CONSTQ_1970_01_01_TO_0000_03_01_US_OFFSET_SHR_13(), Z5, Z5
+  VCVTUQQ2PD Z4, Z4
+  VCVTUQQ2PD Z5, Z5
+  VBROADCASTSD CONSTF64_MICROSECONDS_IN_1_DAY_SHR_13(), Z6
+  VDIVPD.RD_SAE Z6, Z4, Z4
+  VDIVPD.RD_SAE Z6, Z5, Z5
+  VRNDSCALEPD $(VROUND_IMM_DOWN | VROUND_IMM_SUPPRESS), Z4, Z4
+  VRNDSCALEPD $(VROUND_IMM_DOWN | VROUND_IMM_SUPPRESS), Z5, Z5
+  VCVTPD2UQQ Z4, Z4
+  VCVTPD2UQQ Z5, Z5
+
+  // Z4/Z5 <- Days + 3, as 0000-03-01 was a Wednesday.
+  VPADDQ.BCST CONSTQ_3(), Z4, Z4
+  VPADDQ.BCST CONSTQ_3(), Z5, Z5
+
+  // Z6/Z7 <- floor(days / 7) * 7
+  VCVTUQQ2PD Z4, Z6
+  VCVTUQQ2PD Z5, Z7
+  VBROADCASTSD CONSTF64_7(), Z8
+  VDIVPD.RD_SAE Z8, Z6, Z6
+  VDIVPD.RD_SAE Z8, Z7, Z7
+  VRNDSCALEPD $(VROUND_IMM_DOWN | VROUND_IMM_SUPPRESS), Z6, Z6
+  VRNDSCALEPD $(VROUND_IMM_DOWN | VROUND_IMM_SUPPRESS), Z7, Z7
+  VCVTPD2UQQ Z6, Z6
+  VCVTPD2UQQ Z7, Z7
+  VPMULLQ.BCST CONSTQ_7(), Z6, Z6
+  VPMULLQ.BCST CONSTQ_7(), Z7, Z7
+
+  // Z2/Z3 <- Days modulo 7.
+  VPSUBQ Z6, Z4, K1, Z2
+  VPSUBQ Z7, Z5, K2, Z3
+  NEXT()
+
 TEXT bcdatetounixepoch(SB), NOSPLIT|NOFRAME, $0
   KSHIFTRW $8, K1, K2
 
@@ -7371,6 +7412,60 @@ TEXT bcdatetruncyear(SB), NOSPLIT|NOFRAME, $0
 
   NEXT()
 
+// timestamp AT TIME ZONE zone
+//
+// adds to each timestamp the offset of the last
+// table entry that starts at or before the timestamp
+//
+// the table (see timeZoneTable) is the number of search
+// steps N followed by 1<<N sorted start times and 1<<N offsets,
+// and the first start time is always math.MinInt64
+TEXT bcconvtz(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R8)
+  MOVQ (R8), R8                     // R8 = table
+  MOVQ (R8), CX                     // CX = search steps
+  ADDQ $8, R8                       // R8 = start times
+  MOVL $1, DX
+  SHLQ CX, DX                       // DX = number of entries
+  KSHIFTRW $8, K1, K2
+
+  // Z4/Z5 <- Index of the last entry that starts at or before the timestamp.
+  VPXORQ Z4, Z4, Z4
+  VPXORQ Z5, Z5, Z5
+  MOVQ DX, BX
+  TESTQ CX, CX
+  JZ offsets
+search:
+  SHRQ $1, BX
+  VPBROADCASTQ BX, Z6
+  VPADDQ Z6, Z4, Z8
+  VPADDQ Z6, Z5, Z9
+  KMOVW K1, K3
+  KMOVW K2, K4
+  VPXORQ Z10, Z10, Z10
+  VPXORQ Z11, Z11, Z11
+  VPGATHERQQ 0(R8)(Z8*8), K3, Z10
+  VPGATHERQQ 0(R8)(Z9*8), K4, Z11
+  VPCMPQ $VPCMP_IMM_LE, Z2, Z10, K1, K3
+  VPCMPQ $VPCMP_IMM_LE, Z3, Z11, K2, K4
+  VMOVDQA64 Z8, K3, Z4
+  VMOVDQA64 Z9, K4, Z5
+  DECQ CX
+  JNZ search
+
+offsets:
+  // Z2/Z3 <- Timestamp + offset.
+  LEAQ 0(R8)(DX*8), R8              // R8 = offsets
+  KMOVW K1, K3
+  KMOVW K2, K4
+  VPXORQ Z10, Z10, Z10
+  VPXORQ Z11, Z11, Z11
+  VPGATHERQQ 0(R8)(Z4*8), K3, Z10
+  VPGATHERQQ 0(R8)(Z5*8), K4, Z11
+  VPADDQ Z10, Z2, K1, Z2
+  VPADDQ Z11, Z3, K2, Z3
+  NEXT()
+
 TEXT bcunboxts(SB), NOSPLIT|NOFRAME, $0
   // TernLog:
   //   VPTERNLOG(0xD8) == (A & ~C) | (B & C) == Blend(A, B, ~C)
@@ -7463,7 +7558,7 @@ TEXT bcunboxts(SB), NOSPLIT|NOFRAME, $0
   VPMADDWD Z19, Z5, Z5
 
   // Z18 <- Load last 4 bytes of the timestamp if it contains microseconds.
-  VPCMPD.BCST $VPCMP_IMM_GT, CONSTD_10(), Z3, K3
+  VPCMPD.BCST $VPCMP_IMM_GT, CONSTD_10(), Z3, K1, K3
   VPADDD Z2, Z3, Z19
   VPXORD X18, X18, X18
   VPGATHERDD -4(SI)(Z19*1), K3, Z18
@@ -7632,9 +7727,9 @@ TEXT bcboxts(SB), NOSPLIT|NOFRAME, $0
   //   - Microsecond [0, 999999] (1 byte for fraction_exponent 0xC6, 3 bytes for coefficient - UInt)
 
   // Z8/Z9 - Hour [0, 23].
-  VPSRLQ $12, Z4, Z8
-  VPSRLQ $12, Z5, Z9
-  BC_DIV_U64_WITH_CONST_RECIPROCAL_BCST(Z8, Z9, Z8, Z9, CONSTQ_2562048517(), 51)
+  VPSRLQ $9, Z4, Z8
+  VPSRLQ $9, Z5, Z9
+  BC_DIV_U64_WITH_CONST_RECIPROCAL_BCST(Z8, Z9, Z8, Z9, CONSTQ_2562047789(), 54)
 
   // Z4/Z5 - (Minutes * 60000000) + (Second * 1000000) + Microseconds.
   VPMULLQ.BCST CONSTQ_3600000000(), Z8, Z12
@@ -10853,6 +10948,533 @@ TEXT bcaggcount(SB), NOSPLIT|NOFRAME, $0
   ADDQ          R15, 0(R10)(R8*1)
   NEXT_ADVANCE(2)
 
+// HyperLogLog register update shared by
+// bcaggapproxcount and bcaggslotapproxcount:
+//   R14 = low 64 bits of the hash (clobbered)
+//   reg = pointer to the registers
+//
+// the top hllPrecision bits of the hash select
+// the register, and the number of leading zeros
+// in the remaining bits (plus one) is the rank
+#define BC_HLL_UPDATE(reg)                                   \
+  MOVQ    R14, R15                                           \
+  SHRQ    $(64 - const_hllPrecision), R15                    \
+  SHLQ    $const_hllPrecision, R14                           \
+  ORQ     $(1 << (const_hllPrecision - 1)), R14              \
+  LZCNTQ  R14, R14                                           \
+  INCL    R14                                                \
+  MOVBLZX 0(reg)(R15*1), CX                                  \
+  CMPL    R14, CX                                            \
+  JLS     skip                                               \
+  MOVB    R14, 0(reg)(R15*1)                                 \
+skip:
+
+// APPROX_COUNT_DISTINCT: for each lane in K1,
+// take the hash in slot imm0 and update the
+// HyperLogLog registers at aggregate offset imm1
+TEXT bcaggapproxcount(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  MOVWQZX       2(VIRT_PCREG), R13
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          bytecode_hashmem(VIRT_BCPTR), R8 // R8 = pointer to input hash slot
+  ADDQ          R10, R13                         // R13 = pointer to registers
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  SHLL          $4, DX
+  MOVQ          0(R8)(DX*1), R14                 // R14 = low 64 bits of the hash
+  BC_HLL_UPDATE(R13)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(4)
+
+// APPROX_COUNT_DISTINCT merge: for each lane in K1,
+// merge the blob of registers in Z2:Z3 into the
+// HyperLogLog registers at aggregate offset imm0
+// (lanes with a blob of the wrong size are ignored)
+TEXT bcaggapproxcountmerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          R10, R8                          // R8 = pointer to registers
+  MOVL          $const_hllRegisters, CX
+  VPBROADCASTD  CX, Z4
+  VPCMPEQD      Z4, Z3, K1, K2                   // K2 = lanes with len == hllRegisters
+  KMOVW         K2, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(DX*4), R14
+  ADDQ          SI, R14                          // R14 = pointer to the blob
+  XORL          CX, CX
+
+merge:
+  VMOVDQU8      0(R14)(CX*1), Z5
+  VPMAXUB       0(R8)(CX*1), Z5, Z5
+  VMOVDQU8      Z5, 0(R8)(CX*1)
+  ADDQ          $64, CX
+  CMPQ          CX, $const_hllRegisters
+  JNE           merge
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// APPROX_PERCENTILE: the value in R14 (float64 bits)
+// updates the min/max and increments the histogram
+// bucket of the sketch at reg (see percentileIndex)
+#define BC_PERCENTILE_UPDATE(reg)                            \
+  VMOVQ   R14, X5                                            \
+  VMINSD  8(reg), X5, X6                                     \
+  VMOVSD  X6, 8(reg)                                         \
+  VMAXSD  16(reg), X5, X6                                    \
+  VMOVSD  X6, 16(reg)                                        \
+  MOVQ    R14, CX                                            \
+  SHLQ    $1, CX                                             \
+  SHRQ    $(53 - const_percentileSubBits), CX                \
+  SUBQ    $const_percentileBias, CX                          \
+  XORL    R15, R15                                           \
+  TESTQ   CX, CX                                             \
+  CMOVQLT R15, CX                                            \
+  MOVL    $const_percentileMagBuckets, R15                   \
+  CMPQ    CX, R15                                            \
+  CMOVQGT R15, CX                                            \
+  SARQ    $63, R14                                           \
+  XORQ    R14, CX                                            \
+  SUBQ    R14, CX                                            \
+  INCQ    (24 + 8*const_percentileMagBuckets)(reg)(CX*8)
+
+// APPROX_PERCENTILE sketch merge: the sketch
+// in the blob at R14 is merged into the sketch
+// at reg (the percentile itself is not merged)
+#define BC_PERCENTILE_MERGE(reg)                             \
+  VMOVSD  8(reg), X5                                         \
+  VMINSD  8(R14), X5, X5                                     \
+  VMOVSD  X5, 8(reg)                                         \
+  VMOVSD  16(reg), X5                                        \
+  VMAXSD  16(R14), X5, X5                                    \
+  VMOVSD  X5, 16(reg)                                        \
+  MOVL    $24, CX                                            \
+merge:                                                       \
+  VMOVDQU64 0(R14)(CX*1), Z5                                 \
+  VPADDQ  0(reg)(CX*1), Z5, Z5                               \
+  VMOVDQU64 Z5, 0(reg)(CX*1)                                 \
+  ADDQ    $64, CX                                            \
+  CMPQ    CX, $(const_percentileSketchSize - 8)              \
+  JNE     merge                                              \
+  MOVQ    0(R14)(CX*1), R15                                  \
+  ADDQ    R15, 0(reg)(CX*1)
+
+// APPROX_PERCENTILE: for each lane in K1, add the
+// float in Z2:Z3 to the sketch at aggregate offset imm0
+TEXT bcaggapproxpercentile(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          R10, R8                          // R8 = pointer to the sketch
+  VMOVDQU64     Z2, bytecode_spillArea(VIRT_BCPTR)
+  VMOVDQU64     Z3, (bytecode_spillArea+64)(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVQ          bytecode_spillArea(VIRT_BCPTR)(DX*8), R14
+  BC_PERCENTILE_UPDATE(R8)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// APPROX_PERCENTILE merge: for each lane in K1,
+// merge the sketch in the blob in Z2:Z3 into the
+// sketch at aggregate offset imm0
+// (lanes with a blob of the wrong size are ignored)
+TEXT bcaggapproxpercentilemerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          R10, R8                          // R8 = pointer to the sketch
+  MOVL          $const_percentileSketchSize, CX
+  VPBROADCASTD  CX, Z4
+  VPCMPEQD      Z4, Z3, K1, K2                   // K2 = lanes with len == percentileSketchSize
+  KMOVW         K2, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(DX*4), R14
+  ADDQ          SI, R14                          // R14 = pointer to the blob
+  BC_PERCENTILE_MERGE(R8)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// statistical aggregates: the pair (X5, X6) is
+// added to the state at reg with Welford's algorithm
+// (see moments.add)
+#define BC_MOMENTS_UPDATE(reg)                               \
+  VMOVSD  0(reg), X7                                         \
+  VADDSD  CONSTF64_1(), X7, X7                               \
+  VMOVSD  X7, 0(reg)                      /* n += 1 */       \
+  VMOVSD  8(reg), X8                                         \
+  VSUBSD  X8, X5, X9                      /* dx */           \
+  VDIVSD  X7, X9, X10                                        \
+  VADDSD  X10, X8, X8                                        \
+  VMOVSD  X8, 8(reg)                      /* mean(x) */      \
+  VSUBSD  X8, X5, X10                                        \
+  VMULSD  X10, X9, X10                                       \
+  VADDSD  24(reg), X10, X10                                  \
+  VMOVSD  X10, 24(reg)                    /* m2(x) */        \
+  VMOVSD  16(reg), X8                                        \
+  VSUBSD  X8, X6, X11                     /* dy */           \
+  VDIVSD  X7, X11, X10                                       \
+  VADDSD  X10, X8, X8                                        \
+  VMOVSD  X8, 16(reg)                     /* mean(y) */      \
+  VSUBSD  X8, X6, X10                                        \
+  VMULSD  X10, X11, X11                                      \
+  VADDSD  32(reg), X11, X11                                  \
+  VMOVSD  X11, 32(reg)                    /* m2(y) */        \
+  VMULSD  X10, X9, X9                                        \
+  VADDSD  40(reg), X9, X9                                    \
+  VMOVSD  X9, 40(reg)                     /* c(x, y) */
+
+// statistical aggregates: the state in the blob
+// at R14 is merged into the state at reg
+// (see moments.merge)
+#define BC_MOMENTS_MERGE(reg)                                \
+  VMOVSD   0(R14), X5                                        \
+  VXORPD   X7, X7, X7                                        \
+  VUCOMISD X7, X5                                            \
+  JEQ      skip                                              \
+  VMOVSD   0(reg), X6                                        \
+  VADDSD   X5, X6, X7                                        \
+  VMOVSD   X7, 0(reg)                     /* n */            \
+  VDIVSD   X7, X5, X8                     /* f */            \
+  VMULSD   X6, X8, X9                     /* w */            \
+  VMOVSD   8(reg), X10                                       \
+  VMOVSD   8(R14), X11                                       \
+  VSUBSD   X10, X11, X11                  /* dx */           \
+  VMULSD   X8, X11, X12                                      \
+  VADDSD   X12, X10, X10                                     \
+  VMOVSD   X10, 8(reg)                    /* mean(x) */      \
+  VMOVSD   16(reg), X10                                      \
+  VMOVSD   16(R14), X12                                      \
+  VSUBSD   X10, X12, X12                  /* dy */           \
+  VMULSD   X8, X12, X13                                      \
+  VADDSD   X13, X10, X10                                     \
+  VMOVSD   X10, 16(reg)                   /* mean(y) */      \
+  VMULSD   X9, X11, X13                   /* dx*w */         \
+  VMULSD   X11, X13, X10                                     \
+  VADDSD   24(R14), X10, X10                                 \
+  VADDSD   24(reg), X10, X10                                 \
+  VMOVSD   X10, 24(reg)                   /* m2(x) */        \
+  VMULSD   X12, X13, X10                                     \
+  VADDSD   40(R14), X10, X10                                 \
+  VADDSD   40(reg), X10, X10                                 \
+  VMOVSD   X10, 40(reg)                   /* c(x, y) */      \
+  VMULSD   X9, X12, X10                   /* dy*w */         \
+  VMULSD   X12, X10, X10                                     \
+  VADDSD   32(R14), X10, X10                                 \
+  VADDSD   32(reg), X10, X10                                 \
+  VMOVSD   X10, 32(reg)                   /* m2(y) */        \
+skip:
+
+// statistical aggregates: for each lane in K1, add
+// the pair of the float in Z2:Z3 and the float in
+// slot imm0 to the state at aggregate offset imm1
+TEXT bcaggmoments(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R13
+  MOVWQZX       2(VIRT_PCREG), R8
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          VIRT_VALUES, R13                 // R13 = pointer to y
+  ADDQ          R10, R8                          // R8 = pointer to the state
+  VMOVDQU64     Z2, bytecode_spillArea(VIRT_BCPTR)
+  VMOVDQU64     Z3, (bytecode_spillArea+64)(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  VMOVSD        bytecode_spillArea(VIRT_BCPTR)(DX*8), X5
+  VMOVSD        0(R13)(DX*8), X6
+  BC_MOMENTS_UPDATE(R8)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(4)
+
+// statistical aggregates merge: for each lane in K1,
+// merge the state in the blob in Z2:Z3 into the
+// state at aggregate offset imm0
+// (lanes with a blob of the wrong size are ignored)
+TEXT bcaggmomentsmerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          R10, R8                          // R8 = pointer to the state
+  MOVL          $const_momentsSize, CX
+  VPBROADCASTD  CX, Z4
+  VPCMPEQD      Z4, Z3, K1, K2                   // K2 = lanes with len == momentsSize
+  KMOVW         K2, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(DX*4), R14
+  ADDQ          SI, R14                          // R14 = pointer to the blob
+  BC_MOMENTS_MERGE(R8)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// arrayagginsert adds the value at R14 with the
+// length CX and the ordering key in X5 to the
+// ARRAY_AGG state at R15 (see arrayAggInsert)
+//
+// clobbers CX, DX, R13, R14, X6 and X12-X15
+TEXT arrayagginsert(SB), NOSPLIT|NOFRAME, $0
+  VMOVQ         BX, X12
+  VMOVQ         R8, X13
+  VMOVQ         SI, X14
+  VMOVQ         DI, X15
+  LEAQ          const_arrayAggEntryHeader(CX), R13
+  CMPQ          R13, $const_arrayAggCapacity
+  JA            done                             // the value can never fit
+  TESTL         $const_arrayAggDistinct, 4(R15)
+  JZ            fit
+
+  // DISTINCT: look for an entry with the same value
+  LEAQ          const_arrayAggHeaderSize(R15), DX // DX = current entry
+  MOVL          12(R15), R8
+  ADDQ          DX, R8                           // R8 = end of the entries
+dupentry:
+  CMPQ          DX, R8
+  JAE           fit
+  MOVL          8(DX), BX
+  CMPQ          BX, CX
+  JNE           dupnext
+  XORL          SI, SI
+dupbyte:
+  CMPQ          SI, CX
+  JAE           dupfound
+  MOVBLZX       0(R14)(SI*1), DI
+  MOVBLZX       const_arrayAggEntryHeader(DX)(SI*1), BX
+  CMPL          BX, DI
+  JNE           dupnext
+  INCQ          SI
+  JMP           dupbyte
+dupnext:
+  MOVL          8(DX), BX
+  LEAQ          const_arrayAggEntryHeader(DX)(BX*1), DX
+  JMP           dupentry
+dupfound:
+  // keep the value ordered first
+  VUCOMISD      0(DX), X5
+  JPS           done
+  JAE           done
+  VMOVSD        X5, 0(DX)
+  JMP           done
+
+fit:
+  MOVL          8(R15), DX                       // DX = number of entries
+  CMPL          DX, 0(R15)
+  JAE           evict                            // there are already LIMIT entries
+  MOVL          12(R15), DX                      // DX = bytes of entries
+  LEAQ          const_arrayAggEntryHeader(CX)(DX*1), R13
+  CMPQ          R13, $const_arrayAggCapacity
+  JA            evict                            // there is no room for the entry
+  LEAQ          const_arrayAggHeaderSize(R15)(DX*1), DI // DI = new entry
+  MOVL          R13, 12(R15)
+  INCL          8(R15)
+  VMOVSD        X5, 0(DI)
+  MOVL          CX, 8(DI)
+  ADDQ          $const_arrayAggEntryHeader, DI
+  MOVQ          R14, SI
+  REP; MOVSB
+  JMP           done
+
+evict:
+  MOVL          8(R15), R8                       // R8 = number of entries
+  TESTL         R8, R8
+  JZ            done
+  LEAQ          const_arrayAggHeaderSize(R15), DX // DX = current entry
+  MOVQ          DX, BX                           // BX = last entry with the largest key
+worst:
+  VMOVSD        0(DX), X6
+  VUCOMISD      0(BX), X6
+  JCS           worstnext
+  MOVQ          DX, BX
+worstnext:
+  MOVL          8(DX), SI
+  LEAQ          const_arrayAggEntryHeader(DX)(SI*1), DX
+  DECL          R8
+  JNZ           worst
+
+  // drop the new value unless it is ordered
+  // before the entry with the largest key
+  VUCOMISD      0(BX), X5
+  JPS           done
+  JAE           done
+
+  // remove the entry at BX and try again
+  MOVL          8(BX), R13
+  ADDQ          $const_arrayAggEntryHeader, R13  // R13 = size of the removed entry
+  MOVL          12(R15), R8
+  LEAQ          const_arrayAggHeaderSize(R15)(R8*1), R8 // R8 = end of the entries
+  SUBL          R13, 12(R15)
+  DECL          8(R15)
+  MOVQ          BX, DI
+  LEAQ          0(BX)(R13*1), SI
+  MOVQ          CX, BX                           // BX = length of the value
+  MOVQ          R8, CX
+  SUBQ          SI, CX
+  REP; MOVSB
+  MOVQ          BX, CX
+  JMP           fit
+
+done:
+  VMOVQ         X12, BX
+  VMOVQ         X13, R8
+  VMOVQ         X14, SI
+  VMOVQ         X15, DI
+  RET
+
+// ARRAY_AGG: load the value and the ordering key
+// of the lane from the spill area into R14, CX and X5,
+// or jump to skip if the value is not a scalar
+// (symbols, lists, structures, etc. are not collected)
+#define BC_ARRAY_AGG_LOAD(lane, skip)                        \
+  VMOVSD  (bytecode_spillArea+128)(VIRT_BCPTR)(lane*8), X5   \
+  MOVL    bytecode_spillArea(VIRT_BCPTR)(lane*4), R14        \
+  ADDQ    SI, R14                                            \
+  MOVL    (bytecode_spillArea+64)(VIRT_BCPTR)(lane*4), CX    \
+  TESTL   CX, CX                                             \
+  JZ      skip                                               \
+  MOVBLZX 0(R14), R13                                        \
+  SHRL    $4, R13                                            \
+  CMPL    R13, $7                                            \
+  JEQ     skip                                               \
+  CMPL    R13, $0xb                                          \
+  JAE     skip
+
+// ARRAY_AGG: spill the values in Z30:Z31 and
+// the ordering keys at R13
+#define BC_ARRAY_AGG_SPILL()                                 \
+  VMOVDQU32 Z30, bytecode_spillArea(VIRT_BCPTR)              \
+  VMOVDQU32 Z31, (bytecode_spillArea+64)(VIRT_BCPTR)         \
+  VMOVDQU64 0(R13), Z4                                       \
+  VMOVDQU64 Z4, (bytecode_spillArea+128)(VIRT_BCPTR)         \
+  VMOVDQU64 64(R13), Z4                                      \
+  VMOVDQU64 Z4, (bytecode_spillArea+192)(VIRT_BCPTR)
+
+// ARRAY_AGG merge: merge the partial state in
+// the blob of the lane in the spill area into
+// the state at R15 (invalid blobs are ignored)
+//
+// the iteration state is kept in the spill area
+// after the blobs, since arrayagginsert clobbers
+// most of the free registers
+#define BC_ARRAY_AGG_MERGE(lane, skip)                       \
+  MOVL    bytecode_spillArea(VIRT_BCPTR)(lane*4), R14        \
+  ADDQ    SI, R14                                            \
+  MOVL    (bytecode_spillArea+64)(VIRT_BCPTR)(lane*4), CX    \
+  CMPL    CX, $const_arrayAggHeaderSize                      \
+  JB      skip                                               \
+  MOVL    12(R14), R13                                       \
+  ADDQ    $const_arrayAggHeaderSize, R13                     \
+  CMPQ    R13, CX                                            \
+  JNE     skip                                               \
+  ADDQ    R14, CX                                            \
+  ADDQ    $const_arrayAggHeaderSize, R14                     \
+  MOVQ    CX, (bytecode_spillArea+128)(VIRT_BCPTR)           \
+  MOVQ    R15, (bytecode_spillArea+136)(VIRT_BCPTR)          \
+entry:                                                       \
+  MOVQ    (bytecode_spillArea+128)(VIRT_BCPTR), DX           \
+  CMPQ    R14, DX                                            \
+  JAE     skip                                               \
+  LEAQ    const_arrayAggEntryHeader(R14), R13                \
+  CMPQ    R13, DX                                            \
+  JA      skip                                               \
+  MOVL    8(R14), CX                                         \
+  ADDQ    CX, R13                                            \
+  CMPQ    R13, DX                                            \
+  JA      skip                                               \
+  MOVQ    R13, (bytecode_spillArea+144)(VIRT_BCPTR)          \
+  VMOVSD  0(R14), X5                                         \
+  ADDQ    $const_arrayAggEntryHeader, R14                    \
+  MOVQ    (bytecode_spillArea+136)(VIRT_BCPTR), R15          \
+  CALL    arrayagginsert(SB)                                 \
+  MOVQ    (bytecode_spillArea+144)(VIRT_BCPTR), R14          \
+  JMP     entry
+
+// ARRAY_AGG: for each lane in K1, add the value
+// in Z30:Z31 with the ordering key in slot imm0
+// to the state at aggregate offset imm1
+TEXT bcaggarray(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R13
+  MOVWQZX       2(VIRT_PCREG), R8
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          VIRT_VALUES, R13                 // R13 = pointer to the keys
+  ADDQ          R10, R8                          // R8 = pointer to the state
+  BC_ARRAY_AGG_SPILL()
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  BC_ARRAY_AGG_LOAD(DX, skip)
+  MOVQ          R8, R15
+  CALL          arrayagginsert(SB)
+skip:
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(4)
+
+// ARRAY_AGG merge: for each lane in K1, merge
+// the partial state in the blob in Z2:Z3 into
+// the state at aggregate offset imm0
+TEXT bcaggarraymerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          R10, R8                          // R8 = pointer to the state
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+  VMOVDQU32     Z3, (bytecode_spillArea+64)(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVQ          R8, R15
+  BC_ARRAY_AGG_MERGE(DX, skip)
+skip:
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
 // Slot Aggregation Instructions
 // -----------------------------
 
@@ -11350,6 +11972,245 @@ TEXT bcaggslotcount(SB), NOSPLIT|NOFRAME, $0
 
   NEXT_ADVANCE(2)
 
+// APPROX_COUNT_DISTINCT: for each lane in K1,
+// take the hash in slot imm0 and update the
+// HyperLogLog registers at aggregate offset imm1
+// of the bucket associated with the lane
+TEXT bcaggslotapproxcount(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  MOVWQZX       2(VIRT_PCREG), R13
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          bytecode_hashmem(VIRT_BCPTR), R8 // R8 = pointer to input hash slot
+  ADDQ          radixTree64_values(R10), R13
+  ADDQ          $8, R13                          // R13 = pointer to registers of bucket 0
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          DX, R14
+  SHLL          $4, R14
+  MOVQ          0(R8)(R14*1), R14                // R14 = low 64 bits of the hash
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), DX
+  ADDQ          R13, DX                          // DX = pointer to registers
+  BC_HLL_UPDATE(DX)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(4)
+
+// APPROX_COUNT_DISTINCT merge: for each lane in K1,
+// merge the blob of registers in Z2:Z3 into the
+// HyperLogLog registers at aggregate offset imm0
+// of the bucket associated with the lane
+TEXT bcaggslotapproxcountmerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to registers of bucket 0
+  MOVL          $const_hllRegisters, CX
+  VPBROADCASTD  CX, Z4
+  VPCMPEQD      Z4, Z3, K1, K2                   // K2 = lanes with len == hllRegisters
+  KMOVW         K2, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(DX*4), R14
+  ADDQ          SI, R14                          // R14 = pointer to the blob
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), R13
+  ADDQ          R8, R13                          // R13 = pointer to registers
+  XORL          CX, CX
+
+merge:
+  VMOVDQU8      0(R14)(CX*1), Z5
+  VPMAXUB       0(R13)(CX*1), Z5, Z5
+  VMOVDQU8      Z5, 0(R13)(CX*1)
+  ADDQ          $64, CX
+  CMPQ          CX, $const_hllRegisters
+  JNE           merge
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// APPROX_PERCENTILE: for each lane in K1, add the
+// float in Z2:Z3 to the sketch at aggregate offset imm0
+// of the bucket associated with the lane
+TEXT bcaggslotapproxpercentile(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to the sketch of bucket 0
+  VMOVDQU64     Z2, bytecode_spillArea(VIRT_BCPTR)
+  VMOVDQU64     Z3, (bytecode_spillArea+64)(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVQ          bytecode_spillArea(VIRT_BCPTR)(DX*8), R14
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), DX
+  ADDQ          R8, DX                           // DX = pointer to the sketch
+  BC_PERCENTILE_UPDATE(DX)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// APPROX_PERCENTILE merge: for each lane in K1,
+// merge the sketch in the blob in Z2:Z3 into the
+// sketch at aggregate offset imm0 of the bucket
+// associated with the lane
+TEXT bcaggslotapproxpercentilemerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to the sketch of bucket 0
+  MOVL          $const_percentileSketchSize, CX
+  VPBROADCASTD  CX, Z4
+  VPCMPEQD      Z4, Z3, K1, K2                   // K2 = lanes with len == percentileSketchSize
+  KMOVW         K2, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(DX*4), R14
+  ADDQ          SI, R14                          // R14 = pointer to the blob
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), R13
+  ADDQ          R8, R13                          // R13 = pointer to the sketch
+  BC_PERCENTILE_MERGE(R13)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// statistical aggregates: for each lane in K1, add
+// the pair of the float in Z2:Z3 and the float in
+// slot imm0 to the state at aggregate offset imm1
+// of the bucket associated with the lane
+TEXT bcaggslotmoments(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R13
+  MOVWQZX       2(VIRT_PCREG), R8
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          VIRT_VALUES, R13                 // R13 = pointer to y
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to the state of bucket 0
+  VMOVDQU64     Z2, bytecode_spillArea(VIRT_BCPTR)
+  VMOVDQU64     Z3, (bytecode_spillArea+64)(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  VMOVSD        bytecode_spillArea(VIRT_BCPTR)(DX*8), X5
+  VMOVSD        0(R13)(DX*8), X6
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), DX
+  ADDQ          R8, DX                           // DX = pointer to the state
+  BC_MOMENTS_UPDATE(DX)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(4)
+
+// statistical aggregates merge: for each lane in K1,
+// merge the state in the blob in Z2:Z3 into the state
+// at aggregate offset imm0 of the bucket associated
+// with the lane
+TEXT bcaggslotmomentsmerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to the state of bucket 0
+  MOVL          $const_momentsSize, CX
+  VPBROADCASTD  CX, Z4
+  VPCMPEQD      Z4, Z3, K1, K2                   // K2 = lanes with len == momentsSize
+  KMOVW         K2, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(DX*4), R14
+  ADDQ          SI, R14                          // R14 = pointer to the blob
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), R13
+  ADDQ          R8, R13                          // R13 = pointer to the state
+  BC_MOMENTS_MERGE(R13)
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
+// ARRAY_AGG: for each lane in K1, add the value
+// in Z30:Z31 with the ordering key in slot imm0
+// to the state at aggregate offset imm1 of the
+// bucket associated with the lane
+TEXT bcaggslotarray(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R13
+  MOVWQZX       2(VIRT_PCREG), R8
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  ADDQ          VIRT_VALUES, R13                 // R13 = pointer to the keys
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to the state of bucket 0
+  BC_ARRAY_AGG_SPILL()
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  BC_ARRAY_AGG_LOAD(DX, skip)
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), R15
+  ADDQ          R8, R15                          // R15 = pointer to the state
+  CALL          arrayagginsert(SB)
+skip:
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(4)
+
+// ARRAY_AGG merge: for each lane in K1, merge
+// the partial state in the blob in Z2:Z3 into
+// the state at aggregate offset imm0 of the
+// bucket associated with the lane
+TEXT bcaggslotarraymerge(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          radixTree64_values(R10), R8
+  ADDQ          $8, R8                           // R8 = pointer to the state of bucket 0
+  KMOVW         K1, BX
+  TESTL         BX, BX
+  JZ            next
+  VMOVDQU32     Z2, bytecode_spillArea(VIRT_BCPTR)
+  VMOVDQU32     Z3, (bytecode_spillArea+64)(VIRT_BCPTR)
+
+loop:
+  TZCNTL        BX, DX                           // DX = index of the lane to process
+  BLSRL         BX, BX
+  MOVLQSX       bytecode_bucket(VIRT_BCPTR)(DX*4), R15
+  ADDQ          R8, R15                          // R15 = pointer to the state
+  BC_ARRAY_AGG_MERGE(DX, skip)
+skip:
+  TESTL         BX, BX
+  JNZ           loop
+
+next:
+  NEXT_ADVANCE(2)
+
 // Uncategorized Instructions
 // --------------------------
 
@@ -11716,7 +12577,12 @@ trap:
 
 // SIZE(x) function --- returns the number of items
 // in a struct or list, missing otherwise.
+//
+// Z30:Z31 are used to walk the objects, so
+// they are preserved in Z17:Z18
 TEXT bcobjectsize(SB), NOSPLIT|NOFRAME, $0
+    VMOVDQA32 Z30, Z17
+    VMOVDQA32 Z31, Z18
     VPBROADCASTD CONSTD_1(), CONST_0x01
     VPBROADCASTD CONSTD_0x0F(), CONST_0x0f
 
@@ -11797,11 +12663,15 @@ count_fields_end:
     VEXTRACTI32X8   $1, Z2, Y3
     VPMOVZXDQ       Y2, Z2
     VPMOVZXDQ       Y3, Z3
+    VMOVDQA32       Z17, Z30
+    VMOVDQA32       Z18, Z31
     NEXT()
 
 no_compbound_values_found:
 all_nulls:
     KXORW           K1, K1, K1
+    VMOVDQA32       Z17, Z30
+    VMOVDQA32       Z18, Z31
     NEXT()
 trap:
     FAIL()
@@ -15057,6 +15927,2395 @@ next:
   NEXT()
 //; #endregion bcDfaLZ
 
+//; #region per-lane string functions
+//
+// string functions that are hard to vectorize
+// process one lane at a time; they share the
+// following registers:
+//
+//   R13 = pointer to the string of the current lane
+//   X4  = length of the string of the current lane
+//   X11 = lanes that remain to be processed
+//   X12 = index of the current lane
+//   K2  = bit of the current lane
+
+// strcharwidth advances CX past the UTF-8
+// sequence that starts at position CX
+//
+// clobbers R14, R15
+TEXT strcharwidth(SB), NOSPLIT|NOFRAME, $0
+  VMOVQ         X4, R14
+  CMPQ          CX, R14
+  JAE           one
+  MOVBLZX       0(R13)(CX*1), R15
+  CMPL          R15, $0xf5
+  JAE           one                              // invalid
+  CMPL          R15, $0xf0
+  JAE           four
+  CMPL          R15, $0xe0
+  JAE           three
+  CMPL          R15, $0xc2
+  JAE           two
+one:
+  INCQ          CX
+  RET
+two:
+  ADDQ          $2, CX
+  JMP           clamp
+three:
+  ADDQ          $3, CX
+  JMP           clamp
+four:
+  ADDQ          $4, CX
+clamp:
+  CMPQ          CX, R14
+  JBE           done
+  MOVQ          R14, CX
+done:
+  RET
+
+// strappend appends CX bytes from R14 to the
+// scratch buffer; it sets BX to -1 if there is
+// not enough space and to 0 otherwise
+//
+// clobbers CX, R14, R15, X26, X27
+TEXT strappend(SB), NOSPLIT|NOFRAME, $0
+  MOVQ          bytecode_scratch+16(VIRT_BCPTR), R15
+  SUBQ          bytecode_scratch+8(VIRT_BCPTR), R15
+  CMPQ          R15, CX
+  JB            full
+  MOVQ          bytecode_scratch(VIRT_BCPTR), R15
+  ADDQ          bytecode_scratch+8(VIRT_BCPTR), R15
+  ADDQ          CX, bytecode_scratch+8(VIRT_BCPTR)
+  VMOVQ         SI, X26
+  VMOVQ         DI, X27
+  MOVQ          R14, SI
+  MOVQ          R15, DI
+  REP; MOVSB
+  VMOVQ         X26, SI
+  VMOVQ         X27, DI
+  XORL          BX, BX
+  RET
+full:
+  MOVQ          $-1, BX
+  RET
+
+// STR_LANE_BEGIN(done) loads the string of
+// the next lane in X11 into R13 and X4, sets
+// X12 to the lane index and K2 to the lane bit,
+// or jumps to done if there are no more lanes
+#define STR_LANE_BEGIN(done)      \
+  VMOVQ         X11, R15          \
+  TESTL         R15, R15          \
+  JZ            done              \
+  TZCNTL        R15, CX           \
+  BLSRL         R15, R15          \
+  VMOVQ         R15, X11          \
+  VMOVQ         CX, X12           \
+  MOVL          $1, R15           \
+  SHLL          CX, R15           \
+  KMOVW         R15, K2           \
+  VMOVD         CX, X13           \
+  VPERMD        Z2, Z13, Z14      \
+  VMOVD         X14, R13          \
+  ADDQ          SI, R13           \
+  VPERMD        Z3, Z13, Z14      \
+  VMOVD         X14, R14          \
+  VMOVQ         R14, X4
+
+// STR_LANE_RESULT(off, len) sets the string of
+// the current lane to off and len (GP registers)
+#define STR_LANE_RESULT(off, len) \
+  VPBROADCASTD  off, K2, Z2       \
+  VPBROADCASTD  len, K2, Z3
+
+// STR_LANE_SCRATCH_RESULT sets the string of the
+// current lane to the bytes appended to the scratch
+// buffer since the offset in X21
+//
+// clobbers BX, DX
+#define STR_LANE_SCRATCH_RESULT() \
+  VM_GET_SCRATCH_BASE_GP(DX)      \
+  VMOVQ         X21, BX           \
+  SUBQ          BX, DX            \
+  STR_LANE_RESULT(BX, DX)
+
+// STRPOS(str, needle)
+//
+// the result is the 1-based position (in characters)
+// of the first occurrence of needle, or 0
+TEXT bcStrPos(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          8(R14), R8
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = needle
+  VMOVQ         R8, X6                           // X6 = length of the needle
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  VPXORD        Z16, Z16, Z16                    // Z16 = positions
+lane:
+  STR_LANE_BEGIN(done)
+  XORL          CX, CX                           // CX = candidate position
+  XORL          DX, DX                           // DX = characters before CX
+search:
+  VMOVQ         X4, R8
+  VMOVQ         X6, R14
+  SUBQ          R14, R8                          // R8 = last candidate position
+  JB            lane
+  CMPQ          CX, R8
+  JA            lane
+  XORL          BX, BX
+compare:
+  CMPQ          BX, R14
+  JAE           found
+  LEAQ          0(CX)(BX*1), R15
+  MOVBLZX       0(R13)(R15*1), R15
+  VMOVQ         X5, R8
+  MOVBLZX       0(R8)(BX*1), R8
+  CMPL          R15, R8
+  JNE           mismatch
+  INCQ          BX
+  JMP           compare
+mismatch:
+  MOVBLZX       0(R13)(CX*1), R15
+  ANDL          $0xc0, R15
+  CMPL          R15, $0x80
+  JEQ           continuation
+  INCQ          DX
+continuation:
+  INCQ          CX
+  JMP           search
+found:
+  INCQ          DX
+  VPBROADCASTD  DX, K2, Z16
+  JMP           lane
+done:
+  VPMOVZXDQ     Y16, Z2
+  VEXTRACTI32X8 $1, Z16, Y16
+  VPMOVZXDQ     Y16, Z3
+  NEXT()
+
+// REPLACE(str, from, to)
+//
+// the immediate is the length of from (uint32)
+// followed by from and to; from is never empty
+TEXT bcStrReplace(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          8(R14), R8
+  MOVQ          (R14), R14
+  MOVL          0(R14), CX
+  ADDQ          $4, R14
+  VMOVQ         R14, X5                          // X5 = from
+  VMOVQ         CX, X6                           // X6 = length of from
+  ADDQ          CX, R14
+  VMOVQ         R14, X7                          // X7 = to
+  SUBQ          $4, R8
+  SUBQ          CX, R8
+  VMOVQ         R8, X8                           // X8 = length of to
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+lane:
+  STR_LANE_BEGIN(done)
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  XORL          CX, CX                           // CX = candidate position
+  VMOVQ         CX, X19                          // X19 = end of the copied input
+search:
+  VMOVQ         X4, R8
+  VMOVQ         X6, R14
+  SUBQ          R14, R8                          // R8 = last candidate position
+  JB            tail
+  CMPQ          CX, R8
+  JA            tail
+  XORL          BX, BX
+compare:
+  CMPQ          BX, R14
+  JAE           found
+  LEAQ          0(CX)(BX*1), R15
+  MOVBLZX       0(R13)(R15*1), R15
+  VMOVQ         X5, R8
+  MOVBLZX       0(R8)(BX*1), R8
+  CMPL          R15, R8
+  JNE           mismatch
+  INCQ          BX
+  JMP           compare
+mismatch:
+  INCQ          CX
+  JMP           search
+found:
+  VMOVQ         CX, X22                          // X22 = position of the match
+  VMOVQ         X19, R14
+  SUBQ          R14, CX
+  ADDQ          R13, R14
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X7, R14
+  VMOVQ         X8, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X22, CX
+  VMOVQ         X6, R14
+  ADDQ          R14, CX
+  VMOVQ         CX, X19
+  JMP           search
+tail:
+  VMOVQ         X19, R14
+  VMOVQ         X4, CX
+  SUBQ          R14, CX
+  ADDQ          R13, R14
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  STR_LANE_SCRATCH_RESULT()
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// REVERSE(str)
+//
+// the characters (rather than the bytes)
+// of the string are reversed
+TEXT bcStrReverse(SB), NOSPLIT|NOFRAME, $0
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+lane:
+  STR_LANE_BEGIN(done)
+  MOVQ          bytecode_scratch+8(VIRT_BCPTR), R8
+  VMOVQ         R8, X20
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  MOVQ          R13, R14
+  VMOVQ         X4, CX
+  CALL          strappend(SB)                    // reserve space for the output
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X20, R8
+  ADDQ          bytecode_scratch(VIRT_BCPTR), R8
+  VMOVQ         X4, DX
+  ADDQ          DX, R8                           // R8 = end of the output
+  XORL          CX, CX                           // CX = position in the input
+char:
+  VMOVQ         X4, DX
+  CMPQ          CX, DX
+  JAE           lanedone
+  MOVQ          CX, BX
+  CALL          strcharwidth(SB)
+  MOVQ          CX, DX
+  SUBQ          BX, DX
+  SUBQ          DX, R8
+  XORL          DX, DX
+copy:
+  LEAQ          0(BX)(DX*1), R14
+  CMPQ          R14, CX
+  JAE           char
+  MOVB          0(R13)(R14*1), R15
+  MOVB          R15, 0(R8)(DX*1)
+  INCQ          DX
+  JMP           copy
+lanedone:
+  STR_LANE_SCRATCH_RESULT()
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// INITCAP(str)
+//
+// ASCII letters are converted to upper case at
+// the start of a word and to lower case elsewhere;
+// words are sequences of ASCII letters, digits,
+// and non-ASCII characters
+TEXT bcStrInitcap(SB), NOSPLIT|NOFRAME, $0
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+lane:
+  STR_LANE_BEGIN(done)
+  MOVQ          bytecode_scratch+8(VIRT_BCPTR), R8
+  VMOVQ         R8, X20
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  MOVQ          R13, R14
+  VMOVQ         X4, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X20, R8
+  ADDQ          bytecode_scratch(VIRT_BCPTR), R8 // R8 = output
+  VMOVQ         X4, CX                           // CX = remaining bytes
+  XORL          DX, DX                           // DX = 1 within a word
+loop:
+  TESTQ         CX, CX
+  JZ            lanedone
+  MOVBLZX       0(R8), R14
+  MOVL          R14, R15
+  ORL           $0x20, R15
+  SUBL          $0x61, R15                       // R15 = lower-case letter - 'a'
+  CMPL          R15, $26
+  JAE           notletter
+  ANDL          $0xdf, R14                       // upper case
+  TESTL         DX, DX
+  JZ            store
+  ORL           $0x20, R14                       // lower case
+store:
+  MOVB          R14, 0(R8)
+  MOVL          $1, DX
+  JMP           next
+notletter:
+  MOVL          $1, DX
+  CMPL          R14, $0x80
+  JAE           next
+  SUBL          $0x30, R14
+  CMPL          R14, $10
+  JB            next
+  XORL          DX, DX
+next:
+  INCQ          R8
+  DECQ          CX
+  JMP           loop
+lanedone:
+  STR_LANE_SCRATCH_RESULT()
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// REPEAT(str, count)
+TEXT bcStrRepeat(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX       0(VIRT_PCREG), R8
+  ADDQ          $2, VIRT_PCREG
+  LEAQ          0(VIRT_VALUES)(R8*1), R8
+  VMOVQ         R8, X5                           // X5 = counts
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+lane:
+  STR_LANE_BEGIN(done)
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  VMOVQ         X12, CX
+  VMOVQ         X5, R8
+  MOVQ          0(R8)(CX*8), DX                  // DX = count
+  TESTQ         DX, DX
+  JLE           lanedone
+  VMOVQ         X4, R8
+  TESTQ         R8, R8
+  JZ            lanedone
+repeat:
+  VMOVQ         DX, X22                          // X22 = remaining count
+  MOVQ          R13, R14
+  VMOVQ         X4, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X22, DX
+  DECQ          DX
+  JNZ           repeat
+lanedone:
+  STR_LANE_SCRATCH_RESULT()
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// strpadfill appends the first DX characters of
+// the (repeated) fill at X5 to the scratch buffer
+// (see strPadImm); it sets BX to -1 if there is
+// not enough space and to 0 otherwise
+//
+// clobbers BX, CX, DX, R8, R14, R15, X23, X26, X27
+TEXT strpadfill(SB), NOSPLIT|NOFRAME, $0
+  VMOVQ         DX, X23                          // X23 = remaining characters
+loop:
+  VMOVQ         X5, R8
+  MOVL          0(R8), CX                        // CX = characters of the fill
+  VMOVQ         X23, DX
+  CMPQ          DX, CX
+  JB            tail
+  SUBQ          CX, DX
+  VMOVQ         DX, X23
+  LEAQ          8(R8)(CX*4), R14
+  MOVL          4(R8)(CX*4), CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            ret
+  JMP           loop
+tail:
+  LEAQ          8(R8)(CX*4), R14
+  MOVL          4(R8)(DX*4), CX
+  CALL          strappend(SB)
+ret:
+  RET
+
+// STR_PAD_BEGIN() consumes the immediates of
+// LPAD and RPAD and sets up the lane loop
+#define STR_PAD_BEGIN()                     \
+  IMM_FROM_DICT(R14)                        \
+  MOVQ          (R14), R14                  \
+  VMOVQ         R14, X5                     \
+  MOVWQZX       0(VIRT_PCREG), R8           \
+  ADDQ          $2, VIRT_PCREG              \
+  LEAQ          0(VIRT_VALUES)(R8*1), R8    \
+  VMOVQ         R8, X6                      \
+  KMOVW         K1, R15                     \
+  VMOVQ         R15, X11
+
+// LPAD(str, n, fill)
+//
+// the immediates are the fill (see strPadImm)
+// and the stack slot of n
+TEXT bcStrLpad(SB), NOSPLIT|NOFRAME, $0
+  STR_PAD_BEGIN()
+lane:
+  STR_LANE_BEGIN(done)
+  VMOVQ         X12, CX
+  VMOVQ         X6, R8
+  MOVQ          0(R8)(CX*8), DX                  // DX = n
+  XORL          CX, CX                           // CX = bytes of the first BX characters
+  XORL          BX, BX
+  TESTQ         DX, DX
+  JLE           truncate
+measure:
+  VMOVQ         X4, R8
+  CMPQ          CX, R8
+  JAE           short
+  CMPQ          BX, DX
+  JAE           truncate                         // at least n characters
+  CALL          strcharwidth(SB)
+  INCQ          BX
+  JMP           measure
+short:
+  SUBQ          BX, DX                           // DX = characters to add
+  JZ            truncate
+  VMOVQ         X5, R8
+  CMPL          0(R8), $0
+  JEQ           truncate                         // empty fill
+  JMP           pad
+truncate:
+  MOVQ          R13, BX
+  SUBQ          SI, BX
+  STR_LANE_RESULT(BX, CX)
+  JMP           lane
+pad:
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  CALL          strpadfill(SB)
+  TESTQ         BX, BX
+  JS            abort
+  MOVQ          R13, R14
+  VMOVQ         X4, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  STR_LANE_SCRATCH_RESULT()
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// RPAD(str, n, fill)
+//
+// the immediates are the fill (see strPadImm)
+// and the stack slot of n
+TEXT bcStrRpad(SB), NOSPLIT|NOFRAME, $0
+  STR_PAD_BEGIN()
+lane:
+  STR_LANE_BEGIN(done)
+  VMOVQ         X12, CX
+  VMOVQ         X6, R8
+  MOVQ          0(R8)(CX*8), DX                  // DX = n
+  XORL          CX, CX                           // CX = bytes of the first BX characters
+  XORL          BX, BX
+  TESTQ         DX, DX
+  JLE           truncate
+measure:
+  VMOVQ         X4, R8
+  CMPQ          CX, R8
+  JAE           short
+  CMPQ          BX, DX
+  JAE           truncate                         // at least n characters
+  CALL          strcharwidth(SB)
+  INCQ          BX
+  JMP           measure
+short:
+  SUBQ          BX, DX                           // DX = characters to add
+  JZ            truncate
+  VMOVQ         X5, R8
+  CMPL          0(R8), $0
+  JEQ           truncate                         // empty fill
+  JMP           pad
+truncate:
+  MOVQ          R13, BX
+  SUBQ          SI, BX
+  STR_LANE_RESULT(BX, CX)
+  JMP           lane
+pad:
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  VMOVQ         DX, X22                          // X22 = characters to add
+  MOVQ          R13, R14
+  VMOVQ         X4, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X22, DX
+  CALL          strpadfill(SB)
+  TESTQ         BX, BX
+  JS            abort
+  STR_LANE_SCRATCH_RESULT()
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+#undef STR_PAD_BEGIN
+
+//; #region timestamp formats
+//
+// PARSE_TIMESTAMP and FORMAT_TIMESTAMP interpret the
+// program built by timeFormatProgram (see timefmt.go),
+// which is kept in X5; the items of the program are
+// pairs of bytes (kind, literal), and the kinds are:
+//
+//   0 = literal, 1 = %Y, 2 = %m, 3 = %d, 4 = %e,
+//   5 = %H, 6 = %M, 7 = %S, 8 = %f, 9 = %b, 10 = %z
+//
+// the components of the timestamp of the current
+// lane are kept in X20 (year), X21 (month), X22 (day),
+// X23 (hour), X24 (minute), X25 (second), X26 (microsecond),
+// and X27 (the UTC offset in seconds)
+
+// tsdigits parses up to DX decimal digits starting
+// at position CX of the current lane and returns
+// the value in BX and the number of digits in DX
+//
+// clobbers R14, R15
+TEXT tsdigits(SB), NOSPLIT|NOFRAME, $0
+  XORL          BX, BX
+  XORL          R14, R14
+loop:
+  CMPQ          R14, DX
+  JAE           done
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JAE           done
+  MOVBLZX       0(R13)(CX*1), R15
+  SUBL          $0x30, R15
+  CMPL          R15, $10
+  JAE           done
+  IMUL3Q        $10, BX, BX
+  ADDQ          R15, BX
+  INCQ          CX
+  INCQ          R14
+  JMP           loop
+done:
+  MOVQ          R14, DX
+  RET
+
+// tsudiv divides R8 by R14 (both unsigned) and
+// returns the quotient in R8 and the remainder in R14
+//
+// clobbers DX, X15
+TEXT tsudiv(SB), NOSPLIT|NOFRAME, $0
+  VMOVQ         VIRT_PCREG, X15
+  MOVQ          R8, AX
+  XORL          DX, DX
+  DIVQ          R14
+  MOVQ          AX, R8
+  MOVQ          DX, R14
+  VMOVQ         X15, VIRT_PCREG
+  RET
+
+// tsputdigits writes the DX least significant
+// decimal digits of BX (which must be less than
+// 2^32) to R13 without advancing R13
+//
+// clobbers BX, DX, R14, R15
+TEXT tsputdigits(SB), NOSPLIT|NOFRAME, $0
+loop:
+  TESTQ         DX, DX
+  JZ            done
+  DECQ          DX
+  MOVL          $0xcccccccd, R14
+  IMULQ         BX, R14
+  SHRQ          $35, R14                         // R14 = BX / 10
+  LEAQ          0(R14)(R14*4), R15
+  ADDQ          R15, R15
+  SUBQ          R15, BX
+  ADDL          $0x30, BX
+  MOVB          BX, 0(R13)(DX*1)
+  MOVQ          R14, BX
+  JMP           loop
+done:
+  RET
+
+// PARSE_TIMESTAMP(format, str)
+//
+// lanes that do not match the format or that
+// describe a date or time that does not exist
+// are removed from the mask
+TEXT bcparsets(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          8(R14), R8
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = program
+  ADDQ          R14, R8
+  VMOVQ         R8, X6                           // X6 = end of the program
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  VPXORQ        X10, X10, X10                    // X10 = matching lanes
+  VPXORQ        Z16, Z16, Z16                    // Z16/Z17 = timestamps
+  VPXORQ        Z17, Z17, Z17
+lane:
+  STR_LANE_BEGIN(done)
+  MOVL          $1970, R8
+  VMOVQ         R8, X20
+  MOVL          $1, R8
+  VMOVQ         R8, X21
+  VMOVQ         R8, X22
+  VPXORQ        X23, X23, X23
+  VPXORQ        X24, X24, X24
+  VPXORQ        X25, X25, X25
+  VPXORQ        X26, X26, X26
+  VPXORQ        X27, X27, X27
+  VMOVQ         X5, R8
+  ADDQ          $64, R8
+  VMOVQ         R8, X7                           // X7 = current item
+  XORL          CX, CX                           // CX = position in the string
+item:
+  VMOVQ         X7, R8
+  VMOVQ         X6, R14
+  CMPQ          R8, R14
+  JAE           finish
+  MOVBLZX       0(R8), BX
+  MOVBLZX       1(R8), DX
+  ADDQ          $2, R8
+  VMOVQ         R8, X7
+  TESTL         BX, BX
+  JZ            literal
+  CMPL          BX, $1
+  JEQ           year
+  CMPL          BX, $4
+  JB            monthday
+  JEQ           dayspace
+  CMPL          BX, $8
+  JB            clock
+  JEQ           fraction
+  CMPL          BX, $9
+  JEQ           monthname
+  // zone
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JAE           lane
+  MOVBLZX       0(R13)(CX*1), R8
+  INCQ          CX
+  MOVL          R8, R15
+  ORL           $0x20, R15
+  CMPL          R15, $0x7a                       // 'z'
+  JEQ           item
+  CMPL          R8, $0x2b                        // '+'
+  JEQ           zonesign
+  CMPL          R8, $0x2d                        // '-'
+  JNE           lane
+zonesign:
+  MOVL          $2, DX
+  CALL          tsdigits(SB)
+  CMPQ          DX, $2
+  JNE           lane
+  CMPQ          BX, $23
+  JA            lane
+  IMUL3Q        $3600, BX, BX
+  VMOVQ         BX, X27
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JAE           lane
+  MOVBLZX       0(R13)(CX*1), R15
+  CMPL          R15, $0x3a                       // ':'
+  JNE           zoneminutes
+  INCQ          CX
+zoneminutes:
+  MOVL          $2, DX
+  CALL          tsdigits(SB)
+  CMPQ          DX, $2
+  JNE           lane
+  CMPQ          BX, $59
+  JA            lane
+  IMUL3Q        $60, BX, BX
+  VMOVQ         X27, R15
+  ADDQ          R15, BX
+  CMPL          R8, $0x2d
+  JNE           zonedone
+  NEGQ          BX
+zonedone:
+  VMOVQ         BX, X27
+  JMP           item
+literal:
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JAE           lane
+  MOVBLZX       0(R13)(CX*1), R15
+  CMPL          R15, DX
+  JNE           lane
+  INCQ          CX
+  JMP           item
+year:
+  MOVL          $4, DX
+  CALL          tsdigits(SB)
+  TESTQ         DX, DX
+  JZ            lane
+  VMOVQ         BX, X20
+  JMP           item
+dayspace:
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JAE           lane
+  MOVBLZX       0(R13)(CX*1), R15
+  CMPL          R15, $0x20
+  JNE           day
+  INCQ          CX
+day:
+  MOVL          $3, BX
+monthday:
+  MOVQ          BX, R8                           // R8 = kind
+  MOVL          $2, DX
+  CALL          tsdigits(SB)
+  TESTQ         DX, DX
+  JZ            lane
+  CMPL          R8, $2
+  JNE           setday
+  VMOVQ         BX, X21
+  JMP           item
+setday:
+  VMOVQ         BX, X22
+  JMP           item
+clock:
+  MOVQ          BX, R8                           // R8 = kind
+  MOVL          $2, DX
+  CALL          tsdigits(SB)
+  TESTQ         DX, DX
+  JZ            lane
+  CMPL          R8, $6
+  JB            sethour
+  JEQ           setminute
+  VMOVQ         BX, X25
+  JMP           item
+sethour:
+  VMOVQ         BX, X23
+  JMP           item
+setminute:
+  VMOVQ         BX, X24
+  JMP           item
+fraction:
+  MOVL          $9, DX
+  CALL          tsdigits(SB)
+  TESTQ         DX, DX
+  JZ            lane
+scaleup:
+  CMPQ          DX, $6
+  JAE           scaledown
+  IMUL3Q        $10, BX, BX
+  INCQ          DX
+  JMP           scaleup
+scaledown:
+  CMPQ          DX, $6
+  JBE           setfraction
+  MOVL          $0xcccccccd, R14
+  IMULQ         R14, BX
+  SHRQ          $35, BX                          // BX /= 10
+  DECQ          DX
+  JMP           scaledown
+setfraction:
+  VMOVQ         BX, X26
+  JMP           item
+monthname:
+  VMOVQ         X4, R15
+  SUBQ          CX, R15
+  CMPQ          R15, $3
+  JB            lane
+  MOVWLZX       0(R13)(CX*1), BX
+  MOVBLZX       2(R13)(CX*1), R15
+  SHLL          $16, R15
+  ORL           R15, BX
+  ORL           $0x202020, BX                    // BX = lower-case name
+  VMOVQ         X5, R8
+  XORL          DX, DX
+monthnext:
+  CMPL          DX, $12
+  JAE           lane
+  MOVL          0(R8)(DX*4), R15
+  ORL           $0x202020, R15
+  INCQ          DX
+  CMPL          BX, R15
+  JNE           monthnext
+  VMOVQ         DX, X21
+  ADDQ          $3, CX
+  JMP           item
+finish:
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JNE           lane                             // trailing characters
+  // validate the components
+  VMOVQ         X21, R8
+  DECQ          R8
+  CMPQ          R8, $12
+  JAE           lane
+  VMOVQ         X5, R14
+  MOVBLZX       48(R14)(R8*1), R14               // R14 = days in the month
+  VMOVQ         X20, BX                          // BX = year
+  CMPQ          R8, $1
+  JNE           checkday
+  TESTL         $3, BX
+  JNZ           checkday
+  IMUL3Q        $5243, BX, R15
+  SHRQ          $19, R15                         // R15 = year / 100
+  IMUL3Q        $100, R15, DX
+  CMPQ          DX, BX
+  JNE           leap
+  TESTL         $3, R15
+  JNZ           checkday
+leap:
+  INCQ          R14
+checkday:
+  VMOVQ         X22, DX
+  DECQ          DX
+  CMPQ          DX, R14
+  JAE           lane
+  VMOVQ         X23, R15
+  CMPQ          R15, $23
+  JA            lane
+  VMOVQ         X24, R15
+  CMPQ          R15, $59
+  JA            lane
+  VMOVQ         X25, R15
+  CMPQ          R15, $59
+  JA            lane
+  // compute the number of days since the Unix epoch as
+  //   365*y + y/4 - y/100 + y/400 + (153*m + 2)/5 + day - 1 - 865565
+  // where y is the year (starting in March) plus 400
+  // and m is the month starting with March = 0
+  ADDQ          $10, R8
+  CMPQ          R8, $12
+  JAE           march
+  DECQ          BX
+  JMP           shifted
+march:
+  SUBQ          $12, R8
+shifted:
+  ADDQ          $400, BX
+  IMUL3Q        $153, R8, R8
+  ADDQ          $2, R8
+  IMUL3Q        $13108, R8, R8
+  SHRQ          $16, R8                          // R8 = (153*m + 2)/5
+  ADDQ          DX, R8                           // + day - 1
+  IMUL3Q        $365, BX, R14
+  ADDQ          R14, R8
+  MOVQ          BX, R14
+  SHRQ          $2, R14
+  ADDQ          R14, R8
+  IMUL3Q        $5243, BX, R14
+  SHRQ          $19, R14                         // R14 = y / 100
+  SUBQ          R14, R8
+  SHRQ          $2, R14
+  ADDQ          R14, R8
+  SUBQ          $865565, R8                      // R8 = days
+  IMUL3Q        $86400, R8, R8
+  VMOVQ         X23, R14
+  IMUL3Q        $3600, R14, R14
+  ADDQ          R14, R8
+  VMOVQ         X24, R14
+  IMUL3Q        $60, R14, R14
+  ADDQ          R14, R8
+  VMOVQ         X25, R14
+  ADDQ          R14, R8
+  VMOVQ         X27, R14
+  SUBQ          R14, R8                          // R8 = seconds
+  IMUL3Q        $1000000, R8, R8
+  VMOVQ         X26, R14
+  ADDQ          R14, R8                          // R8 = microseconds
+  VPBROADCASTQ  R8, K2, Z16
+  KSHIFTRW      $8, K2, K3
+  VPBROADCASTQ  R8, K3, Z17
+  KMOVW         K2, R8
+  VMOVQ         X10, R15
+  ORL           R8, R15
+  VMOVQ         R15, X10
+  JMP           lane
+done:
+  VMOVDQA64     Z16, Z2
+  VMOVDQA64     Z17, Z3
+  VMOVQ         X10, R15
+  KMOVW         R15, K1
+  NEXT()
+
+// FORMAT_TIMESTAMP(format, timestamp)
+//
+// lanes with timestamps before year 0
+// or after year 9999 are removed from the mask
+TEXT bcformatts(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          8(R14), R8
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = program
+  ADDQ          R14, R8
+  VMOVQ         R8, X6                           // X6 = end of the program
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  VPXORQ        X10, X10, X10                    // X10 = formatted lanes
+  VPXORD        Z16, Z16, Z16                    // Z16/Z17 = offsets/lengths
+  VPXORD        Z17, Z17, Z17
+lane:
+  VMOVQ         X11, R15
+  TESTL         R15, R15
+  JZ            done
+  TZCNTL        R15, CX
+  BLSRL         R15, R15
+  VMOVQ         R15, X11
+  MOVL          $1, R15
+  SHLL          CX, R15
+  KMOVW         R15, K2
+  VMOVQ         CX, X13
+  CMPL          CX, $8
+  JAE           high
+  VPERMQ        Z2, Z13, Z14
+  JMP           loaded
+high:
+  VPERMQ        Z3, Z13, Z14
+loaded:
+  VMOVQ         X14, R8
+  // shift the timestamp so that it is positive
+  // and starts at March 1st of year -400
+  MOVQ          $74784816000000000, R14
+  ADDQ          R14, R8
+  JS            lane
+  MOVQ          $86400000000, R14
+  CALL          tsudiv(SB)
+  VMOVQ         R8, X8                           // X8 = days
+  MOVQ          R14, R8
+  MOVL          $1000000, R14
+  CALL          tsudiv(SB)
+  VMOVQ         R14, X26
+  MOVL          $3600, R14
+  CALL          tsudiv(SB)
+  VMOVQ         R8, X23
+  MOVQ          R14, R8
+  MOVL          $60, R14
+  CALL          tsudiv(SB)
+  VMOVQ         R8, X24
+  VMOVQ         R14, X25
+  // convert days to a date
+  VMOVQ         X8, R8
+  MOVL          $146097, R14
+  CALL          tsudiv(SB)
+  IMUL3Q        $400, R8, BX                     // BX = 400*era
+  MOVQ          R14, R13                         // R13 = day of the era
+  MOVQ          R13, R8
+  MOVL          $1460, R14
+  CALL          tsudiv(SB)
+  MOVQ          R13, R15
+  SUBQ          R8, R15
+  MOVQ          R13, R8
+  MOVL          $36524, R14
+  CALL          tsudiv(SB)
+  ADDQ          R8, R15
+  MOVQ          R13, R8
+  MOVL          $146096, R14
+  CALL          tsudiv(SB)
+  SUBQ          R8, R15
+  MOVQ          R15, R8
+  MOVL          $365, R14
+  CALL          tsudiv(SB)                       // R8 = year of the era
+  ADDQ          R8, BX
+  IMUL3Q        $365, R8, R15
+  SUBQ          R15, R13
+  MOVQ          R8, R15
+  SHRQ          $2, R15
+  SUBQ          R15, R13
+  IMUL3Q        $5243, R8, R15
+  SHRQ          $19, R15
+  ADDQ          R15, R13                         // R13 = day of the year
+  LEAQ          0(R13)(R13*4), R8
+  ADDQ          $2, R8
+  MOVL          $153, R14
+  CALL          tsudiv(SB)                       // R8 = month starting in March
+  IMUL3Q        $153, R8, R15
+  ADDQ          $2, R15
+  IMUL3Q        $13108, R15, R15
+  SHRQ          $16, R15
+  SUBQ          R15, R13
+  INCQ          R13
+  VMOVQ         R13, X22
+  ADDQ          $3, R8
+  CMPQ          R8, $12
+  JBE           setmonth
+  SUBQ          $12, R8
+  INCQ          BX
+setmonth:
+  VMOVQ         R8, X21
+  SUBQ          $400, BX
+  JB            lane
+  CMPQ          BX, $9999
+  JA            lane
+  VMOVQ         BX, X20
+  // write the items to the scratch buffer
+  VMOVQ         X5, R8
+  MOVL          60(R8), R15
+  VMOVQ         R15, X9                          // X9 = width
+  VM_CHECK_SCRATCH_CAPACITY(R15, R14, abort)
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X12                          // X12 = offset of the output
+  MOVQ          bytecode_scratch(VIRT_BCPTR), R13
+  ADDQ          bytecode_scratch+8(VIRT_BCPTR), R13
+  VMOVQ         X5, R8
+  ADDQ          $64, R8
+  VMOVQ         R8, X7                           // X7 = current item
+item:
+  VMOVQ         X7, R8
+  VMOVQ         X6, R14
+  CMPQ          R8, R14
+  JAE           finish
+  MOVBLZX       0(R8), CX
+  MOVBLZX       1(R8), DX
+  ADDQ          $2, R8
+  VMOVQ         R8, X7
+  TESTL         CX, CX
+  JZ            literal
+  CMPL          CX, $1
+  JEQ           year
+  CMPL          CX, $8
+  JEQ           fraction
+  CMPL          CX, $9
+  JEQ           monthname
+  CMPL          CX, $10
+  JEQ           zone
+  CMPL          CX, $2
+  JEQ           month
+  CMPL          CX, $5
+  JB            day
+  JEQ           hour
+  CMPL          CX, $6
+  JEQ           minute
+  VMOVQ         X25, BX
+  JMP           twodigits
+month:
+  VMOVQ         X21, BX
+  JMP           twodigits
+day:
+  VMOVQ         X22, BX
+  CMPL          CX, $4
+  JNE           twodigits
+  CMPQ          BX, $10
+  JAE           twodigits
+  MOVB          $0x20, 0(R13)
+  ADDL          $0x30, BX
+  MOVB          BX, 1(R13)
+  ADDQ          $2, R13
+  JMP           item
+hour:
+  VMOVQ         X23, BX
+  JMP           twodigits
+minute:
+  VMOVQ         X24, BX
+twodigits:
+  MOVL          $2, DX
+  CALL          tsputdigits(SB)
+  ADDQ          $2, R13
+  JMP           item
+literal:
+  MOVB          DX, 0(R13)
+  INCQ          R13
+  JMP           item
+year:
+  VMOVQ         X20, BX
+  MOVL          $4, DX
+  CALL          tsputdigits(SB)
+  ADDQ          $4, R13
+  JMP           item
+fraction:
+  VMOVQ         X26, BX
+  MOVL          $6, DX
+  CALL          tsputdigits(SB)
+  ADDQ          $6, R13
+  JMP           item
+monthname:
+  VMOVQ         X21, BX
+  VMOVQ         X5, R8
+  MOVL          -4(R8)(BX*4), R15
+  MOVW          R15, 0(R13)
+  SHRL          $16, R15
+  MOVB          R15, 2(R13)
+  ADDQ          $3, R13
+  JMP           item
+zone:
+  MOVL          $0x3030302b, 0(R13)              // "+000"
+  MOVB          $0x30, 4(R13)
+  ADDQ          $5, R13
+  JMP           item
+finish:
+  VMOVQ         X9, R8
+  ADDQ          R8, bytecode_scratch+8(VIRT_BCPTR)
+  VMOVQ         X12, R15
+  VPBROADCASTD  R15, K2, Z16
+  VPBROADCASTD  R8, K2, Z17
+  KMOVW         K2, R8
+  VMOVQ         X10, R15
+  ORL           R8, R15
+  VMOVQ         R15, X10
+  JMP           lane
+done:
+  VMOVDQA32     Z16, Z2
+  VMOVDQA32     Z17, Z3
+  VMOVQ         X10, R15
+  KMOVW         R15, K1
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+//; #endregion timestamp formats
+
+//; #region regexp functions
+//
+// REGEXP_EXTRACT, REGEXP_COUNT and REGEXP_REPLACE
+// use the byte DFAs of the compiled program (see
+// regexp.go), which is kept in X5
+
+// regexprun runs the DFA at R8 over the string from CX up to DX
+// and returns in BX the longest (or earliest, depending on the
+// flags of the DFA) position at which the DFA accepts, or -1
+//
+// clobbers CX, R14, R15
+TEXT regexprun(SB), NOSPLIT|NOFRAME, $0
+  MOVQ          $-1, BX
+  MOVL          4(R8), R15                       // R15 = start state
+  TESTQ         CX, CX
+  JNZ           loop
+  MOVL          0(R8), R15                       // R15 = start state at the start of the text
+loop:
+  TESTL         $const_regexpDFAAccept, R15
+  JZ            noaccept
+  MOVQ          CX, BX
+  TESTL         $const_regexpDFAEarliest, const_regexpDFAFlags(R8)
+  JNZ           done
+noaccept:
+  CMPQ          CX, DX
+  JAE           end
+  MOVBLZX       0(R13)(CX*1), R14
+  MOVBLZX       const_regexpDFAClasses(R8)(R14*1), R14 // R14 = equivalence class
+  ANDL          $-4, R15
+  ADDQ          R8, R15
+  MOVL          const_regexpDFARows(R15)(R14*4), R15 // R15 = next state
+  INCQ          CX
+  TESTL         R15, R15
+  JNZ           loop
+  RET                                            // dead state
+end:
+  TESTL         $const_regexpDFAAcceptAtEnd, R15
+  JZ            done
+  VMOVQ         X4, R14
+  CMPQ          DX, R14
+  JNE           done                             // not the end of the text
+  MOVQ          DX, BX
+done:
+  RET
+
+// regexpnext finds the leftmost-longest match
+// at or after position CX and returns its start
+// in BX and its end in DX, or -1 in BX
+//
+// clobbers CX, R8, R14, R15, X6, X7
+TEXT regexpnext(SB), NOSPLIT|NOFRAME, $0
+  VMOVQ         CX, X6                           // X6 = position
+  VMOVQ         X5, R8
+  MOVL          const_regexpFind(R8), R14
+  ADDQ          R14, R8
+  VMOVQ         X4, DX
+  CALL          regexprun(SB)
+  TESTQ         BX, BX
+  JS            none
+  VMOVQ         BX, X7                           // X7 = the earliest end of any match
+start:
+  VMOVQ         X5, R8
+  MOVL          const_regexpMatch(R8), R14
+  ADDQ          R14, R8
+  VMOVQ         X6, CX
+  VMOVQ         X4, DX
+  CALL          regexprun(SB)
+  TESTQ         BX, BX
+  JNS           found
+  VMOVQ         X6, CX
+  INCQ          CX
+  VMOVQ         CX, X6
+  VMOVQ         X7, R14
+  CMPQ          CX, R14
+  JBE           start
+none:
+  MOVQ          $-1, BX
+  RET
+found:
+  MOVQ          BX, DX
+  VMOVQ         X6, BX
+  RET
+
+// regexpbounds stores the boundaries of the pieces
+// of the match from BX to DX into the spill area
+// as uint32s (see regexp2.Matcher.Bounds)
+//
+// clobbers BX, CX, DX, R8, R14, R15, X8, X9, X10
+TEXT regexpbounds(SB), NOSPLIT|NOFRAME, $0
+  VMOVQ         X5, R8
+  MOVL          const_regexpNumPieces(R8), R14
+  MOVL          BX, bytecode_spillArea(VIRT_BCPTR)
+  MOVL          DX, bytecode_spillArea(VIRT_BCPTR)(R14*4)
+  VMOVQ         DX, X8                           // X8 = end of the match
+  XORL          R15, R15
+piece:
+  VMOVQ         R15, X9                          // X9 = j
+  INCQ          R15
+  VMOVQ         X5, R8
+  CMPL          R15, const_regexpNumPieces(R8)
+  JAE           done
+  VMOVQ         X8, DX
+  VMOVQ         DX, X10                          // X10 = q
+try:
+  VMOVQ         X9, R15
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(R15*4), CX // CX = b[j]
+  VMOVQ         X10, DX
+  CMPQ          DX, CX
+  JBE           accept
+  // does piece j match [b[j], q)?
+  VMOVQ         X5, R8
+  MOVL          const_regexpHeaderSize(R8)(R15*8), R14
+  ADDQ          R14, R8
+  CALL          regexprun(SB)
+  CMPQ          BX, DX
+  JNE           next
+  // do the remaining pieces match [q, end)?
+  MOVQ          DX, CX
+  VMOVQ         X9, R15
+  VMOVQ         X5, R8
+  MOVL          (const_regexpHeaderSize+4)(R8)(R15*8), R14
+  ADDQ          R14, R8
+  VMOVQ         X8, DX
+  CALL          regexprun(SB)
+  CMPQ          BX, DX
+  JEQ           accept
+next:
+  VMOVQ         X10, DX
+  DECQ          DX
+  VMOVQ         DX, X10
+  JMP           try
+accept:
+  VMOVQ         X9, R15
+  VMOVQ         X10, DX
+  MOVL          DX, (bytecode_spillArea+4)(VIRT_BCPTR)(R15*4)
+  INCQ          R15
+  JMP           piece
+done:
+  RET
+
+// REGEXP_EXTRACT(str, pattern, group)
+//
+// the result is the span of the capture group
+// of the first match, or MISSING if the pattern
+// does not match
+TEXT bcregexpextract(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5
+  KMOVW         K1, R15
+  VMOVQ         R15, X11                         // X11 = remaining lanes
+  KXORW         K3, K3, K3                       // K3 = lanes with a match
+lane:
+  STR_LANE_BEGIN(done)
+  XORL          CX, CX
+  CALL          regexpnext(SB)
+  TESTQ         BX, BX
+  JS            lane
+  VMOVQ         X5, R8
+  MOVL          const_regexpGroup(R8), R14
+  CMPL          R14, $-1
+  JEQ           found                            // the complete match
+  VMOVQ         R14, X15
+  CALL          regexpbounds(SB)
+  VMOVQ         X15, R14
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(R14*4), BX
+  MOVL          (bytecode_spillArea+4)(VIRT_BCPTR)(R14*4), DX
+found:
+  SUBQ          BX, DX                           // DX = length
+  ADDQ          R13, BX
+  SUBQ          SI, BX                           // BX = offset
+  VPBROADCASTD  BX, K2, Z2
+  VPBROADCASTD  DX, K2, Z3
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  KMOVW         K3, K1
+  NEXT()
+
+// REGEXP_COUNT(str, pattern)
+//
+// the result is the number of non-overlapping
+// matches (see regexp2.Matcher.Matches)
+TEXT bcregexpcount(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5
+  KMOVW         K1, R15
+  VMOVQ         R15, X11                         // X11 = remaining lanes
+  VPXORD        Z16, Z16, Z16                    // Z16 = counts
+lane:
+  STR_LANE_BEGIN(done)
+  XORL          CX, CX
+  VMOVQ         CX, X17                          // X17 = position
+  VMOVQ         CX, X24                          // X24 = count
+  DECQ          CX
+  VMOVQ         CX, X18                          // X18 = end of the previous match
+match:
+  VMOVQ         X17, CX
+  VMOVQ         X4, R14
+  CMPQ          CX, R14
+  JA            lanedone
+  CALL          regexpnext(SB)
+  TESTQ         BX, BX
+  JS            lanedone
+  MOVQ          DX, CX
+  CMPQ          BX, DX
+  JNE           counted
+  // an empty match abutting the previous match is ignored
+  VMOVQ         X18, R14
+  CMPQ          BX, R14
+  JEQ           skip
+counted:
+  VMOVQ         X24, R14
+  INCQ          R14
+  VMOVQ         R14, X24
+  VMOVQ         DX, X18
+  CMPQ          BX, DX
+  JNE           advanced
+skip:
+  CALL          strcharwidth(SB)
+advanced:
+  VMOVQ         CX, X17
+  JMP           match
+lanedone:
+  VMOVQ         X24, R14
+  VPBROADCASTD  R14, K2, Z16
+  JMP           lane
+done:
+  VPMOVZXDQ     Y16, Z2
+  VEXTRACTI32X8 $1, Z16, Y16
+  VPMOVZXDQ     Y16, Z3
+  NEXT()
+
+// REGEXP_REPLACE(str, pattern, replacement)
+//
+// the result is written to the scratch buffer
+TEXT bcregexpreplace(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5
+  KMOVW         K1, R15
+  VMOVQ         R15, X11                         // X11 = remaining lanes
+lane:
+  STR_LANE_BEGIN(done)
+  XORL          CX, CX
+  VMOVQ         CX, X17                          // X17 = position
+  VMOVQ         CX, X19                          // X19 = end of the copied input
+  DECQ          CX
+  VMOVQ         CX, X18                          // X18 = end of the previous match
+  VM_GET_SCRATCH_BASE_GP(R14)
+  VMOVQ         R14, X20                         // X20 = offset of the output
+match:
+  VMOVQ         X17, CX
+  VMOVQ         X4, R14
+  CMPQ          CX, R14
+  JA            lanedone
+  CALL          regexpnext(SB)
+  TESTQ         BX, BX
+  JS            lanedone
+  MOVQ          DX, CX
+  CMPQ          BX, DX
+  JNE           replace
+  // an empty match abutting the previous match is ignored
+  VMOVQ         X18, R14
+  CMPQ          BX, R14
+  JEQ           skip
+replace:
+  VMOVQ         BX, X21                          // X21 = start of the match
+  VMOVQ         DX, X22                          // X22 = end of the match
+  VMOVQ         X19, R14
+  MOVQ          BX, CX
+  SUBQ          R14, CX
+  ADDQ          R13, R14
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X5, R8
+  CMPL          const_regexpNumPieces(R8), $0
+  JEQ           items
+  VMOVQ         X21, BX
+  VMOVQ         X22, DX
+  CALL          regexpbounds(SB)
+items:
+  XORL          CX, CX
+item:
+  VMOVQ         X5, R8
+  CMPL          CX, const_regexpNumRepl(R8)
+  JAE           replaced
+  VMOVQ         CX, X23                          // X23 = index of the item
+  MOVL          const_regexpRepl(R8), R14
+  ADDQ          R14, R8
+  LEAQ          0(R8)(CX*8), R8                  // R8 = item
+  MOVL          0(R8), R14
+  MOVL          4(R8), CX
+  CMPL          R14, $-1
+  JEQ           group
+  VMOVQ         X5, R8
+  ADDQ          R8, R14                          // literal
+  JMP           append
+group:
+  CMPL          CX, $-1
+  JEQ           whole
+  MOVL          bytecode_spillArea(VIRT_BCPTR)(CX*4), R14
+  MOVL          (bytecode_spillArea+4)(VIRT_BCPTR)(CX*4), CX
+  SUBQ          R14, CX
+  ADDQ          R13, R14
+  JMP           append
+whole:
+  VMOVQ         X21, R14
+  VMOVQ         X22, CX
+  SUBQ          R14, CX
+  ADDQ          R13, R14
+append:
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VMOVQ         X23, CX
+  INCQ          CX
+  JMP           item
+replaced:
+  VMOVQ         X21, BX
+  VMOVQ         X22, DX
+  VMOVQ         DX, X19
+  VMOVQ         DX, X18
+  MOVQ          DX, CX
+  CMPQ          BX, DX
+  JNE           advanced
+skip:
+  CALL          strcharwidth(SB)
+advanced:
+  VMOVQ         CX, X17
+  JMP           match
+lanedone:
+  VMOVQ         X19, R14
+  VMOVQ         X4, CX
+  SUBQ          R14, CX
+  ADDQ          R13, R14
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JS            abort
+  VM_GET_SCRATCH_BASE_GP(DX)
+  VMOVQ         X20, BX
+  SUBQ          BX, DX                           // DX = length of the output
+  VPBROADCASTD  BX, K2, Z2
+  VPBROADCASTD  DX, K2, Z3
+  JMP           lane
+done:
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+//; #endregion regexp functions
+
+//; #region inet functions
+
+// inetparse4 parses the IPv4 address from CX
+// to DX in the string at R13 into the spill area
+// at offset R8; it sets BX to 4 if the address
+// is valid and to 0 otherwise (see netip.ParseAddr)
+//
+// clobbers CX, R8, R11, R14, R15
+TEXT inetparse4(SB), NOSPLIT|NOFRAME, $0
+  XORL          R11, R11                         // R11 = number of fields
+field:
+  XORL          R14, R14                         // R14 = value of the field
+  XORL          BX, BX                           // BX = number of digits
+digit:
+  CMPQ          CX, DX
+  JAE           fieldend
+  MOVBLZX       0(R13)(CX*1), R15
+  CMPL          R15, $0x2e                       // '.'
+  JEQ           fieldend
+  SUBL          $0x30, R15
+  CMPL          R15, $10
+  JAE           fail
+  CMPL          BX, $1
+  JNE           accumulate
+  TESTL         R14, R14
+  JZ            fail                             // leading zero
+accumulate:
+  LEAL          0(R14)(R14*4), R14
+  LEAL          0(R15)(R14*2), R14
+  CMPL          R14, $255
+  JA            fail
+  INCL          BX
+  INCQ          CX
+  JMP           digit
+fieldend:
+  TESTL         BX, BX
+  JZ            fail
+  MOVB          R14, bytecode_spillArea(VIRT_BCPTR)(R8*1)
+  INCQ          R8
+  INCL          R11
+  CMPQ          CX, DX
+  JAE           last
+  CMPL          R11, $4
+  JAE           fail
+  INCQ          CX                               // skip '.'
+  JMP           field
+last:
+  CMPL          R11, $4
+  JNE           fail
+  MOVL          $4, BX
+  RET
+fail:
+  XORL          BX, BX
+  RET
+
+// inetparse parses the string at R13 with the
+// length in X4 as an IPv4 or IPv6 address into
+// the first 16 bytes of the spill area (in network
+// byte order); it sets BX to the address family
+// (4 or 6), or to 0 if the string is not an address
+//
+// clobbers CX, DX, R8, R11, R14, R15, X15, X16
+TEXT inetparse(SB), NOSPLIT|NOFRAME, $0
+  XORL          CX, CX
+  VMOVQ         X4, DX
+  XORL          R8, R8
+  CALL          inetparse4(SB)
+  TESTL         BX, BX
+  JZ            ipv6
+  RET
+ipv6:
+  XORL          CX, CX                           // CX = position
+  XORL          R11, R11                         // R11 = number of bytes
+  MOVQ          $-1, BX
+  VMOVQ         BX, X15                          // X15 = position of '::' in bytes
+  CMPQ          DX, $2
+  JB            group
+  CMPW          0(R13), $0x3a3a                  // leading '::'
+  JNE           group
+  XORL          BX, BX
+  VMOVQ         BX, X15
+  MOVL          $2, CX
+  CMPQ          CX, DX
+  JAE           expand
+group:
+  XORL          R14, R14                         // R14 = value of the group
+  XORL          R8, R8                           // R8 = number of digits
+hex:
+  CMPQ          CX, DX
+  JAE           groupend
+  MOVBLZX       0(R13)(CX*1), R15
+  LEAL          -0x30(R15), BX
+  CMPL          BX, $10
+  JB            hexdigit
+  ORL           $0x20, R15
+  LEAL          -0x61(R15), BX
+  CMPL          BX, $6
+  JAE           groupend
+  ADDL          $10, BX
+hexdigit:
+  CMPL          R8, $4
+  JAE           fail
+  SHLL          $4, R14
+  ORL           BX, R14
+  INCL          R8
+  INCQ          CX
+  JMP           hex
+groupend:
+  TESTL         R8, R8
+  JZ            fail
+  CMPQ          CX, DX
+  JAE           store
+  MOVBLZX       0(R13)(CX*1), R15
+  CMPL          R15, $0x2e                       // '.'
+  JEQ           embedded
+store:
+  ROLW          $8, R14
+  MOVW          R14, bytecode_spillArea(VIRT_BCPTR)(R11*1)
+  ADDQ          $2, R11
+  CMPQ          CX, DX
+  JAE           expand
+  CMPL          R15, $0x3a                       // ':'
+  JNE           fail
+  INCQ          CX
+  CMPQ          CX, DX
+  JAE           fail
+  CMPB          0(R13)(CX*1), $0x3a
+  JNE           next
+  VMOVQ         X15, BX
+  TESTQ         BX, BX
+  JNS           fail                             // more than one '::'
+  VMOVQ         R11, X15
+  INCQ          CX
+  CMPQ          CX, DX
+  JAE           expand
+next:
+  CMPQ          R11, $16
+  JB            group
+  CMPQ          CX, DX
+  JNE           fail
+  JMP           expand
+embedded:
+  // an IPv4 address replaces the last two groups
+  VMOVQ         X15, BX
+  TESTQ         BX, BX
+  JNS           room
+  CMPQ          R11, $12
+  JNE           fail
+room:
+  CMPQ          R11, $12
+  JA            fail
+  SUBQ          R8, CX
+  VMOVQ         R11, X16
+  MOVQ          R11, R8
+  CALL          inetparse4(SB)
+  TESTL         BX, BX
+  JZ            fail
+  VMOVQ         X16, R11
+  ADDQ          $4, R11
+expand:
+  VMOVQ         X15, BX                          // BX = position of '::'
+  CMPQ          R11, $16
+  JAE           complete
+  TESTQ         BX, BX
+  JS            fail
+  MOVL          $16, R8
+  SUBQ          R11, R8                          // R8 = number of zero bytes
+move:
+  CMPQ          R11, BX
+  JBE           zero
+  DECQ          R11
+  MOVBLZX       bytecode_spillArea(VIRT_BCPTR)(R11*1), R15
+  LEAQ          0(R11)(R8*1), R14
+  MOVB          R15, bytecode_spillArea(VIRT_BCPTR)(R14*1)
+  JMP           move
+zero:
+  MOVB          $0, bytecode_spillArea(VIRT_BCPTR)(BX*1)
+  INCQ          BX
+  DECQ          R8
+  JNZ           zero
+  JMP           ok
+complete:
+  TESTQ         BX, BX
+  JNS           fail                             // '::' must replace at least one group
+ok:
+  MOVL          $6, BX
+  RET
+fail:
+  XORL          BX, BX
+  RET
+
+// inetdec writes the decimal digits
+// of R14 (< 256) to R8 and advances R8
+//
+// clobbers BX, R14, R15
+TEXT inetdec(SB), NOSPLIT|NOFRAME, $0
+  CMPL          R14, $100
+  JB            below100
+  IMUL3L        $41, R14, R15
+  SHRL          $12, R15                         // R15 = R14 / 100
+  LEAL          0x30(R15), BX
+  MOVB          BX, 0(R8)
+  INCQ          R8
+  IMUL3L        $100, R15, R15
+  SUBL          R15, R14
+  JMP           tens
+below100:
+  CMPL          R14, $10
+  JB            ones
+tens:
+  IMUL3L        $205, R14, R15
+  SHRL          $11, R15                         // R15 = R14 / 10
+  LEAL          0x30(R15), BX
+  MOVB          BX, 0(R8)
+  INCQ          R8
+  LEAL          0(R15)(R15*4), R15
+  ADDL          R15, R15
+  SUBL          R15, R14
+ones:
+  ADDL          $0x30, R14
+  MOVB          R14, 0(R8)
+  INCQ          R8
+  RET
+
+// inethex writes the lower-case hexadecimal digits
+// of R14 (< 65536) without leading zeros to R8 and
+// advances R8
+//
+// clobbers BX, CX, R15
+TEXT inethex(SB), NOSPLIT|NOFRAME, $0
+  MOVL          $12, CX
+  XORL          BX, BX                           // BX != 0 after the first non-zero digit
+nibble:
+  MOVL          R14, R15
+  SHRL          CX, R15
+  ANDL          $15, R15
+  ORL           R15, BX
+  TESTL         CX, CX
+  JZ            emit
+  TESTL         BX, BX
+  JZ            skip
+emit:
+  CMPL          R15, $10
+  JB            decimal
+  ADDL          $0x27, R15                       // 'a' - '0' - 10
+decimal:
+  ADDL          $0x30, R15
+  MOVB          R15, 0(R8)
+  INCQ          R8
+skip:
+  SUBL          $4, CX
+  JNS           nibble
+  RET
+
+// inetformat writes the canonical text form (see
+// netip.Addr.String) of the address in the spill
+// area of the family in BX to R8 and advances R8
+//
+// clobbers BX, CX, DX, R11, R14, R15, X16, X17
+TEXT inetformat(SB), NOSPLIT|NOFRAME, $0
+  XORL          R11, R11                         // R11 = first byte of an IPv4 address
+  CMPL          BX, $4
+  JEQ           ipv4
+  MOVQ          bytecode_spillArea(VIRT_BCPTR), R15
+  TESTQ         R15, R15
+  JNZ           ipv6
+  CMPL          (bytecode_spillArea+8)(VIRT_BCPTR), $0xffff0000
+  JNE           ipv6
+  // an IPv4-mapped IPv6 address is written as ::ffff:a.b.c.d
+  MOVQ          $0x003a666666663a3a, R15
+  MOVQ          R15, 0(R8)
+  ADDQ          $7, R8
+  MOVL          $12, R11
+ipv4:
+  LEAQ          4(R11), DX
+  JMP           octet
+dot:
+  MOVB          $0x2e, 0(R8)
+  INCQ          R8
+octet:
+  MOVBLZX       bytecode_spillArea(VIRT_BCPTR)(R11*1), R14
+  CALL          inetdec(SB)
+  INCQ          R11
+  CMPQ          R11, DX
+  JB            dot
+  RET
+ipv6:
+  // find the first longest run of
+  // (at least two) zero groups
+  MOVQ          $-1, R11                         // R11 = first group of the run
+  MOVL          $1, BX                           // BX = length of the run
+  XORL          CX, CX
+scan:
+  MOVQ          CX, DX
+run:
+  CMPQ          DX, $8
+  JAE           runend
+  MOVWLZX       bytecode_spillArea(VIRT_BCPTR)(DX*2), R15
+  TESTL         R15, R15
+  JNZ           runend
+  INCQ          DX
+  JMP           run
+runend:
+  SUBQ          CX, DX
+  CMPQ          DX, BX
+  JBE           shorter
+  MOVQ          CX, R11
+  MOVQ          DX, BX
+shorter:
+  INCQ          CX
+  CMPQ          CX, $8
+  JB            scan
+  VMOVQ         R11, X16                         // X16 = first group of the run
+  ADDQ          R11, BX
+  VMOVQ         BX, X17                          // X17 = end of the run
+  XORL          DX, DX                           // DX = group
+groups:
+  VMOVQ         X16, R11
+  CMPQ          DX, R11
+  JNE           colon
+  MOVW          $0x3a3a, 0(R8)
+  ADDQ          $2, R8
+  VMOVQ         X17, DX
+  CMPQ          DX, $8
+  JAE           done
+  JMP           group
+colon:
+  TESTQ         DX, DX
+  JZ            group
+  MOVB          $0x3a, 0(R8)
+  INCQ          R8
+group:
+  MOVWLZX       bytecode_spillArea(VIRT_BCPTR)(DX*2), R14
+  ROLW          $8, R14
+  CALL          inethex(SB)
+  INCQ          DX
+  CMPQ          DX, $8
+  JB            groups
+done:
+  RET
+
+// INET_PARSE(str) and INET_TRUNC(str, bits)
+//
+// the immediate is the mask of the address
+// (see inetMask); the result is written to
+// the scratch buffer
+TEXT bcinetparse(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = mask
+  KMOVW         K1, R15
+  VMOVQ         R15, X11                         // X11 = remaining lanes
+  KXORW         K3, K3, K3                       // K3 = lanes with an address
+lane:
+  STR_LANE_BEGIN(done)
+  CALL          inetparse(SB)
+  VMOVQ         X5, R14
+  CMPL          BX, $4
+  JEQ           ipv4
+  CMPL          BX, $6
+  JNE           lane
+  TESTL         $const_inetFamily6, const_inetFamilies(R14)
+  JZ            lane
+  MOVQ          (const_inetMask6+0)(R14), R15
+  ANDQ          R15, bytecode_spillArea(VIRT_BCPTR)
+  MOVQ          (const_inetMask6+8)(R14), R15
+  ANDQ          R15, (bytecode_spillArea+8)(VIRT_BCPTR)
+  JMP           format
+ipv4:
+  TESTL         $const_inetFamily4, const_inetFamilies(R14)
+  JZ            lane
+  MOVL          const_inetMask4(R14), R15
+  ANDL          R15, bytecode_spillArea(VIRT_BCPTR)
+format:
+  MOVQ          bytecode_scratch+16(VIRT_BCPTR), R15
+  SUBQ          bytecode_scratch+8(VIRT_BCPTR), R15
+  CMPQ          R15, $const_inetMaxTextSize
+  JB            abort
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  MOVQ          bytecode_scratch(VIRT_BCPTR), R8
+  ADDQ          bytecode_scratch+8(VIRT_BCPTR), R8
+  CALL          inetformat(SB)
+  SUBQ          bytecode_scratch(VIRT_BCPTR), R8
+  MOVQ          R8, bytecode_scratch+8(VIRT_BCPTR)
+  STR_LANE_SCRATCH_RESULT()
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  KMOVW         K3, K1
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// INET_FAMILY(str)
+TEXT bcinetfamily(SB), NOSPLIT|NOFRAME, $0
+  KMOVW         K1, R15
+  VMOVQ         R15, X11                         // X11 = remaining lanes
+  KXORW         K3, K3, K3                       // K3 = lanes with an address
+  VPXORD        Z20, Z20, Z20                    // Z20 = families
+lane:
+  STR_LANE_BEGIN(done)
+  CALL          inetparse(SB)
+  TESTL         BX, BX
+  JZ            lane
+  VPBROADCASTD  BX, K2, Z20
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  VPMOVZXDQ     Y20, Z2
+  VEXTRACTI32X8 $1, Z20, Y20
+  VPMOVZXDQ     Y20, Z3
+  KMOVW         K3, K1
+  NEXT()
+
+// IS_SUBNET_OF(min, max, str) for IPv6 addresses
+//
+// the immediate is min and max as pairs of
+// integers (see prog.IsSubnetOfIP6)
+TEXT bcIsSubnetOfIP6(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = min and max
+  KMOVW         K1, R15
+  VMOVQ         R15, X11                         // X11 = remaining lanes
+  KXORW         K3, K3, K3                       // K3 = lanes in the range
+lane:
+  STR_LANE_BEGIN(done)
+  CALL          inetparse(SB)
+  CMPL          BX, $6
+  JNE           lane
+  VMOVQ         X5, R14
+  MOVQ          bytecode_spillArea(VIRT_BCPTR), CX
+  BSWAPQ        CX                               // CX = upper half of the address
+  MOVQ          (bytecode_spillArea+8)(VIRT_BCPTR), DX
+  BSWAPQ        DX                               // DX = lower half of the address
+  CMPQ          CX, 0(R14)
+  JB            lane
+  JA            abovemin
+  CMPQ          DX, 8(R14)
+  JB            lane
+abovemin:
+  CMPQ          CX, 16(R14)
+  JA            lane
+  JB            inrange
+  CMPQ          DX, 24(R14)
+  JA            lane
+inrange:
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  KMOVW         K3, K1
+  NEXT()
+
+//; #endregion inet functions
+
+//; #region list functions
+//
+// list functions process one lane at a time
+// like the string functions above (the list
+// of the current lane is in R13 and X4)
+
+// listelem decodes the element at position DX of
+// the list of the current lane; it sets R8 to the
+// address of the element and R11 to its size and
+// advances DX past the element, or sets R11 to 0
+// if the element is malformed
+//
+// symbols are replaced with their strings
+// from the symbol table (see bcunsymbolize)
+//
+// clobbers CX, R14, R15
+TEXT listelem(SB), NOSPLIT|NOFRAME, $0
+  LEAQ          0(R13)(DX*1), R8                 // R8 = element
+  MOVBLZX       0(R8), CX
+  MOVL          CX, R14
+  ANDL          $0x0f, R14                       // R14 = size bits
+  SHRL          $4, CX                           // CX = tag
+  MOVL          $1, R11                          // R11 = size
+  CMPL          CX, $1
+  JEQ           bounds                           // booleans are one byte
+  CMPL          R14, $0x0f
+  JEQ           bounds                           // nulls are one byte
+  CMPL          R14, $0x0e
+  JEQ           varint
+  ADDL          R14, R11
+  JMP           bounds
+varint:
+  XORL          R14, R14                         // R14 = length
+varbyte:
+  CMPL          R11, $4
+  JA            fail
+  LEAQ          0(DX)(R11*1), CX
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JAE           fail
+  MOVBLZX       0(R13)(CX*1), CX
+  INCL          R11
+  SHLL          $7, R14
+  MOVL          CX, R15
+  ANDL          $0x7f, R15
+  ORL           R15, R14
+  TESTL         $0x80, CX                        // last byte?
+  JZ            varbyte
+  ADDL          R14, R11
+bounds:
+  LEAQ          0(DX)(R11*1), CX
+  VMOVQ         X4, R15
+  CMPQ          CX, R15
+  JA            fail
+  MOVQ          CX, DX
+  MOVBLZX       0(R8), CX
+  MOVL          CX, R15
+  SHRL          $4, CX
+  CMPL          CX, $7
+  JNE           done                             // not a symbol
+  ANDL          $0x0f, R15                       // R15 = size of the ID
+  CMPL          R15, $4
+  JA            done
+  XORL          R14, R14                         // R14 = ID
+  MOVL          $1, CX
+symbyte:
+  CMPL          CX, R15
+  JA            lookup
+  SHLL          $8, R14
+  MOVBLZX       0(R8)(CX*1), R11
+  ORL           R11, R14
+  INCL          CX
+  JMP           symbyte
+lookup:
+  LEAL          1(R15), R11                      // R11 = size of the symbol
+  // only replace symbols where id < len(symtab)
+  CMPQ          R14, bytecode_symtab+8(VIRT_BCPTR)
+  JAE           done
+  MOVQ          bytecode_symtab+0(VIRT_BCPTR), R15
+  MOVL          4(R15)(R14*8), R11
+  MOVL          0(R15)(R14*8), R8
+  ADDQ          SI, R8
+done:
+  RET
+fail:
+  XORL          R11, R11
+  RET
+
+// listeq sets ZF if the R11 bytes at R8
+// are equal to the R14 bytes at CX
+//
+// clobbers CX, R8, R11, R15
+TEXT listeq(SB), NOSPLIT|NOFRAME, $0
+  CMPQ          R11, R14
+  JNE           done
+loop:
+  TESTQ         R11, R11
+  JZ            done
+  MOVBLZX       0(R8), R15
+  CMPB          R15, 0(CX)
+  JNE           done
+  INCQ          R8
+  INCQ          CX
+  DECQ          R11
+  JMP           loop
+done:
+  RET
+
+// LIST_LANE_BEGIN(abort) reserves space for the
+// header of a list in the scratch buffer and sets
+// X21 to its offset; the elements of the list are
+// appended to the scratch buffer after the header
+//
+// clobbers R8, R15
+#define LIST_LANE_BEGIN(abort)                       \
+  VM_GET_SCRATCH_BASE_GP(R8)                         \
+  VMOVQ         R8, X21                              \
+  MOVQ          bytecode_scratch+16(VIRT_BCPTR), R15 \
+  SUBQ          bytecode_scratch+8(VIRT_BCPTR), R15  \
+  CMPQ          R15, $4                              \
+  JB            abort                                \
+  ADDQ          $4, bytecode_scratch+8(VIRT_BCPTR)
+
+// listbox writes the header of the list that was
+// started with LIST_LANE_BEGIN in front of its
+// elements and sets the value of the current lane
+// to the list
+//
+// clobbers CX, DX, R14, R15
+TEXT listbox(SB), NOSPLIT|NOFRAME, $0
+  VM_GET_SCRATCH_BASE_GP(CX)
+  VMOVQ         X21, DX
+  ADDQ          $4, DX                           // DX = offset of the elements
+  SUBQ          DX, CX                           // CX = size of the elements
+  LEAQ          0(SI)(DX*1), R14
+  CMPQ          CX, $14
+  JAE           long
+  ORL           $0xb0, CX
+  JMP           descriptor
+long:
+  // the size is encoded as a varint; it fits in
+  // three bytes because the scratch buffer is
+  // smaller than 2^21 bytes
+  MOVL          CX, R15
+  ORL           $0x80, R15
+  DECQ          R14
+  MOVB          R15, 0(R14)
+  SHRL          $7, CX
+size:
+  TESTL         CX, CX
+  JZ            varint
+  MOVL          CX, R15
+  ANDL          $0x7f, R15
+  DECQ          R14
+  MOVB          R15, 0(R14)
+  SHRL          $7, CX
+  JMP           size
+varint:
+  MOVL          $0xbe, CX
+descriptor:
+  DECQ          R14
+  MOVB          CX, 0(R14)
+  SUBQ          SI, R14                          // R14 = offset of the list
+  VM_GET_SCRATCH_BASE_GP(CX)
+  SUBQ          R14, CX                          // CX = size of the list
+  VPBROADCASTD  R14, K2, Z30
+  VPBROADCASTD  CX, K2, Z31
+  RET
+
+// ARRAY_POSITION(list, value) and ARRAY_CONTAINS(list, value)
+//
+// the immediate is the encoded value; the result
+// is the (0-based) index of the first element equal
+// to the value, and lanes without one are removed
+TEXT bcarrayposition(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          8(R14), R8
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = value
+  VMOVQ         R8, X6                           // X6 = size of the value
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  KXORW         K3, K3, K3                       // K3 = lanes with the value
+  VPXORD        Z16, Z16, Z16                    // Z16 = positions
+lane:
+  STR_LANE_BEGIN(done)
+  XORL          DX, DX                           // DX = position in the list
+  XORL          BX, BX                           // BX = index of the element
+elem:
+  VMOVQ         X4, R15
+  CMPQ          DX, R15
+  JAE           lane
+  CALL          listelem(SB)
+  TESTQ         R11, R11
+  JZ            lane
+  VMOVQ         X5, CX
+  VMOVQ         X6, R14
+  CALL          listeq(SB)
+  JEQ           found
+  INCL          BX
+  JMP           elem
+found:
+  VPBROADCASTD  BX, K2, Z16
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  VPMOVZXDQ     Y16, Z2
+  VEXTRACTI32X8 $1, Z16, Y16
+  VPMOVZXDQ     Y16, Z3
+  KMOVW         K3, K1
+  NEXT()
+
+// ARRAY_SLICE(list, from, to)
+//
+// the immediate is from and to (as uint32s;
+// to is 0xffffffff for the end of the list);
+// the result is written to the scratch buffer
+TEXT bcarrayslice(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          (R14), R14
+  MOVL          0(R14), R8
+  VMOVQ         R8, X5                           // X5 = from
+  MOVL          4(R14), R8
+  VMOVQ         R8, X6                           // X6 = to
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  KXORW         K3, K3, K3                       // K3 = lanes with a list
+lane:
+  STR_LANE_BEGIN(done)
+  XORL          DX, DX                           // DX = position in the list
+  XORL          BX, BX                           // BX = index of the element
+skip:
+  VMOVQ         X5, R15
+  CMPQ          BX, R15
+  JAE           start
+  VMOVQ         X4, R15
+  CMPQ          DX, R15
+  JAE           start
+  CALL          listelem(SB)
+  TESTQ         R11, R11
+  JZ            lane
+  INCL          BX
+  JMP           skip
+start:
+  VMOVQ         DX, X7                           // X7 = first byte of the slice
+take:
+  VMOVQ         X6, R15
+  CMPQ          BX, R15
+  JAE           copy
+  VMOVQ         X4, R15
+  CMPQ          DX, R15
+  JAE           copy
+  CALL          listelem(SB)
+  TESTQ         R11, R11
+  JZ            lane
+  INCL          BX
+  JMP           take
+copy:
+  LIST_LANE_BEGIN(abort)
+  VMOVQ         X7, R8
+  MOVQ          DX, CX
+  SUBQ          R8, CX                           // CX = size of the slice
+  LEAQ          0(R13)(R8*1), R14
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JNZ           abort
+  CALL          listbox(SB)
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  KMOVW         K3, K1
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// ARRAY_DISTINCT(list)
+//
+// the result contains the first occurrence of
+// each element and is written to the scratch buffer
+TEXT bcarraydistinct(SB), NOSPLIT|NOFRAME, $0
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  KXORW         K3, K3, K3                       // K3 = lanes with a list
+lane:
+  STR_LANE_BEGIN(done)
+  LIST_LANE_BEGIN(abort)
+  XORL          DX, DX                           // DX = position in the list
+elem:
+  VMOVQ         X4, R15
+  CMPQ          DX, R15
+  JAE           box
+  VMOVQ         DX, X7                           // X7 = position of the element
+  CALL          listelem(SB)
+  TESTQ         R11, R11
+  JZ            lane
+  VMOVQ         DX, X8                           // X8 = position of the next element
+  VMOVQ         R8, X9                           // X9 = element
+  VMOVQ         R11, X10                         // X10 = size of the element
+  XORL          DX, DX
+previous:
+  VMOVQ         X7, R15
+  CMPQ          DX, R15
+  JAE           unique
+  CALL          listelem(SB)
+  VMOVQ         X9, CX
+  VMOVQ         X10, R14
+  CALL          listeq(SB)
+  JEQ           duplicate
+  JMP           previous
+unique:
+  VMOVQ         X9, R14
+  VMOVQ         X10, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JNZ           abort
+duplicate:
+  VMOVQ         X8, DX
+  JMP           elem
+box:
+  CALL          listbox(SB)
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  KMOVW         K3, K1
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// ARRAY_JOIN(list, sep)
+//
+// the immediate is the separator; the strings in
+// the list are joined into the scratch buffer and
+// the other elements are ignored
+TEXT bcarrayjoin(SB), NOSPLIT|NOFRAME, $0
+  IMM_FROM_DICT(R14)
+  MOVQ          8(R14), R8
+  MOVQ          (R14), R14
+  VMOVQ         R14, X5                          // X5 = separator
+  VMOVQ         R8, X6                           // X6 = length of the separator
+  KMOVW         K1, R15
+  VMOVQ         R15, X11
+  KXORW         K3, K3, K3                       // K3 = lanes with a string
+lane:
+  STR_LANE_BEGIN(done)
+  VM_GET_SCRATCH_BASE_GP(R8)
+  VMOVQ         R8, X21                          // X21 = offset of the output
+  XORL          DX, DX                           // DX = position in the list
+  VMOVQ         DX, X9                           // X9 = number of strings
+elem:
+  VMOVQ         X4, R15
+  CMPQ          DX, R15
+  JAE           result
+  CALL          listelem(SB)
+  TESTQ         R11, R11
+  JZ            lane
+  MOVBLZX       0(R8), CX
+  MOVL          CX, R14
+  SHRL          $4, R14
+  CMPL          R14, $8
+  JNE           elem                             // not a string
+  ANDL          $0x0f, CX
+  CMPL          CX, $0x0f
+  JEQ           elem                             // null.string
+  INCQ          R8
+  DECQ          R11
+  CMPL          CX, $0x0e
+  JNE           separator
+length:
+  // skip the varint length
+  MOVBLZX       0(R8), CX
+  INCQ          R8
+  DECQ          R11
+  TESTL         $0x80, CX
+  JZ            length
+separator:
+  VMOVQ         X9, CX
+  INCQ          CX
+  VMOVQ         CX, X9
+  CMPQ          CX, $1
+  JEQ           append
+  VMOVQ         R8, X7
+  VMOVQ         R11, X8
+  VMOVQ         X5, R14
+  VMOVQ         X6, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JNZ           abort
+  VMOVQ         X7, R8
+  VMOVQ         X8, R11
+append:
+  MOVQ          R8, R14
+  MOVQ          R11, CX
+  CALL          strappend(SB)
+  TESTQ         BX, BX
+  JNZ           abort
+  JMP           elem
+result:
+  STR_LANE_SCRATCH_RESULT()
+  KORW          K2, K3, K3
+  JMP           lane
+done:
+  KMOVW         K3, K1
+  NEXT()
+abort:
+  MOVL          $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+#undef LIST_LANE_BEGIN
+
+//; #endregion list functions
+
+#undef STR_LANE_BEGIN
+#undef STR_LANE_RESULT
+#undef STR_LANE_SCRATCH_RESULT
+//; #endregion per-lane string functions
+
 //; #endregion string methods
 
 // LOWER/UPPER functions
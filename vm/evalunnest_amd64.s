@@ -364,3 +364,57 @@ rep_movsb:
 trap:
   BYTE $0xCC
   RET
+
+// evaluate a bytecode program over the list
+// elements produced by evalsplat and store
+// the mask of matching elements for each
+// group of sixteen elements
+TEXT ·evalmatch(SB), NOSPLIT, $16
+  NO_LOCAL_POINTERS
+  XORL R9, R9             // R9 = elements consumed
+  JMP  tail
+loop:
+  CMPQ         CX, $16
+  JLT          genmask
+  KXNORW       K0, K0, K1
+doit:
+  // unpack the next 16 (or fewer) delims
+  // into Z30=indices, Z31=lengths
+  MOVQ         delims+8(FP), DX
+  VMOVDQU64.Z  0(DX)(R9*8), K1, Z2
+  KSHIFTRW     $8, K1, K2
+  VMOVDQU64.Z  64(DX)(R9*8), K2, Z3
+  VPMOVQD      Z2, Y30
+  VPMOVQD      Z3, Y31
+  VINSERTI32X8 $1, Y31, Z30, Z30
+  VPROLQ       $32, Z2, Z2
+  VPROLQ       $32, Z3, Z3
+  VPMOVQD      Z2, Y31
+  VPMOVQD      Z3, Y2
+  VINSERTI32X8 $1, Y2, Z31, Z31
+
+  MOVQ         perm+32(FP), DX
+  VMOVDQU32.Z  0(DX)(R9*4), K1, Z3
+  MOVQ         bc+0(FP), VIRT_BCPTR
+  VMOVDQU32    Z3, bytecode_perm(VIRT_BCPTR)
+
+  // enter bytecode interpretation
+  MOVQ    ·vmm+0(SB), SI  // real static base
+  VMENTER()
+  MOVQ    R9, R8
+  SHRQ    $4, R8
+  MOVQ    mask+56(FP), DX
+  KMOVW   K1, 0(DX)(R8*2) // mask[R9/16] = K1
+  ADDQ    $16, R9
+tail:
+  MOVQ    delims_len+16(FP), CX
+  SUBQ    R9, CX
+  JG      loop
+  RET
+genmask:
+  // K1 = (1 << CX)-1
+  MOVL    $1, R8
+  SHLL    CX, R8
+  SUBL    $1, R8
+  KMOVW   R8, K1
+  JMP     doit
@@ -0,0 +1,242 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/SnellerInc/sneller/expr"
+)
+
+// Exists is a QuerySink that filters rows
+// on an EXISTS predicate over the elements
+// of a list. The elements are splatted
+// the same way UnnestProjection splats them,
+// but the output rows are the input rows
+// for which the predicate holds.
+type Exists struct {
+	dst     QuerySink
+	expr    *expr.Exists
+	negated bool
+	outer   []string // outer bindings referenced in expr.Where
+}
+
+// NewExists creates a new Exists that writes
+// rows for which e is TRUE to dst, or rows for
+// which e is not TRUE if negated is set.
+//
+// Rows for which e.List is not a list have
+// no matching elements, so callers that
+// want those rows excluded from the negated
+// form have to filter them out beforehand.
+func NewExists(dst QuerySink, e *expr.Exists, negated bool) *Exists {
+	ex := &Exists{dst: dst, expr: e, negated: negated}
+	visitfn(func(e expr.Node) {
+		p, ok := e.(*expr.Path)
+		if !ok || p.First == ex.expr.Var {
+			return
+		}
+		for i := range ex.outer {
+			if ex.outer[i] == p.First {
+				return
+			}
+		}
+		ex.outer = append(ex.outer, p.First)
+	}).walk(e.Where)
+	return ex
+}
+
+type visitfn func(e expr.Node)
+
+func (v visitfn) walk(e expr.Node) { expr.Walk(v, e) }
+
+func (v visitfn) Visit(e expr.Node) expr.Visitor {
+	v(e)
+	return v
+}
+
+func (e *Exists) Open() (io.WriteCloser, error) {
+	dst, err := e.dst.Open()
+	if err != nil {
+		return nil, err
+	}
+	return splitter(&existing{parent: e, dst: asRowConsumer(dst)}), nil
+}
+
+func (e *Exists) Close() error {
+	return e.dst.Close()
+}
+
+type existing struct {
+	parent  *Exists
+	outerbc bytecode
+	innerbc bytecode
+	delims  []vmref
+	perms   []int32
+	masks   []uint16
+	dst     rowConsumer
+}
+
+func (e *existing) next() rowConsumer { return e.dst }
+
+func (e *existing) EndSegment() {
+	e.outerbc.dropScratch()
+	e.innerbc.dropScratch()
+}
+
+func (e *existing) symbolize(st *symtab) error {
+	e.outerbc.restoreScratch()
+	e.innerbc.restoreScratch()
+	outer := e.parent.outer
+	ex := e.parent.expr
+
+	// the first program stores the outer bindings
+	// and yields the list to be splatted
+	var p prog
+	p.Begin()
+	mem0 := p.InitMem()
+	mem := make([]*value, len(outer))
+	for i := range outer {
+		var err error
+		mem[i], err = p.compileStore(mem0, &expr.Path{First: outer[i]}, stackSlotFromIndex(regV, i), false)
+		if err != nil {
+			return err
+		}
+	}
+	listv, err := compile(&p, ex.List)
+	if err != nil {
+		return fmt.Errorf("exists: compiling list: %w", err)
+	}
+	list := p.tolist(listv)
+	p.Return(p.msk(p.MergeMem(mem...), list, list))
+	p.symbolize(st)
+	err = p.compile(&e.outerbc)
+	if err != nil {
+		return err
+	}
+
+	// the second program evaluates the condition
+	// for each element; the element itself is
+	// saved into a stack slot on entry and the
+	// outer bindings are loaded as upvalues
+	p.Begin()
+	mem0 = p.InitMem()
+	elem := stackSlotFromIndex(regV, len(outer))
+	p.bound = make(map[string]*value, len(outer)+1)
+	for i := range outer {
+		p.bound[outer[i]] = p.Upvalue(mem0, stackSlotFromIndex(regV, i))
+	}
+	p.bound[ex.Var] = p.Loadvalue(mem0, elem)
+	k, err := p.compileAsBool(ex.Where)
+	if err != nil {
+		return fmt.Errorf("exists: compiling condition: %w", err)
+	}
+	p.Return(p.mk(mem0, k))
+	p.symbolize(st)
+	err = p.compile(&e.innerbc)
+	if err != nil {
+		return err
+	}
+	e.innerbc.outer = &e.outerbc
+
+	// save.z [elem] (the element in Z30:Z31)
+	var asm assembler
+	asm.emitOpcode(opsavezerov)
+	asm.emitImmU16(uint16(elem))
+	e.innerbc.compiled = append(asm.grabCode(), e.innerbc.compiled...)
+	e.innerbc.ensureVStackSize(int(elem) + int(vRegSize))
+	e.innerbc.allocStacks()
+	return e.dst.symbolize(st)
+}
+
+//go:noescape
+func evalmatch(bc *bytecode, delims []vmref, perm []int32, mask []uint16)
+
+func (e *existing) writeRows(delims []vmref) error {
+	if e.outerbc.compiled == nil {
+		return fmt.Errorf("existing.writeRows() before symbolize()")
+	}
+	if len(e.delims) == 0 {
+		e.delims = make([]vmref, 1023)
+		e.perms = make([]int32, 1023)
+		e.masks = make([]uint16, (1023+15)/16)
+	}
+	// matching rows are compacted
+	// into the front of delims
+	out := delims[:0]
+	for len(delims) > 0 {
+		in, n := evalsplat(&e.outerbc, delims, e.delims, e.perms)
+		if e.outerbc.err != 0 {
+			return fmt.Errorf("exists: splatting arrays: %w", e.outerbc.err)
+		}
+		// evalsplat only stops early when it
+		// runs out of space for the elements;
+		// otherwise every lane has been handled,
+		// including trailing lanes that are not lists
+		full := n == len(e.delims)
+		if !full {
+			in = len(delims)
+			if in > 16 {
+				in = 16
+			}
+		} else if in == 0 {
+			// not enough room for the elements
+			// of a single list; try again with more
+			e.grow()
+			continue
+		}
+		evalmatch(&e.innerbc, e.delims[:n], e.perms[:n], e.masks)
+		if e.innerbc.err != 0 {
+			return fmt.Errorf("exists: bytecode error: %w", e.innerbc.err)
+		}
+		// elements past the first 'in' lanes
+		// belong to a partially-splatted list
+		// and are evaluated again next time
+		matched := uint16(0)
+		for i := 0; i < n; i++ {
+			if e.masks[i/16]&(1<<(i%16)) != 0 && int(e.perms[i]) < in {
+				matched |= 1 << e.perms[i]
+			}
+		}
+		for i := 0; i < in; i++ {
+			if (matched&(1<<i) != 0) != e.parent.negated {
+				out = append(out, delims[i])
+			}
+		}
+		if full {
+			// lets allocate more space for the
+			// next go-around to improve lane utilization
+			e.grow()
+		}
+		delims = delims[in:]
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return e.dst.writeRows(out)
+}
+
+func (e *existing) grow() {
+	e.delims = make([]vmref, 2*len(e.delims))
+	e.perms = make([]int32, 2*len(e.perms))
+	e.masks = make([]uint16, (len(e.delims)+15)/16)
+}
+
+func (e *existing) Close() error {
+	e.outerbc.reset()
+	e.innerbc.reset()
+	return e.dst.Close()
+}
@@ -18,8 +18,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"net"
+	"net/netip"
+	"time"
 
 	"github.com/SnellerInc/sneller/regexp2"
 
@@ -146,26 +149,33 @@ func compile(p *prog, e expr.Node) (*value, error) {
 			return p.RegexMatch(left, dfaStore)
 		}
 
-		// the remaining cases expect numeric arguments
-		left, err := p.compileAsNumber(n.Left)
-		if err != nil {
-			return nil, err
+		// the remaining cases are ordinal comparisons
+		// of either numbers or timestamps
+		lt := expr.TypeOf(n.Left, nil)
+		rt := expr.TypeOf(n.Right, nil)
+		if timeOnly(lt) || timeOnly(rt) {
+			return p.compileTimeCompare(n.Op, n.Left, n.Right)
 		}
-		right, err := p.compileAsNumber(n.Right)
+		num, err := p.compileNumberCompare(n)
 		if err != nil {
 			return nil, err
 		}
-		switch n.Op {
-		case expr.Less:
-			return p.Less(left, right), nil
-		case expr.LessEquals:
-			return p.LessEqual(left, right), nil
-		case expr.Greater:
-			return p.Greater(left, right), nil
-		case expr.GreaterEquals:
-			return p.GreaterEqual(left, right), nil
+		if lt.AnyOf(expr.TimeType) && rt.AnyOf(expr.TimeType) {
+			// we don't know statically whether
+			// these are numbers or timestamps,
+			// so we have to try both
+			tm, err := p.compileTimeCompare(n.Op, n.Left, n.Right)
+			if err != nil {
+				return nil, err
+			}
+			v := p.Or(num, tm)
+			// only one of the comparisons can be
+			// applicable, so the result is not
+			// MISSING if either one is not MISSING
+			v.notMissing = p.Or(p.notMissing(num), p.notMissing(tm))
+			return v, nil
 		}
-		return nil, fmt.Errorf("unhandled comparison expression %q", n)
+		return num, nil
 
 	case *expr.UnaryArith:
 		child, err := p.compileAsNumber(n.Child)
@@ -248,6 +258,9 @@ func compile(p *prog, e expr.Node) (*value, error) {
 		}
 		return p.Not(inner), nil
 	case *expr.Path:
+		if v, ok := p.bound[n.First]; ok {
+			return compilepath(p, v, n.Rest)
+		}
 		return compilepath(p, p.Dot(n.First, p.ValidLanes()), n.Rest)
 	case *expr.IsKey:
 		inner, err := compile(p, n.Expr)
@@ -616,7 +629,7 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 
 		return val, nil
 
-	case expr.DateAddMicrosecond, expr.DateAddMillisecond, expr.DateAddSecond, expr.DateAddMinute, expr.DateAddHour, expr.DateAddDay, expr.DateAddMonth, expr.DateAddYear:
+	case expr.DateAddMicrosecond, expr.DateAddMillisecond, expr.DateAddSecond, expr.DateAddMinute, expr.DateAddHour, expr.DateAddDay, expr.DateAddMonth, expr.DateAddYear, expr.DateAddQuarter, expr.DateAddWeek:
 		part := expr.Timepart(fn - expr.DateAddMicrosecond)
 		val0, err0 := p.compileAsNumber(args[0])
 		if err0 != nil {
@@ -631,7 +644,7 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		val := p.DateAdd(part, val0, val1)
 		return val, nil
 
-	case expr.DateDiffMicrosecond, expr.DateDiffMillisecond, expr.DateDiffSecond, expr.DateDiffMinute, expr.DateDiffHour, expr.DateDiffDay, expr.DateDiffMonth, expr.DateDiffYear:
+	case expr.DateDiffMicrosecond, expr.DateDiffMillisecond, expr.DateDiffSecond, expr.DateDiffMinute, expr.DateDiffHour, expr.DateDiffDay, expr.DateDiffMonth, expr.DateDiffYear, expr.DateDiffQuarter, expr.DateDiffWeek:
 		part := expr.Timepart(fn - expr.DateDiffMicrosecond)
 		val0, err0 := p.compileAsTime(args[0])
 		if err0 != nil {
@@ -646,22 +659,52 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		val := p.DateDiff(part, val0, val1)
 		return val, nil
 
-	case expr.DateExtractMicrosecond, expr.DateExtractMillisecond, expr.DateExtractSecond, expr.DateExtractMinute, expr.DateExtractHour, expr.DateExtractDay, expr.DateExtractMonth, expr.DateExtractYear:
+	case expr.DateExtractMicrosecond, expr.DateExtractMillisecond, expr.DateExtractSecond, expr.DateExtractMinute, expr.DateExtractHour, expr.DateExtractDay, expr.DateExtractMonth, expr.DateExtractYear,
+		expr.DateExtractQuarter, expr.DateExtractWeek, expr.DateExtractISOWeek, expr.DateExtractISOYear, expr.DateExtractDOW, expr.DateExtractDOY:
 		part := expr.Timepart(fn - expr.DateExtractMicrosecond)
 		val0, err := p.compileAsTime(args[0])
 		if err != nil {
 			return nil, err
 		}
+		if len(args) == 2 {
+			z, err := expr.TimeZone(args[1])
+			if err != nil {
+				return nil, err
+			}
+			val0 = p.AtTimeZone(val0, z)
+		}
 		return p.DateExtract(part, val0), nil
 
-	case expr.DateTruncYear, expr.DateTruncMonth, expr.DateTruncDay, expr.DateTruncHour, expr.DateTruncMinute, expr.DateTruncSecond:
+	case expr.DateTruncYear, expr.DateTruncMonth, expr.DateTruncDay, expr.DateTruncHour, expr.DateTruncMinute, expr.DateTruncSecond,
+		expr.DateTruncQuarter, expr.DateTruncWeek, expr.DateTruncISOWeek, expr.DateTruncISOYear:
 		part := expr.Timepart(fn - expr.DateTruncMicrosecond)
 		val, err := p.compileAsTime(args[0])
 		if err != nil {
 			return nil, err
 		}
+		if len(args) == 2 {
+			z, err := expr.TimeZone(args[1])
+			if err != nil {
+				return nil, err
+			}
+			return p.DateTruncZone(part, val, z), nil
+		}
 		return p.DateTrunc(part, val), nil
 
+	case expr.AtTimeZone:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s has %d arguments?", fn, len(args))
+		}
+		val, err := p.compileAsTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		z, err := expr.TimeZone(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return p.AtTimeZone(val, z), nil
+
 	case expr.WidthBucket:
 		if len(args) != 4 {
 			return nil, fmt.Errorf("%s must have exactly 4 arguments", fn)
@@ -690,8 +733,8 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		return p.WidthBucket(val, min, max, bucketCount), nil
 
 	case expr.TimeBucket:
-		if len(args) != 2 {
-			return nil, fmt.Errorf("%s must have exactly 2 arguments", fn)
+		if len(args) != 2 && len(args) != 3 {
+			return nil, fmt.Errorf("%s must have 2 or 3 arguments", fn)
 		}
 
 		arg, err := p.compileAsTime(args[0])
@@ -704,6 +747,13 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 			return nil, err2
 		}
 
+		if len(args) == 3 {
+			z, err := expr.TimeZone(args[2])
+			if err != nil {
+				return nil, err
+			}
+			return p.TimeBucketZone(arg, interval, z), nil
+		}
 		return p.TimeBucket(arg, interval), nil
 
 	case expr.Trim, expr.Ltrim, expr.Rtrim:
@@ -787,7 +837,45 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		if err != nil {
 			return nil, err
 		}
-		return p.IsSubnetOfIP4(lhs, net.ParseIP(string(minStr)), net.ParseIP(string(maxStr))), nil
+		min, err := netip.ParseAddr(string(minStr))
+		if err != nil {
+			return nil, err
+		}
+		max, err := netip.ParseAddr(string(maxStr))
+		if err != nil {
+			return nil, err
+		}
+		if min.Is4() {
+			return p.IsSubnetOfIP4(lhs, net.IP(min.AsSlice()), net.IP(max.AsSlice())), nil
+		}
+		return p.IsSubnetOfIP6(lhs, min, max), nil
+
+	case expr.InetParse, expr.InetFamily:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s should have 1 argument, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if fn == expr.InetParse {
+			return p.InetParse(str), nil
+		}
+		return p.InetFamily(str), nil
+
+	case expr.InetTrunc:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s should have 2 arguments, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		bits, ok := args[1].(expr.Integer)
+		if !ok || bits < 0 || bits > 128 {
+			return nil, fmt.Errorf("the second argument of %s should be a literal integer between 0 and 128; found %s", fn, expr.ToString(args[1]))
+		}
+		return p.InetTrunc(str, int(bits)), nil
 
 	case expr.CharLength:
 		if len(args) != 1 {
@@ -835,6 +923,113 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		}
 		return p.SplitPart(lhs, delimiterStr[0], splitPartIndex), nil
 
+	case expr.StrPos, expr.StartsWith, expr.EndsWith:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s should have 2 arguments, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		lit, ok := args[1].(expr.String)
+		if !ok {
+			return nil, fmt.Errorf("the second argument of %s should be a literal string; found %s", fn, expr.ToString(args[1]))
+		}
+		switch fn {
+		case expr.StrPos:
+			return p.StrPos(str, string(lit)), nil
+		case expr.StartsWith:
+			return p.HasPrefix(str, string(lit), true), nil
+		default:
+			return p.HasSuffix(str, string(lit), true), nil
+		}
+
+	case expr.Replace:
+		if len(args) != 3 {
+			return nil, fmt.Errorf("%s should have 3 arguments, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		from, ok1 := args[1].(expr.String)
+		to, ok2 := args[2].(expr.String)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("the second and third arguments of %s should be literal strings", fn)
+		}
+		return p.StrReplace(str, string(from), string(to)), nil
+
+	case expr.Reverse, expr.Initcap:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s should have 1 argument, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if fn == expr.Reverse {
+			return p.StrReverse(str), nil
+		}
+		return p.StrInitcap(str), nil
+
+	case expr.Repeat, expr.Left, expr.Right:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s should have 2 arguments, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		n, err := p.compileAsNumber(args[1])
+		if err != nil {
+			return nil, err
+		}
+		switch fn {
+		case expr.Repeat:
+			return p.StrRepeat(str, n), nil
+		case expr.Left:
+			return p.StrLeft(str, n), nil
+		default:
+			return p.StrRight(str, n), nil
+		}
+
+	case expr.Lpad, expr.Rpad:
+		if len(args) != 2 && len(args) != 3 {
+			return nil, fmt.Errorf("%s should have 2 or 3 arguments, got %d", fn, len(args))
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		n, err := p.compileAsNumber(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 3 {
+			if _, ok := args[2].(expr.String); !ok {
+				return nil, fmt.Errorf("the third argument of %s should be a literal string; found %s", fn, expr.ToString(args[2]))
+			}
+		}
+		return p.StrPad(str, n, expr.PadFill(args), fn == expr.Lpad), nil
+
+	case expr.RegexpExtract, expr.RegexpReplace, expr.RegexpCount:
+		m, repl, err := expr.RegexpMatcher(fn, args)
+		if err != nil {
+			return nil, err
+		}
+		str, err := p.compileAsString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		switch fn {
+		case expr.RegexpExtract:
+			return p.RegexpExtract(str, m, expr.RegexpGroup(args)), nil
+		case expr.RegexpReplace:
+			return p.RegexpReplace(str, m, repl), nil
+		default:
+			return p.RegexpCount(str, m), nil
+		}
+
 	case expr.Unspecified:
 		switch b.Name() {
 		case "UPVALUE":
@@ -896,6 +1091,35 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 			return nil, err
 		}
 		return p.DateToUnixMicro(arg), nil
+	case expr.FromUnixMicro:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("FROM_UNIX_MICRO has %d arguments?", len(args))
+		}
+		arg, err := p.compileAsNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return p.FromUnixMicro(arg), nil
+	case expr.ParseTimestamp, expr.FormatTimestamp:
+		f, err := expr.TimestampFormat(args)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s has %d arguments?", fn, len(args))
+		}
+		if fn == expr.ParseTimestamp {
+			arg, err := p.compileAsString(args[1])
+			if err != nil {
+				return nil, err
+			}
+			return p.ParseTimestamp(arg, f), nil
+		}
+		arg, err := p.compileAsTime(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return p.FormatTimestamp(arg, f), nil
 
 	case expr.GeoHash, expr.GeoTileES:
 		if len(args) != 3 {
@@ -987,6 +1211,69 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		}
 
 		return p.ssa2(sobjectsize, arg, p.mask(arg)), nil
+
+	case expr.ArrayLength, expr.ArrayDistinct:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s should have 1 argument, got %d", fn, len(args))
+		}
+		arg, err := compile(p, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if fn == expr.ArrayLength {
+			return p.ArrayLength(arg), nil
+		}
+		return p.ArrayDistinct(arg), nil
+
+	case expr.ArrayContains, expr.ArrayPosition:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s should have 2 arguments, got %d", fn, len(args))
+		}
+		arg, err := compile(p, args[0])
+		if err != nil {
+			return nil, err
+		}
+		needle, ok := args[1].(expr.Constant)
+		if !ok {
+			return nil, fmt.Errorf("the second argument of %s should be a literal value; found %s", fn, expr.ToString(args[1]))
+		}
+		if fn == expr.ArrayContains {
+			return p.ArrayContains(arg, needle.Datum()), nil
+		}
+		return p.ArrayPosition(arg, needle.Datum()), nil
+
+	case expr.ArraySlice:
+		if len(args) != 2 && len(args) != 3 {
+			return nil, fmt.Errorf("%s should have 2 or 3 arguments, got %d", fn, len(args))
+		}
+		arg, err := compile(p, args[0])
+		if err != nil {
+			return nil, err
+		}
+		bounds := []int{0, -1}
+		for i := 1; i < len(args); i++ {
+			n, ok := args[i].(expr.Integer)
+			if !ok || n < 0 || n >= math.MaxUint32 {
+				return nil, fmt.Errorf("the arguments of %s should be non-negative literal integers; found %s", fn, expr.ToString(args[i]))
+			}
+			bounds[i-1] = int(n)
+		}
+		return p.ArraySlice(arg, bounds[0], bounds[1]), nil
+
+	case expr.ArrayJoin:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s should have 2 arguments, got %d", fn, len(args))
+		}
+		arg, err := compile(p, args[0])
+		if err != nil {
+			return nil, err
+		}
+		sep, ok := args[1].(expr.String)
+		if !ok {
+			return nil, fmt.Errorf("the second argument of %s should be a literal string; found %s", fn, expr.ToString(args[1]))
+		}
+		return p.ArrayJoin(arg, string(sep)), nil
+
 	case expr.HashLookup:
 		return p.compileHashLookup(b.Args)
 	case expr.Lower, expr.Upper:
@@ -1063,6 +1350,73 @@ func (p *prog) timeIntLess(left, right *value) *value {
 	return p.ssa3(scmplttm, left, right, p.And(p.mask(left), p.mask(right)))
 }
 
+// timeOnly returns whether t is a timestamp
+// type (ignoring MISSING and NULL)
+func timeOnly(t expr.TypeSet) bool {
+	t &^= expr.MissingType | expr.NullType
+	return t != 0 && t.Only(expr.TimeType)
+}
+
+func (p *prog) compileNumberCompare(n *expr.Comparison) (*value, error) {
+	left, err := p.compileAsNumber(n.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.compileAsNumber(n.Right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Op {
+	case expr.Less:
+		return p.Less(left, right), nil
+	case expr.LessEquals:
+		return p.LessEqual(left, right), nil
+	case expr.Greater:
+		return p.Greater(left, right), nil
+	case expr.GreaterEquals:
+		return p.GreaterEqual(left, right), nil
+	}
+	return nil, fmt.Errorf("unhandled comparison expression %q", n)
+}
+
+// compileTimeCompare compiles an ordinal comparison
+// of two timestamps into the equivalent of BEFORE
+func (p *prog) compileTimeCompare(op expr.CmpOp, left, right expr.Node) (*value, error) {
+	// a > b is b < a, and a >= b is b <= a
+	if op == expr.Greater || op == expr.GreaterEquals {
+		op = op.Flip()
+		left, right = right, left
+	}
+	// a <= constant is a < constant+1us,
+	// and constant <= a is constant-1us < a
+	if op == expr.LessEquals {
+		if ts, ok := right.(*expr.Timestamp); ok {
+			op = expr.Less
+			right = &expr.Timestamp{Value: ts.Value.Add(time.Microsecond)}
+		} else if ts, ok := left.(*expr.Timestamp); ok {
+			op = expr.Less
+			left = &expr.Timestamp{Value: ts.Value.Add(-time.Microsecond)}
+		}
+	}
+	lhs, err := p.compileAsTime(left)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := p.compileAsTime(right)
+	if err != nil {
+		return nil, err
+	}
+	if op == expr.Less {
+		return p.compileTimeOrdered(lhs, rhs), nil
+	}
+	// a <= b is NOT (b < a) when
+	// both a and b are timestamps
+	lhs = p.toTimeInt(lhs)
+	rhs = p.toTimeInt(rhs)
+	mask := p.And(p.mask(lhs), p.mask(rhs))
+	return p.nand(p.ssa3(scmplttm, rhs, lhs, mask), mask), nil
+}
+
 func (p *prog) compileTimeOrdered(left, right *value) *value {
 	if left.primary() == stTimeInt ||
 		right.primary() == stTimeInt ||
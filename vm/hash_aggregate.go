@@ -15,8 +15,10 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 	"sync/atomic"
 
@@ -47,10 +49,19 @@ type HashAggregate struct {
 	final *aggtable
 	limit int
 
+	// spill, if non-nil, holds the
+	// groups that have been written to disk
+	spill *aggspill
+
 	// ordering functions;
 	// applied in order to determine
 	// the total ordering
 	order []func(*aggtable, hpair, hpair) int
+	// columns are the output columns
+	// corresponding to order, which are
+	// used to sort the groups that are
+	// written to disk (see sortSpilled)
+	columns []SortColumn
 }
 
 // Limit sets the maximum number of output rows.
@@ -59,6 +70,16 @@ func (h *HashAggregate) Limit(n int) {
 	h.limit = n
 }
 
+// Spill permits h to write groups to temporary
+// files in dir (or os.TempDir() if dir is empty)
+// once one of its tables holds more than maxmem
+// bytes of groups. The groups written to disk are
+// partitioned by hash and aggregated one
+// partition at a time when h is closed.
+func (h *HashAggregate) Spill(dir string, maxmem int) {
+	h.spill = &aggspill{dir: dir, maxmem: maxmem}
+}
+
 func (h *HashAggregate) OrderByGroup(n int, desc bool, nullslast bool) error {
 	if n < 0 || n >= len(h.by) {
 		return fmt.Errorf("group %d doesn't exist", n)
@@ -72,6 +93,11 @@ func (h *HashAggregate) OrderByGroup(n int, desc bool, nullslast bool) error {
 	if nullslast {
 		o.Nulls = sorting.NullsLast
 	}
+	h.columns = append(h.columns, SortColumn{
+		Node:      expr.Identifier(h.by[n].Result()),
+		Direction: o.Direction,
+		Nulls:     o.Nulls,
+	})
 	h.order = append(h.order, func(agt *aggtable, left, right hpair) int {
 		leftmem := agt.repridx(&left, n)
 		rightmem := agt.repridx(&right, n)
@@ -85,6 +111,18 @@ func (h *HashAggregate) OrderByAggregate(n int, desc bool) error {
 		return fmt.Errorf("aggregate %d doesn't exist", n)
 	}
 	aggregateKind := h.aggregateKinds[n]
+	if int(aggregateKind) >= len(agg2cmp) || agg2cmp[aggregateKind] == nil {
+		return fmt.Errorf("cannot order by %s", expr.ToString(h.agg[n].Expr))
+	}
+	dir := sorting.Ascending
+	if desc {
+		dir = sorting.Descending
+	}
+	h.columns = append(h.columns, SortColumn{
+		Node:      expr.Identifier(h.agg[n].Result),
+		Direction: dir,
+		Nulls:     sorting.NullsFirst,
+	})
 	h.order = append(h.order, func(agt *aggtable, left, right hpair) int {
 		lmem := agt.valueof(&left)
 		rmem := agt.valueof(&right)
@@ -191,6 +229,9 @@ func NewHashAggregate(agg Aggregation, by Selection, dst QuerySink) (*HashAggreg
 		}
 
 		op := agg[i].Expr.Op
+		if offset > math.MaxUint16 {
+			return nil, fmt.Errorf("aggregate buffer too large for %s", &agg[i])
+		}
 
 		// COUNT(...) is the only aggregate op that doesn't accept numbers;
 		// additionally, it accepts '*', which has a special meaning in this context.
@@ -207,6 +248,70 @@ func NewHashAggregate(agg Aggregation, by Selection, dst QuerySink) (*HashAggreg
 
 			out[i] = prog.AggregateSlotCount(mem, bucket, mask, offset)
 			kinds[i] = AggregateKindCount
+		} else if op == expr.OpApproxCountDistinct || op == expr.OpApproxCountDistinctPartial {
+			argv, err := compile(prog, agg[i].Expr.Inner)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = prog.AggregateSlotApproxCount(mem, bucket, argv, mask, offset)
+			kinds[i] = AggregateKindApproxCount
+			if op == expr.OpApproxCountDistinctPartial {
+				kinds[i] = AggregateKindApproxCountPartial
+			}
+		} else if op == expr.OpApproxCountDistinctMerge {
+			argv, err := compile(prog, agg[i].Expr.Inner)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = prog.AggregateSlotApproxCountMerge(mem, bucket, argv, mask, offset)
+			kinds[i] = AggregateKindApproxCount
+		} else if op == expr.OpApproxPercentile || op == expr.OpApproxPercentilePartial {
+			argv, err := prog.compileAsNumber(agg[i].Expr.Inner)
+			if err != nil {
+				return nil, fmt.Errorf("don't know how to aggregate %q: %w", agg[i].Expr.Inner, err)
+			}
+			out[i] = prog.AggregateSlotApproxPercentile(mem, bucket, argv, mask, offset)
+			kinds[i] = AggregateKindApproxPercentile
+			if op == expr.OpApproxPercentilePartial {
+				kinds[i] = AggregateKindApproxPercentilePartial
+			}
+		} else if op == expr.OpApproxPercentileMerge {
+			argv, err := compile(prog, agg[i].Expr.Inner)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = prog.AggregateSlotApproxPercentileMerge(mem, bucket, argv, mask, offset)
+			kinds[i] = AggregateKindApproxPercentile
+		} else if kind, merge, ok := momentsKind(op); ok {
+			if merge {
+				argv, err := compile(prog, agg[i].Expr.Inner)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = prog.AggregateSlotMomentsMerge(mem, bucket, argv, mask, offset)
+			} else {
+				x, y, err := compileMomentsArgs(prog, agg[i].Expr)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = prog.AggregateSlotMoments(mem, bucket, x, y, mask, offset)
+			}
+			kinds[i] = kind
+		} else if kind, merge, ok := arrayAggKind(op); ok {
+			if merge {
+				argv, err := compile(prog, agg[i].Expr.Inner)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = prog.AggregateSlotArrayMerge(mem, bucket, argv, mask, offset)
+			} else {
+				val, key, err := compileArrayAggArgs(prog, agg[i].Expr)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = prog.AggregateSlotArray(mem, bucket, val, key, mask, offset)
+			}
+			kinds[i] = kind
 		} else if op.IsBoolOp() {
 			argv, err := prog.compileAsBool(agg[i].Expr.Inner)
 			if err != nil {
@@ -292,6 +397,8 @@ func NewHashAggregate(agg Aggregation, by Selection, dst QuerySink) (*HashAggreg
 
 	initialData := make([]byte, offset)
 	initAggregateValues(initialData, kinds)
+	initPercentileSketches(initialData, agg, kinds)
+	initArrayAggs(initialData, agg, kinds)
 
 	h.aggregateKinds = kinds
 	h.initialData = initialData
@@ -300,24 +407,27 @@ func NewHashAggregate(agg Aggregation, by Selection, dst QuerySink) (*HashAggreg
 	return h, nil
 }
 
-func (h *HashAggregate) Open() (io.WriteCloser, error) {
-	at := &aggtable{
-		parent: h,
-		tree:   newRadixTree(len(h.initialData)),
+func (h *HashAggregate) newtable() *aggtable {
+	return &aggtable{
+		parent:         h,
+		tree:           newRadixTree(len(h.initialData)),
+		aggregateKinds: h.aggregateKinds,
 	}
-	at.aggregateKinds = h.aggregateKinds
+}
 
+func (h *HashAggregate) Open() (io.WriteCloser, error) {
+	at := h.newtable()
 	atomic.AddInt64(&h.children, 1)
 	return splitter(at), nil
 }
 
-func (h *HashAggregate) sort(pairs []hpair) {
+func (h *HashAggregate) sort(t *aggtable, pairs []hpair) {
 	if h.order == nil {
 		return
 	}
 	slices.SortFunc(pairs, func(left, right hpair) bool {
 		for k := range h.order {
-			dir := h.order[k](h.final, left, right)
+			dir := h.order[k](t, left, right)
 			if dir < 0 {
 				return true
 			}
@@ -339,6 +449,179 @@ func (h *HashAggregate) Close() error {
 		return fmt.Errorf("HashAggregate.final == nil, didn't compute any aggregates?")
 	}
 
+	dst, err := h.dst.Open()
+	if err != nil {
+		return err
+	}
+	if h.spill != nil && h.spill.spilled() {
+		err = h.writeSpilled(dst)
+	} else {
+		// perform ORDER BY and LIMIT steps
+		pairs := h.final.pairs
+		h.sort(h.final, pairs)
+		if h.limit > 0 && len(pairs) > h.limit {
+			pairs = pairs[:h.limit]
+		}
+		err = h.write(dst, h.final, pairs)
+	}
+	h.final = nil
+	if h.spill != nil {
+		if err2 := h.spill.close(); err == nil {
+			err = err2
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		dst.Close()
+		return err
+	}
+
+	// close the threading context
+	// *and* the destination query sink
+	err = dst.Close()
+	err2 := h.dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+// writeSpilled aggregates each of the buckets
+// written to disk and writes the results to dst
+//
+// without ORDER BY, the rows from the buckets
+// are written as soon as they have been aggregated;
+// with ORDER BY and LIMIT, the rows are collected
+// in a table that is trimmed to the LIMIT after
+// each bucket has been merged into it; with ORDER BY
+// alone, the rows are ordered with an external sort
+func (h *HashAggregate) writeSpilled(dst io.Writer) error {
+	// the groups that are still
+	// in memory are merged with
+	// the groups on disk
+	err := h.spill.write(h.final)
+	if err != nil {
+		return err
+	}
+	h.final = nil
+
+	if h.order == nil {
+		return h.writeBuckets(dst, h.limit)
+	}
+	if h.limit <= 0 {
+		return h.sortSpilled(dst)
+	}
+	out := h.newtable()
+	err = h.eachSpilled(h.spill, func(t *aggtable) error {
+		pairs := t.pairs
+		h.sort(t, pairs)
+		if len(pairs) > h.limit {
+			pairs = pairs[:h.limit]
+		}
+		out.mergePairs(t, pairs)
+		if len(out.pairs) > h.limit {
+			h.sort(out, out.pairs)
+			trim := h.newtable()
+			trim.mergePairs(out, out.pairs[:h.limit])
+			out = trim
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.sort(out, out.pairs)
+	return h.write(dst, out, out.pairs)
+}
+
+// eachSpilled aggregates each of the buckets in s
+// and calls fn with the table holding its groups
+func (h *HashAggregate) eachSpilled(s *aggspill, fn func(t *aggtable) error) error {
+	for i := range s.buckets {
+		t := h.newtable()
+		sub, err := s.load(i, t)
+		if err != nil {
+			return err
+		}
+		if sub != nil {
+			// the bucket was too large and
+			// has been partitioned again
+			err = h.eachSpilled(sub, fn)
+			if err2 := sub.close(); err == nil {
+				err = err2
+			}
+		} else if len(t.pairs) > 0 {
+			err = fn(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBuckets aggregates each of the buckets written
+// to disk and writes up to limit (if positive) of
+// the resulting rows to dst, combining the rows
+// from several small buckets into one chunk
+func (h *HashAggregate) writeBuckets(dst io.Writer, limit int) error {
+	out := h.newtable()
+	remaining := limit
+	err := h.eachSpilled(h.spill, func(t *aggtable) error {
+		pairs := t.pairs
+		if limit > 0 && len(pairs) > remaining {
+			pairs = pairs[:remaining]
+		}
+		remaining -= len(pairs)
+		out.mergePairs(t, pairs)
+		if limit > 0 && remaining == 0 {
+			err := h.write(dst, out, out.pairs)
+			if err == nil {
+				// no more rows are needed
+				err = io.EOF
+			}
+			return err
+		}
+		if out.memsize() < h.spill.maxmem {
+			return nil
+		}
+		err := h.write(dst, out, out.pairs)
+		out.reset()
+		return err
+	})
+	if err != nil || len(out.pairs) == 0 {
+		return err
+	}
+	return h.write(dst, out, out.pairs)
+}
+
+// sortSpilled writes the groups in each of the
+// buckets written to disk to dst in order; there
+// may be too many groups to sort them in memory,
+// so they are ordered with an external sort
+// that is bounded by the same amount of memory
+// as the one used by the spilled groups
+func (h *HashAggregate) sortSpilled(dst io.Writer) error {
+	o := NewSpillingOrder(dst, h.columns, h.spill.dir, h.spill.maxmem, 1)
+	w, err := o.Open()
+	if err != nil {
+		return err
+	}
+	err = h.writeBuckets(w, 0)
+	if err2 := w.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		o.spill.Close()
+		return err
+	}
+	return o.Close()
+}
+
+// write writes the rows for the given pairs
+// of t to dst as a single chunk of ion data;
+// io.EOF indicates that dst does not
+// accept any more rows
+func (h *HashAggregate) write(dst io.Writer, t *aggtable, pairs []hpair) error {
 	var outst ion.Symtab
 	var outbuf ion.Buffer
 
@@ -354,13 +637,6 @@ func (h *HashAggregate) Close() error {
 
 	outst.Marshal(&outbuf, true)
 
-	// perform ORDER BY and LIMIT steps
-	pairs := h.final.pairs
-	h.sort(pairs)
-	if h.limit > 0 && len(pairs) > h.limit {
-		pairs = pairs[:h.limit]
-	}
-
 	// for each of the pairs,
 	// emit the records;
 	// we take special care to
@@ -380,7 +656,7 @@ func (h *HashAggregate) Close() error {
 
 	for i := range pairs {
 		outbuf.BeginStruct(-1)
-		valmem := h.final.valueof(&pairs[i])
+		valmem := t.valueof(&pairs[i])
 		prevsym := ion.Symbol(0)
 		for _, pos := range h.pos2id {
 			if pos < len(h.by) {
@@ -390,7 +666,7 @@ func (h *HashAggregate) Close() error {
 				}
 				prevsym = sym
 				outbuf.BeginField(sym)
-				outval := h.final.repridx(&pairs[i], pos)
+				outval := t.repridx(&pairs[i], pos)
 				outbuf.UnsafeAppend(outval)
 			} else {
 				pos -= len(bysyms)
@@ -406,30 +682,12 @@ func (h *HashAggregate) Close() error {
 		outbuf.EndStruct()
 	}
 
-	h.final = nil
-	// finally, write the output...
-	dst, err := h.dst.Open()
-	if err != nil {
-		return err
-	}
 	// NOTE: we are triggering a vm copy here;
 	// we're doing this deliberately because
 	// typically the result is small (so, cheap)
 	// or the result is large in which case
 	// the RowSplitter will take care to split
 	// it up into small pieces before copying
-	_, err = dst.Write(outbuf.Bytes())
-	if err != nil {
-		dst.Close()
-		return err
-	}
-
-	// close the threading context
-	// *and* the destination query sink
-	err = dst.Close()
-	err2 := h.dst.Close()
-	if err == nil {
-		err = err2
-	}
+	_, err := dst.Write(outbuf.Bytes())
 	return err
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"reflect"
 	"runtime"
 	"testing"
@@ -218,3 +219,191 @@ func BenchmarkHashAggregate(b *testing.B) {
 		})
 	}
 }
+
+func TestHashAggregateSpill(t *testing.T) {
+	const (
+		chunks      = 8
+		rows        = 2000
+		groups      = 5000
+		align       = 64 * 1024
+		parallelism = 4
+	)
+
+	// every chunk has a symbol table of its own
+	// and each group appears in several chunks
+	ints := rand.Perm(chunks * rows)
+	input := make([]byte, chunks*align)
+	for i := 0; i < chunks; i++ {
+		var buf ion.Buffer
+		var st ion.Symtab
+		st.Intern(fmt.Sprintf("chunk%d", i))
+		keySym := st.Intern("key")
+		valSym := st.Intern("val")
+		buf.StartChunk(&st)
+		for _, n := range ints[i*rows : (i+1)*rows] {
+			buf.BeginStruct(-1)
+			buf.BeginField(keySym)
+			buf.WriteString(fmt.Sprintf("key%05d", n%groups))
+			buf.BeginField(valSym)
+			buf.WriteInt(int64(n))
+			buf.EndStruct()
+		}
+		if buf.Size() > align {
+			t.Fatalf("chunk of %d bytes exceeds %d", buf.Size(), align)
+		}
+		copy(input[i*align:], buf.Bytes())
+		noppad(input[i*align+buf.Size() : (i+1)*align])
+	}
+
+	count := make(map[string]uint64)
+	sum := make(map[string]uint64)
+	for n := 0; n < chunks*rows; n++ {
+		key := fmt.Sprintf("key%05d", n%groups)
+		count[key]++
+		sum[key] += uint64(n)
+	}
+
+	spill := func(t *testing.T, dst QuerySink, order bool, limit, maxmem int) *HashAggregate {
+		agg := Aggregation{
+			mkagg(expr.OpCount, "val", "count"),
+			mkagg(expr.OpSum, "val", "sum"),
+		}
+		ha, err := NewHashAggregate(agg, Selection{{Expr: path(t, "key")}}, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ha.Spill(t.TempDir(), maxmem)
+		if order {
+			ha.OrderByGroup(0, true, false)
+		}
+		ha.Limit(limit)
+		err = CopyRows(ha, BufferTable(input, align), parallelism)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ha.spill.spilled() {
+			t.Fatal("no groups were written to disk")
+		}
+		return ha
+	}
+
+	// small enough that every
+	// table is written to disk
+	const maxmem = 16 * 1024
+	// small enough that every bucket
+	// has to be partitioned again
+	const tinymem = 4096
+
+	run := func(t *testing.T, order bool, limit, maxmem int) []string {
+		var qb QueryBuffer
+		ha := spill(t, &qb, order, limit, maxmem)
+		err := ha.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		var st ion.Symtab
+		var d ion.Datum
+		for out := qb.Bytes(); len(out) > 0; {
+			if ion.TypeOf(out) == ion.NullType && ion.SizeOf(out) > 1 {
+				out = out[ion.SizeOf(out):]
+				continue
+			}
+			d, out, err = ion.ReadDatum(&st, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, ok := d.Struct()
+			if !ok {
+				t.Fatalf("unexpected datum %v", d)
+			}
+			var key string
+			var c, n uint64
+			s.Each(func(f ion.Field) bool {
+				switch f.Label {
+				case "key":
+					key, _ = f.Value.String()
+				case "count":
+					c, _ = f.Value.Uint()
+				case "sum":
+					n, _ = f.Value.Uint()
+				}
+				return true
+			})
+			if c != count[key] || n != sum[key] {
+				t.Fatalf("group %q: got count %d sum %d, want %d and %d", key, c, n, count[key], sum[key])
+			}
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	checkAll := func(t *testing.T, keys []string) {
+		if len(keys) != groups {
+			t.Fatalf("got %d groups, want %d", len(keys), groups)
+		}
+		seen := make(map[string]bool)
+		for _, k := range keys {
+			if seen[k] {
+				t.Fatalf("group %q appears more than once", k)
+			}
+			seen[k] = true
+		}
+	}
+
+	t.Run("all", func(t *testing.T) {
+		checkAll(t, run(t, false, 0, maxmem))
+	})
+	t.Run("repartition", func(t *testing.T) {
+		ha := spill(t, nopSink{}, false, 0, tinymem)
+		t.Log("copied")
+		defer ha.spill.close()
+		i := 0
+		for ha.spill.buckets[i] == nil {
+			i++
+		}
+		sub, err := ha.spill.load(i, ha.newtable())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub == nil || !sub.spilled() {
+			t.Fatal("bucket was not partitioned again")
+		}
+		sub.close()
+
+		checkAll(t, run(t, false, 0, tinymem))
+	})
+	t.Run("limit", func(t *testing.T) {
+		keys := run(t, false, 100, maxmem)
+		if len(keys) != 100 {
+			t.Fatalf("got %d groups, want 100", len(keys))
+		}
+	})
+	t.Run("order", func(t *testing.T) {
+		// without a limit, the groups
+		// are ordered by an external sort
+		for _, mem := range []int{maxmem, tinymem} {
+			keys := run(t, true, 0, mem)
+			checkAll(t, keys)
+			for i := range keys {
+				want := fmt.Sprintf("key%05d", groups-1-i)
+				if keys[i] != want {
+					t.Fatalf("row %d: got %q, want %q", i, keys[i], want)
+				}
+			}
+		}
+	})
+	t.Run("order-limit", func(t *testing.T) {
+		keys := run(t, true, 100, maxmem)
+		if len(keys) != 100 {
+			t.Fatalf("got %d groups, want 100", len(keys))
+		}
+		for i := range keys {
+			want := fmt.Sprintf("key%05d", groups-1-i)
+			if keys[i] != want {
+				t.Fatalf("row %d: got %q, want %q", i, keys[i], want)
+			}
+		}
+	})
+}
@@ -0,0 +1,479 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// HashJoin is a QuerySink that joins
+// each input row with the rows of a
+// (small) build table that have an
+// equal join key.
+//
+// Every output row is the input row
+// with an additional field containing
+// the matching build row.
+//
+// See NewHashJoin
+type HashJoin struct {
+	dst   QuerySink
+	kind  expr.JoinKind
+	probe *expr.Path
+	bind  string
+
+	build   []ion.Struct
+	table   map[string][]int
+	matched []uint32
+}
+
+// NewHashJoin constructs a HashJoin that
+// joins rows written to it with the rows in build.
+// The probe path is evaluated against each input row,
+// and the key path is evaluated against each build row;
+// rows are joined when the two keys are equal.
+// Joined rows have the build row bound to the
+// field named by bind.
+//
+// The kind of join must be one of expr.InnerJoin,
+// expr.LeftJoin, or expr.FullJoin.
+// For a LEFT or FULL join, input rows that do not
+// match any build row are passed through unchanged.
+// For a FULL join, build rows that do not match
+// any input row are written as a row containing
+// only the bind field when the HashJoin is closed.
+func NewHashJoin(dst QuerySink, kind expr.JoinKind, probe, key *expr.Path, bind string, build []ion.Struct) (*HashJoin, error) {
+	switch kind {
+	case expr.InnerJoin, expr.LeftJoin, expr.FullJoin:
+	default:
+		return nil, fmt.Errorf("vm.NewHashJoin: unsupported join kind %s", kind)
+	}
+	hj := &HashJoin{
+		dst:   dst,
+		kind:  kind,
+		probe: probe,
+		bind:  bind,
+		build: build,
+		table: make(map[string][]int),
+	}
+	for i := range build {
+		d, ok := structpath(build[i], key)
+		if !ok {
+			continue
+		}
+		k, ok := joinkey(d)
+		if !ok {
+			continue
+		}
+		hj.table[k] = append(hj.table[k], i)
+	}
+	if kind == expr.FullJoin {
+		hj.matched = make([]uint32, len(build))
+	}
+	return hj, nil
+}
+
+// structpath evaluates p against the structure s
+func structpath(s ion.Struct, p *expr.Path) (ion.Datum, bool) {
+	f, ok := s.FieldByName(p.First)
+	if !ok {
+		return ion.Empty, false
+	}
+	d := f.Value
+	for c := p.Rest; c != nil; c = c.Next() {
+		switch c := c.(type) {
+		case *expr.Dot:
+			s, ok := d.Struct()
+			if !ok {
+				return ion.Empty, false
+			}
+			f, ok := s.FieldByName(c.Field)
+			if !ok {
+				return ion.Empty, false
+			}
+			d = f.Value
+		case *expr.LiteralIndex:
+			lst, ok := d.List()
+			if !ok {
+				return ion.Empty, false
+			}
+			items := lst.Items(nil)
+			if c.Field < 0 || c.Field >= len(items) {
+				return ion.Empty, false
+			}
+			d = items[c.Field]
+		default:
+			return ion.Empty, false
+		}
+	}
+	return d, true
+}
+
+func keyof(tag byte, v uint64) string {
+	var buf [9]byte
+	buf[0] = tag
+	binary.LittleEndian.PutUint64(buf[1:], v)
+	return string(buf[:])
+}
+
+// joinkey produces the canonical representation
+// of a join key so that values that compare equal
+// (e.g. 1 and 1.0) produce the same key;
+// it returns false for values that never
+// compare equal to anything (e.g. NULL)
+func joinkey(d ion.Datum) (string, bool) {
+	switch d.Type() {
+	case ion.StringType, ion.SymbolType:
+		s, _ := d.String()
+		return "s" + s, true
+	case ion.IntType:
+		i, _ := d.Int()
+		return keyof('i', uint64(i)), true
+	case ion.UintType:
+		u, _ := d.Uint()
+		if u > math.MaxInt64 {
+			return keyof('u', u), true
+		}
+		return keyof('i', u), true
+	case ion.FloatType:
+		f, _ := d.Float()
+		if math.IsNaN(f) {
+			return "", false
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return keyof('i', uint64(int64(f))), true
+		}
+		return keyof('f', math.Float64bits(f)), true
+	case ion.BoolType:
+		b, _ := d.Bool()
+		if b {
+			return keyof('b', 1), true
+		}
+		return keyof('b', 0), true
+	case ion.TimestampType:
+		// UnixNano overflows outside of the years
+		// 1678 to 2262, so the seconds and
+		// nanoseconds are encoded separately
+		t, _ := d.Timestamp()
+		var buf [13]byte
+		buf[0] = 't'
+		binary.LittleEndian.PutUint64(buf[1:], uint64(t.Unix()))
+		binary.LittleEndian.PutUint32(buf[9:], uint32(t.Nanosecond()))
+		return string(buf[:]), true
+	default:
+		return "", false
+	}
+}
+
+type probestep struct {
+	field string
+	index int // if field == ""
+	sym   ion.Symbol
+	ok    bool
+}
+
+type hashjoin struct {
+	parent *HashJoin
+	st     symtab
+	aw     alignedWriter
+	out    io.WriteCloser
+
+	probe []probestep
+	bind  syminfo
+
+	// build rows encoded using st
+	rows   []byte
+	rowsep [][2]int
+}
+
+func (h *HashJoin) Open() (io.WriteCloser, error) {
+	dst, err := h.dst.Open()
+	if err != nil {
+		return nil, err
+	}
+	hj := &hashjoin{parent: h, out: dst}
+	hj.probe, err = probesteps(h.probe)
+	if err != nil {
+		dst.Close()
+		return nil, fmt.Errorf("vm.HashJoin: %w", err)
+	}
+	return splitter(hj), nil
+}
+
+// probesteps converts p into a list of probesteps
+func probesteps(p *expr.Path) ([]probestep, error) {
+	lst := []probestep{{field: p.First}}
+	for c := p.Rest; c != nil; c = c.Next() {
+		switch c := c.(type) {
+		case *expr.Dot:
+			lst = append(lst, probestep{field: c.Field})
+		case *expr.LiteralIndex:
+			lst = append(lst, probestep{index: c.Field})
+		default:
+			return nil, fmt.Errorf("unsupported path %s", expr.ToString(p))
+		}
+	}
+	return lst, nil
+}
+
+// symbolizeProbe resolves the field
+// symbols of each of the steps in lst
+func symbolizeProbe(lst []probestep, st *symtab) {
+	for i := range lst {
+		if lst[i].field != "" {
+			lst[i].sym, lst[i].ok = st.Symbolize(lst[i].field)
+		} else {
+			lst[i].ok = true
+		}
+	}
+}
+
+// probe evaluates the path described by lst
+// against the struct body mem and returns
+// the value it selects, or nil if there is none
+func probe(mem []byte, lst []probestep) []byte {
+	if !lst[0].ok {
+		return nil
+	}
+	val := structfield(mem, lst[0].sym)
+	for i := range lst[1:] {
+		p := &lst[i+1]
+		if val == nil || !p.ok {
+			return nil
+		}
+		t := ion.TypeOf(val)
+		body, _ := ion.Contents(val)
+		if p.field != "" && t == ion.StructType {
+			val = structfield(body, p.sym)
+		} else if p.field == "" && t == ion.ListType {
+			val = listitem(body, p.index)
+		} else {
+			return nil
+		}
+	}
+	return val
+}
+
+// Close implements io.Closer.Close
+//
+// For a FULL join, Close writes out the
+// build rows that did not match any input row.
+func (h *HashJoin) Close() error {
+	if h.kind == expr.FullJoin {
+		if err := h.unmatched(); err != nil {
+			h.dst.Close()
+			return err
+		}
+	}
+	return h.dst.Close()
+}
+
+func (h *HashJoin) unmatched() error {
+	var rest []int
+	for i := range h.matched {
+		if atomic.LoadUint32(&h.matched[i]) == 0 {
+			rest = append(rest, i)
+		}
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	out, err := h.dst.Open()
+	if err != nil {
+		return err
+	}
+	var st symtab
+	defer st.free()
+	bind := st.Intern(h.bind)
+	var buf ion.Buffer
+	for _, i := range rest {
+		buf.BeginStruct(-1)
+		buf.BeginField(bind)
+		h.build[i].Encode(&buf, &st.Symtab)
+		buf.EndStruct()
+	}
+	st.build()
+	return writeAll(out, &st, buf.Bytes())
+}
+
+func (h *hashjoin) symbolize(st *symtab) error {
+	st.CloneInto(&h.st)
+	h.bind.value = h.st.Intern(h.parent.bind)
+	h.bind.encoded, h.bind.mask, h.bind.size = encoded(h.bind.value)
+
+	// re-encode the build rows using
+	// the symbol table for this stream
+	var buf ion.Buffer
+	buf.Set(h.rows[:0])
+	h.rowsep = h.rowsep[:0]
+	for i := range h.parent.build {
+		start := buf.Size()
+		h.parent.build[i].Encode(&buf, &h.st.Symtab)
+		h.rowsep = append(h.rowsep, [2]int{start, buf.Size() - start})
+	}
+	h.rows = buf.Bytes()
+	h.st.build()
+
+	symbolizeProbe(h.probe, &h.st)
+	if h.aw.buf == nil {
+		h.aw.init(h.out, nil, defaultAlign)
+	}
+	return h.aw.setpre(&h.st)
+}
+
+func (h *hashjoin) next() rowConsumer { return nil }
+
+// structfield returns the value of the field with
+// the given symbol in the struct body mem
+func structfield(mem []byte, sym ion.Symbol) []byte {
+	for len(mem) > 0 {
+		s, rest, err := ion.ReadLabel(mem)
+		if err != nil {
+			return nil
+		}
+		size := ion.SizeOf(rest)
+		if size <= 0 || size > len(rest) {
+			return nil
+		}
+		if s == sym {
+			return rest[:size]
+		}
+		mem = rest[size:]
+	}
+	return nil
+}
+
+// listitem returns the nth item in the list body mem
+func listitem(mem []byte, n int) []byte {
+	for len(mem) > 0 {
+		size := ion.SizeOf(mem)
+		if size <= 0 || size > len(mem) {
+			return nil
+		}
+		if n == 0 {
+			return mem[:size]
+		}
+		n--
+		mem = mem[size:]
+	}
+	return nil
+}
+
+// key computes the join key for
+// the row with the given body
+func (h *hashjoin) key(mem []byte) (string, bool) {
+	val := probe(mem, h.probe)
+	if val == nil || ion.TypeOf(val) == ion.AnnotationType {
+		return "", false
+	}
+	d, _, err := ion.ReadDatum(&h.st.Symtab, val)
+	if err != nil {
+		return "", false
+	}
+	return joinkey(d)
+}
+
+// write a single row, splicing in the bind
+// field with the contents of row if it is non-nil
+func (h *hashjoin) write(mem, row []byte) error {
+	// find the insertion point for the bind
+	// field so that fields remain sorted by symbol,
+	// and drop any existing field with the same symbol
+	at, skip := len(mem), len(mem)
+	if row != nil {
+		off := 0
+		for off < len(mem) {
+			sym, rest, err := ion.ReadLabel(mem[off:])
+			if err != nil {
+				return err
+			}
+			end := len(mem) - len(rest) + ion.SizeOf(rest)
+			if sym >= h.bind.value {
+				at, skip = off, off
+				if sym == h.bind.value {
+					skip = end
+				}
+				break
+			}
+			off = end
+		}
+	}
+	inner := at + len(mem) - skip
+	if row != nil {
+		inner += int(h.bind.size) + len(row)
+	}
+	total := encsize(uint(inner)) + inner
+	if h.aw.space() < total {
+		_, err := h.aw.flush()
+		if err != nil {
+			return err
+		}
+		if h.aw.space() < total {
+			return fmt.Errorf("vm.HashJoin: row of %d bytes too large", total)
+		}
+	}
+	dst := h.aw.reserve(total)
+	w := ion.UnsafeWriteTag(dst, ion.StructType, uint(inner))
+	w += copy(dst[w:], mem[:at])
+	if row != nil {
+		w += putenc(dst[w:], h.bind.encoded, h.bind.size)
+		w += copy(dst[w:], row)
+	}
+	w += copy(dst[w:], mem[skip:])
+	if w != total {
+		panic("bad accounting")
+	}
+	return nil
+}
+
+func (h *hashjoin) writeRows(delims []vmref) error {
+	for i := range delims {
+		mem := delims[i].mem()
+		var match []int
+		if k, ok := h.key(mem); ok {
+			match = h.parent.table[k]
+		}
+		if len(match) == 0 {
+			if h.parent.kind != expr.InnerJoin {
+				if err := h.write(mem, nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for _, j := range match {
+			if h.parent.matched != nil {
+				atomic.StoreUint32(&h.parent.matched[j], 1)
+			}
+			sep := h.rowsep[j]
+			if err := h.write(mem, h.rows[sep[0]:sep[0]+sep[1]]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *hashjoin) Close() error {
+	h.st.free()
+	return h.aw.Close()
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// nanotime returns a timestamp datum
+// with nanosecond precision
+func nanotime(t *testing.T, tm date.Time) ion.Datum {
+	var buf ion.Buffer
+	buf.WriteTime(tm.Truncate(time.Second))
+	b := buf.Bytes()
+	if b[0]&0xf != 8 {
+		t.Fatalf("unexpected timestamp encoding %x", b)
+	}
+	ns := tm.Nanosecond()
+	b[0] += 5
+	b = append(b, 0xC0|0x09, byte(ns>>24), byte(ns>>16), byte(ns>>8), byte(ns))
+	d, _, err := ion.ReadDatum(nil, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestJoinKeyTimestamp(t *testing.T) {
+	// these are 2^64 nanoseconds apart,
+	// so their UnixNano values are equal
+	t0 := date.Date(1900, 1, 1, 0, 0, 0, 0)
+	t1 := t0.Add(1 << 62).Add(1 << 62).Add(1 << 62).Add(1 << 62)
+	if t0.UnixNano() != t1.UnixNano() {
+		t.Fatal("expected UnixNano to overflow")
+	}
+	d0, d1 := nanotime(t, t0), nanotime(t, t1)
+	if ts, _ := d1.Timestamp(); !ts.Equal(t1) {
+		t.Fatalf("got %s, want %s", ts, t1)
+	}
+	k0, ok0 := joinkey(d0)
+	k1, ok1 := joinkey(d1)
+	if !ok0 || !ok1 {
+		t.Fatal("timestamps should be valid join keys")
+	}
+	if k0 == k1 {
+		t.Errorf("%s and %s produced the same key", t0, t1)
+	}
+	if k, _ := joinkey(nanotime(t, t1)); k != k1 {
+		t.Errorf("%s produced different keys", t1)
+	}
+}
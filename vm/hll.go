@@ -0,0 +1,107 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+// APPROX_COUNT_DISTINCT is implemented as a HyperLogLog
+// sketch with 2^hllPrecision one-byte registers.
+//
+// Each input value is hashed; the top hllPrecision
+// bits of the low 64 bits of the hash select a register,
+// and the register is updated to hold the maximum
+// observed rank (the number of leading zeros in the
+// remaining bits, plus one). Sketches are merged by
+// taking the bytewise maximum of the registers,
+// which makes the aggregate trivially distributive.
+const (
+	hllPrecision = 10
+	hllRegisters = 1 << hllPrecision
+)
+
+// hllMerge merges the registers in src into dst
+func hllMerge(dst, src []byte) {
+	_ = dst[:hllRegisters]
+	_ = src[:hllRegisters]
+	for i := 0; i < hllRegisters; i++ {
+		if src[i] > dst[i] {
+			dst[i] = src[i]
+		}
+	}
+}
+
+// maxBytes returns the bytewise maximum of a and b
+func maxBytes(a, b uint64) uint64 {
+	out := uint64(0)
+	for i := 0; i < 64; i += 8 {
+		x := (a >> i) & 0xff
+		y := (b >> i) & 0xff
+		if y > x {
+			x = y
+		}
+		out |= x << i
+	}
+	return out
+}
+
+// hllMergeAtomically is equivalent to hllMerge,
+// but dst may be updated concurrently
+//
+// dst must be 8-byte aligned
+func hllMergeAtomically(dst, src []byte) {
+	_ = dst[:hllRegisters]
+	_ = src[:hllRegisters]
+	for i := 0; i < hllRegisters; i += 8 {
+		ptr := (*uint64)(unsafe.Pointer(&dst[i]))
+		val := binary.LittleEndian.Uint64(src[i:])
+		if val == 0 {
+			continue
+		}
+		for {
+			before := atomic.LoadUint64(ptr)
+			after := maxBytes(before, val)
+			if after == before || atomic.CompareAndSwapUint64(ptr, before, after) {
+				break
+			}
+		}
+	}
+}
+
+// hllEstimate returns the estimated number
+// of distinct items added to the registers
+func hllEstimate(regs []byte) uint64 {
+	_ = regs[:hllRegisters]
+	const m = float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	sum := float64(0)
+	zeros := 0
+	for i := 0; i < hllRegisters; i++ {
+		if regs[i] == 0 {
+			zeros++
+		}
+		sum += math.Ldexp(1, -int(regs[i]))
+	}
+	est := alpha * m * m / sum
+	// use linear counting for small cardinalities
+	if est <= 2.5*m && zeros != 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(est))
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// INET_PARSE and INET_TRUNC are evaluated by the
+// bcinetparse instruction, which parses the address
+// into the spill area, masks it, and formats it
+// again; the mask is encoded as
+//
+//	[0:16]  the mask of an IPv6 address
+//	[16:20] the mask of an IPv4 address
+//	[20:24] the accepted address families
+//	        (inetFamily4 | inetFamily6)
+const (
+	inetMask6       = 0
+	inetMask4       = 16
+	inetFamilies    = 20
+	inetMaskSize    = 24
+	inetFamily4     = 1
+	inetFamily6     = 2
+	inetSubnetSize  = 32
+	inetMaxTextSize = 40 // space reserved for the canonical text
+)
+
+// inetMask encodes the mask that keeps the first bits
+// bits of an address, or the complete address if bits
+// is negative; addresses shorter than bits are rejected
+func inetMask(bits int) string {
+	buf := make([]byte, inetMaskSize)
+	families := uint32(inetFamily4 | inetFamily6)
+	if bits < 0 {
+		bits = 128
+	} else if bits > 32 {
+		families &^= inetFamily4
+	}
+	for i := 0; i < bits && i < 128; i++ {
+		buf[inetMask6+i/8] |= 0x80 >> (i % 8)
+		if i < 32 {
+			buf[inetMask4+i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	binary.LittleEndian.PutUint32(buf[inetFamilies:], families)
+	return string(buf)
+}
+
+// InetParse parses str as an IP address and returns
+// its canonical text form; lanes that do not contain
+// an address are removed
+func (p *prog) InetParse(str *value) *value {
+	return p.InetTrunc(str, -1)
+}
+
+// InetTrunc computes INET_TRUNC(str, bits)
+func (p *prog) InetTrunc(str *value, bits int) *value {
+	str = p.toStr(str)
+	return p.ssa2imm(sInetParse, str, p.mask(str), inetMask(bits))
+}
+
+// InetFamily returns 4 for IPv4 addresses and
+// 6 for IPv6 addresses; lanes that do not contain
+// an address are removed
+func (p *prog) InetFamily(str *value) *value {
+	str = p.toStr(str)
+	return p.ssa2(sInetFamily, str, p.mask(str))
+}
+
+// IsSubnetOfIP6 returns whether the given value is an
+// IPv6 address between (and including) min and max
+func (p *prog) IsSubnetOfIP6(str *value, min, max netip.Addr) *value {
+	str = p.toStr(str)
+
+	// the halves of the addresses are stored as
+	// (little-endian) integers, so that they can be
+	// compared with the byte-swapped halves of the input
+	buf := make([]byte, inetSubnetSize)
+	a, b := min.As16(), max.As16()
+	binary.LittleEndian.PutUint64(buf[0:], binary.BigEndian.Uint64(a[0:]))
+	binary.LittleEndian.PutUint64(buf[8:], binary.BigEndian.Uint64(a[8:]))
+	binary.LittleEndian.PutUint64(buf[16:], binary.BigEndian.Uint64(b[0:]))
+	binary.LittleEndian.PutUint64(buf[24:], binary.BigEndian.Uint64(b[8:]))
+	return p.ssa2imm(sIsSubnetOfIP6, str, p.mask(str), string(buf))
+}
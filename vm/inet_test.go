@@ -0,0 +1,109 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm_test
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// randomInet returns a random (and possibly
+// invalid) text form of an IP address
+func randomInet(r *rand.Rand) string {
+	var b [16]byte
+	r.Read(b[:])
+	// make runs of zero groups likely
+	for i := 0; i < 16; i += 2 {
+		if r.Intn(3) == 0 {
+			b[i], b[i+1] = 0, 0
+		}
+	}
+	var str string
+	switch r.Intn(5) {
+	case 0:
+		str = netip.AddrFrom4([4]byte{b[0], b[1], b[2], b[3]}).String()
+	case 1:
+		str = netip.AddrFrom16(b).String()
+	case 2:
+		// the expanded form
+		str = netip.AddrFrom16(b).StringExpanded()
+	case 3:
+		// an IPv4-mapped address
+		str = "::ffff:" + netip.AddrFrom4([4]byte{b[0], b[1], b[2], b[3]}).String()
+	default:
+		str = strings.ToUpper(netip.AddrFrom16(b).String())
+	}
+	switch r.Intn(4) {
+	case 0:
+		buf := []byte(str)
+		buf[r.Intn(len(buf))] = "0123456789abcdefABCDEF:.%x "[r.Intn(27)]
+		str = string(buf)
+	case 1:
+		str = str[:r.Intn(len(str))]
+	case 2:
+		i := r.Intn(len(str))
+		str = str[:i] + "0" + str[i:]
+	}
+	return str
+}
+
+// TestInetBF compares INET_PARSE, INET_TRUNC,
+// INET_FAMILY, and IS_SUBNET_OF with net/netip
+// for random (and possibly invalid) addresses
+func TestInetBF(t *testing.T) {
+	const rows = 1000
+	subnet := netip.MustParsePrefix("::/1")
+	r := rand.New(rand.NewSource(0))
+	var st ion.Symtab
+	var in, out []ion.Datum
+	for i := 0; i < rows; i++ {
+		str := randomInet(r)
+		in = append(in, ion.NewStruct(&st, []ion.Field{
+			{Label: "s", Value: ion.String(str)},
+		}).Datum())
+
+		fields := []ion.Field{}
+		addr, err := netip.ParseAddr(str)
+		if err == nil {
+			family := 4
+			if addr.Is6() {
+				family = 6
+			}
+			trunc, _ := addr.Prefix(26)
+			fields = append(fields,
+				ion.Field{Label: "p", Value: ion.String(addr.String())},
+				ion.Field{Label: "t", Value: ion.String(trunc.Addr().String())},
+			)
+			if trunc, err := addr.Prefix(100); err == nil {
+				fields = append(fields, ion.Field{Label: "u", Value: ion.String(trunc.Addr().String())})
+			}
+			fields = append(fields,
+				ion.Field{Label: "f", Value: ion.Int(int64(family))},
+				ion.Field{Label: "m", Value: ion.Bool(addr.Is6() && subnet.Contains(addr))},
+			)
+		} else {
+			fields = append(fields, ion.Field{Label: "m", Value: ion.Bool(false)})
+		}
+		out = append(out, ion.NewStruct(&st, fields).Datum())
+	}
+	query := fmt.Sprintf(`SELECT INET_PARSE(s) AS p, INET_TRUNC(s, 26) AS t, INET_TRUNC(s, 100) AS u,
+INET_FAMILY(s) AS f, IS_SUBNET_OF('%s', s) AS m FROM input`, subnet)
+	testInput(t, []byte(query), &st, [][]ion.Datum{in}, out)
+}
@@ -0,0 +1,360 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// JSON is a QuerySink that evaluates
+// JSON_PARSE, JSON_EXTRACT, and TO_JSON_STRING
+// over its input rows.
+//
+// Every output row is an input row with
+// one additional field for each function result.
+// (Functions that produce MISSING do not
+// produce a field.)
+//
+// See NewJSON
+type JSON struct {
+	dst   QuerySink
+	funcs []jsonfunc
+}
+
+type jsonfunc struct {
+	op     expr.BuiltinOp
+	result string
+
+	// the argument is either the result
+	// of a preceding function (if prev >= 0),
+	// a path into the input row (if probe != nil),
+	// or a constant
+	prev     int
+	probe    *expr.Path
+	constant ion.Datum
+
+	// folded is set if the function was
+	// constant-folded into constant
+	folded bool
+
+	// path for JSON_EXTRACT
+	path expr.JSONPath
+}
+
+// NewJSON constructs a JSON that evaluates
+// each of the functions in funcs and writes
+// its output to dst.
+//
+// Every argument to each function must be
+// either a constant or a path expression;
+// a path expression may refer to the result
+// of a preceding function.
+func NewJSON(funcs []expr.Binding, dst QuerySink) (*JSON, error) {
+	j := &JSON{dst: dst}
+	for i := range funcs {
+		f := jsonfunc{result: funcs[i].Result(), prev: -1}
+		var b *expr.Builtin
+		switch e := funcs[i].Expr.(type) {
+		case *expr.Builtin:
+			b = e
+		case expr.Constant:
+			// constant-folded function
+			f.folded = true
+			f.constant = e.Datum()
+		case expr.Missing:
+			f.folded = true
+		default:
+			return nil, fmt.Errorf("vm.NewJSON: unexpected expression %s", expr.ToString(funcs[i].Expr))
+		}
+		if f.folded {
+			j.funcs = append(j.funcs, f)
+			continue
+		}
+		f.op = b.Func
+		switch b.Func {
+		case expr.JSONParse, expr.ToJSONString:
+			if len(b.Args) != 1 {
+				return nil, fmt.Errorf("vm.NewJSON: %s expects 1 argument", expr.ToString(b))
+			}
+		case expr.JSONExtract:
+			if len(b.Args) != 2 {
+				return nil, fmt.Errorf("vm.NewJSON: %s expects 2 arguments", expr.ToString(b))
+			}
+			str, ok := b.Args[1].(expr.String)
+			if !ok {
+				return nil, fmt.Errorf("vm.NewJSON: %s expects a literal path argument", expr.ToString(b))
+			}
+			path, err := expr.ParseJSONPath(string(str))
+			if err != nil {
+				return nil, fmt.Errorf("vm.NewJSON: %w", err)
+			}
+			f.path = path
+		default:
+			return nil, fmt.Errorf("vm.NewJSON: unsupported function %s", expr.ToString(b))
+		}
+		switch arg := b.Args[0].(type) {
+		case *expr.Path:
+			for k := range j.funcs {
+				if arg.Rest == nil && j.funcs[k].result == arg.First {
+					f.prev = k
+				}
+			}
+			if f.prev < 0 {
+				f.probe = arg
+			}
+		case expr.Constant:
+			f.constant = arg.Datum()
+		case expr.Missing:
+			// f.constant is empty
+		default:
+			return nil, fmt.Errorf("vm.NewJSON: unsupported argument %s", expr.ToString(arg))
+		}
+		j.funcs = append(j.funcs, f)
+	}
+	return j, nil
+}
+
+// eval evaluates f given its argument,
+// returning an empty datum if the result is MISSING
+//
+// the result may refer to memory owned by p
+func (f *jsonfunc) eval(arg ion.Datum, p *expr.JSONParser) ion.Datum {
+	if arg.Empty() {
+		return ion.Empty
+	}
+	if f.op == expr.ToJSONString {
+		str, ok := expr.FormatJSON(arg)
+		if !ok {
+			return ion.Empty
+		}
+		return ion.String(str)
+	}
+	str, ok := arg.String()
+	if !ok {
+		return ion.Empty
+	}
+	var d ion.Datum
+	if f.op == expr.JSONParse {
+		d, ok = p.Parse(str)
+	} else {
+		d, ok = p.Extract(str, f.path)
+	}
+	if !ok {
+		return ion.Empty
+	}
+	return d
+}
+
+// Open implements QuerySink.Open
+func (j *JSON) Open() (io.WriteCloser, error) {
+	dst, err := j.dst.Open()
+	if err != nil {
+		return nil, err
+	}
+	je := &jsonEval{
+		parent: j,
+		out:    dst,
+		probes: make([][]probestep, len(j.funcs)),
+		syms:   make([]ion.Symbol, len(j.funcs)),
+		vals:   make([]ion.Datum, len(j.funcs)),
+	}
+	for i := range j.funcs {
+		if p := j.funcs[i].probe; p != nil {
+			je.probes[i], err = probesteps(p)
+			if err != nil {
+				dst.Close()
+				return nil, fmt.Errorf("vm.JSON: %w", err)
+			}
+		}
+	}
+	return splitter(je), nil
+}
+
+// Close implements io.Closer.Close
+func (j *JSON) Close() error {
+	return j.dst.Close()
+}
+
+// jsonfield is one function result
+// that is spliced into an output row
+type jsonfield struct {
+	sym        ion.Symbol
+	start, end int // range in jsonEval.vbuf
+}
+
+type jsonEval struct {
+	parent *JSON
+	st     symtab
+	aw     alignedWriter
+	out    io.WriteCloser
+	// presyms is the number of symbols in
+	// the symbol table written to aw
+	presyms int
+
+	probes [][]probestep
+	syms   []ion.Symbol
+	vals   []ion.Datum
+
+	fields []jsonfield
+	vbuf   ion.Buffer
+	row    ion.Buffer
+
+	parser expr.JSONParser
+}
+
+func (j *jsonEval) symbolize(st *symtab) error {
+	st.CloneInto(&j.st)
+	for i := range j.parent.funcs {
+		j.syms[i] = j.st.Intern(j.parent.funcs[i].result)
+		if j.probes[i] != nil {
+			symbolizeProbe(j.probes[i], &j.st)
+		}
+	}
+	if j.aw.buf == nil {
+		j.aw.init(j.out, nil, defaultAlign)
+	}
+	j.presyms = j.st.MaxID()
+	return j.aw.setpre(&j.st)
+}
+
+func (j *jsonEval) next() rowConsumer { return nil }
+
+// arg produces the argument to the ith function
+func (j *jsonEval) arg(mem []byte, i int) ion.Datum {
+	f := &j.parent.funcs[i]
+	if f.prev >= 0 {
+		return j.vals[f.prev]
+	}
+	if f.probe == nil {
+		return f.constant
+	}
+	val := probe(mem, j.probes[i])
+	if val == nil || ion.TypeOf(val) == ion.AnnotationType {
+		return ion.Empty
+	}
+	d, _, err := ion.ReadDatum(&j.st.Symtab, val)
+	if err != nil {
+		return ion.Empty
+	}
+	return d
+}
+
+func (j *jsonEval) writeRows(delims []vmref) error {
+	for i := range delims {
+		mem := delims[i].mem()
+		j.vbuf.Reset()
+		j.fields = j.fields[:0]
+		for k := range j.parent.funcs {
+			if f := &j.parent.funcs[k]; f.folded {
+				j.vals[k] = f.constant
+			} else {
+				j.vals[k] = f.eval(j.arg(mem, k), &j.parser)
+			}
+			start := j.vbuf.Size()
+			if !j.vals[k].Empty() {
+				j.vals[k].Encode(&j.vbuf, &j.st.Symtab)
+				// the result may refer to the parser state,
+				// which is reused by the following functions
+				j.vals[k], _, _ = ion.ReadDatum(&j.st.Symtab, j.vbuf.Bytes()[start:])
+			}
+			j.fields = append(j.fields, jsonfield{
+				sym:   j.syms[k],
+				start: start,
+				end:   j.vbuf.Size(),
+			})
+		}
+		slices.SortFunc(j.fields, func(a, b jsonfield) bool {
+			return a.sym < b.sym
+		})
+		if j.st.MaxID() != j.presyms {
+			// the results introduced new symbols;
+			// rows that have already been written
+			// only use a prefix of the new symbol table
+			j.presyms = j.st.MaxID()
+			if err := j.aw.setpre(&j.st); err != nil {
+				return err
+			}
+		}
+		if err := j.write(mem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write a single row, splicing in each
+// of the function results (and dropping any
+// existing fields with the same symbols)
+func (j *jsonEval) write(mem []byte) error {
+	vals := j.vbuf.Bytes()
+	fields := j.fields
+	splice := func() {
+		f := &fields[0]
+		if f.end > f.start {
+			j.row.BeginField(f.sym)
+			j.row.UnsafeAppend(vals[f.start:f.end])
+		}
+		fields = fields[1:]
+	}
+	j.row.Reset()
+	for len(mem) > 0 {
+		sym, rest, err := ion.ReadLabel(mem)
+		if err != nil {
+			return err
+		}
+		end := len(mem) - len(rest) + ion.SizeOf(rest)
+		for len(fields) > 0 && fields[0].sym < sym {
+			splice()
+		}
+		if len(fields) > 0 && fields[0].sym == sym {
+			splice()
+		} else {
+			j.row.UnsafeAppend(mem[:end])
+		}
+		mem = mem[end:]
+	}
+	for len(fields) > 0 {
+		splice()
+	}
+	inner := j.row.Size()
+	total := encsize(uint(inner)) + inner
+	if j.aw.space() < total {
+		_, err := j.aw.flush()
+		if err != nil {
+			return err
+		}
+		if j.aw.space() < total {
+			return fmt.Errorf("vm.JSON: row of %d bytes too large", total)
+		}
+	}
+	dst := j.aw.reserve(total)
+	w := ion.UnsafeWriteTag(dst, ion.StructType, uint(inner))
+	w += copy(dst[w:], j.row.Bytes())
+	if w != total {
+		panic("bad accounting")
+	}
+	return nil
+}
+
+func (j *jsonEval) Close() error {
+	j.st.free()
+	return j.aw.Close()
+}
@@ -26,6 +26,8 @@ import (
 // See NewLimit
 type Limit struct {
 	remaining int64
+	offset    int64 // number of rows to skip
+	total     int64 // offset + limit
 	dst       QuerySink
 }
 
@@ -38,9 +40,22 @@ type limiter struct {
 // NewLimit constructs a Limit that will
 // write no more than 'n' rows to 'dst'.
 func NewLimit(n int64, dst QuerySink) *Limit {
+	return NewLimitOffset(n, 0, dst)
+}
+
+// NewLimitOffset constructs a Limit that will
+// skip the first 'offset' rows and then write
+// no more than 'n' rows to 'dst'.
+//
+// If the rows are written to the Limit from more
+// than one stream, the rows that are skipped are
+// the first ones that happen to be written.
+func NewLimitOffset(n, offset int64, dst QuerySink) *Limit {
 	return &Limit{
 		dst:       dst,
-		remaining: n,
+		remaining: n + offset,
+		offset:    offset,
+		total:     n + offset,
 	}
 }
 
@@ -80,6 +95,10 @@ func (l *limiter) writeRows(rows []vmref) error {
 	}
 	c := int64(len(rows))
 	avail := atomic.AddInt64(&l.parent.remaining, -c)
+	// rows[0] is row number (total - (avail + c))
+	// of all the rows written so far, so the first
+	// (offset - that) rows are skipped
+	skip := l.parent.offset - (l.parent.total - avail - c)
 	if avail < 0 {
 		// adjust c so that we only
 		// write the rows we are interested
@@ -97,7 +116,13 @@ func (l *limiter) writeRows(rows []vmref) error {
 			return err
 		}
 	}
-	err := l.dst.writeRows(rows[:c])
+	var err error
+	if skip < c {
+		if skip < 0 {
+			skip = 0
+		}
+		err = l.dst.writeRows(rows[skip:c])
+	}
 	if avail == 0 && err == nil {
 		l.done = true
 		err = l.dst.Close()
@@ -46,3 +46,46 @@ func TestLimit(t *testing.T) {
 		}
 	}
 }
+
+func TestLimitOffset(t *testing.T) {
+	buf, err := ioutil.ReadFile("../testdata/parking.10n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := len(structures(buf))
+	tcs := []struct {
+		limit, offset int
+	}{
+		{1, 1},
+		{15, 1},
+		{16, 16},
+		{17, 100},
+		{100, 17},
+		{1, rows - 1},
+		{10, rows - 5},
+		{10, rows},
+		{10, rows + 10},
+	}
+	for _, tc := range tcs {
+		var dst QueryBuffer
+		l := NewLimitOffset(int64(tc.limit), int64(tc.offset), &dst)
+		s := NewProjection(selection("Ticket as t"), l)
+		err := CopyRows(s, buftbl(buf), 1)
+		if err != nil {
+			t.Errorf("LIMIT %d OFFSET %d: %s", tc.limit, tc.offset, err)
+			continue
+		}
+		b := dst.Bytes()
+		skipok(b, t)
+		want := rows - tc.offset
+		if want < 0 {
+			want = 0
+		} else if want > tc.limit {
+			want = tc.limit
+		}
+		out := len(structures(dst.Bytes()))
+		if out != want {
+			t.Errorf("LIMIT %d OFFSET %d: got %d rows, want %d", tc.limit, tc.offset, out, want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/SnellerInc/sneller/expr"
+)
+
+// The statistical aggregates (VAR_POP, STDDEV_SAMP,
+// COVAR_POP, CORR, etc.) all share the same state:
+// the number of (x, y) pairs, the mean of x and y,
+// the sums of squared differences from the mean of
+// x and y, and the sum of the products of the differences
+// from the means of x and y. (The single-argument
+// aggregates simply use x for y.)
+//
+// The state is updated with Welford's algorithm
+// and merged with the pairwise algorithm of Chan et al.,
+// both of which are numerically stable, unlike
+// computing the variance from SUM(x) and SUM(x*x).
+//
+// The layout of the state is
+//
+//	[0:8]   n (float64)
+//	[8:16]  mean of x (float64)
+//	[16:24] mean of y (float64)
+//	[24:32] sum of (x - mean(x))^2 (float64)
+//	[32:40] sum of (y - mean(y))^2 (float64)
+//	[40:48] sum of (x - mean(x))*(y - mean(y)) (float64)
+const momentsSize = 48
+
+// momentsLocked is stored in place of n
+// while the state is being merged atomically
+// (it is a NaN, so it is never a valid count)
+const momentsLocked = ^uint64(0)
+
+// momentsKind returns the aggregate kind of
+// the statistical aggregate op, and whether op
+// merges partial states (as opposed to values)
+func momentsKind(op expr.AggregateOp) (kind AggregateKind, merge bool, ok bool) {
+	switch op {
+	case expr.OpVarPop, expr.OpVarPopMerge:
+		kind = AggregateKindVarPop
+	case expr.OpVarSamp, expr.OpVarSampMerge:
+		kind = AggregateKindVarSamp
+	case expr.OpStddevPop, expr.OpStddevPopMerge:
+		kind = AggregateKindStddevPop
+	case expr.OpStddevSamp, expr.OpStddevSampMerge:
+		kind = AggregateKindStddevSamp
+	case expr.OpCovarPop, expr.OpCovarPopMerge:
+		kind = AggregateKindCovarPop
+	case expr.OpCorr, expr.OpCorrMerge:
+		kind = AggregateKindCorr
+	case expr.OpMomentsPartial:
+		kind = AggregateKindMomentsPartial
+	default:
+		return 0, false, false
+	}
+	return kind, op >= expr.OpVarPopMerge && op <= expr.OpCorrMerge, true
+}
+
+// compileMomentsArgs compiles the x and y
+// arguments of a statistical aggregate;
+// y is the same as x for the single-argument
+// aggregates
+func compileMomentsArgs(p *prog, agg *expr.Aggregate) (x, y *value, err error) {
+	x, err = p.compileAsNumber(agg.Inner)
+	if err != nil {
+		return nil, nil, fmt.Errorf("don't know how to aggregate %q: %w", agg.Inner, err)
+	}
+	y = x
+	if len(agg.Args) > 0 {
+		y, err = p.compileAsNumber(agg.Args[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("don't know how to aggregate %q: %w", agg.Args[0], err)
+		}
+	}
+	return x, y, nil
+}
+
+type moments struct {
+	n, meanx, meany, m2x, m2y, cxy float64
+}
+
+func (m *moments) load(buf []byte) {
+	_ = buf[:momentsSize]
+	m.n = math.Float64frombits(binary.LittleEndian.Uint64(buf[0:]))
+	m.meanx = math.Float64frombits(binary.LittleEndian.Uint64(buf[8:]))
+	m.meany = math.Float64frombits(binary.LittleEndian.Uint64(buf[16:]))
+	m.m2x = math.Float64frombits(binary.LittleEndian.Uint64(buf[24:]))
+	m.m2y = math.Float64frombits(binary.LittleEndian.Uint64(buf[32:]))
+	m.cxy = math.Float64frombits(binary.LittleEndian.Uint64(buf[40:]))
+}
+
+// storeTail stores everything but n into buf
+func (m *moments) storeTail(buf []byte) {
+	_ = buf[:momentsSize]
+	binary.LittleEndian.PutUint64(buf[8:], math.Float64bits(m.meanx))
+	binary.LittleEndian.PutUint64(buf[16:], math.Float64bits(m.meany))
+	binary.LittleEndian.PutUint64(buf[24:], math.Float64bits(m.m2x))
+	binary.LittleEndian.PutUint64(buf[32:], math.Float64bits(m.m2y))
+	binary.LittleEndian.PutUint64(buf[40:], math.Float64bits(m.cxy))
+}
+
+func (m *moments) store(buf []byte) {
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(m.n))
+	m.storeTail(buf)
+}
+
+// add adds a single (x, y) pair to m
+//
+// (this is the same computation that is
+// performed by the bytecode aggregate ops)
+func (m *moments) add(x, y float64) {
+	m.n++
+	dx := x - m.meanx
+	m.meanx += dx / m.n
+	dy := y - m.meany
+	m.meany += dy / m.n
+	m.m2x += dx * (x - m.meanx)
+	m.m2y += dy * (y - m.meany)
+	m.cxy += dx * (y - m.meany)
+}
+
+// merge merges o into m
+//
+// (this is the same computation that is
+// performed by the bytecode aggregate ops)
+func (m *moments) merge(o *moments) {
+	if o.n == 0 {
+		return
+	}
+	n := m.n + o.n
+	f := o.n / n
+	w := m.n * f
+	dx := o.meanx - m.meanx
+	dy := o.meany - m.meany
+	m.n = n
+	m.meanx += dx * f
+	m.meany += dy * f
+	m.m2x += o.m2x + dx*dx*w
+	m.m2y += o.m2y + dy*dy*w
+	m.cxy += o.cxy + dx*dy*w
+}
+
+// momentsMerge merges the state in src into dst
+func momentsMerge(dst, src []byte) {
+	var a, b moments
+	a.load(dst)
+	b.load(src)
+	a.merge(&b)
+	a.store(dst)
+}
+
+// momentsMergeAtomically is equivalent to
+// momentsMerge, but dst may be updated concurrently
+//
+// dst must be 8-byte aligned
+func momentsMergeAtomically(dst, src []byte) {
+	var a, b moments
+	b.load(src)
+	if b.n == 0 {
+		return
+	}
+	ptr := (*uint64)(unsafe.Pointer(&dst[0]))
+	var n uint64
+	for {
+		n = atomic.LoadUint64(ptr)
+		if n != momentsLocked && atomic.CompareAndSwapUint64(ptr, n, momentsLocked) {
+			break
+		}
+		runtime.Gosched()
+	}
+	a.load(dst)
+	a.n = math.Float64frombits(n)
+	a.merge(&b)
+	a.storeTail(dst)
+	atomic.StoreUint64(ptr, math.Float64bits(a.n))
+}
+
+// momentsResult computes the result
+// of the statistical aggregate kind,
+// or false if the result is NULL
+func momentsResult(data []byte, kind AggregateKind) (float64, bool) {
+	var m moments
+	m.load(data)
+	if m.n == 0 {
+		return 0, false
+	}
+	switch kind {
+	case AggregateKindVarPop:
+		return m.m2x / m.n, true
+	case AggregateKindVarSamp:
+		if m.n < 2 {
+			return 0, false
+		}
+		return m.m2x / (m.n - 1), true
+	case AggregateKindStddevPop:
+		return math.Sqrt(m.m2x / m.n), true
+	case AggregateKindStddevSamp:
+		if m.n < 2 {
+			return 0, false
+		}
+		return math.Sqrt(m.m2x / (m.n - 1)), true
+	case AggregateKindCovarPop:
+		return m.cxy / m.n, true
+	case AggregateKindCorr:
+		if m.m2x == 0 || m.m2y == 0 {
+			return 0, false
+		}
+		return m.cxy / math.Sqrt(m.m2x*m.m2y), true
+	}
+	return 0, false
+}
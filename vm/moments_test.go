@@ -0,0 +1,109 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMomentsMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	xs := make([]float64, 1000)
+	ys := make([]float64, len(xs))
+	for i := range xs {
+		// a large offset makes the naive
+		// sum-of-squares computation useless
+		xs[i] = 1e9 + r.NormFloat64()*10
+		ys[i] = 2*xs[i] + r.NormFloat64()
+	}
+
+	// two-pass reference computation
+	var meanx, meany float64
+	for i := range xs {
+		meanx += xs[i]
+		meany += ys[i]
+	}
+	n := float64(len(xs))
+	meanx /= n
+	meany /= n
+	var m2x, m2y, cxy float64
+	for i := range xs {
+		m2x += (xs[i] - meanx) * (xs[i] - meanx)
+		m2y += (ys[i] - meany) * (ys[i] - meany)
+		cxy += (xs[i] - meanx) * (ys[i] - meany)
+	}
+	want := map[AggregateKind]float64{
+		AggregateKindVarPop:     m2x / n,
+		AggregateKindVarSamp:    m2x / (n - 1),
+		AggregateKindStddevPop:  math.Sqrt(m2x / n),
+		AggregateKindStddevSamp: math.Sqrt(m2x / (n - 1)),
+		AggregateKindCovarPop:   cxy / n,
+		AggregateKindCorr:       cxy / math.Sqrt(m2x*m2y),
+	}
+
+	// accumulate in uneven pieces
+	// to exercise merging
+	for _, split := range []int{0, 1, 137, 500, 999, 1000} {
+		var left, right moments
+		for i := range xs {
+			if i < split {
+				left.add(xs[i], ys[i])
+			} else {
+				right.add(xs[i], ys[i])
+			}
+		}
+		dst := make([]byte, momentsSize)
+		src := make([]byte, momentsSize)
+		left.store(dst)
+		right.store(src)
+		if split&1 == 0 {
+			momentsMerge(dst, src)
+		} else {
+			momentsMergeAtomically(dst, src)
+		}
+		for kind, w := range want {
+			got, ok := momentsResult(dst, kind)
+			if !ok {
+				t.Fatalf("split %d: kind %d: no result", split, kind)
+			}
+			if math.Abs(got-w) > math.Abs(w)*1e-6 {
+				t.Errorf("split %d: kind %d: got %g, want %g", split, kind, got, w)
+			}
+		}
+	}
+}
+
+func TestMomentsNull(t *testing.T) {
+	buf := make([]byte, momentsSize)
+	for _, kind := range []AggregateKind{AggregateKindVarPop, AggregateKindCorr} {
+		if _, ok := momentsResult(buf, kind); ok {
+			t.Errorf("kind %d: result from empty state", kind)
+		}
+	}
+	var m moments
+	m.add(3, 4)
+	m.store(buf)
+	if _, ok := momentsResult(buf, AggregateKindVarSamp); ok {
+		t.Error("VAR_SAMP of one value is not NULL")
+	}
+	if _, ok := momentsResult(buf, AggregateKindCorr); ok {
+		t.Error("CORR of one value is not NULL")
+	}
+	if v, ok := momentsResult(buf, AggregateKindVarPop); !ok || v != 0 {
+		t.Errorf("VAR_POP of one value = %g, %v", v, ok)
+	}
+}
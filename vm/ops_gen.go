@@ -3,308 +3,345 @@ package vm
 // Code generated automatically; DO NOT EDIT
 
 const (
-	opret                    bcop = 0
-	opjz                     bcop = 1
-	oploadk                  bcop = 2
-	opsavek                  bcop = 3
-	opxchgk                  bcop = 4
-	oploadb                  bcop = 5
-	opsaveb                  bcop = 6
-	oploadv                  bcop = 7
-	opsavev                  bcop = 8
-	oploadzerov              bcop = 9
-	opsavezerov              bcop = 10
-	oploadpermzerov          bcop = 11
-	opsaveblendv             bcop = 12
-	oploads                  bcop = 13
-	opsaves                  bcop = 14
-	oploadzeros              bcop = 15
-	opsavezeros              bcop = 16
-	opbroadcastimmk          bcop = 17
-	opfalse                  bcop = 18
-	opandk                   bcop = 19
-	opork                    bcop = 20
-	opandnotk                bcop = 21
-	opnandk                  bcop = 22
-	opxork                   bcop = 23
-	opnotk                   bcop = 24
-	opxnork                  bcop = 25
-	opbroadcastimmf          bcop = 26
-	opbroadcastimmi          bcop = 27
-	opabsf                   bcop = 28
-	opabsi                   bcop = 29
-	opnegf                   bcop = 30
-	opnegi                   bcop = 31
-	opsignf                  bcop = 32
-	opsigni                  bcop = 33
-	opsquaref                bcop = 34
-	opsquarei                bcop = 35
-	opbitnoti                bcop = 36
-	opbitcounti              bcop = 37
-	oproundf                 bcop = 38
-	oproundevenf             bcop = 39
-	optruncf                 bcop = 40
-	opfloorf                 bcop = 41
-	opceilf                  bcop = 42
-	opaddf                   bcop = 43
-	opaddimmf                bcop = 44
-	opaddi                   bcop = 45
-	opaddimmi                bcop = 46
-	opsubf                   bcop = 47
-	opsubimmf                bcop = 48
-	opsubi                   bcop = 49
-	opsubimmi                bcop = 50
-	oprsubf                  bcop = 51
-	oprsubimmf               bcop = 52
-	oprsubi                  bcop = 53
-	oprsubimmi               bcop = 54
-	opmulf                   bcop = 55
-	opmulimmf                bcop = 56
-	opmuli                   bcop = 57
-	opmulimmi                bcop = 58
-	opdivf                   bcop = 59
-	opdivimmf                bcop = 60
-	oprdivf                  bcop = 61
-	oprdivimmf               bcop = 62
-	opdivi                   bcop = 63
-	opdivimmi                bcop = 64
-	oprdivi                  bcop = 65
-	oprdivimmi               bcop = 66
-	opmodf                   bcop = 67
-	opmodimmf                bcop = 68
-	oprmodf                  bcop = 69
-	oprmodimmf               bcop = 70
-	opmodi                   bcop = 71
-	opmodimmi                bcop = 72
-	oprmodi                  bcop = 73
-	oprmodimmi               bcop = 74
-	opaddmulimmi             bcop = 75
-	opminvaluef              bcop = 76
-	opminvalueimmf           bcop = 77
-	opmaxvaluef              bcop = 78
-	opmaxvalueimmf           bcop = 79
-	opminvaluei              bcop = 80
-	opminvalueimmi           bcop = 81
-	opmaxvaluei              bcop = 82
-	opmaxvalueimmi           bcop = 83
-	opandi                   bcop = 84
-	opandimmi                bcop = 85
-	opori                    bcop = 86
-	oporimmi                 bcop = 87
-	opxori                   bcop = 88
-	opxorimmi                bcop = 89
-	opslli                   bcop = 90
-	opsllimmi                bcop = 91
-	opsrai                   bcop = 92
-	opsraimmi                bcop = 93
-	opsrli                   bcop = 94
-	opsrlimmi                bcop = 95
-	opsqrtf                  bcop = 96
-	opcbrtf                  bcop = 97
-	opexpf                   bcop = 98
-	opexp2f                  bcop = 99
-	opexp10f                 bcop = 100
-	opexpm1f                 bcop = 101
-	oplnf                    bcop = 102
-	opln1pf                  bcop = 103
-	oplog2f                  bcop = 104
-	oplog10f                 bcop = 105
-	opsinf                   bcop = 106
-	opcosf                   bcop = 107
-	optanf                   bcop = 108
-	opasinf                  bcop = 109
-	opacosf                  bcop = 110
-	opatanf                  bcop = 111
-	opatan2f                 bcop = 112
-	ophypotf                 bcop = 113
-	oppowf                   bcop = 114
-	opcvtktof64              bcop = 115
-	opcvtktoi64              bcop = 116
-	opcvti64tok              bcop = 117
-	opcvti64tof64            bcop = 118
-	opcvtf64toi64            bcop = 119
-	opfproundu               bcop = 120
-	opfproundd               bcop = 121
-	opcvti64tostr            bcop = 122
-	opcmpeqf                 bcop = 123
-	opcmpeqi                 bcop = 124
-	opcmpeqimmf              bcop = 125
-	opcmpeqimmi              bcop = 126
-	opcmpltf                 bcop = 127
-	opcmplti                 bcop = 128
-	opcmpltimmf              bcop = 129
-	opcmpltimmi              bcop = 130
-	opcmplef                 bcop = 131
-	opcmplei                 bcop = 132
-	opcmpleimmf              bcop = 133
-	opcmpleimmi              bcop = 134
-	opcmpgtf                 bcop = 135
-	opcmpgti                 bcop = 136
-	opcmpgtimmf              bcop = 137
-	opcmpgtimmi              bcop = 138
-	opcmpgef                 bcop = 139
-	opcmpgei                 bcop = 140
-	opcmpgeimmf              bcop = 141
-	opcmpgeimmi              bcop = 142
-	opisnanf                 bcop = 143
-	opchecktag               bcop = 144
-	opisnull                 bcop = 145
-	opisnotnull              bcop = 146
-	opistrue                 bcop = 147
-	opisfalse                bcop = 148
-	opeqslice                bcop = 149
-	opequalv                 bcop = 150
-	opeqv4mask               bcop = 151
-	opeqv4maskplus           bcop = 152
-	opeqv8                   bcop = 153
-	opeqv8plus               bcop = 154
-	opleneq                  bcop = 155
-	opdateaddmonth           bcop = 156
-	opdateaddmonthimm        bcop = 157
-	opdateaddyear            bcop = 158
-	opdatediffparam          bcop = 159
-	opdatediffmonthyear      bcop = 160
-	opdateextractmicrosecond bcop = 161
-	opdateextractmillisecond bcop = 162
-	opdateextractsecond      bcop = 163
-	opdateextractminute      bcop = 164
-	opdateextracthour        bcop = 165
-	opdateextractday         bcop = 166
-	opdateextractmonth       bcop = 167
-	opdateextractyear        bcop = 168
-	opdatetounixepoch        bcop = 169
-	opdatetruncmillisecond   bcop = 170
-	opdatetruncsecond        bcop = 171
-	opdatetruncminute        bcop = 172
-	opdatetrunchour          bcop = 173
-	opdatetruncday           bcop = 174
-	opdatetruncmonth         bcop = 175
-	opdatetruncyear          bcop = 176
-	opunboxts                bcop = 177
-	opboxts                  bcop = 178
-	optimelt                 bcop = 179
-	optimegt                 bcop = 180
-	opconsttm                bcop = 181
-	optmextract              bcop = 182
-	opwidthbucketf           bcop = 183
-	opwidthbucketi           bcop = 184
-	optimebucketts           bcop = 185
-	opgeohash                bcop = 186
-	opgeohashimm             bcop = 187
-	opgeotilex               bcop = 188
-	opgeotiley               bcop = 189
-	opgeotilees              bcop = 190
-	opgeotileesimm           bcop = 191
-	opgeodistance            bcop = 192
-	opconcatlenget1          bcop = 193
-	opconcatlenget2          bcop = 194
-	opconcatlenget3          bcop = 195
-	opconcatlenget4          bcop = 196
-	opconcatlenacc1          bcop = 197
-	opconcatlenacc2          bcop = 198
-	opconcatlenacc3          bcop = 199
-	opconcatlenacc4          bcop = 200
-	opallocstr               bcop = 201
-	opappendstr              bcop = 202
-	opfindsym                bcop = 203
-	opfindsym2               bcop = 204
-	opfindsym2rev            bcop = 205
-	opfindsym3               bcop = 206
-	opblendv                 bcop = 207
-	opblendrevv              bcop = 208
-	opblendnum               bcop = 209
-	opblendnumrev            bcop = 210
-	opblendslice             bcop = 211
-	opblendslicerev          bcop = 212
-	opunpack                 bcop = 213
-	opunsymbolize            bcop = 214
-	opunboxktoi64            bcop = 215
-	optoint                  bcop = 216
-	optof64                  bcop = 217
-	opboxfloat               bcop = 218
-	opboxint                 bcop = 219
-	opboxmask                bcop = 220
-	opboxmask2               bcop = 221
-	opboxmask3               bcop = 222
-	opboxstring              bcop = 223
-	ophashvalue              bcop = 224
-	ophashvalueplus          bcop = 225
-	ophashmember             bcop = 226
-	ophashlookup             bcop = 227
-	opaggandk                bcop = 228
-	opaggork                 bcop = 229
-	opaggsumf                bcop = 230
-	opaggsumi                bcop = 231
-	opaggminf                bcop = 232
-	opaggmini                bcop = 233
-	opaggmaxf                bcop = 234
-	opaggmaxi                bcop = 235
-	opaggandi                bcop = 236
-	opaggori                 bcop = 237
-	opaggxori                bcop = 238
-	opaggcount               bcop = 239
-	opaggbucket              bcop = 240
-	opaggslotandk            bcop = 241
-	opaggslotork             bcop = 242
-	opaggslotaddf            bcop = 243
-	opaggslotaddi            bcop = 244
-	opaggslotavgf            bcop = 245
-	opaggslotavgi            bcop = 246
-	opaggslotminf            bcop = 247
-	opaggslotmini            bcop = 248
-	opaggslotmaxf            bcop = 249
-	opaggslotmaxi            bcop = 250
-	opaggslotandi            bcop = 251
-	opaggslotori             bcop = 252
-	opaggslotxori            bcop = 253
-	opaggslotcount           bcop = 254
-	oplitref                 bcop = 255
-	opsplit                  bcop = 256
-	optuple                  bcop = 257
-	opdupv                   bcop = 258
-	opzerov                  bcop = 259
-	opobjectsize             bcop = 260
-	opCmpStrEqCs             bcop = 261
-	opCmpStrEqCi             bcop = 262
-	opCmpStrEqUTF8Ci         bcop = 263
-	opSkip1charLeft          bcop = 264
-	opSkip1charRight         bcop = 265
-	opSkipNcharLeft          bcop = 266
-	opSkipNcharRight         bcop = 267
-	opTrimWsLeft             bcop = 268
-	opTrimWsRight            bcop = 269
-	opTrim4charLeft          bcop = 270
-	opTrim4charRight         bcop = 271
-	opTrimPrefixCs           bcop = 272
-	opTrimPrefixCi           bcop = 273
-	opTrimSuffixCs           bcop = 274
-	opTrimSuffixCi           bcop = 275
-	opContainsSubstrCs       bcop = 276
-	opContainsSubstrCi       bcop = 277
-	opContainsSuffixCs       bcop = 278
-	opContainsSuffixCi       bcop = 279
-	opContainsSuffixUTF8Ci   bcop = 280
-	opContainsPrefixCs       bcop = 281
-	opContainsPrefixCi       bcop = 282
-	opContainsPrefixUTF8Ci   bcop = 283
-	opLengthStr              bcop = 284
-	opSubstr                 bcop = 285
-	opSplitPart              bcop = 286
-	opMatchpatCs             bcop = 287
-	opMatchpatCi             bcop = 288
-	opMatchpatUTF8Ci         bcop = 289
-	opIsSubnetOfIP4          bcop = 290
-	opDfaT6                  bcop = 291
-	opDfaT7                  bcop = 292
-	opDfaT8                  bcop = 293
-	opDfaT6Z                 bcop = 294
-	opDfaT7Z                 bcop = 295
-	opDfaT8Z                 bcop = 296
-	opDfaL                   bcop = 297
-	opDfaLZ                  bcop = 298
-	opslower                 bcop = 299
-	opsupper                 bcop = 300
-	opsadjustsize            bcop = 301
-	optrap                   bcop = 302
-	_maxbcop                      = 303
+	opret                          bcop = 0
+	opjz                           bcop = 1
+	oploadk                        bcop = 2
+	opsavek                        bcop = 3
+	opxchgk                        bcop = 4
+	oploadb                        bcop = 5
+	opsaveb                        bcop = 6
+	oploadv                        bcop = 7
+	opsavev                        bcop = 8
+	oploadzerov                    bcop = 9
+	opsavezerov                    bcop = 10
+	oploadpermzerov                bcop = 11
+	opsaveblendv                   bcop = 12
+	oploads                        bcop = 13
+	opsaves                        bcop = 14
+	oploadzeros                    bcop = 15
+	opsavezeros                    bcop = 16
+	opbroadcastimmk                bcop = 17
+	opfalse                        bcop = 18
+	opandk                         bcop = 19
+	opork                          bcop = 20
+	opandnotk                      bcop = 21
+	opnandk                        bcop = 22
+	opxork                         bcop = 23
+	opnotk                         bcop = 24
+	opxnork                        bcop = 25
+	opbroadcastimmf                bcop = 26
+	opbroadcastimmi                bcop = 27
+	opabsf                         bcop = 28
+	opabsi                         bcop = 29
+	opnegf                         bcop = 30
+	opnegi                         bcop = 31
+	opsignf                        bcop = 32
+	opsigni                        bcop = 33
+	opsquaref                      bcop = 34
+	opsquarei                      bcop = 35
+	opbitnoti                      bcop = 36
+	opbitcounti                    bcop = 37
+	oproundf                       bcop = 38
+	oproundevenf                   bcop = 39
+	optruncf                       bcop = 40
+	opfloorf                       bcop = 41
+	opceilf                        bcop = 42
+	opaddf                         bcop = 43
+	opaddimmf                      bcop = 44
+	opaddi                         bcop = 45
+	opaddimmi                      bcop = 46
+	opsubf                         bcop = 47
+	opsubimmf                      bcop = 48
+	opsubi                         bcop = 49
+	opsubimmi                      bcop = 50
+	oprsubf                        bcop = 51
+	oprsubimmf                     bcop = 52
+	oprsubi                        bcop = 53
+	oprsubimmi                     bcop = 54
+	opmulf                         bcop = 55
+	opmulimmf                      bcop = 56
+	opmuli                         bcop = 57
+	opmulimmi                      bcop = 58
+	opdivf                         bcop = 59
+	opdivimmf                      bcop = 60
+	oprdivf                        bcop = 61
+	oprdivimmf                     bcop = 62
+	opdivi                         bcop = 63
+	opdivimmi                      bcop = 64
+	oprdivi                        bcop = 65
+	oprdivimmi                     bcop = 66
+	opmodf                         bcop = 67
+	opmodimmf                      bcop = 68
+	oprmodf                        bcop = 69
+	oprmodimmf                     bcop = 70
+	opmodi                         bcop = 71
+	opmodimmi                      bcop = 72
+	oprmodi                        bcop = 73
+	oprmodimmi                     bcop = 74
+	opaddmulimmi                   bcop = 75
+	opminvaluef                    bcop = 76
+	opminvalueimmf                 bcop = 77
+	opmaxvaluef                    bcop = 78
+	opmaxvalueimmf                 bcop = 79
+	opminvaluei                    bcop = 80
+	opminvalueimmi                 bcop = 81
+	opmaxvaluei                    bcop = 82
+	opmaxvalueimmi                 bcop = 83
+	opandi                         bcop = 84
+	opandimmi                      bcop = 85
+	opori                          bcop = 86
+	oporimmi                       bcop = 87
+	opxori                         bcop = 88
+	opxorimmi                      bcop = 89
+	opslli                         bcop = 90
+	opsllimmi                      bcop = 91
+	opsrai                         bcop = 92
+	opsraimmi                      bcop = 93
+	opsrli                         bcop = 94
+	opsrlimmi                      bcop = 95
+	opsqrtf                        bcop = 96
+	opcbrtf                        bcop = 97
+	opexpf                         bcop = 98
+	opexp2f                        bcop = 99
+	opexp10f                       bcop = 100
+	opexpm1f                       bcop = 101
+	oplnf                          bcop = 102
+	opln1pf                        bcop = 103
+	oplog2f                        bcop = 104
+	oplog10f                       bcop = 105
+	opsinf                         bcop = 106
+	opcosf                         bcop = 107
+	optanf                         bcop = 108
+	opasinf                        bcop = 109
+	opacosf                        bcop = 110
+	opatanf                        bcop = 111
+	opatan2f                       bcop = 112
+	ophypotf                       bcop = 113
+	oppowf                         bcop = 114
+	opcvtktof64                    bcop = 115
+	opcvtktoi64                    bcop = 116
+	opcvti64tok                    bcop = 117
+	opcvti64tof64                  bcop = 118
+	opcvtf64toi64                  bcop = 119
+	opfproundu                     bcop = 120
+	opfproundd                     bcop = 121
+	opcvti64tostr                  bcop = 122
+	opcmpeqf                       bcop = 123
+	opcmpeqi                       bcop = 124
+	opcmpeqimmf                    bcop = 125
+	opcmpeqimmi                    bcop = 126
+	opcmpltf                       bcop = 127
+	opcmplti                       bcop = 128
+	opcmpltimmf                    bcop = 129
+	opcmpltimmi                    bcop = 130
+	opcmplef                       bcop = 131
+	opcmplei                       bcop = 132
+	opcmpleimmf                    bcop = 133
+	opcmpleimmi                    bcop = 134
+	opcmpgtf                       bcop = 135
+	opcmpgti                       bcop = 136
+	opcmpgtimmf                    bcop = 137
+	opcmpgtimmi                    bcop = 138
+	opcmpgef                       bcop = 139
+	opcmpgei                       bcop = 140
+	opcmpgeimmf                    bcop = 141
+	opcmpgeimmi                    bcop = 142
+	opisnanf                       bcop = 143
+	opchecktag                     bcop = 144
+	opisnull                       bcop = 145
+	opisnotnull                    bcop = 146
+	opistrue                       bcop = 147
+	opisfalse                      bcop = 148
+	opeqslice                      bcop = 149
+	opequalv                       bcop = 150
+	opeqv4mask                     bcop = 151
+	opeqv4maskplus                 bcop = 152
+	opeqv8                         bcop = 153
+	opeqv8plus                     bcop = 154
+	opleneq                        bcop = 155
+	opdateaddmonth                 bcop = 156
+	opdateaddmonthimm              bcop = 157
+	opdateaddyear                  bcop = 158
+	opdatediffparam                bcop = 159
+	opdatediffmonthyear            bcop = 160
+	opdateextractmicrosecond       bcop = 161
+	opdateextractmillisecond       bcop = 162
+	opdateextractsecond            bcop = 163
+	opdateextractminute            bcop = 164
+	opdateextracthour              bcop = 165
+	opdateextractday               bcop = 166
+	opdateextractmonth             bcop = 167
+	opdateextractyear              bcop = 168
+	opdateextractdow               bcop = 169
+	opdatetounixepoch              bcop = 170
+	opdatetruncmillisecond         bcop = 171
+	opdatetruncsecond              bcop = 172
+	opdatetruncminute              bcop = 173
+	opdatetrunchour                bcop = 174
+	opdatetruncday                 bcop = 175
+	opdatetruncmonth               bcop = 176
+	opdatetruncyear                bcop = 177
+	opconvtz                       bcop = 178
+	opunboxts                      bcop = 179
+	opboxts                        bcop = 180
+	optimelt                       bcop = 181
+	optimegt                       bcop = 182
+	opconsttm                      bcop = 183
+	optmextract                    bcop = 184
+	opwidthbucketf                 bcop = 185
+	opwidthbucketi                 bcop = 186
+	optimebucketts                 bcop = 187
+	opgeohash                      bcop = 188
+	opgeohashimm                   bcop = 189
+	opgeotilex                     bcop = 190
+	opgeotiley                     bcop = 191
+	opgeotilees                    bcop = 192
+	opgeotileesimm                 bcop = 193
+	opgeodistance                  bcop = 194
+	opconcatlenget1                bcop = 195
+	opconcatlenget2                bcop = 196
+	opconcatlenget3                bcop = 197
+	opconcatlenget4                bcop = 198
+	opconcatlenacc1                bcop = 199
+	opconcatlenacc2                bcop = 200
+	opconcatlenacc3                bcop = 201
+	opconcatlenacc4                bcop = 202
+	opallocstr                     bcop = 203
+	opappendstr                    bcop = 204
+	opfindsym                      bcop = 205
+	opfindsym2                     bcop = 206
+	opfindsym2rev                  bcop = 207
+	opfindsym3                     bcop = 208
+	opblendv                       bcop = 209
+	opblendrevv                    bcop = 210
+	opblendnum                     bcop = 211
+	opblendnumrev                  bcop = 212
+	opblendslice                   bcop = 213
+	opblendslicerev                bcop = 214
+	opunpack                       bcop = 215
+	opunsymbolize                  bcop = 216
+	opunboxktoi64                  bcop = 217
+	optoint                        bcop = 218
+	optof64                        bcop = 219
+	opboxfloat                     bcop = 220
+	opboxint                       bcop = 221
+	opboxmask                      bcop = 222
+	opboxmask2                     bcop = 223
+	opboxmask3                     bcop = 224
+	opboxstring                    bcop = 225
+	ophashvalue                    bcop = 226
+	ophashvalueplus                bcop = 227
+	ophashmember                   bcop = 228
+	ophashlookup                   bcop = 229
+	opaggandk                      bcop = 230
+	opaggork                       bcop = 231
+	opaggsumf                      bcop = 232
+	opaggsumi                      bcop = 233
+	opaggminf                      bcop = 234
+	opaggmini                      bcop = 235
+	opaggmaxf                      bcop = 236
+	opaggmaxi                      bcop = 237
+	opaggandi                      bcop = 238
+	opaggori                       bcop = 239
+	opaggxori                      bcop = 240
+	opaggcount                     bcop = 241
+	opaggapproxcount               bcop = 242
+	opaggapproxcountmerge          bcop = 243
+	opaggapproxpercentile          bcop = 244
+	opaggapproxpercentilemerge     bcop = 245
+	opaggmoments                   bcop = 246
+	opaggmomentsmerge              bcop = 247
+	opaggarray                     bcop = 248
+	opaggarraymerge                bcop = 249
+	opaggbucket                    bcop = 250
+	opaggslotandk                  bcop = 251
+	opaggslotork                   bcop = 252
+	opaggslotaddf                  bcop = 253
+	opaggslotaddi                  bcop = 254
+	opaggslotavgf                  bcop = 255
+	opaggslotavgi                  bcop = 256
+	opaggslotminf                  bcop = 257
+	opaggslotmini                  bcop = 258
+	opaggslotmaxf                  bcop = 259
+	opaggslotmaxi                  bcop = 260
+	opaggslotandi                  bcop = 261
+	opaggslotori                   bcop = 262
+	opaggslotxori                  bcop = 263
+	opaggslotcount                 bcop = 264
+	opaggslotapproxcount           bcop = 265
+	opaggslotapproxcountmerge      bcop = 266
+	opaggslotapproxpercentile      bcop = 267
+	opaggslotapproxpercentilemerge bcop = 268
+	opaggslotmoments               bcop = 269
+	opaggslotmomentsmerge          bcop = 270
+	opaggslotarray                 bcop = 271
+	opaggslotarraymerge            bcop = 272
+	oplitref                       bcop = 273
+	opsplit                        bcop = 274
+	optuple                        bcop = 275
+	opdupv                         bcop = 276
+	opzerov                        bcop = 277
+	opobjectsize                   bcop = 278
+	opCmpStrEqCs                   bcop = 279
+	opCmpStrEqCi                   bcop = 280
+	opCmpStrEqUTF8Ci               bcop = 281
+	opSkip1charLeft                bcop = 282
+	opSkip1charRight               bcop = 283
+	opSkipNcharLeft                bcop = 284
+	opSkipNcharRight               bcop = 285
+	opTrimWsLeft                   bcop = 286
+	opTrimWsRight                  bcop = 287
+	opTrim4charLeft                bcop = 288
+	opTrim4charRight               bcop = 289
+	opTrimPrefixCs                 bcop = 290
+	opTrimPrefixCi                 bcop = 291
+	opTrimSuffixCs                 bcop = 292
+	opTrimSuffixCi                 bcop = 293
+	opContainsSubstrCs             bcop = 294
+	opContainsSubstrCi             bcop = 295
+	opContainsSuffixCs             bcop = 296
+	opContainsSuffixCi             bcop = 297
+	opContainsSuffixUTF8Ci         bcop = 298
+	opContainsPrefixCs             bcop = 299
+	opContainsPrefixCi             bcop = 300
+	opContainsPrefixUTF8Ci         bcop = 301
+	opLengthStr                    bcop = 302
+	opSubstr                       bcop = 303
+	opSplitPart                    bcop = 304
+	opMatchpatCs                   bcop = 305
+	opMatchpatCi                   bcop = 306
+	opMatchpatUTF8Ci               bcop = 307
+	opIsSubnetOfIP4                bcop = 308
+	opDfaT6                        bcop = 309
+	opDfaT7                        bcop = 310
+	opDfaT8                        bcop = 311
+	opDfaT6Z                       bcop = 312
+	opDfaT7Z                       bcop = 313
+	opDfaT8Z                       bcop = 314
+	opDfaL                         bcop = 315
+	opDfaLZ                        bcop = 316
+	opStrPos                       bcop = 317
+	opStrReplace                   bcop = 318
+	opStrReverse                   bcop = 319
+	opStrInitcap                   bcop = 320
+	opStrRepeat                    bcop = 321
+	opStrLpad                      bcop = 322
+	opStrRpad                      bcop = 323
+	opparsets                      bcop = 324
+	opformatts                     bcop = 325
+	opregexpextract                bcop = 326
+	opregexpcount                  bcop = 327
+	opregexpreplace                bcop = 328
+	opinetparse                    bcop = 329
+	opinetfamily                   bcop = 330
+	opIsSubnetOfIP6                bcop = 331
+	oparrayposition                bcop = 332
+	oparrayslice                   bcop = 333
+	oparraydistinct                bcop = 334
+	oparrayjoin                    bcop = 335
+	opslower                       bcop = 336
+	opsupper                       bcop = 337
+	opsadjustsize                  bcop = 338
+	optrap                         bcop = 339
+	_maxbcop                            = 340
 )
@@ -0,0 +1,184 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/SnellerInc/sneller/internal/atomicext"
+)
+
+// APPROX_PERCENTILE is implemented as a log-linear
+// histogram: the magnitude of each input value selects
+// one of 2^percentileSubBits buckets per power of two
+// in [2^percentileMinExp, 2^percentileMaxExp), so
+// the relative width of every bucket is at most
+// 1/2^percentileSubBits. Magnitudes below the range
+// are counted as zero, and magnitudes above the range
+// are counted in the last bucket. Negative values
+// are counted in a mirrored set of buckets.
+//
+// Sketches are merged by adding the bucket counts,
+// which makes the aggregate trivially distributive.
+//
+// The layout of a sketch is
+//
+//	[0:8]   the percentile (float64; never merged)
+//	[8:16]  the smallest value seen (float64)
+//	[16:24] the largest value seen (float64)
+//	[24:]   percentileBuckets bucket counts (uint64)
+//
+// The bucket at index percentileMagBuckets holds zero;
+// positive values are counted above it and negative
+// values are counted below it.
+const (
+	percentileSubBits    = 3
+	percentileMinExp     = -24
+	percentileMaxExp     = 40
+	percentileMagBuckets = (percentileMaxExp - percentileMinExp) << percentileSubBits
+	percentileBuckets    = 2*percentileMagBuckets + 1
+	percentileSketchSize = 24 + 8*percentileBuckets
+
+	// percentileBias is subtracted from the biased exponent
+	// and leading mantissa bits of a value to produce
+	// 1 + the index of its magnitude bucket
+	percentileBias = (1023+percentileMinExp)<<percentileSubBits - 1
+)
+
+// initPercentileSketch initializes a sketch for
+// computing the percentile p
+func initPercentileSketch(data []byte, p float64) {
+	_ = data[:percentileSketchSize]
+	binary.LittleEndian.PutUint64(data, math.Float64bits(p))
+	binary.LittleEndian.PutUint64(data[8:], math.Float64bits(math.Inf(1)))
+	binary.LittleEndian.PutUint64(data[16:], math.Float64bits(math.Inf(-1)))
+}
+
+// initPercentileSketches initializes the sketches
+// of every percentile aggregate in data, which
+// must have already been initialized with initAggregateValues
+func initPercentileSketches(data []byte, agg Aggregation, kinds []AggregateKind) {
+	offset := 0
+	for i, kind := range kinds {
+		if kind == AggregateKindApproxPercentile || kind == AggregateKindApproxPercentilePartial {
+			p, _ := agg[i].Expr.Percentile()
+			initPercentileSketch(data[offset:], p)
+		}
+		offset += int(aggregateKindInfoTable[kind].dataSize)
+	}
+}
+
+// percentileIndex returns the bucket index of f
+//
+// (this is the same computation that is
+// performed by the bytecode aggregate ops)
+func percentileIndex(f float64) int {
+	bits := math.Float64bits(f)
+	i := int((bits<<1)>>(53-percentileSubBits)) - percentileBias
+	if i < 0 {
+		i = 0
+	} else if i > percentileMagBuckets {
+		i = percentileMagBuckets
+	}
+	if bits>>63 != 0 {
+		i = -i
+	}
+	return percentileMagBuckets + i
+}
+
+// percentileBounds returns the range of
+// magnitudes counted in the magnitude bucket m
+func percentileBounds(m int) (lo, hi float64) {
+	exp := m>>percentileSubBits + percentileMinExp
+	sub := m & (1<<percentileSubBits - 1)
+	lo = math.Ldexp(1+float64(sub)/(1<<percentileSubBits), exp)
+	hi = math.Ldexp(1+float64(sub+1)/(1<<percentileSubBits), exp)
+	return lo, hi
+}
+
+// percentileMerge merges the sketch in src into dst
+func percentileMerge(dst, src []byte) {
+	_ = dst[:percentileSketchSize]
+	_ = src[:percentileSketchSize]
+	bufferMinFloat64(dst[8:], src[8:])
+	bufferMaxFloat64(dst[16:], src[16:])
+	for i := 24; i < percentileSketchSize; i += 8 {
+		bufferAddInt64(dst[i:], src[i:])
+	}
+}
+
+// percentileMergeAtomically is equivalent to
+// percentileMerge, but dst may be updated concurrently
+//
+// dst must be 8-byte aligned
+func percentileMergeAtomically(dst, src []byte) {
+	_ = dst[:percentileSketchSize]
+	_ = src[:percentileSketchSize]
+	atomicext.MinFloat64((*float64)(unsafe.Pointer(&dst[8])), math.Float64frombits(binary.LittleEndian.Uint64(src[8:])))
+	atomicext.MaxFloat64((*float64)(unsafe.Pointer(&dst[16])), math.Float64frombits(binary.LittleEndian.Uint64(src[16:])))
+	for i := 24; i < percentileSketchSize; i += 8 {
+		val := binary.LittleEndian.Uint64(src[i:])
+		if val != 0 {
+			atomic.AddUint64((*uint64)(unsafe.Pointer(&dst[i])), val)
+		}
+	}
+}
+
+// percentileEstimate returns the estimated
+// percentile of the values added to the sketch,
+// or false if no values were added
+func percentileEstimate(data []byte) (float64, bool) {
+	_ = data[:percentileSketchSize]
+	p := math.Float64frombits(binary.LittleEndian.Uint64(data))
+	min := math.Float64frombits(binary.LittleEndian.Uint64(data[8:]))
+	max := math.Float64frombits(binary.LittleEndian.Uint64(data[16:]))
+	counts := data[24:percentileSketchSize]
+	total := uint64(0)
+	for i := 0; i < len(counts); i += 8 {
+		total += binary.LittleEndian.Uint64(counts[i:])
+	}
+	if total == 0 {
+		return 0, false
+	}
+	rank := p * float64(total-1)
+	if rank <= 0 {
+		return min, true
+	}
+	if rank >= float64(total-1) {
+		return max, true
+	}
+	seen := float64(0)
+	for i := 0; i < percentileBuckets; i++ {
+		c := float64(binary.LittleEndian.Uint64(counts[i*8:]))
+		if c == 0 || rank >= seen+c {
+			seen += c
+			continue
+		}
+		// interpolate linearly within the bucket
+		var lo, hi float64
+		if i > percentileMagBuckets {
+			lo, hi = percentileBounds(i - percentileMagBuckets - 1)
+		} else if i < percentileMagBuckets {
+			hi, lo = percentileBounds(percentileMagBuckets - i - 1)
+			lo, hi = -lo, -hi
+		}
+		est := lo + (hi-lo)*(rank-seen+0.5)/c
+		return math.Max(min, math.Min(max, est)), true
+	}
+	return max, true
+}
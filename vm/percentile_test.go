@@ -0,0 +1,110 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func percentileAdd(sketch []byte, f float64) {
+	bufferMinFloat64(sketch[8:], binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+	bufferMaxFloat64(sketch[16:], binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+	i := 24 + 8*percentileIndex(f)
+	binary.LittleEndian.PutUint64(sketch[i:], binary.LittleEndian.Uint64(sketch[i:])+1)
+}
+
+func TestPercentileIndex(t *testing.T) {
+	testcases := []struct {
+		value float64
+		index int
+	}{
+		{0, percentileMagBuckets},
+		{math.Copysign(0, -1), percentileMagBuckets},
+		{math.Ldexp(1, percentileMinExp-1), percentileMagBuckets},
+		{math.Ldexp(1, percentileMinExp), percentileMagBuckets + 1},
+		{-math.Ldexp(1, percentileMinExp), percentileMagBuckets - 1},
+		{math.Ldexp(1, percentileMaxExp), percentileBuckets - 1},
+		{math.Inf(1), percentileBuckets - 1},
+		{math.Inf(-1), 0},
+	}
+	for i := range testcases {
+		got := percentileIndex(testcases[i].value)
+		if got != testcases[i].index {
+			t.Errorf("percentileIndex(%g) = %d, want %d", testcases[i].value, got, testcases[i].index)
+		}
+	}
+	// every bucket must contain the values it is assigned
+	for _, f := range []float64{1, 1.1, 1.125, 3, 1000, 12345.678, 0.001} {
+		for _, v := range []float64{f, -f} {
+			i := percentileIndex(v)
+			var lo, hi float64
+			if i > percentileMagBuckets {
+				lo, hi = percentileBounds(i - percentileMagBuckets - 1)
+			} else {
+				hi, lo = percentileBounds(percentileMagBuckets - i - 1)
+				lo, hi = -lo, -hi
+			}
+			if v < lo || v > hi {
+				t.Errorf("%g not in bucket [%g, %g]", v, lo, hi)
+			}
+		}
+	}
+}
+
+func TestPercentileEstimate(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = r.ExpFloat64()*1000 - 200
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	for _, p := range []float64{0, 0.01, 0.25, 0.5, 0.75, 0.99, 1} {
+		// build the sketch in two halves
+		// to exercise merging
+		left := make([]byte, percentileSketchSize)
+		right := make([]byte, percentileSketchSize)
+		initPercentileSketch(left, p)
+		initPercentileSketch(right, 0)
+		for i := range values {
+			if i&1 == 0 {
+				percentileAdd(left, values[i])
+			} else {
+				percentileAdd(right, values[i])
+			}
+		}
+		percentileMerge(left, right)
+		got, ok := percentileEstimate(left)
+		if !ok {
+			t.Fatal("no estimate")
+		}
+		want := sorted[int(math.Round(p*float64(len(sorted)-1)))]
+		if math.Abs(got-want) > math.Abs(want)/(1<<percentileSubBits) {
+			t.Errorf("p=%g: got %g, want %g", p, got, want)
+		}
+	}
+
+	empty := make([]byte, percentileSketchSize)
+	initPercentileSketch(empty, 0.5)
+	if _, ok := percentileEstimate(empty); ok {
+		t.Error("empty sketch produced an estimate")
+	}
+}
@@ -464,6 +464,50 @@ func cmpAvgFloat64(left, right []byte) int {
 	return cmpPartiqlfp(lavg, ravg)
 }
 
+func cmpApproxCount(left, right []byte) int {
+	lcnt := hllEstimate(left)
+	rcnt := hllEstimate(right)
+	if lcnt < rcnt {
+		return -1
+	}
+	if lcnt > rcnt {
+		return 1
+	}
+	return 0
+}
+
+func cmpApproxPercentile(left, right []byte) int {
+	lval, lok := percentileEstimate(left)
+	rval, rok := percentileEstimate(right)
+
+	if !lok {
+		if !rok {
+			return 0
+		}
+		return 1
+	} else if !rok {
+		return -1
+	}
+	return cmpPartiqlfp(lval, rval)
+}
+
+func cmpMoments(kind AggregateKind) func(left, right []byte) int {
+	return func(left, right []byte) int {
+		lval, lok := momentsResult(left, kind)
+		rval, rok := momentsResult(right, kind)
+
+		if !lok {
+			if !rok {
+				return 0
+			}
+			return 1
+		} else if !rok {
+			return -1
+		}
+		return cmpPartiqlfp(lval, rval)
+	}
+}
+
 var agg2cmp = [...](func([]byte, []byte) int){
 	AggregateKindNone:  nil,
 	AggregateKindSumF:  cmpFloat,
@@ -483,6 +527,19 @@ var agg2cmp = [...](func([]byte, []byte) int){
 	AggregateKindMinTS: cmpInt64,
 	AggregateKindMaxTS: cmpInt64,
 	AggregateKindCount: cmpCount,
+
+	AggregateKindApproxCount:        cmpApproxCount,
+	AggregateKindApproxCountPartial: cmpApproxCount,
+
+	AggregateKindApproxPercentile:        cmpApproxPercentile,
+	AggregateKindApproxPercentilePartial: cmpApproxPercentile,
+
+	AggregateKindVarPop:     cmpMoments(AggregateKindVarPop),
+	AggregateKindVarSamp:    cmpMoments(AggregateKindVarSamp),
+	AggregateKindStddevPop:  cmpMoments(AggregateKindStddevPop),
+	AggregateKindStddevSamp: cmpMoments(AggregateKindStddevSamp),
+	AggregateKindCovarPop:   cmpMoments(AggregateKindCovarPop),
+	AggregateKindCorr:       cmpMoments(AggregateKindCorr),
 }
 
 // return an integer that can be used to sort
@@ -560,20 +617,14 @@ func (a *aggtable) writeRows(delims []vmref) error {
 		// TODO: when the number of restarts is high,
 		// consider allocating table space more aggressively?
 		step := 16 - bits.LeadingZeros16(abort)
+		// errinfo holds the offset of the hash slot
+		// used by aggbucket; other hash slots may be in
+		// use (e.g. for APPROX_COUNT_DISTINCT)
 		hashmem := a.bc.hashmem[a.bc.errinfo>>3:]
 		for i := 0; i < step; i++ {
 			if abort&(1<<i) == 0 {
 				continue
 			}
-
-			// FIXME: we're assuming the result is
-			// allocated in hash slot 0 because the
-			// only other hash slot usage would be
-			// an IN(...) expression that shouldn't
-			// be part of the aggregation...
-			if len(a.bc.hashmem) != 32 {
-				panic("more than 1 hash slot?")
-			}
 			h := hashmem[i*2]
 			off, ok := a.tree.insertSlow(h)
 			if ok {
@@ -613,7 +664,7 @@ func (a *aggtable) writeRows(delims []vmref) error {
 			}
 		}
 	}
-	return nil
+	return a.maybeSpill()
 }
 
 func (a *aggtable) Close() error {
@@ -639,6 +690,9 @@ func (a *aggtable) Close() error {
 		parent.final = nil
 		parent.lock.Unlock()
 		a.merge(tmp)
+		if err := a.maybeSpill(); err != nil {
+			return err
+		}
 		parent.lock.Lock()
 	}
 
@@ -655,25 +709,31 @@ func (a *aggtable) Close() error {
 // all of the right-hand-side entries
 // and inserting/merging them via the slow path
 func (a *aggtable) merge(r *aggtable) {
-	for i := range r.pairs {
-		p := &r.pairs[i]
-		// get value from rhs
-		hash := r.hashof(p)
-		repr := r.fullrepr(p, len(a.parent.by))
-		value := r.valueof(p)
-
-		// regular insert slow path for lhs
-		off, ok := a.tree.insertSlow(hash)
-		if ok {
-			reprloc := int32(len(a.repr))
-			a.repr = append(a.repr, repr...)
-			a.pairs = append(a.pairs, hpair{
-				reprloc: reprloc,
-				hloc:    off,
-			})
-			a.initentry(a.tree.values[off+8:])
-		}
+	a.mergePairs(r, r.pairs)
+}
+
+// mergePairs merges the given pairs
+// from the right-hand-side table into a
+func (a *aggtable) mergePairs(r *aggtable, pairs []hpair) {
+	for i := range pairs {
+		p := &pairs[i]
+		a.insert(r.hashof(p), r.fullrepr(p, len(a.parent.by)), r.valueof(p))
+	}
+}
 
-		mergeAggregatedValues(a.tree.values[off+8:], value, a.aggregateKinds)
+// insert merges the aggregate value for
+// the group with the given hash and
+// representation into a
+func (a *aggtable) insert(hash uint64, repr, value []byte) {
+	off, ok := a.tree.insertSlow(hash)
+	if ok {
+		reprloc := int32(len(a.repr))
+		a.repr = append(a.repr, repr...)
+		a.pairs = append(a.pairs, hpair{
+			reprloc: reprloc,
+			hloc:    off,
+		})
+		a.initentry(a.tree.values[off+8:])
 	}
+	mergeAggregatedValues(a.tree.values[off+8:], value, a.aggregateKinds)
 }
@@ -0,0 +1,117 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+
+	"github.com/SnellerInc/sneller/regexp2"
+)
+
+// REGEXP_EXTRACT, REGEXP_COUNT, and REGEXP_REPLACE
+// are evaluated by the bytecode using the byte DFAs
+// of a regexp2.Matcher; the matcher is serialized
+// into a program with the layout
+//
+//	[0:4]   offset of the Find DFA
+//	[4:8]   offset of the Match DFA
+//	[8:12]  number of pieces
+//	[12:16] piece of the extracted capture group (or ^0)
+//	[16:20] offset of the replacement items
+//	[20:24] number of replacement items
+//	[24:]   offsets of Pieces[j] and Rests[j] for each piece j
+//
+// followed by the DFAs and the replacement items.
+// Each replacement item is a pair of uint32s: either
+// the offset and the length of a literal string, or
+// ^0 followed by the piece of a capture group (or ^0
+// for the complete match). All of the offsets are
+// relative to the start of the program.
+const (
+	regexpFind       = 0
+	regexpMatch      = 4
+	regexpNumPieces  = 8
+	regexpGroup      = 12
+	regexpRepl       = 16
+	regexpNumRepl    = 20
+	regexpHeaderSize = 24
+
+	// layout of regexp2.DsByte
+	regexpDFAFlags       = 8
+	regexpDFAClasses     = 16
+	regexpDFARows        = regexp2.DsByteHeaderSize
+	regexpDFAAccept      = regexp2.DsByteAccept
+	regexpDFAAcceptAtEnd = regexp2.DsByteAcceptAtEnd
+	regexpDFAEarliest    = regexp2.DsByteEarliest
+)
+
+// regexpProgram serializes m for use by the
+// regexp bytecode ops; group is the capture group
+// to extract and repl is the replacement (if any)
+func regexpProgram(m *regexp2.Matcher, group int, repl []regexp2.ReplaceItem) []byte {
+	buf := make([]byte, regexpHeaderSize+8*m.NumPieces)
+	put := func(off int, v uint32) {
+		binary.LittleEndian.PutUint32(buf[off:], v)
+	}
+	piece := func(g int) uint32 {
+		return uint32(int32(m.Piece(g)))
+	}
+	appendData := func(data []byte) uint32 {
+		for len(buf)%4 != 0 {
+			buf = append(buf, 0)
+		}
+		off := uint32(len(buf))
+		buf = append(buf, data...)
+		return off
+	}
+	put(regexpFind, appendData(m.Find.Data()))
+	put(regexpMatch, appendData(m.Match.Data()))
+	put(regexpNumPieces, uint32(m.NumPieces))
+	put(regexpGroup, piece(group))
+	for j := range m.Pieces {
+		put(regexpHeaderSize+8*j, appendData(m.Pieces[j].Data()))
+		put(regexpHeaderSize+8*j+4, appendData(m.Rests[j].Data()))
+	}
+	items := make([]byte, 8*len(repl))
+	for i := range repl {
+		a, b := ^uint32(0), ^uint32(0)
+		if repl[i].Group < 0 {
+			a = appendData([]byte(repl[i].Literal))
+			b = uint32(len(repl[i].Literal))
+		} else {
+			b = piece(repl[i].Group)
+		}
+		binary.LittleEndian.PutUint32(items[8*i:], a)
+		binary.LittleEndian.PutUint32(items[8*i+4:], b)
+	}
+	put(regexpRepl, appendData(items))
+	put(regexpNumRepl, uint32(len(repl)))
+	return buf
+}
+
+// RegexpExtract computes REGEXP_EXTRACT(str, pattern, group)
+func (p *prog) RegexpExtract(str *value, m *regexp2.Matcher, group int) *value {
+	return p.ssa2imm(sRegexpExtract, str, p.mask(str), p.Constant(string(regexpProgram(m, group, nil))).imm)
+}
+
+// RegexpCount computes REGEXP_COUNT(str, pattern)
+func (p *prog) RegexpCount(str *value, m *regexp2.Matcher) *value {
+	return p.ssa2imm(sRegexpCount, str, p.mask(str), p.Constant(string(regexpProgram(m, 0, nil))).imm)
+}
+
+// RegexpReplace computes REGEXP_REPLACE(str, pattern, replacement)
+func (p *prog) RegexpReplace(str *value, m *regexp2.Matcher, repl []regexp2.ReplaceItem) *value {
+	return p.ssa2imm(sRegexpReplace, str, p.mask(str), p.Constant(string(regexpProgram(m, 0, repl))).imm)
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/SnellerInc/sneller/regexp2"
+)
+
+// TestRegexpBF brute-force tests opregexpextract and
+// opregexpcount against the reference implementation
+// in regexp2.Matcher
+func TestRegexpBF(t *testing.T) {
+	type testCase struct {
+		pattern string
+		group   int
+	}
+	testCases := []testCase{
+		{`a+`, 0},
+		{`b*`, 0},
+		{`^a`, 0},
+		{`a$`, 0},
+		{`(a+)(b+)`, 1},
+		{`(a+)(b+)`, 2},
+		{`(a|ab)(b*)`, 1},
+		{`x(a*)b`, 1},
+		{`(é+)=?`, 1},
+		{`[^ ]+`, 0},
+		{`(\w+)=(\w*)`, 2},
+	}
+	space := createSpace(6, []rune{'a', 'b', 'é', ' ', '=', 'x'}, 2000)
+	space = append(space, "", "a", "ab", "x")
+
+	run := func(t *testing.T, m *regexp2.Matcher, group int, data []string) {
+		prog := regexpProgram(m, group, nil)
+		var ctx bctestContext
+		defer ctx.Free()
+		ctx.Taint()
+		ctx.dict = append(ctx.dict[:0], string(prog))
+		ctx.setScalarStrings(data, []byte{})
+		ctx.current = uint16(1<<len(data) - 1)
+		scalarBefore := ctx.getScalarUint32()
+		if err := ctx.ExecuteImm2(opregexpextract, 0); err != nil {
+			t.Fatal(err)
+		}
+		scalarAfter := ctx.getScalarUint32()
+		for i := range data {
+			want, ok := m.Extract([]byte(data[i]), group)
+			obsLane := (ctx.current>>i)&1 == 1
+			if obsLane != ok {
+				t.Fatalf("extract %q: lane %d: got %v, want %v", data[i], i, obsLane, ok)
+			}
+			if !ok {
+				continue
+			}
+			start := int(scalarAfter[0][i] - scalarBefore[0][i])
+			got := data[i][start : start+int(scalarAfter[1][i])]
+			if got != string(want) {
+				t.Fatalf("extract %q: lane %d: got %q, want %q", data[i], i, got, want)
+			}
+		}
+
+		ctx.setScalarStrings(data, []byte{})
+		ctx.current = uint16(1<<len(data) - 1)
+		if err := ctx.ExecuteImm2(opregexpcount, 0); err != nil {
+			t.Fatal(err)
+		}
+		counts := ctx.getScalarInt64()
+		for i := range data {
+			if want := m.Count([]byte(data[i])); counts[i] != int64(want) {
+				t.Fatalf("count %q: lane %d: got %d, want %d", data[i], i, counts[i], want)
+			}
+		}
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			m, err := regexp2.NewMatcher(tc.pattern, []int{tc.group})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(space); i += 16 {
+				end := i + 16
+				if end > len(space) {
+					end = len(space)
+				}
+				run(t, m, tc.group, space[i:end])
+			}
+		})
+	}
+}
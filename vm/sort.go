@@ -67,6 +67,13 @@ type Order struct {
 	ktop    *sorting.Ktop
 	symtabs []ion.Symtab
 
+	// rows for an external sort
+	// (see NewSpillingOrder)
+	spill    *sorting.Spill
+	spilldir string
+	spillmem int
+	spillerr error
+
 	// lock for writing to `records`/`rawrecords`/'ktop'
 	recordsLock sync.Mutex
 
@@ -109,6 +116,36 @@ func NewOrder(dst io.Writer, columns []SortColumn, limit *sorting.Limit, paralle
 	return s
 }
 
+// NewSpillingOrder constructs a new Order QuerySink
+// that sorts all of its input using an external merge sort.
+// Once the rows held in memory exceed roughly maxmem bytes,
+// they are sorted and written to a temporary file in dir
+// (or os.TempDir() if dir is empty), and the sorted files
+// are merged when the Order is closed.
+//
+// Unlike NewOrder, the input rows are not required
+// to share a symbol table.
+func NewSpillingOrder(dst io.Writer, columns []SortColumn, dir string, maxmem, parallelism int) *Order {
+	s := NewOrder(dst, columns, nil, parallelism)
+	s.spilldir = dir
+	s.spillmem = maxmem
+	s.spill = s.newSpill(maxmem)
+	return s
+}
+
+func (s *Order) orderings() []sorting.Ordering {
+	orders := make([]sorting.Ordering, len(s.columns))
+	for i := range s.columns {
+		orders[i].Direction = s.columns[i].Direction
+		orders[i].Nulls = s.columns[i].Nulls
+	}
+	return orders
+}
+
+func (s *Order) newSpill(maxmem int) *sorting.Spill {
+	return sorting.NewSpill(s.spilldir, maxmem, s.orderings())
+}
+
 // setSymbolTable sets symbol table for the sorted data.
 //
 // It's expected that all input chunks have exactly the same symtab.
@@ -140,23 +177,27 @@ func (s *Order) useKtop() bool {
 	if s.limit == nil {
 		return false
 	}
-	return s.limit.Kind == sorting.LimitToHeadRows
+	// with an offset, the rows up to offset+limit
+	// are kept, and the ones before the offset
+	// are dropped in finalizeKtop
+	return s.limit.Kind == sorting.LimitToHeadRows ||
+		s.limit.Kind == sorting.LimitToRange
 }
 
 func (s *Order) newKtop() *sorting.Ktop {
-	orders := make([]sorting.Ordering, len(s.columns))
-	for i := range s.columns {
-		orders[i].Direction = s.columns[i].Direction
-		orders[i].Nulls = s.columns[i].Nulls
-	}
-	return sorting.NewKtop(s.limit.Limit, orders)
+	return sorting.NewKtop(s.limit.Limit+s.limit.Offset, s.orderings())
 }
 
 // Open implements QuerySink.Open
 func (s *Order) Open() (io.WriteCloser, error) {
 	s.wg.Add(1)
 
-	if s.useKtop() {
+	if s.spill != nil {
+		// each thread gets an equal share
+		// of the memory for buffered rows
+		maxmem := s.spillmem / s.parallelism
+		return splitter(&sortstateSpill{parent: s, spill: s.newSpill(maxmem), lastID: -1}), nil
+	} else if s.useKtop() {
 		return splitter(&sortstateKtop{parent: s, ktop: s.newKtop()}), nil
 	} else if s.useSingleColumnSorter() {
 		chunkID := atomic.AddUint32(&s.chunkID, 1) - 1
@@ -175,6 +216,10 @@ func (s *Order) Close() error {
 	// s.sub safely
 	s.wg.Wait()
 
+	if s.spill != nil {
+		return s.finalizeSpill()
+	}
+
 	if !s.useKtop() && s.symtab == nil {
 		if len(s.records) == 0 {
 			// no data at all
@@ -233,42 +278,95 @@ func (s *Order) finalizeSingleColumnSorting() error {
 }
 
 func (s *Order) finalizeKtop() error {
-	var row []ion.Field
+	records := s.ktop.Capture()
+	if s.limit.Offset >= len(records) {
+		return nil
+	}
+	records = records[s.limit.Offset:]
+	rc := recoder{symtabs: s.symtabs}
+	for i := range records {
+		err := rc.add(&records[i])
+		if err != nil {
+			return err
+		}
+	}
+	return rc.flush(s.dst)
+}
+
+func (s *Order) finalizeSpill() error {
+	defer s.spill.Close()
+	if s.spillerr != nil {
+		return s.spillerr
+	}
+	rc := recoder{symtabs: s.symtabs}
+	err := s.spill.Walk(func(rec *sorting.IonRecord) error {
+		err := rc.add(rec)
+		if err != nil {
+			return err
+		}
+		if rc.size() >= s.rp.ChunkAlignment {
+			return rc.flush(s.dst)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return rc.flush(s.dst)
+}
+
+// recoder re-encodes records captured
+// with different symbol tables into
+// chunks that share a symbol table
+type recoder struct {
+	symtabs []ion.Symtab
+
+	row []ion.Field
+	st  ion.Symtab
+	buf ion.Buffer
+}
+
+func (r *recoder) add(rec *sorting.IonRecord) error {
 	var sym ion.Symbol
 	var val ion.Datum
-	var tmp ion.Buffer
-	var globalst ion.Symtab
 	var err error
-	records := s.ktop.Capture()
-	// for each record, re-encode into
-	// the new global symbol table and then
-	// serialize into the temporary buffer
-	for i := range records {
-		st := &s.symtabs[records[i].SymtabID]
-		row = row[:0]
-		contents := records[i].Bytes()
-		for len(contents) > 0 {
-			sym, contents, err = ion.ReadLabel(contents)
-			if err != nil {
-				return err
-			}
-			val, contents, err = ion.ReadDatum(st, contents)
-			if err != nil {
-				return err
-			}
-			row = append(row, ion.Field{
-				Label: st.Get(sym),
-				Value: val,
-			})
+	st := &r.symtabs[rec.SymtabID]
+	r.row = r.row[:0]
+	contents := rec.Bytes()
+	for len(contents) > 0 {
+		sym, contents, err = ion.ReadLabel(contents)
+		if err != nil {
+			return err
 		}
-		tmp.WriteStruct(&globalst, row)
-	}
-	slice := tmp.Size()
-	globalst.Marshal(&tmp, true)
-	out := make([]byte, tmp.Size())
-	pre := copy(out, tmp.Bytes()[slice:])
-	copy(out[pre:], tmp.Bytes()[:slice])
-	_, err = s.dst.Write(out)
+		val, contents, err = ion.ReadDatum(st, contents)
+		if err != nil {
+			return err
+		}
+		r.row = append(r.row, ion.Field{
+			Label: st.Get(sym),
+			Value: val,
+		})
+	}
+	r.buf.WriteStruct(&r.st, r.row)
+	return nil
+}
+
+func (r *recoder) size() int { return r.buf.Size() }
+
+// flush writes the buffered records
+// (preceded by their symbol table) to dst
+func (r *recoder) flush(dst io.Writer) error {
+	if r.buf.Size() == 0 {
+		return nil
+	}
+	slice := r.buf.Size()
+	r.st.Marshal(&r.buf, true)
+	out := make([]byte, r.buf.Size())
+	pre := copy(out, r.buf.Bytes()[slice:])
+	copy(out[pre:], r.buf.Bytes()[:slice])
+	r.buf.Reset()
+	r.st.Reset()
+	_, err := dst.Write(out)
 	return err
 }
 
@@ -843,6 +941,147 @@ func (s *sortstateKtop) Close() error {
 
 // ----------------------------------------------------------------------
 
+type sortstateSpill struct {
+	// the parent context for this sorting operation
+	parent *Order
+
+	// see the comment in `sortstateMulticolumn`
+	parentNotified bool
+
+	// bytecode for locating columns
+	findbc bytecode
+
+	// local rows (and runs)
+	spill *sorting.Spill
+
+	// the current symbol table and its ID
+	// in parent.symtabs, or -1 if it has not
+	// been captured yet
+	st       *symtab
+	symtabID int
+	// the ID of the last captured symbol
+	// table, or -1 if there is none
+	lastID int
+
+	// buffers for the current record in `writeRows`
+	buffer []byte
+	fields [][2]uint32
+}
+
+func (s *sortstateSpill) next() rowConsumer { return nil }
+
+func (s *sortstateSpill) EndSegment() {
+	s.findbc.dropScratch() // restored in symbolize()
+}
+
+func (s *sortstateSpill) symbolize(st *symtab) error {
+	s.st = st
+	s.symtabID = -1
+	return symbolize(s.parent, &s.findbc, st, false)
+}
+
+// capture saves a copy of the current symbol table
+// in the parent so that records can refer to it
+// after they have been written to disk
+func (s *sortstateSpill) capture() int {
+	if s.symtabID < 0 {
+		s.parent.symtabLock.Lock()
+		// the last captured symbol table is reused
+		// if the current one only adds symbols to it
+		// (or vice versa), which is the common case
+		// for consecutive blocks of the same table
+		if s.lastID >= 0 {
+			if _, ok := s.parent.symtabs[s.lastID].Merge(&s.st.Symtab); ok {
+				s.symtabID = s.lastID
+			}
+		}
+		if s.symtabID < 0 {
+			s.symtabID = len(s.parent.symtabs)
+			s.parent.symtabs = append(s.parent.symtabs, ion.Symtab{})
+			s.st.Symtab.CloneInto(&s.parent.symtabs[s.symtabID])
+		}
+		s.lastID = s.symtabID
+		s.parent.symtabLock.Unlock()
+	}
+	return s.symtabID
+}
+
+func (s *sortstateSpill) writeRows(delims []vmref) error {
+	if len(delims) == 0 {
+		return nil
+	}
+
+	// locate fields within the src
+	fieldsView, err := bcfind(s.parent, &s.findbc, delims)
+	if err != nil {
+		return err
+	}
+
+	columnCount := len(s.parent.columns)
+	if s.fields == nil {
+		s.fields = make([][2]uint32, columnCount)
+	}
+
+	var record sorting.IonRecord
+	record.SymtabID = s.capture()
+	for rowID := 0; rowID < len(delims); rowID++ {
+		bytes := delims[rowID].mem()
+
+		// the boxed values precede the row data;
+		// MISSING columns have a size of zero
+		record.Boxed = 0
+		for columnID := 0; columnID < columnCount; columnID++ {
+			record.Boxed += getdelim(fieldsView, rowID, columnID, columnCount)[1]
+		}
+		bufsize := int(record.Boxed) + len(bytes)
+		if bufsize > cap(s.buffer) {
+			s.buffer = make([]byte, bufsize)
+		}
+		s.buffer = s.buffer[:bufsize]
+		copy(s.buffer[record.Boxed:], bytes)
+
+		boxedOffset := 0
+		for columnID := 0; columnID < columnCount; columnID++ {
+			it := getdelim(fieldsView, rowID, columnID, columnCount)
+			size := it.size()
+			s.fields[columnID][0] = uint32(boxedOffset)
+			s.fields[columnID][1] = uint32(size)
+			if size > 0 {
+				copy(s.buffer[boxedOffset:], it.mem())
+				boxedOffset += size
+			}
+		}
+		record.Raw = s.buffer
+		record.FieldDelims = s.fields
+
+		// the record is copied (or written out)
+		err := s.spill.Add(&record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sortstateSpill) Close() error {
+	if s.parentNotified {
+		return nil
+	}
+	s.parentNotified = true
+
+	s.findbc.reset()
+	s.parent.recordsLock.Lock()
+	err := s.parent.spill.Merge(s.spill)
+	if err != nil && s.parent.spillerr == nil {
+		s.parent.spillerr = err
+	}
+	s.parent.recordsLock.Unlock()
+	s.parent.wg.Done()
+	return err
+}
+
+// ----------------------------------------------------------------------
+
 // bytesAllocator allocates smaller chunks of arbitrary byte
 // from a pre-allocated block of memory.
 //
@@ -17,6 +17,7 @@ package vm
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"strings"
@@ -533,6 +534,106 @@ func limitTestIon(rowsCount int) (result []byte, err error) {
 
 // --------------------------------------------------
 
+func TestSortSpill(t *testing.T) {
+	t.Run("symtab-per-chunk", func(t *testing.T) {
+		testSortSpill(t, false)
+	})
+	t.Run("shared-symtab", func(t *testing.T) {
+		testSortSpill(t, true)
+	})
+}
+
+func testSortSpill(t *testing.T, shared bool) {
+	const (
+		chunks      = 8
+		rows        = 2000
+		align       = 64 * 1024
+		parallelism = 4
+	)
+
+	// unless shared is set, every chunk has
+	// a symbol table of its own; the keys are
+	// spread across all of the chunks
+	ints := rand.Perm(chunks * rows)
+	input := make([]byte, chunks*align)
+	for i := 0; i < chunks; i++ {
+		var buf ion.Buffer
+		var st ion.Symtab
+		if !shared {
+			st.Intern(fmt.Sprintf("chunk%d", i))
+		}
+		keySym := st.Intern("key")
+		tagSym := st.Intern("tag")
+		buf.StartChunk(&st)
+		for _, n := range ints[i*rows : (i+1)*rows] {
+			buf.BeginStruct(-1)
+			buf.BeginField(keySym)
+			buf.WriteInt(int64(n))
+			buf.BeginField(tagSym)
+			buf.WriteString(fmt.Sprintf("tag%d", n%3))
+			buf.EndStruct()
+		}
+		if buf.Size() > align {
+			t.Fatalf("chunk of %d bytes exceeds %d", buf.Size(), align)
+		}
+		copy(input[i*align:], buf.Bytes())
+		noppad(input[i*align+buf.Size() : (i+1)*align])
+	}
+
+	orderBy := []SortColumn{SortColumn{Node: parsePath("key"),
+		Direction: sorting.Descending,
+		Nulls:     sorting.NullsFirst}}
+
+	// small enough that every thread
+	// writes several runs to disk
+	const maxmem = 32 * 1024
+
+	output := new(bytes.Buffer)
+	sorter := NewSpillingOrder(output, orderBy, t.TempDir(), maxmem, parallelism)
+	err := CopyRows(sorter, BufferTable(input, align), parallelism)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sorter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var st ion.Symtab
+	var d ion.Datum
+	want := uint64(chunks*rows - 1)
+	for out := output.Bytes(); len(out) > 0; {
+		d, out, err = ion.ReadDatum(&st, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, ok := d.Struct()
+		if !ok {
+			t.Fatalf("unexpected datum %v", d)
+		}
+		fields := s.Fields(nil)
+		if len(fields) != 2 || fields[0].Label != "key" || fields[1].Label != "tag" {
+			t.Fatalf("unexpected fields %v", fields)
+		}
+		key, _ := fields[0].Value.Uint()
+		tag, _ := fields[1].Value.String()
+		if key != want || tag != fmt.Sprintf("tag%d", want%3) {
+			t.Fatalf("got key %d tag %q, want key %d", key, tag, want)
+		}
+		want--
+	}
+	if want != math.MaxUint64 {
+		t.Fatalf("%d rows missing from the output", want+1)
+	}
+	// each thread captures a symbol table
+	// once as long as they are compatible
+	if shared && len(sorter.symtabs) > parallelism {
+		t.Errorf("captured %d symbol tables", len(sorter.symtabs))
+	}
+}
+
+// --------------------------------------------------
+
 func parseIonRecords(bytes []byte) (result []string, err error) {
 	// we assume id and key fields, where id is always int
 	var st ion.Symtab
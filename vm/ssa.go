@@ -24,6 +24,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/exp/slices"
 	"golang.org/x/sys/cpu"
@@ -84,6 +85,7 @@ const (
 	stostr
 	stolist
 	stotime
+	stoblob
 	sunsymbolize
 
 	scvtktoi // bool to 0 or 1
@@ -128,6 +130,9 @@ const (
 	sStrContainsSubstrCi     // String contains substring case-insensitive
 
 	sIsSubnetOfIP4 // IP subnet matching
+	sIsSubnetOfIP6 // IPv6 subnet matching
+	sInetParse     // INET_PARSE and INET_TRUNC
+	sInetFamily    // INET_FAMILY
 
 	sStrSkip1CharLeft  // String skip 1 unicode code-point from left
 	sStrSkip1CharRight // String skip 1 unicode code-point from right
@@ -137,6 +142,13 @@ const (
 	sCharLength // count number of unicode-points
 	sSubStr     // select a substring
 	sSplitPart  // Presto split_part
+	sStrPos     // STRPOS
+	sStrReplace // REPLACE
+	sStrReverse // REVERSE
+	sStrInitcap // INITCAP
+	sStrRepeat  // REPEAT
+	sStrLpad    // LPAD
+	sStrRpad    // RPAD
 
 	sDfaT6  // DFA tiny 6-bit
 	sDfaT7  // DFA tiny 7-bit
@@ -147,6 +159,10 @@ const (
 	sDfaL   // DFA large
 	sDfaLZ  // DFA large Zero remaining length assertion
 
+	sRegexpExtract // REGEXP_EXTRACT
+	sRegexpCount   // REGEXP_COUNT
+	sRegexpReplace // REGEXP_REPLACE
+
 	// immediate integer comparison ops
 	scmpltimmi // arg0.mask < consti
 	scmpgtimmi // arg0.mask > consti
@@ -319,6 +335,14 @@ const (
 	saggori
 	saggxori
 	saggcount
+	saggapproxcount
+	saggapproxcountmerge
+	saggapproxpercentile
+	saggapproxpercentilemerge
+	saggmoments
+	saggmomentsmerge
+	saggarray
+	saggarraymerge
 
 	saggbucket
 	saggslotandk
@@ -337,6 +361,14 @@ const (
 	saggslotori
 	saggslotxori
 	saggslotcount
+	saggslotapproxcount
+	saggslotapproxcountmerge
+	saggslotapproxpercentile
+	saggslotapproxpercentilemerge
+	saggslotmoments
+	saggslotmomentsmerge
+	saggslotarray
+	saggslotarraymerge
 
 	scmplttm
 	scmpgttm
@@ -362,6 +394,7 @@ const (
 	sdateextractday
 	sdateextractmonth
 	sdateextractyear
+	sdateextractdow
 	sdatetounixepoch
 	sdatetounixmicro
 	sdatetruncmillisecond
@@ -371,6 +404,9 @@ const (
 	sdatetruncday
 	sdatetruncmonth
 	sdatetruncyear
+	sparsets  // PARSE_TIMESTAMP
+	sformatts // FORMAT_TIMESTAMP
+	sconvtz   // timestamp + UTC offset
 
 	sgeohash
 	sgeohashimm
@@ -382,6 +418,11 @@ const (
 
 	sobjectsize // built-in function SIZE()
 
+	sArrayPosition // ARRAY_POSITION and ARRAY_CONTAINS
+	sArraySlice    // ARRAY_SLICE
+	sArrayDistinct // ARRAY_DISTINCT
+	sArrayJoin     // ARRAY_JOIN
+
 	sboxmask   // box a mask
 	sboxint    // box an integer
 	sboxfloat  // box a float
@@ -508,6 +549,7 @@ var str2Args = []ssatype{stString, stString, stBool}
 var str3Args = []ssatype{stString, stString, stString, stBool}
 var str4Args = []ssatype{stString, stString, stString, stString, stBool}
 var time1Args = []ssatype{stTime, stBool}
+var list1Args = []ssatype{stList, stBool}
 
 var parseValueArgs = []ssatype{stScalar, stValue, stBool}
 
@@ -600,6 +642,7 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	stostr:  {text: "tostr", argtypes: scalar1Args, rettype: stStringMasked, bc: opunpack, emit: emitslice},
 	stolist: {text: "tolist", argtypes: scalar1Args, rettype: stListMasked, bc: opunpack, emit: emitslice},
 	stotime: {text: "totime", argtypes: scalar1Args, rettype: stTimeMasked, bc: opunpack, emit: emitslice},
+	stoblob: {text: "toblob", argtypes: scalar1Args, rettype: stStringMasked, bc: opunpack, emit: emitslice},
 
 	sunsymbolize: {text: "unsymbolize", argtypes: scalar1Args, rettype: stValue, bc: opunsymbolize},
 
@@ -658,6 +701,9 @@ var _ssainfo = [_ssamax]ssaopinfo{
 
 	// ip matching
 	sIsSubnetOfIP4: {text: "is_subnet_of_ip4", argtypes: str1Args, rettype: stBool, immfmt: fmtdict, bc: opIsSubnetOfIP4},
+	sIsSubnetOfIP6: {text: "is_subnet_of_ip6", argtypes: str1Args, rettype: stBool, immfmt: fmtdict, bc: opIsSubnetOfIP6},
+	sInetParse:     {text: "inetparse", argtypes: str1Args, rettype: stStringMasked, immfmt: fmtdict, bc: opinetparse, scratch: true},
+	sInetFamily:    {text: "inetfamily", argtypes: str1Args, rettype: stIntMasked, bc: opinetfamily},
 
 	// s, k = skip_1char_left s, k -- skip one unicode character at the beginning (left) of a string slice
 	sStrSkip1CharLeft: {text: "skip_1char_left", argtypes: str1Args, rettype: stStringMasked, bc: opSkip1charLeft},
@@ -671,6 +717,13 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	sCharLength: {text: "char_length", argtypes: str1Args, rettype: stIntMasked, bc: opLengthStr},
 	sSubStr:     {text: "substr", argtypes: []ssatype{stString, stInt, stInt, stBool}, rettype: stStringMasked, immfmt: fmtother, bc: opSubstr, emit: emitStrEditStack2},
 	sSplitPart:  {text: "split_part", argtypes: []ssatype{stString, stInt, stBool}, rettype: stStringMasked, immfmt: fmtother, bc: opSplitPart, emit: emitStrEditStack1x1},
+	sStrPos:     {text: "strpos", argtypes: str1Args, rettype: stIntMasked, immfmt: fmtdict, bc: opStrPos},
+	sStrReplace: {text: "strreplace", argtypes: str1Args, rettype: stStringMasked, immfmt: fmtdict, bc: opStrReplace, scratch: true},
+	sStrReverse: {text: "strreverse", argtypes: str1Args, rettype: stStringMasked, bc: opStrReverse, scratch: true},
+	sStrInitcap: {text: "strinitcap", argtypes: str1Args, rettype: stStringMasked, bc: opStrInitcap, scratch: true},
+	sStrRepeat:  {text: "strrepeat", argtypes: []ssatype{stString, stInt, stBool}, rettype: stStringMasked, bc: opStrRepeat, emit: emitStrEditStack1, scratch: true},
+	sStrLpad:    {text: "strlpad", argtypes: []ssatype{stString, stInt, stBool}, rettype: stStringMasked, immfmt: fmtother, bc: opStrLpad, emit: emitStrEditStack1x1, scratch: true},
+	sStrRpad:    {text: "strrpad", argtypes: []ssatype{stString, stInt, stBool}, rettype: stStringMasked, immfmt: fmtother, bc: opStrRpad, emit: emitStrEditStack1x1, scratch: true},
 
 	sDfaT6:  {text: "dfa_tiny6", argtypes: str1Args, rettype: stBool, immfmt: fmtdict, bc: opDfaT6},
 	sDfaT7:  {text: "dfa_tiny7", argtypes: str1Args, rettype: stBool, immfmt: fmtdict, bc: opDfaT7},
@@ -681,6 +734,10 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	sDfaL:   {text: "dfa_large", argtypes: str1Args, rettype: stBool, immfmt: fmtdict, bc: opDfaL},
 	sDfaLZ:  {text: "dfa_large_flags", argtypes: str1Args, rettype: stBool, immfmt: fmtdict, bc: opDfaLZ},
 
+	sRegexpExtract: {text: "regexp_extract", argtypes: str1Args, rettype: stStringMasked, immfmt: fmtdict, bc: opregexpextract},
+	sRegexpCount:   {text: "regexp_count", argtypes: str1Args, rettype: stIntMasked, immfmt: fmtdict, bc: opregexpcount},
+	sRegexpReplace: {text: "regexp_replace", argtypes: str1Args, rettype: stStringMasked, immfmt: fmtdict, bc: opregexpreplace, scratch: true},
+
 	// compare against a constant exactly
 	sequalconst: {text: "equalconst", argtypes: scalar1Args, rettype: stBool, immfmt: fmtother, emit: emitconstcmp},
 
@@ -851,6 +908,30 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	saggxori:  {text: "aggxor.i", rettype: stMem, argtypes: []ssatype{stMem, stInt, stBool}, immfmt: fmtslot, bc: opaggxori, priority: prioMem},
 	saggcount: {text: "aggcount", rettype: stMem, argtypes: []ssatype{stMem, stBool}, immfmt: fmtslot, bc: opaggcount, priority: prioMem + 1},
 
+	// approximate COUNT(DISTINCT ...) ops; aggapproxcount updates
+	// the HyperLogLog registers with a hash, and aggapproxcountmerge
+	// merges a blob of registers produced by a partial aggregate
+	saggapproxcount:      {text: "aggapproxcount", rettype: stMem, argtypes: []ssatype{stMem, stHash, stBool}, immfmt: fmtother, bc: opaggapproxcount, priority: prioMem, emit: emitaggapproxcount},
+	saggapproxcountmerge: {text: "aggapproxcountmerge", rettype: stMem, argtypes: []ssatype{stMem, stString, stBool}, immfmt: fmtslot, bc: opaggapproxcountmerge, priority: prioMem},
+
+	// approximate percentile ops; aggapproxpercentile adds a value
+	// to the histogram sketch, and aggapproxpercentilemerge merges
+	// a blob containing a sketch produced by a partial aggregate
+	saggapproxpercentile:      {text: "aggapproxpercentile", rettype: stMem, argtypes: []ssatype{stMem, stFloat, stBool}, immfmt: fmtslot, bc: opaggapproxpercentile, priority: prioMem},
+	saggapproxpercentilemerge: {text: "aggapproxpercentilemerge", rettype: stMem, argtypes: []ssatype{stMem, stString, stBool}, immfmt: fmtslot, bc: opaggapproxpercentilemerge, priority: prioMem},
+
+	// statistical aggregate ops; aggmoments adds an (x, y) pair
+	// to the count, means, and (co-)moments, and aggmomentsmerge
+	// merges a blob containing the state produced by a partial aggregate
+	saggmoments:      {text: "aggmoments", rettype: stMem, argtypes: []ssatype{stMem, stFloat, stFloat, stBool}, immfmt: fmtother, bc: opaggmoments, priority: prioMem, emit: emitaggmoments},
+	saggmomentsmerge: {text: "aggmomentsmerge", rettype: stMem, argtypes: []ssatype{stMem, stString, stBool}, immfmt: fmtslot, bc: opaggmomentsmerge, priority: prioMem},
+
+	// ARRAY_AGG ops; aggarray adds a value and its ordering key
+	// to the bounded list of values, and aggarraymerge merges
+	// a blob containing the list produced by a partial aggregate
+	saggarray:      {text: "aggarray", rettype: stMem, argtypes: []ssatype{stMem, stValue, stFloat, stBool}, immfmt: fmtother, bc: opaggarray, priority: prioMem, emit: emitaggarray},
+	saggarraymerge: {text: "aggarraymerge", rettype: stMem, argtypes: []ssatype{stMem, stString, stBool}, immfmt: fmtslot, bc: opaggarraymerge, priority: prioMem},
+
 	// compute hash aggregate bucket location; encoded immediate will be input hash slot to use
 	saggbucket: {text: "aggbucket", argtypes: []ssatype{stMem, stHash, stBool}, rettype: stBucket, immfmt: fmtslot, bc: opaggbucket},
 
@@ -872,6 +953,18 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	saggslotxori:  {text: "aggslotxor.i", argtypes: []ssatype{stMem, stBucket, stInt, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotxori, priority: prioMem},
 	saggslotcount: {text: "aggslotcount", argtypes: []ssatype{stMem, stBucket, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotcount, priority: prioMem},
 
+	saggslotapproxcount:      {text: "aggslotapproxcount", argtypes: []ssatype{stMem, stBucket, stHash, stBool}, rettype: stMem, immfmt: fmtother, bc: opaggslotapproxcount, priority: prioMem, emit: emitaggapproxcount},
+	saggslotapproxcountmerge: {text: "aggslotapproxcountmerge", argtypes: []ssatype{stMem, stBucket, stString, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotapproxcountmerge, priority: prioMem},
+
+	saggslotapproxpercentile:      {text: "aggslotapproxpercentile", argtypes: []ssatype{stMem, stBucket, stFloat, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotapproxpercentile, priority: prioMem},
+	saggslotapproxpercentilemerge: {text: "aggslotapproxpercentilemerge", argtypes: []ssatype{stMem, stBucket, stString, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotapproxpercentilemerge, priority: prioMem},
+
+	saggslotmoments:      {text: "aggslotmoments", argtypes: []ssatype{stMem, stBucket, stFloat, stFloat, stBool}, rettype: stMem, immfmt: fmtother, bc: opaggslotmoments, priority: prioMem, emit: emitaggmoments},
+	saggslotmomentsmerge: {text: "aggslotmomentsmerge", argtypes: []ssatype{stMem, stBucket, stString, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotmomentsmerge, priority: prioMem},
+
+	saggslotarray:      {text: "aggslotarray", argtypes: []ssatype{stMem, stBucket, stValue, stFloat, stBool}, rettype: stMem, immfmt: fmtother, bc: opaggslotarray, priority: prioMem, emit: emitaggarray},
+	saggslotarraymerge: {text: "aggslotarraymerge", argtypes: []ssatype{stMem, stBucket, stString, stBool}, rettype: stMem, immfmt: fmtslot, bc: opaggslotarraymerge, priority: prioMem},
+
 	// boxing ops
 	//
 	// turn two masks into TRUE/FALSE/MISSING according to 3VL
@@ -903,6 +996,7 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	sdateextractday:         {text: "dateextractday", rettype: stInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdateextractday, emit: emitdateextract},
 	sdateextractmonth:       {text: "dateextractmonth", rettype: stInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdateextractmonth, emit: emitdateextract},
 	sdateextractyear:        {text: "dateextractyear", rettype: stInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdateextractyear, emit: emitdateextract},
+	sdateextractdow:         {text: "dateextractdow", rettype: stInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdateextractdow, emit: emitdateextract},
 	sdatetounixepoch:        {text: "datetounixepoch", rettype: stInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdatetounixepoch, emit: emitdateextract},
 	sdatetounixmicro:        {text: "datetounixmicro", rettype: stInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdatetounixepoch, emit: emitdatecasttoint},
 	sdatetruncmillisecond:   {text: "datetruncmillisecond", rettype: stTimeInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdatetruncmillisecond},
@@ -914,6 +1008,9 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	sdatetruncyear:          {text: "datetruncyear", rettype: stTimeInt, argtypes: []ssatype{stTimeInt, stBool}, bc: opdatetruncyear},
 	stimebucketts:           {text: "timebucket.ts", rettype: stInt, argtypes: []ssatype{stInt, stInt, stBool}, bc: optimebucketts, emit: emitBinaryOp},
 	sboxts:                  {text: "boxts", argtypes: []ssatype{stTimeInt, stBool}, rettype: stValue, bc: opboxts, scratch: true},
+	sparsets:                {text: "parsets", argtypes: str1Args, rettype: stTimeIntMasked, immfmt: fmtdict, bc: opparsets},
+	sformatts:               {text: "formatts", argtypes: []ssatype{stTimeInt, stBool}, rettype: stStringMasked, immfmt: fmtdict, bc: opformatts, scratch: true},
+	sconvtz:                 {text: "convtz", argtypes: []ssatype{stTimeInt, stBool}, rettype: stTimeInt, immfmt: fmtdict, bc: opconvtz},
 
 	// GEO functions
 	sgeohash:      {text: "geohash", rettype: stStringMasked, argtypes: []ssatype{stFloat, stFloat, stInt, stBool}, bc: opgeohash, emit: emitGeoHash},
@@ -927,6 +1024,11 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	schecktag: {text: "checktag", argtypes: []ssatype{stValue, stBool}, rettype: stValueMasked, immfmt: fmtother, emit: emitchecktag},
 
 	sobjectsize: {text: "objectsize", argtypes: []ssatype{stValue, stBool}, rettype: stIntMasked, bc: opobjectsize},
+
+	sArrayPosition: {text: "arrayposition", argtypes: list1Args, rettype: stIntMasked, immfmt: fmtdict, bc: oparrayposition},
+	sArraySlice:    {text: "arrayslice", argtypes: list1Args, rettype: stValueMasked, immfmt: fmtdict, bc: oparrayslice, scratch: true},
+	sArrayDistinct: {text: "arraydistinct", argtypes: list1Args, rettype: stValueMasked, bc: oparraydistinct, scratch: true},
+	sArrayJoin:     {text: "arrayjoin", argtypes: list1Args, rettype: stStringMasked, immfmt: fmtdict, bc: oparrayjoin, scratch: true},
 }
 
 type value struct {
@@ -959,6 +1061,12 @@ type prog struct {
 
 	reserved []stackslot
 
+	// bound maps variable names to the values
+	// they are bound to; paths starting with
+	// one of these names do not refer to
+	// fields of the input row (see Exists)
+	bound map[string]*value
+
 	// symbolized records whether
 	// the program has been symbolized
 	symbolized bool
@@ -1078,6 +1186,7 @@ func (p *prog) Begin() {
 	p.values = nil
 	p.ret = nil
 	p.dict = nil
+	p.bound = nil
 
 	// op 0 is always 'init'
 	v := p.val()
@@ -1890,6 +1999,109 @@ func (p *prog) SplitPart(v *value, delimiter byte, index *value) *value {
 	return p.ssa3imm(sSplitPart, v, indexInt, mask, delimiterStr)
 }
 
+// StrPos returns the 1-based position (in code-points) of the
+// first occurrence of needle in str, or 0 if there is none
+func (p *prog) StrPos(str *value, needle string) *value {
+	str = p.toStr(str)
+	return p.ssa2imm(sStrPos, str, p.mask(str), p.Constant(needle).imm)
+}
+
+// StrReplace replaces every non-overlapping occurrence of from in str with to
+func (p *prog) StrReplace(str *value, from, to string) *value {
+	str = p.toStr(str)
+	if from == "" {
+		return str
+	}
+	// the immediate is the length of from followed by from and to
+	enc := binary.LittleEndian.AppendUint32(nil, uint32(len(from)))
+	enc = append(enc, from...)
+	enc = append(enc, to...)
+	return p.ssa2imm(sStrReplace, str, p.mask(str), p.Constant(string(enc)).imm)
+}
+
+// StrReverse reverses the code-points of str
+func (p *prog) StrReverse(str *value) *value {
+	str = p.toStr(str)
+	return p.ssa2(sStrReverse, str, p.mask(str))
+}
+
+// StrInitcap upper-cases the first ASCII letter of
+// each word in str and lower-cases the other ASCII letters
+func (p *prog) StrInitcap(str *value) *value {
+	str = p.toStr(str)
+	return p.ssa2(sStrInitcap, str, p.mask(str))
+}
+
+// StrRepeat concatenates count copies of str
+func (p *prog) StrRepeat(str, count *value) *value {
+	str = p.toStr(str)
+	countInt, countMask := p.coerceInt(count)
+	return p.ssa3(sStrRepeat, str, countInt, p.And(p.mask(str), countMask))
+}
+
+// StrPad pads str to length code-points by prepending (left)
+// or appending copies of fill, or truncates str to length
+// code-points if it is longer than that
+func (p *prog) StrPad(str, length *value, fill string, left bool) *value {
+	str = p.toStr(str)
+	lengthInt, lengthMask := p.coerceInt(length)
+	op := sStrRpad
+	if left {
+		op = sStrLpad
+	}
+	return p.ssa3imm(op, str, lengthInt, p.And(p.mask(str), lengthMask), strPadImm(fill))
+}
+
+// strPadImm encodes the fill string of LPAD and RPAD as
+//
+//	[0:4]   the number of code-points n (uint32)
+//	[4:...] n+1 byte offsets of the code-points (uint32),
+//	        the last of which is the length of fill
+//	[...]   fill
+func strPadImm(fill string) string {
+	n := utf8.RuneCountInString(fill)
+	enc := binary.LittleEndian.AppendUint32(nil, uint32(n))
+	for i := range fill {
+		enc = binary.LittleEndian.AppendUint32(enc, uint32(i))
+	}
+	enc = binary.LittleEndian.AppendUint32(enc, uint32(len(fill)))
+	enc = append(enc, fill...)
+	return string(enc)
+}
+
+// StrLeft returns the first n code-points of str,
+// or all but the last -n code-points if n is negative
+func (p *prog) StrLeft(str, n *value) *value {
+	str = p.toStr(str)
+	skip, mask := p.strSkipCount(str, n)
+	return p.ssa3(sStrSkipNCharRight, str, skip, mask)
+}
+
+// StrRight returns the last n code-points of str,
+// or all but the first -n code-points if n is negative
+func (p *prog) StrRight(str, n *value) *value {
+	str = p.toStr(str)
+	skip, mask := p.strSkipCount(str, n)
+	return p.ssa3(sStrSkipNCharLeft, str, skip, mask)
+}
+
+// strSkipCount returns the number of code-points
+// that LEFT and RIGHT remove from str, which is
+//
+//	n >= 0: max(CHAR_LENGTH(str)-n, 0)
+//	n < 0:  min(-n, CHAR_LENGTH(str))
+//
+// along with the lanes in which it is valid
+func (p *prog) strSkipCount(str, n *value) (*value, *value) {
+	length := p.CharLength(str)
+	nInt, nMask := p.coerceInt(n)
+	zero := p.Constant(int64(0))
+	pos := p.MaxValue(p.Sub(length, nInt), zero)
+	neg := p.MinValue(p.Neg(nInt), length)
+	mask := p.And(p.mask(length), nMask)
+	return p.ssa3(sblendint, pos, neg, p.And(p.Less(nInt, zero), mask)), mask
+}
+
 // is v an ion null value?
 func (p *prog) isnull(v *value) *value {
 	if v.primary() != stValue {
@@ -2155,6 +2367,17 @@ func (p *prog) toStr(str *value) *value {
 	}
 }
 
+func (p *prog) toBlob(v *value) *value {
+	switch v.primary() {
+	case stValue:
+		return p.ssa2(stoblob, v, p.mask(v))
+	default:
+		r := p.val()
+		r.errf("internal error: unsupported value %v", v.String())
+		return r
+	}
+}
+
 func (p *prog) Concat(args ...*value) *value {
 	if len(args) == 0 {
 		panic("CONCAT cannot be empty")
@@ -3145,6 +3368,14 @@ func (p *prog) DateAdd(part expr.Timepart, arg0, arg1 *value) *value {
 		if part == expr.Year {
 			return p.ssa2imm(sdateaddmonthimm, arg1Time, arg1Mask, i64Imm*12)
 		}
+
+		if part == expr.Quarter {
+			return p.ssa2imm(sdateaddmonthimm, arg1Time, arg1Mask, i64Imm*3)
+		}
+
+		if part == expr.Week {
+			return p.ssa2imm(sdateaddimm, arg1Time, arg1Mask, i64Imm*microsecondsPerWeek)
+		}
 	} else {
 		arg0Int, arg0Mask := p.coerceInt(arg0)
 
@@ -3165,6 +3396,15 @@ func (p *prog) DateAdd(part expr.Timepart, arg0, arg1 *value) *value {
 		if part == expr.Year {
 			return p.ssa3(sdateaddyear, arg1Time, arg0Int, p.And(arg1Mask, arg0Mask))
 		}
+
+		if part == expr.Quarter {
+			months := p.ssa2imm(smulimmi, arg0Int, arg0Mask, 3)
+			return p.ssa3(sdateaddmonth, arg1Time, months, p.And(arg1Mask, arg0Mask))
+		}
+
+		if part == expr.Week {
+			return p.ssa3imm(sdateaddmulimm, arg1Time, arg0Int, p.And(arg1Mask, arg0Mask), uint64(microsecondsPerWeek))
+		}
 	}
 
 	return p.errorf("unhandled date part in DateAdd()")
@@ -3191,6 +3431,15 @@ func (p *prog) DateDiff(part expr.Timepart, arg0, arg1 *value) *value {
 		return p.ssa3(sdatediffyear, t0, t1, p.And(m0, m1))
 	}
 
+	if part == expr.Quarter {
+		mask := p.And(m0, m1)
+		return p.ssa2imm(sdivimmi, p.ssa3(sdatediffmonth, t0, t1, mask), mask, 3)
+	}
+
+	if part == expr.Week {
+		return p.ssa3imm(sdatediffparam, t0, t1, p.And(m0, m1), uint64(microsecondsPerWeek))
+	}
+
 	return p.errorf("unhandled date part in DateDiff()")
 }
 
@@ -3214,7 +3463,7 @@ func immediateForBoxedDateInstruction(part expr.Timepart) int {
 }
 
 func (p *prog) DateExtract(part expr.Timepart, val *value) *value {
-	if val.primary() == stTimeInt || part < expr.Second {
+	if val.primary() == stTimeInt || part < expr.Second || part > expr.Year {
 		v, m := p.coerceTimestamp(val)
 		switch part {
 		case expr.Microsecond:
@@ -3233,6 +3482,25 @@ func (p *prog) DateExtract(part expr.Timepart, val *value) *value {
 			return p.ssa2(sdateextractmonth, v, m)
 		case expr.Year:
 			return p.ssa2(sdateextractyear, v, m)
+		case expr.Quarter:
+			month := p.ssa2(sdateextractmonth, v, m)
+			return p.ssa2imm(sdivimmi, p.ssa2imm(saddimmi, month, m, 2), m, 3)
+		case expr.Week:
+			// the number of Sundays from January 1st up to v
+			yday := p.ssa3imm(sdatediffparam, p.ssa2(sdatetruncyear, v, m), v, m, timePartMultiplier[expr.Day])
+			days := p.ssa2imm(saddimmi, p.ssa3(ssubi, yday, p.ssa2(sdateextractdow, v, m), m), m, 7)
+			return p.ssa2imm(sdivimmi, days, m, 7)
+		case expr.ISOWeek:
+			thu := p.isoThursday(v, m)
+			weeks := p.ssa3imm(sdatediffparam, p.ssa2(sdatetruncyear, thu, m), thu, m, uint64(microsecondsPerWeek))
+			return p.ssa2imm(saddimmi, weeks, m, 1)
+		case expr.ISOYear:
+			return p.ssa2(sdateextractyear, p.isoThursday(v, m), m)
+		case expr.DOW:
+			return p.ssa2(sdateextractdow, v, m)
+		case expr.DOY:
+			yday := p.ssa3imm(sdatediffparam, p.ssa2(sdatetruncyear, v, m), v, m, timePartMultiplier[expr.Day])
+			return p.ssa2imm(saddimmi, yday, m, 1)
 		default:
 			return p.errorf("unhandled date part in DateExtract()")
 		}
@@ -3273,11 +3541,44 @@ func (p *prog) DateTrunc(part expr.Timepart, val *value) *value {
 		return p.ssa2(sdatetruncmonth, v, m)
 	case expr.Year:
 		return p.ssa2(sdatetruncyear, v, m)
+	case expr.Quarter:
+		// subtract (month - 1) % 3 months
+		month := p.ssa2(sdateextractmonth, v, m)
+		months := p.ssa2imm(smodimmi, p.ssa2imm(saddimmi, month, m, -1), m, 3)
+		return p.ssa3(sdateaddmonth, p.ssa2(sdatetruncmonth, v, m), p.ssa2(snegi, months, m), m)
+	case expr.Week:
+		return p.weekStart(v, m, 0)
+	case expr.ISOWeek:
+		return p.weekStart(v, m, 1)
+	case expr.ISOYear:
+		// the first ISO week contains January 4th
+		jan4 := p.ssa2imm(sdateaddimm, p.ssa2(sdatetruncyear, p.isoThursday(v, m), m), m, 3*timePartMultiplier[expr.Day])
+		return p.weekStart(jan4, m, 1)
 	default:
 		return p.errorf("unhandled date part in DateTrunc()")
 	}
 }
 
+const microsecondsPerWeek = 7 * 24 * 60 * 60 * 1000000
+
+// weekStart returns the start of the week
+// containing v for weeks that start on the
+// given day of the week (0 is Sunday)
+func (p *prog) weekStart(v, m *value, first int) *value {
+	day := p.ssa2(sdatetruncday, v, m)
+	dow := p.ssa2(sdateextractdow, v, m)
+	if first != 0 {
+		dow = p.ssa2imm(smodimmi, p.ssa2imm(saddimmi, dow, m, 7-first), m, 7)
+	}
+	return p.ssa3imm(sdateaddmulimm, day, p.ssa2(snegi, dow, m), m, timePartMultiplier[expr.Day])
+}
+
+// isoThursday returns the Thursday of the ISO
+// week containing v, which determines the ISO year
+func (p *prog) isoThursday(v, m *value) *value {
+	return p.ssa2imm(sdateaddimm, p.weekStart(v, m, 1), m, 3*timePartMultiplier[expr.Day])
+}
+
 func (p *prog) TimeBucket(timestamp, interval *value) *value {
 	tv := p.DateToUnixEpoch(timestamp)
 	iv, im := p.coerceInt(interval)
@@ -3600,6 +3901,99 @@ func (p *prog) AggregateLatest(child, filter *value, slot int) *value {
 	return p.makeTimeAggregateOp(saggmaxts, child, filter, slot)
 }
 
+// AggregateApproxCount updates the HyperLogLog
+// registers at slot with the hash of child
+func (p *prog) AggregateApproxCount(child, filter *value, slot int) *value {
+	h := p.hash(child)
+	mask := p.mask(child)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa3imm(saggapproxcount, p.InitMem(), h, mask, slot)
+}
+
+// AggregateApproxCountMerge merges the HyperLogLog
+// registers stored in the blob child into the
+// registers at slot
+func (p *prog) AggregateApproxCountMerge(child, filter *value, slot int) *value {
+	blob := p.toBlob(child)
+	mask := p.mask(blob)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa3imm(saggapproxcountmerge, p.InitMem(), blob, mask, slot)
+}
+
+// AggregateApproxPercentile adds the numeric
+// value of child to the percentile sketch at slot
+func (p *prog) AggregateApproxPercentile(child, filter *value, slot int) *value {
+	scalar, mask := p.coercefp(child)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa3imm(saggapproxpercentile, p.InitMem(), scalar, mask, slot)
+}
+
+// AggregateApproxPercentileMerge merges the
+// percentile sketch stored in the blob child
+// into the sketch at slot
+func (p *prog) AggregateApproxPercentileMerge(child, filter *value, slot int) *value {
+	blob := p.toBlob(child)
+	mask := p.mask(blob)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa3imm(saggapproxpercentilemerge, p.InitMem(), blob, mask, slot)
+}
+
+// AggregateMoments adds the numeric values
+// of x and y to the statistical aggregate
+// state at slot
+func (p *prog) AggregateMoments(x, y, filter *value, slot int) *value {
+	xs, xk := p.coercefp(x)
+	ys, yk := p.coercefp(y)
+	mask := p.And(xk, yk)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa4imm(saggmoments, p.InitMem(), xs, ys, mask, slot)
+}
+
+// AggregateMomentsMerge merges the statistical
+// aggregate state stored in the blob child
+// into the state at slot
+func (p *prog) AggregateMomentsMerge(child, filter *value, slot int) *value {
+	blob := p.toBlob(child)
+	mask := p.mask(blob)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa3imm(saggmomentsmerge, p.InitMem(), blob, mask, slot)
+}
+
+// AggregateArray adds the value val with
+// the ordering key key to the ARRAY_AGG
+// state at slot
+func (p *prog) AggregateArray(val, key, filter *value, slot int) *value {
+	mask := p.mask(val)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa4imm(saggarray, p.InitMem(), val, key, mask, slot)
+}
+
+// AggregateArrayMerge merges the ARRAY_AGG
+// state stored in the blob child into the
+// state at slot
+func (p *prog) AggregateArrayMerge(child, filter *value, slot int) *value {
+	blob := p.toBlob(child)
+	mask := p.mask(blob)
+	if filter != nil {
+		mask = p.And(mask, filter)
+	}
+	return p.ssa3imm(saggarraymerge, p.InitMem(), blob, mask, slot)
+}
+
 func (p *prog) AggregateCount(child, filter *value, slot int) *value {
 	mask := p.notMissing(child)
 	if filter != nil {
@@ -3700,6 +4094,77 @@ func (p *prog) AggregateSlotCount(mem, bucket, mask *value, offset int) *value {
 	return p.ssa3imm(saggslotcount, mem, bucket, mask, offset)
 }
 
+func (p *prog) AggregateSlotApproxCount(mem, bucket, value, mask *value, offset int) *value {
+	h := p.hash(value)
+	m := p.mask(value)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssa4imm(saggslotapproxcount, mem, bucket, h, m, offset)
+}
+
+func (p *prog) AggregateSlotApproxCountMerge(mem, bucket, value, mask *value, offset int) *value {
+	blob := p.toBlob(value)
+	m := p.mask(blob)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssa4imm(saggslotapproxcountmerge, mem, bucket, blob, m, offset)
+}
+
+func (p *prog) AggregateSlotApproxPercentile(mem, bucket, value, mask *value, offset int) *value {
+	scalar, m := p.coercefp(value)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssa4imm(saggslotapproxpercentile, mem, bucket, scalar, m, offset)
+}
+
+func (p *prog) AggregateSlotApproxPercentileMerge(mem, bucket, value, mask *value, offset int) *value {
+	blob := p.toBlob(value)
+	m := p.mask(blob)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssa4imm(saggslotapproxpercentilemerge, mem, bucket, blob, m, offset)
+}
+
+func (p *prog) AggregateSlotMoments(mem, bucket, x, y, mask *value, offset int) *value {
+	xs, xk := p.coercefp(x)
+	ys, yk := p.coercefp(y)
+	m := p.And(xk, yk)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssaimm(saggslotmoments, offset, mem, bucket, xs, ys, m)
+}
+
+func (p *prog) AggregateSlotMomentsMerge(mem, bucket, value, mask *value, offset int) *value {
+	blob := p.toBlob(value)
+	m := p.mask(blob)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssa4imm(saggslotmomentsmerge, mem, bucket, blob, m, offset)
+}
+
+func (p *prog) AggregateSlotArray(mem, bucket, val, key, mask *value, offset int) *value {
+	m := p.mask(val)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssaimm(saggslotarray, offset, mem, bucket, val, key, m)
+}
+
+func (p *prog) AggregateSlotArrayMerge(mem, bucket, value, mask *value, offset int) *value {
+	blob := p.toBlob(value)
+	m := p.mask(blob)
+	if mask != nil {
+		m = p.And(m, mask)
+	}
+	return p.ssa4imm(saggslotarraymerge, mem, bucket, blob, m, offset)
+}
+
 // note: the 'mem' argument to aggbucket
 // is for ordering the store(s) that write
 // out the names of the fields being aggregated against
@@ -4142,7 +4607,9 @@ func (t tree) number(v *value, n int) int {
 func (p *prog) articulation(v *value, tr tree) (*value, *value) {
 	parent := v
 	child := parent.maskarg()
-	for child.op != sinit && tr.postdom(v, child) {
+	// values loaded from stack slots
+	// have no mask argument
+	for child != nil && child.op != sinit && tr.postdom(v, child) {
 		// if we find an OR, we have to
 		// postdominate both sides
 		if child.op == sor {
@@ -4203,7 +4670,7 @@ func (p *prog) androtate(v, left, right *value, tr tree, pi *proginfo) *value {
 			return left
 		}
 		if !p.depends(pi, right, lparent) {
-			if lchild.op == sinit || lchild.op == stuples {
+			if lchild != nil && (lchild.op == sinit || lchild.op == stuples) {
 				lparent.setmask(right)
 				return left
 			}
@@ -4217,7 +4684,7 @@ func (p *prog) androtate(v, left, right *value, tr tree, pi *proginfo) *value {
 			return right
 		}
 		if !p.depends(pi, left, rparent) {
-			if rchild.op == sinit || rchild.op == stuples {
+			if rchild != nil && (rchild.op == sinit || rchild.op == stuples) {
 				rparent.setmask(left)
 				return right
 			}
@@ -5366,6 +5833,57 @@ func emithashlookup(v *value, c *compilestate) {
 	c.ops16u16(v, ssainfo[v.op].bc, hSlot, tslot)
 }
 
+// emitaggapproxcount emits aggapproxcount and
+// aggslotapproxcount, which take the input hash
+// slot as their first immediate
+func emitaggapproxcount(v *value, c *compilestate) {
+	h := v.args[len(v.args)-2]
+	k := v.args[len(v.args)-1]
+	if h.op == skfalse || k.op == skfalse {
+		// the input is never present
+		// (e.g. the field is not in the symbol table),
+		// so there is nothing to aggregate
+		return
+	}
+	hSlot := c.existingStackRef(h, regH)
+	c.loadk(v, k)
+	c.ops16s16(v, ssainfo[v.op].bc, hSlot, stackslot(v.imm.(int)))
+}
+
+// emitaggmoments emits aggmoments and
+// aggslotmoments, which take x in the
+// scalar register and y from a stack slot
+func emitaggmoments(v *value, c *compilestate) {
+	x := v.args[len(v.args)-3]
+	y := v.args[len(v.args)-2]
+	k := v.args[len(v.args)-1]
+	if k.op == skfalse {
+		// there are never any (x, y) pairs to aggregate
+		return
+	}
+	ySlot := c.forceStackRef(y, regS)
+	c.loadk(v, k)
+	c.loads(v, x)
+	c.ops16s16(v, ssainfo[v.op].bc, ySlot, stackslot(v.imm.(int)))
+}
+
+// emitaggarray emits aggarray and
+// aggslotarray, which take the value in the
+// value register and the key from a stack slot
+func emitaggarray(v *value, c *compilestate) {
+	val := v.args[len(v.args)-3]
+	key := v.args[len(v.args)-2]
+	k := v.args[len(v.args)-1]
+	if k.op == skfalse {
+		// there are never any values to aggregate
+		return
+	}
+	keySlot := c.forceStackRef(key, regS)
+	c.loadk(v, k)
+	c.loadv(v, val)
+	c.ops16s16(v, ssainfo[v.op].bc, keySlot, stackslot(v.imm.(int)))
+}
+
 func emithashmember(v *value, c *compilestate) {
 	h := v.args[0]
 	k := v.args[1]
@@ -5451,6 +5969,8 @@ func emitslice(v *value, c *compilestate) {
 		bits = 0x0b
 	case stotime:
 		bits = 0x06
+	case stoblob:
+		bits = 0x0a
 	default:
 		panic("unrecognized op for emitslice")
 	}
@@ -5561,6 +6081,7 @@ func emitStrEditStack1(v *value, c *compilestate) {
 	c.loadk(v, mask)
 	c.loads(v, str)
 	c.clobbers(v)
+	c.needscratch = c.needscratch || ssainfo[v.op].scratch
 	c.ops16(v, ssainfo[v.op].bc, imm)
 }
 
@@ -5573,6 +6094,7 @@ func emitStrEditStack1x1(v *value, c *compilestate) {
 	c.loadk(v, mask)
 	c.loads(v, str)
 	c.clobbers(v)
+	c.needscratch = c.needscratch || ssainfo[v.op].scratch
 	c.opu16s16(v, ssainfo[v.op].bc, imm2, imm1)
 }
 
@@ -0,0 +1,74 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+
+	"github.com/SnellerInc/sneller/date"
+)
+
+const timeFormatHeaderSize = 64
+
+// timeFormatProgram encodes f for the
+// bcparsets and bcformatts instructions as
+//
+//	[0:48]  the abbreviated month names (4 bytes each)
+//	[48:60] the number of days in each month
+//	        of a non-leap year (1 byte each)
+//	[60:64] the width of the formatted text (uint32)
+//	[64:]   the items of f as pairs of bytes
+//	        (the date.FormatKind and the literal byte)
+func timeFormatProgram(f date.Format) string {
+	buf := make([]byte, timeFormatHeaderSize, timeFormatHeaderSize+2*len(f))
+	for i, name := range date.MonthNames {
+		copy(buf[i*4:], name)
+	}
+	copy(buf[48:], []byte{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31})
+	binary.LittleEndian.PutUint32(buf[60:], uint32(f.Width()))
+	for i := range f {
+		buf = append(buf, byte(f[i].Kind), f[i].Lit)
+	}
+	return string(buf)
+}
+
+// ParseTimestamp parses str as a timestamp according
+// to f; lanes that do not match f are removed
+func (p *prog) ParseTimestamp(str *value, f date.Format) *value {
+	str = p.toStr(str)
+	return p.ssa2imm(sparsets, str, p.mask(str), timeFormatProgram(f))
+}
+
+// FormatTimestamp formats the timestamp v according to f
+func (p *prog) FormatTimestamp(v *value, f date.Format) *value {
+	ts, mask := p.coerceTimestamp(v)
+	return p.ssa2imm(sformatts, ts, mask, timeFormatProgram(f))
+}
+
+// FromUnixMicro converts a number of
+// microseconds since the Unix epoch to a timestamp
+func (p *prog) FromUnixMicro(v *value) *value {
+	var us, mask *value
+	if v.op != sliteral && v.primary() == stValue {
+		// accept both integers and floats
+		i := p.ssa3(stoint, p.undef(), v, p.mask(v))
+		f := p.ssa3(stofloat, p.undef(), v, p.ssa2(snand, i, p.mask(v)))
+		fi := p.ssa2(scvtftoi, f, f)
+		us, mask = p.ssa3(sblendint, i, fi, fi), p.Or(i, fi)
+	} else {
+		us, mask = p.coerceInt(v)
+	}
+	return p.ssa3imm(sdateaddmulimm, p.ssa0imm(sbroadcastts, 0), us, mask, uint64(1))
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// TestTimestampFormatBF compares PARSE_TIMESTAMP
+// and FORMAT_TIMESTAMP with date.Format for random
+// timestamps and (possibly mangled) strings
+func TestTimestampFormatBF(t *testing.T) {
+	formats := []string{
+		"%d/%b/%Y:%H:%M:%S %z",
+		"%Y-%m-%dT%H:%M:%S.%f%z",
+		"%b %e %T",
+		"%Y%m%d %H%M%S.%f",
+	}
+	const rows = 500
+	for _, text := range formats {
+		f, err := date.ParseFormat(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(0))
+		var st ion.Symtab
+		var in, out []ion.Datum
+		for i := 0; i < rows; i++ {
+			// years 1..9999
+			ts := date.UnixMicro(r.Int63n(315537897600000000) - 62135596800000000)
+			str, _ := f.Append(nil, ts)
+			switch r.Intn(4) {
+			case 0:
+				// a different (unformatted) time
+				other := date.UnixMicro(r.Int63n(1 << 52))
+				str, _ = f.Append(str[:0], other)
+				str[r.Intn(len(str))] = byte('0' + r.Intn(10))
+			case 1:
+				str[r.Intn(len(str))] = byte(' ' + r.Intn(95))
+			case 2:
+				str = str[:r.Intn(len(str))]
+			}
+			in = append(in, ion.NewStruct(&st, []ion.Field{
+				{Label: "t", Value: ion.Timestamp(ts)},
+				{Label: "s", Value: ion.String(string(str))},
+			}).Datum())
+
+			formatted, _ := f.Append(nil, ts)
+			fields := []ion.Field{{Label: "f", Value: ion.String(string(formatted))}}
+			if parsed, ok := f.Parse(str); ok {
+				fields = append(fields, ion.Field{Label: "p", Value: ion.Timestamp(parsed)})
+			}
+			out = append(out, ion.NewStruct(&st, fields).Datum())
+		}
+		query := fmt.Sprintf("SELECT FORMAT_TIMESTAMP('%[1]s', t) AS f, PARSE_TIMESTAMP('%[1]s', s) AS p FROM input", text)
+		t.Run(text, func(t *testing.T) {
+			testInput(t, []byte(query), &st, [][]ion.Datum{in}, out)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/expr"
+)
+
+// timeZoneTable encodes trans for the bcconvtz
+// instruction as the number of binary search steps
+// N followed by 1<<N start times and 1<<N offsets
+// (in microseconds, multiplied by sign); the table
+// is padded with entries that start at math.MaxInt64
+func timeZoneTable(trans []date.Transition, sign int64) string {
+	steps := bits.Len(uint(len(trans) - 1))
+	n := 1 << steps
+	buf := make([]byte, 8+16*n)
+	binary.LittleEndian.PutUint64(buf, uint64(steps))
+	starts := buf[8:]
+	offsets := buf[8+8*n:]
+	for i := 0; i < n; i++ {
+		start := int64(math.MaxInt64)
+		offset := int64(trans[len(trans)-1].Offset)
+		if i < len(trans) {
+			start = trans[i].Start
+			offset = int64(trans[i].Offset)
+		}
+		binary.LittleEndian.PutUint64(starts[8*i:], uint64(start))
+		binary.LittleEndian.PutUint64(offsets[8*i:], uint64(sign*offset*1000000))
+	}
+	return string(buf)
+}
+
+func (p *prog) convtz(v *value, trans []date.Transition, sign int64) *value {
+	ts, mask := p.coerceTimestamp(v)
+	if len(trans) == 1 && trans[0].Offset == 0 {
+		return ts
+	}
+	return p.ssa2imm(sconvtz, ts, mask, timeZoneTable(trans, sign))
+}
+
+// AtTimeZone returns the local date and time
+// of the timestamp v in the time zone z
+func (p *prog) AtTimeZone(v *value, z *date.Zone) *value {
+	return p.convtz(v, z.Transitions(), 1)
+}
+
+// FromTimeZone is the inverse of AtTimeZone
+func (p *prog) FromTimeZone(v *value, z *date.Zone) *value {
+	return p.convtz(v, z.LocalTransitions(), -1)
+}
+
+// DateTruncZone truncates the local time of v
+// in the time zone z and returns the corresponding
+// timestamp, so that DAY truncates to local midnight
+func (p *prog) DateTruncZone(part expr.Timepart, v *value, z *date.Zone) *value {
+	return p.FromTimeZone(p.DateTrunc(part, p.AtTimeZone(v, z)), z)
+}
+
+// TimeBucketZone is like TimeBucket, but
+// the buckets are aligned to the local time
+// of the timestamp in the time zone z
+func (p *prog) TimeBucketZone(timestamp, interval *value, z *date.Zone) *value {
+	local := p.TimeBucket(p.AtTimeZone(timestamp, z), interval)
+	start := p.ssa3imm(sdateaddmulimm, p.ssa0imm(sbroadcastts, 0), local, p.mask(local), uint64(1000000))
+	return p.DateToUnixEpoch(p.FromTimeZone(start, z))
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// TestTimeZoneBF compares AT TIME ZONE and
+// DATE_TRUNC with a time zone with date.Zone
+// for random timestamps
+func TestTimeZoneBF(t *testing.T) {
+	names := []string{
+		"America/New_York",
+		"Europe/Warsaw",
+		"Australia/Lord_Howe",
+		"Asia/Kolkata",
+	}
+	const rows = 500
+	for _, name := range names {
+		z, err := date.LoadZone(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(0))
+		var st ion.Symtab
+		var in, out []ion.Datum
+		for i := 0; i < rows; i++ {
+			// years 1970..2100
+			ts := date.UnixMicro(r.Int63n(4102444800000000))
+			in = append(in, ion.NewStruct(&st, []ion.Field{
+				{Label: "t", Value: ion.Timestamp(ts)},
+			}).Datum())
+			local := z.ToLocal(ts)
+			day := date.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0)
+			out = append(out, ion.NewStruct(&st, []ion.Field{
+				{Label: "l", Value: ion.Timestamp(local)},
+				{Label: "d", Value: ion.Timestamp(z.FromLocal(day))},
+			}).Datum())
+		}
+		query := fmt.Sprintf("SELECT t AT TIME ZONE '%[1]s' AS l, DATE_TRUNC(DAY, t, '%[1]s') AS d FROM input", name)
+		t.Run(name, func(t *testing.T) {
+			testInput(t, []byte(query), &st, [][]ion.Datum{in}, out)
+		})
+	}
+}
@@ -0,0 +1,546 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/sorting"
+)
+
+// Window is a QuerySink that computes
+// window functions over its input rows.
+//
+// Every output row is an input row with
+// one additional field for each window function.
+// Since the result of a window function depends
+// on the other rows in the same partition,
+// Window buffers all of its input and only
+// writes rows to the output when it is closed,
+// so the input must be small enough to fit in memory.
+//
+// See NewWindow
+type Window struct {
+	dst   QuerySink
+	funcs Aggregation
+
+	lock sync.Mutex
+	rows []ion.Struct
+}
+
+// NewWindow constructs a Window that computes
+// each of the window functions in funcs and
+// writes its output to dst.
+//
+// Every expression that is an argument to a
+// window function (including the PARTITION BY
+// and ORDER BY expressions) must be either a
+// constant or a path expression.
+func NewWindow(funcs Aggregation, dst QuerySink) (*Window, error) {
+	for i := range funcs {
+		agg := funcs[i].Expr
+		if agg.Over == nil {
+			return nil, fmt.Errorf("vm.NewWindow: %s is not a window function", expr.ToString(agg))
+		}
+		switch agg.Op {
+		case expr.OpRowNumber, expr.OpRank, expr.OpDenseRank,
+			expr.OpLag, expr.OpLead, expr.OpFirstValue, expr.OpLastValue,
+			expr.OpCount, expr.OpCountDistinct, expr.OpSum, expr.OpSumInt, expr.OpSumCount,
+			expr.OpAvg, expr.OpMin, expr.OpMax, expr.OpEarliest, expr.OpLatest,
+			expr.OpBitAnd, expr.OpBitOr, expr.OpBitXor, expr.OpBoolAnd, expr.OpBoolOr:
+		default:
+			return nil, fmt.Errorf("vm.NewWindow: unsupported window function %s", agg.Op)
+		}
+		var err error
+		check := func(e expr.Node) {
+			switch e.(type) {
+			case *expr.Path, expr.Constant, expr.Star:
+			default:
+				err = fmt.Errorf("vm.NewWindow: unsupported argument %s", expr.ToString(e))
+			}
+		}
+		check(agg.Inner)
+		for j := range agg.Args {
+			check(agg.Args[j])
+		}
+		if agg.Filter != nil {
+			check(agg.Filter)
+		}
+		for j := range agg.Over.PartitionBy {
+			check(agg.Over.PartitionBy[j])
+		}
+		for j := range agg.Over.OrderBy {
+			check(agg.Over.OrderBy[j].Column)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Window{dst: dst, funcs: funcs}, nil
+}
+
+type windowWriter struct {
+	parent *Window
+	// st is the symbol table of the rows
+	// that are being decoded; the rows that
+	// have already been buffered refer to it,
+	// so it is replaced (rather than modified)
+	// whenever the input contains a new one
+	st  *ion.Symtab
+	tmp []ion.Struct
+}
+
+// Open implements QuerySink.Open
+func (w *Window) Open() (io.WriteCloser, error) {
+	return &windowWriter{parent: w, st: new(ion.Symtab)}, nil
+}
+
+func (w *windowWriter) Write(buf []byte) (int, error) {
+	// the input buffer is re-used after
+	// Write returns, and the rows we keep
+	// reference the buffer directly
+	buf = slices.Clone(buf)
+	orig := len(buf)
+	w.tmp = w.tmp[:0]
+	var err error
+	var d ion.Datum
+	for len(buf) > 0 {
+		if ion.IsBVM(buf) || ion.TypeOf(buf) == ion.AnnotationType {
+			st := new(ion.Symtab)
+			w.st.CloneInto(st)
+			w.st = st
+		}
+		d, buf, err = ion.ReadDatum(w.st, buf)
+		if err != nil {
+			return orig - len(buf), err
+		}
+		if d.Empty() || d.Null() {
+			continue // symbol table or nop pad
+		}
+		s, ok := d.Struct()
+		if !ok {
+			return orig - len(buf), fmt.Errorf("vm.Window: unexpected non-struct row")
+		}
+		w.tmp = append(w.tmp, s)
+	}
+	w.parent.lock.Lock()
+	defer w.parent.lock.Unlock()
+	w.parent.rows = append(w.parent.rows, w.tmp...)
+	return orig, nil
+}
+
+// EndSegment implements EndSegmentWriter.EndSegment
+//
+// Rows are copied out of each buffer as
+// they are written, so there is nothing
+// to release here.
+func (w *windowWriter) EndSegment() {}
+
+func (w *windowWriter) Close() error { return nil }
+
+// Close implements io.Closer.Close
+//
+// Close computes the window functions and
+// writes all of the output rows to the output.
+func (w *Window) Close() error {
+	if len(w.rows) == 0 {
+		return w.dst.Close()
+	}
+	results := make([][]ion.Field, len(w.rows))
+	for i := range w.funcs {
+		w.compute(&w.funcs[i], results)
+	}
+	var st symtab
+	defer st.free()
+	var buf ion.Buffer
+	var fields []ion.Field
+	for i := range w.rows {
+		fields = fields[:0]
+		w.rows[i].Each(func(f ion.Field) bool {
+			for j := range results[i] {
+				if results[i][j].Label == f.Label {
+					return true
+				}
+			}
+			fields = append(fields, f)
+			return true
+		})
+		for j := range results[i] {
+			if !results[i][j].Value.Empty() {
+				fields = append(fields, results[i][j])
+			}
+		}
+		buf.WriteStruct(&st.Symtab, fields)
+	}
+	st.build()
+	out, err := w.dst.Open()
+	if err == nil {
+		err = writeAll(out, &st, buf.Bytes())
+	}
+	err2 := w.dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+// windowValue evaluates e (a constant or path)
+// relative to row; it returns ion.Empty
+// if the result is MISSING
+func windowValue(e expr.Node, row ion.Struct) ion.Datum {
+	switch e := e.(type) {
+	case *expr.Path:
+		d, ok := structpath(row, e)
+		if !ok {
+			return ion.Empty
+		}
+		return d
+	case expr.Constant:
+		return e.Datum()
+	case expr.Star:
+		return ion.Bool(true)
+	default:
+		return ion.Empty
+	}
+}
+
+// windowKeys encodes the values of the PARTITION BY
+// and ORDER BY columns of each row so that they can
+// be compared with sorting.Ordering.Compare
+func (w *Window) windowKeys(over *expr.Window) [][][]byte {
+	var st ion.Symtab
+	var buf ion.Buffer
+	cols := make([]expr.Node, 0, len(over.PartitionBy)+len(over.OrderBy))
+	cols = append(cols, over.PartitionBy...)
+	for i := range over.OrderBy {
+		cols = append(cols, over.OrderBy[i].Column)
+	}
+	keys := make([][][]byte, len(w.rows))
+	for i := range w.rows {
+		row := make([][]byte, len(cols))
+		for j := range cols {
+			buf.Reset()
+			d := windowValue(cols[j], w.rows[i])
+			if d.Empty() {
+				// MISSING sorts along with NULL
+				d = ion.Null
+			} else if d.Type() == ion.SymbolType {
+				// symbols are compared as strings
+				s, _ := d.String()
+				d = ion.String(s)
+			}
+			d.Encode(&buf, &st)
+			row[j] = slices.Clone(buf.Bytes())
+		}
+		keys[i] = row
+	}
+	return keys
+}
+
+// compute evaluates one window function
+// and appends its result to results
+func (w *Window) compute(fn *AggBinding, results [][]ion.Field) {
+	agg := fn.Expr
+	over := agg.Over
+	keys := w.windowKeys(over)
+	np := len(over.PartitionBy)
+	orders := make([]sorting.Ordering, len(keys[0]))
+	for i := range orders {
+		orders[i] = sorting.Ordering{Direction: sorting.Ascending, Nulls: sorting.NullsFirst}
+		if i >= np {
+			o := &over.OrderBy[i-np]
+			if o.Desc {
+				orders[i].Direction = sorting.Descending
+			}
+			if o.NullsLast {
+				orders[i].Nulls = sorting.NullsLast
+			}
+		}
+	}
+	// cmp compares rows i and j using
+	// the key columns in [from, to)
+	cmp := func(i, j, from, to int) int {
+		for k := from; k < to; k++ {
+			if c := orders[k].Compare(keys[i][k], keys[j][k]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	idx := make([]int, len(w.rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return cmp(idx[i], idx[j], 0, len(orders)) < 0
+	})
+	values := make([]ion.Datum, len(w.rows))
+	ordered := len(over.OrderBy) > 0
+	for start := 0; start < len(idx); {
+		end := start + 1
+		for end < len(idx) && cmp(idx[start], idx[end], 0, np) == 0 {
+			end++
+		}
+		var acc windowAcc
+		acc.init(agg.Op)
+		group := 0
+		for peer := start; peer < end; {
+			// rows that are equal with respect to
+			// ORDER BY are "peers" that share the
+			// same frame
+			peerEnd := peer + 1
+			for peerEnd < end && cmp(idx[peer], idx[peerEnd], np, len(orders)) == 0 {
+				peerEnd++
+			}
+			group++
+			frameEnd := end
+			if ordered {
+				frameEnd = peerEnd
+			}
+			for k := peer; k < peerEnd; k++ {
+				row := idx[k]
+				switch agg.Op {
+				case expr.OpRowNumber:
+					values[row] = ion.Uint(uint64(k - start + 1))
+				case expr.OpRank:
+					values[row] = ion.Uint(uint64(peer - start + 1))
+				case expr.OpDenseRank:
+					values[row] = ion.Uint(uint64(group))
+				case expr.OpLag, expr.OpLead:
+					offset := 1
+					if len(agg.Args) > 0 {
+						offset = int(agg.Args[0].(expr.Integer))
+					}
+					if agg.Op == expr.OpLag {
+						offset = -offset
+					}
+					if j := k + offset; j >= start && j < end {
+						values[row] = windowValue(agg.Inner, w.rows[idx[j]])
+					} else if len(agg.Args) > 1 {
+						values[row] = windowValue(agg.Args[1], w.rows[row])
+					} else {
+						values[row] = ion.Null
+					}
+				case expr.OpFirstValue:
+					values[row] = windowValue(agg.Inner, w.rows[idx[start]])
+				case expr.OpLastValue:
+					values[row] = windowValue(agg.Inner, w.rows[idx[frameEnd-1]])
+				}
+			}
+			if !agg.Op.WindowOnly() {
+				// accumulate the rest of the frame
+				// and assign the result to each peer
+				last := peerEnd
+				if !ordered {
+					last = end
+				}
+				for k := peer; k < last; k++ {
+					row := w.rows[idx[k]]
+					if agg.Filter != nil {
+						if b, ok := windowValue(agg.Filter, row).Bool(); !ok || !b {
+							continue
+						}
+					}
+					acc.add(windowValue(agg.Inner, row))
+				}
+				res := acc.result()
+				for k := peer; k < frameEnd; k++ {
+					values[idx[k]] = res
+				}
+				if !ordered {
+					break
+				}
+			}
+			peer = peerEnd
+		}
+		start = end
+	}
+	for i := range values {
+		results[i] = append(results[i], ion.Field{Label: fn.Result, Value: values[i]})
+	}
+}
+
+// windowAcc accumulates the
+// result of an aggregate window function
+type windowAcc struct {
+	op       expr.AggregateOp
+	n        int64
+	isum     int64
+	fsum     float64
+	isfloat  bool
+	best     ion.Datum
+	bits     int64
+	bool     bool
+	time     date.Time
+	distinct map[string]struct{}
+}
+
+func (w *windowAcc) init(op expr.AggregateOp) {
+	*w = windowAcc{op: op}
+	switch op {
+	case expr.OpBitAnd:
+		w.bits = -1
+	case expr.OpBoolAnd:
+		w.bool = true
+	case expr.OpCountDistinct:
+		w.distinct = make(map[string]struct{})
+	}
+}
+
+// winNumber returns the value of d as an integer
+// or a float (if it cannot be represented as an integer)
+func winNumber(d ion.Datum) (i int64, f float64, isfloat, ok bool) {
+	switch d.Type() {
+	case ion.IntType:
+		i, _ = d.Int()
+		return i, float64(i), false, true
+	case ion.UintType:
+		u, _ := d.Uint()
+		if u > math.MaxInt64 {
+			return 0, float64(u), true, true
+		}
+		return int64(u), float64(u), false, true
+	case ion.FloatType:
+		f, _ = d.Float()
+		return 0, f, true, true
+	default:
+		return 0, 0, false, false
+	}
+}
+
+// winLess returns whether the number a is less than b
+func winLess(a, b ion.Datum) bool {
+	ai, af, afloat, _ := winNumber(a)
+	bi, bf, bfloat, _ := winNumber(b)
+	if !afloat && !bfloat {
+		return ai < bi
+	}
+	return af < bf
+}
+
+func (w *windowAcc) add(d ion.Datum) {
+	if d.Empty() {
+		return
+	}
+	switch w.op {
+	case expr.OpCount:
+		w.n++
+	case expr.OpCountDistinct:
+		if k, ok := joinkey(d); ok {
+			w.distinct[k] = struct{}{}
+		}
+	case expr.OpSum, expr.OpSumInt, expr.OpSumCount, expr.OpAvg:
+		i, f, isfloat, ok := winNumber(d)
+		if !ok {
+			return
+		}
+		w.n++
+		if isfloat {
+			w.isfloat = true
+			w.fsum += f
+		} else {
+			w.isum += i
+		}
+	case expr.OpMin, expr.OpMax:
+		if _, _, _, ok := winNumber(d); !ok {
+			return
+		}
+		if w.n == 0 || (w.op == expr.OpMin) == winLess(d, w.best) {
+			w.best = d
+		}
+		w.n++
+	case expr.OpEarliest, expr.OpLatest:
+		t, ok := d.Timestamp()
+		if !ok {
+			return
+		}
+		if w.n == 0 || (w.op == expr.OpEarliest && t.Before(w.time)) ||
+			(w.op == expr.OpLatest && t.After(w.time)) {
+			w.time = t
+		}
+		w.n++
+	case expr.OpBitAnd, expr.OpBitOr, expr.OpBitXor:
+		i, _, isfloat, ok := winNumber(d)
+		if !ok || isfloat {
+			return
+		}
+		switch w.op {
+		case expr.OpBitAnd:
+			w.bits &= i
+		case expr.OpBitOr:
+			w.bits |= i
+		default:
+			w.bits ^= i
+		}
+		w.n++
+	case expr.OpBoolAnd, expr.OpBoolOr:
+		b, ok := d.Bool()
+		if !ok {
+			return
+		}
+		if w.op == expr.OpBoolAnd {
+			w.bool = w.bool && b
+		} else {
+			w.bool = w.bool || b
+		}
+		w.n++
+	}
+}
+
+func (w *windowAcc) result() ion.Datum {
+	switch w.op {
+	case expr.OpCount:
+		return ion.Uint(uint64(w.n))
+	case expr.OpCountDistinct:
+		return ion.Uint(uint64(len(w.distinct)))
+	}
+	if w.n == 0 {
+		if w.op == expr.OpSumCount {
+			return ion.Int(0)
+		}
+		return ion.Null
+	}
+	switch w.op {
+	case expr.OpSum, expr.OpSumInt, expr.OpSumCount:
+		if w.isfloat {
+			return ion.Float(w.fsum + float64(w.isum))
+		}
+		return ion.Int(w.isum)
+	case expr.OpAvg:
+		if w.isfloat {
+			return ion.Float((w.fsum + float64(w.isum)) / float64(w.n))
+		}
+		return ion.Int(w.isum / w.n)
+	case expr.OpMin, expr.OpMax:
+		return w.best
+	case expr.OpEarliest, expr.OpLatest:
+		return ion.Timestamp(w.time)
+	case expr.OpBitAnd, expr.OpBitOr, expr.OpBitXor:
+		return ion.Int(w.bits)
+	case expr.OpBoolAnd, expr.OpBoolOr:
+		return ion.Bool(w.bool)
+	default:
+		return ion.Null
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// rows buffered by Window must keep their
+// symbols when a later chunk resets the symbol table
+func TestWindowSymbolTableReset(t *testing.T) {
+	chunk := func(names []string, rows [][]ion.Field) []byte {
+		var st ion.Symtab
+		for _, name := range names {
+			st.Intern(name)
+		}
+		var buf ion.Buffer
+		st.Marshal(&buf, true)
+		for i := range rows {
+			ion.NewStruct(&st, rows[i]).Encode(&buf, &st)
+		}
+		return buf.Bytes()
+	}
+	first := chunk([]string{"a", "b"}, [][]ion.Field{
+		{{Label: "a", Value: ion.Int(2)}, {Label: "b", Value: ion.String("x")}},
+	})
+	second := chunk([]string{"b", "a"}, [][]ion.Field{
+		{{Label: "b", Value: ion.String("y")}, {Label: "a", Value: ion.Int(1)}},
+	})
+
+	var dst QueryBuffer
+	w, err := NewWindow(Aggregation{{
+		Expr: &expr.Aggregate{
+			Op:    expr.OpRowNumber,
+			Inner: expr.Star{},
+			Over: &expr.Window{
+				OrderBy: []expr.Order{{Column: expr.Identifier("a")}},
+			},
+		},
+		Result: "n",
+	}}, &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc, err := w.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, buf := range [][]byte{first, second} {
+		if _, err := wc.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var st ion.Symtab
+	var got []string
+	buf := dst.Bytes()
+	for len(buf) > 0 {
+		var d ion.Datum
+		d, buf, err = ion.ReadDatum(&st, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Empty() || d.Null() {
+			continue
+		}
+		s, ok := d.Struct()
+		if !ok {
+			t.Fatalf("unexpected datum %v", d)
+		}
+		row := ""
+		s.Each(func(f ion.Field) bool {
+			if str, ok := f.Value.String(); ok {
+				row += fmt.Sprintf("%s=%s ", f.Label, str)
+			} else if u, ok := f.Value.Uint(); ok {
+				row += fmt.Sprintf("%s=%d ", f.Label, u)
+			}
+			return true
+		})
+		got = append(got, row)
+	}
+	want := []string{
+		"a=2 b=x n=2 ",
+		"a=1 b=y n=1 ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}